@@ -12,29 +12,94 @@ type Config struct {
 	Server                 *server.Config
 	SwaggerManifest        string `envconfig:"SWAGGER_MANIFEST_PATH"`
 	DefaultSegmentDuration uint   `envconfig:"DEFAULT_SEGMENT_DURATION" default:"5"`
-	Redis                  *storage.Config
-	EncodingCom            *EncodingCom
-	ElasticTranscoder      *ElasticTranscoder
-	ElementalConductor     *ElementalConductor
-	Zencoder               *Zencoder
-	Bitmovin               *Bitmovin
+
+	// Semicolon-separated list of named failover policies, each one
+	// being an ordered list of providers.
+	//
+	// Example: default:zencoder,encodingcom;hls:bitmovin,elastictranscoder.
+	FailoverPolicies string `envconfig:"FAILOVER_POLICIES"`
+
+	// Comma-separated list of destinations that jobs may use instead of
+	// the destination configured in the provider. Jobs can write to the
+	// listed destinations and to any path under them, and can't override
+	// the destination when the list is empty.
+	//
+	// Example: s3://videos-bucket/,gs://archive-bucket/transcoded/.
+	AllowedDestinations string `envconfig:"ALLOWED_DESTINATIONS"`
+
+	// Comma-separated list of the Azure storage accounts used by Azure
+	// destinations (azure://<account>/<container>/path), along with
+	// their access keys, so keys are never part of destinations.
+	//
+	// Example: videosaccount:base64-key,archiveaccount:base64-key.
+	AzureStorageAccounts string `envconfig:"AZURE_STORAGE_ACCOUNTS"`
+
+	Redis              *storage.Config
+	EncodingCom        *EncodingCom
+	ElasticTranscoder  *ElasticTranscoder
+	MediaConvert       *MediaConvert
+	GCPTranscoder      *GCPTranscoder
+	ElementalConductor *ElementalConductor
+	Zencoder           *Zencoder
+	Bitmovin           *Bitmovin
+	FFmpeg             *FFmpeg
+	FakeProvider       *FakeProvider
+	Notifications      *Notifications
+	StatusPoller       *StatusPoller
+	PresetReconciler   *PresetReconciler
+	Routing            *Routing
+	Probe              *Probe
 }
 
 // EncodingCom represents the set of configurations for the Encoding.com
 // provider.
 type EncodingCom struct {
-	UserID         string `envconfig:"ENCODINGCOM_USER_ID"`
-	UserKey        string `envconfig:"ENCODINGCOM_USER_KEY"`
-	Destination    string `envconfig:"ENCODINGCOM_DESTINATION"`
-	Region         string `envconfig:"ENCODINGCOM_REGION"`
-	StatusEndpoint string `envconfig:"ENCODINGCOM_STATUS_ENDPOINT" default:"http://status.encoding.com"`
+	UserID            string `envconfig:"ENCODINGCOM_USER_ID"`
+	UserKey           string `envconfig:"ENCODINGCOM_USER_KEY"`
+	Destination       string `envconfig:"ENCODINGCOM_DESTINATION"`
+	Region            string `envconfig:"ENCODINGCOM_REGION"`
+	StatusEndpoint    string `envconfig:"ENCODINGCOM_STATUS_ENDPOINT" default:"http://status.encoding.com"`
+	NotificationToken string `envconfig:"ENCODINGCOM_NOTIFICATION_TOKEN"`
 }
 
 // Zencoder represents the set of configurations for the Zencoder
 // provider.
 type Zencoder struct {
-	APIKey      string `envconfig:"ZENCODER_API_KEY"`
-	Destination string `envconfig:"ZENCODER_DESTINATION"`
+	APIKey            string `envconfig:"ZENCODER_API_KEY"`
+	Destination       string `envconfig:"ZENCODER_DESTINATION"`
+	NotificationToken string `envconfig:"ZENCODER_NOTIFICATION_TOKEN"`
+}
+
+// FFmpeg represents the set of configurations for the FFmpeg provider, that
+// transcodes media files locally.
+type FFmpeg struct {
+	BinaryPath  string `envconfig:"FFMPEG_BINARY_PATH" default:"ffmpeg"`
+	Destination string `envconfig:"FFMPEG_DESTINATION"`
+	Concurrency uint   `envconfig:"FFMPEG_CONCURRENCY" default:"2"`
+
+	// Directory that local (file://) sources must be in. Local sources
+	// are refused when empty.
+	SourceRoot string `envconfig:"FFMPEG_SOURCE_ROOT"`
+}
+
+// FakeProvider represents the set of configurations for the fake provider,
+// that simulates the lifecycle of jobs without transcoding anything. It's
+// meant to be used in integration tests.
+type FakeProvider struct {
+	Enabled     bool   `envconfig:"FAKE_PROVIDER_ENABLED"`
+	Destination string `envconfig:"FAKE_PROVIDER_DESTINATION" default:"s3://fake-bucket/"`
+
+	// Time, in milliseconds, that jobs stay queued and then in progress.
+	QueueTime      uint `envconfig:"FAKE_PROVIDER_QUEUE_TIME_MS" default:"1000"`
+	ProcessingTime uint `envconfig:"FAKE_PROVIDER_PROCESSING_TIME_MS" default:"5000"`
+
+	// Probability, between 0 and 1, of a job failing. Jobs with sources
+	// matching FailurePattern (a regular expression) always fail.
+	FailureRate    float64 `envconfig:"FAKE_PROVIDER_FAILURE_RATE"`
+	FailurePattern string  `envconfig:"FAKE_PROVIDER_FAILURE_PATTERN"`
+
+	// Makes the healthcheck of the provider fail, for testing failover.
+	Unhealthy bool `envconfig:"FAKE_PROVIDER_UNHEALTHY"`
 }
 
 // ElasticTranscoder represents the set of configurations for the Elastic
@@ -44,6 +109,45 @@ type ElasticTranscoder struct {
 	SecretAccessKey string `envconfig:"AWS_SECRET_ACCESS_KEY"`
 	Region          string `envconfig:"AWS_REGION"`
 	PipelineID      string `envconfig:"ELASTICTRANSCODER_PIPELINE_ID"`
+
+	// ARN of the SNS topic configured in the pipeline for notifications.
+	// Notifications from other topics are rejected.
+	NotificationTopicARN string `envconfig:"ELASTICTRANSCODER_NOTIFICATION_TOPIC_ARN"`
+}
+
+// MediaConvert represents the set of configurations for the MediaConvert
+// provider.
+type MediaConvert struct {
+	AccessKeyID     string `envconfig:"AWS_ACCESS_KEY_ID"`
+	SecretAccessKey string `envconfig:"AWS_SECRET_ACCESS_KEY"`
+	Region          string `envconfig:"AWS_REGION"`
+
+	// Account-specific endpoint of the MediaConvert API, as returned by
+	// the DescribeEndpoints operation.
+	Endpoint string `envconfig:"MEDIACONVERT_ENDPOINT"`
+
+	// ARN of the queue where jobs are submitted. Jobs are submitted to
+	// the default queue when empty.
+	Queue string `envconfig:"MEDIACONVERT_QUEUE_ARN"`
+
+	// ARN of the IAM role that MediaConvert assumes for reading the
+	// source media and writing the outputs.
+	Role        string `envconfig:"MEDIACONVERT_ROLE_ARN"`
+	Destination string `envconfig:"MEDIACONVERT_DESTINATION"`
+}
+
+// GCPTranscoder represents the set of configurations for the Google Cloud
+// Transcoder API provider.
+type GCPTranscoder struct {
+	ProjectID string `envconfig:"GCP_TRANSCODER_PROJECT_ID"`
+	Location  string `envconfig:"GCP_TRANSCODER_LOCATION" default:"us-central1"`
+
+	// Path to the JSON key of the service account used for
+	// authenticating with the API. Application default credentials are
+	// used when empty.
+	CredentialsFile string `envconfig:"GCP_TRANSCODER_CREDENTIALS_FILE"`
+	Endpoint        string `envconfig:"GCP_TRANSCODER_ENDPOINT" default:"https://transcoder.googleapis.com/v1/"`
+	Destination     string `envconfig:"GCP_TRANSCODER_DESTINATION"`
 }
 
 // ElementalConductor represents the set of configurations for the Elemental
@@ -72,18 +176,104 @@ type Bitmovin struct {
 	EncodingVersion  string `envconfig:"BITMOVIN_ENCODING_VERSION" default:"STABLE"`
 }
 
+// Notifications represents the set of configurations for delivering
+// notifications to the callback URL of jobs.
+type Notifications struct {
+	MaxAttempts    uint `envconfig:"NOTIFICATIONS_MAX_ATTEMPTS" default:"5"`
+	InitialBackoff uint `envconfig:"NOTIFICATIONS_INITIAL_BACKOFF_SECONDS" default:"1"`
+	MaxBackoff     uint `envconfig:"NOTIFICATIONS_MAX_BACKOFF_SECONDS" default:"60"`
+	Timeout        uint `envconfig:"NOTIFICATIONS_TIMEOUT_SECONDS" default:"10"`
+
+	// Comma-separated list of hosts that callback URLs can point to. When
+	// empty, callback URLs can point to any host, except for loopback,
+	// private and link-local addresses.
+	//
+	// Example: callbacks.example.com,hooks.example.net.
+	AllowedCallbackHosts string `envconfig:"NOTIFICATIONS_ALLOWED_CALLBACK_HOSTS"`
+}
+
+// StatusPoller represents the set of configurations for the background worker
+// that refreshes the status of jobs that are not done yet.
+type StatusPoller struct {
+	Enabled     bool `envconfig:"STATUS_POLLER_ENABLED"`
+	Concurrency uint `envconfig:"STATUS_POLLER_CONCURRENCY" default:"4"`
+	Interval    uint `envconfig:"STATUS_POLLER_INTERVAL_SECONDS" default:"30"`
+
+	// Comma-separated list of provider:seconds pairs, overriding Interval
+	// for the given providers.
+	//
+	// Example: zencoder:10,encodingcom:60.
+	ProviderIntervals string `envconfig:"STATUS_POLLER_PROVIDER_INTERVALS"`
+
+	// Jobs older than the given number of hours are not polled anymore,
+	// even if they're not done.
+	MaxJobAge uint `envconfig:"STATUS_POLLER_MAX_JOB_AGE_HOURS" default:"72"`
+}
+
+// PresetReconciler represents the set of configurations for the background
+// worker that detects presets that drifted from their definition in
+// providers.
+type PresetReconciler struct {
+	Enabled  bool `envconfig:"PRESET_RECONCILER_ENABLED"`
+	Interval uint `envconfig:"PRESET_RECONCILER_INTERVAL_MINUTES" default:"60"`
+
+	// When enabled, drifted presets are re-created in providers and the
+	// presetmap is updated.
+	Repair bool `envconfig:"PRESET_RECONCILER_REPAIR"`
+}
+
+// Routing represents the set of configurations for choosing the provider of
+// jobs that don't specify one.
+type Routing struct {
+	// JSON-encoded list of routing rules, evaluated in order. The first
+	// rule that matches the job defines the providers that may be used.
+	//
+	// Example: [{"name":"hls","streamingProtocols":["hls"],"providers":[{"name":"zencoder","weight":3},{"name":"bitmovin","weight":1}]}].
+	Rules string `envconfig:"ROUTING_RULES"`
+}
+
+// Probe represents the set of configurations for inspecting the source media
+// of jobs before submitting them to providers.
+type Probe struct {
+	// Enables the pre-flight probing of sources when creating jobs. Jobs
+	// may enable or disable it individually.
+	Enabled bool `envconfig:"PROBE_ENABLED"`
+	Timeout uint `envconfig:"PROBE_TIMEOUT_SECONDS" default:"10"`
+
+	// Comma-separated list of rules that jobs must satisfy when their
+	// sources are probed. Supported rules are presetHeight and presetWidth
+	// (the source can't be smaller than the presets), requireVideo,
+	// requireAudio and maxDuration:<seconds>.
+	//
+	// Example: presetHeight,requireAudio,maxDuration:7200.
+	Rules string `envconfig:"PROBE_RULES"`
+
+	// Directory that local (file://) sources must be in to be probed.
+	// Local sources aren't probed when empty.
+	FileRoot string `envconfig:"PROBE_FILE_ROOT"`
+}
+
 // LoadConfig loads the configuration of the API using environment variables.
 func LoadConfig() *Config {
 	cfg := Config{
 		Redis:              new(storage.Config),
 		EncodingCom:        new(EncodingCom),
 		ElasticTranscoder:  new(ElasticTranscoder),
+		MediaConvert:       new(MediaConvert),
+		GCPTranscoder:      new(GCPTranscoder),
 		ElementalConductor: new(ElementalConductor),
 		Bitmovin:           new(Bitmovin),
+		FFmpeg:             new(FFmpeg),
+		FakeProvider:       new(FakeProvider),
+		Notifications:      new(Notifications),
+		StatusPoller:       new(StatusPoller),
+		PresetReconciler:   new(PresetReconciler),
+		Routing:            new(Routing),
+		Probe:              new(Probe),
 		Server:             new(server.Config),
 	}
 	config.LoadEnvConfig(&cfg)
-	loadFromEnv(cfg.Redis, cfg.EncodingCom, cfg.ElasticTranscoder, cfg.ElementalConductor, cfg.Bitmovin, cfg.Server)
+	loadFromEnv(cfg.Redis, cfg.EncodingCom, cfg.ElasticTranscoder, cfg.MediaConvert, cfg.GCPTranscoder, cfg.ElementalConductor, cfg.Bitmovin, cfg.FFmpeg, cfg.FakeProvider, cfg.Notifications, cfg.StatusPoller, cfg.PresetReconciler, cfg.Routing, cfg.Probe, cfg.Server)
 	return &cfg
 }
 
@@ -24,10 +24,21 @@ func TestLoadConfigFromEnv(t *testing.T) {
 		"ENCODINGCOM_DESTINATION":                  "https://safe-stuff",
 		"ENCODINGCOM_STATUS_ENDPOINT":              "https://safe-status",
 		"ENCODINGCOM_REGION":                       "sa-east-1",
+		"ENCODINGCOM_NOTIFICATION_TOKEN":           "notification-token",
 		"AWS_ACCESS_KEY_ID":                        "AKIANOTREALLY",
 		"AWS_SECRET_ACCESS_KEY":                    "secret-key",
 		"AWS_REGION":                               "us-east-1",
 		"ELASTICTRANSCODER_PIPELINE_ID":            "mypipeline",
+		"ELASTICTRANSCODER_NOTIFICATION_TOPIC_ARN": "arn:aws:sns:us-east-1:123456789012:transcoding",
+		"MEDIACONVERT_ENDPOINT":                    "https://abcd1234.mediaconvert.us-east-1.amazonaws.com",
+		"MEDIACONVERT_QUEUE_ARN":                   "arn:aws:mediaconvert:us-east-1:123456789012:queues/transcoding",
+		"MEDIACONVERT_ROLE_ARN":                    "arn:aws:iam::123456789012:role/MediaConvert",
+		"MEDIACONVERT_DESTINATION":                 "s3://my-bucket/",
+		"GCP_TRANSCODER_PROJECT_ID":                "my-project",
+		"GCP_TRANSCODER_LOCATION":                  "europe-west1",
+		"GCP_TRANSCODER_CREDENTIALS_FILE":          "/etc/transcoding/credentials.json",
+		"GCP_TRANSCODER_ENDPOINT":                  "http://transcoder",
+		"GCP_TRANSCODER_DESTINATION":               "gs://my-bucket/",
 		"ELEMENTALCONDUCTOR_HOST":                  "elemental-server",
 		"ELEMENTALCONDUCTOR_USER_LOGIN":            "myuser",
 		"ELEMENTALCONDUCTOR_API_KEY":               "secret-key",
@@ -44,15 +55,50 @@ func TestLoadConfigFromEnv(t *testing.T) {
 		"BITMOVIN_AWS_STORAGE_REGION":              "US_WEST_1",
 		"BITMOVIN_ENCODING_REGION":                 "GOOGLE_EUROPE_WEST_1",
 		"BITMOVIN_ENCODING_VERSION":                "notstable",
+		"FFMPEG_BINARY_PATH":                       "/usr/local/bin/ffmpeg",
+		"FFMPEG_DESTINATION":                       "/var/lib/transcoding",
+		"FFMPEG_CONCURRENCY":                       "4",
+		"FFMPEG_SOURCE_ROOT":                       "/var/media",
+		"FAKE_PROVIDER_ENABLED":                    "true",
+		"FAKE_PROVIDER_DESTINATION":                "s3://my-bucket/",
+		"FAKE_PROVIDER_QUEUE_TIME_MS":              "10",
+		"FAKE_PROVIDER_PROCESSING_TIME_MS":         "100",
+		"FAKE_PROVIDER_FAILURE_RATE":               "0.1",
+		"FAKE_PROVIDER_FAILURE_PATTERN":            "fail",
+		"FAKE_PROVIDER_UNHEALTHY":                  "true",
+		"NOTIFICATIONS_MAX_ATTEMPTS":               "3",
+		"NOTIFICATIONS_INITIAL_BACKOFF_SECONDS":    "2",
+		"NOTIFICATIONS_MAX_BACKOFF_SECONDS":        "30",
+		"NOTIFICATIONS_TIMEOUT_SECONDS":            "5",
+		"NOTIFICATIONS_ALLOWED_CALLBACK_HOSTS":     "callbacks.example.com",
+		"STATUS_POLLER_ENABLED":                    "true",
+		"STATUS_POLLER_CONCURRENCY":                "10",
+		"STATUS_POLLER_INTERVAL_SECONDS":           "15",
+		"STATUS_POLLER_PROVIDER_INTERVALS":         "zencoder:10,encodingcom:60",
+		"STATUS_POLLER_MAX_JOB_AGE_HOURS":          "24",
+		"PRESET_RECONCILER_ENABLED":                "true",
+		"PRESET_RECONCILER_INTERVAL_MINUTES":       "30",
+		"PRESET_RECONCILER_REPAIR":                 "true",
+		"ROUTING_RULES":                            `[{"name":"default","providers":[{"name":"zencoder"}]}]`,
+		"PROBE_ENABLED":                            "true",
+		"PROBE_TIMEOUT_SECONDS":                    "5",
+		"PROBE_RULES":                              "presetHeight,maxDuration:7200",
+		"PROBE_FILE_ROOT":                          "/var/media",
 		"SWAGGER_MANIFEST_PATH":                    "/opt/video-transcoding-api-swagger.json",
 		"HTTP_ACCESS_LOG":                          accessLog,
 		"HTTP_PORT":                                "8080",
 		"DEFAULT_SEGMENT_DURATION":                 "3",
+		"FAILOVER_POLICIES":                        "default:zencoder,encodingcom",
+		"ALLOWED_DESTINATIONS":                     "s3://videos-bucket/,gs://archive-bucket/",
+		"AZURE_STORAGE_ACCOUNTS":                   "videosaccount:secret-key",
 	})
 	cfg := LoadConfig()
 	expectedCfg := Config{
 		SwaggerManifest:        "/opt/video-transcoding-api-swagger.json",
 		DefaultSegmentDuration: 3,
+		FailoverPolicies:       "default:zencoder,encodingcom",
+		AllowedDestinations:    "s3://videos-bucket/,gs://archive-bucket/",
+		AzureStorageAccounts:   "videosaccount:secret-key",
 		Redis: &storage.Config{
 			SentinelAddrs:      "10.10.10.10:26379,10.10.10.11:26379,10.10.10.12:26379",
 			SentinelMasterName: "super-master",
@@ -62,17 +108,35 @@ func TestLoadConfigFromEnv(t *testing.T) {
 			PoolTimeout:        10,
 		},
 		EncodingCom: &EncodingCom{
-			UserID:         "myuser",
-			UserKey:        "secret-key",
-			Destination:    "https://safe-stuff",
-			StatusEndpoint: "https://safe-status",
-			Region:         "sa-east-1",
+			UserID:            "myuser",
+			UserKey:           "secret-key",
+			Destination:       "https://safe-stuff",
+			StatusEndpoint:    "https://safe-status",
+			Region:            "sa-east-1",
+			NotificationToken: "notification-token",
 		},
 		ElasticTranscoder: &ElasticTranscoder{
+			AccessKeyID:          "AKIANOTREALLY",
+			SecretAccessKey:      "secret-key",
+			Region:               "us-east-1",
+			PipelineID:           "mypipeline",
+			NotificationTopicARN: "arn:aws:sns:us-east-1:123456789012:transcoding",
+		},
+		MediaConvert: &MediaConvert{
 			AccessKeyID:     "AKIANOTREALLY",
 			SecretAccessKey: "secret-key",
 			Region:          "us-east-1",
-			PipelineID:      "mypipeline",
+			Endpoint:        "https://abcd1234.mediaconvert.us-east-1.amazonaws.com",
+			Queue:           "arn:aws:mediaconvert:us-east-1:123456789012:queues/transcoding",
+			Role:            "arn:aws:iam::123456789012:role/MediaConvert",
+			Destination:     "s3://my-bucket/",
+		},
+		GCPTranscoder: &GCPTranscoder{
+			ProjectID:       "my-project",
+			Location:        "europe-west1",
+			CredentialsFile: "/etc/transcoding/credentials.json",
+			Endpoint:        "http://transcoder",
+			Destination:     "gs://my-bucket/",
 		},
 		ElementalConductor: &ElementalConductor{
 			Host:            "elemental-server",
@@ -94,6 +158,49 @@ func TestLoadConfigFromEnv(t *testing.T) {
 			EncodingRegion:   "GOOGLE_EUROPE_WEST_1",
 			EncodingVersion:  "notstable",
 		},
+		FFmpeg: &FFmpeg{
+			BinaryPath:  "/usr/local/bin/ffmpeg",
+			Destination: "/var/lib/transcoding",
+			Concurrency: 4,
+			SourceRoot:  "/var/media",
+		},
+		FakeProvider: &FakeProvider{
+			Enabled:        true,
+			Destination:    "s3://my-bucket/",
+			QueueTime:      10,
+			ProcessingTime: 100,
+			FailureRate:    0.1,
+			FailurePattern: "fail",
+			Unhealthy:      true,
+		},
+		Notifications: &Notifications{
+			MaxAttempts:          3,
+			InitialBackoff:       2,
+			MaxBackoff:           30,
+			Timeout:              5,
+			AllowedCallbackHosts: "callbacks.example.com",
+		},
+		StatusPoller: &StatusPoller{
+			Enabled:           true,
+			Concurrency:       10,
+			Interval:          15,
+			ProviderIntervals: "zencoder:10,encodingcom:60",
+			MaxJobAge:         24,
+		},
+		PresetReconciler: &PresetReconciler{
+			Enabled:  true,
+			Interval: 30,
+			Repair:   true,
+		},
+		Routing: &Routing{
+			Rules: `[{"name":"default","providers":[{"name":"zencoder"}]}]`,
+		},
+		Probe: &Probe{
+			Enabled:  true,
+			Timeout:  5,
+			Rules:    "presetHeight,maxDuration:7200",
+			FileRoot: "/var/media",
+		},
 		Server: &server.Config{
 			HTTPPort:      8080,
 			HTTPAccessLog: &accessLog,
@@ -102,6 +209,15 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	if cfg.SwaggerManifest != expectedCfg.SwaggerManifest {
 		t.Errorf("LoadConfig(): wrong swagger manifest. Want %q. Got %q", expectedCfg.SwaggerManifest, cfg.SwaggerManifest)
 	}
+	if cfg.FailoverPolicies != expectedCfg.FailoverPolicies {
+		t.Errorf("LoadConfig(): wrong failover policies. Want %q. Got %q", expectedCfg.FailoverPolicies, cfg.FailoverPolicies)
+	}
+	if cfg.AllowedDestinations != expectedCfg.AllowedDestinations {
+		t.Errorf("LoadConfig(): wrong allowed destinations. Want %q. Got %q", expectedCfg.AllowedDestinations, cfg.AllowedDestinations)
+	}
+	if cfg.AzureStorageAccounts != expectedCfg.AzureStorageAccounts {
+		t.Errorf("LoadConfig(): wrong Azure storage accounts. Want %q. Got %q", expectedCfg.AzureStorageAccounts, cfg.AzureStorageAccounts)
+	}
 	if cfg.DefaultSegmentDuration != expectedCfg.DefaultSegmentDuration {
 		t.Errorf("LoadConfig(): wrong default segment duration. Want %q. Got %q", expectedCfg.DefaultSegmentDuration, cfg.DefaultSegmentDuration)
 	}
@@ -114,12 +230,39 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	if !reflect.DeepEqual(*cfg.ElasticTranscoder, *expectedCfg.ElasticTranscoder) {
 		t.Errorf("LoadConfig(): wrong ElasticTranscoder config returned. Want %#v. Got %#v.", *expectedCfg.ElasticTranscoder, *cfg.ElasticTranscoder)
 	}
+	if !reflect.DeepEqual(*cfg.MediaConvert, *expectedCfg.MediaConvert) {
+		t.Errorf("LoadConfig(): wrong MediaConvert config returned. Want %#v. Got %#v.", *expectedCfg.MediaConvert, *cfg.MediaConvert)
+	}
+	if !reflect.DeepEqual(*cfg.GCPTranscoder, *expectedCfg.GCPTranscoder) {
+		t.Errorf("LoadConfig(): wrong GCPTranscoder config returned. Want %#v. Got %#v.", *expectedCfg.GCPTranscoder, *cfg.GCPTranscoder)
+	}
 	if !reflect.DeepEqual(*cfg.Bitmovin, *expectedCfg.Bitmovin) {
 		t.Errorf("LoadConfig(): wrong Bitmovin config returned. Want %#v. Got %#v.", *expectedCfg.Bitmovin, *cfg.Bitmovin)
 	}
 	if !reflect.DeepEqual(*cfg.ElementalConductor, *expectedCfg.ElementalConductor) {
 		t.Errorf("LoadConfig(): wrong Elemental Conductor config returned. Want %#v. Got %#v.", *expectedCfg.ElementalConductor, *cfg.ElementalConductor)
 	}
+	if !reflect.DeepEqual(*cfg.FFmpeg, *expectedCfg.FFmpeg) {
+		t.Errorf("LoadConfig(): wrong FFmpeg config returned. Want %#v. Got %#v.", *expectedCfg.FFmpeg, *cfg.FFmpeg)
+	}
+	if !reflect.DeepEqual(*cfg.FakeProvider, *expectedCfg.FakeProvider) {
+		t.Errorf("LoadConfig(): wrong FakeProvider config returned. Want %#v. Got %#v.", *expectedCfg.FakeProvider, *cfg.FakeProvider)
+	}
+	if !reflect.DeepEqual(*cfg.Notifications, *expectedCfg.Notifications) {
+		t.Errorf("LoadConfig(): wrong Notifications config returned. Want %#v. Got %#v.", *expectedCfg.Notifications, *cfg.Notifications)
+	}
+	if !reflect.DeepEqual(*cfg.StatusPoller, *expectedCfg.StatusPoller) {
+		t.Errorf("LoadConfig(): wrong StatusPoller config returned. Want %#v. Got %#v.", *expectedCfg.StatusPoller, *cfg.StatusPoller)
+	}
+	if !reflect.DeepEqual(*cfg.PresetReconciler, *expectedCfg.PresetReconciler) {
+		t.Errorf("LoadConfig(): wrong PresetReconciler config returned. Want %#v. Got %#v.", *expectedCfg.PresetReconciler, *cfg.PresetReconciler)
+	}
+	if !reflect.DeepEqual(*cfg.Routing, *expectedCfg.Routing) {
+		t.Errorf("LoadConfig(): wrong Routing config returned. Want %#v. Got %#v.", *expectedCfg.Routing, *cfg.Routing)
+	}
+	if !reflect.DeepEqual(*cfg.Probe, *expectedCfg.Probe) {
+		t.Errorf("LoadConfig(): wrong Probe config returned. Want %#v. Got %#v.", *expectedCfg.Probe, *cfg.Probe)
+	}
 }
 
 func TestLoadConfigFromEnvWithDefaults(t *testing.T) {
@@ -181,6 +324,15 @@ func TestLoadConfigFromEnvWithDefaults(t *testing.T) {
 			Region:          "us-east-1",
 			PipelineID:      "mypipeline",
 		},
+		MediaConvert: &MediaConvert{
+			AccessKeyID:     "AKIANOTREALLY",
+			SecretAccessKey: "secret-key",
+			Region:          "us-east-1",
+		},
+		GCPTranscoder: &GCPTranscoder{
+			Location: "us-central1",
+			Endpoint: "https://transcoder.googleapis.com/v1/",
+		},
 		ElementalConductor: &ElementalConductor{
 			Host:            "elemental-server",
 			UserLogin:       "myuser",
@@ -201,6 +353,33 @@ func TestLoadConfigFromEnvWithDefaults(t *testing.T) {
 			EncodingRegion:   "AWS_US_EAST_1",
 			EncodingVersion:  "STABLE",
 		},
+		Notifications: &Notifications{
+			MaxAttempts:    5,
+			InitialBackoff: 1,
+			MaxBackoff:     60,
+			Timeout:        10,
+		},
+		FFmpeg: &FFmpeg{
+			BinaryPath:  "ffmpeg",
+			Concurrency: 2,
+		},
+		FakeProvider: &FakeProvider{
+			Destination:    "s3://fake-bucket/",
+			QueueTime:      1000,
+			ProcessingTime: 5000,
+		},
+		StatusPoller: &StatusPoller{
+			Concurrency: 4,
+			Interval:    30,
+			MaxJobAge:   72,
+		},
+		PresetReconciler: &PresetReconciler{
+			Interval: 60,
+		},
+		Routing: &Routing{},
+		Probe: &Probe{
+			Timeout: 10,
+		},
 		Server: &server.Config{
 			HTTPPort:      8080,
 			HTTPAccessLog: &accessLog,
@@ -224,6 +403,33 @@ func TestLoadConfigFromEnvWithDefaults(t *testing.T) {
 	if !reflect.DeepEqual(*cfg.ElementalConductor, *expectedCfg.ElementalConductor) {
 		t.Errorf("LoadConfig(): wrong Elemental Conductor config returned. Want %#v. Got %#v.", *expectedCfg.ElementalConductor, *cfg.ElementalConductor)
 	}
+	if !reflect.DeepEqual(*cfg.FFmpeg, *expectedCfg.FFmpeg) {
+		t.Errorf("LoadConfig(): wrong FFmpeg config returned. Want %#v. Got %#v.", *expectedCfg.FFmpeg, *cfg.FFmpeg)
+	}
+	if !reflect.DeepEqual(*cfg.FakeProvider, *expectedCfg.FakeProvider) {
+		t.Errorf("LoadConfig(): wrong FakeProvider config returned. Want %#v. Got %#v.", *expectedCfg.FakeProvider, *cfg.FakeProvider)
+	}
+	if !reflect.DeepEqual(*cfg.Notifications, *expectedCfg.Notifications) {
+		t.Errorf("LoadConfig(): wrong Notifications config returned. Want %#v. Got %#v.", *expectedCfg.Notifications, *cfg.Notifications)
+	}
+	if !reflect.DeepEqual(*cfg.StatusPoller, *expectedCfg.StatusPoller) {
+		t.Errorf("LoadConfig(): wrong StatusPoller config returned. Want %#v. Got %#v.", *expectedCfg.StatusPoller, *cfg.StatusPoller)
+	}
+	if !reflect.DeepEqual(*cfg.PresetReconciler, *expectedCfg.PresetReconciler) {
+		t.Errorf("LoadConfig(): wrong PresetReconciler config returned. Want %#v. Got %#v.", *expectedCfg.PresetReconciler, *cfg.PresetReconciler)
+	}
+	if !reflect.DeepEqual(*cfg.Routing, *expectedCfg.Routing) {
+		t.Errorf("LoadConfig(): wrong Routing config returned. Want %#v. Got %#v.", *expectedCfg.Routing, *cfg.Routing)
+	}
+	if !reflect.DeepEqual(*cfg.Probe, *expectedCfg.Probe) {
+		t.Errorf("LoadConfig(): wrong Probe config returned. Want %#v. Got %#v.", *expectedCfg.Probe, *cfg.Probe)
+	}
+	if !reflect.DeepEqual(*cfg.MediaConvert, *expectedCfg.MediaConvert) {
+		t.Errorf("LoadConfig(): wrong MediaConvert config returned. Want %#v. Got %#v.", *expectedCfg.MediaConvert, *cfg.MediaConvert)
+	}
+	if !reflect.DeepEqual(*cfg.GCPTranscoder, *expectedCfg.GCPTranscoder) {
+		t.Errorf("LoadConfig(): wrong GCPTranscoder config returned. Want %#v. Got %#v.", *expectedCfg.GCPTranscoder, *cfg.GCPTranscoder)
+	}
 	if !reflect.DeepEqual(*cfg.Bitmovin, *expectedCfg.Bitmovin) {
 		t.Errorf("LoadConfig(): wrong Bitmovin config returned. Want %#v. Got %#v.", *expectedCfg.Bitmovin, *cfg.Bitmovin)
 	}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Clipping modes of jobs, as returned by Job.Clipping.
+const (
+	// ClippingJob means that all outputs share the clip of the job.
+	ClippingJob = "job"
+
+	// ClippingOutputs means that some outputs define their own clips.
+	ClippingOutputs = "outputs"
+)
+
+// Clip represents a segment of the source media, used instead of the whole
+// source. Positions are given in seconds (e.g. "12.5"). Clips without start
+// begin at the beginning of the source, and clips without end last until the
+// end of the source.
+//
+// swagger:model
+type Clip struct {
+	// start of the segment, in seconds
+	Start string `redis-hash:"start,omitempty" json:"start,omitempty"`
+
+	// end of the segment, in seconds
+	End string `redis-hash:"end,omitempty" json:"end,omitempty"`
+}
+
+// Validate checks that the clip is properly defined.
+func (c *Clip) Validate() error {
+	if c.Start == "" && c.End == "" {
+		return errors.New("invalid clip: missing start or end")
+	}
+	start, err := parseSeconds(c.Start)
+	if err != nil {
+		return fmt.Errorf("invalid clip: invalid start %q", c.Start)
+	}
+	end, err := parseSeconds(c.End)
+	if err != nil {
+		return fmt.Errorf("invalid clip: invalid end %q", c.End)
+	}
+	if c.End != "" && end <= start {
+		return errors.New("invalid clip: end must be after the start")
+	}
+	return nil
+}
+
+// StartTime returns the start of the clip, or zero when the clip begins at
+// the beginning of the source.
+func (c *Clip) StartTime() time.Duration {
+	start, _ := parseSeconds(c.Start)
+	return start
+}
+
+// EndTime returns the end of the clip, or zero when the clip lasts until the
+// end of the source.
+func (c *Clip) EndTime() time.Duration {
+	end, _ := parseSeconds(c.End)
+	return end
+}
+
+// Duration returns the duration of the clip, or zero when the clip lasts
+// until the end of the source.
+func (c *Clip) Duration() time.Duration {
+	if c.End == "" {
+		return 0
+	}
+	return c.EndTime() - c.StartTime()
+}
+
+func parseSeconds(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0, errors.New("invalid number of seconds")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// OutputClip returns the clip used for the given output of the job: the clip
+// of the output, or the clip of the job when the output doesn't define one.
+// It returns nil when the output uses the whole source.
+func (j *Job) OutputClip(output TranscodeOutput) *Clip {
+	if output.Clip != nil {
+		return output.Clip
+	}
+	return j.Clip
+}
+
+// Clipping returns how the source media of the job is clipped: ClippingJob,
+// ClippingOutputs or an empty string when the job uses the whole source.
+// Outputs with the same clip as the job don't count as clipped outputs.
+func (j *Job) Clipping() string {
+	mode := ""
+	if j.Clip != nil {
+		mode = ClippingJob
+	}
+	for _, output := range j.Outputs {
+		if output.Clip != nil && (j.Clip == nil || *output.Clip != *j.Clip) {
+			return ClippingOutputs
+		}
+	}
+	return mode
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClipValidate(t *testing.T) {
+	var tests = []struct {
+		testCase string
+		clip     Clip
+		wantErr  string
+	}{
+		{
+			"start and end",
+			Clip{Start: "10", End: "25.5"},
+			"",
+		},
+		{
+			"start only",
+			Clip{Start: "60"},
+			"",
+		},
+		{
+			"end only",
+			Clip{End: "30"},
+			"",
+		},
+		{
+			"missing start and end",
+			Clip{},
+			"invalid clip: missing start or end",
+		},
+		{
+			"invalid start",
+			Clip{Start: "00:01:00"},
+			`invalid clip: invalid start "00:01:00"`,
+		},
+		{
+			"negative end",
+			Clip{End: "-5"},
+			`invalid clip: invalid end "-5"`,
+		},
+		{
+			"end before start",
+			Clip{Start: "30", End: "30"},
+			"invalid clip: end must be after the start",
+		},
+	}
+	for _, test := range tests {
+		err := test.clip.Validate()
+		if test.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.testCase, err)
+		}
+		if test.wantErr != "" && (err == nil || err.Error() != test.wantErr) {
+			t.Errorf("%s: wrong error returned. Want %q. Got %v", test.testCase, test.wantErr, err)
+		}
+	}
+}
+
+func TestClipTimes(t *testing.T) {
+	var tests = []struct {
+		clip         Clip
+		wantStart    time.Duration
+		wantEnd      time.Duration
+		wantDuration time.Duration
+	}{
+		{Clip{Start: "10", End: "25.5"}, 10 * time.Second, 25500 * time.Millisecond, 15500 * time.Millisecond},
+		{Clip{Start: "60"}, time.Minute, 0, 0},
+		{Clip{End: "30"}, 0, 30 * time.Second, 30 * time.Second},
+	}
+	for _, test := range tests {
+		if start := test.clip.StartTime(); start != test.wantStart {
+			t.Errorf("%#v: wrong start. Want %s. Got %s", test.clip, test.wantStart, start)
+		}
+		if end := test.clip.EndTime(); end != test.wantEnd {
+			t.Errorf("%#v: wrong end. Want %s. Got %s", test.clip, test.wantEnd, end)
+		}
+		if duration := test.clip.Duration(); duration != test.wantDuration {
+			t.Errorf("%#v: wrong duration. Want %s. Got %s", test.clip, test.wantDuration, duration)
+		}
+	}
+}
+
+func TestJobClipping(t *testing.T) {
+	jobClip := Clip{Start: "10", End: "20"}
+	outputClip := Clip{Start: "30"}
+	var tests = []struct {
+		testCase string
+		job      Job
+		want     string
+	}{
+		{
+			"no clips",
+			Job{Outputs: []TranscodeOutput{{FileName: "a.mp4"}}},
+			"",
+		},
+		{
+			"job clip",
+			Job{Clip: &jobClip, Outputs: []TranscodeOutput{{FileName: "a.mp4"}}},
+			ClippingJob,
+		},
+		{
+			"outputs with the clip of the job",
+			Job{Clip: &jobClip, Outputs: []TranscodeOutput{{FileName: "a.mp4", Clip: &Clip{Start: "10", End: "20"}}}},
+			ClippingJob,
+		},
+		{
+			"output clip",
+			Job{Outputs: []TranscodeOutput{{FileName: "a.mp4"}, {FileName: "b.mp4", Clip: &outputClip}}},
+			ClippingOutputs,
+		},
+		{
+			"output clip overriding the job clip",
+			Job{Clip: &jobClip, Outputs: []TranscodeOutput{{FileName: "a.mp4", Clip: &outputClip}}},
+			ClippingOutputs,
+		},
+	}
+	for _, test := range tests {
+		if got := test.job.Clipping(); got != test.want {
+			t.Errorf("%s: wrong clipping. Want %q. Got %q", test.testCase, test.want, got)
+		}
+	}
+	job := Job{Clip: &jobClip}
+	if clip := job.OutputClip(TranscodeOutput{}); clip != &jobClip {
+		t.Errorf("OutputClip: want the clip of the job. Got %#v", clip)
+	}
+	if clip := job.OutputClip(TranscodeOutput{Clip: &outputClip}); clip != &outputClip {
+		t.Errorf("OutputClip: want the clip of the output. Got %#v", clip)
+	}
+}
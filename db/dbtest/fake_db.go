@@ -2,6 +2,9 @@ package dbtest
 
 import (
 	"errors"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/NYTimes/video-transcoding-api/db"
@@ -10,8 +13,28 @@ import (
 type fakeRepository struct {
 	triggerError bool
 	presetmaps   map[string]*db.PresetMap
+	versions     map[string][]db.PresetVersion
 	localpresets map[string]*db.LocalPreset
-	jobs         []*db.Job
+	ladders      map[string]*db.Ladder
+
+	jobsMtx     sync.RWMutex
+	jobs        []*db.Job
+	expiredJobs map[string]bool
+
+	notificationsMtx sync.RWMutex
+	notifications    []db.Notification
+
+	retiredPresetsMtx sync.RWMutex
+	retiredPresets    []db.RetiredPreset
+
+	locksMtx   sync.Mutex
+	locks      map[string]fakeLock
+	lockTokens uint64
+}
+
+type fakeLock struct {
+	token   string
+	expires time.Time
 }
 
 // NewFakeRepository creates a new instance of the fake repository
@@ -21,7 +44,11 @@ func NewFakeRepository(triggerError bool) db.Repository {
 	return &fakeRepository{
 		triggerError: triggerError,
 		presetmaps:   make(map[string]*db.PresetMap),
+		versions:     make(map[string][]db.PresetVersion),
 		localpresets: make(map[string]*db.LocalPreset),
+		ladders:      make(map[string]*db.Ladder),
+		expiredJobs:  make(map[string]bool),
+		locks:        make(map[string]fakeLock),
 	}
 }
 
@@ -29,6 +56,8 @@ func (d *fakeRepository) CreateJob(job *db.Job) error {
 	if d.triggerError {
 		return errors.New("database error")
 	}
+	d.jobsMtx.Lock()
+	defer d.jobsMtx.Unlock()
 	if job.CreationTime.IsZero() {
 		job.CreationTime = time.Now().UTC()
 	}
@@ -36,10 +65,30 @@ func (d *fakeRepository) CreateJob(job *db.Job) error {
 	return nil
 }
 
+func (d *fakeRepository) UpdateJob(id string, update func(*db.Job)) (*db.Job, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	d.jobsMtx.Lock()
+	defer d.jobsMtx.Unlock()
+	index, err := d.findJob(id)
+	if err != nil {
+		return nil, err
+	}
+	job := *d.jobs[index]
+	update(&job)
+	job.ID = id
+	d.jobs[index] = &job
+	delete(d.expiredJobs, id)
+	return &job, nil
+}
+
 func (d *fakeRepository) DeleteJob(job *db.Job) error {
 	if d.triggerError {
 		return errors.New("database error")
 	}
+	d.jobsMtx.Lock()
+	defer d.jobsMtx.Unlock()
 	index, err := d.findJob(job.ID)
 	if err != nil {
 		return err
@@ -55,6 +104,8 @@ func (d *fakeRepository) GetJob(id string) (*db.Job, error) {
 	if d.triggerError {
 		return nil, errors.New("database error")
 	}
+	d.jobsMtx.RLock()
+	defer d.jobsMtx.RUnlock()
 	index, err := d.findJob(id)
 	if err != nil {
 		return nil, err
@@ -62,6 +113,20 @@ func (d *fakeRepository) GetJob(id string) (*db.Job, error) {
 	return d.jobs[index], nil
 }
 
+func (d *fakeRepository) GetJobByProviderJobID(providerName, providerJobID string) (*db.Job, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	d.jobsMtx.RLock()
+	defer d.jobsMtx.RUnlock()
+	for _, job := range d.jobs {
+		if job.ProviderName == providerName && job.ProviderJobID == providerJobID {
+			return job, nil
+		}
+	}
+	return nil, db.ErrJobNotFound
+}
+
 func (d *fakeRepository) findJob(id string) (int, error) {
 	index := -1
 	for i, job := range d.jobs {
@@ -80,12 +145,26 @@ func (d *fakeRepository) ListJobs(filter db.JobFilter) ([]db.Job, error) {
 	if d.triggerError {
 		return nil, errors.New("database error")
 	}
+	d.jobsMtx.RLock()
+	defer d.jobsMtx.RUnlock()
+	sortedJobs := make(jobList, len(d.jobs))
+	copy(sortedJobs, d.jobs)
+	sort.Stable(sortedJobs)
 	jobs := make([]db.Job, 0, len(d.jobs))
 	var count uint
-	for _, job := range d.jobs {
+	for _, job := range sortedJobs {
 		if job.CreationTime.Before(filter.Since) {
 			continue
 		}
+		if !filter.Until.IsZero() && job.CreationTime.After(filter.Until) {
+			continue
+		}
+		if !filter.After.Precedes(job) || !filter.Match(job) {
+			continue
+		}
+		if filter.Active && d.expiredJobs[job.ID] {
+			continue
+		}
 		if filter.Limit != 0 && count == filter.Limit {
 			break
 		}
@@ -95,6 +174,39 @@ func (d *fakeRepository) ListJobs(filter db.JobFilter) ([]db.Job, error) {
 	return jobs, nil
 }
 
+func (d *fakeRepository) ExpireActiveJobs(until time.Time) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	d.jobsMtx.Lock()
+	defer d.jobsMtx.Unlock()
+	for _, job := range d.jobs {
+		if job.CreationTime.Before(until) {
+			d.expiredJobs[job.ID] = true
+		}
+	}
+	return nil
+}
+
+type jobList []*db.Job
+
+func (l jobList) Len() int {
+	return len(l)
+}
+
+func (l jobList) Less(i, j int) bool {
+	iTime := l[i].CreationTime.Truncate(db.JobTimePrecision)
+	jTime := l[j].CreationTime.Truncate(db.JobTimePrecision)
+	if iTime.Equal(jTime) {
+		return l[i].ID < l[j].ID
+	}
+	return iTime.Before(jTime)
+}
+
+func (l jobList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
 func (d *fakeRepository) CreatePresetMap(presetmap *db.PresetMap) error {
 	if d.triggerError {
 		return errors.New("database error")
@@ -105,6 +217,7 @@ func (d *fakeRepository) CreatePresetMap(presetmap *db.PresetMap) error {
 	if _, ok := d.presetmaps[presetmap.Name]; ok {
 		return db.ErrPresetMapAlreadyExists
 	}
+	d.addVersion(presetmap)
 	d.presetmaps[presetmap.Name] = presetmap
 	return nil
 }
@@ -116,10 +229,40 @@ func (d *fakeRepository) UpdatePresetMap(presetmap *db.PresetMap) error {
 	if _, ok := d.presetmaps[presetmap.Name]; !ok {
 		return db.ErrPresetMapNotFound
 	}
+	d.addVersion(presetmap)
 	d.presetmaps[presetmap.Name] = presetmap
 	return nil
 }
 
+// addVersion records a new version of the given presetmap, comparing it with
+// the previous version, as stored presetmaps may be changed in place.
+func (d *fakeRepository) addVersion(presetmap *db.PresetMap) {
+	versions := d.versions[presetmap.Name]
+	var previous db.PresetMap
+	if len(versions) > 0 {
+		previous = versions[len(versions)-1].PresetMap
+	}
+	presetmap.Version = len(versions) + 1
+	snapshot := *presetmap
+	if presetmap.ProviderMapping != nil {
+		snapshot.ProviderMapping = make(map[string]string, len(presetmap.ProviderMapping))
+		for name, presetID := range presetmap.ProviderMapping {
+			snapshot.ProviderMapping[name] = presetID
+		}
+	}
+	if presetmap.Preset != nil {
+		preset := *presetmap.Preset
+		snapshot.Preset = &preset
+	}
+	d.versions[presetmap.Name] = append(versions, db.PresetVersion{
+		Version:      snapshot.Version,
+		PresetMap:    snapshot,
+		Author:       snapshot.Author,
+		CreationTime: time.Now().UTC(),
+		Changes:      db.DiffPresetMaps(previous, snapshot),
+	})
+}
+
 func (d *fakeRepository) GetPresetMap(name string) (*db.PresetMap, error) {
 	if d.triggerError {
 		return nil, errors.New("database error")
@@ -152,6 +295,27 @@ func (d *fakeRepository) ListPresetMaps() ([]db.PresetMap, error) {
 	return presetmaps, nil
 }
 
+func (d *fakeRepository) ListPresetVersions(name string) ([]db.PresetVersion, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	versions := make([]db.PresetVersion, len(d.versions[name]))
+	copy(versions, d.versions[name])
+	return versions, nil
+}
+
+func (d *fakeRepository) GetPresetVersion(name string, version int) (*db.PresetVersion, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	versions := d.versions[name]
+	if version < 1 || version > len(versions) {
+		return nil, db.ErrPresetVersionNotFound
+	}
+	presetVersion := versions[version-1]
+	return &presetVersion, nil
+}
+
 func (d *fakeRepository) CreateLocalPreset(preset *db.LocalPreset) error {
 	if d.triggerError {
 		return errors.New("database error")
@@ -198,3 +362,217 @@ func (d *fakeRepository) DeleteLocalPreset(preset *db.LocalPreset) error {
 	delete(d.localpresets, preset.Name)
 	return nil
 }
+
+func (d *fakeRepository) CreateLadder(ladder *db.Ladder) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	if _, ok := d.ladders[ladder.Name]; ok {
+		return db.ErrLadderAlreadyExists
+	}
+	d.ladders[ladder.Name] = ladder
+	return nil
+}
+
+func (d *fakeRepository) UpdateLadder(ladder *db.Ladder) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	if _, ok := d.ladders[ladder.Name]; !ok {
+		return db.ErrLadderNotFound
+	}
+	d.ladders[ladder.Name] = ladder
+	return nil
+}
+
+func (d *fakeRepository) DeleteLadder(ladder *db.Ladder) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	if _, ok := d.ladders[ladder.Name]; !ok {
+		return db.ErrLadderNotFound
+	}
+	delete(d.ladders, ladder.Name)
+	return nil
+}
+
+func (d *fakeRepository) GetLadder(name string) (*db.Ladder, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	if ladder, ok := d.ladders[name]; ok {
+		return ladder, nil
+	}
+	return nil, db.ErrLadderNotFound
+}
+
+func (d *fakeRepository) ListLadders() ([]db.Ladder, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	ladders := make([]db.Ladder, 0, len(d.ladders))
+	for _, ladder := range d.ladders {
+		ladders = append(ladders, *ladder)
+	}
+	return ladders, nil
+}
+
+func (d *fakeRepository) CreateNotification(notification *db.Notification) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	if notification.CreationTime.IsZero() {
+		notification.CreationTime = time.Now().UTC()
+	}
+	d.notificationsMtx.Lock()
+	defer d.notificationsMtx.Unlock()
+	d.notifications = append(d.notifications, *notification)
+	return nil
+}
+
+func (d *fakeRepository) UpdateNotification(notification *db.Notification) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	d.notificationsMtx.Lock()
+	defer d.notificationsMtx.Unlock()
+	for i := range d.notifications {
+		if d.notifications[i].ID == notification.ID {
+			d.notifications[i] = *notification
+			return nil
+		}
+	}
+	return db.ErrNotificationNotFound
+}
+
+func (d *fakeRepository) GetNotification(id string) (*db.Notification, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	d.notificationsMtx.RLock()
+	defer d.notificationsMtx.RUnlock()
+	for _, notification := range d.notifications {
+		if notification.ID == id {
+			return &notification, nil
+		}
+	}
+	return nil, db.ErrNotificationNotFound
+}
+
+func (d *fakeRepository) ListNotifications(jobID string) ([]db.Notification, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	d.notificationsMtx.RLock()
+	defer d.notificationsMtx.RUnlock()
+	notifications := make([]db.Notification, 0, len(d.notifications))
+	for _, notification := range d.notifications {
+		if notification.JobID == jobID {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications, nil
+}
+
+func (d *fakeRepository) ListPendingNotifications(until time.Time) ([]db.Notification, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	d.notificationsMtx.RLock()
+	defer d.notificationsMtx.RUnlock()
+	var notifications pendingNotificationList
+	for _, notification := range d.notifications {
+		if notification.Pending() && !notification.NextAttemptTime.After(until) {
+			notifications = append(notifications, notification)
+		}
+	}
+	sort.Stable(notifications)
+	return notifications, nil
+}
+
+type pendingNotificationList []db.Notification
+
+func (l pendingNotificationList) Len() int {
+	return len(l)
+}
+
+func (l pendingNotificationList) Less(i, j int) bool {
+	return l[i].NextAttemptTime.Before(l[j].NextAttemptTime)
+}
+
+func (l pendingNotificationList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (d *fakeRepository) CreateRetiredPreset(retiredPreset *db.RetiredPreset) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	if retiredPreset.RetirementTime.IsZero() {
+		retiredPreset.RetirementTime = time.Now().UTC()
+	}
+	d.retiredPresetsMtx.Lock()
+	defer d.retiredPresetsMtx.Unlock()
+	for i, p := range d.retiredPresets {
+		if p.ProviderName == retiredPreset.ProviderName && p.PresetID == retiredPreset.PresetID {
+			d.retiredPresets[i] = *retiredPreset
+			return nil
+		}
+	}
+	d.retiredPresets = append(d.retiredPresets, *retiredPreset)
+	return nil
+}
+
+func (d *fakeRepository) DeleteRetiredPreset(retiredPreset *db.RetiredPreset) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	d.retiredPresetsMtx.Lock()
+	defer d.retiredPresetsMtx.Unlock()
+	for i, p := range d.retiredPresets {
+		if p.ProviderName == retiredPreset.ProviderName && p.PresetID == retiredPreset.PresetID {
+			d.retiredPresets = append(d.retiredPresets[:i], d.retiredPresets[i+1:]...)
+			return nil
+		}
+	}
+	return db.ErrRetiredPresetNotFound
+}
+
+func (d *fakeRepository) ListRetiredPresets() ([]db.RetiredPreset, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	d.retiredPresetsMtx.RLock()
+	defer d.retiredPresetsMtx.RUnlock()
+	retiredPresets := make([]db.RetiredPreset, len(d.retiredPresets))
+	copy(retiredPresets, d.retiredPresets)
+	return retiredPresets, nil
+}
+
+func (d *fakeRepository) AcquireLock(name string, duration time.Duration) (string, error) {
+	if d.triggerError {
+		return "", errors.New("database error")
+	}
+	d.locksMtx.Lock()
+	defer d.locksMtx.Unlock()
+	now := time.Now()
+	if lock, ok := d.locks[name]; ok && lock.expires.After(now) {
+		return "", nil
+	}
+	d.lockTokens++
+	token := strconv.FormatUint(d.lockTokens, 10)
+	d.locks[name] = fakeLock{token: token, expires: now.Add(duration)}
+	return token, nil
+}
+
+func (d *fakeRepository) ReleaseLock(name, token string) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	d.locksMtx.Lock()
+	defer d.locksMtx.Unlock()
+	if lock, ok := d.locks[name]; ok && lock.token == token {
+		delete(d.locks, name)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package dbtest
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
@@ -288,6 +289,55 @@ func TestUpdatePresetMap(t *testing.T) {
 	}
 }
 
+func TestPresetVersions(t *testing.T) {
+	repo := NewFakeRepository(false)
+	preset := db.PresetMap{Name: "mypreset", ProviderMapping: map[string]string{"some": "provider"}}
+	err := repo.CreatePresetMap(&preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstVersion := preset
+	firstVersion.ProviderMapping = map[string]string{"some": "provider"}
+	// stored presetmaps may be changed in place before being updated.
+	preset.ProviderMapping["some"] = "other-provider"
+	preset.Author = "someone"
+	err = repo.UpdatePresetMap(&preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preset.Version != 2 {
+		t.Errorf("Wrong version. Want 2. Got %d", preset.Version)
+	}
+	versions, err := repo.ListPresetVersions(preset.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Wrong number of versions. Want 2. Got %d", len(versions))
+	}
+	if !reflect.DeepEqual(versions[0].PresetMap, firstVersion) {
+		t.Errorf("Wrong first version. Want %#v. Got %#v", firstVersion, versions[0].PresetMap)
+	}
+	expectedChanges := []db.PresetFieldDiff{{Field: "providerMapping.some", From: "provider", To: "other-provider"}}
+	if !reflect.DeepEqual(versions[1].Changes, expectedChanges) {
+		t.Errorf("Wrong changes. Want %#v. Got %#v", expectedChanges, versions[1].Changes)
+	}
+	if versions[1].Author != "someone" {
+		t.Errorf("Wrong author. Want %q. Got %q", "someone", versions[1].Author)
+	}
+	version, err := repo.GetPresetVersion(preset.Name, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*version, versions[1]) {
+		t.Errorf("Wrong version returned. Want %#v. Got %#v", versions[1], *version)
+	}
+	_, err = repo.GetPresetVersion(preset.Name, 3)
+	if err != db.ErrPresetVersionNotFound {
+		t.Errorf("GetPresetVersion: wrong error. Want %#v. Got %#v", db.ErrPresetVersionNotFound, err)
+	}
+}
+
 func TestUpdatePresetMapNotFound(t *testing.T) {
 	repo := NewFakeRepository(false)
 	preset := db.PresetMap{Name: "mypreset"}
@@ -573,3 +623,449 @@ func TestDeleteLocalPresetDBError(t *testing.T) {
 		t.Errorf("DeleteLocalPreset: wrong error message. Want %q. Got %q", dbErrorMsg, err.Error())
 	}
 }
+
+func TestCreateLadder(t *testing.T) {
+	repo := NewFakeRepository(false)
+	ladder := db.Ladder{Name: "web"}
+	err := repo.CreateLadder(&ladder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLadders := map[string]*db.Ladder{"web": &ladder}
+	ladders := repo.(*fakeRepository).ladders
+	if !reflect.DeepEqual(ladders, expectedLadders) {
+		t.Errorf("Wrong internal ladder registry. Want %#v. Got %#v", expectedLadders, ladders)
+	}
+}
+
+func TestCreateLadderDuplicate(t *testing.T) {
+	repo := NewFakeRepository(false)
+	ladder := db.Ladder{Name: "web"}
+	err := repo.CreateLadder(&ladder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.CreateLadder(&ladder)
+	if err != db.ErrLadderAlreadyExists {
+		t.Errorf("CreateLadder: wrong error returned. Want %#v. Got %#v", db.ErrLadderAlreadyExists, err)
+	}
+}
+
+func TestCreateLadderDBError(t *testing.T) {
+	repo := NewFakeRepository(true)
+	err := repo.CreateLadder(&db.Ladder{Name: "web"})
+	if err == nil {
+		t.Fatal("got unexpected <nil> error")
+	}
+	if err.Error() != dbErrorMsg {
+		t.Errorf("CreateLadder: wrong error message. Want %q. Got %q", dbErrorMsg, err.Error())
+	}
+}
+
+func TestUpdateLadder(t *testing.T) {
+	repo := NewFakeRepository(false)
+	ladder := db.Ladder{Name: "web"}
+	err := repo.CreateLadder(&ladder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := db.Ladder{Name: "web", Rungs: []db.LadderRung{{PresetMap: "mp4_720p", Height: 720, Bitrate: 3000000}}}
+	err = repo.UpdateLadder(&updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotLadder, err := repo.GetLadder("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotLadder, updated) {
+		t.Errorf("UpdateLadder: wrong ladder stored. Want %#v. Got %#v", updated, *gotLadder)
+	}
+}
+
+func TestUpdateLadderNotFound(t *testing.T) {
+	repo := NewFakeRepository(false)
+	err := repo.UpdateLadder(&db.Ladder{Name: "web"})
+	if err != db.ErrLadderNotFound {
+		t.Errorf("UpdateLadder: wrong error. Want %#v. Got %#v", db.ErrLadderNotFound, err)
+	}
+}
+
+func TestGetLadderNotFound(t *testing.T) {
+	repo := NewFakeRepository(false)
+	ladder, err := repo.GetLadder("web")
+	if ladder != nil {
+		t.Errorf("GetLadder: unexpected non-nil ladder: %#v", *ladder)
+	}
+	if err != db.ErrLadderNotFound {
+		t.Errorf("GetLadder: wrong error. Want ErrLadderNotFound. Got %#v", err)
+	}
+}
+
+func TestListLadders(t *testing.T) {
+	repo := NewFakeRepository(false)
+	ladder := db.Ladder{Name: "web"}
+	err := repo.CreateLadder(&ladder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLadders := []db.Ladder{ladder}
+	ladders, err := repo.ListLadders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ladders, expectedLadders) {
+		t.Errorf("ListLadders: wrong list returned. Want %#v. Got %#v", expectedLadders, ladders)
+	}
+}
+
+func TestDeleteLadder(t *testing.T) {
+	repo := NewFakeRepository(false)
+	ladder := db.Ladder{Name: "web"}
+	err := repo.CreateLadder(&ladder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.DeleteLadder(&ladder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ladders := repo.(*fakeRepository).ladders
+	if len(ladders) != 0 {
+		t.Errorf("DeleteLadder: unexpected ladders in the registry: %#v", ladders)
+	}
+}
+
+func TestDeleteLadderNotFound(t *testing.T) {
+	repo := NewFakeRepository(false)
+	err := repo.DeleteLadder(&db.Ladder{Name: "web"})
+	if err != db.ErrLadderNotFound {
+		t.Errorf("DeleteLadder: wrong error. Want %#v. Got %#v", db.ErrLadderNotFound, err)
+	}
+}
+
+func TestGetJobByProviderJobID(t *testing.T) {
+	repo := NewFakeRepository(false)
+	jobs := []db.Job{
+		{ID: "j-1", ProviderName: "encodingcom", ProviderJobID: "123"},
+		{ID: "j-2", ProviderName: "zencoder", ProviderJobID: "123"},
+	}
+	for i := range jobs {
+		err := repo.CreateJob(&jobs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	gotJob, err := repo.GetJobByProviderJobID("zencoder", "123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotJob, jobs[1]) {
+		t.Errorf("Wrong job returned. Want %#v. Got %#v", jobs[1], *gotJob)
+	}
+	_, err = repo.GetJobByProviderJobID("elastictranscoder", "123")
+	if err != db.ErrJobNotFound {
+		t.Errorf("Wrong error returned. Want %#v. Got %#v", db.ErrJobNotFound, err)
+	}
+}
+
+func TestUpdateJob(t *testing.T) {
+	repo := NewFakeRepository(false)
+	job := db.Job{ID: "j-123", ProviderName: "myprovider"}
+	err := repo.CreateJob(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updatedJob, err := repo.UpdateJob(job.ID, func(job *db.Job) {
+		job.Status = "finished"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Status = "finished"
+	if !reflect.DeepEqual(*updatedJob, job) {
+		t.Errorf("Wrong job returned by UpdateJob. Want %#v. Got %#v", job, *updatedJob)
+	}
+	gotJob, err := repo.GetJob(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotJob, job) {
+		t.Errorf("Wrong job returned. Want %#v. Got %#v", job, *gotJob)
+	}
+}
+
+func TestUpdateJobNotFound(t *testing.T) {
+	repo := NewFakeRepository(false)
+	_, err := repo.UpdateJob("some-job", func(*db.Job) {})
+	if err != db.ErrJobNotFound {
+		t.Errorf("Wrong error returned. Want %#v. Got %#v", db.ErrJobNotFound, err)
+	}
+}
+
+func TestUpdateJobDBError(t *testing.T) {
+	repo := NewFakeRepository(true)
+	_, err := repo.UpdateJob("some-job", func(*db.Job) {})
+	if err.Error() != dbErrorMsg {
+		t.Errorf("Wrong error message returned. Want %q. Got %q", dbErrorMsg, err.Error())
+	}
+}
+
+func TestListJobsAttributeFilters(t *testing.T) {
+	now := time.Now().UTC()
+	jobs := []db.Job{
+		{
+			ID:           "job-1",
+			ProviderName: "encodingcom",
+			Status:       "finished",
+			SourceMedia:  "s3://bucket/a/video.mov",
+			CreationTime: now.Add(-3 * time.Hour),
+			Outputs:      []db.TranscodeOutput{{Preset: db.PresetMap{Name: "mp4_720p"}}},
+		},
+		{
+			ID:           "job-2",
+			ProviderName: "zencoder",
+			Status:       "started",
+			SourceMedia:  "s3://bucket/a/video.mov",
+			CreationTime: now.Add(-2 * time.Hour),
+			Outputs:      []db.TranscodeOutput{{Preset: db.PresetMap{Name: "mp4_1080p"}}},
+		},
+		{
+			ID:           "job-3",
+			ProviderName: "encodingcom",
+			Status:       "started",
+			SourceMedia:  "s3://bucket/b/video.mov",
+			CreationTime: now.Add(-time.Hour),
+			Outputs:      []db.TranscodeOutput{{Preset: db.PresetMap{Name: "mp4_1080p"}}},
+		},
+	}
+	repo := NewFakeRepository(false)
+	for i, job := range jobs {
+		job := job
+		err := repo.CreateJob(&job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs[i] = job
+	}
+	var tests = []struct {
+		givenTestCase string
+		givenFilter   db.JobFilter
+		wantJobs      []db.Job
+	}{
+		{"provider", db.JobFilter{ProviderName: "encodingcom"}, []db.Job{jobs[0], jobs[2]}},
+		{"status", db.JobFilter{Status: "started"}, jobs[1:]},
+		{"active", db.JobFilter{Active: true}, jobs[1:]},
+		{"source prefix", db.JobFilter{SourcePrefix: "s3://bucket/a/"}, jobs[:2]},
+		{"preset", db.JobFilter{PresetName: "mp4_1080p", Status: "started"}, jobs[1:]},
+		{"until", db.JobFilter{Until: now.Add(-90 * time.Minute)}, jobs[:2]},
+		{"cursor", db.JobFilter{After: db.NewJobCursor(&jobs[0]), Limit: 1}, jobs[1:2]},
+		{"no match", db.JobFilter{ProviderName: "elastictranscoder"}, []db.Job{}},
+	}
+	for _, test := range tests {
+		gotJobs, err := repo.ListJobs(test.givenFilter)
+		if err != nil {
+			t.Fatalf("%s: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(gotJobs, test.wantJobs) {
+			t.Errorf("%s: wrong list returned. Want %#v. Got %#v", test.givenTestCase, test.wantJobs, gotJobs)
+		}
+	}
+}
+
+func TestExpireActiveJobs(t *testing.T) {
+	now := time.Now().UTC()
+	repo := NewFakeRepository(false)
+	for i, creationTime := range []time.Time{now.Add(-100 * time.Hour), now.Add(-time.Hour)} {
+		err := repo.CreateJob(&db.Job{ID: fmt.Sprintf("job-%d", i), Status: "started", CreationTime: creationTime})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertActiveJobs := func(step string, want []string) {
+		jobs, err := repo.ListJobs(db.JobFilter{Active: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("%s: wrong active jobs. Want %#v. Got %#v", step, want, ids)
+		}
+	}
+	if err := repo.ExpireActiveJobs(now.Add(-72 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	assertActiveJobs("ExpireActiveJobs", []string{"job-1"})
+	if _, err := repo.UpdateJob("job-0", func(*db.Job) {}); err != nil {
+		t.Fatal(err)
+	}
+	assertActiveJobs("UpdateJob", []string{"job-0", "job-1"})
+}
+
+func TestNotifications(t *testing.T) {
+	repo := NewFakeRepository(false)
+	notifications := []db.Notification{
+		{ID: "n-1", JobID: "job-1", Status: "started"},
+		{ID: "n-2", JobID: "job-2", Status: "started"},
+		{ID: "n-3", JobID: "job-1", Status: "finished"},
+	}
+	for i := range notifications {
+		err := repo.CreateNotification(&notifications[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if notifications[i].CreationTime.IsZero() {
+			t.Errorf("Did not set the CreationTime of %q", notifications[i].ID)
+		}
+	}
+	notifications[2].Delivered = true
+	notifications[2].Attempts = []db.NotificationAttempt{{StatusCode: 200}}
+	err := repo.UpdateNotification(&notifications[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotNotifications, err := repo.ListNotifications("job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []db.Notification{notifications[0], notifications[2]}
+	if !reflect.DeepEqual(gotNotifications, expected) {
+		t.Errorf("ListNotifications: wrong list returned. Want %#v. Got %#v", expected, gotNotifications)
+	}
+}
+
+func TestListPendingNotifications(t *testing.T) {
+	repo := NewFakeRepository(false)
+	now := time.Now().UTC()
+	notifications := []db.Notification{
+		{ID: "n-1", JobID: "job-1", NextAttemptTime: now.Add(time.Minute)},
+		{ID: "n-2", JobID: "job-1", NextAttemptTime: now.Add(-time.Minute)},
+		{ID: "n-3", JobID: "job-2", Delivered: true},
+		{ID: "n-4", JobID: "job-2", DeadLetter: true},
+		{ID: "n-5", JobID: "job-3"},
+	}
+	for i := range notifications {
+		err := repo.CreateNotification(&notifications[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	gotNotifications, err := repo.ListPendingNotifications(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []db.Notification{notifications[4], notifications[1]}
+	if !reflect.DeepEqual(gotNotifications, expected) {
+		t.Errorf("ListPendingNotifications: wrong list returned. Want %#v. Got %#v", expected, gotNotifications)
+	}
+}
+
+func TestUpdateNotificationNotFound(t *testing.T) {
+	repo := NewFakeRepository(false)
+	err := repo.UpdateNotification(&db.Notification{ID: "n-1"})
+	if err != db.ErrNotificationNotFound {
+		t.Errorf("Wrong error returned. Want %#v. Got %#v", db.ErrNotificationNotFound, err)
+	}
+}
+
+func TestGetNotification(t *testing.T) {
+	repo := NewFakeRepository(false)
+	notification := db.Notification{ID: "n-1", JobID: "job-1", Status: "finished"}
+	if err := repo.CreateNotification(&notification); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetNotification("n-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*got, notification) {
+		t.Errorf("wrong notification returned. Want %#v. Got %#v", notification, *got)
+	}
+	if _, err = repo.GetNotification("n-2"); err != db.ErrNotificationNotFound {
+		t.Errorf("wrong error returned. Want %#v. Got %#v", db.ErrNotificationNotFound, err)
+	}
+}
+
+func TestLocks(t *testing.T) {
+	repo := NewFakeRepository(false)
+	token, err := repo.AcquireLock("poller", time.Minute)
+	if err != nil || token == "" {
+		t.Fatalf("lock not acquired: %v", err)
+	}
+	if other, _ := repo.AcquireLock("poller", time.Minute); other != "" {
+		t.Errorf("lock acquired twice. Got token %q", other)
+	}
+	repo.ReleaseLock("poller", "wrong-token")
+	if other, _ := repo.AcquireLock("poller", time.Minute); other != "" {
+		t.Error("lock released with the wrong token")
+	}
+	repo.ReleaseLock("poller", token)
+	if other, _ := repo.AcquireLock("poller", time.Millisecond); other == "" {
+		t.Error("lock not acquired after releasing it")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if other, _ := repo.AcquireLock("poller", time.Minute); other == "" {
+		t.Error("lock not acquired after expiring")
+	}
+}
+
+func TestNotificationsDBError(t *testing.T) {
+	repo := NewFakeRepository(true)
+	err := repo.CreateNotification(&db.Notification{ID: "n-1"})
+	if err == nil || err.Error() != dbErrorMsg {
+		t.Errorf("CreateNotification: wrong error returned. Want %q. Got %v", dbErrorMsg, err)
+	}
+	notifications, err := repo.ListNotifications("job-1")
+	if err == nil || err.Error() != dbErrorMsg {
+		t.Errorf("ListNotifications: wrong error returned. Want %q. Got %v", dbErrorMsg, err)
+	}
+	if _, err = repo.ListPendingNotifications(time.Now()); err == nil || err.Error() != dbErrorMsg {
+		t.Errorf("ListPendingNotifications: wrong error returned. Want %q. Got %v", dbErrorMsg, err)
+	}
+	if notifications != nil {
+		t.Errorf("ListNotifications: unexpected non-nil list: %#v", notifications)
+	}
+}
+
+func TestRetiredPresets(t *testing.T) {
+	repo := NewFakeRepository(false)
+	retiredPresets := []db.RetiredPreset{
+		{ProviderName: "elastictranscoder", PresetID: "preset-1", PresetMap: "720p"},
+		{ProviderName: "encodingcom", PresetID: "preset-1", PresetMap: "720p"},
+		{ProviderName: "elastictranscoder", PresetID: "preset-1", PresetMap: "1080p"},
+	}
+	for i := range retiredPresets {
+		err := repo.CreateRetiredPreset(&retiredPresets[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retiredPresets[i].RetirementTime.IsZero() {
+			t.Errorf("Did not set the RetirementTime of %q", retiredPresets[i].PresetID)
+		}
+	}
+	got, err := repo.ListRetiredPresets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []db.RetiredPreset{retiredPresets[2], retiredPresets[1]}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("ListRetiredPresets: wrong list returned. Want %#v. Got %#v", expected, got)
+	}
+	if err = repo.DeleteRetiredPreset(&retiredPresets[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err = repo.DeleteRetiredPreset(&retiredPresets[0]); err != db.ErrRetiredPresetNotFound {
+		t.Errorf("DeleteRetiredPreset: wrong error returned. Want %#v. Got %#v", db.ErrRetiredPresetNotFound, err)
+	}
+	got, err = repo.ListRetiredPresets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []db.RetiredPreset{retiredPresets[1]}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("ListRetiredPresets: wrong list returned after deleting. Want %#v. Got %#v", expected, got)
+	}
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PresetFieldDiff describes a field that differs between two presets.
+type PresetFieldDiff struct {
+	// JSON path of the field, like "video.bitrate"
+	Field string `redis-hash:"field" json:"field"`
+	From  string `redis-hash:"from" json:"from"`
+	To    string `redis-hash:"to" json:"to"`
+}
+
+// DiffPresets compares the given presets, returning the fields that differ,
+// in the order they're declared in Preset.
+func DiffPresets(from, to Preset) []PresetFieldDiff {
+	return diffStructs(reflect.ValueOf(from), reflect.ValueOf(to), "")
+}
+
+// DiffPresetMaps compares the given presetmaps, returning the fields that
+// differ. The version and the author of the presetmaps are not compared.
+func DiffPresetMaps(from, to PresetMap) []PresetFieldDiff {
+	from.Version, from.Author = 0, ""
+	to.Version, to.Author = 0, ""
+	return diffStructs(reflect.ValueOf(from), reflect.ValueOf(to), "")
+}
+
+func diffStructs(from, to reflect.Value, prefix string) []PresetFieldDiff {
+	var diffs []PresetFieldDiff
+	for i := 0; i < from.NumField(); i++ {
+		field := from.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+		fromValue, toValue := from.Field(i), to.Field(i)
+		if fromValue.Kind() == reflect.Ptr {
+			// nil pointers are compared as zero values.
+			fromValue, toValue = derefValue(fromValue), derefValue(toValue)
+		}
+		switch fromValue.Kind() {
+		case reflect.Struct:
+			diffs = append(diffs, diffStructs(fromValue, toValue, prefix+name+".")...)
+		case reflect.Map:
+			diffs = append(diffs, diffMaps(fromValue, toValue, prefix+name+".")...)
+		case reflect.Slice:
+			diffs = append(diffs, diffSlices(fromValue, toValue, prefix+name)...)
+		case reflect.String:
+			if fromValue.String() != toValue.String() {
+				diffs = append(diffs, PresetFieldDiff{Field: prefix + name, From: fromValue.String(), To: toValue.String()})
+			}
+		case reflect.Int:
+			if fromValue.Int() != toValue.Int() {
+				diffs = append(diffs, PresetFieldDiff{
+					Field: prefix + name,
+					From:  strconv.FormatInt(fromValue.Int(), 10),
+					To:    strconv.FormatInt(toValue.Int(), 10),
+				})
+			}
+		case reflect.Bool:
+			if fromValue.Bool() != toValue.Bool() {
+				diffs = append(diffs, PresetFieldDiff{
+					Field: prefix + name,
+					From:  strconv.FormatBool(fromValue.Bool()),
+					To:    strconv.FormatBool(toValue.Bool()),
+				})
+			}
+		}
+	}
+	return diffs
+}
+
+// diffMaps compares maps of strings, in the order of their keys.
+func diffMaps(from, to reflect.Value, prefix string) []PresetFieldDiff {
+	keys := make(map[string]bool)
+	for _, key := range from.MapKeys() {
+		keys[key.String()] = true
+	}
+	for _, key := range to.MapKeys() {
+		keys[key.String()] = true
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+	var diffs []PresetFieldDiff
+	for _, key := range sortedKeys {
+		var fromValue, toValue string
+		if value := from.MapIndex(reflect.ValueOf(key)); value.IsValid() {
+			fromValue = value.String()
+		}
+		if value := to.MapIndex(reflect.ValueOf(key)); value.IsValid() {
+			toValue = value.String()
+		}
+		if fromValue != toValue {
+			diffs = append(diffs, PresetFieldDiff{Field: prefix + key, From: fromValue, To: toValue})
+		}
+	}
+	return diffs
+}
+
+// diffSlices compares slices of structs item by item. Items missing in one of
+// the slices are compared as zero values.
+func diffSlices(from, to reflect.Value, prefix string) []PresetFieldDiff {
+	length := from.Len()
+	if to.Len() > length {
+		length = to.Len()
+	}
+	var diffs []PresetFieldDiff
+	for i := 0; i < length; i++ {
+		fromItem, toItem := sliceItem(from, i), sliceItem(to, i)
+		if fromItem.Kind() != reflect.Struct {
+			continue
+		}
+		diffs = append(diffs, diffStructs(fromItem, toItem, prefix+"["+strconv.Itoa(i)+"].")...)
+	}
+	return diffs
+}
+
+func sliceItem(slice reflect.Value, i int) reflect.Value {
+	if i >= slice.Len() {
+		return reflect.Zero(slice.Type().Elem())
+	}
+	return slice.Index(i)
+}
+
+func derefValue(value reflect.Value) reflect.Value {
+	if value.IsNil() {
+		return reflect.Zero(value.Type().Elem())
+	}
+	return value.Elem()
+}
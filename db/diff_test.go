@@ -0,0 +1,92 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffPresets(t *testing.T) {
+	preset := Preset{
+		Name:        "mp4_1080p",
+		Container:   "mp4",
+		RateControl: "VBR",
+		Video:       VideoPreset{Codec: "h264", Bitrate: "3500000", Height: "1080"},
+		Audio:       AudioPreset{Codec: "aac", Bitrate: "128000"},
+	}
+	var tests = []struct {
+		testCase string
+		to       func(Preset) Preset
+		expected []PresetFieldDiff
+	}{
+		{
+			"same preset",
+			func(p Preset) Preset { return p },
+			nil,
+		},
+		{
+			"changed fields",
+			func(p Preset) Preset {
+				p.RateControl = "CBR"
+				p.Video.Bitrate = "5000000"
+				p.Video.Width = "1920"
+				p.Video.TwoPass = true
+				p.Audio.Codec = ""
+				return p
+			},
+			[]PresetFieldDiff{
+				{Field: "rateControl", From: "VBR", To: "CBR"},
+				{Field: "video.width", From: "", To: "1920"},
+				{Field: "video.bitrate", From: "3500000", To: "5000000"},
+				{Field: "video.twoPass", From: "false", To: "true"},
+				{Field: "audio.codec", From: "aac", To: ""},
+			},
+		},
+		{
+			"added audio tracks",
+			func(p Preset) Preset {
+				p.Audio.Language = "en"
+				p.AudioTracks = []AudioPreset{{Codec: "aac", Language: "es"}}
+				return p
+			},
+			[]PresetFieldDiff{
+				{Field: "audio.language", From: "", To: "en"},
+				{Field: "audioTracks[0].codec", From: "", To: "aac"},
+				{Field: "audioTracks[0].language", From: "", To: "es"},
+			},
+		},
+	}
+	for _, test := range tests {
+		diffs := DiffPresets(preset, test.to(preset))
+		if !reflect.DeepEqual(diffs, test.expected) {
+			t.Errorf("%s: wrong diff\nWant %#v\nGot  %#v", test.testCase, test.expected, diffs)
+		}
+	}
+}
+
+func TestDiffPresetMaps(t *testing.T) {
+	from := PresetMap{
+		Name:            "mp4_1080p",
+		ProviderMapping: map[string]string{"zencoder": "123", "encodingcom": "456"},
+		OutputOpts:      OutputOptions{Extension: "mp4"},
+		Version:         1,
+		Author:          "someone",
+	}
+	to := PresetMap{
+		Name:            "mp4_1080p",
+		ProviderMapping: map[string]string{"zencoder": "789", "bitmovin": "abc"},
+		OutputOpts:      OutputOptions{Extension: "mp4"},
+		Preset:          &Preset{Container: "mp4"},
+		Version:         2,
+		Author:          "someone else",
+	}
+	expected := []PresetFieldDiff{
+		{Field: "providerMapping.bitmovin", From: "", To: "abc"},
+		{Field: "providerMapping.encodingcom", From: "456", To: ""},
+		{Field: "providerMapping.zencoder", From: "123", To: "789"},
+		{Field: "preset.container", From: "", To: "mp4"},
+	}
+	diffs := DiffPresetMaps(from, to)
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("wrong diff\nWant %#v\nGot  %#v", expected, diffs)
+	}
+}
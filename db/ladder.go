@@ -0,0 +1,168 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// cappedBitrateStep is the step used for rounding down capped bitrates, so
+// sources with similar bitrates share the presets generated for them.
+const cappedBitrateStep = 100000
+
+// Ladder is a named set of presetmaps, each one being a rung with a given
+// resolution and bitrate. Jobs using a ladder get the rungs that suit their
+// source media, instead of listing their outputs.
+//
+// swagger:model
+type Ladder struct {
+	// name of the ladder
+	//
+	// unique: true
+	// required: true
+	Name string `redis-hash:"ladder_name" json:"name"`
+
+	// rungs of the ladder
+	//
+	// required: true
+	Rungs []LadderRung `redis-hash:"rungs,expand" json:"rungs"`
+}
+
+// LadderRung represents one rung of a ladder.
+//
+// swagger:model
+type LadderRung struct {
+	// name of the presetmap used for transcoding the rung
+	//
+	// required: true
+	PresetMap string `redis-hash:"presetmap" json:"presetmap"`
+
+	// dimensions of the rung, in pixels. Rungs without a width are only
+	// compared with the height of the source
+	Width  int `redis-hash:"width,omitempty" json:"width,omitempty"`
+	Height int `redis-hash:"height" json:"height"`
+
+	// video bitrate of the rung, in bits per second
+	Bitrate int `redis-hash:"bitrate" json:"bitrate"`
+
+	// minimum duration of the source media, in seconds, for using the
+	// rung. Rungs with a minimum duration, like the highest rungs of a
+	// ladder, are dropped for shorter sources (e.g. previews)
+	MinDuration float64 `redis-hash:"min_duration,omitempty" json:"minDuration,omitempty"`
+}
+
+// JobLadder records how the ladder of a job was resolved, for auditing which
+// rungs were chosen as the outputs of the job.
+//
+// swagger:model
+type JobLadder struct {
+	// name of the ladder
+	Name string `redis-hash:"name" json:"name"`
+
+	// dimensions, bitrate and duration (in seconds) of the source media
+	// used for resolving the ladder, zero when unknown
+	SourceWidth    int     `redis-hash:"source_width" json:"sourceWidth,omitempty"`
+	SourceHeight   int     `redis-hash:"source_height" json:"sourceHeight,omitempty"`
+	SourceBitrate  int     `redis-hash:"source_bitrate" json:"sourceBitrate,omitempty"`
+	SourceDuration float64 `redis-hash:"source_duration" json:"sourceDuration,omitempty"`
+
+	// rungs chosen as outputs of the job, with their bitrate capped at the
+	// bitrate of the source media
+	Rungs []LadderRung `redis-hash:"rungs,expand" json:"rungs"`
+
+	// rungs dropped because they exceed the resolution of the source media,
+	// or because the source is shorter than their minimum duration
+	DroppedRungs []LadderRung `redis-hash:"dropped_rungs,expand" json:"droppedRungs,omitempty"`
+}
+
+// Validate checks that the ladder is properly defined.
+func (l *Ladder) Validate() error {
+	if l.Name == "" {
+		return errors.New("missing field name from the request")
+	}
+	if len(l.Rungs) == 0 {
+		return errors.New("missing field rungs from the request")
+	}
+	presetMaps := make(map[string]bool, len(l.Rungs))
+	for i, rung := range l.Rungs {
+		switch {
+		case rung.PresetMap == "":
+			return fmt.Errorf("invalid rung %d: missing presetmap", i)
+		case presetMaps[rung.PresetMap]:
+			return fmt.Errorf("invalid rung %d: presetmap %q is used in more than one rung", i, rung.PresetMap)
+		case rung.Height <= 0:
+			return fmt.Errorf("invalid rung %d: height must be positive", i)
+		case rung.Width < 0:
+			return fmt.Errorf("invalid rung %d: width can't be negative", i)
+		case rung.Bitrate <= 0:
+			return fmt.Errorf("invalid rung %d: bitrate must be positive", i)
+		case rung.MinDuration < 0:
+			return fmt.Errorf("invalid rung %d: minDuration can't be negative", i)
+		}
+		presetMaps[rung.PresetMap] = true
+	}
+	return nil
+}
+
+// Resolve chooses the rungs that suit source media with the given
+// dimensions, bitrate and duration, dropping the rungs that would upscale the
+// source and the rungs whose minimum duration is longer than the source. The
+// bitrate of the chosen rungs is capped at the bitrate of the source, rounded
+// down to 100 kbps. Unknown (zero) values don't drop or cap any rung, and the
+// lowest rung is kept when all rungs would be dropped.
+func (l *Ladder) Resolve(width, height, bitrate int, duration time.Duration) JobLadder {
+	resolved := JobLadder{
+		Name:           l.Name,
+		SourceWidth:    width,
+		SourceHeight:   height,
+		SourceBitrate:  bitrate,
+		SourceDuration: duration.Seconds(),
+	}
+	for _, rung := range l.Rungs {
+		exceeds := (height > 0 && rung.Height > height) ||
+			(width > 0 && rung.Width > width) ||
+			(duration > 0 && rung.MinDuration > duration.Seconds())
+		if exceeds {
+			resolved.DroppedRungs = append(resolved.DroppedRungs, rung)
+		} else {
+			resolved.Rungs = append(resolved.Rungs, rung.capBitrate(bitrate))
+		}
+	}
+	if len(resolved.Rungs) == 0 && len(l.Rungs) > 0 {
+		lowest := 0
+		for i, rung := range l.Rungs {
+			if rung.lowerThan(l.Rungs[lowest]) {
+				lowest = i
+			}
+		}
+		resolved.Rungs = []LadderRung{l.Rungs[lowest].capBitrate(bitrate)}
+		var dropped []LadderRung
+		for i, rung := range l.Rungs {
+			if i != lowest {
+				dropped = append(dropped, rung)
+			}
+		}
+		resolved.DroppedRungs = dropped
+	}
+	return resolved
+}
+
+// capBitrate returns the rung with its bitrate capped at the given bitrate of
+// the source media, rounded down to cappedBitrateStep.
+func (r LadderRung) capBitrate(bitrate int) LadderRung {
+	if bitrate <= 0 || r.Bitrate <= bitrate {
+		return r
+	}
+	if bitrate >= cappedBitrateStep {
+		bitrate -= bitrate % cappedBitrateStep
+	}
+	r.Bitrate = bitrate
+	return r
+}
+
+func (r LadderRung) lowerThan(other LadderRung) bool {
+	if r.Height != other.Height {
+		return r.Height < other.Height
+	}
+	return r.Bitrate < other.Bitrate
+}
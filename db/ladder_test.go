@@ -0,0 +1,194 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testLadder = Ladder{
+	Name: "web",
+	Rungs: []LadderRung{
+		{PresetMap: "mp4_1080p", Width: 1920, Height: 1080, Bitrate: 5000000, MinDuration: 60},
+		{PresetMap: "mp4_720p", Width: 1280, Height: 720, Bitrate: 3000000},
+		{PresetMap: "mp4_480p", Height: 480, Bitrate: 1200000},
+		{PresetMap: "mp4_360p", Width: 640, Height: 360, Bitrate: 800000},
+	},
+}
+
+func TestLadderValidate(t *testing.T) {
+	var tests = []struct {
+		testCase string
+		ladder   Ladder
+		wantErr  string
+	}{
+		{
+			"valid ladder",
+			testLadder,
+			"",
+		},
+		{
+			"missing name",
+			Ladder{Rungs: testLadder.Rungs},
+			"missing field name from the request",
+		},
+		{
+			"missing rungs",
+			Ladder{Name: "web"},
+			"missing field rungs from the request",
+		},
+		{
+			"rung without presetmap",
+			Ladder{Name: "web", Rungs: []LadderRung{{Height: 720, Bitrate: 3000000}}},
+			"invalid rung 0: missing presetmap",
+		},
+		{
+			"duplicate presetmap",
+			Ladder{Name: "web", Rungs: []LadderRung{
+				{PresetMap: "mp4_720p", Height: 720, Bitrate: 3000000},
+				{PresetMap: "mp4_720p", Height: 720, Bitrate: 2000000},
+			}},
+			`invalid rung 1: presetmap "mp4_720p" is used in more than one rung`,
+		},
+		{
+			"rung without height",
+			Ladder{Name: "web", Rungs: []LadderRung{{PresetMap: "mp4_720p", Bitrate: 3000000}}},
+			"invalid rung 0: height must be positive",
+		},
+		{
+			"rung with negative width",
+			Ladder{Name: "web", Rungs: []LadderRung{{PresetMap: "mp4_720p", Width: -1, Height: 720, Bitrate: 3000000}}},
+			"invalid rung 0: width can't be negative",
+		},
+		{
+			"rung without bitrate",
+			Ladder{Name: "web", Rungs: []LadderRung{{PresetMap: "mp4_720p", Height: 720}}},
+			"invalid rung 0: bitrate must be positive",
+		},
+		{
+			"rung with negative minimum duration",
+			Ladder{Name: "web", Rungs: []LadderRung{{PresetMap: "mp4_720p", Height: 720, Bitrate: 3000000, MinDuration: -1}}},
+			"invalid rung 0: minDuration can't be negative",
+		},
+	}
+	for _, test := range tests {
+		err := test.ladder.Validate()
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", test.testCase, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.wantErr {
+			t.Errorf("%s: wrong error returned. Want %q. Got %v", test.testCase, test.wantErr, err)
+		}
+	}
+}
+
+func TestLadderResolve(t *testing.T) {
+	rungs := testLadder.Rungs
+	capped := func(rung LadderRung, bitrate int) LadderRung {
+		rung.Bitrate = bitrate
+		return rung
+	}
+	var tests = []struct {
+		testCase string
+		width    int
+		height   int
+		bitrate  int
+		duration time.Duration
+		want     JobLadder
+	}{
+		{
+			"unknown source",
+			0, 0, 0, 0,
+			JobLadder{Name: "web", Rungs: rungs},
+		},
+		{
+			"1080p source",
+			1920, 1080, 8000000, time.Hour,
+			JobLadder{
+				Name:           "web",
+				SourceWidth:    1920,
+				SourceHeight:   1080,
+				SourceBitrate:  8000000,
+				SourceDuration: 3600,
+				Rungs:          rungs,
+			},
+		},
+		{
+			"720p source",
+			1280, 720, 0, 0,
+			JobLadder{
+				Name:         "web",
+				SourceWidth:  1280,
+				SourceHeight: 720,
+				Rungs:        rungs[1:],
+				DroppedRungs: rungs[:1],
+			},
+		},
+		{
+			"1080p source with a lower bitrate",
+			1920, 1080, 4000000, 0,
+			JobLadder{
+				Name:          "web",
+				SourceWidth:   1920,
+				SourceHeight:  1080,
+				SourceBitrate: 4000000,
+				Rungs:         []LadderRung{capped(rungs[0], 4000000), rungs[1], rungs[2], rungs[3]},
+			},
+		},
+		{
+			"low bitrate source",
+			1920, 1080, 1543210, 0,
+			JobLadder{
+				Name:          "web",
+				SourceWidth:   1920,
+				SourceHeight:  1080,
+				SourceBitrate: 1543210,
+				Rungs:         []LadderRung{capped(rungs[0], 1500000), capped(rungs[1], 1500000), rungs[2], rungs[3]},
+			},
+		},
+		{
+			"short source",
+			1920, 1080, 0, 30 * time.Second,
+			JobLadder{
+				Name:           "web",
+				SourceWidth:    1920,
+				SourceHeight:   1080,
+				SourceDuration: 30,
+				Rungs:          rungs[1:],
+				DroppedRungs:   rungs[:1],
+			},
+		},
+		{
+			"narrow source",
+			480, 480, 0, 0,
+			JobLadder{
+				Name:         "web",
+				SourceWidth:  480,
+				SourceHeight: 480,
+				Rungs:        []LadderRung{rungs[2]},
+				DroppedRungs: []LadderRung{rungs[0], rungs[1], rungs[3]},
+			},
+		},
+		{
+			"source below all rungs",
+			320, 180, 300000, 0,
+			JobLadder{
+				Name:          "web",
+				SourceWidth:   320,
+				SourceHeight:  180,
+				SourceBitrate: 300000,
+				Rungs:         []LadderRung{capped(rungs[3], 300000)},
+				DroppedRungs:  rungs[:3],
+			},
+		},
+	}
+	for _, test := range tests {
+		got := testLadder.Resolve(test.width, test.height, test.bitrate, test.duration)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: wrong resolved ladder.\nWant %#v\nGot  %#v", test.testCase, test.want, got)
+		}
+	}
+}
@@ -10,7 +10,17 @@ import (
 	"gopkg.in/redis.v5"
 )
 
-const jobsSetKey = "jobs"
+const (
+	jobsSetKey = "jobs"
+
+	// activeJobsSetKey is the key of the sorted set of jobs that are not
+	// done yet, scored by creation time like jobsSetKey (see jobScore).
+	activeJobsSetKey = "jobs:active"
+
+	// jobUpdateAttempts is the number of times UpdateJob applies the update
+	// before giving up when the job keeps being modified concurrently.
+	jobUpdateAttempts = 10
+)
 
 func (r *redisRepository) CreateJob(job *db.Job) error {
 	if job.ID == "" {
@@ -20,30 +30,77 @@ func (r *redisRepository) CreateJob(job *db.Job) error {
 	return r.saveJob(job)
 }
 
+func (r *redisRepository) UpdateJob(id string, update func(*db.Job)) (*db.Job, error) {
+	jobKey := r.jobKey(id)
+	var job *db.Job
+	var err error
+	for i := 0; i < jobUpdateAttempts; i++ {
+		err = r.storage.RedisClient().Watch(func(tx *redis.Tx) error {
+			stored, err := r.GetJob(id)
+			if err != nil {
+				return err
+			}
+			update(stored)
+			stored.ID = id
+			job = stored
+			return r.replaceJob(tx, stored)
+		}, jobKey)
+		if err != redis.TxFailedErr {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
 func (r *redisRepository) saveJob(job *db.Job) error {
+	return r.storage.RedisClient().Watch(func(tx *redis.Tx) error {
+		return r.replaceJob(tx, job)
+	}, r.jobKey(job.ID))
+}
+
+// replaceJob writes the job in a MULTI block of the given transaction. The
+// hash is replaced, so fields cleared in the job don't linger in Redis.
+func (r *redisRepository) replaceJob(tx *redis.Tx, job *db.Job) error {
 	fields, err := r.storage.FieldMap(job)
 	if err != nil {
 		return err
 	}
 	jobKey := r.jobKey(job.ID)
-	return r.storage.RedisClient().Watch(func(tx *redis.Tx) error {
-		err := tx.HMSet(jobKey, fields).Err()
-		if err != nil {
-			return err
+	_, err = tx.Pipelined(func(pipe *redis.Pipeline) error {
+		pipe.Del(jobKey)
+		pipe.HMSet(jobKey, fields)
+		pipe.ZAddNX(jobsSetKey, redis.Z{Member: job.ID, Score: jobScore(job.CreationTime)})
+		if job.ProviderName != "" && job.ProviderJobID != "" {
+			pipe.Set(r.providerJobKey(job.ProviderName, job.ProviderJobID), job.ID, 0)
 		}
-		return tx.ZAddNX(jobsSetKey, redis.Z{Member: job.ID, Score: float64(job.CreationTime.UnixNano())}).Err()
-	}, jobKey)
+		if job.Done() {
+			pipe.ZRem(activeJobsSetKey, job.ID)
+		} else {
+			pipe.ZAdd(activeJobsSetKey, redis.Z{Member: job.ID, Score: jobScore(job.CreationTime)})
+		}
+		return nil
+	})
+	return err
 }
 
 func (r *redisRepository) DeleteJob(job *db.Job) error {
-	err := r.storage.Delete(r.jobKey(job.ID))
+	stored, err := r.GetJob(job.ID)
 	if err != nil {
-		if err == storage.ErrNotFound {
-			return db.ErrJobNotFound
-		}
 		return err
 	}
-	return r.storage.RedisClient().ZRem(jobsSetKey, job.ID).Err()
+	_, err = r.storage.RedisClient().Pipelined(func(pipe *redis.Pipeline) error {
+		pipe.Del(r.jobKey(job.ID))
+		pipe.ZRem(jobsSetKey, job.ID)
+		pipe.ZRem(activeJobsSetKey, job.ID)
+		if stored.ProviderName != "" && stored.ProviderJobID != "" {
+			pipe.Del(r.providerJobKey(stored.ProviderName, stored.ProviderJobID))
+		}
+		return nil
+	})
+	return err
 }
 
 func (r *redisRepository) GetJob(id string) (*db.Job, error) {
@@ -55,33 +112,86 @@ func (r *redisRepository) GetJob(id string) (*db.Job, error) {
 	return &job, err
 }
 
+func (r *redisRepository) GetJobByProviderJobID(providerName, providerJobID string) (*db.Job, error) {
+	id, err := r.storage.RedisClient().Get(r.providerJobKey(providerName, providerJobID)).Result()
+	if err == redis.Nil {
+		return nil, db.ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.GetJob(id)
+}
+
 func (r *redisRepository) ListJobs(filter db.JobFilter) ([]db.Job, error) {
-	now := time.Now().UTC()
+	until := filter.Until
+	if until.IsZero() {
+		until = time.Now().UTC()
+	}
+	since := filter.Since
+	if filter.After.CreationTime.After(since) {
+		since = filter.After.CreationTime
+	}
 	rangeOpts := redis.ZRangeBy{
-		Min:   strconv.FormatInt(filter.Since.UnixNano(), 10),
-		Max:   strconv.FormatInt(now.UnixNano(), 10),
+		Min:   jobScoreBound(since),
+		Max:   jobScoreBound(until),
 		Count: int64(filter.Limit),
 	}
 	if rangeOpts.Count == 0 {
 		rangeOpts.Count = -1
 	}
-	jobIDs, err := r.storage.RedisClient().ZRangeByScore(jobsSetKey, rangeOpts).Result()
-	if err != nil {
-		return nil, err
+	setKey := jobsSetKey
+	if filter.Active {
+		setKey = activeJobsSetKey
 	}
-	jobs := make([]db.Job, 0, len(jobIDs))
-	for _, id := range jobIDs {
-		job, err := r.GetJob(id)
-		if err != nil && err != db.ErrJobNotFound {
+	jobs := make([]db.Job, 0, filter.Limit)
+	for {
+		jobIDs, err := r.storage.RedisClient().ZRangeByScore(setKey, rangeOpts).Result()
+		if err != nil {
 			return nil, err
 		}
-		if job != nil {
+		for _, id := range jobIDs {
+			job, err := r.GetJob(id)
+			if err != nil && err != db.ErrJobNotFound {
+				return nil, err
+			}
+			if job == nil || !filter.After.Precedes(job) || !filter.Match(job) {
+				continue
+			}
 			jobs = append(jobs, *job)
+			if filter.Limit != 0 && uint(len(jobs)) == filter.Limit {
+				return jobs, nil
+			}
 		}
+		if rangeOpts.Count < 0 || int64(len(jobIDs)) < rangeOpts.Count {
+			return jobs, nil
+		}
+		rangeOpts.Offset += int64(len(jobIDs))
 	}
-	return jobs, nil
+}
+
+func (r *redisRepository) ExpireActiveJobs(until time.Time) error {
+	return r.storage.RedisClient().ZRemRangeByScore(activeJobsSetKey, "-inf", "("+jobScoreBound(until)).Err()
+}
+
+// jobScore returns the score of a job created at the given time in the sorted
+// sets of jobs: its Unix time in microseconds (see db.JobTimePrecision), as
+// scores are float64 and can't hold the time in nanoseconds exactly.
+func jobScore(creationTime time.Time) float64 {
+	return float64(creationTime.UnixNano() / int64(db.JobTimePrecision))
+}
+
+// jobScoreBound returns the given time as a bound of a range of job scores.
+func jobScoreBound(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(db.JobTimePrecision), 10)
 }
 
 func (r *redisRepository) jobKey(id string) string {
 	return "job:" + id
 }
+
+// providerJobKey returns the key mapping the id of a job in the provider to
+// the id of the job in the API.
+func (r *redisRepository) providerJobKey(providerName, providerJobID string) string {
+	return "jobs:provider:" + providerName + ":" + providerJobID
+}
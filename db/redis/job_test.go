@@ -4,6 +4,7 @@ import (
 	"math"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -54,14 +55,19 @@ func TestCreateJob(t *testing.T) {
 		t.Fatal(err)
 	}
 	expected := map[string]string{
-		"source":                           "http://nyt.net/source_here.mp4",
-		"jobID":                            "job1",
-		"providerName":                     "encoding.com",
-		"providerJobID":                    "",
-		"streamingparams_segmentDuration":  "10",
-		"streamingparams_protocol":         "hls",
-		"streamingparams_playlistFileName": "hls/playlist.m3u8",
-		"creationTime":                     creationTime.Format(time.RFC3339Nano),
+		"source":                             "http://nyt.net/source_here.mp4",
+		"jobID":                              "job1",
+		"providerName":                       "encoding.com",
+		"providerJobID":                      "",
+		"streamingparams_segmentDuration":    "10",
+		"streamingparams_protocol":           "hls",
+		"streamingparams_playlistFileName":   "hls/playlist.m3u8",
+		"creationTime":                       creationTime.Format(time.RFC3339Nano),
+		"outputs":                            "2",
+		"outputs_0_presetmap_presetmap_name": "preset-1",
+		"outputs_0_filename":                 "output1.m3u8",
+		"outputs_1_presetmap_presetmap_name": "preset-2",
+		"outputs_1_filename":                 "output2.m3u8",
 	}
 	if !reflect.DeepEqual(items, expected) {
 		pretty.Fdiff(os.Stderr, expected, items)
@@ -485,3 +491,612 @@ func TestListJobsFilteringAndLimit(t *testing.T) {
 		t.Errorf("ListJobs({}): wrong list returned. Want %#v. Got %#v", expectedJobs, gotJobs)
 	}
 }
+
+func TestGetJobByProviderJobID(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := []db.Job{
+		{ID: "job-1", ProviderName: "encodingcom", ProviderJobID: "123"},
+		{ID: "job-2", ProviderName: "zencoder", ProviderJobID: "123"},
+	}
+	for i := range jobs {
+		err = repo.CreateJob(&jobs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	gotJob, err := repo.GetJobByProviderJobID("zencoder", "123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotJob.ID != "job-2" {
+		t.Errorf("GetJobByProviderJobID: wrong job returned. Want %q. Got %q", "job-2", gotJob.ID)
+	}
+	_, err = repo.GetJobByProviderJobID("elastictranscoder", "123")
+	if err != db.ErrJobNotFound {
+		t.Errorf("GetJobByProviderJobID: wrong error returned. Want ErrJobNotFound. Got %#v", err)
+	}
+	err = repo.DeleteJob(&db.Job{ID: "job-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.GetJobByProviderJobID("zencoder", "123")
+	if err != db.ErrJobNotFound {
+		t.Errorf("GetJobByProviderJobID: wrong error returned after deleting the job. Want ErrJobNotFound. Got %#v", err)
+	}
+}
+
+func TestUpdateJob(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := db.Job{ID: "myjob", ProviderName: "encodingcom", ProviderJobID: "123", CallbackURL: "http://example.com/callback"}
+	err = repo.CreateJob(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statusUpdateTime := time.Now().UTC().Truncate(time.Millisecond)
+	updatedJob, err := repo.UpdateJob(job.ID, func(job *db.Job) {
+		job.Status = "finished"
+		job.LastStatus = `{"status":"finished","progress":100}`
+		job.StatusUpdateTime = statusUpdateTime
+		job.CallbackURL = ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotJob, err := repo.GetJob(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, j := range []*db.Job{updatedJob, gotJob} {
+		if j.Status != "finished" {
+			t.Errorf("UpdateJob: wrong status. Want %q. Got %q", "finished", j.Status)
+		}
+		if want := `{"status":"finished","progress":100}`; j.LastStatus != want {
+			t.Errorf("UpdateJob: wrong last status. Want %q. Got %q", want, j.LastStatus)
+		}
+		if !j.StatusUpdateTime.Equal(statusUpdateTime) {
+			t.Errorf("UpdateJob: wrong status update time. Want %s. Got %s", statusUpdateTime, j.StatusUpdateTime)
+		}
+		if j.CallbackURL != "" {
+			t.Errorf("UpdateJob: cleared callback url lingered. Got %q", j.CallbackURL)
+		}
+		if j.ProviderJobID != job.ProviderJobID {
+			t.Errorf("UpdateJob: wrong provider job id. Want %q. Got %q", job.ProviderJobID, j.ProviderJobID)
+		}
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	defer client.Close()
+	members, err := client.ZRange(jobsSetKey, 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(members, []string{job.ID}) {
+		t.Errorf("UpdateJob: wrong members in the jobs set. Want %#v. Got %#v", []string{job.ID}, members)
+	}
+}
+
+func TestUpdateJobIsSafe(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := db.Job{ID: "myjob", ProviderName: "encodingcom", ProviderJobID: "123"}
+	err = repo.CreateJob(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const updates = 4
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.UpdateJob(job.ID, func(job *db.Job) {
+				job.LastStatus += "x"
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	gotJob, err := repo.GetJob(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Repeat("x", updates); gotJob.LastStatus != want {
+		t.Errorf("UpdateJob: lost concurrent updates. Want %q. Got %q", want, gotJob.LastStatus)
+	}
+}
+
+func TestActiveJobs(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := []db.Job{
+		{ID: "job-1", ProviderName: "encodingcom", ProviderJobID: "1"},
+		{ID: "job-2", ProviderName: "encodingcom", ProviderJobID: "2", Status: "started"},
+	}
+	for i := range jobs {
+		err = repo.CreateJob(&jobs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	defer client.Close()
+	assertActiveJobs := func(step string, want []string) {
+		members, err := client.ZRange(activeJobsSetKey, 0, -1).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(members, want) {
+			t.Errorf("%s: wrong active jobs. Want %#v. Got %#v", step, want, members)
+		}
+	}
+	assertActiveJobs("CreateJob", []string{"job-1", "job-2"})
+	_, err = repo.UpdateJob("job-1", func(job *db.Job) {
+		job.Status = "finished"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertActiveJobs("UpdateJob", []string{"job-2"})
+	err = repo.DeleteJob(&jobs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertActiveJobs("DeleteJob", []string{})
+}
+
+func TestExpireActiveJobs(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := []db.Job{
+		{ID: "job-1", ProviderName: "encodingcom", ProviderJobID: "1", Status: "started"},
+		{ID: "job-2", ProviderName: "encodingcom", ProviderJobID: "2", Status: "started"},
+	}
+	for i := range jobs {
+		err = repo.CreateJob(&jobs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	defer client.Close()
+	assertActiveJobs := func(step string, want []string) {
+		members, err := client.ZRange(activeJobsSetKey, 0, -1).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(members, want) {
+			t.Errorf("%s: wrong active jobs. Want %#v. Got %#v", step, want, members)
+		}
+	}
+	err = repo.ExpireActiveJobs(jobs[0].CreationTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertActiveJobs("ExpireActiveJobs before the jobs", []string{"job-1", "job-2"})
+	err = repo.ExpireActiveJobs(jobs[1].CreationTime.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertActiveJobs("ExpireActiveJobs after the jobs", []string{})
+	_, err = repo.UpdateJob("job-2", func(job *db.Job) {
+		job.Status = "started"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertActiveJobs("UpdateJob", []string{"job-2"})
+	stored, err := repo.GetJob("job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != "started" {
+		t.Errorf("ExpireActiveJobs changed the job. Want status %q. Got %q", "started", stored.Status)
+	}
+}
+
+func TestUpdateJobNotFound(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.UpdateJob("myjob", func(*db.Job) {})
+	if err != db.ErrJobNotFound {
+		t.Errorf("Wrong error returned by UpdateJob. Want ErrJobNotFound. Got %#v.", err)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	defer client.Close()
+	if exists, err := client.Exists("job:myjob").Result(); err != nil || exists {
+		t.Errorf("UpdateJob: unexpected job created. Exists: %v. Error: %v", exists, err)
+	}
+}
+
+func TestListJobsAttributeFilters(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	jobs := []db.Job{
+		{
+			ID:            "job-1",
+			ProviderName:  "encodingcom",
+			ProviderJobID: "1",
+			Status:        "finished",
+			SourceMedia:   "s3://bucket/a/video.mov",
+			CreationTime:  now.Add(-3 * time.Hour),
+		},
+		{
+			ID:            "job-2",
+			ProviderName:  "zencoder",
+			ProviderJobID: "2",
+			Status:        "started",
+			SourceMedia:   "s3://bucket/a/video.mov",
+			CreationTime:  now.Add(-2 * time.Hour),
+		},
+		{
+			ID:            "job-3",
+			ProviderName:  "encodingcom",
+			ProviderJobID: "3",
+			Status:        "started",
+			SourceMedia:   "s3://bucket/b/video.mov",
+			CreationTime:  now.Add(-time.Hour),
+		},
+	}
+	redisRepo := repo.(*redisRepository)
+	for _, job := range jobs {
+		err = redisRepo.saveJob(&job)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var tests = []struct {
+		givenTestCase string
+		givenFilter   db.JobFilter
+		wantJobs      []db.Job
+	}{
+		{"provider", db.JobFilter{ProviderName: "encodingcom"}, []db.Job{jobs[0], jobs[2]}},
+		{"status", db.JobFilter{Status: "started"}, jobs[1:]},
+		{"active", db.JobFilter{Active: true}, jobs[1:]},
+		{"source prefix", db.JobFilter{SourcePrefix: "s3://bucket/a/"}, jobs[:2]},
+		{"provider and limit", db.JobFilter{ProviderName: "encodingcom", Limit: 1}, jobs[:1]},
+		{"until", db.JobFilter{Until: now.Add(-90 * time.Minute)}, jobs[:2]},
+		{"no match", db.JobFilter{ProviderName: "elastictranscoder"}, []db.Job{}},
+	}
+	for _, test := range tests {
+		gotJobs, err := repo.ListJobs(test.givenFilter)
+		if err != nil {
+			t.Fatalf("%s: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(gotJobs, test.wantJobs) {
+			t.Errorf("%s: wrong list returned. Want %#v. Got %#v", test.givenTestCase, test.wantJobs, gotJobs)
+		}
+	}
+}
+
+func TestListJobsCursor(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	redisRepo := repo.(*redisRepository)
+	jobs := []db.Job{
+		{ID: "job-1", ProviderName: "encodingcom", CreationTime: now.Add(-time.Hour)},
+		{ID: "job-2", ProviderName: "encodingcom", CreationTime: now.Add(-time.Hour)},
+		{ID: "job-3", ProviderName: "encodingcom", CreationTime: now.Add(-30 * time.Minute)},
+	}
+	for _, job := range jobs {
+		err = redisRepo.saveJob(&job)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	firstPage, err := repo.ListJobs(db.JobFilter{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(firstPage, jobs[:1]) {
+		t.Errorf("ListJobs: wrong first page. Want %#v. Got %#v", jobs[:1], firstPage)
+	}
+	newJob := db.Job{ID: "job-0", ProviderName: "encodingcom", CreationTime: now.Add(-time.Second)}
+	err = redisRepo.saveJob(&newJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondPage, err := repo.ListJobs(db.JobFilter{After: db.NewJobCursor(&firstPage[0]), Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(secondPage, jobs[1:]) {
+		t.Errorf("ListJobs: wrong second page. Want %#v. Got %#v", jobs[1:], secondPage)
+	}
+}
+
+func TestListJobsCursorCloseCreationTimes(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the jobs are less than 256ns apart, the precision of a float64 score
+	// holding the creation time in nanoseconds.
+	creationTime := time.Now().UTC().Truncate(time.Second)
+	redisRepo := repo.(*redisRepository)
+	jobs := []db.Job{
+		{ID: "job-a", ProviderName: "encodingcom", CreationTime: creationTime.Add(150 * time.Nanosecond)},
+		{ID: "job-b", ProviderName: "encodingcom", CreationTime: creationTime.Add(50 * time.Nanosecond)},
+	}
+	for _, job := range jobs {
+		err = redisRepo.saveJob(&job)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var gotJobs []db.Job
+	var cursor db.JobCursor
+	for i := 0; i < len(jobs)+1; i++ {
+		page, err := repo.ListJobs(db.JobFilter{After: cursor, Limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		gotJobs = append(gotJobs, page...)
+		cursor = db.NewJobCursor(&page[0])
+	}
+	if !reflect.DeepEqual(gotJobs, jobs) {
+		t.Errorf("ListJobs: wrong jobs paginated. Want %#v. Got %#v", jobs, gotJobs)
+	}
+}
+
+func TestGetJobWithOutputs(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := db.Job{
+		ID:           "myjob",
+		ProviderName: "encodingcom",
+		Outputs: []db.TranscodeOutput{
+			{
+				Preset: db.PresetMap{
+					Name:            "mp4_1080p",
+					ProviderMapping: map[string]string{"encodingcom": "123", "zencoder": "456"},
+					OutputOpts:      db.OutputOptions{Extension: "mp4"},
+				},
+				FileName: "video_1080p.mp4",
+			},
+			{
+				Preset: db.PresetMap{
+					Name:            "webm_720p",
+					ProviderMapping: map[string]string{"encodingcom": "789"},
+					OutputOpts:      db.OutputOptions{Extension: "webm"},
+				},
+				FileName: "video_720p.webm",
+			},
+		},
+	}
+	err = repo.CreateJob(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotJob, err := repo.GetJob(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotJob, job) {
+		pretty.Fdiff(os.Stderr, job, *gotJob)
+		t.Errorf("Wrong job. Want %#v. Got %#v.", job, *gotJob)
+	}
+}
+
+func TestGetJobWithLadder(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := db.Job{
+		ID:           "myjob",
+		ProviderName: "encodingcom",
+		Ladder: &db.JobLadder{
+			Name:         "web",
+			SourceWidth:  1280,
+			SourceHeight: 720,
+			Rungs:        []db.LadderRung{{PresetMap: "mp4_720p", Width: 1280, Height: 720, Bitrate: 3000000}},
+			DroppedRungs: []db.LadderRung{{PresetMap: "mp4_1080p", Width: 1920, Height: 1080, Bitrate: 5000000}},
+		},
+	}
+	err = repo.CreateJob(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotJob, err := repo.GetJob(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotJob, job) {
+		pretty.Fdiff(os.Stderr, job, *gotJob)
+		t.Errorf("Wrong job. Want %#v. Got %#v.", job, *gotJob)
+	}
+}
+
+func TestGetJobWithThumbnails(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := []db.Job{
+		{
+			ID:           "interval",
+			ProviderName: "ffmpeg",
+			Thumbnails: &db.Thumbnails{
+				Interval: 5,
+				Height:   90,
+				Format:   "jpg",
+				Path:     "thumbnails",
+				Sprite:   &db.ThumbnailSprite{Columns: 10, Rows: 5},
+			},
+		},
+		{
+			ID:           "timecodes",
+			ProviderName: "ffmpeg",
+			Thumbnails: &db.Thumbnails{
+				Timecodes: []string{"0", "12.5"},
+				Width:     320,
+				Height:    180,
+				Format:    "png",
+				Path:      "thumbnails",
+			},
+		},
+	}
+	for i := range jobs {
+		err = repo.CreateJob(&jobs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotJob, err := repo.GetJob(jobs[i].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*gotJob, jobs[i]) {
+			pretty.Fdiff(os.Stderr, jobs[i], *gotJob)
+			t.Errorf("Wrong job. Want %#v. Got %#v.", jobs[i], *gotJob)
+		}
+	}
+}
+
+func TestGetJobWithClips(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := db.Job{
+		ID:           "myjob",
+		ProviderName: "ffmpeg",
+		Clip:         &db.Clip{Start: "10", End: "70.5"},
+		Outputs: []db.TranscodeOutput{
+			{Preset: db.PresetMap{Name: "mp4_720p"}, FileName: "video.mp4"},
+			{Preset: db.PresetMap{Name: "mp4_360p"}, FileName: "preview.mp4", Clip: &db.Clip{End: "15"}},
+		},
+	}
+	err = repo.CreateJob(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotJob, err := repo.GetJob(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotJob, job) {
+		pretty.Fdiff(os.Stderr, job, *gotJob)
+		t.Errorf("Wrong job. Want %#v. Got %#v.", job, *gotJob)
+	}
+}
+
+func TestListJobsPresetFilter(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	jobs := []db.Job{
+		{
+			ID:           "job-1",
+			ProviderName: "encodingcom",
+			CreationTime: now.Add(-2 * time.Hour),
+			Outputs:      []db.TranscodeOutput{{Preset: db.PresetMap{Name: "mp4_720p"}, FileName: "video.mp4"}},
+		},
+		{
+			ID:           "job-2",
+			ProviderName: "encodingcom",
+			CreationTime: now.Add(-time.Hour),
+			Outputs: []db.TranscodeOutput{
+				{Preset: db.PresetMap{Name: "mp4_720p"}, FileName: "video_720p.mp4"},
+				{Preset: db.PresetMap{Name: "mp4_1080p"}, FileName: "video_1080p.mp4"},
+			},
+		},
+	}
+	redisRepo := repo.(*redisRepository)
+	for _, job := range jobs {
+		err = redisRepo.saveJob(&job)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	gotJobs, err := repo.ListJobs(db.JobFilter{PresetName: "mp4_1080p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotJobs, jobs[1:]) {
+		t.Errorf("ListJobs: wrong list returned. Want %#v. Got %#v", jobs[1:], gotJobs)
+	}
+}
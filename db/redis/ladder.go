@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+	"gopkg.in/redis.v5"
+)
+
+const laddersSetKey = "ladders"
+
+func (r *redisRepository) CreateLadder(ladder *db.Ladder) error {
+	if _, err := r.GetLadder(ladder.Name); err == nil {
+		return db.ErrLadderAlreadyExists
+	}
+	return r.saveLadder(ladder)
+}
+
+func (r *redisRepository) UpdateLadder(ladder *db.Ladder) error {
+	if _, err := r.GetLadder(ladder.Name); err != nil {
+		return err
+	}
+	return r.saveLadder(ladder)
+}
+
+func (r *redisRepository) saveLadder(ladder *db.Ladder) error {
+	fields, err := r.storage.FieldMap(ladder)
+	if err != nil {
+		return err
+	}
+	ladderKey := r.ladderKey(ladder.Name)
+	return r.storage.RedisClient().Watch(func(tx *redis.Tx) error {
+		// the hash is replaced, so removed rungs don't linger.
+		_, err := tx.Pipelined(func(pipe *redis.Pipeline) error {
+			pipe.Del(ladderKey)
+			pipe.HMSet(ladderKey, fields)
+			pipe.SAdd(laddersSetKey, ladder.Name)
+			return nil
+		})
+		return err
+	}, ladderKey)
+}
+
+func (r *redisRepository) DeleteLadder(ladder *db.Ladder) error {
+	err := r.storage.Delete(r.ladderKey(ladder.Name))
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return db.ErrLadderNotFound
+		}
+		return err
+	}
+	r.storage.RedisClient().SRem(laddersSetKey, ladder.Name)
+	return nil
+}
+
+func (r *redisRepository) GetLadder(name string) (*db.Ladder, error) {
+	ladder := db.Ladder{Name: name}
+	err := r.storage.Load(r.ladderKey(name), &ladder)
+	if err == storage.ErrNotFound {
+		return nil, db.ErrLadderNotFound
+	}
+	return &ladder, err
+}
+
+func (r *redisRepository) ListLadders() ([]db.Ladder, error) {
+	names, err := r.storage.RedisClient().SMembers(laddersSetKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	ladders := make([]db.Ladder, 0, len(names))
+	for _, name := range names {
+		ladder, err := r.GetLadder(name)
+		if err != nil && err != db.ErrLadderNotFound {
+			return nil, err
+		}
+		if ladder != nil {
+			ladders = append(ladders, *ladder)
+		}
+	}
+	return ladders, nil
+}
+
+func (r *redisRepository) ladderKey(name string) string {
+	return "ladder:" + name
+}
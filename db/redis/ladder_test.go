@@ -0,0 +1,216 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+)
+
+func TestCreateLadder(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ladder := db.Ladder{
+		Name: "web",
+		Rungs: []db.LadderRung{
+			{PresetMap: "mp4_1080p", Width: 1920, Height: 1080, Bitrate: 5000000},
+			{PresetMap: "mp4_720p", Height: 720, Bitrate: 3000000},
+		},
+	}
+	err = repo.CreateLadder(&ladder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	defer client.Close()
+	items, err := client.HGetAll("ladder:web").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedItems := map[string]string{
+		"ladder_name":       "web",
+		"rungs":             "2",
+		"rungs_0_presetmap": "mp4_1080p",
+		"rungs_0_width":     "1920",
+		"rungs_0_height":    "1080",
+		"rungs_0_bitrate":   "5000000",
+		"rungs_1_presetmap": "mp4_720p",
+		"rungs_1_width":     "0",
+		"rungs_1_height":    "720",
+		"rungs_1_bitrate":   "3000000",
+	}
+	if !reflect.DeepEqual(items, expectedItems) {
+		t.Errorf("Wrong ladder hash returned from Redis. Want %#v. Got %#v", expectedItems, items)
+	}
+	gotLadder, err := repo.GetLadder("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotLadder, ladder) {
+		t.Errorf("Wrong ladder. Want %#v. Got %#v", ladder, *gotLadder)
+	}
+}
+
+func TestCreateLadderDuplicate(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ladder := db.Ladder{Name: "web", Rungs: []db.LadderRung{{PresetMap: "mp4_720p", Height: 720, Bitrate: 3000000}}}
+	err = repo.CreateLadder(&ladder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.CreateLadder(&ladder)
+	if err != db.ErrLadderAlreadyExists {
+		t.Errorf("Wrong error returned by CreateLadder. Want ErrLadderAlreadyExists. Got %#v.", err)
+	}
+}
+
+func TestUpdateLadderRemovedRungs(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ladder := db.Ladder{
+		Name: "web",
+		Rungs: []db.LadderRung{
+			{PresetMap: "mp4_1080p", Width: 1920, Height: 1080, Bitrate: 5000000},
+			{PresetMap: "mp4_720p", Height: 720, Bitrate: 3000000},
+		},
+	}
+	err = repo.CreateLadder(&ladder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ladder.Rungs = ladder.Rungs[1:]
+	err = repo.UpdateLadder(&ladder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotLadder, err := repo.GetLadder("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotLadder, ladder) {
+		t.Errorf("Wrong ladder. Want %#v. Got %#v", ladder, *gotLadder)
+	}
+}
+
+func TestUpdateLadderNotFound(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.UpdateLadder(&db.Ladder{Name: "non-existent"})
+	if err != db.ErrLadderNotFound {
+		t.Errorf("Wrong error returned by UpdateLadder. Want ErrLadderNotFound. Got %#v.", err)
+	}
+}
+
+func TestListLadders(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ladders := []db.Ladder{
+		{Name: "mobile", Rungs: []db.LadderRung{{PresetMap: "mp4_360p", Height: 360, Bitrate: 800000}}},
+		{Name: "web", Rungs: []db.LadderRung{{PresetMap: "mp4_720p", Height: 720, Bitrate: 3000000}}},
+	}
+	for i := range ladders {
+		err = repo.CreateLadder(&ladders[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	gotLadders, err := repo.ListLadders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the names of the ladders are stored in a set, so the order of the
+	// list is not important.
+	expected := ladderListToMap(ladders)
+	got := ladderListToMap(gotLadders)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("ListLadders(): wrong list. Want %#v. Got %#v.", ladders, gotLadders)
+	}
+}
+
+func TestDeleteLadder(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ladder := db.Ladder{Name: "web", Rungs: []db.LadderRung{{PresetMap: "mp4_720p", Height: 720, Bitrate: 3000000}}}
+	err = repo.CreateLadder(&ladder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.DeleteLadder(&db.Ladder{Name: ladder.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	result := client.HGetAll("ladder:web")
+	if len(result.Val()) != 0 {
+		t.Errorf("Unexpected value after delete call: %v", result.Val())
+	}
+	ladders, err := repo.ListLadders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ladders) != 0 {
+		t.Errorf("Unexpected ladders after delete call: %#v", ladders)
+	}
+}
+
+func TestDeleteLadderNotFound(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.DeleteLadder(&db.Ladder{Name: "non-existent"})
+	if err != db.ErrLadderNotFound {
+		t.Errorf("Wrong error returned by DeleteLadder. Want ErrLadderNotFound. Got %#v.", err)
+	}
+}
+
+func ladderListToMap(ladders []db.Ladder) map[string]db.Ladder {
+	result := make(map[string]db.Ladder, len(ladders))
+	for _, ladder := range ladders {
+		result[ladder.Name] = ladder
+	}
+	return result
+}
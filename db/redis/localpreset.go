@@ -34,7 +34,13 @@ func (r *redisRepository) saveLocalPreset(localPreset *db.LocalPreset) error {
 	}
 	localPresetKey := r.localPresetKey(localPreset.Name)
 	return r.storage.RedisClient().Watch(func(tx *redis.Tx) error {
-		err := tx.HMSet(localPresetKey, fields).Err()
+		// the hash is replaced, so fields that are no longer set in the
+		// preset don't linger after updates.
+		_, err := tx.Pipelined(func(pipe *redis.Pipeline) error {
+			pipe.Del(localPresetKey)
+			pipe.HMSet(localPresetKey, fields)
+			return nil
+		})
 		if err != nil {
 			return err
 		}
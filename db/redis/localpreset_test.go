@@ -109,6 +109,40 @@ func TestUpdateLocalPreset(t *testing.T) {
 	}
 }
 
+func TestUpdateLocalPresetRemovedFields(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	preset := db.LocalPreset{
+		Name: "test",
+		Preset: db.Preset{
+			Name:  "test",
+			Video: db.VideoPreset{Codec: "h264", CRF: "23"},
+		},
+	}
+	err = repo.CreateLocalPreset(&preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preset.Preset.Video.CRF = ""
+	err = repo.UpdateLocalPreset(&preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotPreset, err := repo.GetLocalPreset(preset.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotPreset, preset) {
+		t.Errorf("Wrong preset. Want %#v. Got %#v.", preset, *gotPreset)
+	}
+}
+
 func TestUpdateLocalPresetNotFound(t *testing.T) {
 	err := cleanRedis()
 	if err != nil {
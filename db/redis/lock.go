@@ -0,0 +1,35 @@
+package redis
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// releaseLockScript deletes the lock only if it still holds the given token,
+// so a lock that expired and was acquired by someone else isn't released.
+const releaseLockScript = `if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`
+
+func (r *redisRepository) AcquireLock(name string, duration time.Duration) (string, error) {
+	var data [16]byte
+	if _, err := rand.Read(data[:]); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(data[:])
+	acquired, err := r.storage.RedisClient().SetNX(r.lockKey(name), token, duration).Result()
+	if err != nil || !acquired {
+		return "", err
+	}
+	return token, nil
+}
+
+func (r *redisRepository) ReleaseLock(name, token string) error {
+	return r.storage.RedisClient().Eval(releaseLockScript, []string{r.lockKey(name)}, token).Err()
+}
+
+func (r *redisRepository) lockKey(name string) string {
+	return "lock:" + name
+}
@@ -0,0 +1,64 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+)
+
+func TestLocks(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := repo.AcquireLock("poller", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == "" {
+		t.Fatal("lock not acquired")
+	}
+	other, err := repo.AcquireLock("poller", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other != "" {
+		t.Errorf("lock acquired twice. Got token %q", other)
+	}
+	if err = repo.ReleaseLock("poller", "wrong-token"); err != nil {
+		t.Fatal(err)
+	}
+	if other, _ = repo.AcquireLock("poller", time.Minute); other != "" {
+		t.Error("lock released with the wrong token")
+	}
+	if err = repo.ReleaseLock("poller", token); err != nil {
+		t.Fatal(err)
+	}
+	if other, _ = repo.AcquireLock("poller", time.Minute); other == "" {
+		t.Error("lock not acquired after releasing it")
+	}
+}
+
+func TestLockExpiration(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, err := repo.AcquireLock("poller", 10*time.Millisecond); err != nil || token == "" {
+		t.Fatalf("lock not acquired: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if token, err := repo.AcquireLock("poller", time.Minute); err != nil || token == "" {
+		t.Errorf("lock not acquired after expiring: %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package redis
+
+import (
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+	"gopkg.in/redis.v5"
+)
+
+const (
+	schemaVersionKey     = "schema-version"
+	currentSchemaVersion = 5
+)
+
+// Migrate updates the data stored in Redis to the layout expected by the
+// current version of the repository. It's safe to call it multiple times and
+// concurrently with other instances of the API.
+//
+// Version 1 stores the outputs of jobs. Outputs of jobs created before that
+// were never persisted and can't be recovered, so their hashes get an
+// explicit empty list of outputs.
+//
+// Version 2 records the first version of presetmaps created before presetmaps
+// were versioned.
+//
+// Version 3 indexes the jobs that are not done yet, so the status poller
+// doesn't need to load finished jobs. Jobs older than the maximum age of the
+// status poller are expired from the index by the poller itself.
+//
+// Version 4 indexes jobs by their id in the provider, for looking them up
+// when providers push notifications.
+//
+// Version 5 scores the sorted sets of jobs by their creation time in
+// microseconds instead of nanoseconds, which Redis can't store exactly.
+func Migrate(cfg *config.Config) error {
+	s, err := storage.NewStorage(cfg.Redis)
+	if err != nil {
+		return err
+	}
+	r := &redisRepository{config: cfg, storage: s}
+	return r.migrate()
+}
+
+func (r *redisRepository) migrate() error {
+	client := r.storage.RedisClient()
+	version, err := client.Get(schemaVersionKey).Int64()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if version >= currentSchemaVersion {
+		return nil
+	}
+	if version < 1 {
+		err = r.migrateJobOutputs()
+		if err != nil {
+			return err
+		}
+	}
+	if version < 2 {
+		err = r.migratePresetVersions()
+		if err != nil {
+			return err
+		}
+	}
+	if version < 3 {
+		err = r.migrateActiveJobs()
+		if err != nil {
+			return err
+		}
+	}
+	if version < 4 {
+		err = r.migrateProviderJobIDs()
+		if err != nil {
+			return err
+		}
+	}
+	if version < 5 {
+		err = r.migrateJobScores()
+		if err != nil {
+			return err
+		}
+	}
+	return client.Set(schemaVersionKey, currentSchemaVersion, 0).Err()
+}
+
+func (r *redisRepository) migrateJobOutputs() error {
+	client := r.storage.RedisClient()
+	jobIDs, err := client.ZRange(jobsSetKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range jobIDs {
+		jobKey := r.jobKey(id)
+		err = client.Watch(func(tx *redis.Tx) error {
+			exists, err := tx.HExists(jobKey, "jobID").Result()
+			if err != nil || !exists {
+				return err
+			}
+			return tx.HSetNX(jobKey, "outputs", "0").Err()
+		}, jobKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *redisRepository) migrateActiveJobs() error {
+	client := r.storage.RedisClient()
+	// jobs are filtered by their creation time rather than by their score,
+	// as jobs are scored in nanoseconds before version 5.
+	jobIDs, err := client.ZRange(jobsSetKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range jobIDs {
+		job, err := r.GetJob(id)
+		if err == db.ErrJobNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if job.Done() {
+			continue
+		}
+		err = client.ZAdd(activeJobsSetKey, redis.Z{Member: id, Score: jobScore(job.CreationTime)}).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateJobScores rescores the jobs in the sorted sets of jobs from the
+// creation time of the job, leaving out the jobs that no longer exist.
+func (r *redisRepository) migrateJobScores() error {
+	client := r.storage.RedisClient()
+	for _, setKey := range []string{jobsSetKey, activeJobsSetKey} {
+		jobIDs, err := client.ZRange(setKey, 0, -1).Result()
+		if err != nil {
+			return err
+		}
+		for _, id := range jobIDs {
+			job, err := r.GetJob(id)
+			if err == db.ErrJobNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			err = client.ZAddXX(setKey, redis.Z{Member: id, Score: jobScore(job.CreationTime)}).Err()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *redisRepository) migrateProviderJobIDs() error {
+	client := r.storage.RedisClient()
+	jobIDs, err := client.ZRange(jobsSetKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range jobIDs {
+		job, err := r.GetJob(id)
+		if err == db.ErrJobNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if job.ProviderName == "" || job.ProviderJobID == "" {
+			continue
+		}
+		err = client.SetNX(r.providerJobKey(job.ProviderName, job.ProviderJobID), id, 0).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *redisRepository) migratePresetVersions() error {
+	client := r.storage.RedisClient()
+	names, err := client.SMembers(presetmapsSetKey).Result()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		count, err := client.LLen(r.presetVersionsKey(name)).Result()
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		presetMap, err := r.GetPresetMap(name)
+		if err == db.ErrPresetMapNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = r.savePresetMap(presetMap, db.PresetMap{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
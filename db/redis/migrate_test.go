@@ -0,0 +1,322 @@
+package redis
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+	"gopkg.in/redis.v5"
+)
+
+func TestMigrateJobOutputs(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{Redis: new(storage.Config)}
+	repo, err := NewRepository(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	legacyJob := map[string]string{
+		"jobID":         "legacy-job",
+		"providerName":  "encodingcom",
+		"providerJobID": "123",
+		"creationTime":  "2017-01-01T00:00:00Z",
+	}
+	err = client.HMSet("job:legacy-job", legacyJob).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.ZAdd(jobsSetKey, redis.Z{Member: "legacy-job", Score: 1}).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// dangling entry in the set, it should not create a new hash.
+	err = client.ZAdd(jobsSetKey, redis.Z{Member: "deleted-job", Score: 2}).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := db.Job{
+		ID:           "new-job",
+		ProviderName: "encodingcom",
+		Outputs:      []db.TranscodeOutput{{Preset: db.PresetMap{Name: "preset-1"}, FileName: "output.mp4"}},
+	}
+	err = repo.CreateJob(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		err = Migrate(&cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	gotLegacyJob, err := repo.GetJob("legacy-job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotLegacyJob.Outputs == nil || len(gotLegacyJob.Outputs) != 0 {
+		t.Errorf("Wrong outputs for the legacy job. Want empty list. Got %#v", gotLegacyJob.Outputs)
+	}
+	gotJob, err := repo.GetJob(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotJob, job) {
+		t.Errorf("Migrate changed a job in the current layout. Want %#v. Got %#v", job, *gotJob)
+	}
+	exists, err := client.Exists("job:deleted-job").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("Migrate created a hash for a job that doesn't exist")
+	}
+	version, err := client.Get(schemaVersionKey).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != strconv.Itoa(currentSchemaVersion) {
+		t.Errorf("Wrong schema version. Want %d. Got %s", currentSchemaVersion, version)
+	}
+}
+
+func TestMigratePresetVersions(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{Redis: new(storage.Config)}
+	repo, err := NewRepository(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	legacyPresetMap := map[string]string{
+		"presetmap_name":    "legacy-preset",
+		"pmapping_zencoder": "123",
+		"output_extension":  "mp4",
+	}
+	err = client.HMSet("presetmap:legacy-preset", legacyPresetMap).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.SAdd(presetmapsSetKey, "legacy-preset").Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	presetMap := db.PresetMap{Name: "new-preset", ProviderMapping: map[string]string{"zencoder": "456"}}
+	err = repo.CreatePresetMap(&presetMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		err = Migrate(&cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var tests = []struct {
+		name        string
+		wantMapping map[string]string
+	}{
+		{"legacy-preset", map[string]string{"zencoder": "123"}},
+		{"new-preset", map[string]string{"zencoder": "456"}},
+	}
+	for _, test := range tests {
+		versions, err := repo.ListPresetVersions(test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != 1 {
+			t.Errorf("%s: wrong number of versions. Want 1. Got %d", test.name, len(versions))
+			continue
+		}
+		if !reflect.DeepEqual(versions[0].PresetMap.ProviderMapping, test.wantMapping) {
+			t.Errorf("%s: wrong provider mapping. Want %#v. Got %#v", test.name, test.wantMapping, versions[0].PresetMap.ProviderMapping)
+		}
+		gotPresetMap, err := repo.GetPresetMap(test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotPresetMap.Version != 1 {
+			t.Errorf("%s: wrong version of the presetmap. Want 1. Got %d", test.name, gotPresetMap.Version)
+		}
+	}
+}
+
+func TestMigrateActiveJobs(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{Redis: new(storage.Config)}
+	repo, err := NewRepository(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	now := time.Now().UTC().Truncate(time.Second)
+	creationTimes := []time.Time{
+		now.Add(-100 * time.Hour),
+		now.Add(-3 * time.Hour),
+		now.Add(-2 * time.Hour),
+		now.Add(-time.Hour),
+	}
+	legacyJobs := []map[string]string{
+		{"jobID": "job-0", "providerName": "encodingcom", "providerJobID": "0", "status": "started", "outputs": "0"},
+		{"jobID": "job-1", "providerName": "encodingcom", "providerJobID": "1", "status": "started", "outputs": "0"},
+		{"jobID": "job-2", "providerName": "encodingcom", "providerJobID": "2", "status": "finished", "outputs": "0"},
+		{"jobID": "job-3", "providerName": "encodingcom", "providerJobID": "3", "outputs": "0"},
+	}
+	for i, job := range legacyJobs {
+		job["creationTime"] = creationTimes[i].Format(time.RFC3339)
+		err = client.HMSet("job:"+job["jobID"], job).Err()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = client.ZAdd(jobsSetKey, redis.Z{Member: job["jobID"], Score: float64(creationTimes[i].UnixNano())}).Err()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = client.Set(schemaVersionKey, 2, 0).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Migrate(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := repo.ListJobs(db.JobFilter{Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	if want := []string{"job-0", "job-1", "job-3"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Wrong active jobs after migrating. Want %#v. Got %#v", want, ids)
+	}
+	// jobs past the maximum age of the status poller are expired by the
+	// poller.
+	if err = repo.ExpireActiveJobs(now.Add(-72 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err = repo.ListJobs(db.JobFilter{Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != "job-1" {
+		t.Errorf("Wrong active jobs after expiring. Want job-1 and job-3. Got %#v", jobs)
+	}
+}
+
+func TestMigrateProviderJobIDs(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{Redis: new(storage.Config)}
+	repo, err := NewRepository(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	legacyJob := map[string]string{
+		"jobID":         "job-1",
+		"providerName":  "encodingcom",
+		"providerJobID": "123",
+		"creationTime":  "2017-01-01T00:00:00Z",
+		"outputs":       "0",
+	}
+	err = client.HMSet("job:job-1", legacyJob).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.ZAdd(jobsSetKey, redis.Z{Member: "job-1", Score: 1}).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Set(schemaVersionKey, 3, 0).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Migrate(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := repo.GetJobByProviderJobID("encodingcom", "123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != "job-1" {
+		t.Errorf("Wrong job returned after migrating. Want %q. Got %q", "job-1", job.ID)
+	}
+}
+
+func TestMigrateJobScores(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{Redis: new(storage.Config)}
+	repo, err := NewRepository(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	creationTime := time.Date(2017, 1, 1, 0, 0, 0, 123456789, time.UTC)
+	legacyJob := map[string]string{
+		"jobID":        "job-1",
+		"providerName": "encodingcom",
+		"status":       "started",
+		"creationTime": creationTime.Format(time.RFC3339Nano),
+		"outputs":      "0",
+	}
+	err = client.HMSet("job:job-1", legacyJob).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, setKey := range []string{jobsSetKey, activeJobsSetKey} {
+		err = client.ZAdd(setKey, redis.Z{Member: "job-1", Score: float64(creationTime.UnixNano())}).Err()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = client.ZAdd(jobsSetKey, redis.Z{Member: "job-2", Score: float64(creationTime.UnixNano())}).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Set(schemaVersionKey, 4, 0).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Migrate(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, setKey := range []string{jobsSetKey, activeJobsSetKey} {
+		score, err := client.ZScore(setKey, "job-1").Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := float64(1483228800123456); score != want {
+			t.Errorf("Wrong score of the job in %q after migrating. Want %f. Got %f", setKey, want, score)
+		}
+	}
+	jobs, err := repo.ListJobs(db.JobFilter{Since: creationTime.Add(-time.Second), Until: creationTime.Add(time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != "job-1" {
+		t.Errorf("Wrong jobs listed after migrating. Want job-1. Got %#v", jobs)
+	}
+}
@@ -0,0 +1,126 @@
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+	"gopkg.in/redis.v5"
+)
+
+// pendingNotificationsSetKey is the key of the sorted set of notifications
+// that are still to be delivered, scored by the time of their next attempt.
+const pendingNotificationsSetKey = "notifications:pending"
+
+func (r *redisRepository) CreateNotification(notification *db.Notification) error {
+	if notification.ID == "" {
+		return errors.New("notification id is required")
+	}
+	if notification.CreationTime.IsZero() {
+		notification.CreationTime = time.Now().UTC()
+	}
+	return r.saveNotification(notification)
+}
+
+func (r *redisRepository) UpdateNotification(notification *db.Notification) error {
+	if _, err := r.GetNotification(notification.ID); err != nil {
+		return err
+	}
+	return r.saveNotification(notification)
+}
+
+func (r *redisRepository) saveNotification(notification *db.Notification) error {
+	fields, err := r.storage.FieldMap(notification)
+	if err != nil {
+		return err
+	}
+	notificationKey := r.notificationKey(notification.ID)
+	return r.storage.RedisClient().Watch(func(tx *redis.Tx) error {
+		_, err := tx.Pipelined(func(pipe *redis.Pipeline) error {
+			pipe.Del(notificationKey)
+			pipe.HMSet(notificationKey, fields)
+			pipe.ZAddNX(r.jobNotificationsKey(notification.JobID), redis.Z{
+				Member: notification.ID,
+				Score:  float64(notification.CreationTime.UnixNano()),
+			})
+			if notification.Pending() {
+				pipe.ZAdd(pendingNotificationsSetKey, redis.Z{
+					Member: notification.ID,
+					Score:  pendingNotificationScore(notification),
+				})
+			} else {
+				pipe.ZRem(pendingNotificationsSetKey, notification.ID)
+			}
+			return nil
+		})
+		return err
+	}, notificationKey)
+}
+
+// pendingNotificationScore returns the score of the notification in the set
+// of pending notifications: the time of its next attempt, or zero when it's
+// due immediately.
+func pendingNotificationScore(notification *db.Notification) float64 {
+	if notification.NextAttemptTime.IsZero() {
+		return 0
+	}
+	return float64(notification.NextAttemptTime.UnixNano())
+}
+
+func (r *redisRepository) GetNotification(id string) (*db.Notification, error) {
+	notification := db.Notification{ID: id}
+	err := r.storage.Load(r.notificationKey(id), &notification)
+	if err == storage.ErrNotFound {
+		return nil, db.ErrNotificationNotFound
+	}
+	return &notification, err
+}
+
+func (r *redisRepository) ListNotifications(jobID string) ([]db.Notification, error) {
+	ids, err := r.storage.RedisClient().ZRange(r.jobNotificationsKey(jobID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	notifications := make([]db.Notification, 0, len(ids))
+	for _, id := range ids {
+		notification, err := r.GetNotification(id)
+		if err != nil && err != db.ErrNotificationNotFound {
+			return nil, err
+		}
+		if notification != nil {
+			notifications = append(notifications, *notification)
+		}
+	}
+	return notifications, nil
+}
+
+func (r *redisRepository) ListPendingNotifications(until time.Time) ([]db.Notification, error) {
+	ids, err := r.storage.RedisClient().ZRangeByScore(pendingNotificationsSetKey, redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(until.UnixNano(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	notifications := make([]db.Notification, 0, len(ids))
+	for _, id := range ids {
+		notification, err := r.GetNotification(id)
+		if err != nil && err != db.ErrNotificationNotFound {
+			return nil, err
+		}
+		if notification != nil {
+			notifications = append(notifications, *notification)
+		}
+	}
+	return notifications, nil
+}
+
+func (r *redisRepository) notificationKey(id string) string {
+	return "notification:" + id
+}
+
+func (r *redisRepository) jobNotificationsKey(jobID string) string {
+	return "jobnotifications:" + jobID
+}
@@ -0,0 +1,208 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+)
+
+func TestCreateNotification(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification := db.Notification{
+		ID:          "notification-1",
+		JobID:       "job-1",
+		Status:      "finished",
+		CallbackURL: "https://example.com/callback",
+		Payload:     `{"jobId":"job-1"}`,
+	}
+	err = repo.CreateNotification(&notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notification.CreationTime.IsZero() {
+		t.Error("Should set the creation time of the notification, but did not")
+	}
+	notifications, err := repo.ListNotifications("job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []db.Notification{notification}
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("ListNotifications: wrong list returned. Want %#v. Got %#v", expected, notifications)
+	}
+}
+
+func TestCreateNotificationNoID(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.CreateNotification(&db.Notification{JobID: "job-1"})
+	if err == nil {
+		t.Fatal("Got unexpected <nil> error")
+	}
+	expectedMsg := "notification id is required"
+	if err.Error() != expectedMsg {
+		t.Errorf("Got wrong error message. Want %q. Got %q", expectedMsg, err.Error())
+	}
+}
+
+func TestUpdateNotification(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	notification := db.Notification{
+		ID:           "notification-1",
+		JobID:        "job-1",
+		Status:       "failed",
+		CallbackURL:  "https://example.com/callback",
+		CreationTime: now,
+	}
+	err = repo.CreateNotification(&notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification.Attempts = []db.NotificationAttempt{
+		{Time: now.Add(time.Second), Error: "connection refused"},
+		{Time: now.Add(2 * time.Second), StatusCode: 500},
+	}
+	notification.DeadLetter = true
+	err = repo.UpdateNotification(&notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifications, err := repo.ListNotifications("job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []db.Notification{notification}
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("ListNotifications: wrong list returned. Want %#v. Got %#v", expected, notifications)
+	}
+	got, err := repo.GetNotification(notification.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*got, notification) {
+		t.Errorf("GetNotification: wrong notification returned. Want %#v. Got %#v", notification, *got)
+	}
+}
+
+func TestUpdateNotificationNotFound(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.UpdateNotification(&db.Notification{ID: "notification-1", JobID: "job-1"})
+	if err != db.ErrNotificationNotFound {
+		t.Errorf("Wrong error returned by UpdateNotification. Want ErrNotificationNotFound. Got %#v.", err)
+	}
+	_, err = repo.GetNotification("notification-1")
+	if err != db.ErrNotificationNotFound {
+		t.Errorf("Wrong error returned by GetNotification. Want ErrNotificationNotFound. Got %#v.", err)
+	}
+}
+
+func TestListNotificationsOrder(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	notifications := []db.Notification{
+		{ID: "notification-b", JobID: "job-1", Status: "started", CreationTime: now.Add(-time.Minute)},
+		{ID: "notification-a", JobID: "job-1", Status: "finished", CreationTime: now},
+	}
+	for i := len(notifications) - 1; i >= 0; i-- {
+		err = repo.CreateNotification(&notifications[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = repo.CreateNotification(&db.Notification{ID: "notification-c", JobID: "job-2", Status: "started"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotNotifications, err := repo.ListNotifications("job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotNotifications, notifications) {
+		t.Errorf("ListNotifications: wrong list returned. Want %#v. Got %#v", notifications, gotNotifications)
+	}
+}
+
+func TestListPendingNotifications(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	notifications := []db.Notification{
+		{ID: "notification-1", JobID: "job-1", CreationTime: now, NextAttemptTime: now.Add(time.Minute)},
+		{ID: "notification-2", JobID: "job-1", CreationTime: now, NextAttemptTime: now.Add(-time.Minute)},
+		{ID: "notification-3", JobID: "job-2", CreationTime: now, Delivered: true},
+		{ID: "notification-4", JobID: "job-3", CreationTime: now},
+	}
+	for i := range notifications {
+		err = repo.CreateNotification(&notifications[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	gotNotifications, err := repo.ListPendingNotifications(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []db.Notification{notifications[3], notifications[1]}
+	if !reflect.DeepEqual(gotNotifications, expected) {
+		t.Errorf("ListPendingNotifications: wrong list returned. Want %#v. Got %#v", expected, gotNotifications)
+	}
+
+	notifications[1].DeadLetter = true
+	err = repo.UpdateNotification(&notifications[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotNotifications, err = repo.ListPendingNotifications(now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []db.Notification{notifications[3], notifications[0]}
+	if !reflect.DeepEqual(gotNotifications, expected) {
+		t.Errorf("ListPendingNotifications: wrong list returned after update. Want %#v. Got %#v", expected, gotNotifications)
+	}
+}
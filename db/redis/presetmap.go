@@ -1,6 +1,9 @@
 package redis
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
 	"gopkg.in/redis.v5"
@@ -12,29 +15,60 @@ func (r *redisRepository) CreatePresetMap(presetMap *db.PresetMap) error {
 	if _, err := r.GetPresetMap(presetMap.Name); err == nil {
 		return db.ErrPresetMapAlreadyExists
 	}
-	return r.savePresetMap(presetMap)
+	return r.savePresetMap(presetMap, db.PresetMap{})
 }
 
 func (r *redisRepository) UpdatePresetMap(presetMap *db.PresetMap) error {
-	if _, err := r.GetPresetMap(presetMap.Name); err == db.ErrPresetMapNotFound {
+	previous, err := r.GetPresetMap(presetMap.Name)
+	if err != nil {
 		return err
 	}
-	return r.savePresetMap(presetMap)
+	return r.savePresetMap(presetMap, *previous)
 }
 
-func (r *redisRepository) savePresetMap(presetMap *db.PresetMap) error {
-	fields, err := r.storage.FieldMap(presetMap)
-	if err != nil {
-		return err
-	}
+// savePresetMap saves the presetmap along with a new version of it, recording
+// the changes from the previous version of the presetmap.
+func (r *redisRepository) savePresetMap(presetMap *db.PresetMap, previous db.PresetMap) error {
 	presetMapKey := r.presetMapKey(presetMap.Name)
+	versionsKey := r.presetVersionsKey(presetMap.Name)
 	return r.storage.RedisClient().Watch(func(tx *redis.Tx) error {
-		err := tx.HMSet(presetMapKey, fields).Err()
+		count, err := tx.LLen(versionsKey).Result()
+		if err != nil {
+			return err
+		}
+		saved := *presetMap
+		saved.Version = int(count) + 1
+		version := db.PresetVersion{
+			Version:      saved.Version,
+			PresetMap:    saved,
+			Author:       saved.Author,
+			CreationTime: time.Now().UTC(),
+			Changes:      db.DiffPresetMaps(previous, saved),
+		}
+		fields, err := r.storage.FieldMap(&saved)
+		if err != nil {
+			return err
+		}
+		versionFields, err := r.storage.FieldMap(&version)
 		if err != nil {
 			return err
 		}
-		return tx.SAdd(presetmapsSetKey, presetMap.Name).Err()
-	}, presetMapKey)
+		_, err = tx.Pipelined(func(pipe *redis.Pipeline) error {
+			// the hash is replaced, so fields removed from the
+			// presetmap don't linger.
+			pipe.Del(presetMapKey)
+			pipe.HMSet(presetMapKey, fields)
+			pipe.SAdd(presetmapsSetKey, presetMap.Name)
+			pipe.HMSet(r.presetVersionKey(presetMap.Name, version.Version), versionFields)
+			pipe.RPush(versionsKey, version.Version)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		presetMap.Version = saved.Version
+		return nil
+	}, presetMapKey, versionsKey)
 }
 
 func (r *redisRepository) DeletePresetMap(presetMap *db.PresetMap) error {
@@ -76,6 +110,39 @@ func (r *redisRepository) ListPresetMaps() ([]db.PresetMap, error) {
 	return presetsMap, nil
 }
 
+func (r *redisRepository) ListPresetVersions(name string) ([]db.PresetVersion, error) {
+	count, err := r.storage.RedisClient().LLen(r.presetVersionsKey(name)).Result()
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]db.PresetVersion, 0, count)
+	for i := 1; i <= int(count); i++ {
+		version, err := r.GetPresetVersion(name, i)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *version)
+	}
+	return versions, nil
+}
+
+func (r *redisRepository) GetPresetVersion(name string, version int) (*db.PresetVersion, error) {
+	presetVersion := db.PresetVersion{PresetMap: db.PresetMap{ProviderMapping: make(map[string]string)}}
+	err := r.storage.Load(r.presetVersionKey(name, version), &presetVersion)
+	if err == storage.ErrNotFound {
+		return nil, db.ErrPresetVersionNotFound
+	}
+	return &presetVersion, err
+}
+
 func (r *redisRepository) presetMapKey(name string) string {
 	return "presetmap:" + name
 }
+
+func (r *redisRepository) presetVersionsKey(name string) string {
+	return "presetversions:" + name
+}
+
+func (r *redisRepository) presetVersionKey(name string, version int) string {
+	return "presetversion:" + name + ":" + strconv.Itoa(version)
+}
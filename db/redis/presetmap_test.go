@@ -43,6 +43,7 @@ func TestCreatePresetMap(t *testing.T) {
 		"pmapping_elastictranscoder":  "1281742-93939",
 		"output_extension":            "ts",
 		"presetmap_name":              "mypreset",
+		"version":                     "1",
 	}
 	if !reflect.DeepEqual(items, expectedItems) {
 		t.Errorf("Wrong presetmap hash returned from Redis. Want %#v. Got %#v", expectedItems, items)
@@ -106,12 +107,109 @@ func TestUpdatePresetMap(t *testing.T) {
 		"pmapping_elastictranscoder": "def123",
 		"output_extension":           "mp4",
 		"presetmap_name":             "mypresetmap",
+		"version":                    "2",
 	}
 	if !reflect.DeepEqual(items, expectedItems) {
 		t.Errorf("Wrong presetmap hash returned from Redis. Want %#v. Got %#v", expectedItems, items)
 	}
 }
 
+func TestPresetVersions(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	presetmap := db.PresetMap{
+		Name:            "mypresetmap",
+		ProviderMapping: map[string]string{"elemental": "abc123"},
+		Author:          "someone",
+	}
+	err = repo.CreatePresetMap(&presetmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstVersion := presetmap
+	presetmap.ProviderMapping = map[string]string{"elemental": "abc1234"}
+	presetmap.Preset = &db.Preset{Name: "mypresetmap", Container: "mp4"}
+	presetmap.Author = "someone else"
+	err = repo.UpdatePresetMap(&presetmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if presetmap.Version != 2 {
+		t.Errorf("Wrong version of the presetmap. Want 2. Got %d", presetmap.Version)
+	}
+	versions, err := repo.ListPresetVersions(presetmap.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Wrong number of versions. Want 2. Got %d", len(versions))
+	}
+	var tests = []struct {
+		version     db.PresetVersion
+		wantMap     db.PresetMap
+		wantAuthor  string
+		wantChanges []db.PresetFieldDiff
+	}{
+		{
+			versions[0],
+			firstVersion,
+			"someone",
+			[]db.PresetFieldDiff{
+				{Field: "name", From: "", To: "mypresetmap"},
+				{Field: "providerMapping.elemental", From: "", To: "abc123"},
+			},
+		},
+		{
+			versions[1],
+			presetmap,
+			"someone else",
+			[]db.PresetFieldDiff{
+				{Field: "providerMapping.elemental", From: "abc123", To: "abc1234"},
+				{Field: "preset.name", From: "", To: "mypresetmap"},
+				{Field: "preset.container", From: "", To: "mp4"},
+			},
+		},
+	}
+	for i, test := range tests {
+		if test.version.Version != i+1 {
+			t.Errorf("Wrong version number. Want %d. Got %d", i+1, test.version.Version)
+		}
+		if !reflect.DeepEqual(test.version.PresetMap, test.wantMap) {
+			t.Errorf("Wrong presetmap in version %d.\nWant %#v\nGot  %#v", i+1, test.wantMap, test.version.PresetMap)
+		}
+		if test.version.Author != test.wantAuthor {
+			t.Errorf("Wrong author in version %d. Want %q. Got %q", i+1, test.wantAuthor, test.version.Author)
+		}
+		if test.version.CreationTime.IsZero() {
+			t.Errorf("Missing creation time in version %d", i+1)
+		}
+		if !reflect.DeepEqual(test.version.Changes, test.wantChanges) {
+			t.Errorf("Wrong changes in version %d.\nWant %#v\nGot  %#v", i+1, test.wantChanges, test.version.Changes)
+		}
+	}
+	err = repo.DeletePresetMap(&presetmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := repo.GetPresetVersion(presetmap.Name, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(version.PresetMap, firstVersion) {
+		t.Errorf("Wrong presetmap in version 1 after deleting the presetmap.\nWant %#v\nGot  %#v", firstVersion, version.PresetMap)
+	}
+	_, err = repo.GetPresetVersion(presetmap.Name, 3)
+	if err != db.ErrPresetVersionNotFound {
+		t.Errorf("Wrong error returned by GetPresetVersion. Want ErrPresetVersionNotFound. Got %#v.", err)
+	}
+}
+
 func TestUpdatePresetMapNotFound(t *testing.T) {
 	err := cleanRedis()
 	if err != nil {
@@ -198,6 +296,40 @@ func TestGetPresetMap(t *testing.T) {
 	}
 }
 
+func TestGetPresetMapWithPreset(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	presetmap := db.PresetMap{
+		Name:            "mypresetmap",
+		ProviderMapping: map[string]string{"elastictranscoder": "0129291-0001"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		Preset: &db.Preset{
+			Name:        "mypresetmap",
+			Container:   "mp4",
+			RateControl: "VBR",
+			Video:       db.VideoPreset{Codec: "h264", Bitrate: "1000000", Height: "720"},
+			Audio:       db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+		},
+	}
+	err = repo.CreatePresetMap(&presetmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotPresetMap, err := repo.GetPresetMap(presetmap.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotPresetMap, presetmap) {
+		t.Errorf("Wrong preset. Want %#v. Got %#v.", presetmap, *gotPresetMap)
+	}
+}
+
 func TestGetPresetMapNotFound(t *testing.T) {
 	err := cleanRedis()
 	if err != nil {
@@ -21,10 +21,30 @@ func cleanRedis() error {
 	if err != nil {
 		return err
 	}
+	err = deleteKeys("presetversion:*", client)
+	if err != nil {
+		return err
+	}
+	err = deleteKeys("presetversions:*", client)
+	if err != nil {
+		return err
+	}
 	err = deleteKeys(localPresetsSetKey, client)
 	if err != nil {
 		return err
 	}
+	err = deleteKeys("notification:*", client)
+	if err != nil {
+		return err
+	}
+	err = deleteKeys("jobnotifications:*", client)
+	if err != nil {
+		return err
+	}
+	err = deleteKeys(schemaVersionKey, client)
+	if err != nil {
+		return err
+	}
 
 	return deleteKeys(jobsSetKey, client)
 }
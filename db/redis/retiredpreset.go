@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+	"gopkg.in/redis.v5"
+)
+
+const retiredPresetsSetKey = "retiredpresets"
+
+func (r *redisRepository) CreateRetiredPreset(retiredPreset *db.RetiredPreset) error {
+	if retiredPreset.ProviderName == "" || retiredPreset.PresetID == "" {
+		return errors.New("provider name and preset id are required")
+	}
+	if retiredPreset.RetirementTime.IsZero() {
+		retiredPreset.RetirementTime = time.Now().UTC()
+	}
+	fields, err := r.storage.FieldMap(retiredPreset)
+	if err != nil {
+		return err
+	}
+	id := retiredPresetID(retiredPreset)
+	retiredPresetKey := r.retiredPresetKey(id)
+	return r.storage.RedisClient().Watch(func(tx *redis.Tx) error {
+		_, err := tx.Pipelined(func(pipe *redis.Pipeline) error {
+			pipe.Del(retiredPresetKey)
+			pipe.HMSet(retiredPresetKey, fields)
+			pipe.SAdd(retiredPresetsSetKey, id)
+			return nil
+		})
+		return err
+	}, retiredPresetKey)
+}
+
+func (r *redisRepository) DeleteRetiredPreset(retiredPreset *db.RetiredPreset) error {
+	id := retiredPresetID(retiredPreset)
+	err := r.storage.Delete(r.retiredPresetKey(id))
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return db.ErrRetiredPresetNotFound
+		}
+		return err
+	}
+	r.storage.RedisClient().SRem(retiredPresetsSetKey, id)
+	return nil
+}
+
+func (r *redisRepository) ListRetiredPresets() ([]db.RetiredPreset, error) {
+	ids, err := r.storage.RedisClient().SMembers(retiredPresetsSetKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	retiredPresets := make([]db.RetiredPreset, 0, len(ids))
+	for _, id := range ids {
+		var retiredPreset db.RetiredPreset
+		err := r.storage.Load(r.retiredPresetKey(id), &retiredPreset)
+		if err == storage.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		retiredPresets = append(retiredPresets, retiredPreset)
+	}
+	return retiredPresets, nil
+}
+
+func retiredPresetID(retiredPreset *db.RetiredPreset) string {
+	return retiredPreset.ProviderName + ":" + retiredPreset.PresetID
+}
+
+func (r *redisRepository) retiredPresetKey(id string) string {
+	return "retiredpreset:" + id
+}
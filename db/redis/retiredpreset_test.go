@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+)
+
+func TestRetiredPresets(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retirementTime := time.Date(2017, 3, 10, 12, 0, 0, 0, time.UTC)
+	retiredPresets := []db.RetiredPreset{
+		{ProviderName: "elastictranscoder", PresetID: "preset-1", PresetMap: "720p", RetirementTime: retirementTime},
+		{ProviderName: "encodingcom", PresetID: "preset-1", PresetMap: "720p", RetirementTime: retirementTime},
+	}
+	for i := range retiredPresets {
+		err = repo.CreateRetiredPreset(&retiredPresets[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	defer client.Close()
+	items, err := client.HGetAll("retiredpreset:elastictranscoder:preset-1").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedItems := map[string]string{
+		"providerName":   "elastictranscoder",
+		"presetID":       "preset-1",
+		"presetmap":      "720p",
+		"retirementTime": "2017-03-10T12:00:00Z",
+	}
+	if !reflect.DeepEqual(items, expectedItems) {
+		t.Errorf("Wrong retired preset hash returned from Redis. Want %#v. Got %#v", expectedItems, items)
+	}
+	got, err := repo.ListRetiredPresets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Sort(retiredPresetList(got))
+	if !reflect.DeepEqual(got, retiredPresets) {
+		t.Errorf("ListRetiredPresets: wrong list returned. Want %#v. Got %#v", retiredPresets, got)
+	}
+	if err = repo.DeleteRetiredPreset(&retiredPresets[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err = repo.DeleteRetiredPreset(&retiredPresets[0]); err != db.ErrRetiredPresetNotFound {
+		t.Errorf("DeleteRetiredPreset: wrong error returned. Want %#v. Got %#v", db.ErrRetiredPresetNotFound, err)
+	}
+	got, err = repo.ListRetiredPresets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, retiredPresets[1:]) {
+		t.Errorf("ListRetiredPresets: wrong list returned after deleting. Want %#v. Got %#v", retiredPresets[1:], got)
+	}
+}
+
+type retiredPresetList []db.RetiredPreset
+
+func (l retiredPresetList) Len() int {
+	return len(l)
+}
+
+func (l retiredPresetList) Less(i, j int) bool {
+	return l[i].ProviderName < l[j].ProviderName
+}
+
+func (l retiredPresetList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
@@ -111,7 +111,7 @@ func (s *Storage) mapToFieldList(hash interface{}, prefixes ...string) (map[stri
 	if !ok {
 		return nil, errors.New("please provide a map[string]string")
 	}
-	if len(m) < 1 {
+	if len(m) < 1 && len(prefixes) == 0 {
 		return nil, errors.New("please provide a map[string]string with at least one item")
 	}
 	fields := make(map[string]string, len(m))
@@ -137,6 +137,9 @@ func (s *Storage) structToFieldList(value reflect.Value, prefixes ...string) (ma
 		fieldValue := value.Field(i)
 		if len(parts) > 1 && parts[len(parts)-1] == "expand" {
 			if fieldValue.Kind() == reflect.Ptr {
+				if fieldValue.IsNil() {
+					continue
+				}
 				fieldValue = fieldValue.Elem()
 			}
 			myPrefixes := append(prefixes, parts[0])
@@ -157,8 +160,16 @@ func (s *Storage) structToFieldList(value reflect.Value, prefixes ...string) (ma
 				for k, v := range expandedFields {
 					fields[k] = v
 				}
+			case reflect.Slice:
+				expandedFields, err := s.sliceToFieldList(fieldValue, myPrefixes...)
+				if err != nil {
+					return nil, err
+				}
+				for k, v := range expandedFields {
+					fields[k] = v
+				}
 			default:
-				return nil, errors.New("can only expand structs and maps")
+				return nil, errors.New("can only expand structs, maps and slices")
 			}
 		} else {
 			if parts[0] != "" {
@@ -167,9 +178,15 @@ func (s *Storage) structToFieldList(value reflect.Value, prefixes ...string) (ma
 				iface := fieldValue.Interface()
 				switch v := iface.(type) {
 				case time.Time:
-					strValue = v.Format(time.RFC3339Nano)
+					if !v.IsZero() || parts[len(parts)-1] != "omitempty" {
+						strValue = v.Format(time.RFC3339Nano)
+					}
 				case []string:
 					strValue = strings.Join(v, "%%%")
+				case bool:
+					if v || parts[len(parts)-1] != "omitempty" {
+						strValue = strconv.FormatBool(v)
+					}
 				default:
 					strValue = fmt.Sprintf("%v", v)
 				}
@@ -183,6 +200,41 @@ func (s *Storage) structToFieldList(value reflect.Value, prefixes ...string) (ma
 	return fields, nil
 }
 
+// sliceToFieldList expands each item of the given slice under its index, and
+// stores the length of the slice in the key named after the prefixes, so
+// empty slices can be told apart from missing ones. Nil slices are omitted.
+func (s *Storage) sliceToFieldList(value reflect.Value, prefixes ...string) (map[string]string, error) {
+	fields := make(map[string]string)
+	if value.IsNil() {
+		return fields, nil
+	}
+	fields[strings.Join(prefixes, "_")] = strconv.Itoa(value.Len())
+	for i := 0; i < value.Len(); i++ {
+		item := value.Index(i)
+		if item.Kind() == reflect.Ptr {
+			item = item.Elem()
+		}
+		itemPrefixes := append(prefixes, strconv.Itoa(i))
+		var itemFields map[string]string
+		var err error
+		switch item.Kind() {
+		case reflect.Struct:
+			itemFields, err = s.structToFieldList(item, itemPrefixes...)
+		case reflect.Map:
+			itemFields, err = s.mapToFieldList(item.Interface(), itemPrefixes...)
+		default:
+			err = errors.New("can only expand slices of structs and maps")
+		}
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range itemFields {
+			fields[k] = v
+		}
+	}
+	return fields, nil
+}
+
 // Load loads the given key in the given output. The output must be a pointer
 // to a struct or a map[string]string.
 func (s *Storage) Load(key string, out interface{}) error {
@@ -221,6 +273,9 @@ func (s *Storage) loadMap(in map[string]string, out reflect.Value, prefixes ...s
 			continue
 		}
 		k = strings.Replace(k, joinedPrefixes, "", 1)
+		if out.IsNil() {
+			out.Set(reflect.MakeMap(out.Type()))
+		}
 		out.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
 	}
 	return nil
@@ -241,6 +296,14 @@ func (s *Storage) loadStruct(in map[string]string, out reflect.Value, prefixes .
 		if len(parts) > 1 && parts[len(parts)-1] == "expand" {
 			myPrefixes := append(prefixes, parts[0])
 			if fieldValue.Kind() == reflect.Ptr {
+				if fieldValue.IsNil() {
+					// nil pointers are only allocated when there's
+					// something stored under their prefix.
+					if !hasPrefix(in, strings.Join(myPrefixes, "_")+"_") {
+						continue
+					}
+					fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+				}
 				fieldValue = fieldValue.Elem()
 			}
 			switch fieldValue.Kind() {
@@ -254,8 +317,13 @@ func (s *Storage) loadStruct(in map[string]string, out reflect.Value, prefixes .
 				if err != nil {
 					return err
 				}
+			case reflect.Slice:
+				err := s.loadSlice(in, fieldValue, myPrefixes...)
+				if err != nil {
+					return err
+				}
 			default:
-				return errors.New("can only expand values to structs or maps")
+				return errors.New("can only expand values to structs, maps or slices")
 			}
 		} else {
 			key := strings.Join(append(prefixes, parts[0]), "_")
@@ -307,6 +375,48 @@ func (s *Storage) loadStruct(in map[string]string, out reflect.Value, prefixes .
 	return nil
 }
 
+func hasPrefix(in map[string]string, prefix string) bool {
+	for key := range in {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Storage) loadSlice(in map[string]string, out reflect.Value, prefixes ...string) error {
+	length, ok := in[strings.Join(prefixes, "_")]
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(length)
+	if err != nil {
+		return err
+	}
+	slice := reflect.MakeSlice(out.Type(), n, n)
+	for i := 0; i < n; i++ {
+		item := slice.Index(i)
+		if item.Kind() == reflect.Ptr {
+			item.Set(reflect.New(item.Type().Elem()))
+			item = item.Elem()
+		}
+		itemPrefixes := append(prefixes, strconv.Itoa(i))
+		switch item.Kind() {
+		case reflect.Struct:
+			err = s.loadStruct(in, item, itemPrefixes...)
+		case reflect.Map:
+			err = s.loadMap(in, item, itemPrefixes...)
+		default:
+			err = errors.New("can only expand values to slices of structs or maps")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	out.Set(slice)
+	return nil
+}
+
 // Delete deletes the given key from redis, returning ErrNotFound when it
 // doesn't exist.
 func (s *Storage) Delete(key string) error {
@@ -203,7 +203,7 @@ func TestSaveErrors(t *testing.T) {
 		{map[string]string{}, "please provide a map[string]string with at least one item"},
 		{struct {
 			Name string `redis-hash:",expand"`
-		}{}, "can only expand structs and maps"},
+		}{}, "can only expand structs, maps and slices"},
 		{struct {
 			Data map[int]int `redis-hash:",expand"`
 		}{}, "please provide a map[string]string"},
@@ -256,19 +256,48 @@ func TestFieldMap(t *testing.T) {
 					PlaylistFileName: "hls/playlist.m3u8",
 				},
 				Outputs: []TranscodeOutput{
-					{Preset: PresetMap{Name: "preset-1"}, FileName: "output1.m3u8"},
+					{Preset: PresetMap{Name: "preset-1", ProviderMapping: map[string]string{"encoding.com": "123"}}, FileName: "output1.m3u8"},
 					{Preset: PresetMap{Name: "preset-2"}, FileName: "output2.m3u8"},
 				},
 			},
 			map[string]string{
-				"source":                           "http://nyt.net/source_here.mp4",
-				"jobID":                            "job1",
-				"providerName":                     "encoding.com",
-				"providerJobID":                    "123abc",
-				"streamingparams_segmentDuration":  "10",
-				"streamingparams_protocol":         "hls",
-				"streamingparams_playlistFileName": "hls/playlist.m3u8",
+				"source":                             "http://nyt.net/source_here.mp4",
+				"jobID":                              "job1",
+				"providerName":                       "encoding.com",
+				"providerJobID":                      "123abc",
+				"streamingparams_segmentDuration":    "10",
+				"streamingparams_protocol":           "hls",
+				"streamingparams_playlistFileName":   "hls/playlist.m3u8",
+				"creationTime":                       "0001-01-01T00:00:00Z",
+				"outputs":                            "2",
+				"outputs_0_presetmap_presetmap_name": "preset-1",
+				"outputs_0_presetmap_pmapping_encoding.com": "123",
+				"outputs_0_filename":                        "output1.m3u8",
+				"outputs_1_presetmap_presetmap_name":        "preset-2",
+				"outputs_1_filename":                        "output2.m3u8",
+			},
+		},
+		{
+			"Job without outputs",
+			Job{ID: "job2", Outputs: []TranscodeOutput{}},
+			map[string]string{
+				"source":                           "",
+				"jobID":                            "job2",
+				"providerName":                     "",
+				"providerJobID":                    "",
+				"streamingparams_segmentDuration":  "0",
+				"streamingparams_protocol":         "",
+				"streamingparams_playlistFileName": "",
 				"creationTime":                     "0001-01-01T00:00:00Z",
+				"outputs":                          "0",
+			},
+		},
+		{
+			"nil pointer",
+			Address{Number: 3},
+			map[string]string{
+				"number": "3",
+				"main":   "false",
 			},
 		},
 		{
@@ -315,6 +344,38 @@ func TestFieldMap(t *testing.T) {
 				"preset_audio_codec":         "aac",
 			},
 		},
+		{
+			"omitempty zero time",
+			struct {
+				Name       string    `redis-hash:"name"`
+				UpdateTime time.Time `redis-hash:"updateTime,omitempty"`
+			}{Name: "Gopher"},
+			map[string]string{"name": "Gopher"},
+		},
+		{
+			"omitempty time",
+			struct {
+				Name       string    `redis-hash:"name"`
+				UpdateTime time.Time `redis-hash:"updateTime,omitempty"`
+			}{Name: "Gopher", UpdateTime: time.Date(2017, 5, 10, 13, 20, 0, 0, time.UTC)},
+			map[string]string{"name": "Gopher", "updateTime": "2017-05-10T13:20:00Z"},
+		},
+		{
+			"omitempty false bool",
+			struct {
+				Name    string `redis-hash:"name"`
+				TwoPass bool   `redis-hash:"twopass,omitempty"`
+			}{Name: "Gopher"},
+			map[string]string{"name": "Gopher"},
+		},
+		{
+			"omitempty bool",
+			struct {
+				Name    string `redis-hash:"name"`
+				TwoPass bool   `redis-hash:"twopass,omitempty"`
+			}{Name: "Gopher", TwoPass: true},
+			map[string]string{"name": "Gopher", "twopass": "true"},
+		},
 	}
 
 	for _, test := range tests {
@@ -373,6 +434,96 @@ func TestLoadStruct(t *testing.T) {
 	}
 }
 
+func TestLoadStructSlice(t *testing.T) {
+	storage, err := NewStorage(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := storage.RedisClient()
+	defer client.Close()
+	job := Job{
+		ID:           "job1",
+		ProviderName: "encoding.com",
+		CreationTime: time.Now().UTC(),
+		Outputs: []TranscodeOutput{
+			{Preset: PresetMap{Name: "preset-1", ProviderMapping: map[string]string{"encoding.com": "123", "zencoder": "abc"}}, FileName: "output1.m3u8"},
+			{Preset: PresetMap{Name: "preset-2"}, FileName: "output2.m3u8"},
+			{Preset: PresetMap{Name: "preset-3", ProviderMapping: map[string]string{"zencoder": "def"}}, FileName: "output3.m3u8"},
+		},
+	}
+	err = storage.Save("test-key", job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Del("test-key")
+	var gotJob Job
+	err = storage.Load("test-key", &gotJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotJob, job) {
+		t.Errorf("Didn't load data to struct. Want %#v. Got %#v.", job, gotJob)
+	}
+}
+
+func TestLoadStructEmptySlice(t *testing.T) {
+	storage, err := NewStorage(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := storage.RedisClient()
+	defer client.Close()
+	err = storage.Save("test-key", map[string]string{"jobID": "job1", "outputs": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Del("test-key")
+	var job Job
+	err = storage.Load("test-key", &job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Outputs == nil || len(job.Outputs) != 0 {
+		t.Errorf("Wrong outputs. Want empty slice. Got %#v", job.Outputs)
+	}
+}
+
+func TestLoadStructNilPointer(t *testing.T) {
+	storage, err := NewStorage(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := storage.RedisClient()
+	defer client.Close()
+	err = storage.Save("test-key", map[string]string{"number": "3", "city_name": "New York"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Del("test-key")
+	err = storage.Save("test-key-2", map[string]string{"number": "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Del("test-key-2")
+	var address Address
+	err = storage.Load("test-key", &address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Address{Number: 3, City: &City{Name: "New York"}}
+	if !reflect.DeepEqual(address, expected) {
+		t.Errorf("Didn't load data to struct. Want %#v. Got %#v.", expected, address)
+	}
+	address = Address{}
+	err = storage.Load("test-key-2", &address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address.City != nil {
+		t.Errorf("Unexpected city. Want <nil>. Got %#v", address.City)
+	}
+}
+
 func TestLoadMap(t *testing.T) {
 	storage, err := NewStorage(&Config{})
 	if err != nil {
@@ -407,7 +558,7 @@ func TestLoadErrors(t *testing.T) {
 		{"dont-know", &Person{}, "not found"},
 		{"test-key", Person{}, "please provide a pointer for getting result from the database"},
 		{"test-key", &n, "please provider a pointer to a struct or a map for getting result from the database"},
-		{"test-key", &InvalidStruct{}, "can only expand values to structs or maps"},
+		{"test-key", &InvalidStruct{}, "can only expand values to structs, maps or slices"},
 		{"test-key", &invalidMap, "please provide a map[string]string"},
 		{"test-key", &InvalidInnerStruct{}, "please provide a map[string]string"},
 	}
@@ -41,7 +41,7 @@ type Job struct {
 	StreamingParams StreamingParams   `redis-hash:"streamingparams,expand"`
 	CreationTime    time.Time         `redis-hash:"creationTime"`
 	SourceMedia     string            `redis-hash:"source"`
-	Outputs         []TranscodeOutput `redis-hash:"-"`
+	Outputs         []TranscodeOutput `redis-hash:"outputs,expand"`
 }
 
 type TranscodeOutput struct {
@@ -50,7 +50,8 @@ type TranscodeOutput struct {
 }
 
 type PresetMap struct {
-	Name string `redis-hash:"presetmap_name"`
+	Name            string            `redis-hash:"presetmap_name"`
+	ProviderMapping map[string]string `redis-hash:"pmapping,expand"`
 }
 
 type StreamingParams struct {
@@ -2,6 +2,7 @@ package db
 
 import (
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -18,6 +19,10 @@ var (
 	// exists.
 	ErrPresetMapAlreadyExists = errors.New("presetmap already exists")
 
+	// ErrPresetVersionNotFound is the error returned when the version of
+	// the presetmap is not found on GetPresetVersion.
+	ErrPresetVersionNotFound = errors.New("preset version not found")
+
 	// ErrLocalPresetNotFound is the error returned when the local preset is not found
 	// on GetPresetMap, UpdatePresetMap or DeletePresetMap.
 	ErrLocalPresetNotFound = errors.New("local preset not found")
@@ -25,6 +30,22 @@ var (
 	// ErrLocalPresetAlreadyExists is the error returned when the local preset already
 	// exists.
 	ErrLocalPresetAlreadyExists = errors.New("local preset already exists")
+
+	// ErrLadderNotFound is the error returned when the ladder is not found on
+	// GetLadder, UpdateLadder or DeleteLadder.
+	ErrLadderNotFound = errors.New("ladder not found")
+
+	// ErrLadderAlreadyExists is the error returned when the ladder already
+	// exists.
+	ErrLadderAlreadyExists = errors.New("ladder already exists")
+
+	// ErrNotificationNotFound is the error returned when the notification is
+	// not found on UpdateNotification and GetNotification.
+	ErrNotificationNotFound = errors.New("notification not found")
+
+	// ErrRetiredPresetNotFound is the error returned when the retired
+	// preset is not found on DeleteRetiredPreset.
+	ErrRetiredPresetNotFound = errors.New("retired preset not found")
 )
 
 // Repository represents the repository for persisting types of the API.
@@ -32,35 +53,151 @@ type Repository interface {
 	JobRepository
 	PresetMapRepository
 	LocalPresetRepository
+	LadderRepository
+	NotificationRepository
+	RetiredPresetRepository
+	LockRepository
 }
 
 // JobRepository is the interface that defines the set of methods for managing Job
 // persistence.
+//
+// UpdateJob applies the given update to the stored version of the job and
+// returns the updated job. The update is atomic: when the job is modified
+// concurrently, it's reloaded and the update is applied again.
+//
+// ExpireActiveJobs removes the jobs created before the given time from the
+// jobs that are not done yet, so they're no longer listed with the Active
+// filter. Expired jobs are listed as active again when they're updated.
 type JobRepository interface {
 	CreateJob(*Job) error
+	UpdateJob(id string, update func(*Job)) (*Job, error)
 	DeleteJob(*Job) error
 	GetJob(id string) (*Job, error)
+	GetJobByProviderJobID(providerName, providerJobID string) (*Job, error)
 	ListJobs(JobFilter) ([]Job, error)
+	ExpireActiveJobs(until time.Time) error
 }
 
 // JobFilter contains a set of parameters for filtering the list of jobs in
 // JobRepository.
+//
+// Jobs are always listed in ascending order of creation time, using the id of
+// the job to break ties.
 type JobFilter struct {
 	// Filter jobs since the given time.
 	Since time.Time
 
+	// Filter jobs until the given time. Zero means no upper bound.
+	Until time.Time
+
+	// Filter jobs that come after the given cursor in the list, used for
+	// paginating results.
+	After JobCursor
+
+	// Filter jobs by the name of the provider.
+	ProviderName string
+
+	// Filter jobs by their id in the provider.
+	ProviderJobID string
+
+	// Filter jobs by their last known status.
+	Status string
+
+	// Filter jobs that are not done yet, see Job.Done.
+	Active bool
+
+	// Filter jobs whose source media starts with the given prefix.
+	SourcePrefix string
+
+	// Filter jobs that have at least one output using the given preset.
+	PresetName string
+
 	// Limit the number of jobs in the result. 0 means no limit.
 	Limit uint
 }
 
+// Match checks whether the given job matches the provider, provider job id,
+// status, active, source and preset filters. It's up to the repository to handle the time range, the
+// cursor and the limit.
+func (f *JobFilter) Match(job *Job) bool {
+	if f.ProviderName != "" && job.ProviderName != f.ProviderName {
+		return false
+	}
+	if f.ProviderJobID != "" && job.ProviderJobID != f.ProviderJobID {
+		return false
+	}
+	if f.Status != "" && job.Status != f.Status {
+		return false
+	}
+	if f.Active && job.Done() {
+		return false
+	}
+	if f.SourcePrefix != "" && !strings.HasPrefix(job.SourceMedia, f.SourcePrefix) {
+		return false
+	}
+	if f.PresetName != "" {
+		for _, output := range job.Outputs {
+			if output.Preset.Name == f.PresetName {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// JobTimePrecision is the precision of the creation time of jobs when sorting
+// them. Repositories may not keep a higher precision in their indexes, like
+// Redis, whose scores are floating-point numbers that only hold the Unix time
+// in microseconds exactly.
+const JobTimePrecision = time.Microsecond
+
+// JobCursor represents a position in the list of jobs. The zero value points
+// to the beginning of the list.
+type JobCursor struct {
+	CreationTime time.Time
+	ID           string
+}
+
+// NewJobCursor returns a cursor pointing to the given job.
+func NewJobCursor(job *Job) JobCursor {
+	return JobCursor{CreationTime: job.CreationTime, ID: job.ID}
+}
+
+// IsZero indicates whether the cursor points to the beginning of the list.
+func (c JobCursor) IsZero() bool {
+	return c.ID == "" && c.CreationTime.IsZero()
+}
+
+// Precedes checks whether the cursor comes before the given job in the list.
+// Jobs are sorted by their creation time, at the precision of JobTimePrecision,
+// and then by their id.
+func (c JobCursor) Precedes(job *Job) bool {
+	if c.IsZero() {
+		return true
+	}
+	cursorTime := c.CreationTime.Truncate(JobTimePrecision)
+	jobTime := job.CreationTime.Truncate(JobTimePrecision)
+	if cursorTime.Before(jobTime) {
+		return true
+	}
+	return cursorTime.Equal(jobTime) && c.ID < job.ID
+}
+
 // PresetMapRepository is the interface that defines the set of methods for
 // managing PresetMap persistence.
+//
+// CreatePresetMap and UpdatePresetMap record a new version of the presetmap,
+// setting its Version. Versions are kept after the presetmap is deleted.
 type PresetMapRepository interface {
 	CreatePresetMap(*PresetMap) error
 	UpdatePresetMap(*PresetMap) error
 	DeletePresetMap(*PresetMap) error
 	GetPresetMap(name string) (*PresetMap, error)
 	ListPresetMaps() ([]PresetMap, error)
+	ListPresetVersions(name string) ([]PresetVersion, error)
+	GetPresetVersion(name string, version int) (*PresetVersion, error)
 }
 
 // LocalPresetRepository provides an interface that defines the set of methods for
@@ -71,3 +208,54 @@ type LocalPresetRepository interface {
 	DeleteLocalPreset(*LocalPreset) error
 	GetLocalPreset(name string) (*LocalPreset, error)
 }
+
+// LadderRepository is the interface that defines the set of methods for
+// managing Ladder persistence.
+type LadderRepository interface {
+	CreateLadder(*Ladder) error
+	UpdateLadder(*Ladder) error
+	DeleteLadder(*Ladder) error
+	GetLadder(name string) (*Ladder, error)
+	ListLadders() ([]Ladder, error)
+}
+
+// NotificationRepository is the interface that defines the set of methods for
+// managing the persistence of notifications sent for jobs.
+//
+// ListPendingNotifications lists the pending notifications whose next
+// delivery attempt is due at the given time, in order of next attempt time.
+type NotificationRepository interface {
+	CreateNotification(*Notification) error
+	UpdateNotification(*Notification) error
+	GetNotification(id string) (*Notification, error)
+	ListNotifications(jobID string) ([]Notification, error)
+	ListPendingNotifications(until time.Time) ([]Notification, error)
+}
+
+// RetiredPresetRepository is the interface that defines the set of methods for
+// managing the persistence of presets replaced in providers, which are
+// deleted once no active job uses them.
+//
+// Retired presets are identified by the provider name and the preset id, so
+// creating a retired preset that's already stored replaces it.
+type RetiredPresetRepository interface {
+	CreateRetiredPreset(*RetiredPreset) error
+	DeleteRetiredPreset(*RetiredPreset) error
+	ListRetiredPresets() ([]RetiredPreset, error)
+}
+
+// LockRepository is the interface that defines the set of methods for
+// managing locks shared by all instances of the API, used for coordinating
+// background work between them.
+//
+// AcquireLock acquires the lock with the given name for the given duration,
+// returning the token of the lock, or an empty string when the lock is held
+// by someone else. Locks are released after the duration even if they're not
+// released explicitly.
+//
+// ReleaseLock releases the lock with the given name, unless it expired and
+// was acquired again with a different token.
+type LockRepository interface {
+	AcquireLock(name string, duration time.Duration) (string, error)
+	ReleaseLock(name, token string) error
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJobFilterMatch(t *testing.T) {
+	job := Job{
+		ID:            "job-1",
+		ProviderName:  "encodingcom",
+		ProviderJobID: "12345",
+		Status:        "started",
+		SourceMedia:   "s3://bucket/videos/video.mov",
+		Outputs: []TranscodeOutput{
+			{Preset: PresetMap{Name: "mp4_720p"}},
+			{Preset: PresetMap{Name: "mp4_1080p"}},
+		},
+	}
+	var tests = []struct {
+		testCase string
+		filter   JobFilter
+		expected bool
+	}{
+		{"empty filter", JobFilter{}, true},
+		{"matching provider", JobFilter{ProviderName: "encodingcom"}, true},
+		{"other provider", JobFilter{ProviderName: "zencoder"}, false},
+		{"matching provider job id", JobFilter{ProviderJobID: "12345"}, true},
+		{"other provider job id", JobFilter{ProviderJobID: "54321"}, false},
+		{"matching status", JobFilter{Status: "started"}, true},
+		{"other status", JobFilter{Status: "finished"}, false},
+		{"matching source prefix", JobFilter{SourcePrefix: "s3://bucket/videos/"}, true},
+		{"other source prefix", JobFilter{SourcePrefix: "s3://other-bucket/"}, false},
+		{"matching preset", JobFilter{PresetName: "mp4_1080p"}, true},
+		{"other preset", JobFilter{PresetName: "hls_1080p"}, false},
+		{"all matching", JobFilter{ProviderName: "encodingcom", Status: "started", PresetName: "mp4_720p"}, true},
+		{"partially matching", JobFilter{ProviderName: "encodingcom", Status: "finished"}, false},
+	}
+	for _, test := range tests {
+		if got := test.filter.Match(&job); got != test.expected {
+			t.Errorf("%s: wrong result. Want %v. Got %v", test.testCase, test.expected, got)
+		}
+	}
+}
+
+func TestJobCursorPrecedes(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Microsecond).Add(500 * time.Nanosecond)
+	cursor := NewJobCursor(&Job{ID: "job-2", CreationTime: now})
+	var tests = []struct {
+		testCase string
+		cursor   JobCursor
+		job      Job
+		expected bool
+	}{
+		{"zero cursor", JobCursor{}, Job{ID: "job-1", CreationTime: now.Add(-time.Hour)}, true},
+		{"later job", cursor, Job{ID: "job-1", CreationTime: now.Add(time.Second)}, true},
+		{"earlier job", cursor, Job{ID: "job-3", CreationTime: now.Add(-time.Second)}, false},
+		{"same time, greater id", cursor, Job{ID: "job-3", CreationTime: now}, true},
+		{"same time, smaller id", cursor, Job{ID: "job-1", CreationTime: now}, false},
+		{"same job", cursor, Job{ID: "job-2", CreationTime: now}, false},
+		{"same microsecond, greater id", cursor, Job{ID: "job-3", CreationTime: now.Add(-time.Nanosecond)}, true},
+		{"same microsecond, smaller id", cursor, Job{ID: "job-1", CreationTime: now.Add(time.Nanosecond)}, false},
+	}
+	for _, test := range tests {
+		if got := test.cursor.Precedes(&test.job); got != test.expected {
+			t.Errorf("%s: wrong result. Want %v. Got %v", test.testCase, test.expected, got)
+		}
+	}
+}
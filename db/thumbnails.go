@@ -0,0 +1,84 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Thumbnails represents the thumbnails generated from the source media of a
+// job, along with its outputs. Thumbnails are taken either at a regular
+// interval or at the given timecodes.
+//
+// swagger:model
+type Thumbnails struct {
+	// interval between thumbnails, in seconds
+	Interval uint `redis-hash:"interval" json:"interval,omitempty"`
+
+	// positions of the thumbnails in the source media, in seconds (e.g.
+	// "12.5"). Mutually exclusive with interval
+	Timecodes []string `redis-hash:"timecodes,omitempty" json:"timecodes,omitempty"`
+
+	// dimensions of the thumbnails, in pixels. When only one of them is
+	// defined, the other one is calculated keeping the aspect ratio of the
+	// source
+	Width  uint `redis-hash:"width" json:"width,omitempty"`
+	Height uint `redis-hash:"height" json:"height,omitempty"`
+
+	// format of the images: jpg or png. Defaults to jpg
+	Format string `redis-hash:"format" json:"format,omitempty"`
+
+	// directory of the thumbnails, relative to the outputs of the job.
+	// Defaults to thumbnails
+	Path string `redis-hash:"path" json:"path,omitempty"`
+
+	// sprite sheet combining the thumbnails, along with a WebVTT track
+	// referencing each thumbnail in the sheet
+	Sprite *ThumbnailSprite `redis-hash:"sprite,expand" json:"sprite,omitempty"`
+}
+
+// ThumbnailSprite represents the layout of the sprite sheets generated from
+// the thumbnails of a job. Thumbnails that don't fit in one sheet are placed
+// in additional sheets.
+//
+// swagger:model
+type ThumbnailSprite struct {
+	// number of thumbnails in each row of the sheet
+	//
+	// required: true
+	Columns uint `redis-hash:"columns" json:"columns"`
+
+	// number of rows of the sheet
+	//
+	// required: true
+	Rows uint `redis-hash:"rows" json:"rows"`
+}
+
+// Validate checks that the thumbnails are properly defined.
+func (t *Thumbnails) Validate() error {
+	if t.Interval == 0 && len(t.Timecodes) == 0 {
+		return errors.New("invalid thumbnails: missing interval or timecodes")
+	}
+	if t.Interval > 0 && len(t.Timecodes) > 0 {
+		return errors.New("invalid thumbnails: interval and timecodes are mutually exclusive")
+	}
+	for _, timecode := range t.Timecodes {
+		if seconds, err := strconv.ParseFloat(timecode, 64); err != nil || seconds < 0 {
+			return fmt.Errorf("invalid thumbnails: invalid timecode %q", timecode)
+		}
+	}
+	switch t.Format {
+	case "", "jpg", "png":
+	default:
+		return fmt.Errorf("invalid thumbnails: invalid format %q: must be jpg or png", t.Format)
+	}
+	if t.Sprite != nil {
+		if t.Interval == 0 {
+			return errors.New("invalid thumbnails: sprite sheets require an interval")
+		}
+		if t.Sprite.Columns == 0 || t.Sprite.Rows == 0 {
+			return errors.New("invalid thumbnails: sprite sheets require columns and rows")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package db
+
+import "testing"
+
+func TestThumbnailsValidate(t *testing.T) {
+	var tests = []struct {
+		testCase   string
+		thumbnails Thumbnails
+		wantErr    string
+	}{
+		{
+			"interval",
+			Thumbnails{Interval: 10, Width: 320, Format: "png"},
+			"",
+		},
+		{
+			"timecodes",
+			Thumbnails{Timecodes: []string{"0", "12.5", "60"}},
+			"",
+		},
+		{
+			"sprite sheet",
+			Thumbnails{Interval: 5, Height: 90, Sprite: &ThumbnailSprite{Columns: 10, Rows: 10}},
+			"",
+		},
+		{
+			"missing interval and timecodes",
+			Thumbnails{Width: 320},
+			"invalid thumbnails: missing interval or timecodes",
+		},
+		{
+			"interval and timecodes",
+			Thumbnails{Interval: 10, Timecodes: []string{"5"}},
+			"invalid thumbnails: interval and timecodes are mutually exclusive",
+		},
+		{
+			"invalid timecode",
+			Thumbnails{Timecodes: []string{"5", "00:01:00"}},
+			`invalid thumbnails: invalid timecode "00:01:00"`,
+		},
+		{
+			"negative timecode",
+			Thumbnails{Timecodes: []string{"-1"}},
+			`invalid thumbnails: invalid timecode "-1"`,
+		},
+		{
+			"invalid format",
+			Thumbnails{Interval: 10, Format: "gif"},
+			`invalid thumbnails: invalid format "gif": must be jpg or png`,
+		},
+		{
+			"sprite sheet with timecodes",
+			Thumbnails{Timecodes: []string{"5"}, Sprite: &ThumbnailSprite{Columns: 10, Rows: 10}},
+			"invalid thumbnails: sprite sheets require an interval",
+		},
+		{
+			"sprite sheet without rows",
+			Thumbnails{Interval: 5, Sprite: &ThumbnailSprite{Columns: 10}},
+			"invalid thumbnails: sprite sheets require columns and rows",
+		},
+	}
+	for _, test := range tests {
+		err := test.thumbnails.Validate()
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", test.testCase, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.wantErr {
+			t.Errorf("%s: wrong error returned. Want %q. Got %v", test.testCase, test.wantErr, err)
+		}
+	}
+}
@@ -26,6 +26,20 @@ type Job struct {
 	// required: true
 	ProviderJobID string `redis-hash:"providerJobID" json:"providerJobId"`
 
+	// last known status of the job in the provider
+	//
+	// required: false
+	Status string `redis-hash:"status,omitempty" json:"status,omitempty"`
+
+	// last known status of the job, as returned by the provider, encoded
+	// in JSON
+	LastStatus string `redis-hash:"lastStatus,omitempty" json:"-"`
+
+	// time of the last refresh of the status of the job
+	//
+	// required: false
+	StatusUpdateTime time.Time `redis-hash:"statusUpdateTime,omitempty" json:"statusUpdateTime,omitempty"`
+
 	// configuration for adaptive streaming jobs
 	// Defaults to false.
 	//
@@ -45,7 +59,147 @@ type Job struct {
 	// Output list of the given job
 	//
 	// required: true
-	Outputs []TranscodeOutput `redis-hash:"-" json:"outputs"`
+	Outputs []TranscodeOutput `redis-hash:"outputs,expand" json:"outputs"`
+
+	// ladder used for choosing the outputs of the job, recording the rungs
+	// that were chosen and dropped
+	//
+	// required: false
+	Ladder *JobLadder `redis-hash:"ladder,expand" json:"ladder,omitempty"`
+
+	// thumbnails generated from the source media
+	//
+	// required: false
+	Thumbnails *Thumbnails `redis-hash:"thumbnails,expand" json:"thumbnails,omitempty"`
+
+	// segment of the source media used by the outputs of the job, instead
+	// of the whole source
+	//
+	// required: false
+	Clip *Clip `redis-hash:"clip,expand" json:"clip,omitempty"`
+
+	// base location of the outputs of the job, overriding the destination
+	// configured in the provider. Outputs are written under
+	// <destination>/<jobId>
+	//
+	// required: false
+	Destination string `redis-hash:"destination,omitempty" json:"destination,omitempty"`
+
+	// URL that receives notifications when the status of the job changes
+	//
+	// required: false
+	CallbackURL string `redis-hash:"callbackURL,omitempty" json:"callbackUrl,omitempty"`
+
+	// secret used for signing the notifications sent to CallbackURL
+	CallbackSecret string `redis-hash:"callbackSecret,omitempty" json:"-"`
+
+	// attempts of submitting the job to providers, in order. The last
+	// attempt is the one made to the provider that accepted the job
+	//
+	// required: false
+	ProviderAttempts []ProviderAttempt `redis-hash:"providerAttempts,expand" json:"providerAttempts,omitempty"`
+}
+
+// ProviderAttempt represents one attempt of submitting a Job to a provider.
+//
+// swagger:model
+type ProviderAttempt struct {
+	// name of the provider
+	ProviderName string `redis-hash:"providerName" json:"providerName"`
+
+	// time of the attempt
+	Time time.Time `redis-hash:"time" json:"time"`
+
+	// error that caused the attempt to fail, empty when the provider
+	// accepted the job
+	Error string `redis-hash:"error,omitempty" json:"error,omitempty"`
+}
+
+// Notification represents a notification sent to the callback URL of a job
+// when its status changes, along with the delivery attempts.
+//
+// swagger:model
+type Notification struct {
+	// id of the notification
+	//
+	// unique: true
+	ID string `redis-hash:"notificationID" json:"id"`
+
+	// id of the job
+	JobID string `redis-hash:"jobID" json:"jobId"`
+
+	// status of the job that triggered the notification
+	Status string `redis-hash:"status" json:"status"`
+
+	// URL that the notification is delivered to
+	CallbackURL string `redis-hash:"callbackURL" json:"callbackUrl"`
+
+	// JSON payload sent in the notification
+	Payload string `redis-hash:"payload" json:"payload"`
+
+	// whether the notification has been successfully delivered
+	Delivered bool `redis-hash:"delivered" json:"delivered"`
+
+	// whether the API gave up on delivering the notification after
+	// exhausting all attempts
+	DeadLetter bool `redis-hash:"deadLetter" json:"deadLetter"`
+
+	// time of the creation of the notification
+	CreationTime time.Time `redis-hash:"creationTime" json:"creationTime"`
+
+	// time of the next delivery attempt, zero when the notification is
+	// due immediately
+	NextAttemptTime time.Time `redis-hash:"nextAttemptTime,omitempty" json:"-"`
+
+	// list of delivery attempts
+	Attempts []NotificationAttempt `redis-hash:"attempts,expand" json:"attempts"`
+}
+
+// Done indicates whether the last known status of the job is terminal
+// (finished, failed or canceled), meaning that it won't change anymore.
+func (j *Job) Done() bool {
+	switch j.Status {
+	case "finished", "failed", "canceled":
+		return true
+	default:
+		return false
+	}
+}
+
+// Pending indicates whether the notification is still to be delivered,
+// meaning that it's neither delivered nor a dead letter.
+func (n *Notification) Pending() bool {
+	return !n.Delivered && !n.DeadLetter
+}
+
+// RetiredPreset represents a preset of a provider that was replaced by a new
+// version of the preset. It's kept in the provider until no active job that
+// may use it is left.
+type RetiredPreset struct {
+	// name of the provider
+	ProviderName string `redis-hash:"providerName" json:"providerName"`
+
+	// id of the preset on the provider
+	PresetID string `redis-hash:"presetID" json:"presetId"`
+
+	// name of the presetmap that used the preset
+	PresetMap string `redis-hash:"presetmap" json:"presetMap"`
+
+	// time the preset was replaced
+	RetirementTime time.Time `redis-hash:"retirementTime" json:"retirementTime"`
+}
+
+// NotificationAttempt represents one attempt of delivering a Notification.
+type NotificationAttempt struct {
+	// time of the attempt
+	Time time.Time `redis-hash:"time" json:"time"`
+
+	// HTTP status code returned by the callback URL, zero when the request
+	// failed before getting a response
+	StatusCode int `redis-hash:"statusCode" json:"statusCode,omitempty"`
+
+	// error that caused the attempt to fail
+	Error string `redis-hash:"error,omitempty" json:"error,omitempty"`
 }
 
 // TranscodeOutput represents a transcoding output. It's a combination of the
@@ -60,6 +214,13 @@ type TranscodeOutput struct {
 	//
 	// required: true
 	FileName string `redis-hash:"filename" json:"filename"`
+
+	// Version of the presetmap used for the output
+	PresetVersion int `redis-hash:"preset_version" json:"presetVersion,omitempty"`
+
+	// segment of the source media used by the output, overriding the clip
+	// of the job
+	Clip *Clip `redis-hash:"clip,expand" json:"clip,omitempty"`
 }
 
 // StreamingParams represents the params necessary to create Adaptive Streaming jobs
@@ -105,6 +266,23 @@ type Preset struct {
 	RateControl string      `json:"rateControl,omitempty" redis-hash:"ratecontrol,omitempty"`
 	Video       VideoPreset `json:"video" redis-hash:"video,expand"`
 	Audio       AudioPreset `json:"audio" redis-hash:"audio,expand"`
+
+	// additional audio renditions, for outputs with more than one audio
+	// track (e.g. one track per language). Audio is always the first
+	// rendition.
+	AudioTracks []AudioPreset `json:"audioTracks,omitempty" redis-hash:"audiotracks,expand"`
+}
+
+// AudioRenditions returns all the audio renditions of the preset, starting
+// with Audio.
+func (p *Preset) AudioRenditions() []AudioPreset {
+	return append([]AudioPreset{p.Audio}, p.AudioTracks...)
+}
+
+// AudioOnly returns true when the preset doesn't define any video
+// parameter, generating outputs that contain only audio.
+func (p *Preset) AudioOnly() bool {
+	return p.Video == VideoPreset{} && p.Audio.Codec != ""
 }
 
 // VideoPreset define the set of parameters for video on a given preset
@@ -118,12 +296,44 @@ type VideoPreset struct {
 	GopSize       string `json:"gopSize,omitempty" redis-hash:"gopsize,omitempty"`
 	GopMode       string `json:"gopMode,omitempty" redis-hash:"gopmode,omitempty"`
 	InterlaceMode string `json:"interlaceMode,omitempty" redis-hash:"interlacemode,omitempty"`
+
+	// codec-specific parameters, mostly relevant for HEVC, VP9 and AV1.
+	// Providers that can't translate one of these parameters fail to
+	// create the preset with a provider.UnsupportedParameterError.
+	Tier            string `json:"tier,omitempty" redis-hash:"tier,omitempty"`
+	BitDepth        string `json:"bitDepth,omitempty" redis-hash:"bitdepth,omitempty"`
+	CRF             string `json:"crf,omitempty" redis-hash:"crf,omitempty"`
+	MaxBitrate      string `json:"maxBitrate,omitempty" redis-hash:"maxbitrate,omitempty"`
+	BufferSize      string `json:"bufferSize,omitempty" redis-hash:"buffersize,omitempty"`
+	BFrames         string `json:"bFrames,omitempty" redis-hash:"bframes,omitempty"`
+	ReferenceFrames string `json:"referenceFrames,omitempty" redis-hash:"referenceframes,omitempty"`
+	TwoPass         bool   `json:"twoPass,omitempty" redis-hash:"twopass,omitempty"`
+
+	// frames per second of the output, like "30" or "29.97", up to 240.
+	// The source frame rate is kept when it's not set. Along with the
+	// GOP size, it defines the keyframe interval, which is limited to 10
+	// seconds. Providers without an equivalent setting reject it with a
+	// provider.UnsupportedParameterError.
+	FrameRate string `json:"frameRate,omitempty" redis-hash:"framerate,omitempty"`
 }
 
 // AudioPreset define the set of parameters for audio on a given preset
 type AudioPreset struct {
 	Codec   string `json:"codec,omitempty" redis-hash:"codec,omitempty"`
 	Bitrate string `json:"bitrate,omitempty" redis-hash:"bitrate,omitempty"`
+
+	// optional parameters of the rendition. Providers that can't
+	// translate one of these parameters fail to create the preset with a
+	// provider.UnsupportedParameterError.
+	//
+	// ChannelLayout is one of mono, stereo, 5.1 or 7.1, SampleRate is in
+	// Hz, Language is an ISO 639 code (like "en" or "spa") and Loudness
+	// is the target of the loudness normalization, in LUFS (like "-23").
+	Channels      string `json:"channels,omitempty" redis-hash:"channels,omitempty"`
+	ChannelLayout string `json:"channelLayout,omitempty" redis-hash:"channellayout,omitempty"`
+	SampleRate    string `json:"sampleRate,omitempty" redis-hash:"samplerate,omitempty"`
+	Language      string `json:"language,omitempty" redis-hash:"language,omitempty"`
+	Loudness      string `json:"loudness,omitempty" redis-hash:"loudness,omitempty"`
 }
 
 // PresetMap represents the preset that is persisted in the repository of the
@@ -149,6 +359,33 @@ type PresetMap struct {
 	//
 	// required: true
 	OutputOpts OutputOptions `redis-hash:"output,expand" json:"output"`
+
+	// the preset submitted when creating the presetmap through the
+	// presets endpoint, as a reference of what was sent to the
+	// providers.
+	Preset *Preset `redis-hash:"preset,expand" json:"preset,omitempty"`
+
+	// current version of the presetmap, incremented by the repository
+	// whenever the presetmap is saved.
+	Version int `redis-hash:"version" json:"version,omitempty"`
+
+	// author of the current version of the presetmap.
+	Author string `redis-hash:"author,omitempty" json:"author,omitempty"`
+}
+
+// PresetVersion is an immutable snapshot of a presetmap, recorded by the
+// repository whenever the presetmap is created or updated.
+//
+// swagger:model
+type PresetVersion struct {
+	Version      int       `redis-hash:"version" json:"version"`
+	PresetMap    PresetMap `redis-hash:"presetmap,expand" json:"presetMap"`
+	Author       string    `redis-hash:"author,omitempty" json:"author,omitempty"`
+	CreationTime time.Time `redis-hash:"creation_time" json:"creationTime"`
+
+	// changes from the previous version of the presetmap, or from an
+	// empty presetmap for the first version.
+	Changes []PresetFieldDiff `redis-hash:"changes,expand" json:"changes,omitempty"`
 }
 
 // OutputOptions is the set of options for the output file.
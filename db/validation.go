@@ -0,0 +1,330 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// well-known codecs, checked against the container of the preset.
+	videoCodecs = []string{"h264", "hevc", "vp8", "vp9", "av1"}
+	audioCodecs = []string{"aac", "mp3", "opus", "vorbis", "libvorbis"}
+
+	// containerVideoCodecs lists the video codecs that can be muxed in
+	// each of the well-known containers. Containers that are not listed
+	// aren't checked.
+	containerVideoCodecs = map[string][]string{
+		"mp4":  {"h264", "hevc", "vp9", "av1"},
+		"m3u8": {"h264", "hevc"},
+		"mpd":  {"h264", "hevc", "vp9", "av1"},
+		"webm": {"vp8", "vp9", "av1"},
+	}
+
+	// containerAudioCodecs lists the audio codecs that can be muxed in
+	// each of the well-known containers.
+	containerAudioCodecs = map[string][]string{
+		"mp4":  {"aac", "mp3", "opus"},
+		"m3u8": {"aac", "mp3"},
+		"mpd":  {"aac", "opus"},
+		"webm": {"vorbis", "libvorbis", "opus"},
+	}
+
+	videoProfiles = map[string][]string{
+		"h264": {"baseline", "main", "high", "high10"},
+		"hevc": {"main", "main10"},
+	}
+
+	videoLevels = map[string][]string{
+		"h264": {"1", "1b", "1.1", "1.2", "1.3", "2", "2.1", "2.2", "3", "3.1", "3.2", "4", "4.1", "4.2", "5", "5.1", "5.2", "6", "6.1", "6.2"},
+		"hevc": {"1", "2", "2.1", "3", "3.1", "4", "4.1", "5", "5.1", "5.2", "6", "6.1", "6.2"},
+	}
+
+	// channelLayouts maps the supported channel layouts to their number
+	// of channels.
+	channelLayouts = map[string]uint64{"mono": 1, "stereo": 2, "5.1": 6, "7.1": 8}
+
+	// maxCRF is the upper bound of the CRF scale of each codec.
+	maxCRF = map[string]uint64{"h264": 51, "hevc": 51, "vp8": 63, "vp9": 63, "av1": 63}
+
+	// maxFrameRate is the highest frame rate accepted in presets.
+	maxFrameRate = 240.0
+
+	// maxKeyframeInterval is the longest time between keyframes accepted
+	// in presets with a frame rate, in seconds. Longer GOPs make seeking
+	// and switching between renditions unusable.
+	maxKeyframeInterval = 10.0
+
+	// reservedPresetNames lists the names of the actions of the /presets
+	// endpoints (e.g.: GET /presets/export), which can't be used as the names
+	// of presets.
+	reservedPresetNames = []string{"export", "import", "validate"}
+)
+
+// PresetFieldError describes a problem with one of the fields of a preset.
+type PresetFieldError struct {
+	// JSON path of the field, like "video.bitrate"
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e PresetFieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// PresetValidationError is returned by Preset.Validate, listing all the
+// problems found in the preset.
+type PresetValidationError []PresetFieldError
+
+func (e PresetValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fieldErr := range e {
+		msgs[i] = fieldErr.Error()
+	}
+	return "invalid preset: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks that the preset is internally consistent: numeric
+// parameters must be numbers, the profile and the level must make sense for
+// the codec, the GOP settings must agree with each other and the codecs must
+// fit in the container. It doesn't check whether providers support the
+// preset.
+//
+// The returned error, if any, is a PresetValidationError.
+func (p *Preset) Validate() error {
+	var v presetValidator
+	if p.Name == "" {
+		v.add("name", "is required")
+	} else if contains(reservedPresetNames, p.Name) {
+		v.add("name", fmt.Sprintf("%q is reserved", p.Name))
+	}
+	if p.Container == "" {
+		v.add("container", "is required")
+	}
+	switch strings.ToUpper(p.RateControl) {
+	case "", "VBR", "CBR":
+	default:
+		v.add("rateControl", fmt.Sprintf("invalid value %q: must be VBR or CBR", p.RateControl))
+	}
+	v.parseUint("video.width", p.Video.Width)
+	v.parseUint("video.height", p.Video.Height)
+	bitrate := v.parseUint("video.bitrate", p.Video.Bitrate)
+	maxBitrate := v.parseUint("video.maxBitrate", p.Video.MaxBitrate)
+	v.parseUint("video.bufferSize", p.Video.BufferSize)
+	if maxBitrate > 0 && bitrate > maxBitrate {
+		v.add("video.maxBitrate", fmt.Sprintf("must not be lower than the bitrate (%d)", bitrate))
+	}
+	p.validateGOP(&v)
+	p.validateProfile(&v)
+	p.validateCodecParameters(&v)
+	p.validateAudio(&v)
+	p.validateContainer(&v)
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+func (p *Preset) validateGOP(v *presetValidator) {
+	gopSize := v.parseUint("video.gopSize", p.Video.GopSize)
+	switch p.Video.GopMode {
+	case "", "variable":
+	case "fixed":
+		if p.Video.GopSize == "" {
+			v.add("video.gopSize", "is required when gopMode is fixed")
+		}
+	default:
+		v.add("video.gopMode", fmt.Sprintf("invalid value %q: must be fixed or variable", p.Video.GopMode))
+	}
+	if p.Video.GopSize != "" && gopSize == 0 {
+		v.add("video.gopSize", "must be greater than zero")
+	}
+	if bFrames, err := strconv.ParseUint(p.Video.BFrames, 10, 64); err == nil && gopSize > 0 && bFrames >= gopSize {
+		v.add("video.bFrames", fmt.Sprintf("must be lower than the GOP size (%d)", gopSize))
+	}
+	if p.Video.FrameRate == "" {
+		return
+	}
+	frameRate, err := strconv.ParseFloat(p.Video.FrameRate, 64)
+	if err != nil || frameRate <= 0 || frameRate > maxFrameRate {
+		v.add("video.frameRate", fmt.Sprintf("invalid value %q: must be a number of frames per second between 0 and %g", p.Video.FrameRate, maxFrameRate))
+		return
+	}
+	// the GOP size is given in frames, so the time between keyframes
+	// depends on the frame rate.
+	if interval := float64(gopSize) / frameRate; interval > maxKeyframeInterval {
+		v.add("video.gopSize", fmt.Sprintf("the keyframe interval of %.2f seconds at %g fps exceeds %g seconds", interval, frameRate, maxKeyframeInterval))
+	}
+}
+
+func (p *Preset) validateProfile(v *presetValidator) {
+	codec := p.Video.Codec
+	profile := strings.ToLower(p.Video.Profile)
+	if profile != "" {
+		if profiles, ok := videoProfiles[codec]; ok && !contains(profiles, profile) {
+			v.add("video.profile", fmt.Sprintf("invalid %s profile %q: must be one of %s", codec, p.Video.Profile, strings.Join(profiles, ", ")))
+			return
+		}
+	}
+	if p.Video.ProfileLevel != "" {
+		if levels, ok := videoLevels[codec]; ok && !contains(levels, strings.ToLower(p.Video.ProfileLevel)) {
+			v.add("video.profileLevel", fmt.Sprintf("invalid %s level %q", codec, p.Video.ProfileLevel))
+		}
+	}
+	tenBit := p.Video.BitDepth == "10"
+	switch {
+	case codec == "h264" && profile == "high10" && p.Video.BitDepth != "" && !tenBit,
+		codec == "hevc" && profile == "main10" && p.Video.BitDepth != "" && !tenBit:
+		v.add("video.bitDepth", fmt.Sprintf("profile %q requires a bit depth of 10", p.Video.Profile))
+	case codec == "h264" && tenBit && profile != "" && profile != "high10",
+		codec == "hevc" && tenBit && profile != "" && profile != "main10":
+		v.add("video.profile", fmt.Sprintf("profile %q doesn't support a bit depth of 10", p.Video.Profile))
+	}
+	if codec == "h264" && profile == "baseline" && p.Video.BFrames != "" && p.Video.BFrames != "0" {
+		v.add("video.bFrames", "the baseline profile doesn't support B-frames")
+	}
+}
+
+func (p *Preset) validateCodecParameters(v *presetValidator) {
+	codec := p.Video.Codec
+	v.parseUint("video.referenceFrames", p.Video.ReferenceFrames)
+	v.parseUint("video.bFrames", p.Video.BFrames)
+	switch strings.ToLower(p.Video.Tier) {
+	case "":
+	case "main", "high":
+		if codec != "hevc" {
+			v.add("video.tier", "is only supported by hevc")
+		} else if strings.ToLower(p.Video.Tier) == "high" && p.Video.ProfileLevel != "" {
+			if level, err := strconv.ParseFloat(p.Video.ProfileLevel, 64); err == nil && level < 4 {
+				v.add("video.tier", "the high tier requires level 4 or higher")
+			}
+		}
+	default:
+		v.add("video.tier", fmt.Sprintf("invalid value %q: must be main or high", p.Video.Tier))
+	}
+	switch p.Video.BitDepth {
+	case "", "8":
+	case "10":
+		if codec == "vp8" {
+			v.add("video.bitDepth", "vp8 only supports a bit depth of 8")
+		}
+	default:
+		v.add("video.bitDepth", fmt.Sprintf("invalid value %q: must be 8 or 10", p.Video.BitDepth))
+	}
+	if p.Video.CRF != "" {
+		crf, err := strconv.ParseUint(p.Video.CRF, 10, 64)
+		if err != nil {
+			v.add("video.crf", fmt.Sprintf("invalid value %q: must be a non-negative integer", p.Video.CRF))
+		} else if max, ok := maxCRF[codec]; ok && crf > max {
+			v.add("video.crf", fmt.Sprintf("must be between 0 and %d for %s", max, codec))
+		}
+		if strings.ToUpper(p.RateControl) == "CBR" {
+			v.add("video.crf", "can't be used with CBR rate control")
+		}
+	}
+}
+
+func (p *Preset) validateAudio(v *presetValidator) {
+	for i, audio := range p.AudioRenditions() {
+		field := audioField(i)
+		if i > 0 && audio.Codec == "" {
+			v.add(field+".codec", "is required")
+		}
+		v.parseUint(field+".bitrate", audio.Bitrate)
+		v.parseUint(field+".sampleRate", audio.SampleRate)
+		channels := v.parseUint(field+".channels", audio.Channels)
+		if audio.Channels != "" && channels == 0 {
+			v.add(field+".channels", "must be greater than zero")
+		}
+		if audio.ChannelLayout != "" {
+			layoutChannels, ok := channelLayouts[audio.ChannelLayout]
+			if !ok {
+				v.add(field+".channelLayout", fmt.Sprintf("invalid value %q: must be mono, stereo, 5.1 or 7.1", audio.ChannelLayout))
+			} else if channels > 0 && channels != layoutChannels {
+				v.add(field+".channelLayout", fmt.Sprintf("%s requires %d channels", audio.ChannelLayout, layoutChannels))
+			}
+		}
+		if audio.Language != "" && !isLanguageCode(audio.Language) {
+			v.add(field+".language", fmt.Sprintf("invalid value %q: must be an ISO 639 code", audio.Language))
+		}
+		if audio.Loudness != "" {
+			loudness, err := strconv.ParseFloat(audio.Loudness, 64)
+			if err != nil || loudness < -70 || loudness > 0 {
+				v.add(field+".loudness", fmt.Sprintf("invalid value %q: must be between -70 and 0 LUFS", audio.Loudness))
+			}
+		}
+	}
+}
+
+func (p *Preset) validateContainer(v *presetValidator) {
+	if codecs, ok := containerVideoCodecs[p.Container]; ok && contains(videoCodecs, p.Video.Codec) && !contains(codecs, p.Video.Codec) {
+		v.add("video.codec", fmt.Sprintf("%s can't be used in %s outputs", p.Video.Codec, p.Container))
+	}
+	codecs, ok := containerAudioCodecs[p.Container]
+	if !ok {
+		return
+	}
+	for i, audio := range p.AudioRenditions() {
+		if contains(audioCodecs, audio.Codec) && !contains(codecs, audio.Codec) {
+			v.add(audioField(i)+".codec", fmt.Sprintf("%s can't be used in %s outputs", audio.Codec, p.Container))
+		}
+	}
+}
+
+// audioField returns the JSON path of the i-th audio rendition of a preset,
+// as returned by AudioRenditions.
+func audioField(i int) string {
+	if i == 0 {
+		return "audio"
+	}
+	return fmt.Sprintf("audioTracks[%d]", i-1)
+}
+
+// isLanguageCode reports whether the given value looks like an ISO 639-1 or
+// ISO 639-2 language code.
+func isLanguageCode(value string) bool {
+	if len(value) < 2 || len(value) > 3 {
+		return false
+	}
+	for _, r := range value {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// presetValidator accumulates the problems found in a preset.
+type presetValidator struct {
+	errs PresetValidationError
+}
+
+func (v *presetValidator) add(field, message string) {
+	v.errs = append(v.errs, PresetFieldError{Field: field, Message: message})
+}
+
+// parseUint parses the given numeric field, recording an error if it's not
+// a non-negative integer. It returns zero for empty and invalid values.
+func (v *presetValidator) parseUint(field, value string) uint64 {
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		v.add(field, fmt.Sprintf("invalid value %q: must be a non-negative integer", value))
+		return 0
+	}
+	return n
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,245 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPresetValidate(t *testing.T) {
+	validPreset := Preset{
+		Name:        "mp4_1080p",
+		Container:   "mp4",
+		RateControl: "VBR",
+		Video: VideoPreset{
+			Profile:      "Main",
+			ProfileLevel: "3.1",
+			Width:        "1920",
+			Height:       "1080",
+			Codec:        "h264",
+			Bitrate:      "3500000",
+			GopSize:      "90",
+			GopMode:      "fixed",
+		},
+		Audio: AudioPreset{Codec: "aac", Bitrate: "128000"},
+	}
+	var tests = []struct {
+		testCase string
+		change   func(*Preset)
+		errs     PresetValidationError
+	}{
+		{
+			"valid preset",
+			func(*Preset) {},
+			nil,
+		},
+		{
+			"valid hevc preset",
+			func(p *Preset) {
+				p.Video = VideoPreset{Codec: "hevc", Profile: "main10", ProfileLevel: "5.1", Tier: "high", BitDepth: "10", CRF: "24", BFrames: "4"}
+			},
+			nil,
+		},
+		{
+			"missing name and container",
+			func(p *Preset) {
+				p.Name = ""
+				p.Container = ""
+			},
+			PresetValidationError{
+				{Field: "name", Message: "is required"},
+				{Field: "container", Message: "is required"},
+			},
+		},
+		{
+			"reserved name",
+			func(p *Preset) { p.Name = "export" },
+			PresetValidationError{{Field: "name", Message: `"export" is reserved`}},
+		},
+		{
+			"non-numeric parameters",
+			func(p *Preset) {
+				p.Video.Bitrate = "3.5m"
+				p.Video.Width = "auto"
+				p.Audio.Bitrate = "128k"
+			},
+			PresetValidationError{
+				{Field: "video.width", Message: `invalid value "auto": must be a non-negative integer`},
+				{Field: "video.bitrate", Message: `invalid value "3.5m": must be a non-negative integer`},
+				{Field: "audio.bitrate", Message: `invalid value "128k": must be a non-negative integer`},
+			},
+		},
+		{
+			"invalid rate control",
+			func(p *Preset) { p.RateControl = "ABR" },
+			PresetValidationError{{Field: "rateControl", Message: `invalid value "ABR": must be VBR or CBR`}},
+		},
+		{
+			"max bitrate lower than the bitrate",
+			func(p *Preset) { p.Video.MaxBitrate = "3000000" },
+			PresetValidationError{{Field: "video.maxBitrate", Message: "must not be lower than the bitrate (3500000)"}},
+		},
+		{
+			"fixed GOP without size",
+			func(p *Preset) { p.Video.GopSize = "" },
+			PresetValidationError{{Field: "video.gopSize", Message: "is required when gopMode is fixed"}},
+		},
+		{
+			"B-frames longer than the GOP",
+			func(p *Preset) { p.Video.BFrames = "90" },
+			PresetValidationError{{Field: "video.bFrames", Message: "must be lower than the GOP size (90)"}},
+		},
+		{
+			"valid frame rate",
+			func(p *Preset) { p.Video.FrameRate = "29.97" },
+			nil,
+		},
+		{
+			"keyframe interval too long for the frame rate",
+			func(p *Preset) { p.Video.FrameRate = "6" },
+			PresetValidationError{{Field: "video.gopSize", Message: "the keyframe interval of 15.00 seconds at 6 fps exceeds 10 seconds"}},
+		},
+		{
+			"invalid frame rate",
+			func(p *Preset) { p.Video.FrameRate = "0" },
+			PresetValidationError{{Field: "video.frameRate", Message: `invalid value "0": must be a number of frames per second between 0 and 240`}},
+		},
+		{
+			"invalid profile",
+			func(p *Preset) { p.Video.Profile = "main10" },
+			PresetValidationError{{Field: "video.profile", Message: `invalid h264 profile "main10": must be one of baseline, main, high, high10`}},
+		},
+		{
+			"invalid level",
+			func(p *Preset) { p.Video.ProfileLevel = "7" },
+			PresetValidationError{{Field: "video.profileLevel", Message: `invalid h264 level "7"`}},
+		},
+		{
+			"baseline profile with B-frames",
+			func(p *Preset) {
+				p.Video.Profile = "baseline"
+				p.Video.BFrames = "2"
+			},
+			PresetValidationError{{Field: "video.bFrames", Message: "the baseline profile doesn't support B-frames"}},
+		},
+		{
+			"10-bit depth in 8-bit profile",
+			func(p *Preset) { p.Video.BitDepth = "10" },
+			PresetValidationError{{Field: "video.profile", Message: `profile "Main" doesn't support a bit depth of 10`}},
+		},
+		{
+			"high tier in low level",
+			func(p *Preset) {
+				p.Video.Codec = "hevc"
+				p.Video.Tier = "high"
+			},
+			PresetValidationError{{Field: "video.tier", Message: "the high tier requires level 4 or higher"}},
+		},
+		{
+			"tier in h264",
+			func(p *Preset) { p.Video.Tier = "main" },
+			PresetValidationError{{Field: "video.tier", Message: "is only supported by hevc"}},
+		},
+		{
+			"CRF out of range with CBR",
+			func(p *Preset) {
+				p.RateControl = "CBR"
+				p.Video.CRF = "60"
+			},
+			PresetValidationError{
+				{Field: "video.crf", Message: "must be between 0 and 51 for h264"},
+				{Field: "video.crf", Message: "can't be used with CBR rate control"},
+			},
+		},
+		{
+			"codecs not supported by the container",
+			func(p *Preset) {
+				p.Container = "webm"
+				p.Video.Profile = ""
+				p.Video.ProfileLevel = ""
+			},
+			PresetValidationError{
+				{Field: "video.codec", Message: "h264 can't be used in webm outputs"},
+				{Field: "audio.codec", Message: "aac can't be used in webm outputs"},
+			},
+		},
+		{
+			"valid audio renditions",
+			func(p *Preset) {
+				p.Audio = AudioPreset{Codec: "aac", Bitrate: "128000", Channels: "2", ChannelLayout: "stereo", SampleRate: "48000", Language: "en", Loudness: "-23"}
+				p.AudioTracks = []AudioPreset{{Codec: "aac", Bitrate: "384000", ChannelLayout: "5.1", Language: "spa", Loudness: "-24.5"}}
+			},
+			nil,
+		},
+		{
+			"valid audio-only preset",
+			func(p *Preset) {
+				p.RateControl = ""
+				p.Video = VideoPreset{}
+			},
+			nil,
+		},
+		{
+			"invalid audio parameters",
+			func(p *Preset) {
+				p.Audio.Channels = "0"
+				p.Audio.SampleRate = "44.1k"
+				p.AudioTracks = []AudioPreset{
+					{Codec: "aac", Channels: "2", ChannelLayout: "5.1", Language: "english", Loudness: "-80"},
+					{ChannelLayout: "quad", Loudness: "loud"},
+				}
+			},
+			PresetValidationError{
+				{Field: "audio.sampleRate", Message: `invalid value "44.1k": must be a non-negative integer`},
+				{Field: "audio.channels", Message: "must be greater than zero"},
+				{Field: "audioTracks[0].channelLayout", Message: "5.1 requires 6 channels"},
+				{Field: "audioTracks[0].language", Message: `invalid value "english": must be an ISO 639 code`},
+				{Field: "audioTracks[0].loudness", Message: `invalid value "-80": must be between -70 and 0 LUFS`},
+				{Field: "audioTracks[1].codec", Message: "is required"},
+				{Field: "audioTracks[1].channelLayout", Message: `invalid value "quad": must be mono, stereo, 5.1 or 7.1`},
+				{Field: "audioTracks[1].loudness", Message: `invalid value "loud": must be between -70 and 0 LUFS`},
+			},
+		},
+		{
+			"audio track not supported by the container",
+			func(p *Preset) {
+				p.Container = "m3u8"
+				p.AudioTracks = []AudioPreset{{Codec: "opus"}}
+			},
+			PresetValidationError{{Field: "audioTracks[0].codec", Message: "opus can't be used in m3u8 outputs"}},
+		},
+		{
+			"unknown container and codecs",
+			func(p *Preset) {
+				p.Container = "mov"
+				p.Video = VideoPreset{Codec: "prores"}
+				p.Audio = AudioPreset{Codec: "pcm"}
+			},
+			nil,
+		},
+	}
+	for _, test := range tests {
+		preset := validPreset
+		test.change(&preset)
+		err := preset.Validate()
+		if test.errs == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", test.testCase, err)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(err, test.errs) {
+			t.Errorf("%s: wrong error returned\nWant %#v\nGot  %#v", test.testCase, test.errs, err)
+		}
+	}
+}
+
+func TestPresetValidationErrorMessage(t *testing.T) {
+	err := PresetValidationError{
+		{Field: "name", Message: "is required"},
+		{Field: "video.bitrate", Message: `invalid value "3.5m": must be a non-negative integer`},
+	}
+	expected := `invalid preset: name: is required; video.bitrate: invalid value "3.5m": must be a non-negative integer`
+	if err.Error() != expected {
+		t.Errorf("wrong error message\nWant %q\nGot  %q", expected, err.Error())
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"github.com/Gurpartap/logrus-stack"
 	"github.com/NYTimes/gizmo/server"
 	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db/redis"
 	_ "github.com/NYTimes/video-transcoding-api/provider/bitmovin"
 	_ "github.com/NYTimes/video-transcoding-api/provider/elastictranscoder"
 	_ "github.com/NYTimes/video-transcoding-api/provider/elementalconductor"
 	_ "github.com/NYTimes/video-transcoding-api/provider/encodingcom"
+	_ "github.com/NYTimes/video-transcoding-api/provider/fakeprovider"
+	_ "github.com/NYTimes/video-transcoding-api/provider/ffmpeg"
+	_ "github.com/NYTimes/video-transcoding-api/provider/gcptranscoder"
+	_ "github.com/NYTimes/video-transcoding-api/provider/mediaconvert"
 	_ "github.com/NYTimes/video-transcoding-api/provider/zencoder"
 	"github.com/NYTimes/video-transcoding-api/service"
 	"github.com/google/gops/agent"
@@ -35,15 +40,35 @@ func main() {
 	} else {
 		server.Log.Hooks.Add(gcpLoggingHook)
 	}
+	err = redis.Migrate(cfg)
+	if err != nil {
+		server.Log.Fatal("unable to migrate the database: ", err)
+	}
 	service, err := service.NewTranscodingService(cfg, server.Log)
 	if err != nil {
 		server.Log.Fatal("unable to initialize service: ", err)
 	}
+	err = service.StartStatusPoller()
+	if err != nil {
+		server.Log.Fatal("unable to start the status poller: ", err)
+	}
+	err = service.StartPresetReconciler()
+	if err != nil {
+		server.Log.Fatal("unable to start the preset reconciler: ", err)
+	}
+	service.StartPresetCleaner()
+	service.StartNotifier()
+	service.StartHealthMonitor()
 	err = server.Register(service)
 	if err != nil {
 		server.Log.Fatal("unable to register service: ", err)
 	}
 	err = server.Run()
+	service.StopStatusPoller()
+	service.StopPresetReconciler()
+	service.StopPresetCleaner()
+	service.StopNotifier()
+	service.StopHealthMonitor()
 	if err != nil {
 		server.Log.Fatal("server encountered a fatal error: ", err)
 	}
@@ -0,0 +1,255 @@
+package probe
+
+import "errors"
+
+var errTruncatedSPS = errors.New("truncated SPS")
+
+// bitReader reads the bits of H.264 NAL units, including exponential-Golomb
+// coded values.
+type bitReader struct {
+	data []byte
+	pos  uint
+}
+
+func (r *bitReader) bit() (uint, error) {
+	if r.pos >= uint(len(r.data))*8 {
+		return 0, errTruncatedSPS
+	}
+	b := uint(r.data[r.pos/8]>>(7-r.pos%8)) & 1
+	r.pos++
+	return b, nil
+}
+
+func (r *bitReader) bits(n uint) (uint, error) {
+	var value uint
+	for i := uint(0); i < n; i++ {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		value = value<<1 | b
+	}
+	return value, nil
+}
+
+// ue reads an unsigned exponential-Golomb coded value.
+func (r *bitReader) ue() (uint, error) {
+	var zeros uint
+	for {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		if b == 1 {
+			break
+		}
+		zeros++
+		if zeros > 31 {
+			return 0, errors.New("invalid exponential-Golomb code")
+		}
+	}
+	value, err := r.bits(zeros)
+	return 1<<zeros - 1 + value, err
+}
+
+// se reads a signed exponential-Golomb coded value.
+func (r *bitReader) se() (int, error) {
+	value, err := r.ue()
+	if value%2 == 0 {
+		return -int(value / 2), err
+	}
+	return int(value+1) / 2, err
+}
+
+// unescapeRBSP removes the emulation prevention bytes of a NAL unit.
+func unescapeRBSP(data []byte) []byte {
+	rbsp := make([]byte, 0, len(data))
+	var zeros int
+	for _, b := range data {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		rbsp = append(rbsp, b)
+	}
+	return rbsp
+}
+
+// parseSPS returns the dimensions of the pictures described by the given H.264
+// sequence parameter set, excluding the NAL unit header.
+func parseSPS(data []byte) (width, height int64, err error) {
+	r := bitReader{data: unescapeRBSP(data)}
+	profile, err := r.bits(8)
+	if err != nil {
+		return 0, 0, err
+	}
+	// constraint flags, level and id of the SPS
+	if _, err = r.bits(16); err != nil {
+		return 0, 0, err
+	}
+	if _, err = r.ue(); err != nil {
+		return 0, 0, err
+	}
+	chromaFormat := uint(1)
+	var separateColourPlanes uint
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		if chromaFormat, err = r.ue(); err != nil {
+			return 0, 0, err
+		}
+		if chromaFormat == 3 {
+			if separateColourPlanes, err = r.bit(); err != nil {
+				return 0, 0, err
+			}
+		}
+		// bit depths of luma and chroma
+		for i := 0; i < 2; i++ {
+			if _, err = r.ue(); err != nil {
+				return 0, 0, err
+			}
+		}
+		// qpprime_y_zero_transform_bypass_flag
+		if _, err = r.bit(); err != nil {
+			return 0, 0, err
+		}
+		if err = skipScalingMatrix(&r, chromaFormat); err != nil {
+			return 0, 0, err
+		}
+	}
+	// log2_max_frame_num_minus4
+	if _, err = r.ue(); err != nil {
+		return 0, 0, err
+	}
+	if err = skipPicOrderCount(&r); err != nil {
+		return 0, 0, err
+	}
+	// max_num_ref_frames and gaps_in_frame_num_value_allowed_flag
+	if _, err = r.ue(); err != nil {
+		return 0, 0, err
+	}
+	if _, err = r.bit(); err != nil {
+		return 0, 0, err
+	}
+	widthInMbs, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	heightInMapUnits, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	frameMbsOnly, err := r.bit()
+	if err != nil {
+		return 0, 0, err
+	}
+	if frameMbsOnly == 0 {
+		// mb_adaptive_frame_field_flag
+		if _, err = r.bit(); err != nil {
+			return 0, 0, err
+		}
+	}
+	// direct_8x8_inference_flag
+	if _, err = r.bit(); err != nil {
+		return 0, 0, err
+	}
+	var crop [4]uint
+	cropping, err := r.bit()
+	if err != nil {
+		return 0, 0, err
+	}
+	if cropping == 1 {
+		for i := range crop {
+			if crop[i], err = r.ue(); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	cropUnitX, cropUnitY := uint(1), 2-frameMbsOnly
+	if separateColourPlanes == 0 {
+		switch chromaFormat {
+		case 1:
+			cropUnitX, cropUnitY = 2, 2*(2-frameMbsOnly)
+		case 2:
+			cropUnitX = 2
+		}
+	}
+	width = int64((widthInMbs+1)*16 - cropUnitX*(crop[0]+crop[1]))
+	height = int64((2-frameMbsOnly)*(heightInMapUnits+1)*16 - cropUnitY*(crop[2]+crop[3]))
+	return width, height, nil
+}
+
+func skipScalingMatrix(r *bitReader, chromaFormat uint) error {
+	present, err := r.bit()
+	if err != nil || present == 0 {
+		return err
+	}
+	lists := 8
+	if chromaFormat == 3 {
+		lists = 12
+	}
+	for i := 0; i < lists; i++ {
+		listPresent, err := r.bit()
+		if err != nil {
+			return err
+		}
+		if listPresent == 0 {
+			continue
+		}
+		size := 16
+		if i >= 6 {
+			size = 64
+		}
+		last, next := 8, 8
+		for j := 0; j < size; j++ {
+			if next != 0 {
+				delta, err := r.se()
+				if err != nil {
+					return err
+				}
+				next = (last + delta + 256) % 256
+			}
+			if next != 0 {
+				last = next
+			}
+		}
+	}
+	return nil
+}
+
+func skipPicOrderCount(r *bitReader) error {
+	picOrderCountType, err := r.ue()
+	if err != nil {
+		return err
+	}
+	switch picOrderCountType {
+	case 0:
+		_, err = r.ue()
+		return err
+	case 1:
+		// delta_pic_order_always_zero_flag, offset_for_non_ref_pic
+		// and offset_for_top_to_bottom_field
+		if _, err = r.bit(); err != nil {
+			return err
+		}
+		for i := 0; i < 2; i++ {
+			if _, err = r.se(); err != nil {
+				return err
+			}
+		}
+		frames, err := r.ue()
+		if err != nil {
+			return err
+		}
+		for i := uint(0); i < frames; i++ {
+			if _, err = r.se(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package probe
+
+import "testing"
+
+// bitWriter writes the bits of synthetic H.264 NAL units.
+type bitWriter struct {
+	data []byte
+	pos  uint
+}
+
+func (w *bitWriter) bits(value uint, n uint) {
+	for i := n; i > 0; i-- {
+		if w.pos%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte((value>>(i-1))&1) << (7 - w.pos%8)
+		w.pos++
+	}
+}
+
+func (w *bitWriter) ue(value uint) {
+	var n uint
+	for v := value + 1; v > 1; v >>= 1 {
+		n++
+	}
+	w.bits(0, n)
+	w.bits(value+1, n+1)
+}
+
+func (w *bitWriter) se(value int) {
+	if value > 0 {
+		w.ue(uint(2*value - 1))
+	} else {
+		w.ue(uint(-2 * value))
+	}
+}
+
+type spsParams struct {
+	profile      uint
+	chromaFormat uint
+	scaling      bool
+	pocType      uint
+	widthInMbs   uint
+	heightInMbs  uint
+	frameMbsOnly bool
+	crop         [4]uint
+}
+
+func buildSPS(p spsParams) []byte {
+	var w bitWriter
+	w.bits(p.profile, 8)
+	w.bits(0, 8)
+	w.bits(40, 8)
+	w.ue(0)
+	if p.profile == 100 {
+		w.ue(p.chromaFormat)
+		w.ue(0)
+		w.ue(0)
+		w.bits(0, 1)
+		if p.scaling {
+			w.bits(1, 1)
+			w.bits(1, 1)
+			for i := 0; i < 16; i++ {
+				w.se(1)
+			}
+			w.bits(0, 7)
+		} else {
+			w.bits(0, 1)
+		}
+	}
+	w.ue(0)
+	w.ue(p.pocType)
+	switch p.pocType {
+	case 0:
+		w.ue(2)
+	case 1:
+		w.bits(0, 1)
+		w.se(-1)
+		w.se(2)
+		w.ue(2)
+		w.se(1)
+		w.se(-1)
+	}
+	w.ue(1)
+	w.bits(0, 1)
+	w.ue(p.widthInMbs - 1)
+	w.ue(p.heightInMbs - 1)
+	if p.frameMbsOnly {
+		w.bits(1, 1)
+	} else {
+		w.bits(0, 2)
+	}
+	w.bits(1, 1)
+	if p.crop == [4]uint{} {
+		w.bits(0, 1)
+	} else {
+		w.bits(1, 1)
+		for _, c := range p.crop {
+			w.ue(c)
+		}
+	}
+	w.bits(0, 1)
+	w.bits(1, 1)
+	return w.data
+}
+
+func TestParseSPS(t *testing.T) {
+	var tests = []struct {
+		name       string
+		params     spsParams
+		wantWidth  int64
+		wantHeight int64
+	}{
+		{
+			"baseline 1080p",
+			spsParams{profile: 66, widthInMbs: 120, heightInMbs: 68, frameMbsOnly: true, crop: [4]uint{0, 0, 0, 4}},
+			1920, 1080,
+		},
+		{
+			"high 720p",
+			spsParams{profile: 100, chromaFormat: 1, pocType: 2, widthInMbs: 80, heightInMbs: 45, frameMbsOnly: true},
+			1280, 720,
+		},
+		{
+			"high with scaling matrix",
+			spsParams{profile: 100, chromaFormat: 1, scaling: true, pocType: 1, widthInMbs: 40, heightInMbs: 23, frameMbsOnly: true, crop: [4]uint{0, 0, 0, 4}},
+			640, 360,
+		},
+		{
+			"interlaced 1080i",
+			spsParams{profile: 100, chromaFormat: 1, widthInMbs: 120, heightInMbs: 34, crop: [4]uint{0, 0, 0, 2}},
+			1920, 1080,
+		},
+	}
+	for _, test := range tests {
+		width, height, err := parseSPS(buildSPS(test.params))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if width != test.wantWidth || height != test.wantHeight {
+			t.Errorf("%s: wrong dimensions. Want %dx%d. Got %dx%d", test.name, test.wantWidth, test.wantHeight, width, height)
+		}
+	}
+}
+
+func TestParseSPSTruncated(t *testing.T) {
+	sps := buildSPS(spsParams{profile: 66, widthInMbs: 120, heightInMbs: 68, frameMbsOnly: true})
+	_, _, err := parseSPS(sps[:4])
+	if err != errTruncatedSPS {
+		t.Errorf("wrong error returned. Want %#v. Got %#v", errTruncatedSPS, err)
+	}
+}
+
+func TestUnescapeRBSP(t *testing.T) {
+	got := unescapeRBSP([]byte{0x42, 0, 0, 3, 1, 0, 0, 3, 0, 3})
+	want := []byte{0x42, 0, 0, 1, 0, 0, 0, 3}
+	if string(got) != string(want) {
+		t.Errorf("wrong RBSP. Want %#v. Got %#v", want, got)
+	}
+}
@@ -0,0 +1,308 @@
+package probe
+
+import (
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+// maxMoovSize is the maximum size of the moov box, which is read entirely.
+const maxMoovSize = 64 << 20
+
+// topLevelBoxes lists the types of boxes that may start ISO base media files.
+var topLevelBoxes = map[string]bool{
+	"ftyp": true,
+	"moov": true,
+	"mdat": true,
+	"free": true,
+	"skip": true,
+	"wide": true,
+	"pnot": true,
+}
+
+// sampleEntryCodecs maps the types of sample entries to the names of the
+// codecs.
+var sampleEntryCodecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"av01": "av1",
+	"vp08": "vp8",
+	"vp09": "vp9",
+	"mp4v": "mpeg4",
+	"apch": "prores",
+	"apcn": "prores",
+	"apcs": "prores",
+	"apco": "prores",
+	"ap4h": "prores",
+	"ap4x": "prores",
+	"mp4a": "aac",
+	".mp3": "mp3",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	"Opus": "opus",
+	"fLaC": "flac",
+	"lpcm": "pcm",
+	"sowt": "pcm",
+	"twos": "pcm",
+	"in24": "pcm",
+}
+
+func isMP4(header []byte) bool {
+	return topLevelBoxes[string(header[4:8])]
+}
+
+type box struct {
+	typ  string
+	data []byte
+}
+
+// parseBoxes splits the given data in boxes.
+func parseBoxes(data []byte) ([]box, error) {
+	var boxes []box
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, mp4Error("truncated box header")
+		}
+		size := uint64(binary.BigEndian.Uint32(data))
+		headerSize := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, mp4Error("truncated box header")
+			}
+			size = binary.BigEndian.Uint64(data[8:])
+			headerSize = 16
+		}
+		if size < headerSize || size > uint64(len(data)) {
+			return nil, mp4Error("invalid size of box " + string(data[4:8]))
+		}
+		boxes = append(boxes, box{typ: string(data[4:8]), data: data[headerSize:size]})
+		data = data[size:]
+	}
+	return boxes, nil
+}
+
+func findBox(boxes []box, path ...string) *box {
+	for i := range boxes {
+		if boxes[i].typ != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return &boxes[i]
+		}
+		children, err := parseBoxes(boxes[i].data)
+		if err != nil {
+			return nil
+		}
+		return findBox(children, path[1:]...)
+	}
+	return nil
+}
+
+func mp4Error(message string) error {
+	return InvalidMediaError{Format: "MP4", Message: message}
+}
+
+// probeMP4 walks the top-level boxes of the file until it finds the moov box,
+// which describes the tracks of the media.
+func probeMP4(r mediaReader) (*provider.SourceInfo, error) {
+	var offset int64
+	header := make([]byte, 16)
+	for offset+8 <= r.Size() {
+		if err := readFull(r, header[:8], offset); err != nil {
+			return nil, err
+		}
+		size := int64(binary.BigEndian.Uint32(header))
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			size = r.Size() - offset
+		case 1:
+			if err := readFull(r, header[8:], offset+8); err != nil {
+				return nil, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:]))
+			headerSize = 16
+		}
+		if size < headerSize || offset+size > r.Size() {
+			return nil, mp4Error("invalid size of box " + string(header[4:8]))
+		}
+		if string(header[4:8]) == "moov" {
+			if size > maxMoovSize {
+				return nil, mp4Error("moov box is too large")
+			}
+			data := make([]byte, size-headerSize)
+			if err := readFull(r, data, offset+headerSize); err != nil {
+				return nil, err
+			}
+			return parseMoov(data)
+		}
+		offset += size
+	}
+	return nil, mp4Error("missing moov box")
+}
+
+func parseMoov(data []byte) (*provider.SourceInfo, error) {
+	boxes, err := parseBoxes(data)
+	if err != nil {
+		return nil, err
+	}
+	mvhd := findBox(boxes, "mvhd")
+	if mvhd == nil {
+		return nil, mp4Error("missing mvhd box")
+	}
+	timescale, duration, ok := parseTimescale(mvhd.data)
+	if !ok {
+		return nil, mp4Error("truncated mvhd box")
+	}
+	if mehd := findBox(boxes, "mvex", "mehd"); duration == 0 && mehd != nil {
+		duration = fragmentDuration(mehd.data)
+	}
+	info := provider.SourceInfo{Duration: toDuration(duration, timescale)}
+	for _, b := range boxes {
+		if b.typ != "trak" {
+			continue
+		}
+		track, err := parseTrak(b.data)
+		if err != nil {
+			return nil, err
+		}
+		switch track.handler {
+		case "vide":
+			if info.VideoCodec == "" {
+				info.VideoCodec = track.codec
+				info.Width = track.width
+				info.Height = track.height
+			}
+		case "soun":
+			info.AudioTracks = append(info.AudioTracks, provider.AudioTrackInfo{
+				Codec:      track.codec,
+				Channels:   track.channels,
+				SampleRate: track.sampleRate,
+				Language:   track.language,
+			})
+		}
+	}
+	return &info, nil
+}
+
+// parseTimescale parses the timescale and the duration of mvhd and mdhd
+// boxes.
+func parseTimescale(data []byte) (timescale, duration uint64, ok bool) {
+	if len(data) > 0 && data[0] == 1 {
+		if len(data) < 32 {
+			return 0, 0, false
+		}
+		return uint64(binary.BigEndian.Uint32(data[20:])), binary.BigEndian.Uint64(data[24:]), true
+	}
+	if len(data) < 20 {
+		return 0, 0, false
+	}
+	return uint64(binary.BigEndian.Uint32(data[12:])), uint64(binary.BigEndian.Uint32(data[16:])), true
+}
+
+// fragmentDuration parses the duration of fragmented files, defined in the
+// mehd box, in the timescale of the movie.
+func fragmentDuration(mehd []byte) uint64 {
+	if len(mehd) >= 12 && mehd[0] == 1 {
+		return binary.BigEndian.Uint64(mehd[4:])
+	}
+	if len(mehd) >= 8 {
+		return uint64(binary.BigEndian.Uint32(mehd[4:]))
+	}
+	return 0
+}
+
+func toDuration(value, timescale uint64) time.Duration {
+	if timescale == 0 {
+		return 0
+	}
+	return time.Duration(float64(value) / float64(timescale) * float64(time.Second))
+}
+
+type mp4Track struct {
+	handler    string
+	codec      string
+	width      int64
+	height     int64
+	channels   int64
+	sampleRate int64
+	language   string
+}
+
+func parseTrak(data []byte) (*mp4Track, error) {
+	boxes, err := parseBoxes(data)
+	if err != nil {
+		return nil, err
+	}
+	var track mp4Track
+	if tkhd := findBox(boxes, "tkhd"); tkhd != nil {
+		offset := 76
+		if len(tkhd.data) > 0 && tkhd.data[0] == 1 {
+			offset = 88
+		}
+		if len(tkhd.data) >= offset+8 {
+			track.width = int64(binary.BigEndian.Uint32(tkhd.data[offset:]) >> 16)
+			track.height = int64(binary.BigEndian.Uint32(tkhd.data[offset+4:]) >> 16)
+		}
+	}
+	if hdlr := findBox(boxes, "mdia", "hdlr"); hdlr != nil && len(hdlr.data) >= 12 {
+		track.handler = string(hdlr.data[8:12])
+	}
+	if mdhd := findBox(boxes, "mdia", "mdhd"); mdhd != nil {
+		offset := 20
+		if len(mdhd.data) > 0 && mdhd.data[0] == 1 {
+			offset = 32
+		}
+		if len(mdhd.data) >= offset+2 {
+			track.language = parseLanguage(binary.BigEndian.Uint16(mdhd.data[offset:]))
+		}
+	}
+	stsd := findBox(boxes, "mdia", "minf", "stbl", "stsd")
+	if stsd == nil || len(stsd.data) < 16 {
+		return &track, nil
+	}
+	entry := stsd.data[8:]
+	entryType := string(entry[4:8])
+	track.codec = sampleEntryCodecs[entryType]
+	if track.codec == "" {
+		track.codec = strings.TrimSpace(entryType)
+	}
+	switch track.handler {
+	case "vide":
+		if (track.width == 0 || track.height == 0) && len(entry) >= 36 {
+			track.width = int64(binary.BigEndian.Uint16(entry[32:]))
+			track.height = int64(binary.BigEndian.Uint16(entry[34:]))
+		}
+	case "soun":
+		if len(entry) >= 36 {
+			track.channels = int64(binary.BigEndian.Uint16(entry[24:]))
+			track.sampleRate = int64(binary.BigEndian.Uint32(entry[32:]) >> 16)
+		}
+	}
+	return &track, nil
+}
+
+// parseLanguage parses the packed ISO 639-2/T code of mdhd boxes. It returns
+// an empty string for undetermined languages and for the Macintosh language
+// codes used in QuickTime files.
+func parseLanguage(packed uint16) string {
+	if packed < 0x400 || packed == 0x7fff {
+		return ""
+	}
+	code := []byte{
+		byte(packed>>10&0x1f) + 0x60,
+		byte(packed>>5&0x1f) + 0x60,
+		byte(packed&0x1f) + 0x60,
+	}
+	if string(code) == "und" {
+		return ""
+	}
+	return string(code)
+}
@@ -0,0 +1,177 @@
+package probe
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+func mp4Box(typ string, children ...[]byte) []byte {
+	data := make([]byte, 8)
+	copy(data[4:], typ)
+	for _, child := range children {
+		data = append(data, child...)
+	}
+	binary.BigEndian.PutUint32(data, uint32(len(data)))
+	return data
+}
+
+func mp4FullBox(typ string, size int, fields map[int]uint32) []byte {
+	data := make([]byte, size)
+	for offset, value := range fields {
+		binary.BigEndian.PutUint32(data[offset:], value)
+	}
+	return mp4Box(typ, data)
+}
+
+func packLanguage(code string) uint32 {
+	return uint32(code[0]-0x60)<<10 | uint32(code[1]-0x60)<<5 | uint32(code[2]-0x60)
+}
+
+func mp4VideoTrak(entryType string, width, height uint32) []byte {
+	entry := make([]byte, 78)
+	binary.BigEndian.PutUint16(entry[24:], uint16(width))
+	binary.BigEndian.PutUint16(entry[26:], uint16(height))
+	return mp4Box("trak",
+		mp4FullBox("tkhd", 84, map[int]uint32{76: width << 16, 80: height << 16}),
+		mp4Box("mdia",
+			mp4FullBox("mdhd", 24, map[int]uint32{12: 24000, 20: packLanguage("und") << 16}),
+			mp4FullBox("hdlr", 24, map[int]uint32{8: binary.BigEndian.Uint32([]byte("vide"))}),
+			mp4Box("minf", mp4Box("stbl", mp4Box("stsd", make([]byte, 8), mp4Box(entryType, entry)))),
+		),
+	)
+}
+
+func mp4AudioTrak(entryType, language string, channels, sampleRate uint32) []byte {
+	entry := make([]byte, 28)
+	binary.BigEndian.PutUint16(entry[16:], uint16(channels))
+	binary.BigEndian.PutUint32(entry[24:], sampleRate<<16)
+	return mp4Box("trak",
+		mp4FullBox("tkhd", 84, nil),
+		mp4Box("mdia",
+			mp4FullBox("mdhd", 24, map[int]uint32{12: sampleRate, 20: packLanguage(language) << 16}),
+			mp4FullBox("hdlr", 24, map[int]uint32{8: binary.BigEndian.Uint32([]byte("soun"))}),
+			mp4Box("minf", mp4Box("stbl", mp4Box("stsd", make([]byte, 8), mp4Box(entryType, entry)))),
+		),
+	)
+}
+
+func buildMP4(moovFirst bool) []byte {
+	moov := mp4Box("moov",
+		mp4FullBox("mvhd", 100, map[int]uint32{12: 1000, 16: 63500}),
+		mp4VideoTrak("avc1", 1920, 1080),
+		mp4AudioTrak("mp4a", "eng", 2, 48000),
+		mp4AudioTrak("ac-3", "por", 6, 48000),
+	)
+	ftyp := mp4Box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2avc1mp41"))
+	mdat := mp4Box("mdat", make([]byte, 4096))
+	if moovFirst {
+		return append(append(ftyp, moov...), mdat...)
+	}
+	return append(append(ftyp, mdat...), moov...)
+}
+
+func TestProbeMP4(t *testing.T) {
+	want := provider.SourceInfo{
+		Duration:   63500 * time.Millisecond,
+		Width:      1920,
+		Height:     1080,
+		VideoCodec: "h264",
+		Bitrate:    632,
+		AudioTracks: []provider.AudioTrackInfo{
+			{Codec: "aac", Channels: 2, SampleRate: 48000, Language: "eng"},
+			{Codec: "ac3", Channels: 6, SampleRate: 48000, Language: "por"},
+		},
+	}
+	for _, moovFirst := range []bool{true, false} {
+		info, err := probeMedia(bytesReader(buildMP4(moovFirst)))
+		if err != nil {
+			t.Errorf("moovFirst=%v: unexpected error: %s", moovFirst, err)
+			continue
+		}
+		if !reflect.DeepEqual(*info, want) {
+			t.Errorf("moovFirst=%v: wrong info returned.\nWant %#v\nGot  %#v", moovFirst, want, *info)
+		}
+	}
+}
+
+func TestProbeMP4FragmentedAndVideoOnly(t *testing.T) {
+	video := mp4VideoTrak("hvc1", 0, 0)
+	// the dimensions of the sample entry are used when tkhd doesn't
+	// define them
+	entry := video[len(video)-78:]
+	binary.BigEndian.PutUint16(entry[24:], 3840)
+	binary.BigEndian.PutUint16(entry[26:], 2160)
+	data := append(mp4Box("ftyp", []byte("iso5")), mp4Box("moov",
+		mp4FullBox("mvhd", 100, map[int]uint32{12: 90000}),
+		mp4Box("mvex", mp4FullBox("mehd", 8, map[int]uint32{4: 900000})),
+		video,
+	)...)
+	info, err := probeMedia(bytesReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := provider.SourceInfo{Duration: 10 * time.Second, Width: 3840, Height: 2160, VideoCodec: "hevc", Bitrate: 353}
+	if !reflect.DeepEqual(*info, want) {
+		t.Errorf("wrong info returned.\nWant %#v\nGot  %#v", want, *info)
+	}
+}
+
+func TestProbeMP4Invalid(t *testing.T) {
+	var tests = []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{
+			"missing moov",
+			append(mp4Box("ftyp", []byte("isom")), mp4Box("mdat", make([]byte, 64))...),
+			"invalid MP4 file: missing moov box",
+		},
+		{
+			"missing mvhd",
+			append(mp4Box("ftyp", []byte("isom")), mp4Box("moov", mp4VideoTrak("avc1", 640, 360))...),
+			"invalid MP4 file: missing mvhd box",
+		},
+		{
+			"invalid box size",
+			append(mp4Box("ftyp", []byte("isom")), 0, 0, 0x10, 0, 'm', 'o', 'o', 'v'),
+			"invalid MP4 file: invalid size of box moov",
+		},
+		{
+			"invalid child box",
+			append(mp4Box("ftyp", []byte("isom")), mp4Box("moov", []byte{0, 0, 0, 2, 'm', 'v', 'h', 'd'})...),
+			"invalid MP4 file: invalid size of box mvhd",
+		},
+	}
+	for _, test := range tests {
+		_, err := probeMedia(bytesReader(test.data))
+		if err == nil {
+			t.Errorf("%s: unexpected <nil> error", test.name)
+			continue
+		}
+		if err.Error() != test.wantErr {
+			t.Errorf("%s: wrong error returned. Want %q. Got %q", test.name, test.wantErr, err.Error())
+		}
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	var tests = []struct {
+		packed uint16
+		want   string
+	}{
+		{uint16(packLanguage("eng")), "eng"},
+		{uint16(packLanguage("und")), ""},
+		{0x7fff, ""},
+		{0, ""},
+	}
+	for _, test := range tests {
+		if got := parseLanguage(test.packed); got != test.want {
+			t.Errorf("wrong language for %#x. Want %q. Got %q", test.packed, test.want, got)
+		}
+	}
+}
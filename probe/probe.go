@@ -0,0 +1,273 @@
+// Package probe inspects source media before transcoding it, reading only
+// the parts of the file needed for extracting its duration, its dimensions
+// and the codecs of its streams. The overall bitrate of the media is derived
+// from its size and duration.
+//
+// It supports MP4 and MOV files (ISO base media files) and MPEG transport
+// streams, either local (file://) or served over HTTP. Remote files are read
+// using range requests, so the server must support them. Local files can only
+// be probed within the root directory configured in the prober.
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+var (
+	// ErrUnsupportedSource is the error returned when the source media
+	// can't be read by the prober, like S3 objects.
+	ErrUnsupportedSource = errors.New("unsupported source: only http://, https:// and allowed file:// sources can be probed")
+
+	// ErrUnsupportedFormat is the error returned when the format of the
+	// source media is not supported by the prober.
+	ErrUnsupportedFormat = errors.New("unsupported media format: only MP4, MOV and MPEG-TS files can be probed")
+
+	// ErrSourceNotFound is the error returned when the source media
+	// doesn't exist.
+	ErrSourceNotFound = errors.New("source not found")
+
+	// ErrSourceUnavailable is the error returned when the source media
+	// can't be read, like when its server is unreachable or refuses the
+	// request.
+	ErrSourceUnavailable = errors.New("unable to read source")
+)
+
+// InvalidMediaError is returned when the source media is corrupt, or can't
+// be parsed.
+type InvalidMediaError struct {
+	Format  string
+	Message string
+}
+
+func (err InvalidMediaError) Error() string {
+	return fmt.Sprintf("invalid %s file: %s", err.Format, err.Message)
+}
+
+// Prober inspects source media.
+type Prober struct {
+	// HTTP client used for reading remote sources. http.DefaultClient is
+	// used when nil.
+	Client *http.Client
+
+	// Directory that local (file://) sources must be in. Local sources
+	// can't be probed when empty.
+	FileRoot string
+}
+
+// Probe inspects the given source media, returning its duration, its
+// dimensions and the codecs of its streams.
+func (p *Prober) Probe(source string) (*provider.SourceInfo, error) {
+	r, err := p.open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return probeMedia(r)
+}
+
+func probeMedia(r mediaReader) (*provider.SourceInfo, error) {
+	header := make([]byte, 8)
+	if err := readFull(r, header, 0); err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return nil, ErrUnsupportedFormat
+		}
+		return nil, err
+	}
+	var info *provider.SourceInfo
+	var err error
+	switch {
+	case isMP4(header):
+		info, err = probeMP4(r)
+	case isTS(r, header):
+		info, err = probeTS(r)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Duration > 0 {
+		info.Bitrate = int64(float64(r.Size()*8) / info.Duration.Seconds())
+	}
+	return info, nil
+}
+
+// mediaReader provides random access to the source media.
+type mediaReader interface {
+	io.ReaderAt
+	io.Closer
+	Size() int64
+}
+
+func readFull(r mediaReader, p []byte, offset int64) error {
+	n, err := r.ReadAt(p, offset)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (p *Prober) open(source string) (mediaReader, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source %q: %s", source, err)
+	}
+	switch u.Scheme {
+	case "file":
+		path, err := LocalPath(p.FileRoot, u.Path)
+		if err != nil {
+			return nil, err
+		}
+		return openFile(path)
+	case "http", "https":
+		client := p.Client
+		if client == nil {
+			client = http.DefaultClient
+		}
+		return openHTTP(client, source)
+	default:
+		return nil, ErrUnsupportedSource
+	}
+}
+
+// LocalPath resolves the given path of a local source, returning
+// ErrUnsupportedSource when it's not within the given root directory,
+// including paths that resolve to a file outside of it through symlinks, or
+// when the root is empty.
+func LocalPath(root, path string) (string, error) {
+	if root == "" {
+		return "", ErrUnsupportedSource
+	}
+	root = filepath.Clean(root)
+	path = filepath.Clean(path)
+	if !withinDir(root, path) {
+		return "", ErrUnsupportedSource
+	}
+	resolvedPath, err := filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		return "", ErrSourceNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	if !withinDir(resolvedRoot, resolvedPath) {
+		return "", ErrUnsupportedSource
+	}
+	return resolvedPath, nil
+}
+
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+type fileReader struct {
+	*os.File
+	size int64
+}
+
+func openFile(path string) (*fileReader, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrSourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("invalid source %q: is a directory", path)
+	}
+	return &fileReader{File: f, size: info.Size()}, nil
+}
+
+func (r *fileReader) Size() int64 {
+	return r.size
+}
+
+// httpReader reads remote media using range requests. Failures reaching the
+// server are reported as ErrSourceUnavailable, so the errors don't tell apart
+// the hosts that can't be reached.
+type httpReader struct {
+	client *http.Client
+	url    string
+	size   int64
+}
+
+func openHTTP(client *http.Client, source string) (*httpReader, error) {
+	resp, err := client.Head(source)
+	if err != nil {
+		return nil, ErrSourceUnavailable
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrSourceNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, ErrSourceUnavailable
+	case resp.ContentLength < 0:
+		return nil, errors.New("unable to read source: unknown size")
+	}
+	return &httpReader{client: client, url: source, size: resp.ContentLength}, nil
+}
+
+func (r *httpReader) ReadAt(p []byte, offset int64) (int, error) {
+	if offset >= r.size {
+		return 0, io.EOF
+	}
+	end := offset + int64(len(p))
+	if end > r.size {
+		end = r.size
+	}
+	req, err := http.NewRequest("GET", r.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-"+strconv.FormatInt(end-1, 10))
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, ErrSourceUnavailable
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("unable to read source: range request returned %s", resp.Status)
+	}
+	n, err := io.ReadFull(resp.Body, p[:end-offset])
+	if err == nil && end < offset+int64(len(p)) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *httpReader) Size() int64 {
+	return r.size
+}
+
+func (r *httpReader) Close() error {
+	return nil
+}
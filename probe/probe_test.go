@@ -0,0 +1,167 @@
+package probe
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (memoryReader) Close() error {
+	return nil
+}
+
+func bytesReader(data []byte) mediaReader {
+	return memoryReader{Reader: bytes.NewReader(data)}
+}
+
+func TestProbeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "video.mp4")
+	err = ioutil.WriteFile(path, buildMP4(false), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prober := Prober{FileRoot: dir}
+	info, err := prober.Probe("file://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Duration != 63500*time.Millisecond || info.Width != 1920 || info.Height != 1080 {
+		t.Errorf("wrong info returned: %#v", *info)
+	}
+	_, err = prober.Probe("file://" + filepath.Join(dir, "missing.mp4"))
+	if err != ErrSourceNotFound {
+		t.Errorf("wrong error for missing file. Want %#v. Got %#v", ErrSourceNotFound, err)
+	}
+}
+
+func TestProbeFileOutsideRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "media")
+	if err = os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(dir, "video.mp4")
+	if err = ioutil.WriteFile(outside, buildMP4(false), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(outside, filepath.Join(root, "link.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		givenTestCase string
+		givenRoot     string
+		givenSource   string
+	}{
+		{"local sources disabled", "", "file://" + outside},
+		{"file outside of the root", root, "file://" + outside},
+		{"relative path escaping the root", root, "file://" + root + "/../video.mp4"},
+		{"missing file outside of the root", root, "file://" + filepath.Join(dir, "missing.mp4")},
+		{"symlink to a file outside of the root", root, "file://" + filepath.Join(root, "link.mp4")},
+	}
+	for _, test := range tests {
+		prober := Prober{FileRoot: test.givenRoot}
+		_, err := prober.Probe(test.givenSource)
+		if err != ErrUnsupportedSource {
+			t.Errorf("%s: wrong error returned. Want %#v. Got %#v", test.givenTestCase, ErrUnsupportedSource, err)
+		}
+	}
+}
+
+func TestProbeHTTP(t *testing.T) {
+	files := map[string][]byte{
+		"/video.mp4": buildMP4(false),
+		"/video.ts":  buildTS(3, 0),
+		"/video.txt": []byte("this is not a video file"),
+	}
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/forbidden.mp4" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == "GET" {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	var tests = []struct {
+		path      string
+		wantCodec string
+		wantErr   error
+	}{
+		{"/video.mp4", "h264", nil},
+		{"/video.ts", "h264", nil},
+		{"/video.txt", "", ErrUnsupportedFormat},
+		{"/missing.mp4", "", ErrSourceNotFound},
+		{"/forbidden.mp4", "", ErrSourceUnavailable},
+	}
+	var prober Prober
+	for _, test := range tests {
+		ranges = nil
+		info, err := prober.Probe(server.URL + test.path)
+		if err != test.wantErr {
+			t.Errorf("%s: wrong error returned. Want %#v. Got %#v", test.path, test.wantErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if info.VideoCodec != test.wantCodec {
+			t.Errorf("%s: wrong codec. Want %q. Got %q", test.path, test.wantCodec, info.VideoCodec)
+		}
+		for _, r := range ranges {
+			if r == "" {
+				t.Errorf("%s: unexpected request without range", test.path)
+			}
+		}
+	}
+	wantRanges := []string{"bytes=0-7", "bytes=0-7", "bytes=32-39", "bytes=4136-4143", "bytes=4144-5021"}
+	ranges = nil
+	prober.Probe(server.URL + "/video.mp4")
+	if !reflect.DeepEqual(ranges, wantRanges) {
+		t.Errorf("wrong range requests.\nWant %#v\nGot  %#v", wantRanges, ranges)
+	}
+}
+
+func TestProbeUnreachableSource(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	var prober Prober
+	_, err := prober.Probe(server.URL + "/video.mp4")
+	if err != ErrSourceUnavailable {
+		t.Errorf("wrong error returned. Want %#v. Got %#v", ErrSourceUnavailable, err)
+	}
+}
+
+func TestProbeUnsupportedSource(t *testing.T) {
+	var prober Prober
+	_, err := prober.Probe("s3://some-bucket/video.mp4")
+	if err != ErrUnsupportedSource {
+		t.Errorf("wrong error returned. Want %#v. Got %#v", ErrUnsupportedSource, err)
+	}
+}
@@ -0,0 +1,358 @@
+package probe
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+const (
+	tsPacketSize = 188
+	tsSyncByte   = 0x47
+
+	// tsProbeSize is the size of the chunks read from the start and from
+	// the end of transport streams.
+	tsProbeSize = 2 << 20
+
+	// maxPESSize is the maximum amount of data kept from the first PES
+	// packet of each stream, used for parsing its codec parameters.
+	maxPESSize = 256 << 10
+
+	ptsClockRate = 90000
+	ptsWrap      = 1 << 33
+)
+
+// tsStreamTypes maps the stream types of program map tables to the names of
+// the codecs.
+var tsStreamTypes = map[byte]string{
+	0x01: "mpeg2",
+	0x02: "mpeg2",
+	0x03: "mp3",
+	0x04: "mp3",
+	0x0f: "aac",
+	0x11: "aac",
+	0x1b: "h264",
+	0x24: "hevc",
+	0x81: "ac3",
+	0x87: "eac3",
+}
+
+// tsDescriptorCodecs maps the tags of the descriptors of private streams to
+// the names of the codecs.
+var tsDescriptorCodecs = map[byte]string{
+	0x6a: "ac3",
+	0x7a: "eac3",
+}
+
+var adtsSampleRates = []int64{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+func isTS(r mediaReader, header []byte) bool {
+	if header[0] != tsSyncByte {
+		return false
+	}
+	if r.Size() < 2*tsPacketSize {
+		return r.Size() == tsPacketSize
+	}
+	next := make([]byte, 1)
+	return readFull(r, next, tsPacketSize) == nil && next[0] == tsSyncByte
+}
+
+type tsStream struct {
+	codec    string
+	video    bool
+	language string
+
+	firstPTS int64
+	lastPTS  int64
+	payload  []byte
+	started  bool
+	complete bool
+}
+
+type tsDemuxer struct {
+	pmtPID  int
+	streams map[int]*tsStream
+	order   []*tsStream
+}
+
+func tsError(message string) error {
+	return InvalidMediaError{Format: "MPEG-TS", Message: message}
+}
+
+// probeTS parses the program tables and the first PES packets of the
+// elementary streams at the start of the file, and then the last PES packets
+// at the end of the file, for calculating its duration.
+func probeTS(r mediaReader) (*provider.SourceInfo, error) {
+	size := r.Size()
+	if size > tsProbeSize {
+		size = tsProbeSize
+	}
+	head := make([]byte, size-size%tsPacketSize)
+	if err := readFull(r, head, 0); err != nil {
+		return nil, err
+	}
+	d := tsDemuxer{pmtPID: -1}
+	for offset := 0; offset+tsPacketSize <= len(head); offset += tsPacketSize {
+		packet := head[offset : offset+tsPacketSize]
+		if packet[0] != tsSyncByte {
+			return nil, tsError("lost sync")
+		}
+		d.parsePacket(packet, false)
+	}
+	if d.streams == nil {
+		return nil, tsError("missing program map table")
+	}
+	if r.Size() > int64(len(head)) {
+		if err := d.parseTail(r); err != nil {
+			return nil, err
+		}
+	}
+	var info provider.SourceInfo
+	var reference *tsStream
+	for _, stream := range d.order {
+		if stream.video {
+			if info.VideoCodec != "" {
+				continue
+			}
+			info.VideoCodec = stream.codec
+			if stream.codec == "h264" {
+				info.Width, info.Height = h264Dimensions(stream.payload)
+			}
+			reference = stream
+		} else {
+			track := provider.AudioTrackInfo{Codec: stream.codec, Language: stream.language}
+			if stream.codec == "aac" {
+				track.Channels, track.SampleRate = adtsParameters(stream.payload)
+			}
+			info.AudioTracks = append(info.AudioTracks, track)
+			if reference == nil {
+				reference = stream
+			}
+		}
+	}
+	if reference != nil && reference.started && reference.lastPTS >= 0 {
+		ticks := (reference.lastPTS - reference.firstPTS + ptsWrap) % ptsWrap
+		info.Duration = time.Duration(ticks) * time.Second / ptsClockRate
+	}
+	return &info, nil
+}
+
+// parseTail parses the PES packets at the end of the file, recording the
+// last PTS of each stream.
+func (d *tsDemuxer) parseTail(r mediaReader) error {
+	size := int64(tsProbeSize)
+	if r.Size() < size {
+		size = r.Size()
+	}
+	tail := make([]byte, size)
+	if err := readFull(r, tail, r.Size()-size); err != nil {
+		return err
+	}
+	start := -1
+	for i := 0; i < tsPacketSize && i+tsPacketSize < len(tail); i++ {
+		if tail[i] == tsSyncByte && tail[i+tsPacketSize] == tsSyncByte {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return tsError("lost sync")
+	}
+	for offset := start; offset+tsPacketSize <= len(tail); offset += tsPacketSize {
+		if tail[offset] != tsSyncByte {
+			return tsError("lost sync")
+		}
+		d.parsePacket(tail[offset:offset+tsPacketSize], true)
+	}
+	return nil
+}
+
+func (d *tsDemuxer) parsePacket(packet []byte, tail bool) {
+	pid := int(packet[1]&0x1f)<<8 | int(packet[2])
+	unitStart := packet[1]&0x40 != 0
+	payload := tsPayload(packet)
+	if payload == nil {
+		return
+	}
+	switch {
+	case pid == 0 && unitStart && d.pmtPID < 0:
+		d.parsePAT(psiSection(payload))
+	case pid == d.pmtPID && unitStart && d.streams == nil:
+		d.parsePMT(psiSection(payload))
+	}
+	stream, ok := d.streams[pid]
+	if !ok {
+		return
+	}
+	if unitStart {
+		data, pts := parsePES(payload)
+		if tail {
+			if pts >= 0 {
+				stream.lastPTS = pts
+			}
+			return
+		}
+		if stream.started {
+			stream.complete = true
+		} else if pts >= 0 {
+			stream.started = true
+			stream.firstPTS = pts
+			stream.lastPTS = pts
+			stream.payload = append(stream.payload, data...)
+		}
+		if pts >= 0 {
+			stream.lastPTS = pts
+		}
+		return
+	}
+	if !tail && stream.started && !stream.complete && len(stream.payload) < maxPESSize {
+		stream.payload = append(stream.payload, payload...)
+	}
+}
+
+// tsPayload returns the payload of the given packet, skipping its adaptation
+// field.
+func tsPayload(packet []byte) []byte {
+	adaptationFieldControl := packet[3] >> 4 & 0x3
+	if adaptationFieldControl&0x1 == 0 {
+		return nil
+	}
+	offset := 4
+	if adaptationFieldControl&0x2 != 0 {
+		offset += 1 + int(packet[4])
+	}
+	if offset >= len(packet) {
+		return nil
+	}
+	return packet[offset:]
+}
+
+// psiSection returns the section that starts in the given payload, skipping
+// the pointer field, and limited to the length declared in the section.
+func psiSection(payload []byte) []byte {
+	start := 1 + int(payload[0])
+	if start+3 > len(payload) {
+		return nil
+	}
+	section := payload[start:]
+	end := 3 + (int(section[1]&0x0f)<<8 | int(section[2])) - 4
+	if end > len(section) || end < 8 {
+		return nil
+	}
+	return section[:end]
+}
+
+func (d *tsDemuxer) parsePAT(section []byte) {
+	if len(section) < 8 || section[0] != 0x00 {
+		return
+	}
+	for i := 8; i+4 <= len(section); i += 4 {
+		program := int(section[i])<<8 | int(section[i+1])
+		if program != 0 {
+			d.pmtPID = int(section[i+2]&0x1f)<<8 | int(section[i+3])
+			return
+		}
+	}
+}
+
+func (d *tsDemuxer) parsePMT(section []byte) {
+	if len(section) < 12 || section[0] != 0x02 {
+		return
+	}
+	d.streams = make(map[int]*tsStream)
+	i := 12 + (int(section[10]&0x0f)<<8 | int(section[11]))
+	for i+5 <= len(section) {
+		streamType := section[i]
+		pid := int(section[i+1]&0x1f)<<8 | int(section[i+2])
+		end := i + 5 + (int(section[i+3]&0x0f)<<8 | int(section[i+4]))
+		if end > len(section) {
+			return
+		}
+		stream := tsStream{codec: tsStreamTypes[streamType], lastPTS: -1}
+		descriptors := section[i+5 : end]
+		for len(descriptors) >= 2 && 2+int(descriptors[1]) <= len(descriptors) {
+			tag, data := descriptors[0], descriptors[2:2+descriptors[1]]
+			if tag == 0x0a && len(data) >= 3 {
+				stream.language = string(data[:3])
+			}
+			if codec, ok := tsDescriptorCodecs[tag]; ok && streamType == 0x06 {
+				stream.codec = codec
+			}
+			descriptors = descriptors[2+descriptors[1]:]
+		}
+		i = end
+		switch streamType {
+		case 0x01, 0x02, 0x1b, 0x24:
+			stream.video = true
+		case 0x03, 0x04, 0x0f, 0x11, 0x81, 0x87:
+		default:
+			if stream.codec == "" {
+				// data streams, like subtitles and metadata
+				continue
+			}
+		}
+		d.streams[pid] = &stream
+		d.order = append(d.order, &stream)
+	}
+}
+
+// parsePES parses the header of the PES packet that starts in the given
+// payload, returning the data of the packet and its PTS, or -1 when the
+// packet doesn't define it.
+func parsePES(payload []byte) ([]byte, int64) {
+	if len(payload) < 9 || !bytes.HasPrefix(payload, []byte{0, 0, 1}) {
+		return nil, -1
+	}
+	start := 9 + int(payload[8])
+	if start > len(payload) {
+		return nil, -1
+	}
+	if payload[7]&0x80 == 0 || len(payload) < 14 {
+		return payload[start:], -1
+	}
+	p := payload[9:14]
+	pts := int64(p[0]>>1&0x07)<<30 | int64(p[1])<<22 | int64(p[2]>>1)<<15 | int64(p[3])<<7 | int64(p[4]>>1)
+	return payload[start:], pts
+}
+
+// h264Dimensions returns the dimensions defined in the first sequence
+// parameter set found in the given H.264 elementary stream.
+func h264Dimensions(data []byte) (int64, int64) {
+	for {
+		i := bytes.Index(data, []byte{0, 0, 1})
+		if i < 0 || i+4 > len(data) {
+			return 0, 0
+		}
+		data = data[i+3:]
+		if data[0]&0x1f != 7 {
+			continue
+		}
+		nal := data[1:]
+		if end := bytes.Index(nal, []byte{0, 0, 1}); end >= 0 {
+			nal = nal[:end]
+		}
+		width, height, err := parseSPS(nal)
+		if err != nil {
+			return 0, 0
+		}
+		return width, height
+	}
+}
+
+// adtsParameters returns the number of channels and the sample rate defined
+// in the first ADTS header of the given AAC elementary stream.
+func adtsParameters(data []byte) (int64, int64) {
+	for i := 0; i+4 <= len(data); i++ {
+		if data[i] != 0xff || data[i+1]&0xf0 != 0xf0 {
+			continue
+		}
+		sampleRateIndex := int(data[i+2] >> 2 & 0x0f)
+		channels := int64(data[i+2]&0x01)<<2 | int64(data[i+3]>>6)
+		if sampleRateIndex >= len(adtsSampleRates) {
+			return channels, 0
+		}
+		return channels, adtsSampleRates[sampleRateIndex]
+	}
+	return 0, 0
+}
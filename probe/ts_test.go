@@ -0,0 +1,194 @@
+package probe
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+const (
+	testPMTPID   = 0x1000
+	testVideoPID = 0x100
+	testAudioPID = 0x101
+)
+
+type tsTestStream struct {
+	streamType  byte
+	pid         int
+	descriptors []byte
+}
+
+func tsPacket(pid int, unitStart bool, payload []byte) []byte {
+	packet := make([]byte, tsPacketSize)
+	packet[0] = tsSyncByte
+	packet[1] = byte(pid >> 8 & 0x1f)
+	if unitStart {
+		packet[1] |= 0x40
+	}
+	packet[2] = byte(pid)
+	packet[3] = 0x10
+	n := copy(packet[4:], payload)
+	for i := 4 + n; i < tsPacketSize; i++ {
+		packet[i] = 0xff
+	}
+	return packet
+}
+
+func psiPacket(pid int, tableID byte, body []byte) []byte {
+	sectionLength := 5 + len(body) + 4
+	section := []byte{0, tableID, 0xb0 | byte(sectionLength>>8), byte(sectionLength), 0, 1, 0xc1, 0, 0}
+	section = append(section, body...)
+	section = append(section, 0, 0, 0, 0)
+	return tsPacket(pid, true, section)
+}
+
+func patPacket() []byte {
+	return psiPacket(0, 0x00, []byte{0, 1, 0xe0 | testPMTPID>>8, testPMTPID & 0xff})
+}
+
+func pmtPacket(streams ...tsTestStream) []byte {
+	body := []byte{0xe0 | testVideoPID>>8, testVideoPID & 0xff, 0xf0, 0}
+	for _, stream := range streams {
+		body = append(body, stream.streamType, 0xe0|byte(stream.pid>>8), byte(stream.pid), 0xf0, byte(len(stream.descriptors)))
+		body = append(body, stream.descriptors...)
+	}
+	return psiPacket(testPMTPID, 0x02, body)
+}
+
+func pesPackets(pid int, streamID byte, pts int64, data []byte) []byte {
+	payload := []byte{
+		0, 0, 1, streamID, 0, 0, 0x80, 0x80, 5,
+		0x21 | byte(pts>>29&0x0e),
+		byte(pts >> 22),
+		byte(pts>>14) | 1,
+		byte(pts >> 7),
+		byte(pts<<1) | 1,
+	}
+	payload = append(payload, data...)
+	var packets []byte
+	for unitStart := true; len(payload) > 0; unitStart = false {
+		n := len(payload)
+		if n > tsPacketSize-4 {
+			n = tsPacketSize - 4
+		}
+		packets = append(packets, tsPacket(pid, unitStart, payload[:n])...)
+		payload = payload[n:]
+	}
+	return packets
+}
+
+func h264AccessUnit() []byte {
+	sps := buildSPS(spsParams{profile: 100, chromaFormat: 1, widthInMbs: 80, heightInMbs: 45, frameMbsOnly: true})
+	data := []byte{0, 0, 0, 1, 0x09, 0xf0, 0, 0, 0, 1, 0x67}
+	data = append(data, sps...)
+	data = append(data, 0, 0, 0, 1, 0x68, 0xce, 0x3c, 0x80, 0, 0, 1, 0x65)
+	return append(data, make([]byte, 400)...)
+}
+
+// adtsFrame returns an AAC-LC frame of 48kHz stereo audio.
+func adtsFrame() []byte {
+	return []byte{0xff, 0xf1, 0x4c, 0x80, 0x02, 0x1f, 0xfc, 0x21, 0x10}
+}
+
+func buildTS(segments int, padding int) []byte {
+	data := append(patPacket(), pmtPacket(
+		tsTestStream{streamType: 0x1b, pid: testVideoPID},
+		tsTestStream{streamType: 0x0f, pid: testAudioPID, descriptors: []byte{0x0a, 4, 'e', 'n', 'g', 0}},
+		tsTestStream{streamType: 0x06, pid: 0x102, descriptors: []byte{0x6a, 1, 0, 0x0a, 4, 's', 'p', 'a', 0}},
+		tsTestStream{streamType: 0x15, pid: 0x103},
+	)...)
+	const firstPTS = 126000
+	for i := 0; i < segments; i++ {
+		if i == 1 {
+			for j := 0; j < padding; j++ {
+				data = append(data, tsPacket(0x1fff, false, nil)...)
+			}
+		}
+		pts := int64(firstPTS + i*90000)
+		data = append(data, pesPackets(testVideoPID, 0xe0, pts, h264AccessUnit())...)
+		data = append(data, pesPackets(testAudioPID, 0xc0, pts, adtsFrame())...)
+		data = append(data, pesPackets(0x102, 0xbd, pts, []byte{0x0b, 0x77})...)
+	}
+	return data
+}
+
+func TestProbeTS(t *testing.T) {
+	want := provider.SourceInfo{
+		Duration:   9 * time.Second,
+		Width:      1280,
+		Height:     720,
+		VideoCodec: "h264",
+		AudioTracks: []provider.AudioTrackInfo{
+			{Codec: "aac", Channels: 2, SampleRate: 48000, Language: "eng"},
+			{Codec: "ac3", Language: "spa"},
+		},
+	}
+	var tests = []struct {
+		name        string
+		padding     int
+		wantBitrate int64
+	}{
+		{"short file", 0, 8689},
+		{"long file", tsProbeSize / tsPacketSize * 2, 3736938},
+	}
+	for _, test := range tests {
+		info, err := probeMedia(bytesReader(buildTS(10, test.padding)))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		want.Bitrate = test.wantBitrate
+		if !reflect.DeepEqual(*info, want) {
+			t.Errorf("%s: wrong info returned.\nWant %#v\nGot  %#v", test.name, want, *info)
+		}
+	}
+}
+
+func TestProbeTSPTSWrap(t *testing.T) {
+	data := append(patPacket(), pmtPacket(tsTestStream{streamType: 0x0f, pid: testAudioPID})...)
+	data = append(data, pesPackets(testAudioPID, 0xc0, ptsWrap-90000, adtsFrame())...)
+	data = append(data, pesPackets(testAudioPID, 0xc0, 45000, adtsFrame())...)
+	info, err := probeMedia(bytesReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := provider.SourceInfo{
+		Duration:    1500 * time.Millisecond,
+		Bitrate:     4010,
+		AudioTracks: []provider.AudioTrackInfo{{Codec: "aac", Channels: 2, SampleRate: 48000}},
+	}
+	if !reflect.DeepEqual(*info, want) {
+		t.Errorf("wrong info returned.\nWant %#v\nGot  %#v", want, *info)
+	}
+}
+
+func TestProbeTSInvalid(t *testing.T) {
+	var tests = []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{
+			"missing PMT",
+			append(patPacket(), pesPackets(testVideoPID, 0xe0, 0, h264AccessUnit())...),
+			"invalid MPEG-TS file: missing program map table",
+		},
+		{
+			"lost sync",
+			append(append(patPacket(), tsPacket(0x1fff, false, nil)...), make([]byte, tsPacketSize)...),
+			"invalid MPEG-TS file: lost sync",
+		},
+	}
+	for _, test := range tests {
+		_, err := probeMedia(bytesReader(test.data))
+		if err == nil {
+			t.Errorf("%s: unexpected <nil> error", test.name)
+			continue
+		}
+		if err.Error() != test.wantErr {
+			t.Errorf("%s: wrong error returned. Want %q. Got %q", test.name, test.wantErr, err.Error())
+		}
+	}
+}
@@ -84,6 +84,15 @@ var h264Levels = []bitmovintypes.H264Level{
 
 var errBitmovinInvalidConfig = provider.InvalidConfigError("Invalid configuration")
 
+// audioParameters lists the optional audio parameters supported by Bitmovin.
+// The language is used for labeling the audio renditions in manifests, and
+// audio-only HLS outputs are alternate audio renditions of the video outputs.
+var audioParameters = []string{"sampleRate", "language", "audioOnly", "audioTracks"}
+
+// videoParameters lists the codec-specific parameters supported by Bitmovin
+// with H.264.
+var videoParameters = []string{"frameRate"}
+
 var s3Pattern = regexp.MustCompile(`^s3://`)
 var httpPattern = regexp.MustCompile(`^http://`)
 var httpsPattern = regexp.MustCompile(`^https://`)
@@ -98,40 +107,60 @@ type bitmovinPreset struct {
 	Audio models.AACCodecConfiguration
 }
 
+// presetData is the data of a preset, stored in the custom data of its H.264
+// configuration, or of its AAC configuration for audio-only presets.
+type presetData struct {
+	container string
+	audioOnly bool
+
+	// audio renditions of the preset, starting with the main audio
+	// configuration, followed by the audio tracks.
+	audio []audioRendition
+}
+
+// audioRendition is an AAC configuration of a preset, along with the language
+// used for labeling it in manifests.
+type audioRendition struct {
+	configID string
+	language string
+}
+
 func (p *bitmovinProvider) CreatePreset(preset db.Preset) (string, error) {
-	if strings.ToLower(preset.Audio.Codec) != "aac" {
-		return "", fmt.Errorf("Unsupported Audio codec: %v", preset.Audio.Codec)
+	if err := checkPreset(preset); err != nil {
+		return "", err
 	}
-	// Bitmovin supports H.264 and H.265, H.265 support can be added in the future
-	if strings.ToLower(preset.Video.Codec) != "h264" {
-		return "", fmt.Errorf("Unsupported Video codec: %v", preset.Video.Codec)
+	audioOnly := preset.AudioOnly()
+	customData := make(map[string]interface{})
+	customData["container"] = preset.Container
+	if preset.Audio.Language != "" {
+		customData["language"] = preset.Audio.Language
 	}
-
-	aac := services.NewAACCodecConfigurationService(p.client)
-	var audioConfigID string
-	bitrate, err := strconv.Atoi(preset.Audio.Bitrate)
-	if err != nil {
-		return "", err
+	if len(preset.AudioTracks) > 0 {
+		audioTracks := make([]interface{}, len(preset.AudioTracks))
+		for i, track := range preset.AudioTracks {
+			trackConfigID, err := p.createAudioPreset(fmt.Sprintf("%s_track%d", preset.Name, i+1), track, nil)
+			if err != nil {
+				return "", err
+			}
+			audioTrack := map[string]interface{}{"audio": trackConfigID}
+			if track.Language != "" {
+				audioTrack["language"] = track.Language
+			}
+			audioTracks[i] = audioTrack
+		}
+		customData["audioTracks"] = audioTracks
 	}
-	temp := int64(bitrate)
-	audioConfig := &models.AACCodecConfiguration{
-		Name:         stringToPtr(preset.Name),
-		Bitrate:      &temp,
-		SamplingRate: floatToPtr(48000.0),
+	if audioOnly {
+		// audio-only presets are AAC configurations holding the
+		// custom data of the preset.
+		return p.createAudioPreset(preset.Name, preset.Audio, customData)
 	}
-	audioResp, err := aac.Create(audioConfig)
+
+	audioConfigID, err := p.createAudioPreset(preset.Name, preset.Audio, nil)
 	if err != nil {
 		return "", err
 	}
-	if audioResp.Status == bitmovinAPIErrorMsg {
-		return "", errors.New("Error in creating audio portion of Preset")
-	}
-
-	audioConfigID = *audioResp.Data.Result.ID
-
-	customData := make(map[string]interface{})
 	customData["audio"] = audioConfigID
-	customData["container"] = preset.Container
 	h264Config, err := p.createVideoPreset(preset, customData)
 	if err != nil {
 		return "", err
@@ -148,6 +177,68 @@ func (p *bitmovinProvider) CreatePreset(preset db.Preset) (string, error) {
 	return *videoResp.Data.Result.ID, nil
 }
 
+// checkPreset returns an error if the given preset can't be translated to
+// Bitmovin codec configurations.
+func checkPreset(preset db.Preset) error {
+	for _, audio := range preset.AudioRenditions() {
+		if strings.ToLower(audio.Codec) != "aac" {
+			return fmt.Errorf("Unsupported Audio codec: %v", audio.Codec)
+		}
+	}
+	if !preset.AudioOnly() {
+		// Bitmovin supports H.264 and H.265, H.265 support can be added in the future
+		if strings.ToLower(preset.Video.Codec) != "h264" {
+			return provider.UnsupportedParameterError{Parameter: "video.codec", Value: preset.Video.Codec}
+		}
+		if err := provider.CheckVideoParameters(preset.Video, videoParameters...); err != nil {
+			return err
+		}
+	}
+	return provider.CheckAudioParameters(preset, audioParameters...)
+}
+
+// createAudioPreset creates the AAC configuration of an audio rendition,
+// returning its ID.
+func (p *bitmovinProvider) createAudioPreset(name string, audio db.AudioPreset, customData map[string]interface{}) (string, error) {
+	audioConfig, err := aacConfiguration(name, audio, customData)
+	if err != nil {
+		return "", err
+	}
+	aac := services.NewAACCodecConfigurationService(p.client)
+	audioResp, err := aac.Create(audioConfig)
+	if err != nil {
+		return "", err
+	}
+	if audioResp.Status == bitmovinAPIErrorMsg {
+		return "", errors.New("Error in creating audio portion of Preset")
+	}
+	return *audioResp.Data.Result.ID, nil
+}
+
+// aacConfiguration translates the given audio rendition to an AAC
+// configuration.
+func aacConfiguration(name string, audio db.AudioPreset, customData map[string]interface{}) (*models.AACCodecConfiguration, error) {
+	samplingRate := 48000.0
+	if audio.SampleRate != "" {
+		rate, err := strconv.ParseFloat(audio.SampleRate, 64)
+		if err != nil {
+			return nil, err
+		}
+		samplingRate = rate
+	}
+	bitrate, err := strconv.Atoi(audio.Bitrate)
+	if err != nil {
+		return nil, err
+	}
+	temp := int64(bitrate)
+	return &models.AACCodecConfiguration{
+		Name:         stringToPtr(name),
+		Bitrate:      &temp,
+		SamplingRate: floatToPtr(samplingRate),
+		CustomData:   customData,
+	}, nil
+}
+
 func (p *bitmovinProvider) createVideoPreset(preset db.Preset, customData map[string]interface{}) (*models.H264CodecConfiguration, error) {
 	h264 := &models.H264CodecConfiguration{
 		CustomData: customData,
@@ -208,44 +299,39 @@ func (p *bitmovinProvider) createVideoPreset(preset db.Preset, customData map[st
 		}
 		h264.MaxGOP = intToPtr(int64(gopSize))
 	}
+	if preset.Video.FrameRate != "" {
+		rate, err := strconv.ParseFloat(preset.Video.FrameRate, 64)
+		if err != nil {
+			return nil, err
+		}
+		h264.Rate = floatToPtr(rate)
+	}
 
 	return h264, nil
 }
 
 func (p *bitmovinProvider) DeletePreset(presetID string) error {
-	// Delete both the audio and video preset
-	h264 := services.NewH264CodecConfigurationService(p.client)
-	cdResp, err := h264.RetrieveCustomData(presetID)
+	// Delete the audio configurations and the video preset
+	data, err := p.retrievePresetData(presetID)
 	if err != nil {
 		return err
 	}
-	if cdResp.Status == bitmovinAPIErrorMsg {
-		return errors.New("Video Preset must contain custom data to hold audio and container information")
-	}
-	var audioPresetID string
-	if cdResp.Data.Result.CustomData != nil {
-		cd := cdResp.Data.Result.CustomData
-		i, ok := cd["audio"]
-		if !ok {
-			return errors.New("No Audio configuration found for Video Preset")
-		}
-		audioPresetID, ok = i.(string)
-		if !ok {
-			return errors.New("Audio Configuration somehow not a string")
-		}
-	} else {
-		return errors.New("No Audio configuration found for Video Preset")
-	}
 
 	aac := services.NewAACCodecConfigurationService(p.client)
-	audioDeleteResp, err := aac.Delete(audioPresetID)
-	if err != nil {
-		return err
+	for _, audio := range data.audio {
+		audioDeleteResp, err := aac.Delete(audio.configID)
+		if err != nil {
+			return err
+		}
+		if audioDeleteResp.Status == bitmovinAPIErrorMsg {
+			return errors.New("Error in deleting audio portion of Preset")
+		}
 	}
-	if audioDeleteResp.Status == bitmovinAPIErrorMsg {
-		return errors.New("Error in deleting audio portion of Preset")
+	if data.audioOnly {
+		return nil
 	}
 
+	h264 := services.NewH264CodecConfigurationService(p.client)
 	videoDeleteResp, err := h264.Delete(presetID)
 	if err != nil {
 		return err
@@ -256,6 +342,55 @@ func (p *bitmovinProvider) DeletePreset(presetID string) error {
 	return nil
 }
 
+// retrievePresetData retrieves the data of the given preset from the custom
+// data of its H.264 configuration, falling back to the AAC configuration of
+// audio-only presets.
+func (p *bitmovinProvider) retrievePresetData(presetID string) (presetData, error) {
+	h264 := services.NewH264CodecConfigurationService(p.client)
+	cdResp, err := h264.RetrieveCustomData(presetID)
+	if err != nil {
+		return presetData{}, err
+	}
+	var data presetData
+	customData := cdResp.Data.Result.CustomData
+	audioPresetID := presetID
+	if cdResp.Status == bitmovinAPIErrorMsg {
+		aac := services.NewAACCodecConfigurationService(p.client)
+		aacResp, err := aac.RetrieveCustomData(presetID)
+		if err != nil {
+			return presetData{}, err
+		}
+		if aacResp.Status == bitmovinAPIErrorMsg {
+			return presetData{}, errors.New("Preset must contain custom data to hold audio and container information")
+		}
+		customData = aacResp.Data.Result.CustomData
+		data.audioOnly = true
+	} else {
+		i, ok := customData["audio"]
+		if !ok {
+			return presetData{}, errors.New("No Audio configuration found for Video Preset")
+		}
+		audioPresetID, ok = i.(string)
+		if !ok {
+			return presetData{}, errors.New("Audio Configuration somehow not a string")
+		}
+	}
+	data.container, _ = customData["container"].(string)
+	language, _ := customData["language"].(string)
+	data.audio = []audioRendition{{configID: audioPresetID, language: language}}
+	audioTracks, _ := customData["audioTracks"].([]interface{})
+	for _, i := range audioTracks {
+		audioTrack, _ := i.(map[string]interface{})
+		configID, ok := audioTrack["audio"].(string)
+		if !ok {
+			return presetData{}, errors.New("Audio track configuration somehow not a string")
+		}
+		language, _ := audioTrack["language"].(string)
+		data.audio = append(data.audio, audioRendition{configID: configID, language: language})
+	}
+	return data, nil
+}
+
 func (p *bitmovinProvider) GetPreset(presetID string) (interface{}, error) {
 	h264 := services.NewH264CodecConfigurationService(p.client)
 	response, err := h264.Retrieve(presetID)
@@ -263,7 +398,7 @@ func (p *bitmovinProvider) GetPreset(presetID string) (interface{}, error) {
 		return nil, err
 	}
 	if response.Status == bitmovinAPIErrorMsg {
-		return nil, errors.New("Error in retrieving video portion of Preset")
+		return p.getAudioOnlyPreset(presetID)
 	}
 	h264Config := response.Data.Result
 	cd, err := h264.RetrieveCustomData(presetID)
@@ -301,6 +436,29 @@ func (p *bitmovinProvider) GetPreset(presetID string) (interface{}, error) {
 	return nil, errors.New("No Audio configuration found for Video Preset")
 }
 
+// getAudioOnlyPreset returns the AAC configuration of an audio-only preset,
+// along with its custom data.
+func (p *bitmovinProvider) getAudioOnlyPreset(presetID string) (interface{}, error) {
+	aac := services.NewAACCodecConfigurationService(p.client)
+	audioResponse, err := aac.Retrieve(presetID)
+	if err != nil {
+		return nil, err
+	}
+	if audioResponse.Status == bitmovinAPIErrorMsg {
+		return nil, errors.New("Error in retrieving Preset")
+	}
+	aacConfig := audioResponse.Data.Result
+	cd, err := aac.RetrieveCustomData(presetID)
+	if err != nil {
+		return nil, err
+	}
+	if cd.Status == bitmovinAPIErrorMsg {
+		return nil, errors.New("Error in retrieving custom data of audio-only Preset")
+	}
+	aacConfig.CustomData = cd.Data.Result.CustomData
+	return bitmovinPreset{Audio: aacConfig}, nil
+}
+
 func (p *bitmovinProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
 	aclEntry := models.ACLItem{
 		Permission: bitmovintypes.ACLPermissionPublicRead,
@@ -308,7 +466,7 @@ func (p *bitmovinProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
 	acl := []models.ACLItem{aclEntry}
 
 	cloudRegion := bitmovintypes.AWSCloudRegion(p.config.AWSStorageRegion)
-	outputBucketName, err := grabBucketNameFromS3Destination(p.config.Destination)
+	outputBucketName, outputPrefix, err := parseS3Destination(provider.JobDestination(job, p.config.Destination))
 	if err != nil {
 		return nil, err
 	}
@@ -352,39 +510,47 @@ func (p *bitmovinProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
 
 	var masterManifestPath string
 	var masterManifestFile string
+	var dashManifestPath string
 	outputtingHLS := false
+	outputtingDASH := false
 	manifestID := ""
+	dashManifestID := ""
+	dashPeriodID := ""
+	dashVideoAdaptationSetID := ""
+	dashAudioAdaptationSetIDs := make(map[string]string)
 
 	//create the master manifest if needed so we can add it to the customData of the encoding response
-	for _, output := range job.Outputs {
-		videoPresetID := output.Preset.ProviderMapping[Name]
-		customDataResp, cdErr := h264S.RetrieveCustomData(videoPresetID)
-
-		if cdErr != nil {
-			return nil, cdErr
-		}
-		if customDataResp.Status == bitmovinAPIErrorMsg {
-			return nil, errors.New("")
-		}
-		containerInterface, ok := customDataResp.Data.Result.CustomData["container"]
-		if !ok {
-			return nil, errors.New("")
+	presets := make([]presetData, len(job.Outputs))
+	hlsVideo, hlsAudioOnly := false, false
+	for i, output := range job.Outputs {
+		data, err := p.retrievePresetData(output.Preset.ProviderMapping[Name])
+		if err != nil {
+			return nil, err
 		}
-		container, ok := containerInterface.(string)
-		if !ok {
-			return nil, errors.New("")
+		if data.container == "" {
+			return nil, errors.New("Container type not found in preset custom data")
 		}
-		if container == "m3u8" {
+		switch data.container {
+		case "m3u8":
 			outputtingHLS = true
-			break
+			if data.audioOnly {
+				hlsAudioOnly = true
+			} else {
+				hlsVideo = true
+			}
+		case "mpd":
+			outputtingDASH = true
 		}
+		presets[i] = data
 	}
 
 	hlsService := services.NewHLSManifestService(p.client)
+	dashService := services.NewDashManifestService(p.client)
 
 	if outputtingHLS {
-		masterManifestPath = filepath.Dir(job.StreamingParams.PlaylistFileName)
-		masterManifestFile = filepath.Base(job.StreamingParams.PlaylistFileName)
+		playlistFileName := manifestName(job.StreamingParams, "hls", "hls/index.m3u8")
+		masterManifestPath = filepath.Join(outputPrefix, filepath.Dir(playlistFileName))
+		masterManifestFile = filepath.Base(playlistFileName)
 		manifestOutput := models.Output{
 			OutputID:   s3OSResponse.Data.Result.ID,
 			OutputPath: stringToPtr(masterManifestPath),
@@ -403,11 +569,51 @@ func (p *bitmovinProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
 		manifestID = *hlsMasterManifestResp.Data.Result.ID
 	}
 
+	if outputtingDASH {
+		dashManifestFileName := manifestName(job.StreamingParams, "dash", "dash/index.mpd")
+		dashManifestPath = filepath.Join(outputPrefix, filepath.Dir(dashManifestFileName))
+		manifestOutput := models.Output{
+			OutputID:   s3OSResponse.Data.Result.ID,
+			OutputPath: stringToPtr(dashManifestPath),
+			ACL:        acl,
+		}
+		dashManifest := &models.DashManifest{
+			ManifestName: stringToPtr(filepath.Base(dashManifestFileName)),
+			Outputs:      []models.Output{manifestOutput},
+		}
+		dashManifestResp, manErr := dashService.Create(dashManifest)
+		if manErr != nil {
+			return nil, manErr
+		} else if dashManifestResp.Status == bitmovinAPIErrorMsg {
+			return nil, errors.New("Error in DASH Manifest creation")
+		}
+		dashManifestID = *dashManifestResp.Data.Result.ID
+
+		periodResp, periodErr := dashService.AddPeriod(dashManifestID, &models.Period{})
+		if periodErr != nil {
+			return nil, periodErr
+		} else if periodResp.Status == bitmovinAPIErrorMsg {
+			return nil, errors.New("Error in adding Period to DASH Manifest")
+		}
+		dashPeriodID = *periodResp.Data.Result.ID
+
+		videoAdaptationSetResp, vasErr := dashService.AddVideoAdaptationSet(dashManifestID, dashPeriodID, &models.VideoAdaptationSet{})
+		if vasErr != nil {
+			return nil, vasErr
+		} else if videoAdaptationSetResp.Status == bitmovinAPIErrorMsg {
+			return nil, errors.New("Error in adding video AdaptationSet to DASH Manifest")
+		}
+		dashVideoAdaptationSetID = *videoAdaptationSetResp.Data.Result.ID
+	}
+
 	encodingS := services.NewEncodingService(p.client)
 	customData := make(map[string]interface{})
 	if outputtingHLS {
 		customData["manifest"] = manifestID
 	}
+	if outputtingDASH {
+		customData["dashManifest"] = dashManifestID
+	}
 	encodingRegion := bitmovintypes.CloudRegion(p.config.EncodingRegion)
 	encodingVersion := bitmovintypes.EncoderVersion(p.config.EncodingVersion)
 	encoding := &models.Encoding{
@@ -424,118 +630,161 @@ func (p *bitmovinProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
 		return nil, errors.New("Error in Encoding Creation")
 	}
 
-	for _, output := range job.Outputs {
-		videoPresetID := output.Preset.ProviderMapping[Name]
-		videoResponse, h264Err := h264S.Retrieve(videoPresetID)
-		if err != nil {
-			return nil, h264Err
-		}
-		if videoResponse.Status == bitmovinAPIErrorMsg {
-			return nil, errors.New("Error in retrieving video portion of preset")
-		}
-		customDataResp, h264CDErr := h264S.RetrieveCustomData(videoPresetID)
-		if err != nil {
-			return nil, h264CDErr
-		}
-		if customDataResp.Status == bitmovinAPIErrorMsg {
-			return nil, errors.New("Error in retrieving video custom data where the audio ID and container type is stored")
-		}
-		audioPresetIDInterface, ok := customDataResp.Data.Result.CustomData["audio"]
-		if !ok {
-			return nil, errors.New("Audio ID not found in video custom data")
-		}
-		audioPresetID, ok := audioPresetIDInterface.(string)
-		if !ok {
-			return nil, errors.New("Audio ID somehow not a string")
-		}
-
-		var audioStreamID, videoStreamID string
-		audioStream := &models.Stream{
-			CodecConfigurationID: &audioPresetID,
-			InputStreams:         aiss,
-		}
-		audioStreamResp, audioErr := encodingS.AddStream(*encodingResp.Data.Result.ID, audioStream)
-		if audioErr != nil {
-			return nil, audioErr
-		}
-		if audioStreamResp.Status == bitmovinAPIErrorMsg {
-			return nil, errors.New("Error in adding audio stream to Encoding")
-		}
-		audioStreamID = *audioStreamResp.Data.Result.ID
-
-		videoStream := &models.Stream{
-			CodecConfigurationID: &videoPresetID,
-			InputStreams:         viss,
-		}
-		videoStreamResp, vsErr := encodingS.AddStream(*encodingResp.Data.Result.ID, videoStream)
-		if vsErr != nil {
-			return nil, vsErr
-		}
-		if videoStreamResp.Status == bitmovinAPIErrorMsg {
-			return nil, errors.New("Error in adding video stream to Encoding")
-		}
-		videoStreamID = *videoStreamResp.Data.Result.ID
+	// audio renditions of HLS outputs are grouped per output, unless the
+	// job has audio-only outputs, which are alternate audio renditions
+	// of all the video outputs.
+	hlsAudioGroup := ""
+	if hlsVideo && hlsAudioOnly {
+		hlsAudioGroup = "audio"
+	}
 
-		audioMuxingStream := models.StreamItem{
-			StreamID: &audioStreamID,
-		}
-		videoMuxingStream := models.StreamItem{
-			StreamID: &videoStreamID,
+	trimmed := trimmedInputs{
+		provider:   p,
+		encodingID: *encodingResp.Data.Result.ID,
+		inputID:    inputID,
+		inputPath:  inputFullPath,
+	}
+	for i, output := range job.Outputs {
+		data := presets[i]
+		clip := job.OutputClip(output)
+		videoPresetID := output.Preset.ProviderMapping[Name]
+		if !data.audioOnly {
+			videoResponse, h264Err := h264S.Retrieve(videoPresetID)
+			if h264Err != nil {
+				return nil, h264Err
+			}
+			if videoResponse.Status == bitmovinAPIErrorMsg {
+				return nil, errors.New("Error in retrieving video portion of preset")
+			}
 		}
 
-		containerInterface, ok := customDataResp.Data.Result.CustomData["container"]
-		if !ok {
-			return nil, errors.New("Container type not found in video custom data")
-		}
-		container, ok := containerInterface.(string)
-		if !ok {
-			return nil, errors.New("Container type somehow not a string")
-		}
-		if container == "m3u8" {
-			audioMuxingOutput := models.Output{
-				OutputID:   s3OSResponse.Data.Result.ID,
-				OutputPath: stringToPtr(filepath.Join(masterManifestPath, audioPresetID)),
-				ACL:        acl,
+		audioMuxingStreams := make([]models.StreamItem, len(data.audio))
+		audioStreamIDs := make([]*string, len(data.audio))
+		for j, audio := range data.audio {
+			inputStreams := aiss
+			selectionMode := bitmovintypes.SelectionModeAuto
+			if j > 0 {
+				// audio tracks are encoded from the matching
+				// audio stream of the source.
+				selectionMode = bitmovintypes.SelectionModeAudioRelative
+				inputStreams = []models.InputStream{{
+					InputID:       stringToPtr(inputID),
+					InputPath:     stringToPtr(inputFullPath),
+					SelectionMode: selectionMode,
+					Position:      intToPtr(int64(j)),
+				}}
 			}
-			audioMuxing := &models.TSMuxing{
-				SegmentLength: floatToPtr(float64(job.StreamingParams.SegmentDuration)),
-				SegmentNaming: stringToPtr("seg_%number%.ts"),
-				Streams:       []models.StreamItem{audioMuxingStream},
-				Outputs:       []models.Output{audioMuxingOutput},
-			}
-			audioMuxingResp, muxErr := encodingS.AddTSMuxing(*encodingResp.Data.Result.ID, audioMuxing)
-			if muxErr != nil {
-				return nil, muxErr
+			audioStream := &models.Stream{
+				CodecConfigurationID: stringToPtr(audio.configID),
+				InputStreams:         inputStreams,
 			}
-			if audioMuxingResp.Status == bitmovinAPIErrorMsg {
-				return nil, errors.New("Error in adding TS Muxing for audio")
+			audioStreamID, audioErr := trimmed.addStream("audio", audioStream, clip, selectionMode, int64(j))
+			if audioErr != nil {
+				return nil, audioErr
 			}
+			audioStreamIDs[j] = audioStreamID
+			audioMuxingStreams[j] = models.StreamItem{StreamID: audioStreamID}
+		}
 
-			// create the MediaInfo
-			audioMediaInfo := &models.MediaInfo{
-				Type:            bitmovintypes.MediaTypeAudio,
-				URI:             stringToPtr(audioPresetID + ".m3u8"),
-				GroupID:         stringToPtr(audioPresetID),
-				Language:        stringToPtr("en"),
-				Name:            stringToPtr(audioPresetID),
-				IsDefault:       boolToPtr(false),
-				Autoselect:      boolToPtr(false),
-				Forced:          boolToPtr(false),
-				SegmentPath:     stringToPtr(audioPresetID),
-				Characteristics: []string{"public.accessibility.describes-video"},
-				EncodingID:      encodingResp.Data.Result.ID,
-				StreamID:        audioStreamResp.Data.Result.ID,
-				MuxingID:        audioMuxingResp.Data.Result.ID,
+		var videoMuxingStream models.StreamItem
+		var videoStreamID *string
+		if !data.audioOnly {
+			videoStream := &models.Stream{
+				CodecConfigurationID: &videoPresetID,
+				InputStreams:         viss,
 			}
+			var vsErr error
+			videoStreamID, vsErr = trimmed.addStream("video", videoStream, clip, bitmovintypes.SelectionModeAuto, 0)
+			if vsErr != nil {
+				return nil, vsErr
+			}
+			videoMuxingStream = models.StreamItem{StreamID: videoStreamID}
+		}
 
-			// Add to Master manifest, we will set the m3u8 and segments relative to the master
-
-			audioMediaInfoResp, miErr := hlsService.AddMediaInfo(manifestID, audioMediaInfo)
-			if miErr != nil {
-				return nil, miErr
+		if data.container == "m3u8" {
+			audioGroup := hlsAudioGroup
+			if audioGroup == "" {
+				audioGroup = data.audio[0].configID
+			}
+			for j, audio := range data.audio {
+				audioMuxingOutput := models.Output{
+					OutputID:   s3OSResponse.Data.Result.ID,
+					OutputPath: stringToPtr(filepath.Join(masterManifestPath, audio.configID)),
+					ACL:        acl,
+				}
+				audioMuxing := &models.TSMuxing{
+					SegmentLength: floatToPtr(float64(job.StreamingParams.SegmentDuration)),
+					SegmentNaming: stringToPtr("seg_%number%.ts"),
+					Streams:       []models.StreamItem{audioMuxingStreams[j]},
+					Outputs:       []models.Output{audioMuxingOutput},
+				}
+				audioMuxingResp, muxErr := encodingS.AddTSMuxing(*encodingResp.Data.Result.ID, audioMuxing)
+				if muxErr != nil {
+					return nil, muxErr
+				}
+				if audioMuxingResp.Status == bitmovinAPIErrorMsg {
+					return nil, errors.New("Error in adding TS Muxing for audio")
+				}
+
+				if data.audioOnly && !hlsVideo {
+					// without video outputs, the audio
+					// renditions are the variants of the
+					// master manifest.
+					uri := audio.configID + ".m3u8"
+					if j == 0 {
+						uri = filepath.Base(output.FileName)
+					}
+					audioStreamInfo := &models.StreamInfo{
+						SegmentPath: stringToPtr(audio.configID),
+						URI:         stringToPtr(uri),
+						EncodingID:  encodingResp.Data.Result.ID,
+						StreamID:    audioStreamIDs[j],
+						MuxingID:    audioMuxingResp.Data.Result.ID,
+					}
+					audioStreamInfoResp, asiErr := hlsService.AddStreamInfo(manifestID, audioStreamInfo)
+					if asiErr != nil {
+						return nil, asiErr
+					}
+					if audioStreamInfoResp.Status == bitmovinAPIErrorMsg {
+						return nil, errors.New("Error in adding EXT-X-STREAM-INF")
+					}
+					continue
+				}
+
+				// create the MediaInfo, labeled with the language of
+				// the rendition, which defaults to English.
+				language, name := audio.language, audio.language
+				if language == "" {
+					language, name = "en", audio.configID
+				}
+				audioMediaInfo := &models.MediaInfo{
+					Type:            bitmovintypes.MediaTypeAudio,
+					URI:             stringToPtr(audio.configID + ".m3u8"),
+					GroupID:         stringToPtr(audioGroup),
+					Language:        stringToPtr(language),
+					Name:            stringToPtr(name),
+					IsDefault:       boolToPtr(false),
+					Autoselect:      boolToPtr(false),
+					Forced:          boolToPtr(false),
+					SegmentPath:     stringToPtr(audio.configID),
+					Characteristics: []string{"public.accessibility.describes-video"},
+					EncodingID:      encodingResp.Data.Result.ID,
+					StreamID:        audioStreamIDs[j],
+					MuxingID:        audioMuxingResp.Data.Result.ID,
+				}
+
+				// Add to Master manifest, we will set the m3u8 and segments relative to the master
+
+				audioMediaInfoResp, miErr := hlsService.AddMediaInfo(manifestID, audioMediaInfo)
+				if miErr != nil {
+					return nil, miErr
+				}
+				if audioMediaInfoResp.Status == bitmovinAPIErrorMsg {
+					return nil, errors.New("Error in adding EXT-X-MEDIA")
+				}
 			}
-			if audioMediaInfoResp.Status == bitmovinAPIErrorMsg {
-				return nil, errors.New("Error in adding EXT-X-MEDIA")
+			if data.audioOnly {
+				continue
 			}
 
 			videoMuxingOutput := models.Output{
@@ -550,7 +799,7 @@ func (p *bitmovinProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
 				Outputs:       []models.Output{videoMuxingOutput},
 			}
 			videoMuxingResp, vmuxErr := encodingS.AddTSMuxing(*encodingResp.Data.Result.ID, videoMuxing)
-			if err != nil {
+			if vmuxErr != nil {
 				return nil, vmuxErr
 			}
 			if videoMuxingResp.Status == bitmovinAPIErrorMsg {
@@ -558,11 +807,11 @@ func (p *bitmovinProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
 			}
 
 			videoStreamInfo := &models.StreamInfo{
-				Audio:       stringToPtr(audioPresetID),
+				Audio:       stringToPtr(audioGroup),
 				SegmentPath: stringToPtr(videoPresetID),
 				URI:         stringToPtr(filepath.Base(output.FileName)),
 				EncodingID:  encodingResp.Data.Result.ID,
-				StreamID:    videoStreamResp.Data.Result.ID,
+				StreamID:    videoStreamID,
 				MuxingID:    videoMuxingResp.Data.Result.ID,
 			}
 
@@ -573,19 +822,92 @@ func (p *bitmovinProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
 			if videoStreamInfoResp.Status == bitmovinAPIErrorMsg {
 				return nil, errors.New("Error in adding EXT-X-STREAM-INF")
 			}
-		} else if container == "mp4" {
+		} else if data.container == "mpd" {
+			type dashMuxing struct {
+				kind            string
+				stream          models.StreamItem
+				path            string
+				adaptationSetID string
+			}
+			var muxings []dashMuxing
+			for j, audio := range data.audio {
+				// audio renditions are grouped by language in the
+				// adaptation sets of the manifest, which defaults to
+				// English.
+				language := audio.language
+				if language == "" {
+					language = "en"
+				}
+				audioAdaptationSetID, ok := dashAudioAdaptationSetIDs[language]
+				if !ok {
+					audioAdaptationSet := &models.AudioAdaptationSet{Language: stringToPtr(language)}
+					audioAdaptationSetResp, aasErr := dashService.AddAudioAdaptationSet(dashManifestID, dashPeriodID, audioAdaptationSet)
+					if aasErr != nil {
+						return nil, aasErr
+					}
+					if audioAdaptationSetResp.Status == bitmovinAPIErrorMsg {
+						return nil, errors.New("Error in adding audio AdaptationSet to DASH Manifest")
+					}
+					audioAdaptationSetID = *audioAdaptationSetResp.Data.Result.ID
+					dashAudioAdaptationSetIDs[language] = audioAdaptationSetID
+				}
+				muxings = append(muxings, dashMuxing{"audio", audioMuxingStreams[j], audio.configID, audioAdaptationSetID})
+			}
+			if !data.audioOnly {
+				muxings = append(muxings, dashMuxing{"video", videoMuxingStream, videoPresetID, dashVideoAdaptationSetID})
+			}
+			for _, muxing := range muxings {
+				muxingOutput := models.Output{
+					OutputID:   s3OSResponse.Data.Result.ID,
+					OutputPath: stringToPtr(filepath.Join(dashManifestPath, muxing.path)),
+					ACL:        acl,
+				}
+				fmp4Muxing := &models.FMP4Muxing{
+					SegmentLength:   floatToPtr(float64(job.StreamingParams.SegmentDuration)),
+					SegmentNaming:   stringToPtr("seg_%number%.m4s"),
+					InitSegmentName: stringToPtr("init.mp4"),
+					Streams:         []models.StreamItem{muxing.stream},
+					Outputs:         []models.Output{muxingOutput},
+				}
+				muxingResp, muxErr := encodingS.AddFMP4Muxing(*encodingResp.Data.Result.ID, fmp4Muxing)
+				if muxErr != nil {
+					return nil, muxErr
+				}
+				if muxingResp.Status == bitmovinAPIErrorMsg {
+					return nil, fmt.Errorf("Error in adding fMP4 Muxing for %s", muxing.kind)
+				}
+
+				representation := &models.FMP4Representation{
+					Type:        bitmovintypes.FMP4RepresentationTypeTemplate,
+					MuxingID:    muxingResp.Data.Result.ID,
+					EncodingID:  encodingResp.Data.Result.ID,
+					SegmentPath: stringToPtr(muxing.path),
+				}
+				representationResp, repErr := dashService.AddFMP4Representation(dashManifestID, dashPeriodID, muxing.adaptationSetID, representation)
+				if repErr != nil {
+					return nil, repErr
+				}
+				if representationResp.Status == bitmovinAPIErrorMsg {
+					return nil, fmt.Errorf("Error in adding %s Representation to DASH Manifest", muxing.kind)
+				}
+			}
+		} else if data.container == "mp4" {
 			videoMuxingOutput := models.Output{
 				OutputID:   s3OSResponse.Data.Result.ID,
 				ACL:        acl,
-				OutputPath: stringToPtr(filepath.Dir(output.FileName)),
+				OutputPath: stringToPtr(filepath.Join(outputPrefix, filepath.Dir(output.FileName))),
+			}
+			streams := audioMuxingStreams
+			if !data.audioOnly {
+				streams = append([]models.StreamItem{videoMuxingStream}, audioMuxingStreams...)
 			}
 			videoMuxing := &models.MP4Muxing{
 				Filename: stringToPtr(filepath.Base(output.FileName)),
 				Outputs:  []models.Output{videoMuxingOutput},
-				Streams:  []models.StreamItem{videoMuxingStream, audioMuxingStream},
+				Streams:  streams,
 			}
 			videoMuxingResp, vmErr := encodingS.AddMP4Muxing(*encodingResp.Data.Result.ID, videoMuxing)
-			if err != nil {
+			if vmErr != nil {
 				return nil, vmErr
 			}
 			if videoMuxingResp.Status == bitmovinAPIErrorMsg {
@@ -635,66 +957,46 @@ func (p *bitmovinProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
 			return nil, errors.New("No Custom Data on Encoding, there should at least be container information here")
 		}
 		cd := cdResp.Data.Result.CustomData
-		i, ok := cd["manifest"]
-		if !ok {
-			// No manifest generation needed, we are finished
-			return &provider.JobStatus{
-				ProviderName:  Name,
-				ProviderJobID: job.ProviderJobID,
-				Status:        provider.StatusFinished,
-			}, nil
-		}
-		manifestID, ok := i.(string)
-		if !ok {
-			return nil, errors.New("Audio Configuration somehow not a string")
-		}
-		manifestS := services.NewHLSManifestService(p.client)
-		manifestStatusResp, err := manifestS.RetrieveStatus(manifestID)
-		if err != nil {
-			return nil, err
-		}
-		if *manifestStatusResp.Data.Result.Status == bitmovinAPIErrorMsg {
-			return &provider.JobStatus{
-				ProviderName:  Name,
-				ProviderJobID: job.ProviderJobID,
-				Status:        provider.StatusFailed,
-			}, nil
+		status := provider.StatusFinished
+		if manifestID, ok := cd["manifest"].(string); ok {
+			manifestS := services.NewHLSManifestService(p.client)
+			manifestStatusResp, err := manifestS.RetrieveStatus(manifestID)
+			if err != nil {
+				return nil, err
+			}
+			status, err = manifestStatus(status, *manifestStatusResp.Data.Result.Status, func() (string, error) {
+				startResp, err := manifestS.Start(manifestID)
+				if err != nil {
+					return "", err
+				}
+				return startResp.Status, nil
+			})
+			if err != nil {
+				return nil, err
+			}
 		}
-
-		if *manifestStatusResp.Data.Result.Status == "CREATED" {
-			// start the manifest generation
-			startResp, err := manifestS.Start(manifestID)
+		if manifestID, ok := cd["dashManifest"].(string); ok {
+			manifestS := services.NewDashManifestService(p.client)
+			manifestStatusResp, err := manifestS.RetrieveStatus(manifestID)
+			if err != nil {
+				return nil, err
+			}
+			status, err = manifestStatus(status, *manifestStatusResp.Data.Result.Status, func() (string, error) {
+				startResp, err := manifestS.Start(manifestID)
+				if err != nil {
+					return "", err
+				}
+				return startResp.Status, nil
+			})
 			if err != nil {
 				return nil, err
-			} else if startResp.Status == bitmovinAPIErrorMsg {
-				return &provider.JobStatus{
-					ProviderName:  Name,
-					ProviderJobID: job.ProviderJobID,
-					Status:        provider.StatusFailed,
-				}, nil
-			}
-			return &provider.JobStatus{
-				ProviderName:  Name,
-				ProviderJobID: job.ProviderJobID,
-				Status:        provider.StatusStarted,
-			}, nil
-		}
-
-		if *manifestStatusResp.Data.Result.Status == "QUEUED" || *manifestStatusResp.Data.Result.Status == "RUNNING" {
-			return &provider.JobStatus{
-				ProviderName:  Name,
-				ProviderJobID: job.ProviderJobID,
-				Status:        provider.StatusStarted,
-			}, nil
-		}
-
-		if *manifestStatusResp.Data.Result.Status == "FINISHED" {
-			return &provider.JobStatus{
-				ProviderName:  Name,
-				ProviderJobID: job.ProviderJobID,
-				Status:        provider.StatusFinished,
-			}, nil
+			}
 		}
+		return &provider.JobStatus{
+			ProviderName:  Name,
+			ProviderJobID: job.ProviderJobID,
+			Status:        status,
+		}, nil
 	} else if *statusResp.Data.Result.Status == "CREATED" || *statusResp.Data.Result.Status == "QUEUED" {
 		return &provider.JobStatus{
 			ProviderName:  Name,
@@ -716,6 +1018,46 @@ func (p *bitmovinProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
 	}, nil
 }
 
+// manifestStatus combines the status of a job whose encoding is finished with
+// the status of one of its manifests, starting the generation of the manifest
+// once it's created.
+func manifestStatus(status provider.Status, manifestStatus string, start func() (string, error)) (provider.Status, error) {
+	if status == provider.StatusFailed {
+		return status, nil
+	}
+	switch manifestStatus {
+	case "CREATED":
+		startStatus, err := start()
+		if err != nil {
+			return "", err
+		}
+		if startStatus == bitmovinAPIErrorMsg {
+			return provider.StatusFailed, nil
+		}
+		return provider.StatusStarted, nil
+	case "QUEUED", "RUNNING":
+		return provider.StatusStarted, nil
+	case "FINISHED":
+		return status, nil
+	default:
+		return provider.StatusFailed, nil
+	}
+}
+
+// manifestName returns the name of the manifest used for the given streaming
+// protocol. The playlist of jobs without streaming protocol is an HLS
+// playlist.
+func manifestName(params db.StreamingParams, protocol, defaultName string) string {
+	jobProtocol := params.Protocol
+	if jobProtocol == "" {
+		jobProtocol = "hls"
+	}
+	if jobProtocol == protocol && params.PlaylistFileName != "" {
+		return params.PlaylistFileName
+	}
+	return defaultName
+}
+
 func (p *bitmovinProvider) CancelJob(jobID string) error {
 	// stop the job
 	encodingS := services.NewEncodingService(p.client)
@@ -745,8 +1087,17 @@ func (p *bitmovinProvider) Healthcheck() error {
 func (p *bitmovinProvider) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
 		InputFormats:  []string{"prores", "h264"},
-		OutputFormats: []string{"mp4", "hls"},
+		OutputFormats: []string{"mp4", "hls", "dash"},
 		Destinations:  []string{"s3"},
+
+		Clipping: &provider.ClippingCapabilities{PerOutput: true},
+		PresetConstraints: &provider.PresetConstraints{
+			Containers:  []string{"mp4", "m3u8", "mpd"},
+			AudioCodecs: []string{"aac"},
+			VideoCodecs: map[string][]string{"h264": videoParameters},
+
+			AudioParameters: audioParameters,
+		},
 	}
 }
 
@@ -777,13 +1128,11 @@ func parseS3URL(input string) (bucketName string, path string, fileName string,
 	return "", "", "", errors.New("Could not parse S3 URL")
 }
 
-func grabBucketNameFromS3Destination(input string) (bucketName string, err error) {
-	if s3Pattern.MatchString(input) {
-		name := strings.TrimPrefix(input, "s3://")
-		name = strings.TrimSuffix(name, "/")
-		return name, err
-	}
-	return "", errors.New("Could not parse S3 URL")
+// parseS3Destination returns the bucket and the prefix of the given S3
+// destination, which is a directory whether or not it ends with a slash.
+func parseS3Destination(input string) (bucketName string, prefix string, err error) {
+	bucketName, prefix, _, err = parseS3URL(strings.TrimSuffix(input, "/") + "/")
+	return bucketName, prefix, err
 }
 
 func createInput(provider *bitmovinProvider, input string) (inputID string, path string, err error) {
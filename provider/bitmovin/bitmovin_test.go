@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/NYTimes/video-transcoding-api/config"
@@ -97,6 +99,106 @@ func TestCreatePreset(t *testing.T) {
 	}
 }
 
+func TestCreatePresetAudioParameters(t *testing.T) {
+	preset := getPreset()
+	preset.Audio.SampleRate = "44100"
+	preset.Audio.Language = "es"
+	var audioConfig models.AACCodecConfiguration
+	var videoConfig models.H264CodecConfiguration
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/encoding/configurations/audio/aac":
+			json.NewDecoder(r.Body).Decode(&audioConfig)
+			json.NewEncoder(w).Encode(models.AACCodecConfigurationResponse{
+				Status: bitmovintypes.ResponseStatusSuccess,
+				Data: models.AACCodecConfigurationData{
+					Result: models.AACCodecConfiguration{ID: stringToPtr("audio_config_uuid")},
+				},
+			})
+		case "/encoding/configurations/video/h264":
+			json.NewDecoder(r.Body).Decode(&videoConfig)
+			json.NewEncoder(w).Encode(models.H264CodecConfigurationResponse{
+				Status: bitmovintypes.ResponseStatusSuccess,
+				Data: models.H264CodecConfigurationData{
+					Result: models.H264CodecConfiguration{ID: stringToPtr("video_config_uuid")},
+				},
+			})
+		default:
+			t.Fatal(errors.New("unexpected path hit"))
+		}
+	}))
+	defer ts.Close()
+	prov := getBitmovinProvider(ts.URL)
+	if _, err := prov.CreatePreset(preset); err != nil {
+		t.Fatal(err)
+	}
+	if audioConfig.SamplingRate == nil || *audioConfig.SamplingRate != 44100 {
+		t.Errorf("wrong sampling rate. Want 44100. Got %v", audioConfig.SamplingRate)
+	}
+	if language := videoConfig.CustomData["language"]; language != "es" {
+		t.Errorf("wrong language in custom data. Want %q. Got %v", "es", language)
+	}
+}
+
+func TestCreatePresetUnsupportedAudioParameter(t *testing.T) {
+	preset := getPreset()
+	preset.Audio.Loudness = "-23"
+	prov := getBitmovinProvider("http://localhost")
+	_, err := prov.CreatePreset(preset)
+	expected := provider.UnsupportedParameterError{Parameter: "audio.loudness", Value: "-23"}
+	if !reflect.DeepEqual(err, expected) {
+		t.Errorf("wrong error returned. Want %#v. Got %#v", expected, err)
+	}
+}
+
+func TestCreateAudioOnlyPresetWithAudioTracks(t *testing.T) {
+	preset := getPreset()
+	preset.Container = "m3u8"
+	preset.Video = db.VideoPreset{}
+	preset.Audio.Language = "en"
+	preset.AudioTracks = []db.AudioPreset{{Codec: "aac", Bitrate: "96000", Language: "es"}}
+	var audioConfigs []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/encoding/configurations/audio/aac":
+			var audioConfig map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&audioConfig)
+			audioConfigs = append(audioConfigs, audioConfig)
+			json.NewEncoder(w).Encode(models.AACCodecConfigurationResponse{
+				Status: bitmovintypes.ResponseStatusSuccess,
+				Data: models.AACCodecConfigurationData{
+					Result: models.AACCodecConfiguration{ID: stringToPtr(fmt.Sprintf("audio_config_%d", len(audioConfigs)))},
+				},
+			})
+		default:
+			t.Fatal(errors.New("unexpected path hit " + r.URL.Path))
+		}
+	}))
+	defer ts.Close()
+	prov := getBitmovinProvider(ts.URL)
+	presetID, err := prov.CreatePreset(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if presetID != "audio_config_2" {
+		t.Errorf("wrong preset id. Want %q. Got %q", "audio_config_2", presetID)
+	}
+	if len(audioConfigs) != 2 {
+		t.Fatalf("wrong number of audio configurations. Want 2. Got %d", len(audioConfigs))
+	}
+	if name := audioConfigs[0]["name"]; name != "mp4_1080p_track1" {
+		t.Errorf("wrong name of the audio track configuration. Want %q. Got %v", "mp4_1080p_track1", name)
+	}
+	expectedCustomData := map[string]interface{}{
+		"container":   "m3u8",
+		"language":    "en",
+		"audioTracks": []interface{}{map[string]interface{}{"audio": "audio_config_1", "language": "es"}},
+	}
+	if customData := audioConfigs[1]["customData"]; !reflect.DeepEqual(customData, expectedCustomData) {
+		t.Errorf("wrong custom data\nwant %#v\ngot  %#v", expectedCustomData, customData)
+	}
+}
+
 func TestCreatePresetFailsOnAPIError(t *testing.T) {
 	preset := getPreset()
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -184,6 +286,11 @@ func TestDeletePresetFailsOnAPIError(t *testing.T) {
 				Status: bitmovintypes.ResponseStatusError,
 			}
 			json.NewEncoder(w).Encode(resp)
+		case "/encoding/configurations/audio/aac/" + testPresetID + "/customData":
+			resp := models.AACCodecConfigurationResponse{
+				Status: bitmovintypes.ResponseStatusError,
+			}
+			json.NewEncoder(w).Encode(resp)
 		default:
 			t.Fatal(errors.New("unexpected path hit"))
 		}
@@ -269,6 +376,11 @@ func TestGetPresetFailsOnAPIError(t *testing.T) {
 				Status: bitmovintypes.ResponseStatusError,
 			}
 			json.NewEncoder(w).Encode(resp)
+		case "/encoding/configurations/audio/aac/" + testPresetID:
+			resp := models.AACCodecConfigurationResponse{
+				Status: bitmovintypes.ResponseStatusError,
+			}
+			json.NewEncoder(w).Encode(resp)
 		default:
 			t.Fatal(errors.New("unexpected path hit"))
 		}
@@ -305,6 +417,66 @@ func TestGetPresetFailsOnGenericError(t *testing.T) {
 	}
 }
 
+func TestNormalizePreset(t *testing.T) {
+	prov := getBitmovinProvider("https://api.bitmovin.com/v1")
+	preset := getPreset()
+	preset.Audio.Language = "en"
+	preset.Video.FrameRate = "29.97"
+	expected := db.Preset{
+		Container: "mp4",
+		Video:     db.VideoPreset{Profile: "main", ProfileLevel: "3.1", Height: "1080", Codec: "h264", Bitrate: "3500000", GopSize: "90", FrameRate: "29.97"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "128000", SampleRate: "48000", Language: "en"},
+	}
+	normalized, err := prov.NormalizePreset(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(normalized, expected) {
+		t.Errorf("wrong normalized preset\nwant %#v\ngot  %#v", expected, normalized)
+	}
+
+	// the preset is changed in the dashboard of Bitmovin.
+	customData := map[string]interface{}{"container": "mp4", "language": "en", "audio": "audio_config_id"}
+	video, err := prov.createVideoPreset(preset, customData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	video.Bitrate = intToPtr(1800000)
+	video.Height = intToPtr(480)
+	audio, err := aacConfiguration(preset.Name, preset.Audio, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized, err = prov.NormalizePreset(bitmovinPreset{Video: *video, Audio: *audio})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedDiffs := []db.PresetFieldDiff{
+		{Field: "video.height", From: "1080", To: "480"},
+		{Field: "video.bitrate", From: "3500000", To: "1800000"},
+	}
+	if diffs := db.DiffPresets(expected, normalized); !reflect.DeepEqual(diffs, expectedDiffs) {
+		t.Errorf("wrong differences\nwant %#v\ngot  %#v", expectedDiffs, diffs)
+	}
+
+	audioOnly := db.Preset{Name: "audio", Container: "m3u8", Audio: db.AudioPreset{Codec: "aac", Bitrate: "64000"}}
+	audio, err = aacConfiguration(audioOnly.Name, audioOnly.Audio, map[string]interface{}{"container": "m3u8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = db.Preset{Container: "m3u8", Audio: db.AudioPreset{Codec: "aac", Bitrate: "64000", SampleRate: "48000"}}
+	normalized, err = prov.NormalizePreset(bitmovinPreset{Audio: *audio})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(normalized, expected) {
+		t.Errorf("wrong normalized audio-only preset\nwant %#v\ngot  %#v", expected, normalized)
+	}
+	if _, err = prov.NormalizePreset("mp4_1080p"); err == nil {
+		t.Error("unexpected <nil> error for invalid preset type")
+	}
+}
+
 func TestTranscodeWithS3Input(t *testing.T) {
 	s3InputID := "this_is_the_s3_input_id"
 	s3OutputID := "this_is_the_s3_output_id"
@@ -458,6 +630,424 @@ func TestTranscodeWithS3Input(t *testing.T) {
 	}
 }
 
+func TestTranscodeWithDASHOutputs(t *testing.T) {
+	s3OutputID := "this_is_the_s3_output_id"
+	encodingID := "this_is_the_master_encoding_id"
+	manifestID := "this_is_the_dash_manifest_id"
+	periodID := "this_is_the_period_id"
+	manifestPath := "/encoding/manifests/dash/" + manifestID + "/periods/" + periodID + "/adaptationsets/"
+	idResponse := func(id string) map[string]interface{} {
+		return map[string]interface{}{
+			"status": bitmovintypes.ResponseStatusSuccess,
+			"data":   map[string]interface{}{"result": map[string]interface{}{"id": id}},
+		}
+	}
+	var manifest map[string]interface{}
+	var muxingPaths, representations []string
+	var audioAdaptationSets int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/encoding/inputs/http":
+			json.NewEncoder(w).Encode(idResponse("this_is_the_http_input_id"))
+		case "/encoding/outputs/s3":
+			json.NewEncoder(w).Encode(idResponse(s3OutputID))
+		case "/encoding/configurations/video/h264/videoID1/customData",
+			"/encoding/configurations/video/h264/videoID2/customData":
+			videoID := strings.Split(r.URL.Path, "/")[5]
+			customData := map[string]interface{}{"audio": "audio" + videoID, "container": "mpd"}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": bitmovintypes.ResponseStatusSuccess,
+				"data":   map[string]interface{}{"result": map[string]interface{}{"customData": customData}},
+			})
+		case "/encoding/configurations/video/h264/videoID1",
+			"/encoding/configurations/video/h264/videoID2":
+			json.NewEncoder(w).Encode(idResponse(""))
+		case "/encoding/manifests/dash":
+			manifest = body
+			json.NewEncoder(w).Encode(idResponse(manifestID))
+		case "/encoding/manifests/dash/" + manifestID + "/periods":
+			json.NewEncoder(w).Encode(idResponse(periodID))
+		case manifestPath + "video":
+			json.NewEncoder(w).Encode(idResponse("video_set"))
+		case manifestPath + "audio":
+			audioAdaptationSets++
+			json.NewEncoder(w).Encode(idResponse(fmt.Sprintf("audio_set_%v", body["lang"])))
+		case manifestPath + "video_set/representations/fmp4", manifestPath + "audio_set_en/representations/fmp4":
+			representations = append(representations, strings.Split(r.URL.Path, "/")[8]+":"+body["segmentPath"].(string))
+			json.NewEncoder(w).Encode(idResponse("this_is_a_representation_id"))
+		case "/encoding/encodings":
+			json.NewEncoder(w).Encode(idResponse(encodingID))
+		case "/encoding/encodings/" + encodingID + "/streams":
+			json.NewEncoder(w).Encode(idResponse("this_is_a_stream_id"))
+		case "/encoding/encodings/" + encodingID + "/muxings/fmp4":
+			output := body["outputs"].([]interface{})[0].(map[string]interface{})
+			muxingPaths = append(muxingPaths, output["outputPath"].(string))
+			json.NewEncoder(w).Encode(idResponse("this_is_a_fmp4_muxing_id"))
+		case "/encoding/encodings/" + encodingID + "/start":
+			json.NewEncoder(w).Encode(idResponse(""))
+		default:
+			t.Fatal(errors.New("unexpected path hit " + r.URL.Path))
+		}
+	}))
+	defer ts.Close()
+	prov := getBitmovinProvider(ts.URL)
+	job := &db.Job{
+		ProviderName: Name,
+		SourceMedia:  "http://host/folder/filename.mp4",
+		StreamingParams: db.StreamingParams{
+			Protocol:         "dash",
+			SegmentDuration:  4,
+			PlaylistFileName: "dash/manifest.mpd",
+		},
+		Outputs: []db.TranscodeOutput{
+			{Preset: db.PresetMap{Name: "dash_1080p", ProviderMapping: map[string]string{Name: "videoID1"}}, FileName: "dash/1080p.mpd"},
+			{Preset: db.PresetMap{Name: "dash_480p", ProviderMapping: map[string]string{Name: "videoID2"}}, FileName: "dash/480p.mpd"},
+		},
+	}
+	jobStatus, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobStatus.ProviderJobID != encodingID {
+		t.Errorf("wrong provider job id. Want %q. Got %q", encodingID, jobStatus.ProviderJobID)
+	}
+	if name := manifest["manifestName"]; name != "manifest.mpd" {
+		t.Errorf("wrong manifest name. Want %q. Got %v", "manifest.mpd", name)
+	}
+	if audioAdaptationSets != 1 {
+		t.Errorf("wrong number of audio adaptation sets. Want 1. Got %d", audioAdaptationSets)
+	}
+	expectedMuxingPaths := []string{"dash/audiovideoID1", "dash/videoID1", "dash/audiovideoID2", "dash/videoID2"}
+	if !reflect.DeepEqual(muxingPaths, expectedMuxingPaths) {
+		t.Errorf("wrong muxing paths\nwant %q\ngot  %q", expectedMuxingPaths, muxingPaths)
+	}
+	expectedRepresentations := []string{
+		"audio_set_en:audiovideoID1", "video_set:videoID1",
+		"audio_set_en:audiovideoID2", "video_set:videoID2",
+	}
+	if !reflect.DeepEqual(representations, expectedRepresentations) {
+		t.Errorf("wrong representations\nwant %q\ngot  %q", expectedRepresentations, representations)
+	}
+}
+
+func TestTranscodeWithAlternateAudio(t *testing.T) {
+	s3OutputID := "this_is_the_s3_output_id"
+	encodingID := "this_is_the_master_encoding_id"
+	manifestID := "this_is_the_hls_manifest_id"
+	idResponse := func(id string) map[string]interface{} {
+		return map[string]interface{}{
+			"status": bitmovintypes.ResponseStatusSuccess,
+			"data":   map[string]interface{}{"result": map[string]interface{}{"id": id}},
+		}
+	}
+	customDataResponse := func(customData map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"status": bitmovintypes.ResponseStatusSuccess,
+			"data":   map[string]interface{}{"result": map[string]interface{}{"customData": customData}},
+		}
+	}
+	var streams, mediaInfos, streamInfos []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/encoding/inputs/s3":
+			json.NewEncoder(w).Encode(idResponse("this_is_the_s3_input_id"))
+		case "/encoding/outputs/s3":
+			json.NewEncoder(w).Encode(idResponse(s3OutputID))
+		case "/encoding/configurations/video/h264/videoID1/customData":
+			json.NewEncoder(w).Encode(customDataResponse(map[string]interface{}{
+				"audio":       "audioConfigID1",
+				"container":   "m3u8",
+				"audioTracks": []interface{}{map[string]interface{}{"audio": "trackConfigID1", "language": "es"}},
+			}))
+		case "/encoding/configurations/video/h264/audioID1/customData":
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": bitmovintypes.ResponseStatusError})
+		case "/encoding/configurations/audio/aac/audioID1/customData":
+			json.NewEncoder(w).Encode(customDataResponse(map[string]interface{}{"container": "m3u8", "language": "fr"}))
+		case "/encoding/configurations/video/h264/videoID1":
+			json.NewEncoder(w).Encode(idResponse("videoID1"))
+		case "/encoding/manifests/hls":
+			json.NewEncoder(w).Encode(idResponse(manifestID))
+		case "/encoding/encodings":
+			json.NewEncoder(w).Encode(idResponse(encodingID))
+		case "/encoding/encodings/" + encodingID + "/streams":
+			streams = append(streams, body)
+			json.NewEncoder(w).Encode(idResponse(fmt.Sprintf("stream_%d", len(streams))))
+		case "/encoding/encodings/" + encodingID + "/muxings/ts":
+			json.NewEncoder(w).Encode(idResponse("this_is_a_ts_muxing_id"))
+		case "/encoding/manifests/hls/" + manifestID + "/media":
+			mediaInfos = append(mediaInfos, body)
+			json.NewEncoder(w).Encode(idResponse("this_is_a_media_info_id"))
+		case "/encoding/manifests/hls/" + manifestID + "/streams":
+			streamInfos = append(streamInfos, body)
+			json.NewEncoder(w).Encode(idResponse("this_is_a_stream_info_id"))
+		case "/encoding/encodings/" + encodingID + "/start":
+			json.NewEncoder(w).Encode(idResponse(""))
+		default:
+			t.Fatal(errors.New("unexpected path hit " + r.URL.Path))
+		}
+	}))
+	defer ts.Close()
+	prov := getBitmovinProvider(ts.URL)
+	job := &db.Job{
+		ProviderName: Name,
+		SourceMedia:  "s3://bucket/folder/filename.mp4",
+		StreamingParams: db.StreamingParams{
+			Protocol:         "hls",
+			SegmentDuration:  4,
+			PlaylistFileName: "hls/master.m3u8",
+		},
+		Outputs: []db.TranscodeOutput{
+			{Preset: db.PresetMap{Name: "hls_1080p", ProviderMapping: map[string]string{Name: "videoID1"}}, FileName: "hls/1080p.m3u8"},
+			{Preset: db.PresetMap{Name: "hls_audio", ProviderMapping: map[string]string{Name: "audioID1"}}, FileName: "hls/audio.m3u8"},
+		},
+	}
+	if _, err := prov.Transcode(job); err != nil {
+		t.Fatal(err)
+	}
+	var streamConfigs []string
+	for _, stream := range streams {
+		streamConfigs = append(streamConfigs, stream["codecConfigId"].(string))
+	}
+	expectedStreamConfigs := []string{"audioConfigID1", "trackConfigID1", "videoID1", "audioID1"}
+	if !reflect.DeepEqual(streamConfigs, expectedStreamConfigs) {
+		t.Fatalf("wrong streams\nwant %q\ngot  %q", expectedStreamConfigs, streamConfigs)
+	}
+	trackInput := streams[1]["inputStreams"].([]interface{})[0].(map[string]interface{})
+	if mode := trackInput["selectionMode"]; mode != string(bitmovintypes.SelectionModeAudioRelative) {
+		t.Errorf("wrong selection mode of the audio track. Want %q. Got %v", bitmovintypes.SelectionModeAudioRelative, mode)
+	}
+	if position := trackInput["position"]; position != 1.0 {
+		t.Errorf("wrong position of the audio track. Want 1. Got %v", position)
+	}
+	var renditions []string
+	for _, mediaInfo := range mediaInfos {
+		renditions = append(renditions, fmt.Sprintf("%v:%v:%v", mediaInfo["groupId"], mediaInfo["language"], mediaInfo["uri"]))
+	}
+	expectedRenditions := []string{
+		"audio:en:audioConfigID1.m3u8",
+		"audio:es:trackConfigID1.m3u8",
+		"audio:fr:audioID1.m3u8",
+	}
+	if !reflect.DeepEqual(renditions, expectedRenditions) {
+		t.Errorf("wrong audio renditions\nwant %q\ngot  %q", expectedRenditions, renditions)
+	}
+	if len(streamInfos) != 1 {
+		t.Fatalf("wrong number of variants. Want 1. Got %d", len(streamInfos))
+	}
+	if audio := streamInfos[0]["audio"]; audio != "audio" {
+		t.Errorf("wrong audio group of the variant. Want %q. Got %v", "audio", audio)
+	}
+}
+
+func TestTranscodeWithClips(t *testing.T) {
+	encodingID := "this_is_the_master_encoding_id"
+	idResponse := func(id string) map[string]interface{} {
+		return map[string]interface{}{
+			"status": bitmovintypes.ResponseStatusSuccess,
+			"data":   map[string]interface{}{"result": map[string]interface{}{"id": id}},
+		}
+	}
+	var ingests, trimmings, streams []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/encoding/inputs/s3":
+			json.NewEncoder(w).Encode(idResponse("this_is_the_s3_input_id"))
+		case "/encoding/outputs/s3":
+			json.NewEncoder(w).Encode(idResponse("this_is_the_s3_output_id"))
+		case "/encoding/configurations/video/h264/videoID1/customData",
+			"/encoding/configurations/video/h264/videoID2/customData":
+			videoID := strings.Split(r.URL.Path, "/")[5]
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": bitmovintypes.ResponseStatusSuccess,
+				"data": map[string]interface{}{"result": map[string]interface{}{
+					"customData": map[string]interface{}{"audio": "audio" + videoID, "container": "mp4"},
+				}},
+			})
+		case "/encoding/configurations/video/h264/videoID1",
+			"/encoding/configurations/video/h264/videoID2":
+			json.NewEncoder(w).Encode(idResponse(""))
+		case "/encoding/encodings":
+			json.NewEncoder(w).Encode(idResponse(encodingID))
+		case "/encoding/encodings/" + encodingID + "/input-streams/ingest":
+			ingests = append(ingests, body)
+			json.NewEncoder(w).Encode(idResponse(fmt.Sprintf("ingest_%d", len(ingests))))
+		case "/encoding/encodings/" + encodingID + "/input-streams/trimming/time-based":
+			trimmings = append(trimmings, body)
+			json.NewEncoder(w).Encode(idResponse(fmt.Sprintf("trimmed_%d", len(trimmings))))
+		case "/encoding/encodings/" + encodingID + "/streams":
+			streams = append(streams, body)
+			json.NewEncoder(w).Encode(idResponse(fmt.Sprintf("stream_%d", len(streams))))
+		case "/encoding/encodings/" + encodingID + "/muxings/mp4":
+			json.NewEncoder(w).Encode(idResponse("this_is_a_mp4_muxing_id"))
+		case "/encoding/encodings/" + encodingID + "/start":
+			json.NewEncoder(w).Encode(idResponse(""))
+		default:
+			t.Fatal(errors.New("unexpected path hit " + r.URL.Path))
+		}
+	}))
+	defer ts.Close()
+	prov := getBitmovinProvider(ts.URL)
+	job := &db.Job{
+		ProviderName: Name,
+		SourceMedia:  "s3://bucket/folder/filename.mp4",
+		Clip:         &db.Clip{Start: "10", End: "40"},
+		Outputs: []db.TranscodeOutput{
+			{Preset: db.PresetMap{Name: "mp4_1080p", ProviderMapping: map[string]string{Name: "videoID1"}}, FileName: "1080p.mp4"},
+			{Preset: db.PresetMap{Name: "mp4_720p", ProviderMapping: map[string]string{Name: "videoID2"}}, FileName: "720p.mp4"},
+			{Preset: db.PresetMap{Name: "mp4_1080p", ProviderMapping: map[string]string{Name: "videoID1"}}, FileName: "end.mp4", Clip: &db.Clip{Start: "60"}},
+		},
+	}
+	if _, err := prov.Transcode(job); err != nil {
+		t.Fatal(err)
+	}
+	var ingestModes []string
+	for _, ingest := range ingests {
+		if ingest["inputId"] != "this_is_the_s3_input_id" {
+			t.Errorf("wrong input of ingest input stream: %v", ingest["inputId"])
+		}
+		ingestModes = append(ingestModes, ingest["selectionMode"].(string))
+	}
+	auto := string(bitmovintypes.SelectionModeAuto)
+	if expected := []string{auto, auto, auto, auto}; !reflect.DeepEqual(ingestModes, expected) {
+		t.Errorf("wrong ingest input streams\nwant %q\ngot  %q", expected, ingestModes)
+	}
+	expectedTrimmings := []map[string]interface{}{
+		{"inputStreamId": "ingest_1", "offset": 10.0, "duration": 30.0},
+		{"inputStreamId": "ingest_2", "offset": 10.0, "duration": 30.0},
+		{"inputStreamId": "ingest_3", "offset": 60.0},
+		{"inputStreamId": "ingest_4", "offset": 60.0},
+	}
+	if !reflect.DeepEqual(trimmings, expectedTrimmings) {
+		t.Errorf("wrong trimming input streams\nwant %#v\ngot  %#v", expectedTrimmings, trimmings)
+	}
+	var streamInputs []string
+	for _, stream := range streams {
+		input := stream["inputStreams"].([]interface{})[0].(map[string]interface{})
+		streamInputs = append(streamInputs, fmt.Sprintf("%v:%v", stream["codecConfigId"], input["inputStreamId"]))
+	}
+	expectedStreamInputs := []string{
+		"audiovideoID1:trimmed_1", "videoID1:trimmed_2",
+		"audiovideoID2:trimmed_1", "videoID2:trimmed_2",
+		"audiovideoID1:trimmed_3", "videoID1:trimmed_4",
+	}
+	if !reflect.DeepEqual(streamInputs, expectedStreamInputs) {
+		t.Errorf("wrong input streams of the streams\nwant %q\ngot  %q", expectedStreamInputs, streamInputs)
+	}
+}
+
+func TestTranscodeWithPrefixedDestination(t *testing.T) {
+	s3OutputID := "this_is_the_s3_output_id"
+	encodingID := "this_is_the_master_encoding_id"
+	hlsManifestID := "this_is_the_hls_manifest_id"
+	dashManifestID := "this_is_the_dash_manifest_id"
+	periodID := "this_is_the_period_id"
+	adaptationSetsPath := "/encoding/manifests/dash/" + dashManifestID + "/periods/" + periodID + "/adaptationsets/"
+	idResponse := func(id string) map[string]interface{} {
+		return map[string]interface{}{
+			"status": bitmovintypes.ResponseStatusSuccess,
+			"data":   map[string]interface{}{"result": map[string]interface{}{"id": id}},
+		}
+	}
+	containers := map[string]string{"videoID1": "m3u8", "videoID2": "mpd", "videoID3": "mp4"}
+	var bucketName interface{}
+	var outputPaths []string
+	addOutputPath := func(body map[string]interface{}) {
+		output := body["outputs"].([]interface{})[0].(map[string]interface{})
+		outputPaths = append(outputPaths, output["outputPath"].(string))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/encoding/inputs/http":
+			json.NewEncoder(w).Encode(idResponse("this_is_the_http_input_id"))
+		case "/encoding/outputs/s3":
+			bucketName = body["bucketName"]
+			json.NewEncoder(w).Encode(idResponse(s3OutputID))
+		case "/encoding/configurations/video/h264/videoID1/customData",
+			"/encoding/configurations/video/h264/videoID2/customData",
+			"/encoding/configurations/video/h264/videoID3/customData":
+			videoID := strings.Split(r.URL.Path, "/")[5]
+			customData := map[string]interface{}{"audio": "audio" + videoID, "container": containers[videoID]}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": bitmovintypes.ResponseStatusSuccess,
+				"data":   map[string]interface{}{"result": map[string]interface{}{"customData": customData}},
+			})
+		case "/encoding/configurations/video/h264/videoID1",
+			"/encoding/configurations/video/h264/videoID2",
+			"/encoding/configurations/video/h264/videoID3":
+			json.NewEncoder(w).Encode(idResponse(strings.Split(r.URL.Path, "/")[5]))
+		case "/encoding/manifests/hls":
+			addOutputPath(body)
+			json.NewEncoder(w).Encode(idResponse(hlsManifestID))
+		case "/encoding/manifests/hls/" + hlsManifestID + "/media",
+			"/encoding/manifests/hls/" + hlsManifestID + "/streams":
+			json.NewEncoder(w).Encode(idResponse("this_is_a_hls_info_id"))
+		case "/encoding/manifests/dash":
+			addOutputPath(body)
+			json.NewEncoder(w).Encode(idResponse(dashManifestID))
+		case "/encoding/manifests/dash/" + dashManifestID + "/periods":
+			json.NewEncoder(w).Encode(idResponse(periodID))
+		case adaptationSetsPath + "video":
+			json.NewEncoder(w).Encode(idResponse("video_set"))
+		case adaptationSetsPath + "audio":
+			json.NewEncoder(w).Encode(idResponse("audio_set"))
+		case adaptationSetsPath + "video_set/representations/fmp4", adaptationSetsPath + "audio_set/representations/fmp4":
+			json.NewEncoder(w).Encode(idResponse("this_is_a_representation_id"))
+		case "/encoding/encodings":
+			json.NewEncoder(w).Encode(idResponse(encodingID))
+		case "/encoding/encodings/" + encodingID + "/streams":
+			json.NewEncoder(w).Encode(idResponse("this_is_a_stream_id"))
+		case "/encoding/encodings/" + encodingID + "/muxings/ts",
+			"/encoding/encodings/" + encodingID + "/muxings/fmp4",
+			"/encoding/encodings/" + encodingID + "/muxings/mp4":
+			addOutputPath(body)
+			json.NewEncoder(w).Encode(idResponse("this_is_a_muxing_id"))
+		case "/encoding/encodings/" + encodingID + "/start":
+			json.NewEncoder(w).Encode(idResponse(""))
+		default:
+			t.Fatal(errors.New("unexpected path hit " + r.URL.Path))
+		}
+	}))
+	defer ts.Close()
+	prov := getBitmovinProvider(ts.URL)
+	job := &db.Job{
+		ProviderName: Name,
+		SourceMedia:  "http://host/folder/filename.mp4",
+		Destination:  "s3://output-bucket/some/prefix/",
+		Outputs: []db.TranscodeOutput{
+			{Preset: db.PresetMap{Name: "hls_1080p", ProviderMapping: map[string]string{Name: "videoID1"}}, FileName: "hls/1080p.m3u8"},
+			{Preset: db.PresetMap{Name: "dash_1080p", ProviderMapping: map[string]string{Name: "videoID2"}}, FileName: "dash/1080p.mpd"},
+			{Preset: db.PresetMap{Name: "mp4_1080p", ProviderMapping: map[string]string{Name: "videoID3"}}, FileName: "mp4/1080p.mp4"},
+		},
+	}
+	if _, err := prov.Transcode(job); err != nil {
+		t.Fatal(err)
+	}
+	if bucketName != "output-bucket" {
+		t.Errorf("wrong bucket name. Want %q. Got %v", "output-bucket", bucketName)
+	}
+	expectedOutputPaths := []string{
+		"some/prefix/dash",
+		"some/prefix/dash/audiovideoID2",
+		"some/prefix/dash/videoID2",
+		"some/prefix/hls",
+		"some/prefix/hls/audiovideoID1",
+		"some/prefix/hls/videoID1",
+		"some/prefix/mp4",
+	}
+	sort.Strings(outputPaths)
+	if !reflect.DeepEqual(outputPaths, expectedOutputPaths) {
+		t.Errorf("wrong output paths\nwant %q\ngot  %q", expectedOutputPaths, outputPaths)
+	}
+}
+
 func TestTranscodeWithHTTPInput(t *testing.T) {
 	httpInputID := "this_is_the_s3_input_id"
 	s3OutputID := "this_is_the_s3_output_id"
@@ -1024,6 +1614,74 @@ func TestJobStatusReturnsStartedIfEncodeIsFinishedAndManifestIsCreatedAndStartRe
 	}
 }
 
+func TestJobStatusStartsTheDASHManifest(t *testing.T) {
+	testJobID := "this_is_a_job_id"
+	manifestID := "this_is_the_hls_manifest_id"
+	dashManifestID := "this_is_the_dash_manifest_id"
+	customData := map[string]interface{}{"manifest": manifestID, "dashManifest": dashManifestID}
+	var dashManifestStarted bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/encoding/encodings/" + testJobID + "/status",
+			"/encoding/manifests/hls/" + manifestID + "/status":
+			resp := models.StatusResponse{
+				Status: bitmovintypes.ResponseStatusSuccess,
+				Data: models.StatusData{
+					Result: models.StatusResult{
+						Status: stringToPtr("FINISHED"),
+					},
+				},
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/encoding/encodings/" + testJobID + "/customData":
+			resp := models.CustomDataResponse{
+				Data: models.Data{
+					Result: models.Result{
+						CustomData: customData,
+					},
+				},
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/encoding/manifests/dash/" + dashManifestID + "/status":
+			status := "CREATED"
+			if dashManifestStarted {
+				status = "FINISHED"
+			}
+			resp := models.StatusResponse{
+				Status: bitmovintypes.ResponseStatusSuccess,
+				Data: models.StatusData{
+					Result: models.StatusResult{
+						Status: stringToPtr(status),
+					},
+				},
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/encoding/manifests/dash/" + dashManifestID + "/start":
+			dashManifestStarted = true
+			resp := models.StartStopResponse{
+				Status: bitmovintypes.ResponseStatusSuccess,
+			}
+			json.NewEncoder(w).Encode(resp)
+		default:
+			t.Fatal(errors.New("unexpected path hit " + r.URL.Path))
+		}
+	}))
+	defer ts.Close()
+	prov := getBitmovinProvider(ts.URL)
+	for _, expectedStatus := range []provider.Status{provider.StatusStarted, provider.StatusFinished} {
+		jobStatus, err := prov.JobStatus(&db.Job{ID: "job-123", ProviderJobID: testJobID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if jobStatus.Status != expectedStatus {
+			t.Errorf("wrong job status. Want %q. Got %q", expectedStatus, jobStatus.Status)
+		}
+	}
+	if !dashManifestStarted {
+		t.Error("the DASH manifest wasn't started")
+	}
+}
+
 func TestJobStatusReturnsQueuedIfEncodeIsCreated(t *testing.T) {
 	testJobID := "this_is_a_job_id"
 	manifestID := "this_is_the_underlying_manifest_id"
@@ -1369,8 +2027,17 @@ func TestCapabilities(t *testing.T) {
 	var prov bitmovinProvider
 	expected := provider.Capabilities{
 		InputFormats:  []string{"prores", "h264"},
-		OutputFormats: []string{"mp4", "hls"},
+		OutputFormats: []string{"mp4", "hls", "dash"},
 		Destinations:  []string{"s3"},
+
+		Clipping: &provider.ClippingCapabilities{PerOutput: true},
+		PresetConstraints: &provider.PresetConstraints{
+			Containers:  []string{"mp4", "m3u8", "mpd"},
+			AudioCodecs: []string{"aac"},
+			VideoCodecs: map[string][]string{"h264": {"frameRate"}},
+
+			AudioParameters: []string{"sampleRate", "language", "audioOnly", "audioTracks"},
+		},
 	}
 	cap := prov.Capabilities()
 	if !reflect.DeepEqual(cap, expected) {
@@ -0,0 +1,89 @@
+package bitmovin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/bitmovin/bitmovin-go/models"
+)
+
+func (p *bitmovinProvider) NormalizePreset(preset interface{}) (db.Preset, error) {
+	var normalized db.Preset
+	switch preset := preset.(type) {
+	case db.Preset:
+		if err := checkPreset(preset); err != nil {
+			return db.Preset{}, err
+		}
+		audio, err := aacConfiguration(preset.Name, preset.Audio, nil)
+		if err != nil {
+			return db.Preset{}, err
+		}
+		normalized = db.Preset{Container: preset.Container, Audio: normalizeAudio(*audio, preset.Audio.Language)}
+		if !preset.AudioOnly() {
+			video, err := p.createVideoPreset(preset, nil)
+			if err != nil {
+				return db.Preset{}, err
+			}
+			normalized.Video = normalizeVideo(*video)
+		}
+	case bitmovinPreset:
+		// the custom data of audio-only presets is kept in their AAC
+		// configuration.
+		customData := preset.Video.CustomData
+		if customData == nil {
+			customData = preset.Audio.CustomData
+		}
+		container, _ := customData["container"].(string)
+		language, _ := customData["language"].(string)
+		normalized = db.Preset{Container: container, Audio: normalizeAudio(preset.Audio, language)}
+		if preset.Video.CustomData != nil {
+			normalized.Video = normalizeVideo(preset.Video)
+		}
+	default:
+		return db.Preset{}, fmt.Errorf("unexpected preset type %T", preset)
+	}
+	return normalized, nil
+}
+
+// normalizeVideo translates the given H.264 configuration back to the video
+// parameters of a preset, reverting createVideoPreset.
+func normalizeVideo(config models.H264CodecConfiguration) db.VideoPreset {
+	return db.VideoPreset{
+		Codec:        "h264",
+		Profile:      strings.ToLower(string(config.Profile)),
+		ProfileLevel: string(config.Level),
+		Width:        formatInt(config.Width),
+		Height:       formatInt(config.Height),
+		Bitrate:      formatInt(config.Bitrate),
+		GopSize:      formatInt(config.MaxGOP),
+		FrameRate:    formatFloat(config.Rate),
+	}
+}
+
+// normalizeAudio translates the given AAC configuration back to the audio
+// parameters of a preset, reverting aacConfiguration.
+func normalizeAudio(config models.AACCodecConfiguration, language string) db.AudioPreset {
+	audio := db.AudioPreset{
+		Codec:    "aac",
+		Bitrate:  formatInt(config.Bitrate),
+		Language: language,
+	}
+	audio.SampleRate = formatFloat(config.SamplingRate)
+	return audio
+}
+
+func formatInt(value *int64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatInt(*value, 10)
+}
+
+func formatFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
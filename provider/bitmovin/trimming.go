@@ -0,0 +1,135 @@
+package bitmovin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/bitmovin/bitmovin-go/bitmovintypes"
+	"github.com/bitmovin/bitmovin-go/models"
+	"github.com/bitmovin/bitmovin-go/services"
+)
+
+// trimmedInputs creates the input streams of clipped outputs in an encoding,
+// trimming the source media with time-based trimming input streams. The
+// Bitmovin client doesn't support input streams, so they're created through
+// the REST API.
+type trimmedInputs struct {
+	provider   *bitmovinProvider
+	encodingID string
+	inputID    string
+	inputPath  string
+
+	// IDs of the trimming input streams created in the encoding, keyed by
+	// the selection of the source stream and the clip.
+	streams map[string]string
+}
+
+// inputStreamID returns the ID of the input stream that trims the given
+// stream of the source (selected by the given mode and position) to the given
+// clip, creating it when needed.
+func (t *trimmedInputs) inputStreamID(selectionMode bitmovintypes.SelectionMode, position int64, clip *db.Clip) (string, error) {
+	key := fmt.Sprintf("%s/%d/%s-%s", selectionMode, position, clip.Start, clip.End)
+	if id, ok := t.streams[key]; ok {
+		return id, nil
+	}
+	ingest := map[string]interface{}{
+		"inputId":       t.inputID,
+		"inputPath":     t.inputPath,
+		"selectionMode": selectionMode,
+	}
+	if selectionMode == bitmovintypes.SelectionModeAudioRelative {
+		ingest["position"] = position
+	}
+	ingestID, err := t.provider.post("encoding/encodings/"+t.encodingID+"/input-streams/ingest", ingest)
+	if err != nil {
+		return "", fmt.Errorf("error creating ingest input stream: %s", err)
+	}
+	trimming := map[string]interface{}{
+		"inputStreamId": ingestID,
+		"offset":        clip.StartTime().Seconds(),
+	}
+	if duration := clip.Duration(); duration > 0 {
+		trimming["duration"] = duration.Seconds()
+	}
+	id, err := t.provider.post("encoding/encodings/"+t.encodingID+"/input-streams/trimming/time-based", trimming)
+	if err != nil {
+		return "", fmt.Errorf("error creating trimming input stream: %s", err)
+	}
+	if t.streams == nil {
+		t.streams = make(map[string]string)
+	}
+	t.streams[key] = id
+	return id, nil
+}
+
+// addStream adds the given audio or video stream (kind) to the encoding,
+// reading from the given stream of the source trimmed to the clip, or from
+// the input streams of the stream when the clip is nil. It returns the ID of
+// the stream.
+func (t *trimmedInputs) addStream(kind string, stream *models.Stream, clip *db.Clip, selectionMode bitmovintypes.SelectionMode, position int64) (*string, error) {
+	if clip == nil {
+		encodingS := services.NewEncodingService(t.provider.client)
+		resp, err := encodingS.AddStream(t.encodingID, stream)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Status == bitmovinAPIErrorMsg {
+			return nil, fmt.Errorf("Error in adding %s stream to Encoding", kind)
+		}
+		return resp.Data.Result.ID, nil
+	}
+	inputStreamID, err := t.inputStreamID(selectionMode, position, clip)
+	if err != nil {
+		return nil, err
+	}
+	id, err := t.provider.post("encoding/encodings/"+t.encodingID+"/streams", map[string]interface{}{
+		"codecConfigId": stream.CodecConfigurationID,
+		"inputStreams":  []map[string]string{{"inputStreamId": inputStreamID}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error in adding %s stream to Encoding: %s", kind, err)
+	}
+	return &id, nil
+}
+
+// post sends the given resource to the given path of the Bitmovin API,
+// returning the ID of the created resource.
+func (p *bitmovinProvider) post(path string, resource interface{}) (string, error) {
+	body, err := json.Marshal(resource)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(p.config.Endpoint, "/")+"/"+path, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", p.config.APIKey)
+	client := http.Client{Timeout: time.Duration(p.config.Timeout) * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Status string
+		Data   struct {
+			Result struct {
+				ID string
+			}
+			Message string
+		}
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.Status == bitmovinAPIErrorMsg || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected response from the Bitmovin API (%d): %s", resp.StatusCode, result.Data.Message)
+	}
+	return result.Data.Result.ID, nil
+}
@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+// ErrClippingNotSupported is the error returned when checking a clipped job
+// against the capabilities of a provider that always transcodes the whole
+// source media.
+var ErrClippingNotSupported = errors.New("clipping is not supported")
+
+// ErrOutputClippingNotSupported is the error returned when checking a job
+// with per output clips against the capabilities of a provider that can only
+// clip the source media of the whole job.
+var ErrOutputClippingNotSupported = errors.New("clipping per output is not supported")
+
+// ClippingCapabilities describes how a provider is able to clip the source
+// media natively.
+type ClippingCapabilities struct {
+	// whether each output can use its own segment of the source, instead
+	// of all outputs sharing the clip of the job
+	PerOutput bool `json:"perOutput"`
+}
+
+// Check returns an error if the provider can't clip the source media in the
+// given mode (db.ClippingJob or db.ClippingOutputs). It's safe to call it on
+// nil capabilities, meaning that the provider doesn't support clipping at
+// all.
+func (c *ClippingCapabilities) Check(mode string) error {
+	if mode == "" {
+		return nil
+	}
+	if c == nil {
+		return ErrClippingNotSupported
+	}
+	if mode == db.ClippingOutputs && !c.PerOutput {
+		return ErrOutputClippingNotSupported
+	}
+	return nil
+}
+
+// FormatSeconds formats the given duration as a number of seconds, the way
+// clips are described (e.g. "12.5").
+func FormatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
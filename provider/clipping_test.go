@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+func TestClippingCapabilitiesCheck(t *testing.T) {
+	var tests = []struct {
+		testCase     string
+		capabilities *ClippingCapabilities
+		mode         string
+		want         error
+	}{
+		{"no clipping", nil, "", nil},
+		{"job clipping", &ClippingCapabilities{}, db.ClippingJob, nil},
+		{"output clipping", &ClippingCapabilities{PerOutput: true}, db.ClippingOutputs, nil},
+		{"unsupported output clipping", &ClippingCapabilities{}, db.ClippingOutputs, ErrOutputClippingNotSupported},
+		{"provider without clipping", nil, db.ClippingJob, ErrClippingNotSupported},
+	}
+	for _, test := range tests {
+		err := test.capabilities.Check(test.mode)
+		if err != test.want {
+			t.Errorf("%s: wrong error returned. Want %v. Got %v", test.testCase, test.want, err)
+		}
+	}
+}
+
+func TestFormatSeconds(t *testing.T) {
+	var tests = []struct {
+		duration time.Duration
+		want     string
+	}{
+		{0, "0"},
+		{90 * time.Second, "90"},
+		{12500 * time.Millisecond, "12.5"},
+	}
+	for _, test := range tests {
+		if got := FormatSeconds(test.duration); got != test.want {
+			t.Errorf("FormatSeconds(%s): want %q. Got %q", test.duration, test.want, got)
+		}
+	}
+}
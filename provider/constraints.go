@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+// PresetConstraints describes the parameters of presets supported by a
+// provider. Empty lists don't constrain the corresponding parameter.
+type PresetConstraints struct {
+	// containers supported by the provider (mp4, m3u8, webm, ...)
+	Containers []string `json:"containers,omitempty"`
+
+	// audio codecs supported by the provider
+	AudioCodecs []string `json:"audioCodecs,omitempty"`
+
+	// video codecs supported by the provider, mapped to the
+	// codec-specific parameters supported with each codec (see
+	// CheckVideoParameters)
+	VideoCodecs map[string][]string `json:"videoCodecs,omitempty"`
+
+	// optional audio parameters supported by the provider (see
+	// CheckAudioParameters). Unlike the other lists, an empty non-nil
+	// list means that none of the optional parameters is supported.
+	AudioParameters []string `json:"audioParameters,omitempty"`
+}
+
+// Validate checks the given preset against the constraints, returning one
+// error for each parameter that isn't supported by the provider.
+func (c *PresetConstraints) Validate(preset db.Preset) []db.PresetFieldError {
+	var errs []db.PresetFieldError
+	if len(c.Containers) > 0 && !isSupported(preset.Container, c.Containers) {
+		errs = append(errs, unsupportedValue("container", preset.Container, c.Containers))
+	}
+	if len(c.AudioCodecs) > 0 {
+		for i, audio := range preset.AudioRenditions() {
+			if audio.Codec == "" || isSupported(audio.Codec, c.AudioCodecs) {
+				continue
+			}
+			field := "audio.codec"
+			if i > 0 {
+				field = fmt.Sprintf("audioTracks[%d].codec", i-1)
+			}
+			errs = append(errs, unsupportedValue(field, audio.Codec, c.AudioCodecs))
+		}
+	}
+	if c.AudioParameters != nil {
+		for _, err := range unsupportedAudioParameters(preset, c.AudioParameters) {
+			errs = append(errs, db.PresetFieldError{Field: err.Parameter, Message: "not supported by the provider"})
+		}
+		if preset.AudioOnly() {
+			// the video codec of audio-only presets is reported above
+			// when they're not supported.
+			return errs
+		}
+	}
+	if len(c.VideoCodecs) > 0 {
+		params, ok := c.VideoCodecs[preset.Video.Codec]
+		if !ok {
+			codecs := make([]string, 0, len(c.VideoCodecs))
+			for codec := range c.VideoCodecs {
+				codecs = append(codecs, codec)
+			}
+			sort.Strings(codecs)
+			return append(errs, unsupportedValue("video.codec", preset.Video.Codec, codecs))
+		}
+		for _, err := range unsupportedVideoParameters(preset.Video, params) {
+			errs = append(errs, db.PresetFieldError{
+				Field:   err.Parameter,
+				Message: fmt.Sprintf("not supported with %s", preset.Video.Codec),
+			})
+		}
+	}
+	return errs
+}
+
+func unsupportedValue(field, value string, supported []string) db.PresetFieldError {
+	return db.PresetFieldError{
+		Field:   field,
+		Message: fmt.Sprintf("unsupported value %q: must be one of %s", value, strings.Join(supported, ", ")),
+	}
+}
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+func TestPresetConstraintsValidate(t *testing.T) {
+	constraints := PresetConstraints{
+		Containers:  []string{"mp4", "m3u8"},
+		AudioCodecs: []string{"aac"},
+		VideoCodecs: map[string][]string{
+			"h264": {"maxBitrate", "bufferSize"},
+			"hevc": {"bitDepth", "crf"},
+		},
+	}
+	var tests = []struct {
+		description string
+		preset      db.Preset
+		expected    []db.PresetFieldError
+	}{
+		{
+			"supported preset",
+			db.Preset{Container: "mp4", Video: db.VideoPreset{Codec: "hevc", BitDepth: "10", CRF: "24"}, Audio: db.AudioPreset{Codec: "aac"}},
+			nil,
+		},
+		{
+			"unsupported container and codecs",
+			db.Preset{Container: "webm", Video: db.VideoPreset{Codec: "vp9"}, Audio: db.AudioPreset{Codec: "opus"}},
+			[]db.PresetFieldError{
+				{Field: "container", Message: `unsupported value "webm": must be one of mp4, m3u8`},
+				{Field: "audio.codec", Message: `unsupported value "opus": must be one of aac`},
+				{Field: "video.codec", Message: `unsupported value "vp9": must be one of h264, hevc`},
+			},
+		},
+		{
+			"unsupported codec-specific parameters",
+			db.Preset{Container: "m3u8", Video: db.VideoPreset{Codec: "h264", CRF: "23", MaxBitrate: "5000000", TwoPass: true}},
+			[]db.PresetFieldError{
+				{Field: "video.crf", Message: "not supported with h264"},
+				{Field: "video.twoPass", Message: "not supported with h264"},
+			},
+		},
+	}
+	for _, test := range tests {
+		errs := constraints.Validate(test.preset)
+		if !reflect.DeepEqual(errs, test.expected) {
+			t.Errorf("%s: wrong errors returned\nwant %#v\ngot  %#v", test.description, test.expected, errs)
+		}
+	}
+}
+
+func TestPresetConstraintsValidateAudioParameters(t *testing.T) {
+	constraints := PresetConstraints{
+		AudioCodecs:     []string{"aac"},
+		VideoCodecs:     map[string][]string{"h264": nil},
+		AudioParameters: []string{"channels", "audioOnly"},
+	}
+	var tests = []struct {
+		description string
+		preset      db.Preset
+		expected    []db.PresetFieldError
+	}{
+		{
+			"supported parameters",
+			db.Preset{Video: db.VideoPreset{Codec: "h264"}, Audio: db.AudioPreset{Codec: "aac", Channels: "2"}},
+			nil,
+		},
+		{
+			"supported audio-only preset",
+			db.Preset{Audio: db.AudioPreset{Codec: "aac", Channels: "1"}},
+			nil,
+		},
+		{
+			"unsupported parameters",
+			db.Preset{
+				Video:       db.VideoPreset{Codec: "h264"},
+				Audio:       db.AudioPreset{Codec: "aac", Loudness: "-23"},
+				AudioTracks: []db.AudioPreset{{Codec: "mp3", Language: "es"}},
+			},
+			[]db.PresetFieldError{
+				{Field: "audioTracks[0].codec", Message: `unsupported value "mp3": must be one of aac`},
+				{Field: "audioTracks", Message: "not supported by the provider"},
+				{Field: "audio.loudness", Message: "not supported by the provider"},
+				{Field: "audioTracks[0].language", Message: "not supported by the provider"},
+			},
+		},
+	}
+	for _, test := range tests {
+		errs := constraints.Validate(test.preset)
+		if !reflect.DeepEqual(errs, test.expected) {
+			t.Errorf("%s: wrong errors returned\nwant %#v\ngot  %#v", test.description, test.expected, errs)
+		}
+	}
+}
+
+func TestPresetConstraintsValidateEmpty(t *testing.T) {
+	var constraints PresetConstraints
+	preset := db.Preset{Container: "mov", Video: db.VideoPreset{Codec: "prores", CRF: "10"}}
+	if errs := constraints.Validate(preset); len(errs) > 0 {
+		t.Errorf("unexpected errors: %#v", errs)
+	}
+}
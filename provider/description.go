@@ -18,6 +18,18 @@ type Capabilities struct {
 	InputFormats  []string `json:"input"`
 	OutputFormats []string `json:"output"`
 	Destinations  []string `json:"destinations"`
+
+	// PresetConstraints describes the presets supported by the
+	// provider. It's nil for providers that don't declare constraints.
+	PresetConstraints *PresetConstraints `json:"presetConstraints,omitempty"`
+
+	// Thumbnails describes the thumbnails generated by the provider. It's
+	// nil for providers that can't generate thumbnails.
+	Thumbnails *ThumbnailCapabilities `json:"thumbnails,omitempty"`
+
+	// Clipping describes how the provider clips the source media. It's
+	// nil for providers that always transcode the whole source.
+	Clipping *ClippingCapabilities `json:"clipping,omitempty"`
 }
 
 // Health describes the current health status of the provider. If indicates
@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+// destinationTypes maps the schemes of destination URLs to the types of
+// destinations listed in Capabilities.
+var destinationTypes = map[string]string{
+	"s3":    "s3",
+	"gs":    "gcs",
+	"azure": "azure",
+	"ftp":   "ftp",
+	"sftp":  "ftp",
+	"http":  "http",
+	"https": "http",
+	"file":  "local",
+}
+
+// DestinationType returns the type of the given destination URL, as listed in
+// Capabilities.Destinations (e.g.: "s3" for s3://bucket/path or "gcs" for
+// gs://bucket/path).
+func DestinationType(destination string) (string, error) {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("invalid destination %q: %s", destination, err)
+	}
+	destinationType, ok := destinationTypes[strings.ToLower(u.Scheme)]
+	if !ok {
+		return "", fmt.Errorf("invalid destination %q: unsupported scheme %q", destination, u.Scheme)
+	}
+	if u.Host == "" && destinationType != "local" {
+		return "", fmt.Errorf("invalid destination %q: missing host", destination)
+	}
+	return destinationType, nil
+}
+
+// StripCredentials removes the credentials (the user info) from the given
+// destination URL, so destinations can be stored and returned by the API
+// without leaking them. Providers take credentials from their configuration.
+func StripCredentials(destination string) string {
+	u, err := url.Parse(destination)
+	if err != nil || u.User == nil {
+		return destination
+	}
+	u.User = nil
+	return u.String()
+}
+
+// AzureBlob parses the given Azure destination, in the format
+// azure://<account>/<container>/path, returning the storage account, its
+// access key, taken from the given list of accounts (the
+// AZURE_STORAGE_ACCOUNTS configuration), and the path of the blob, starting
+// with its container.
+func AzureBlob(destination, accounts string) (account, key, blobPath string, err error) {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid destination %q: %s", destination, err)
+	}
+	if strings.ToLower(u.Scheme) != "azure" {
+		return "", "", "", fmt.Errorf("invalid destination %q: not an Azure destination", destination)
+	}
+	account = u.Host
+	blobPath = strings.TrimLeft(u.Path, "/")
+	if blobPath == "" {
+		return "", "", "", fmt.Errorf("invalid destination %q: missing container", destination)
+	}
+	for _, entry := range strings.Split(accounts, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) == 2 && parts[0] == account && parts[1] != "" {
+			return account, parts[1], blobPath, nil
+		}
+	}
+	return "", "", "", fmt.Errorf("no access key configured for the Azure storage account %q", account)
+}
+
+// JobDestination returns the base location of the outputs of the given job:
+// the destination defined in the job, or the given default destination,
+// configured in the provider, when the job doesn't define one.
+func JobDestination(job *db.Job, defaultDestination string) string {
+	if job.Destination != "" {
+		return job.Destination
+	}
+	return defaultDestination
+}
@@ -13,11 +13,21 @@
 //         factory, err := provider.GetProviderFactory(elastictranscoder.Name)
 //         // handle err and use factory to get an instance of the provider.
 //     }
+//
+// Elastic Transcoder configures thumbnails in presets, so the thumbnails of
+// a job are generated along with its first video output, using a copy of
+// its preset with the settings of the thumbnails. The generated images are
+// listed from S3 once the job is complete.
+//
+// A clip shared by all outputs is set as the time span of the input. When
+// outputs have their own clips, each output gets its clip as a composition.
 package elastictranscoder
 
 import (
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -32,6 +42,8 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elastictranscoder"
 	"github.com/aws/aws-sdk-go/service/elastictranscoder/elastictranscoderiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 )
 
 const (
@@ -41,11 +53,47 @@ const (
 
 	defaultAWSRegion = "us-east-1"
 	hlsPlayList      = "HLSv3"
+	hlsv4PlayList    = "HLSv4"
+	dashPlayList     = "MPEG-DASH"
 )
 
 var (
 	errAWSInvalidConfig = errors.New("invalid Elastic Transcoder config. Please define the configuration entries in the config file or environment variables")
+	errNoVideoOutput    = errors.New("thumbnails require at least one output with video")
 	s3Pattern           = regexp.MustCompile(`^s3://`)
+
+	// playlistFormats maps the containers of adaptive streaming presets to
+	// the format of the playlists that reference them.
+	playlistFormats = map[string]string{"ts": hlsPlayList, "fmp4": dashPlayList}
+
+	// videoCodecParameters lists the video codecs supported by Elastic
+	// Transcoder, along with the codec-specific parameters supported by
+	// each of them.
+	videoCodecParameters = map[string][]string{
+		"h264":  {"maxBitrate", "bufferSize", "referenceFrames", "frameRate"},
+		"vp8":   {"frameRate"},
+		"vp9":   {"frameRate"},
+		"mpeg2": {"frameRate"},
+		"gif":   {"frameRate"},
+	}
+
+	// audioParameters lists the optional audio parameters supported by
+	// Elastic Transcoder, which only supports up to two channels. Audio-only
+	// HLS outputs are alternate audio renditions of HLSv4 playlists. Audio
+	// tracks aren't supported, as the audio streams of the source can't be
+	// selected.
+	audioParameters = []string{"channels", "channelLayout", "sampleRate", "audioOnly"}
+
+	audioChannels    = map[string]string{"1": "1", "2": "2", "mono": "1", "stereo": "2"}
+	audioSampleRates = []string{"22050", "32000", "44100", "48000", "96000"}
+
+	// videoFrameRates lists the frame rates supported by Elastic
+	// Transcoder, which keeps the frame rate of the source by default.
+	videoFrameRates = []string{"10", "15", "23.97", "24", "25", "29.97", "30", "50", "60"}
+
+	// thumbnailCapabilities describes the thumbnails generated by Elastic
+	// Transcoder, at a regular interval.
+	thumbnailCapabilities = provider.ThumbnailCapabilities{Formats: []string{"jpg", "png"}}
 )
 
 func init() {
@@ -54,17 +102,34 @@ func init() {
 
 type awsProvider struct {
 	c      elastictranscoderiface.ElasticTranscoderAPI
+	s3     s3iface.S3API
 	config *config.ElasticTranscoder
+
+	// getCertificate returns the certificate used for verifying the
+	// signature of SNS messages. Defaults to fetchSNSCertificate.
+	getCertificate func(certURL string) (*x509.Certificate, error)
 }
 
 func (p *awsProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
-	var adaptiveStreamingOutputs []db.TranscodeOutput
+	adaptiveStreamingOutputs := make(map[string][]db.TranscodeOutput)
+	var hlsAudioOnly bool
 	source := p.normalizeSource(job.SourceMedia)
 	params := elastictranscoder.CreateJobInput{
 		PipelineId: aws.String(p.config.PipelineID),
 		Input:      &elastictranscoder.JobInput{Key: aws.String(source)},
 	}
+	outputKeyPrefix, err := p.outputKeyPrefix(job)
+	if err != nil {
+		return nil, err
+	}
+	params.OutputKeyPrefix = outputKeyPrefix
+	clipping := job.Clipping()
+	if clipping == db.ClippingJob {
+		params.Input.TimeSpan = timeSpan(job.Clip)
+	}
 	params.Outputs = make([]*elastictranscoder.CreateJobOutput, len(job.Outputs))
+	var thumbnailsOutput *elastictranscoder.CreateJobOutput
+	var thumbnailsPreset *elastictranscoder.Preset
 	for i, output := range job.Outputs {
 		presetID, ok := output.Preset.ProviderMapping[Name]
 		if !ok {
@@ -80,34 +145,64 @@ func (p *awsProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
 		if presetOutput.Preset == nil || presetOutput.Preset.Container == nil {
 			return nil, fmt.Errorf("misconfigured preset: %s", presetID)
 		}
-		var isAdaptiveStreamingPreset bool
-		if *presetOutput.Preset.Container == "ts" {
-			isAdaptiveStreamingPreset = true
-			adaptiveStreamingOutputs = append(adaptiveStreamingOutputs, output)
+		playlistFormat, isAdaptiveStreamingPreset := playlistFormats[*presetOutput.Preset.Container]
+		if isAdaptiveStreamingPreset {
+			adaptiveStreamingOutputs[playlistFormat] = append(adaptiveStreamingOutputs[playlistFormat], output)
+			if playlistFormat == hlsPlayList && presetOutput.Preset.Video == nil {
+				hlsAudioOnly = true
+			}
 		}
 		params.Outputs[i] = &elastictranscoder.CreateJobOutput{
 			PresetId: aws.String(presetID),
 			Key:      p.outputKey(job, output.FileName, isAdaptiveStreamingPreset),
 		}
+		if clip := job.OutputClip(output); clipping == db.ClippingOutputs && clip != nil {
+			params.Outputs[i].Composition = []*elastictranscoder.Clip{{TimeSpan: timeSpan(clip)}}
+		}
 		if isAdaptiveStreamingPreset {
 			params.Outputs[i].SegmentDuration = aws.String(strconv.Itoa(int(job.StreamingParams.SegmentDuration)))
 		}
+		if thumbnailsOutput == nil && presetOutput.Preset.Video != nil {
+			thumbnailsOutput = params.Outputs[i]
+			thumbnailsPreset = presetOutput.Preset
+		}
+	}
+	if job.Thumbnails != nil {
+		if thumbnailsOutput == nil {
+			return nil, errNoVideoOutput
+		}
+		presetID, err := p.thumbnailsPreset(thumbnailsPreset, job.Thumbnails)
+		if err != nil {
+			return nil, err
+		}
+		thumbnailsOutput.PresetId = aws.String(presetID)
+		thumbnailsOutput.ThumbnailPattern = aws.String(job.ID + "/" + strings.Trim(job.Thumbnails.Path, "/") + "/thumbnail-{count}")
 	}
 
-	if len(adaptiveStreamingOutputs) > 0 {
-		playlistFileName := job.StreamingParams.PlaylistFileName
+	for _, playlistFormat := range []string{hlsPlayList, dashPlayList} {
+		outputs := adaptiveStreamingOutputs[playlistFormat]
+		if len(outputs) == 0 {
+			continue
+		}
+		playlistFileName := p.playlistFileName(job, playlistFormat)
 		playlistFileName = strings.TrimRight(playlistFileName, filepath.Ext(playlistFileName))
+		format := playlistFormat
+		if playlistFormat == hlsPlayList && hlsAudioOnly {
+			// audio-only outputs are listed as alternate audio
+			// renditions, which requires HLSv4 playlists.
+			format = hlsv4PlayList
+		}
 		jobPlaylist := elastictranscoder.CreateJobPlaylist{
-			Format: aws.String(hlsPlayList),
+			Format: aws.String(format),
 			Name:   aws.String(job.ID + "/" + playlistFileName),
 		}
 
-		jobPlaylist.OutputKeys = make([]*string, len(adaptiveStreamingOutputs))
-		for i, output := range adaptiveStreamingOutputs {
+		jobPlaylist.OutputKeys = make([]*string, len(outputs))
+		for i, output := range outputs {
 			jobPlaylist.OutputKeys[i] = p.outputKey(job, output.FileName, true)
 		}
 
-		params.Playlists = []*elastictranscoder.CreateJobPlaylist{&jobPlaylist}
+		params.Playlists = append(params.Playlists, &jobPlaylist)
 	}
 	resp, err := p.c.CreateJob(&params)
 	if err != nil {
@@ -129,6 +224,48 @@ func (p *awsProvider) normalizeSource(source string) string {
 	return source
 }
 
+// playlistFileName returns the name of the playlist in the given format. The
+// playlist file name of the job is used for the playlist of its streaming
+// protocol, while other playlists are generated with a default name.
+func (p *awsProvider) playlistFileName(job *db.Job, playlistFormat string) string {
+	isDASH := job.StreamingParams.Protocol == "dash"
+	switch {
+	case playlistFormat == dashPlayList && !isDASH:
+		return "dash/index.mpd"
+	case playlistFormat == hlsPlayList && isDASH:
+		return "hls/index.m3u8"
+	default:
+		return job.StreamingParams.PlaylistFileName
+	}
+}
+
+// outputKeyPrefix returns the prefix of the keys of the outputs of the given
+// job, taken from the path of its destination. Elastic Transcoder writes the
+// outputs to the output bucket of the pipeline, so the destination must be in
+// that bucket.
+func (p *awsProvider) outputKeyPrefix(job *db.Job) (*string, error) {
+	if job.Destination == "" {
+		return nil, nil
+	}
+	if !s3Pattern.MatchString(job.Destination) {
+		return nil, fmt.Errorf("invalid destination %q: must be an S3 URL", job.Destination)
+	}
+	pipeline, err := p.c.ReadPipeline(&elastictranscoder.ReadPipelineInput{
+		Id: aws.String(p.config.PipelineID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(strings.Replace(job.Destination, "s3://", "", 1), "/", 2)
+	if bucket := aws.StringValue(pipeline.Pipeline.OutputBucket); parts[0] != bucket {
+		return nil, fmt.Errorf("invalid destination %q: outputs must be in the output bucket of the pipeline (%s)", job.Destination, bucket)
+	}
+	if len(parts) < 2 || strings.Trim(parts[1], "/") == "" {
+		return nil, nil
+	}
+	return aws.String(strings.Trim(parts[1], "/") + "/"), nil
+}
+
 func (p *awsProvider) outputKey(job *db.Job, fileName string, adaptive bool) *string {
 	if adaptive {
 		fileName = strings.TrimRight(fileName, filepath.Ext(fileName))
@@ -136,7 +273,7 @@ func (p *awsProvider) outputKey(job *db.Job, fileName string, adaptive bool) *st
 	return aws.String(job.ID + "/" + fileName)
 }
 
-func (p *awsProvider) createVideoPreset(preset db.Preset) *elastictranscoder.VideoParameters {
+func (p *awsProvider) createVideoPreset(preset db.Preset) (*elastictranscoder.VideoParameters, error) {
 	videoPreset := elastictranscoder.VideoParameters{
 		DisplayAspectRatio: aws.String("auto"),
 		FrameRate:          aws.String("auto"),
@@ -163,36 +300,112 @@ func (p *awsProvider) createVideoPreset(preset db.Preset) *elastictranscoder.Vid
 	normalizedVideoBitRate, _ := strconv.Atoi(preset.Video.Bitrate)
 	videoBitrate := strconv.Itoa(normalizedVideoBitRate / 1000)
 	videoPreset.BitRate = &videoBitrate
+	if preset.Video.FrameRate != "" {
+		if !isFrameRateSupported(preset.Video.FrameRate) {
+			return nil, provider.UnsupportedParameterError{Parameter: "video.frameRate", Value: preset.Video.FrameRate}
+		}
+		videoPreset.FrameRate = &preset.Video.FrameRate
+	}
 	switch preset.Video.Codec {
 	case "h264":
+		err := provider.CheckVideoParameters(preset.Video, videoCodecParameters["h264"]...)
+		if err != nil {
+			return nil, err
+		}
 		videoPreset.Codec = aws.String("H.264")
+		if preset.Video.ReferenceFrames != "" {
+			videoPreset.CodecOptions["MaxReferenceFrames"] = &preset.Video.ReferenceFrames
+		}
+		// Elastic Transcoder expects both values in kilobits.
+		if preset.Video.MaxBitrate != "" {
+			maxBitrate, _ := strconv.Atoi(preset.Video.MaxBitrate)
+			videoPreset.CodecOptions["MaxBitRate"] = aws.String(strconv.Itoa(maxBitrate / 1000))
+		}
+		if preset.Video.BufferSize != "" {
+			bufferSize, _ := strconv.Atoi(preset.Video.BufferSize)
+			videoPreset.CodecOptions["BufferSize"] = aws.String(strconv.Itoa(bufferSize / 1000))
+		}
 	case "vp8", "vp9":
+		if err := provider.CheckVideoParameters(preset.Video, videoCodecParameters[preset.Video.Codec]...); err != nil {
+			return nil, err
+		}
 		videoPreset.Codec = aws.String(preset.Video.Codec)
 		delete(videoPreset.CodecOptions, "MaxReferenceFrames")
 		delete(videoPreset.CodecOptions, "Level")
 		// Recommended profile value is zero, based on:
 		// http://www.webmproject.org/docs/encoder-parameters/
 		videoPreset.CodecOptions["Profile"] = aws.String("0")
+	case "hevc", "av1":
+		return nil, provider.UnsupportedParameterError{Parameter: "video.codec", Value: preset.Video.Codec}
 	}
 	if preset.Video.GopMode == "fixed" {
 		videoPreset.FixedGOP = aws.String("true")
 	}
-	return &videoPreset
+	return &videoPreset, nil
+}
+
+// thumbnailsPreset returns the id of a copy of the given preset that also
+// generates the given thumbnails, creating it when it doesn't exist yet. The
+// copy is named after the preset and the settings of the thumbnails, so
+// jobs with the same thumbnails share it.
+func (p *awsProvider) thumbnailsPreset(preset *elastictranscoder.Preset, thumbnails *db.Thumbnails) (string, error) {
+	settings := thumbnailParameters(thumbnails)
+	hash := fnv.New32a()
+	fmt.Fprintf(hash, "%s/%s/%s/%s", aws.StringValue(settings.Format), aws.StringValue(settings.Interval),
+		aws.StringValue(settings.MaxWidth), aws.StringValue(settings.MaxHeight))
+	name := fmt.Sprintf("thumbnails-%s-%08x", aws.StringValue(preset.Id), hash.Sum32())
+	var presetID string
+	err := p.c.ListPresetsPages(&elastictranscoder.ListPresetsInput{}, func(page *elastictranscoder.ListPresetsOutput, lastPage bool) bool {
+		for _, existing := range page.Presets {
+			if aws.StringValue(existing.Name) == name {
+				presetID = aws.StringValue(existing.Id)
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil || presetID != "" {
+		return presetID, err
+	}
+	presetOutput, err := p.c.CreatePreset(&elastictranscoder.CreatePresetInput{
+		Name:        aws.String(name),
+		Description: aws.String("Thumbnails for the preset " + aws.StringValue(preset.Id)),
+		Container:   preset.Container,
+		Audio:       preset.Audio,
+		Video:       preset.Video,
+		Thumbnails:  settings,
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(presetOutput.Preset.Id), nil
 }
 
-func (p *awsProvider) createThumbsPreset(preset db.Preset) *elastictranscoder.Thumbnails {
-	thumbsPreset := &elastictranscoder.Thumbnails{
-		PaddingPolicy: aws.String("Pad"),
-		Format:        aws.String("png"),
-		Interval:      aws.String("1"),
-		SizingPolicy:  aws.String("Fill"),
+// thumbnailParameters translates the given thumbnails to the thumbnail
+// settings of Elastic Transcoder presets, shrinking the images to fit the
+// given dimensions while keeping the aspect ratio of the video.
+func thumbnailParameters(thumbnails *db.Thumbnails) *elastictranscoder.Thumbnails {
+	params := &elastictranscoder.Thumbnails{
+		Format:        aws.String(thumbnails.Format),
+		Interval:      aws.String(strconv.Itoa(int(thumbnails.Interval))),
 		MaxWidth:      aws.String("auto"),
 		MaxHeight:     aws.String("auto"),
+		SizingPolicy:  aws.String("ShrinkToFit"),
+		PaddingPolicy: aws.String("NoPad"),
+	}
+	if thumbnails.Width > 0 {
+		params.MaxWidth = aws.String(strconv.Itoa(int(thumbnails.Width)))
 	}
-	return thumbsPreset
+	if thumbnails.Height > 0 {
+		params.MaxHeight = aws.String(strconv.Itoa(int(thumbnails.Height)))
+	}
+	return params
 }
 
-func (p *awsProvider) createAudioPreset(preset db.Preset) *elastictranscoder.AudioParameters {
+func (p *awsProvider) createAudioPreset(preset db.Preset) (*elastictranscoder.AudioParameters, error) {
+	if err := provider.CheckAudioParameters(preset, audioParameters...); err != nil {
+		return nil, err
+	}
 	audioPreset := &elastictranscoder.AudioParameters{
 		Codec:      &preset.Audio.Codec,
 		Channels:   aws.String("auto"),
@@ -210,27 +423,88 @@ func (p *awsProvider) createAudioPreset(preset db.Preset) *elastictranscoder.Aud
 		audioPreset.Codec = aws.String("vorbis")
 	}
 
-	return audioPreset
+	if preset.Audio.ChannelLayout != "" {
+		channels, ok := audioChannels[preset.Audio.ChannelLayout]
+		if !ok {
+			return nil, provider.UnsupportedParameterError{Parameter: "audio.channelLayout", Value: preset.Audio.ChannelLayout}
+		}
+		audioPreset.Channels = aws.String(channels)
+	}
+	if preset.Audio.Channels != "" {
+		channels, ok := audioChannels[preset.Audio.Channels]
+		if !ok {
+			return nil, provider.UnsupportedParameterError{Parameter: "audio.channels", Value: preset.Audio.Channels}
+		}
+		audioPreset.Channels = aws.String(channels)
+	}
+	if preset.Audio.SampleRate != "" {
+		if !isSampleRateSupported(preset.Audio.SampleRate) {
+			return nil, provider.UnsupportedParameterError{Parameter: "audio.sampleRate", Value: preset.Audio.SampleRate}
+		}
+		audioPreset.SampleRate = &preset.Audio.SampleRate
+	}
+
+	return audioPreset, nil
+}
+
+func isSampleRateSupported(sampleRate string) bool {
+	for _, supported := range audioSampleRates {
+		if supported == sampleRate {
+			return true
+		}
+	}
+	return false
+}
+
+func isFrameRateSupported(frameRate string) bool {
+	for _, supported := range videoFrameRates {
+		if supported == frameRate {
+			return true
+		}
+	}
+	return false
 }
 
 func (p *awsProvider) CreatePreset(preset db.Preset) (string, error) {
+	presetInput, err := p.presetInput(preset)
+	if err != nil {
+		return "", err
+	}
+	presetOutput, err := p.c.CreatePreset(presetInput)
+	if err != nil {
+		return "", err
+	}
+	return *presetOutput.Preset.Id, nil
+}
+
+// presetInput translates the given preset to the input for creating it in
+// Elastic Transcoder.
+func (p *awsProvider) presetInput(preset db.Preset) (*elastictranscoder.CreatePresetInput, error) {
 	presetInput := elastictranscoder.CreatePresetInput{
 		Name:        &preset.Name,
 		Description: &preset.Description,
 	}
-	if preset.Container == "m3u8" {
+	switch preset.Container {
+	case "m3u8":
 		presetInput.Container = aws.String("ts")
-	} else {
+	case "mpd":
+		presetInput.Container = aws.String("fmp4")
+	default:
 		presetInput.Container = &preset.Container
 	}
-	presetInput.Video = p.createVideoPreset(preset)
-	presetInput.Audio = p.createAudioPreset(preset)
-	presetInput.Thumbnails = p.createThumbsPreset(preset)
-	presetOutput, err := p.c.CreatePreset(&presetInput)
+	audioPreset, err := p.createAudioPreset(preset)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return *presetOutput.Preset.Id, nil
+	presetInput.Audio = audioPreset
+	if !preset.AudioOnly() {
+		videoPreset, err := p.createVideoPreset(preset)
+		if err != nil {
+			return nil, err
+		}
+		presetInput.Video = videoPreset
+	}
+	return &presetInput, nil
 }
 
 func (p *awsProvider) GetPreset(presetID string) (interface{}, error) {
@@ -277,6 +551,13 @@ func (p *awsProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
 	if err != nil {
 		return nil, err
 	}
+	var thumbnailFiles []provider.OutputFile
+	if p.statusMap(aws.StringValue(resp.Job.Status)) == provider.StatusFinished {
+		thumbnailFiles, err = p.getThumbnailFiles(resp.Job)
+		if err != nil {
+			return nil, err
+		}
+	}
 	var sourceInfo provider.SourceInfo
 	if resp.Job.Input.DetectedProperties != nil {
 		sourceInfo = provider.SourceInfo{
@@ -303,6 +584,7 @@ func (p *awsProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
 		Output: provider.JobOutput{
 			Destination: outputDestination,
 			Files:       outputFiles,
+			Thumbnails:  thumbnailFiles,
 		},
 	}, nil
 }
@@ -314,8 +596,9 @@ func (p *awsProvider) getOutputDestination(job *db.Job, awsJob *elastictranscode
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("s3://%s/%s",
+	return fmt.Sprintf("s3://%s/%s%s",
 		aws.StringValue(readPipelineOutput.Pipeline.OutputBucket),
+		aws.StringValue(awsJob.OutputKeyPrefix),
 		job.ID,
 	), nil
 }
@@ -341,25 +624,77 @@ func (p *awsProvider) getOutputFiles(job *elastictranscoder.Job) ([]provider.Out
 			aws.StringValue(output.Key),
 		)
 		container := aws.StringValue(preset.Preset.Container)
-		if container == "ts" {
+		if _, ok := playlistFormats[container]; ok {
 			continue
 		}
 		file := provider.OutputFile{
-			Path:       filePath,
-			Container:  container,
-			VideoCodec: aws.StringValue(preset.Preset.Video.Codec),
-			Width:      aws.Int64Value(output.Width),
-			Height:     aws.Int64Value(output.Height),
+			Path:      filePath,
+			Container: container,
+			Width:     aws.Int64Value(output.Width),
+			Height:    aws.Int64Value(output.Height),
+		}
+		if preset.Preset.Video != nil {
+			file.VideoCodec = aws.StringValue(preset.Preset.Video.Codec)
 		}
 		files = append(files, file)
 	}
 	for _, playlist := range job.Playlists {
+		container := "m3u8"
+		if aws.StringValue(playlist.Format) == dashPlayList {
+			container = "mpd"
+		}
 		filePath := fmt.Sprintf("s3://%s/%s%s",
 			aws.StringValue(pipeline.Pipeline.OutputBucket),
 			aws.StringValue(job.OutputKeyPrefix),
-			aws.StringValue(playlist.Name)+".m3u8",
+			aws.StringValue(playlist.Name)+"."+container,
 		)
-		files = append(files, provider.OutputFile{Path: filePath, Container: "m3u8"})
+		files = append(files, provider.OutputFile{Path: filePath, Container: container})
+	}
+	return files, nil
+}
+
+// getThumbnailFiles returns the thumbnails generated by the given job,
+// listing them from the thumbnails bucket of the pipeline, as Elastic
+// Transcoder doesn't report them.
+func (p *awsProvider) getThumbnailFiles(job *elastictranscoder.Job) ([]provider.OutputFile, error) {
+	var prefixes []string
+	for _, output := range job.Outputs {
+		if pattern := aws.StringValue(output.ThumbnailPattern); pattern != "" {
+			prefixes = append(prefixes, aws.StringValue(job.OutputKeyPrefix)+strings.SplitN(pattern, "{", 2)[0])
+		}
+	}
+	if len(prefixes) == 0 {
+		return nil, nil
+	}
+	pipeline, err := p.c.ReadPipeline(&elastictranscoder.ReadPipelineInput{
+		Id: job.PipelineId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	bucket := aws.StringValue(pipeline.Pipeline.OutputBucket)
+	if cfg := pipeline.Pipeline.ThumbnailConfig; cfg != nil && aws.StringValue(cfg.Bucket) != "" {
+		bucket = aws.StringValue(cfg.Bucket)
+	}
+	var files []provider.OutputFile
+	for _, prefix := range prefixes {
+		err = p.s3.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+			Bucket: aws.String(bucket),
+			Prefix: aws.String(prefix),
+		}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, object := range page.Contents {
+				key := aws.StringValue(object.Key)
+				files = append(files, provider.OutputFile{
+					Path:      fmt.Sprintf("s3://%s/%s", bucket, key),
+					Container: strings.TrimPrefix(filepath.Ext(key), "."),
+					FileSize:  aws.Int64Value(object.Size),
+				})
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 	return files, nil
 }
@@ -391,11 +726,31 @@ func (p *awsProvider) Healthcheck() error {
 	return err
 }
 
+// timeSpan returns the Elastic Transcoder time span of the given clip.
+// Positions are given in seconds, with millisecond precision.
+func timeSpan(clip *db.Clip) *elastictranscoder.TimeSpan {
+	var span elastictranscoder.TimeSpan
+	if clip.Start != "" {
+		span.StartTime = aws.String(provider.FormatSeconds(clip.StartTime() / time.Millisecond * time.Millisecond))
+	}
+	if clip.End != "" {
+		span.Duration = aws.String(provider.FormatSeconds(clip.Duration() / time.Millisecond * time.Millisecond))
+	}
+	return &span
+}
+
 func (p *awsProvider) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
 		InputFormats:  []string{"h264"},
-		OutputFormats: []string{"mp4", "hls", "webm"},
+		OutputFormats: []string{"mp4", "hls", "dash", "webm"},
 		Destinations:  []string{"s3"},
+
+		PresetConstraints: &provider.PresetConstraints{
+			VideoCodecs:     videoCodecParameters,
+			AudioParameters: audioParameters,
+		},
+		Thumbnails: &thumbnailCapabilities,
+		Clipping:   &provider.ClippingCapabilities{PerOutput: true},
 	}
 }
 
@@ -414,6 +769,7 @@ func elasticTranscoderFactory(cfg *config.Config) (provider.TranscodingProvider,
 	}
 	return &awsProvider{
 		c:      elastictranscoder.New(awsSession),
+		s3:     s3.New(awsSession),
 		config: cfg.ElasticTranscoder,
 	}, nil
 }
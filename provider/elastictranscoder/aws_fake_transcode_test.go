@@ -4,10 +4,13 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/elastictranscoder"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 )
 
 type failure struct {
@@ -18,6 +21,7 @@ type failure struct {
 type fakeElasticTranscoder struct {
 	*elastictranscoder.ElasticTranscoder
 	jobs         map[string]*elastictranscoder.CreateJobInput
+	presets      map[string]*elastictranscoder.Preset
 	canceledJobs []elastictranscoder.CancelJobInput
 	failures     chan failure
 }
@@ -27,6 +31,7 @@ func newFakeElasticTranscoder() *fakeElasticTranscoder {
 		ElasticTranscoder: &elastictranscoder.ElasticTranscoder{},
 		failures:          make(chan failure, 1),
 		jobs:              make(map[string]*elastictranscoder.CreateJobInput),
+		presets:           make(map[string]*elastictranscoder.Preset),
 	}
 }
 
@@ -54,17 +59,26 @@ func (c *fakeElasticTranscoder) CreateJob(input *elastictranscoder.CreateJobInpu
 
 func (c *fakeElasticTranscoder) CreatePreset(input *elastictranscoder.CreatePresetInput) (*elastictranscoder.CreatePresetOutput, error) {
 	var presetID = *input.Name + "-abc123"
-	return &elastictranscoder.CreatePresetOutput{
-		Preset: &elastictranscoder.Preset{
-			Audio:       input.Audio,
-			Container:   input.Container,
-			Description: input.Description,
-			Name:        input.Name,
-			Id:          &presetID,
-			Thumbnails:  input.Thumbnails,
-			Video:       input.Video,
-		},
-	}, nil
+	preset := &elastictranscoder.Preset{
+		Audio:       input.Audio,
+		Container:   input.Container,
+		Description: input.Description,
+		Name:        input.Name,
+		Id:          &presetID,
+		Thumbnails:  input.Thumbnails,
+		Video:       input.Video,
+	}
+	c.presets[presetID] = preset
+	return &elastictranscoder.CreatePresetOutput{Preset: preset}, nil
+}
+
+func (c *fakeElasticTranscoder) ListPresetsPages(input *elastictranscoder.ListPresetsInput, fn func(*elastictranscoder.ListPresetsOutput, bool) bool) error {
+	var output elastictranscoder.ListPresetsOutput
+	for _, preset := range c.presets {
+		output.Presets = append(output.Presets, preset)
+	}
+	fn(&output, true)
+	return nil
 }
 
 func (c *fakeElasticTranscoder) ReadPreset(input *elastictranscoder.ReadPresetInput) (*elastictranscoder.ReadPresetOutput, error) {
@@ -73,18 +87,23 @@ func (c *fakeElasticTranscoder) ReadPreset(input *elastictranscoder.ReadPresetIn
 	if strings.Contains(*input.Id, "hls") {
 		container = "ts"
 	}
+	if strings.Contains(*input.Id, "dash") {
+		container = "fmp4"
+	}
 	if strings.Contains(*input.Id, "webm") {
 		container = "webm"
 		codec = "VP8"
 	}
-	return &elastictranscoder.ReadPresetOutput{
-		Preset: &elastictranscoder.Preset{
-			Id:        input.Id,
-			Name:      input.Id,
-			Container: aws.String(container),
-			Video:     &elastictranscoder.VideoParameters{Codec: aws.String(codec)},
-		},
-	}, nil
+	preset := &elastictranscoder.Preset{
+		Id:        input.Id,
+		Name:      input.Id,
+		Container: aws.String(container),
+		Video:     &elastictranscoder.VideoParameters{Codec: aws.String(codec)},
+	}
+	if strings.Contains(*input.Id, "audio") {
+		preset.Video = nil
+	}
+	return &elastictranscoder.ReadPresetOutput{Preset: preset}, nil
 }
 
 func (c *fakeElasticTranscoder) ReadJob(input *elastictranscoder.ReadJobInput) (*elastictranscoder.ReadJobOutput, error) {
@@ -98,12 +117,13 @@ func (c *fakeElasticTranscoder) ReadJob(input *elastictranscoder.ReadJobInput) (
 	outputs := make([]*elastictranscoder.JobOutput, len(createJobInput.Outputs))
 	for i, createJobOutput := range createJobInput.Outputs {
 		outputs[i] = &elastictranscoder.JobOutput{
-			Key:          createJobOutput.Key,
-			Status:       aws.String("Complete"),
-			StatusDetail: aws.String("it's finished!"),
-			PresetId:     aws.String(fmt.Sprintf("preset-%s", aws.StringValue(createJobOutput.Key))),
-			Width:        aws.Int64(0),
-			Height:       aws.Int64(720),
+			Key:              createJobOutput.Key,
+			Status:           aws.String("Complete"),
+			StatusDetail:     aws.String("it's finished!"),
+			PresetId:         aws.String(fmt.Sprintf("preset-%s", aws.StringValue(createJobOutput.Key))),
+			ThumbnailPattern: createJobOutput.ThumbnailPattern,
+			Width:            aws.Int64(0),
+			Height:           aws.Int64(720),
 		}
 	}
 	playlists := make([]*elastictranscoder.Playlist, len(createJobInput.Playlists))
@@ -116,12 +136,13 @@ func (c *fakeElasticTranscoder) ReadJob(input *elastictranscoder.ReadJobInput) (
 	}
 	return &elastictranscoder.ReadJobOutput{
 		Job: &elastictranscoder.Job{
-			Id:         input.Id,
-			Input:      createJobInput.Input,
-			PipelineId: createJobInput.PipelineId,
-			Status:     aws.String("Complete"),
-			Outputs:    outputs,
-			Playlists:  playlists,
+			Id:              input.Id,
+			Input:           createJobInput.Input,
+			PipelineId:      createJobInput.PipelineId,
+			OutputKeyPrefix: createJobInput.OutputKeyPrefix,
+			Status:          aws.String("Complete"),
+			Outputs:         outputs,
+			Playlists:       playlists,
 		},
 	}, nil
 }
@@ -163,6 +184,33 @@ func (c *fakeElasticTranscoder) getError(op string) error {
 	return nil
 }
 
+// fakeS3 is a fake implementation of the S3 API, supporting the listing of
+// objects used by the provider.
+type fakeS3 struct {
+	s3iface.S3API
+
+	// objects maps S3 URLs to the size of the objects
+	objects map[string]int64
+}
+
+func (s *fakeS3) ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	prefix := "s3://" + aws.StringValue(input.Bucket) + "/" + aws.StringValue(input.Prefix)
+	var urls []string
+	for u := range s.objects {
+		if strings.HasPrefix(u, prefix) {
+			urls = append(urls, u)
+		}
+	}
+	sort.Strings(urls)
+	var output s3.ListObjectsV2Output
+	for _, u := range urls {
+		key := strings.TrimPrefix(u, "s3://"+aws.StringValue(input.Bucket)+"/")
+		output.Contents = append(output.Contents, &s3.Object{Key: aws.String(key), Size: aws.Int64(s.objects[u])})
+	}
+	fn(&output, true)
+	return nil
+}
+
 func generateID() []byte {
 	var b [4]byte
 	rand.Read(b[:])
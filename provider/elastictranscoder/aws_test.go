@@ -217,6 +217,130 @@ func TestAWSTranscode(t *testing.T) {
 	}
 }
 
+func TestAWSTranscodeThumbnails(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c:      fakeTranscoder,
+		config: &config.ElasticTranscoder{PipelineID: "mypipeline"},
+	}
+	job := db.Job{
+		ID:          "job-123",
+		SourceMedia: "dir/file.mov",
+		Outputs: []db.TranscodeOutput{
+			{FileName: "audio.mp4", Preset: db.PresetMap{Name: "audio", ProviderMapping: map[string]string{Name: "audio-0001"}}},
+			{FileName: "output-720p.mp4", Preset: db.PresetMap{Name: "mp4_720p", ProviderMapping: map[string]string{Name: "93239832-0001"}}},
+			{FileName: "output-1080p.mp4", Preset: db.PresetMap{Name: "mp4_1080p", ProviderMapping: map[string]string{Name: "93239832-0002"}}},
+		},
+		Thumbnails: &db.Thumbnails{Interval: 10, Width: 320, Format: "png", Path: "thumbnails/"},
+	}
+	jobStatus, err := prov.Transcode(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeTranscoder.presets) != 1 {
+		t.Fatalf("wrong number of presets created. Want 1. Got %d", len(fakeTranscoder.presets))
+	}
+	var thumbnailsPreset *elastictranscoder.Preset
+	for _, preset := range fakeTranscoder.presets {
+		thumbnailsPreset = preset
+	}
+	if name := aws.StringValue(thumbnailsPreset.Name); !regexp.MustCompile(`^thumbnails-93239832-0001-[a-f0-9]{8}$`).MatchString(name) {
+		t.Errorf("wrong name of the thumbnails preset: %q", name)
+	}
+	expectedThumbnails := &elastictranscoder.Thumbnails{
+		Format:        aws.String("png"),
+		Interval:      aws.String("10"),
+		MaxWidth:      aws.String("320"),
+		MaxHeight:     aws.String("auto"),
+		SizingPolicy:  aws.String("ShrinkToFit"),
+		PaddingPolicy: aws.String("NoPad"),
+	}
+	if !reflect.DeepEqual(thumbnailsPreset.Thumbnails, expectedThumbnails) {
+		t.Errorf("wrong thumbnail settings\nWant %#v\nGot  %#v", expectedThumbnails, thumbnailsPreset.Thumbnails)
+	}
+	expectedOutputs := []*elastictranscoder.CreateJobOutput{
+		{PresetId: aws.String("audio-0001"), Key: aws.String("job-123/audio.mp4")},
+		{PresetId: thumbnailsPreset.Id, Key: aws.String("job-123/output-720p.mp4"), ThumbnailPattern: aws.String("job-123/thumbnails/thumbnail-{count}")},
+		{PresetId: aws.String("93239832-0002"), Key: aws.String("job-123/output-1080p.mp4")},
+	}
+	if outputs := fakeTranscoder.jobs[jobStatus.ProviderJobID].Outputs; !reflect.DeepEqual(outputs, expectedOutputs) {
+		t.Errorf("wrong outputs\nWant %#v\nGot  %#v", expectedOutputs, outputs)
+	}
+
+	jobStatus, err = prov.Transcode(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeTranscoder.presets) != 1 {
+		t.Errorf("thumbnails preset not reused. Got %d presets", len(fakeTranscoder.presets))
+	}
+	if presetID := fakeTranscoder.jobs[jobStatus.ProviderJobID].Outputs[1].PresetId; *presetID != *thumbnailsPreset.Id {
+		t.Errorf("wrong preset id. Want %q. Got %q", *thumbnailsPreset.Id, *presetID)
+	}
+
+	job.Outputs = job.Outputs[:1]
+	_, err = prov.Transcode(&job)
+	if err != errNoVideoOutput {
+		t.Errorf("wrong error returned for audio-only job. Want errNoVideoOutput. Got %#v", err)
+	}
+}
+
+func TestAWSTranscodeClip(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c:      fakeTranscoder,
+		config: &config.ElasticTranscoder{PipelineID: "mypipeline"},
+	}
+	job := db.Job{
+		ID:          "job-123",
+		SourceMedia: "dir/file.mov",
+		Clip:        &db.Clip{Start: "10", End: "25.5"},
+		Outputs: []db.TranscodeOutput{
+			{FileName: "output-720p.mp4", Preset: db.PresetMap{Name: "mp4_720p", ProviderMapping: map[string]string{Name: "93239832-0001"}}},
+			{FileName: "output-1080p.mp4", Preset: db.PresetMap{Name: "mp4_1080p", ProviderMapping: map[string]string{Name: "93239832-0002"}}},
+		},
+	}
+	jobStatus, err := prov.Transcode(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobInput := fakeTranscoder.jobs[jobStatus.ProviderJobID]
+	expectedTimeSpan := &elastictranscoder.TimeSpan{StartTime: aws.String("10"), Duration: aws.String("15.5")}
+	if !reflect.DeepEqual(jobInput.Input.TimeSpan, expectedTimeSpan) {
+		t.Errorf("wrong time span of the input\nWant %#v\nGot  %#v", expectedTimeSpan, jobInput.Input.TimeSpan)
+	}
+	for _, output := range jobInput.Outputs {
+		if output.Composition != nil {
+			t.Errorf("unexpected composition in output %q: %#v", *output.Key, output.Composition)
+		}
+	}
+
+	job.Outputs[1].Clip = &db.Clip{Start: "0.0015"}
+	jobStatus, err = prov.Transcode(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobInput = fakeTranscoder.jobs[jobStatus.ProviderJobID]
+	if jobInput.Input.TimeSpan != nil {
+		t.Errorf("unexpected time span of the input: %#v", jobInput.Input.TimeSpan)
+	}
+	expectedOutputs := []*elastictranscoder.CreateJobOutput{
+		{
+			PresetId:    aws.String("93239832-0001"),
+			Key:         aws.String("job-123/output-720p.mp4"),
+			Composition: []*elastictranscoder.Clip{{TimeSpan: expectedTimeSpan}},
+		},
+		{
+			PresetId:    aws.String("93239832-0002"),
+			Key:         aws.String("job-123/output-1080p.mp4"),
+			Composition: []*elastictranscoder.Clip{{TimeSpan: &elastictranscoder.TimeSpan{StartTime: aws.String("0.001")}}},
+		},
+	}
+	if !reflect.DeepEqual(jobInput.Outputs, expectedOutputs) {
+		t.Errorf("wrong outputs\nWant %#v\nGot  %#v", expectedOutputs, jobInput.Outputs)
+	}
+}
+
 func TestAWSTranscodeAdaptiveStreaming(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	prov := &awsProvider{
@@ -326,6 +450,59 @@ func TestAWSTranscodeAdaptiveStreaming(t *testing.T) {
 	}
 }
 
+func TestAWSTranscodeAdaptiveStreamingAlternateAudio(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c:      fakeTranscoder,
+		config: &config.ElasticTranscoder{PipelineID: "mypipeline"},
+	}
+	outputs := []db.TranscodeOutput{
+		{
+			FileName: "output_720p_hls/video.m3u8",
+			Preset: db.PresetMap{
+				Name:            "hls_720p",
+				ProviderMapping: map[string]string{Name: "93239832-0001-hls"},
+				OutputOpts:      db.OutputOptions{Extension: "hls"},
+			},
+		},
+		{
+			FileName: "output_audio_hls/audio.m3u8",
+			Preset: db.PresetMap{
+				Name:            "hls_audio",
+				ProviderMapping: map[string]string{Name: "93239832-0002-hls-audio"},
+				OutputOpts:      db.OutputOptions{Extension: "hls"},
+			},
+		},
+	}
+	jobStatus, err := prov.Transcode(&db.Job{
+		ID:          "job-123",
+		SourceMedia: "dir/file.mov",
+		Outputs:     outputs,
+		StreamingParams: db.StreamingParams{
+			PlaylistFileName: "video.m3u8",
+			Protocol:         "hls",
+			SegmentDuration:  3,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobInput := fakeTranscoder.jobs[jobStatus.ProviderJobID]
+	expectedPlaylists := []*elastictranscoder.CreateJobPlaylist{
+		{
+			Format: aws.String("HLSv4"),
+			Name:   aws.String("job-123/video"),
+			OutputKeys: []*string{
+				aws.String("job-123/output_720p_hls/video"),
+				aws.String("job-123/output_audio_hls/audio"),
+			},
+		},
+	}
+	if !reflect.DeepEqual(jobInput.Playlists, expectedPlaylists) {
+		t.Errorf("Elastic Transcoder: wrong playlists\nWant %#v\nGot  %#v", expectedPlaylists, jobInput.Playlists)
+	}
+}
+
 func TestAWSTranscodeAdaptiveAndNonAdaptiveStreaming(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	prov := &awsProvider{
@@ -447,6 +624,108 @@ func TestAWSTranscodeAdaptiveAndNonAdaptiveStreaming(t *testing.T) {
 	}
 }
 
+func TestAWSTranscodeAdaptiveStreamingDASH(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c: fakeTranscoder,
+		config: &config.ElasticTranscoder{
+			AccessKeyID:     "AKIA",
+			SecretAccessKey: "secret",
+			Region:          "sa-east-1",
+			PipelineID:      "mypipeline",
+		},
+	}
+	source := "dir/file.mov"
+	outputs := []db.TranscodeOutput{
+		{
+			FileName: "dash/output_dash_480p/video.mpd",
+			Preset: db.PresetMap{
+				Name: "dash_480p",
+				ProviderMapping: map[string]string{
+					Name:    "93239832-0001-dash",
+					"other": "irrelevant",
+				},
+				OutputOpts: db.OutputOptions{Extension: "mpd"},
+			},
+		},
+		{
+			FileName: "dash/output_dash_720p/video.mpd",
+			Preset: db.PresetMap{
+				Name: "dash_720p",
+				ProviderMapping: map[string]string{
+					Name:    "93239832-0002-dash",
+					"other": "irrelevant",
+				},
+				OutputOpts: db.OutputOptions{Extension: "mpd"},
+			},
+		},
+		{
+			FileName: "hls/output_hls_720p/video.m3u8",
+			Preset: db.PresetMap{
+				Name: "hls_720p",
+				ProviderMapping: map[string]string{
+					Name:    "93239832-0003-hls",
+					"other": "irrelevant",
+				},
+				OutputOpts: db.OutputOptions{Extension: "m3u8"},
+			},
+		},
+	}
+
+	jobStatus, err := prov.Transcode(&db.Job{
+		ID:          "job-123",
+		SourceMedia: source,
+		Outputs:     outputs,
+		StreamingParams: db.StreamingParams{
+			PlaylistFileName: "dash/manifest.mpd",
+			Protocol:         "dash",
+			SegmentDuration:  3,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobInput := fakeTranscoder.jobs[jobStatus.ProviderJobID]
+	expectedOutputs := []*elastictranscoder.CreateJobOutput{
+		{PresetId: aws.String("93239832-0001-dash"), Key: aws.String("job-123/dash/output_dash_480p/video"), SegmentDuration: aws.String("3")},
+		{PresetId: aws.String("93239832-0002-dash"), Key: aws.String("job-123/dash/output_dash_720p/video"), SegmentDuration: aws.String("3")},
+		{PresetId: aws.String("93239832-0003-hls"), Key: aws.String("job-123/hls/output_hls_720p/video"), SegmentDuration: aws.String("3")},
+	}
+	if !reflect.DeepEqual(jobInput.Outputs, expectedOutputs) {
+		t.Errorf("Elastic Transcoder: wrong outputs\nWant %#v\nGot  %#v", expectedOutputs, jobInput.Outputs)
+	}
+	expectedPlaylists := []*elastictranscoder.CreateJobPlaylist{
+		{
+			Format:     aws.String("HLSv3"),
+			Name:       aws.String("job-123/hls/index"),
+			OutputKeys: []*string{aws.String("job-123/hls/output_hls_720p/video")},
+		},
+		{
+			Format: aws.String("MPEG-DASH"),
+			Name:   aws.String("job-123/dash/manifest"),
+			OutputKeys: []*string{
+				aws.String("job-123/dash/output_dash_480p/video"),
+				aws.String("job-123/dash/output_dash_720p/video"),
+			},
+		},
+	}
+	if !reflect.DeepEqual(jobInput.Playlists, expectedPlaylists) {
+		t.Errorf("Elastic Transcoder: wrong playlists\nWant %#v\nGot  %#v", expectedPlaylists, jobInput.Playlists)
+	}
+
+	jobStatus, err = prov.JobStatus(&db.Job{ID: "job-123", ProviderJobID: jobStatus.ProviderJobID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFiles := []provider.OutputFile{
+		{Path: "s3://some bucket/job-123/hls/index.m3u8", Container: "m3u8"},
+		{Path: "s3://some bucket/job-123/dash/manifest.mpd", Container: "mpd"},
+	}
+	if !reflect.DeepEqual(jobStatus.Output.Files, expectedFiles) {
+		t.Errorf("Elastic Transcoder: wrong output files\nWant %#v\nGot  %#v", expectedFiles, jobStatus.Output.Files)
+	}
+}
+
 func TestAWSTranscodeNormalizedSource(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	prov := &awsProvider{
@@ -528,6 +807,84 @@ func TestAWSTranscodeNormalizedSource(t *testing.T) {
 	}
 }
 
+func TestAWSTranscodeJobDestination(t *testing.T) {
+	var tests = []struct {
+		givenDestination      string
+		wantOutputKeyPrefix   *string
+		wantOutputDestination string
+		wantErr               string
+	}{
+		{
+			"s3://some bucket/videos/",
+			aws.String("videos/"),
+			"s3://some bucket/videos/job-1",
+			"",
+		},
+		{
+			"s3://some bucket",
+			nil,
+			"s3://some bucket/job-1",
+			"",
+		},
+		{
+			"s3://other-bucket/videos",
+			nil,
+			"",
+			`invalid destination "s3://other-bucket/videos": outputs must be in the output bucket of the pipeline (some bucket)`,
+		},
+		{
+			"gs://some bucket/videos",
+			nil,
+			"",
+			`invalid destination "gs://some bucket/videos": must be an S3 URL`,
+		},
+	}
+	for _, test := range tests {
+		fakeTranscoder := newFakeElasticTranscoder()
+		prov := &awsProvider{
+			c:      fakeTranscoder,
+			config: &config.ElasticTranscoder{PipelineID: "mypipeline"},
+		}
+		job := db.Job{
+			ID:          "job-1",
+			SourceMedia: "s3://bucketname/file.mov",
+			Destination: test.givenDestination,
+			Outputs: []db.TranscodeOutput{
+				{
+					FileName: "output_720p.mp4",
+					Preset: db.PresetMap{
+						Name:            "mp4_720p",
+						ProviderMapping: map[string]string{Name: "93239832-0001"},
+						OutputOpts:      db.OutputOptions{Extension: "mp4"},
+					},
+				},
+			},
+		}
+		jobStatus, err := prov.Transcode(&job)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("%s: wrong error returned. Want %q. Got %v", test.givenDestination, test.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobInput := fakeTranscoder.jobs[jobStatus.ProviderJobID]
+		if !reflect.DeepEqual(jobInput.OutputKeyPrefix, test.wantOutputKeyPrefix) {
+			t.Errorf("%s: wrong output key prefix. Want %v. Got %v", test.givenDestination, aws.StringValue(test.wantOutputKeyPrefix), aws.StringValue(jobInput.OutputKeyPrefix))
+		}
+		job.ProviderJobID = jobStatus.ProviderJobID
+		jobStatus, err = prov.JobStatus(&job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if jobStatus.Output.Destination != test.wantOutputDestination {
+			t.Errorf("%s: wrong output destination. Want %q. Got %q", test.givenDestination, test.wantOutputDestination, jobStatus.Output.Destination)
+		}
+	}
+}
+
 func TestAWSTranscodePresetNotFound(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	prov := &awsProvider{
@@ -701,6 +1058,41 @@ func TestAWSJobStatus(t *testing.T) {
 	}
 }
 
+func TestAWSJobStatusThumbnails(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c: fakeTranscoder,
+		s3: &fakeS3{objects: map[string]int64{
+			"s3://some bucket/job-123/output_720p.mp4":                4096,
+			"s3://some bucket/job-123/thumbnails/thumbnail-00001.jpg": 1024,
+			"s3://some bucket/job-123/thumbnails/thumbnail-00002.jpg": 1024,
+		}},
+		config: &config.ElasticTranscoder{PipelineID: "mypipeline"},
+	}
+	jobStatus, err := prov.Transcode(&db.Job{
+		ID:          "job-123",
+		SourceMedia: "dir/file.mov",
+		Outputs: []db.TranscodeOutput{
+			{FileName: "output_720p.mp4", Preset: db.PresetMap{Name: "mp4_720p", ProviderMapping: map[string]string{Name: "93239832-0001"}}},
+		},
+		Thumbnails: &db.Thumbnails{Interval: 10, Format: "jpg", Path: "thumbnails"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobStatus, err = prov.JobStatus(&db.Job{ID: "job-123", ProviderJobID: jobStatus.ProviderJobID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []provider.OutputFile{
+		{Path: "s3://some bucket/job-123/thumbnails/thumbnail-00001.jpg", Container: "jpg", FileSize: 1024},
+		{Path: "s3://some bucket/job-123/thumbnails/thumbnail-00002.jpg", Container: "jpg", FileSize: 1024},
+	}
+	if !reflect.DeepEqual(jobStatus.Output.Thumbnails, expected) {
+		t.Errorf("wrong thumbnails\nWant %#v\nGot  %#v", expected, jobStatus.Output.Thumbnails)
+	}
+}
+
 func TestAWSJobStatusNoDetectedProperties(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	prov := &awsProvider{
@@ -826,6 +1218,90 @@ func TestAWSCreatePreset(t *testing.T) {
 	}
 }
 
+func TestAWSNormalizePreset(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c: fakeTranscoder,
+		config: &config.ElasticTranscoder{
+			AccessKeyID:     "AKIA",
+			SecretAccessKey: "secret",
+			Region:          "sa-east-1",
+			PipelineID:      "mypipeline",
+		},
+	}
+	preset := db.Preset{
+		Name:        "preset_name",
+		Description: "description here",
+		Container:   "m3u8",
+		Video: db.VideoPreset{
+			Profile:      "Main",
+			ProfileLevel: "3.1",
+			Height:       "720",
+			Codec:        "h264",
+			Bitrate:      "2500000",
+			GopSize:      "90",
+			GopMode:      "fixed",
+			MaxBitrate:   "3000000",
+		},
+		Audio: db.AudioPreset{Codec: "aac", Bitrate: "64000", Channels: "2"},
+	}
+	expected := db.Preset{
+		Description: "description here",
+		Container:   "m3u8",
+		Video: db.VideoPreset{
+			Profile:         "main",
+			ProfileLevel:    "3.1",
+			Height:          "720",
+			Codec:           "h264",
+			Bitrate:         "2500000",
+			GopSize:         "90",
+			GopMode:         "fixed",
+			MaxBitrate:      "3000000",
+			ReferenceFrames: "2",
+		},
+		Audio: db.AudioPreset{Codec: "aac", Bitrate: "64000", Channels: "2"},
+	}
+	normalized, err := prov.NormalizePreset(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(normalized, expected) {
+		t.Errorf("wrong normalized preset\nwant %#v\ngot  %#v", expected, normalized)
+	}
+	presetID, err := prov.CreatePreset(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := *fakeTranscoder.presets[presetID]
+	normalized, err = prov.NormalizePreset(&elastictranscoder.ReadPresetOutput{Preset: &stored})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := db.DiffPresets(expected, normalized); len(diffs) > 0 {
+		t.Errorf("unexpected differences in the stored preset: %#v", diffs)
+	}
+
+	// the preset is changed in the console of Elastic Transcoder.
+	video := *stored.Video
+	video.BitRate = aws.String("1800")
+	video.MaxHeight = aws.String("480")
+	stored.Video = &video
+	normalized, err = prov.NormalizePreset(&elastictranscoder.ReadPresetOutput{Preset: &stored})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedDiffs := []db.PresetFieldDiff{
+		{Field: "video.height", From: "720", To: "480"},
+		{Field: "video.bitrate", From: "2500000", To: "1800000"},
+	}
+	if diffs := db.DiffPresets(expected, normalized); !reflect.DeepEqual(diffs, expectedDiffs) {
+		t.Errorf("wrong differences\nwant %#v\ngot  %#v", expectedDiffs, diffs)
+	}
+	if _, err = prov.NormalizePreset("preset_name"); err == nil {
+		t.Error("unexpected <nil> error for invalid preset type")
+	}
+}
+
 func TestCreateVideoPreset(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	prov := &awsProvider{
@@ -898,8 +1374,9 @@ func TestCreateVideoPreset(t *testing.T) {
 			db.Preset{
 				Container: "webm",
 				Video: db.VideoPreset{
-					Codec:   "vp9",
-					GopSize: "90",
+					Codec:     "vp9",
+					GopSize:   "90",
+					FrameRate: "29.97",
 				},
 			},
 			&elastictranscoder.VideoParameters{
@@ -909,7 +1386,7 @@ func TestCreateVideoPreset(t *testing.T) {
 					"Profile": aws.String("0"),
 				},
 				DisplayAspectRatio: aws.String("auto"),
-				FrameRate:          aws.String("auto"),
+				FrameRate:          aws.String("29.97"),
 				KeyframesMaxDist:   aws.String("90"),
 				MaxHeight:          aws.String("auto"),
 				MaxWidth:           aws.String("auto"),
@@ -917,6 +1394,39 @@ func TestCreateVideoPreset(t *testing.T) {
 				SizingPolicy:       aws.String("Fill"),
 			},
 		},
+		{
+			"H.264 preset with buffer settings",
+			db.Preset{
+				Container: "mp4",
+				Video: db.VideoPreset{
+					Profile:         "High",
+					ProfileLevel:    "4.1",
+					Codec:           "h264",
+					Bitrate:         "5000000",
+					MaxBitrate:      "7500000",
+					BufferSize:      "10000000",
+					ReferenceFrames: "4",
+				},
+			},
+			&elastictranscoder.VideoParameters{
+				BitRate: aws.String("5000"),
+				Codec:   aws.String("H.264"),
+				CodecOptions: map[string]*string{
+					"MaxReferenceFrames": aws.String("4"),
+					"MaxBitRate":         aws.String("7500"),
+					"BufferSize":         aws.String("10000"),
+					"Profile":            aws.String("high"),
+					"Level":              aws.String("4.1"),
+				},
+				DisplayAspectRatio: aws.String("auto"),
+				FrameRate:          aws.String("auto"),
+				KeyframesMaxDist:   aws.String(""),
+				MaxHeight:          aws.String("auto"),
+				MaxWidth:           aws.String("auto"),
+				PaddingPolicy:      aws.String("Pad"),
+				SizingPolicy:       aws.String("Fill"),
+			},
+		},
 		{
 			"MP4 preset",
 			db.Preset{
@@ -947,7 +1457,11 @@ func TestCreateVideoPreset(t *testing.T) {
 		},
 	}
 	for _, test := range tests {
-		videoParams := prov.createVideoPreset(test.givenPreset)
+		videoParams, err := prov.createVideoPreset(test.givenPreset)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
 		if !reflect.DeepEqual(test.expectedVideoParams, videoParams) {
 			t.Errorf("%s: CreateVideoPreset: want %s. Got %s", test.givenTestCase, test.expectedVideoParams, videoParams)
 			pretty.Fdiff(os.Stderr, videoParams, test.expectedVideoParams)
@@ -955,6 +1469,55 @@ func TestCreateVideoPreset(t *testing.T) {
 	}
 }
 
+func TestCreateVideoPresetUnsupportedParameters(t *testing.T) {
+	prov := &awsProvider{c: newFakeElasticTranscoder()}
+	var tests = []struct {
+		givenTestCase string
+		givenVideo    db.VideoPreset
+		wantErr       error
+	}{
+		{
+			"HEVC",
+			db.VideoPreset{Codec: "hevc", Bitrate: "5000000"},
+			provider.UnsupportedParameterError{Parameter: "video.codec", Value: "hevc"},
+		},
+		{
+			"AV1",
+			db.VideoPreset{Codec: "av1", CRF: "30"},
+			provider.UnsupportedParameterError{Parameter: "video.codec", Value: "av1"},
+		},
+		{
+			"CRF in H.264",
+			db.VideoPreset{Codec: "h264", CRF: "23"},
+			provider.UnsupportedParameterError{Parameter: "video.crf", Value: "23"},
+		},
+		{
+			"two-pass H.264",
+			db.VideoPreset{Codec: "h264", Bitrate: "5000000", TwoPass: true},
+			provider.UnsupportedParameterError{Parameter: "video.twoPass", Value: "true"},
+		},
+		{
+			"max bitrate in VP9",
+			db.VideoPreset{Codec: "vp9", MaxBitrate: "3000000"},
+			provider.UnsupportedParameterError{Parameter: "video.maxBitrate", Value: "3000000"},
+		},
+		{
+			"unsupported frame rate",
+			db.VideoPreset{Codec: "h264", FrameRate: "12.5"},
+			provider.UnsupportedParameterError{Parameter: "video.frameRate", Value: "12.5"},
+		},
+	}
+	for _, test := range tests {
+		videoParams, err := prov.createVideoPreset(db.Preset{Container: "mp4", Video: test.givenVideo})
+		if !reflect.DeepEqual(err, test.wantErr) {
+			t.Errorf("%s: wrong error returned. Want %#v. Got %#v", test.givenTestCase, test.wantErr, err)
+		}
+		if videoParams != nil {
+			t.Errorf("%s: unexpected non-nil video params: %#v", test.givenTestCase, videoParams)
+		}
+	}
+}
+
 func TestCreateAudioPreset(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	prov := &awsProvider{
@@ -999,9 +1562,30 @@ func TestCreateAudioPreset(t *testing.T) {
 				SampleRate: aws.String("auto"),
 			},
 		},
+		{
+			"AAC preset with channels and sample rate",
+			db.Preset{
+				Audio: db.AudioPreset{
+					Codec:         "aac",
+					Bitrate:       "96000",
+					ChannelLayout: "mono",
+					SampleRate:    "44100",
+				},
+			},
+			&elastictranscoder.AudioParameters{
+				BitRate:    aws.String("96"),
+				Channels:   aws.String("1"),
+				Codec:      aws.String("AAC"),
+				SampleRate: aws.String("44100"),
+			},
+		},
 	}
 	for _, test := range tests {
-		audioParams := prov.createAudioPreset(test.givenPreset)
+		audioParams, err := prov.createAudioPreset(test.givenPreset)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
 		if !reflect.DeepEqual(test.expectedAudioParams, audioParams) {
 			t.Errorf("%s: CreateAudioPreset: want %s. Got %s", test.givenTestCase, test.expectedAudioParams, audioParams)
 			pretty.Fdiff(os.Stderr, audioParams, test.expectedAudioParams)
@@ -1009,6 +1593,66 @@ func TestCreateAudioPreset(t *testing.T) {
 	}
 }
 
+func TestCreateAudioPresetUnsupportedParameters(t *testing.T) {
+	prov := &awsProvider{c: newFakeElasticTranscoder()}
+	var tests = []struct {
+		givenTestCase string
+		givenPreset   db.Preset
+		expectedErr   error
+	}{
+		{
+			"surround audio",
+			db.Preset{Audio: db.AudioPreset{Codec: "aac", Channels: "6", ChannelLayout: "5.1"}},
+			provider.UnsupportedParameterError{Parameter: "audio.channelLayout", Value: "5.1"},
+		},
+		{
+			"unsupported sample rate",
+			db.Preset{Audio: db.AudioPreset{Codec: "aac", SampleRate: "8000"}},
+			provider.UnsupportedParameterError{Parameter: "audio.sampleRate", Value: "8000"},
+		},
+		{
+			"loudness normalization",
+			db.Preset{Audio: db.AudioPreset{Codec: "aac", Loudness: "-23"}},
+			provider.UnsupportedParameterError{Parameter: "audio.loudness", Value: "-23"},
+		},
+		{
+			"multiple audio tracks",
+			db.Preset{Audio: db.AudioPreset{Codec: "aac"}, AudioTracks: []db.AudioPreset{{Codec: "aac", Language: "es"}}},
+			provider.UnsupportedParameterError{Parameter: "audioTracks", Value: "1"},
+		},
+	}
+	for _, test := range tests {
+		_, err := prov.createAudioPreset(test.givenPreset)
+		if !reflect.DeepEqual(err, test.expectedErr) {
+			t.Errorf("%s: wrong error returned. Want %#v. Got %#v", test.givenTestCase, test.expectedErr, err)
+		}
+	}
+}
+
+func TestAWSCreateAudioOnlyPreset(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{c: fakeTranscoder}
+	preset := db.Preset{
+		Name:      "hls_audio",
+		Container: "m3u8",
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000", Channels: "2"},
+	}
+	presetID, err := prov.CreatePreset(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if presetID != "hls_audio-abc123" {
+		t.Errorf("wrong preset id returned. Want %q. Got %q", "hls_audio-abc123", presetID)
+	}
+	createdPreset := fakeTranscoder.presets[presetID]
+	if createdPreset.Video != nil || createdPreset.Thumbnails != nil {
+		t.Errorf("unexpected video parameters in audio-only preset: %#v", createdPreset)
+	}
+	if aws.StringValue(createdPreset.Audio.Channels) != "2" {
+		t.Errorf("wrong audio channels. Want %q. Got %q", "2", aws.StringValue(createdPreset.Audio.Channels))
+	}
+}
+
 func TestAWSJobStatusNotFound(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	provider := &awsProvider{
@@ -1155,8 +1799,21 @@ func TestCapabilities(t *testing.T) {
 	var prov awsProvider
 	expected := provider.Capabilities{
 		InputFormats:  []string{"h264"},
-		OutputFormats: []string{"mp4", "hls", "webm"},
+		OutputFormats: []string{"mp4", "hls", "dash", "webm"},
 		Destinations:  []string{"s3"},
+
+		PresetConstraints: &provider.PresetConstraints{
+			VideoCodecs: map[string][]string{
+				"h264":  {"maxBitrate", "bufferSize", "referenceFrames", "frameRate"},
+				"vp8":   {"frameRate"},
+				"vp9":   {"frameRate"},
+				"mpeg2": {"frameRate"},
+				"gif":   {"frameRate"},
+			},
+			AudioParameters: []string{"channels", "channelLayout", "sampleRate", "audioOnly"},
+		},
+		Thumbnails: &provider.ThumbnailCapabilities{Formats: []string{"jpg", "png"}},
+		Clipping:   &provider.ClippingCapabilities{PerOutput: true},
 	}
 	cap := prov.Capabilities()
 	if !reflect.DeepEqual(cap, expected) {
@@ -0,0 +1,107 @@
+package elastictranscoder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elastictranscoder"
+)
+
+var (
+	// normalizedContainers maps the containers of Elastic Transcoder
+	// presets back to the containers of the API, reverting CreatePreset.
+	normalizedContainers = map[string]string{"ts": "m3u8", "fmp4": "mpd"}
+
+	normalizedVideoCodecs = map[string]string{"H.264": "h264"}
+	normalizedAudioCodecs = map[string]string{"AAC": "aac", "vorbis": "libvorbis"}
+)
+
+func (p *awsProvider) NormalizePreset(preset interface{}) (db.Preset, error) {
+	var description, container *string
+	var audio *elastictranscoder.AudioParameters
+	var video *elastictranscoder.VideoParameters
+	switch preset := preset.(type) {
+	case db.Preset:
+		input, err := p.presetInput(preset)
+		if err != nil {
+			return db.Preset{}, err
+		}
+		description, container, audio, video = input.Description, input.Container, input.Audio, input.Video
+	case *elastictranscoder.ReadPresetOutput:
+		if preset.Preset == nil {
+			return db.Preset{}, fmt.Errorf("missing preset in %T", preset)
+		}
+		description, container, audio, video = preset.Preset.Description, preset.Preset.Container, preset.Preset.Audio, preset.Preset.Video
+	default:
+		return db.Preset{}, fmt.Errorf("unexpected preset type %T", preset)
+	}
+	normalized := db.Preset{
+		Description: aws.StringValue(description),
+		Container:   lookupValue(normalizedContainers, aws.StringValue(container)),
+	}
+	if audio != nil {
+		normalized.Audio = db.AudioPreset{
+			Codec:      lookupValue(normalizedAudioCodecs, aws.StringValue(audio.Codec)),
+			Bitrate:    kilobitsToBits(audio.BitRate),
+			Channels:   autoValue(audio.Channels),
+			SampleRate: autoValue(audio.SampleRate),
+		}
+	}
+	if video != nil {
+		normalized.Video = normalizeVideo(video)
+	}
+	return normalized, nil
+}
+
+// normalizeVideo translates the given video parameters back to the video
+// parameters of a preset, reverting createVideoPreset.
+func normalizeVideo(params *elastictranscoder.VideoParameters) db.VideoPreset {
+	video := db.VideoPreset{
+		Codec:     lookupValue(normalizedVideoCodecs, aws.StringValue(params.Codec)),
+		Width:     autoValue(params.MaxWidth),
+		Height:    autoValue(params.MaxHeight),
+		Bitrate:   kilobitsToBits(params.BitRate),
+		GopSize:   aws.StringValue(params.KeyframesMaxDist),
+		FrameRate: autoValue(params.FrameRate),
+	}
+	if aws.StringValue(params.FixedGOP) == "true" {
+		video.GopMode = "fixed"
+	}
+	if video.Codec == "h264" {
+		video.Profile = aws.StringValue(params.CodecOptions["Profile"])
+		video.ProfileLevel = aws.StringValue(params.CodecOptions["Level"])
+		video.ReferenceFrames = aws.StringValue(params.CodecOptions["MaxReferenceFrames"])
+		video.MaxBitrate = kilobitsToBits(params.CodecOptions["MaxBitRate"])
+		video.BufferSize = kilobitsToBits(params.CodecOptions["BufferSize"])
+	}
+	return video
+}
+
+// lookupValue returns the value of the given key in the map, or the key
+// itself, in lowercase, if it's not in the map.
+func lookupValue(m map[string]string, key string) string {
+	if value, ok := m[key]; ok {
+		return value
+	}
+	return strings.ToLower(key)
+}
+
+// autoValue returns the given value, or an empty string when it's auto.
+func autoValue(value *string) string {
+	if v := aws.StringValue(value); v != "auto" {
+		return v
+	}
+	return ""
+}
+
+// kilobitsToBits converts the given bitrate, in kilobits, to bits.
+func kilobitsToBits(value *string) string {
+	kilobits, err := strconv.Atoi(aws.StringValue(value))
+	if err != nil || kilobits == 0 {
+		return ""
+	}
+	return strconv.Itoa(kilobits * 1000)
+}
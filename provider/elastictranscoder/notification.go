@@ -0,0 +1,228 @@
+package elastictranscoder
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+var (
+	snsCertHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+	snsClient          = &http.Client{Timeout: 10 * time.Second}
+
+	snsCertsMtx sync.Mutex
+	snsCerts    = make(map[string]*x509.Certificate)
+)
+
+// snsMessage is the envelope of messages delivered by Amazon SNS to HTTP
+// endpoints.
+type snsMessage struct {
+	Type             string
+	MessageID        string `json:"MessageId"`
+	Token            string
+	TopicArn         string
+	Subject          string
+	Message          string
+	Timestamp        string
+	SignatureVersion string
+	Signature        string
+	SigningCertURL   string
+	SubscribeURL     string
+}
+
+// notification is the message published by Elastic Transcoder to the SNS
+// topic of the pipeline.
+type notification struct {
+	State          string `json:"state"`
+	JobID          string `json:"jobId"`
+	PipelineID     string `json:"pipelineId"`
+	ErrorCode      int    `json:"errorCode"`
+	MessageDetails string `json:"messageDetails"`
+	Outputs        []struct {
+		Key          string `json:"key"`
+		Status       string `json:"status"`
+		StatusDetail string `json:"statusDetail"`
+	} `json:"outputs"`
+}
+
+// HandleNotification handles notifications sent by Elastic Transcoder through
+// Amazon SNS. Messages are authenticated using their signature, and only
+// messages from the topic defined in the
+// ELASTICTRANSCODER_NOTIFICATION_TOPIC_ARN environment variable are accepted.
+// Subscription confirmations are confirmed automatically.
+//
+// Elastic Transcoder notifications don't include the location of the output
+// files, so the returned status doesn't include them.
+func (p *awsProvider) HandleNotification(r *http.Request) (*provider.JobStatus, error) {
+	var msg snsMessage
+	err := json.NewDecoder(r.Body).Decode(&msg)
+	if err != nil {
+		return nil, provider.InvalidNotificationError(err.Error())
+	}
+	if p.config.NotificationTopicARN == "" || msg.TopicArn != p.config.NotificationTopicARN {
+		return nil, provider.ErrNotificationUnauthorized
+	}
+	err = p.verifySNSMessage(&msg)
+	if err != nil {
+		return nil, provider.ErrNotificationUnauthorized
+	}
+	switch msg.Type {
+	case "SubscriptionConfirmation":
+		return nil, p.confirmSubscription(msg.SubscribeURL)
+	case "Notification":
+	default:
+		return nil, nil
+	}
+	var n notification
+	err = json.Unmarshal([]byte(msg.Message), &n)
+	if err != nil {
+		return nil, provider.InvalidNotificationError(err.Error())
+	}
+	if n.JobID == "" {
+		return nil, provider.InvalidNotificationError("missing job id")
+	}
+	if n.PipelineID != p.config.PipelineID {
+		return nil, provider.InvalidNotificationError(fmt.Sprintf("unknown pipeline %q", n.PipelineID))
+	}
+	outputs := make(map[string]interface{}, len(n.Outputs))
+	for _, output := range n.Outputs {
+		outputs[output.Key] = output.StatusDetail
+	}
+	status := provider.JobStatus{
+		ProviderJobID:  n.JobID,
+		Status:         p.notificationStatusMap(n.State),
+		StatusMessage:  n.MessageDetails,
+		ProviderStatus: map[string]interface{}{"outputs": outputs},
+	}
+	if status.Status == provider.StatusFinished {
+		status.Progress = 100
+	}
+	return &status, nil
+}
+
+func (p *awsProvider) notificationStatusMap(state string) provider.Status {
+	switch state {
+	case "PROGRESSING", "WARNING":
+		return provider.StatusStarted
+	case "COMPLETED":
+		return provider.StatusFinished
+	case "ERROR":
+		return provider.StatusFailed
+	default:
+		return provider.StatusUnknown
+	}
+}
+
+func (p *awsProvider) confirmSubscription(subscribeURL string) error {
+	resp, err := snsClient.Get(subscribeURL)
+	if err != nil {
+		return fmt.Errorf("failed to confirm SNS subscription: %s", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to confirm SNS subscription: %s", resp.Status)
+	}
+	return nil
+}
+
+// verifySNSMessage checks the signature of the message, as described in
+// http://docs.aws.amazon.com/sns/latest/dg/SendMessageToHttp.verify.signature.html.
+func (p *awsProvider) verifySNSMessage(msg *snsMessage) error {
+	var algorithm x509.SignatureAlgorithm
+	switch msg.SignatureVersion {
+	case "1":
+		algorithm = x509.SHA1WithRSA
+	case "2":
+		algorithm = x509.SHA256WithRSA
+	default:
+		return fmt.Errorf("unsupported signature version %q", msg.SignatureVersion)
+	}
+	signature, err := base64.StdEncoding.DecodeString(msg.Signature)
+	if err != nil {
+		return err
+	}
+	getCertificate := p.getCertificate
+	if getCertificate == nil {
+		getCertificate = fetchSNSCertificate
+	}
+	cert, err := getCertificate(msg.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	return cert.CheckSignature(algorithm, snsStringToSign(msg), signature)
+}
+
+func snsStringToSign(msg *snsMessage) []byte {
+	keys := []string{"Message", "MessageId", "SubscribeURL", "Timestamp", "Token", "TopicArn", "Type"}
+	if msg.Type == "Notification" {
+		keys = []string{"Message", "MessageId", "Subject", "Timestamp", "TopicArn", "Type"}
+	}
+	values := map[string]string{
+		"Message":      msg.Message,
+		"MessageId":    msg.MessageID,
+		"Subject":      msg.Subject,
+		"SubscribeURL": msg.SubscribeURL,
+		"Timestamp":    msg.Timestamp,
+		"Token":        msg.Token,
+		"TopicArn":     msg.TopicArn,
+		"Type":         msg.Type,
+	}
+	var buf bytes.Buffer
+	for _, key := range keys {
+		if key == "Subject" && msg.Subject == "" {
+			continue
+		}
+		buf.WriteString(key + "\n" + values[key] + "\n")
+	}
+	return buf.Bytes()
+}
+
+// fetchSNSCertificate downloads the certificate used for signing SNS
+// messages, making sure that it's hosted by Amazon SNS.
+func fetchSNSCertificate(certURL string) (*x509.Certificate, error) {
+	u, err := url.Parse(certURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" || !snsCertHostPattern.MatchString(u.Host) {
+		return nil, fmt.Errorf("invalid signing certificate URL %q", certURL)
+	}
+	snsCertsMtx.Lock()
+	defer snsCertsMtx.Unlock()
+	if cert, ok := snsCerts[certURL]; ok {
+		return cert, nil
+	}
+	resp, err := snsClient.Get(certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid signing certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	snsCerts[certURL] = cert
+	return cert, nil
+}
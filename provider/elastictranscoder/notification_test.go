@@ -0,0 +1,254 @@
+package elastictranscoder
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/kr/pretty"
+)
+
+const (
+	testTopicARN = "arn:aws:sns:us-east-1:123456789012:transcoding"
+	testCertURL  = "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem"
+)
+
+type snsSigner struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newSNSSigner(t *testing.T) *snsSigner {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &snsSigner{key: key, cert: cert}
+}
+
+func (s *snsSigner) getCertificate(certURL string) (*x509.Certificate, error) {
+	if certURL != testCertURL {
+		return nil, errors.New("unexpected certificate URL")
+	}
+	return s.cert, nil
+}
+
+func (s *snsSigner) request(t *testing.T, msg snsMessage) *http.Request {
+	msg.SignatureVersion = "1"
+	msg.SigningCertURL = testCertURL
+	hashed := sha1.Sum(snsStringToSign(&msg))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA1, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.Signature = base64.StdEncoding.EncodeToString(signature)
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := http.NewRequest("POST", "http://transcoding.api/notifications/elastictranscoder", bytes.NewReader(data))
+	r.Header.Set("x-amz-sns-message-type", msg.Type)
+	return r
+}
+
+func newNotificationProvider(signer *snsSigner) *awsProvider {
+	return &awsProvider{
+		config: &config.ElasticTranscoder{
+			PipelineID:           "mypipeline",
+			NotificationTopicARN: testTopicARN,
+		},
+		getCertificate: signer.getCertificate,
+	}
+}
+
+func TestAWSHandleNotification(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenFile     string
+		wantStatus    *provider.JobStatus
+	}{
+		{
+			"job completed",
+			"testdata/job_completed.json",
+			&provider.JobStatus{
+				ProviderJobID:  "1490121688017-jd1wfw",
+				Status:         provider.StatusFinished,
+				Progress:       100,
+				ProviderStatus: map[string]interface{}{"outputs": map[string]interface{}{"video_720p.mp4": ""}},
+			},
+		},
+		{
+			"job error",
+			"testdata/job_error.json",
+			&provider.JobStatus{
+				ProviderJobID: "1490121688017-xk2jf1",
+				Status:        provider.StatusFailed,
+				StatusMessage: "3002 25319782: The specified object could not be saved in the specified bucket because an object by that name already exists: bucket=mybucket, key=job-456/video_720p.mp4.",
+				ProviderStatus: map[string]interface{}{
+					"outputs": map[string]interface{}{
+						"video_720p.mp4": "3002 25319782: The specified object could not be saved in the specified bucket because an object by that name already exists: bucket=mybucket, key=job-456/video_720p.mp4.",
+					},
+				},
+			},
+		},
+	}
+	signer := newSNSSigner(t)
+	prov := newNotificationProvider(signer)
+	for _, test := range tests {
+		message, err := ioutil.ReadFile(test.givenFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := signer.request(t, snsMessage{
+			Type:      "Notification",
+			MessageID: "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+			TopicArn:  testTopicARN,
+			Message:   string(message),
+			Timestamp: "2017-03-21T18:44:05.312Z",
+		})
+		status, err := prov.HandleNotification(r)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
+		if !reflect.DeepEqual(status, test.wantStatus) {
+			pretty.Fdiff(os.Stderr, test.wantStatus, status)
+			t.Errorf("%s: wrong status returned\nwant %#v\ngot  %#v", test.givenTestCase, test.wantStatus, status)
+		}
+	}
+}
+
+func TestAWSHandleNotificationSubscriptionConfirmation(t *testing.T) {
+	var confirmed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		confirmed = r.URL.Query().Get("Token") == "some-token"
+	}))
+	defer server.Close()
+	signer := newSNSSigner(t)
+	prov := newNotificationProvider(signer)
+	r := signer.request(t, snsMessage{
+		Type:         "SubscriptionConfirmation",
+		MessageID:    "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+		Token:        "some-token",
+		TopicArn:     testTopicARN,
+		Message:      "You have chosen to subscribe to the topic " + testTopicARN,
+		SubscribeURL: server.URL + "/?Action=ConfirmSubscription&Token=some-token",
+		Timestamp:    "2017-03-21T18:40:05.312Z",
+	})
+	status, err := prov.HandleNotification(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != nil {
+		t.Errorf("unexpected non-nil status: %#v", status)
+	}
+	if !confirmed {
+		t.Error("subscription was not confirmed")
+	}
+}
+
+func TestAWSHandleNotificationErrors(t *testing.T) {
+	signer := newSNSSigner(t)
+	otherSigner := newSNSSigner(t)
+	message, err := ioutil.ReadFile("testdata/job_completed.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	validMessage := snsMessage{
+		Type:      "Notification",
+		MessageID: "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+		TopicArn:  testTopicARN,
+		Message:   string(message),
+		Timestamp: "2017-03-21T18:44:05.312Z",
+	}
+	otherTopicMessage := validMessage
+	otherTopicMessage.TopicArn = "arn:aws:sns:us-east-1:123456789012:other"
+	invalidMessage := validMessage
+	invalidMessage.Message = `{"state":"COMPLETED","pipelineId":"mypipeline"}`
+	otherPipelineMessage := validMessage
+	otherPipelineMessage.Message = `{"state":"COMPLETED","jobId":"123","pipelineId":"otherpipeline"}`
+	var tests = []struct {
+		givenTestCase string
+		givenRequest  *http.Request
+		wantErr       error
+	}{
+		{
+			"invalid signature",
+			otherSigner.request(t, validMessage),
+			provider.ErrNotificationUnauthorized,
+		},
+		{
+			"unknown topic",
+			signer.request(t, otherTopicMessage),
+			provider.ErrNotificationUnauthorized,
+		},
+		{
+			"missing job id",
+			signer.request(t, invalidMessage),
+			provider.InvalidNotificationError("missing job id"),
+		},
+		{
+			"unknown pipeline",
+			signer.request(t, otherPipelineMessage),
+			provider.InvalidNotificationError(`unknown pipeline "otherpipeline"`),
+		},
+	}
+	prov := newNotificationProvider(signer)
+	for _, test := range tests {
+		status, err := prov.HandleNotification(test.givenRequest)
+		if err != test.wantErr {
+			t.Errorf("%s: wrong error returned. Want %#v. Got %#v", test.givenTestCase, test.wantErr, err)
+		}
+		if status != nil {
+			t.Errorf("%s: unexpected non-nil status: %#v", test.givenTestCase, status)
+		}
+	}
+}
+
+func TestFetchSNSCertificateInvalidURL(t *testing.T) {
+	var tests = []string{
+		"http://sns.us-east-1.amazonaws.com/cert.pem",
+		"https://sns.us-east-1.amazonaws.com.evil.com/cert.pem",
+		"https://evil.com/sns.us-east-1.amazonaws.com/cert.pem",
+	}
+	for _, certURL := range tests {
+		cert, err := fetchSNSCertificate(certURL)
+		if err == nil {
+			t.Errorf("%s: unexpected <nil> error", certURL)
+		}
+		if cert != nil {
+			t.Errorf("%s: unexpected non-nil certificate", certURL)
+		}
+	}
+}
@@ -1,14 +1,107 @@
 package elementalconductor
 
-import "github.com/NYTimes/encoding-wrapper/elementalconductor"
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/encoding-wrapper/elementalconductor"
+)
 
 type clientInterface interface {
 	GetPreset(presetID string) (*elementalconductor.Preset, error)
 	CreatePreset(preset *elementalconductor.Preset) (*elementalconductor.Preset, error)
 	DeletePreset(presetID string) error
 	CreateJob(job *elementalconductor.Job) (*elementalconductor.Job, error)
+	CreateClippedJob(job *elementalconductor.Job, clipping inputClipping) (*elementalconductor.Job, error)
 	GetJob(jobID string) (*elementalconductor.Job, error)
 	CancelJob(jobID string) (*elementalconductor.Job, error)
 	GetNodes() ([]elementalconductor.Node, error)
 	GetCloudConfig() (*elementalconductor.CloudConfig, error)
 }
+
+// inputClipping is the segment of the input used by a job, given by
+// HH:MM:SS:FF timecodes relative to the beginning of the input.
+type inputClipping struct {
+	StartTimecode string `xml:"start_timecode,omitempty"`
+	EndTimecode   string `xml:"end_timecode,omitempty"`
+}
+
+// clippedJob is a job whose input is clipped. The Elemental Conductor client
+// doesn't support input clipping, so the input of the job is replaced.
+type clippedJob struct {
+	*elementalconductor.Job
+	Input clippedInput `xml:"input"`
+}
+
+type clippedInput struct {
+	elementalconductor.Input
+	TimecodeSource string        `xml:"timecode_source"`
+	InputClipping  inputClipping `xml:"input_clipping"`
+}
+
+// extendedClient extends the Elemental Conductor client with the creation of jobs
+// with clipped inputs.
+type extendedClient struct {
+	*elementalconductor.Client
+}
+
+// CreateClippedJob creates the given job, using only the given segment of its
+// input.
+func (c *extendedClient) CreateClippedJob(job *elementalconductor.Job, clipping inputClipping) (*elementalconductor.Job, error) {
+	body, err := xml.Marshal(clippedJob{
+		Job: job,
+		Input: clippedInput{
+			Input:          job.Input,
+			TimecodeSource: "zerobased",
+			InputClipping:  clipping,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(c.Host, "/")+"/api/jobs", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/xml")
+	req.Header.Set("Content-Type", "application/xml")
+	if c.UserLogin != "" && c.APIKey != "" {
+		expires := strconv.FormatInt(time.Now().Add(time.Duration(c.AuthExpires)*time.Minute).Unix(), 10)
+		req.Header.Set("X-Auth-User", c.UserLogin)
+		req.Header.Set("X-Auth-Expires", expires)
+		req.Header.Set("X-Auth-Key", c.authKey("/jobs", expires))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("error creating clipped job (%d): %s", resp.StatusCode, data)
+	}
+	var created elementalconductor.Job
+	if err = xml.Unmarshal(data, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// authKey returns the key used for authenticating requests to the given path
+// of the API, expiring at the given Unix timestamp.
+func (c *extendedClient) authKey(path, expires string) string {
+	inner := md5.Sum([]byte(path + c.UserLogin + c.APIKey + expires))
+	key := md5.Sum([]byte(c.APIKey + hex.EncodeToString(inner[:])))
+	return hex.EncodeToString(key[:])
+}
@@ -35,6 +35,33 @@ const Name = "elementalconductor"
 
 const defaultJobPriority = 50
 
+// hlsAudioGroup is the group of the alternate audio renditions of HLS
+// outputs.
+const hlsAudioGroup = "audio"
+
+var (
+	// audioParameters lists the optional audio parameters supported by
+	// Elemental Conductor. Audio-only HLS outputs are listed in the
+	// alternate audio group of the video outputs. Audio tracks aren't
+	// supported, as presets can't select the audio streams of the source.
+	audioParameters = []string{"channels", "channelLayout", "sampleRate", "language", "loudness", "audioOnly"}
+
+	// videoParameters lists the codec-specific parameters supported by
+	// Elemental Conductor.
+	videoParameters = []string{"frameRate"}
+
+	// audioCodingModes maps the channels and channel layouts of audio
+	// renditions to the coding modes of Elemental Conductor.
+	audioCodingModes = map[string]string{
+		"1":      "1_0",
+		"2":      "2_0",
+		"6":      "5_1",
+		"mono":   "1_0",
+		"stereo": "2_0",
+		"5.1":    "5_1",
+	}
+)
+
 var errElementalConductorInvalidConfig = provider.InvalidConfigError("missing Elemental user login or api key. Please define the environment variables ELEMENTALCONDUCTOR_USER_LOGIN and ELEMENTALCONDUCTOR_API_KEY or set these values in the configuration file")
 
 func init() {
@@ -51,6 +78,22 @@ func (p *elementalConductorProvider) DeletePreset(presetID string) error {
 }
 
 func (p *elementalConductorProvider) CreatePreset(preset db.Preset) (string, error) {
+	if preset.Container == "mpd" {
+		return "", provider.UnsupportedParameterError{Parameter: "container", Value: preset.Container}
+	}
+	if preset.Video.Codec == "av1" {
+		return "", provider.UnsupportedParameterError{Parameter: "video.codec", Value: preset.Video.Codec}
+	}
+	if err := provider.CheckVideoParameters(preset.Video, videoParameters...); err != nil {
+		return "", err
+	}
+	if err := provider.CheckAudioParameters(preset, audioParameters...); err != nil {
+		return "", err
+	}
+	codingMode, err := audioCodingMode(preset.Audio)
+	if err != nil {
+		return "", err
+	}
 	elementalConductorPreset := elementalconductor.Preset{
 		XMLName: xml.Name{Local: "preset"},
 	}
@@ -67,8 +110,13 @@ func (p *elementalConductorProvider) CreatePreset(preset db.Preset) (string, err
 	elementalConductorPreset.GopSize = preset.Video.GopSize
 	elementalConductorPreset.GopMode = preset.Video.GopMode
 	elementalConductorPreset.InterlaceMode = preset.Video.InterlaceMode
+	elementalConductorPreset.FrameRate = preset.Video.FrameRate
 	elementalConductorPreset.AudioCodec = preset.Audio.Codec
 	elementalConductorPreset.AudioBitrate = preset.Audio.Bitrate
+	elementalConductorPreset.AudioCodingMode = codingMode
+	elementalConductorPreset.AudioSampleRate = preset.Audio.SampleRate
+	elementalConductorPreset.AudioLanguage = preset.Audio.Language
+	elementalConductorPreset.AudioLoudness = preset.Audio.Loudness
 
 	result, err := p.client.CreatePreset(&elementalConductorPreset)
 	if err != nil {
@@ -78,6 +126,27 @@ func (p *elementalConductorProvider) CreatePreset(preset db.Preset) (string, err
 	return result.Name, nil
 }
 
+// audioCodingMode returns the coding mode of the given audio rendition, or an
+// empty string when the rendition doesn't define its channels.
+func audioCodingMode(audio db.AudioPreset) (string, error) {
+	codingMode := ""
+	if audio.ChannelLayout != "" {
+		mode, ok := audioCodingModes[audio.ChannelLayout]
+		if !ok {
+			return "", provider.UnsupportedParameterError{Parameter: "audio.channelLayout", Value: audio.ChannelLayout}
+		}
+		codingMode = mode
+	}
+	if audio.Channels != "" {
+		mode, ok := audioCodingModes[audio.Channels]
+		if !ok {
+			return "", provider.UnsupportedParameterError{Parameter: "audio.channels", Value: audio.Channels}
+		}
+		codingMode = mode
+	}
+	return codingMode, nil
+}
+
 func (p *elementalConductorProvider) GetPreset(presetID string) (interface{}, error) {
 	preset, err := p.client.GetPreset(presetID)
 	if err != nil {
@@ -91,7 +160,12 @@ func (p *elementalConductorProvider) Transcode(job *db.Job) (*provider.JobStatus
 	if err != nil {
 		return nil, err
 	}
-	resp, err := p.client.CreateJob(newJob)
+	var resp *elementalconductor.Job
+	if job.Clip != nil {
+		resp, err = p.client.CreateClippedJob(newJob, newInputClipping(job.Clip))
+	} else {
+		resp, err = p.client.CreateJob(newJob)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -102,6 +176,25 @@ func (p *elementalConductorProvider) Transcode(job *db.Job) (*provider.JobStatus
 	}, nil
 }
 
+// newInputClipping returns the input clipping for the given clip. Timecodes
+// have whole-second precision, so the clip is widened to the enclosing
+// seconds.
+func newInputClipping(clip *db.Clip) inputClipping {
+	var clipping inputClipping
+	if clip.Start != "" {
+		clipping.StartTimecode = timecode(clip.StartTime() / time.Second)
+	}
+	if clip.End != "" {
+		clipping.EndTimecode = timecode((clip.EndTime() + time.Second - 1) / time.Second)
+	}
+	return clipping
+}
+
+// timecode formats the given number of seconds as a HH:MM:SS:FF timecode.
+func timecode(seconds time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d:00", seconds/3600, seconds/60%60, seconds%60)
+}
+
 func (p *elementalConductorProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
 	resp, err := p.client.GetJob(job.ProviderJobID)
 	if err != nil {
@@ -147,7 +240,7 @@ func (p *elementalConductorProvider) JobStatus(job *db.Job) (*provider.JobStatus
 }
 
 func (p *elementalConductorProvider) getOutputDestination(job *db.Job) string {
-	return strings.TrimRight(p.config.Destination, "/") + "/" + job.ID
+	return strings.TrimRight(provider.JobDestination(job, p.config.Destination), "/") + "/" + job.ID
 }
 
 func (p *elementalConductorProvider) getOutputFiles(job *elementalconductor.Job) []provider.OutputFile {
@@ -202,6 +295,8 @@ func (p *elementalConductorProvider) buildOutputGroupAndStreamAssemblies(outputL
 	var outputGroupList []elementalconductor.OutputGroup
 	var outputGroupOrder int
 	var streamingGroupOrder int
+	var hlsVideo bool
+	var hlsAudioOnly []int
 	for index, output := range job.Outputs {
 		indexString := strconv.Itoa(index)
 		streamAssemblyName := "stream_" + indexString
@@ -220,6 +315,11 @@ func (p *elementalConductorProvider) buildOutputGroupAndStreamAssemblies(outputL
 			out.NameModifier = fmt.Sprintf("_%010d", streamingGroupOrder)
 			out.Container = elementalconductor.AppleHTTPLiveStreaming
 			out.Order = streamingGroupOrder
+			if presetStruct.VideoCodec == "" {
+				hlsAudioOnly = append(hlsAudioOnly, len(streamingOutputList))
+			} else {
+				hlsVideo = true
+			}
 			streamingOutputList = append(streamingOutputList, out)
 		} else {
 			outputGroupOrder++
@@ -243,6 +343,29 @@ func (p *elementalConductorProvider) buildOutputGroupAndStreamAssemblies(outputL
 		}
 		streamAssemblyList = append(streamAssemblyList, streamAssembly)
 	}
+	if hlsVideo && len(hlsAudioOnly) > 0 {
+		// audio-only outputs are the alternate audio renditions of
+		// the video outputs.
+		isAudioOnly := make(map[int]bool, len(hlsAudioOnly))
+		for i, index := range hlsAudioOnly {
+			trackType := "ALTERNATE_AUDIO_AUTO_SELECT"
+			if i == 0 {
+				trackType = "ALTERNATE_AUDIO_AUTO_SELECT_DEFAULT"
+			}
+			streamingOutputList[index].AppleLiveSettings = &elementalconductor.AppleLiveSettings{
+				AudioGroupID:   hlsAudioGroup,
+				AudioTrackType: trackType,
+			}
+			isAudioOnly[index] = true
+		}
+		for index := range streamingOutputList {
+			if !isAudioOnly[index] {
+				streamingOutputList[index].AppleLiveSettings = &elementalconductor.AppleLiveSettings{
+					AudioRenditionSets: hlsAudioGroup,
+				}
+			}
+		}
+	}
 	if len(streamingOutputList) > 0 {
 		playlistFileName := job.StreamingParams.PlaylistFileName
 		location := outputLocation
@@ -270,7 +393,7 @@ func (p *elementalConductorProvider) newJob(job *db.Job) (*elementalconductor.Jo
 		Username: p.config.AccessKeyID,
 		Password: p.config.SecretAccessKey,
 	}
-	baseLocation := strings.TrimRight(p.config.Destination, "/")
+	baseLocation := strings.TrimRight(provider.JobDestination(job, p.config.Destination), "/")
 	outputLocation := elementalconductor.Location{
 		URI:      baseLocation + "/" + job.ID,
 		Username: p.config.AccessKeyID,
@@ -320,11 +443,17 @@ func (p *elementalConductorProvider) Healthcheck() error {
 	return nil
 }
 
+// Capabilities returns the capabilities of Elemental Conductor. DASH isn't
+// supported, as the client of the Elemental Conductor API only builds file and
+// Apple HLS output groups.
 func (p *elementalConductorProvider) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
 		InputFormats:  []string{"prores", "h264"},
 		OutputFormats: []string{"mp4", "hls"},
 		Destinations:  []string{"akamai", "s3"},
+
+		PresetConstraints: &provider.PresetConstraints{AudioParameters: audioParameters},
+		Clipping:          &provider.ClippingCapabilities{},
 	}
 }
 
@@ -333,7 +462,7 @@ func elementalConductorFactory(cfg *config.Config) (provider.TranscodingProvider
 		cfg.ElementalConductor.APIKey == "" || cfg.ElementalConductor.AuthExpires == 0 {
 		return nil, errElementalConductorInvalidConfig
 	}
-	client := elementalconductor.NewClient(
+	client := &extendedClient{elementalconductor.NewClient(
 		cfg.ElementalConductor.Host,
 		cfg.ElementalConductor.UserLogin,
 		cfg.ElementalConductor.APIKey,
@@ -341,6 +470,6 @@ func elementalConductorFactory(cfg *config.Config) (provider.TranscodingProvider
 		cfg.ElementalConductor.AccessKeyID,
 		cfg.ElementalConductor.SecretAccessKey,
 		cfg.ElementalConductor.Destination,
-	)
+	)}
 	return &elementalConductorProvider{client: client, config: cfg.ElementalConductor}, nil
 }
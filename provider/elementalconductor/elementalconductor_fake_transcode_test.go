@@ -1,6 +1,7 @@
 package elementalconductor
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/NYTimes/encoding-wrapper/elementalconductor"
@@ -11,7 +12,14 @@ import (
 type fakeElementalConductorClient struct {
 	*elementalconductor.Client
 	jobs         map[string]elementalconductor.Job
+	presets      []elementalconductor.Preset
 	canceledJobs []string
+	createdJobs  []createdJob
+}
+
+type createdJob struct {
+	job      elementalconductor.Job
+	clipping *inputClipping
 }
 
 func newFakeElementalConductorClient(cfg *config.ElementalConductor) *fakeElementalConductorClient {
@@ -34,18 +42,33 @@ func (c *fakeElementalConductorClient) GetPreset(presetID string) (*elementalcon
 	if strings.Contains(presetID, "hls") {
 		container = elementalconductor.AppleHTTPLiveStreaming
 	}
-	return &elementalconductor.Preset{
+	preset := elementalconductor.Preset{
 		Name:      presetID,
 		Container: string(container),
-	}, nil
+	}
+	if !strings.Contains(presetID, "audio") {
+		preset.VideoCodec = "h.264"
+	}
+	return &preset, nil
 }
 
 func (c *fakeElementalConductorClient) CreatePreset(preset *elementalconductor.Preset) (*elementalconductor.Preset, error) {
+	c.presets = append(c.presets, *preset)
 	return &elementalconductor.Preset{
 		Name: preset.Name,
 	}, nil
 }
 
+func (c *fakeElementalConductorClient) CreateJob(job *elementalconductor.Job) (*elementalconductor.Job, error) {
+	c.createdJobs = append(c.createdJobs, createdJob{job: *job})
+	return &elementalconductor.Job{Href: fmt.Sprintf("/jobs/%d", len(c.createdJobs))}, nil
+}
+
+func (c *fakeElementalConductorClient) CreateClippedJob(job *elementalconductor.Job, clipping inputClipping) (*elementalconductor.Job, error) {
+	c.createdJobs = append(c.createdJobs, createdJob{job: *job, clipping: &clipping})
+	return &elementalconductor.Job{Href: fmt.Sprintf("/jobs/%d", len(c.createdJobs))}, nil
+}
+
 func (c *fakeElementalConductorClient) GetJob(jobID string) (*elementalconductor.Job, error) {
 	job := c.jobs[jobID]
 	return &job, nil
@@ -36,12 +36,12 @@ func TestElementalConductorFactory(t *testing.T) {
 	if !ok {
 		t.Fatalf("Wrong provider returned. Want elementalConductorProvider instance. Got %#v.", provider)
 	}
-	expected := &elementalconductor.Client{
+	expected := &extendedClient{&elementalconductor.Client{
 		Host:        "elemental-server",
 		UserLogin:   "myuser",
 		APIKey:      "secret-key",
 		AuthExpires: 30,
-	}
+	}}
 	if !reflect.DeepEqual(econductorProvider.client, expected) {
 		t.Errorf("Factory: wrong client returned. Want %#v. Got %#v.", expected, econductorProvider.client)
 	}
@@ -666,6 +666,14 @@ func TestJobStatusOutputDestination(t *testing.T) {
 			"s3://destination",
 			"s3://destination/job-123",
 		},
+		{
+			db.Job{
+				ID:          "job-123",
+				Destination: "s3://other-destination/videos/",
+			},
+			"s3://destination",
+			"s3://other-destination/videos/job-123",
+		},
 	}
 	elementalConductorConfig := config.Config{
 		ElementalConductor: &config.ElementalConductor{
@@ -1100,7 +1108,7 @@ func TestHealthcheck(t *testing.T) {
 	server := NewElementalServer(nil, nil)
 	defer server.Close()
 	prov := elementalConductorProvider{
-		client: elementalconductor.NewClient(server.URL, "", "", 0, "", "", ""),
+		client: &extendedClient{elementalconductor.NewClient(server.URL, "", "", 0, "", "", "")},
 	}
 	var tests = []struct {
 		minNodes    int
@@ -1184,12 +1192,284 @@ func TestHealthcheck(t *testing.T) {
 	}
 }
 
+func TestCreatePresetDASH(t *testing.T) {
+	client := newFakeElementalConductorClient(&config.ElementalConductor{})
+	prov := elementalConductorProvider{client: client}
+	presetID, err := prov.CreatePreset(db.Preset{Name: "dash_1080p", Container: "mpd"})
+	if presetID != "" {
+		t.Errorf("unexpected non-empty preset id: %q", presetID)
+	}
+	expected := provider.UnsupportedParameterError{Parameter: "container", Value: "mpd"}
+	if err != expected {
+		t.Errorf("wrong error returned. Want %#v. Got %#v", expected, err)
+	}
+}
+
+func TestCreatePresetAudioParameters(t *testing.T) {
+	client := newFakeElementalConductorClient(&config.ElementalConductor{})
+	prov := elementalConductorProvider{client: client}
+	_, err := prov.CreatePreset(db.Preset{
+		Name:      "hls_audio",
+		Container: "m3u8",
+		Audio: db.AudioPreset{
+			Codec:         "aac",
+			Bitrate:       "128000",
+			ChannelLayout: "5.1",
+			SampleRate:    "48000",
+			Language:      "spa",
+			Loudness:      "-23",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []elementalconductor.Preset{
+		{
+			XMLName:         xml.Name{Local: "preset"},
+			Name:            "hls_audio",
+			Container:       "m3u8",
+			AudioCodec:      "aac",
+			AudioBitrate:    "128000",
+			AudioCodingMode: "5_1",
+			AudioSampleRate: "48000",
+			AudioLanguage:   "spa",
+			AudioLoudness:   "-23",
+		},
+	}
+	if !reflect.DeepEqual(client.presets, expected) {
+		t.Errorf("wrong presets created\nwant %#v\ngot  %#v", expected, client.presets)
+	}
+}
+
+func TestCreatePresetFrameRate(t *testing.T) {
+	client := newFakeElementalConductorClient(&config.ElementalConductor{})
+	prov := elementalConductorProvider{client: client}
+	_, err := prov.CreatePreset(db.Preset{
+		Name:      "mp4_720p",
+		Container: "mp4",
+		Video:     db.VideoPreset{Codec: "h264", Bitrate: "2000000", GopSize: "60", FrameRate: "29.97"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []elementalconductor.Preset{
+		{
+			XMLName:      xml.Name{Local: "preset"},
+			Name:         "mp4_720p",
+			Container:    "mp4",
+			VideoCodec:   "h264",
+			VideoBitrate: "2000000",
+			GopSize:      "60",
+			FrameRate:    "29.97",
+			AudioCodec:   "aac",
+			AudioBitrate: "128000",
+		},
+	}
+	if !reflect.DeepEqual(client.presets, expected) {
+		t.Errorf("wrong presets created\nwant %#v\ngot  %#v", expected, client.presets)
+	}
+}
+
+func TestCreatePresetUnsupportedAudioParameters(t *testing.T) {
+	var tests = []struct {
+		audio       db.AudioPreset
+		audioTracks []db.AudioPreset
+		expected    provider.UnsupportedParameterError
+	}{
+		{
+			db.AudioPreset{Codec: "aac", Bitrate: "128000", ChannelLayout: "7.1"},
+			nil,
+			provider.UnsupportedParameterError{Parameter: "audio.channelLayout", Value: "7.1"},
+		},
+		{
+			db.AudioPreset{Codec: "aac", Bitrate: "128000", Channels: "8"},
+			nil,
+			provider.UnsupportedParameterError{Parameter: "audio.channels", Value: "8"},
+		},
+		{
+			db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+			[]db.AudioPreset{{Codec: "aac", Bitrate: "128000", Language: "spa"}},
+			provider.UnsupportedParameterError{Parameter: "audioTracks", Value: "1"},
+		},
+	}
+	for _, test := range tests {
+		client := newFakeElementalConductorClient(&config.ElementalConductor{})
+		prov := elementalConductorProvider{client: client}
+		_, err := prov.CreatePreset(db.Preset{Name: "mp4_audio", Container: "mp4", Audio: test.audio, AudioTracks: test.audioTracks})
+		if err != test.expected {
+			t.Errorf("wrong error returned. Want %#v. Got %#v", test.expected, err)
+		}
+		if len(client.presets) > 0 {
+			t.Errorf("unexpected presets created: %#v", client.presets)
+		}
+	}
+}
+
+func TestElementalNewJobAlternateAudio(t *testing.T) {
+	prov := elementalConductorProvider{
+		client: newFakeElementalConductorClient(&config.ElementalConductor{}),
+		config: &config.ElementalConductor{Destination: "s3://destination"},
+	}
+	newJob, err := prov.newJob(&db.Job{
+		ID:          "job-3",
+		SourceMedia: "http://some.nice/video.mov",
+		Outputs: []db.TranscodeOutput{
+			{FileName: "hls/720p.m3u8", Preset: db.PresetMap{Name: "hls_720p", ProviderMapping: map[string]string{Name: "hls_720p"}}},
+			{FileName: "hls/audio_en.m3u8", Preset: db.PresetMap{Name: "hls_audio_en", ProviderMapping: map[string]string{Name: "hls_audio_en"}}},
+			{FileName: "hls/1080p.m3u8", Preset: db.PresetMap{Name: "hls_1080p", ProviderMapping: map[string]string{Name: "hls_1080p"}}},
+			{FileName: "hls/audio_es.m3u8", Preset: db.PresetMap{Name: "hls_audio_es", ProviderMapping: map[string]string{Name: "hls_audio_es"}}},
+		},
+		StreamingParams: db.StreamingParams{
+			Protocol:         "hls",
+			SegmentDuration:  3,
+			PlaylistFileName: "hls/master.m3u8",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(newJob.OutputGroup) != 1 {
+		t.Fatalf("wrong number of output groups. Want 1. Got %d", len(newJob.OutputGroup))
+	}
+	expected := []*elementalconductor.AppleLiveSettings{
+		{AudioRenditionSets: "audio"},
+		{AudioGroupID: "audio", AudioTrackType: "ALTERNATE_AUDIO_AUTO_SELECT_DEFAULT"},
+		{AudioRenditionSets: "audio"},
+		{AudioGroupID: "audio", AudioTrackType: "ALTERNATE_AUDIO_AUTO_SELECT"},
+	}
+	var got []*elementalconductor.AppleLiveSettings
+	for _, output := range newJob.OutputGroup[0].Output {
+		got = append(got, output.AppleLiveSettings)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong HLS settings of the outputs\nwant %#v\ngot  %#v", expected, got)
+	}
+}
+
+func TestTranscodeClippedJob(t *testing.T) {
+	var tests = []struct {
+		name     string
+		clip     *db.Clip
+		expected *inputClipping
+	}{
+		{"no clip", nil, nil},
+		{"start and end", &db.Clip{Start: "10.5", End: "3725.2"}, &inputClipping{StartTimecode: "00:00:10:00", EndTimecode: "01:02:06:00"}},
+		{"start only", &db.Clip{Start: "62"}, &inputClipping{StartTimecode: "00:01:02:00"}},
+		{"end only", &db.Clip{End: "30"}, &inputClipping{EndTimecode: "00:00:30:00"}},
+	}
+	for _, test := range tests {
+		cfg := config.Config{
+			ElementalConductor: &config.ElementalConductor{
+				Host:        "https://elemental-server",
+				UserLogin:   "myuser",
+				APIKey:      "elemental-api-key",
+				AuthExpires: 30,
+				Destination: "s3://destination",
+			},
+		}
+		prov, err := fakeElementalConductorFactory(&cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, err := prov.Transcode(&db.Job{
+			ID:          "job-1",
+			SourceMedia: "http://some.nice/video.mov",
+			Clip:        test.clip,
+			Outputs: []db.TranscodeOutput{
+				{
+					FileName: "output_720p.mp4",
+					Preset: db.PresetMap{
+						Name:            "mp4_720p",
+						ProviderMapping: map[string]string{Name: "mp4_720p"},
+						OutputOpts:      db.OutputOptions{Extension: "mp4"},
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if status.ProviderJobID != "1" {
+			t.Errorf("%s: wrong job ID. Want %q. Got %q", test.name, "1", status.ProviderJobID)
+		}
+		client := prov.(*elementalConductorProvider).client.(*fakeElementalConductorClient)
+		if len(client.createdJobs) != 1 {
+			t.Fatalf("%s: wrong number of jobs created. Want 1. Got %d", test.name, len(client.createdJobs))
+		}
+		if clipping := client.createdJobs[0].clipping; !reflect.DeepEqual(clipping, test.expected) {
+			t.Errorf("%s: wrong input clipping\nwant %#v\ngot  %#v", test.name, test.expected, clipping)
+		}
+	}
+}
+
+func TestCreateClippedJob(t *testing.T) {
+	server := NewElementalServer(nil, nil)
+	defer server.Close()
+	client := extendedClient{elementalconductor.NewClient(server.URL, "myuser", "elemental-api-key", 30, "", "", "")}
+	job, err := client.CreateClippedJob(&elementalconductor.Job{
+		XMLName: xml.Name{Local: "job"},
+		Input: elementalconductor.Input{
+			FileInput: elementalconductor.Location{URI: "http://some.nice/video.mov"},
+		},
+		Priority: 50,
+	}, inputClipping{StartTimecode: "00:00:10:00", EndTimecode: "00:00:40:00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := job.GetID(); id != "1" {
+		t.Errorf("wrong job ID. Want %q. Got %q", "1", id)
+	}
+	var body struct {
+		XMLName  xml.Name
+		Priority int `xml:"priority"`
+		Input    struct {
+			URI            string        `xml:"file_input>uri"`
+			TimecodeSource string        `xml:"timecode_source"`
+			InputClipping  inputClipping `xml:"input_clipping"`
+		} `xml:"input"`
+	}
+	if err = xml.Unmarshal(server.jobBody, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.XMLName.Local != "job" || body.Priority != 50 {
+		t.Errorf("wrong job sent: %s", server.jobBody)
+	}
+	if body.Input.URI != "http://some.nice/video.mov" {
+		t.Errorf("wrong input URI. Want %q. Got %q", "http://some.nice/video.mov", body.Input.URI)
+	}
+	if body.Input.TimecodeSource != "zerobased" {
+		t.Errorf("wrong timecode source. Want %q. Got %q", "zerobased", body.Input.TimecodeSource)
+	}
+	expectedClipping := inputClipping{StartTimecode: "00:00:10:00", EndTimecode: "00:00:40:00"}
+	if body.Input.InputClipping != expectedClipping {
+		t.Errorf("wrong input clipping\nwant %#v\ngot  %#v", expectedClipping, body.Input.InputClipping)
+	}
+	header := server.jobRequest.Header
+	if user := header.Get("X-Auth-User"); user != "myuser" {
+		t.Errorf("wrong X-Auth-User. Want %q. Got %q", "myuser", user)
+	}
+	expires := header.Get("X-Auth-Expires")
+	if key := header.Get("X-Auth-Key"); key != client.authKey("/jobs", expires) {
+		t.Errorf("wrong X-Auth-Key %q for expiration %q", key, expires)
+	}
+	if contentType := header.Get("Content-Type"); contentType != "application/xml" {
+		t.Errorf("wrong Content-Type. Want %q. Got %q", "application/xml", contentType)
+	}
+}
+
 func TestCapabilities(t *testing.T) {
 	var prov elementalConductorProvider
 	expected := provider.Capabilities{
 		InputFormats:  []string{"prores", "h264"},
 		OutputFormats: []string{"mp4", "hls"},
 		Destinations:  []string{"akamai", "s3"},
+
+		PresetConstraints: &provider.PresetConstraints{
+			AudioParameters: []string{"channels", "channelLayout", "sampleRate", "language", "loudness", "audioOnly"},
+		},
+		Clipping: &provider.ClippingCapabilities{},
 	}
 	cap := prov.Capabilities()
 	if !reflect.DeepEqual(cap, expected) {
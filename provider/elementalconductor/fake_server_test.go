@@ -2,6 +2,7 @@ package elementalconductor
 
 import (
 	"encoding/xml"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 
@@ -17,6 +18,10 @@ type ElementalServer struct {
 	*httptest.Server
 	nodes  *nodeList
 	config *elementalconductor.CloudConfig
+
+	// last request sent to the jobs endpoint, with its body.
+	jobRequest *http.Request
+	jobBody    []byte
 }
 
 func NewElementalServer(config *elementalconductor.CloudConfig, nodes []elementalconductor.Node) *ElementalServer {
@@ -36,6 +41,15 @@ func (s *ElementalServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case "/api/config/cloud":
 		w.Header().Set("Content-Type", "application/xml")
 		xml.NewEncoder(w).Encode(s.config)
+	case "/api/jobs":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.jobRequest = r
+		s.jobBody, _ = ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(w).Encode(elementalconductor.Job{Href: "/jobs/1"})
 	default:
 		http.Error(w, "not found", http.StatusNotFound)
 	}
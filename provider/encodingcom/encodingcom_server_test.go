@@ -44,6 +44,7 @@ type fakePreset struct {
 type fakeMedia struct {
 	ID       string
 	Request  request
+	JSON     string
 	Created  time.Time
 	Started  time.Time
 	Finished time.Time
@@ -92,7 +93,7 @@ func (s *encodingComFakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request
 	req := m["query"]
 	switch req.Action {
 	case "AddMedia":
-		s.addMedia(w, req)
+		s.addMedia(w, req, requestData)
 	case "CancelMedia":
 		s.cancelMedia(w, req)
 	case "GetStatus":
@@ -115,12 +116,13 @@ func (s *encodingComFakeServer) apiStatus(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(s.status)
 }
 
-func (s *encodingComFakeServer) addMedia(w http.ResponseWriter, req request) {
+func (s *encodingComFakeServer) addMedia(w http.ResponseWriter, req request, requestData string) {
 	id := generateID()
 	created := time.Now().UTC()
 	s.medias[id] = &fakeMedia{
 		ID:       id,
 		Request:  req,
+		JSON:     requestData,
 		Created:  created,
 		Started:  created.Add(time.Second),
 		Size:     "1920x1080",
@@ -0,0 +1,113 @@
+package encodingcom
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NYTimes/encoding-wrapper/encodingcom"
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+// normalizedCodecs maps the codecs of Encoding.com back to the codecs of the
+// API, reverting getNormalizedCodec.
+var normalizedCodecs = map[string]string{
+	"dolby_aac":  "aac",
+	"libvorbis":  "vorbis",
+	"libx264":    "h264",
+	"libx265":    "hevc",
+	"libvpx":     "vp8",
+	"libvpx-vp9": "vp9",
+}
+
+func (e *encodingComProvider) NormalizePreset(preset interface{}) (db.Preset, error) {
+	var output string
+	var streams []encodingcom.Stream
+	switch preset := preset.(type) {
+	case db.Preset:
+		if err := checkPreset(preset); err != nil {
+			return db.Preset{}, err
+		}
+		format := e.presetToFormat(preset)
+		output, streams = format.Output[0], format.Stream
+		if len(streams) == 0 {
+			var keyframe string
+			if len(format.Keyframe) > 0 {
+				keyframe = format.Keyframe[0]
+			}
+			streams = []encodingcom.Stream{{
+				VideoCodec:          format.VideoCodec,
+				Profile:             format.Profile,
+				Bitrate:             format.Bitrate,
+				Size:                format.Size,
+				Keyframe:            keyframe,
+				AudioCodec:          format.AudioCodec,
+				AudioBitrate:        format.AudioBitrate,
+				AudioChannelsNumber: format.AudioChannelsNumber,
+				AudioSampleRate:     format.AudioSampleRate,
+			}}
+		}
+	case *encodingcom.Preset:
+		output, streams = preset.Output, preset.Format.Stream()
+		if len(streams) == 0 {
+			streams = []encodingcom.Stream{{
+				VideoCodec:          preset.Format.VideoCodec,
+				Profile:             preset.Format.Profile,
+				Bitrate:             preset.Format.Bitrate,
+				Size:                preset.Format.Size,
+				Keyframe:            preset.Format.Keyframe,
+				AudioCodec:          preset.Format.AudioCodec,
+				AudioBitrate:        preset.Format.AudioBitrate,
+				AudioChannelsNumber: preset.Format.AudioChannelsNumber,
+				AudioSampleRate:     preset.Format.AudioSampleRate,
+			}}
+		}
+	default:
+		return db.Preset{}, fmt.Errorf("unexpected preset type %T", preset)
+	}
+	normalized := db.Preset{Container: output}
+	if streaming, ok := getStreamingFormat(func(f streamingFormat) bool { return f.output == output }); ok {
+		normalized.Container = streaming.container
+	}
+	stream := streams[0]
+	normalized.Audio = db.AudioPreset{
+		Codec:      lookupCodec(stream.AudioCodec),
+		Bitrate:    kilobitsToBits(stream.AudioBitrate),
+		Channels:   stream.AudioChannelsNumber,
+		SampleRate: stream.AudioSampleRate,
+	}
+	if (stream.AudioOnly != nil && bool(*stream.AudioOnly)) || stream.VideoCodec == "" {
+		return normalized, nil
+	}
+	normalized.Video = db.VideoPreset{
+		Codec:   lookupCodec(stream.VideoCodec),
+		Profile: stream.Profile,
+		Bitrate: kilobitsToBits(stream.Bitrate),
+		GopSize: stream.Keyframe,
+	}
+	// getSize uses 0 for dimensions that are not set.
+	if size := strings.SplitN(stream.Size, "x", 2); len(size) == 2 {
+		if size[0] != "0" {
+			normalized.Video.Width = size[0]
+		}
+		if size[1] != "0" {
+			normalized.Video.Height = size[1]
+		}
+	}
+	return normalized, nil
+}
+
+func lookupCodec(codec string) string {
+	if normalized, ok := normalizedCodecs[codec]; ok {
+		return normalized
+	}
+	return codec
+}
+
+// kilobitsToBits reverts the bitrates of Encoding.com in kilobits, like 64k,
+// to bits.
+func kilobitsToBits(bitrate string) string {
+	if strings.HasSuffix(bitrate, "k") {
+		return strings.TrimSuffix(bitrate, "k") + "000"
+	}
+	return bitrate
+}
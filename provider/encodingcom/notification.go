@@ -0,0 +1,71 @@
+package encodingcom
+
+import (
+	"encoding/xml"
+	"net/http"
+
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+// notification is the XML document sent by Encoding.com, in the "xml" form
+// field, when the processing of a media is done.
+type notification struct {
+	MediaID     string               `xml:"mediaid"`
+	Source      string               `xml:"source"`
+	Status      string               `xml:"status"`
+	Description string               `xml:"description"`
+	Formats     []notificationFormat `xml:"format"`
+}
+
+type notificationFormat struct {
+	TaskID            string `xml:"taskid"`
+	Output            string `xml:"output"`
+	Status            string `xml:"status"`
+	Destination       string `xml:"destination"`
+	DestinationStatus string `xml:"destination_status"`
+	Description       string `xml:"description"`
+}
+
+// HandleNotification handles notifications sent by Encoding.com. Notifications
+// are authenticated using the token defined in the
+// ENCODINGCOM_NOTIFICATION_TOKEN environment variable, which must be included
+// in the notification URL as the "token" parameter.
+//
+// Encoding.com notifications don't include details about the output files,
+// so the returned status doesn't include them.
+func (e *encodingComProvider) HandleNotification(r *http.Request) (*provider.JobStatus, error) {
+	err := provider.CheckNotificationToken(r, e.config.EncodingCom.NotificationToken)
+	if err != nil {
+		return nil, err
+	}
+	data := r.PostFormValue("xml")
+	if data == "" {
+		return nil, provider.InvalidNotificationError("missing xml field")
+	}
+	var n notification
+	err = xml.Unmarshal([]byte(data), &n)
+	if err != nil {
+		return nil, provider.InvalidNotificationError(err.Error())
+	}
+	if n.MediaID == "" || n.Status == "" {
+		return nil, provider.InvalidNotificationError("missing media id or status")
+	}
+	formatStatus := make([]string, 0, len(n.Formats))
+	for _, format := range n.Formats {
+		formatStatus = append(formatStatus, format.Status)
+	}
+	status := provider.JobStatus{
+		ProviderJobID: n.MediaID,
+		ProviderName:  "encoding.com",
+		Status:        e.statusMap(n.Status),
+		StatusMessage: n.Description,
+		ProviderStatus: map[string]interface{}{
+			"sourcefile":   n.Source,
+			"formatStatus": formatStatus,
+		},
+	}
+	if status.Status == provider.StatusFinished {
+		status.Progress = 100
+	}
+	return &status, nil
+}
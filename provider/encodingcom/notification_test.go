@@ -0,0 +1,119 @@
+package encodingcom
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/kr/pretty"
+)
+
+func newNotificationRequest(rawURL, body string) *http.Request {
+	form := url.Values{"xml": []string{body}}
+	r, _ := http.NewRequest("POST", rawURL, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestEncodingComHandleNotification(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenFile     string
+		wantStatus    *provider.JobStatus
+	}{
+		{
+			"media finished",
+			"testdata/media_finished.xml",
+			&provider.JobStatus{
+				ProviderJobID: "54338173",
+				ProviderName:  "encoding.com",
+				Status:        provider.StatusFinished,
+				Progress:      100,
+				ProviderStatus: map[string]interface{}{
+					"sourcefile":   "http://nyt-bucket.s3.amazonaws.com/source/video.mov",
+					"formatStatus": []string{"Finished", "Finished"},
+				},
+			},
+		},
+		{
+			"media error",
+			"testdata/media_error.xml",
+			&provider.JobStatus{
+				ProviderJobID: "54338174",
+				ProviderName:  "encoding.com",
+				Status:        provider.StatusFailed,
+				StatusMessage: "Source file download failed: 404 Not Found",
+				ProviderStatus: map[string]interface{}{
+					"sourcefile":   "http://nyt-bucket.s3.amazonaws.com/source/missing.mov",
+					"formatStatus": []string{"Error"},
+				},
+			},
+		},
+	}
+	prov := encodingComProvider{
+		config: &config.Config{EncodingCom: &config.EncodingCom{NotificationToken: "s3cr3t"}},
+	}
+	for _, test := range tests {
+		body, err := ioutil.ReadFile(test.givenFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := newNotificationRequest("http://transcoding.api/notifications/encodingcom?token=s3cr3t", string(body))
+		status, err := prov.HandleNotification(r)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
+		if !reflect.DeepEqual(status, test.wantStatus) {
+			pretty.Fdiff(os.Stderr, test.wantStatus, status)
+			t.Errorf("%s: wrong status returned\nwant %#v\ngot  %#v", test.givenTestCase, test.wantStatus, status)
+		}
+	}
+}
+
+func TestEncodingComHandleNotificationErrors(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenURL      string
+		givenBody     string
+		wantErr       error
+	}{
+		{
+			"invalid token",
+			"http://transcoding.api/notifications/encodingcom?token=guess",
+			"<result><mediaid>1</mediaid><status>Finished</status></result>",
+			provider.ErrNotificationUnauthorized,
+		},
+		{
+			"missing xml",
+			"http://transcoding.api/notifications/encodingcom?token=s3cr3t",
+			"",
+			provider.InvalidNotificationError("missing xml field"),
+		},
+		{
+			"missing media id",
+			"http://transcoding.api/notifications/encodingcom?token=s3cr3t",
+			"<result><status>Finished</status></result>",
+			provider.InvalidNotificationError("missing media id or status"),
+		},
+	}
+	prov := encodingComProvider{
+		config: &config.Config{EncodingCom: &config.EncodingCom{NotificationToken: "s3cr3t"}},
+	}
+	for _, test := range tests {
+		r := newNotificationRequest(test.givenURL, test.givenBody)
+		status, err := prov.HandleNotification(r)
+		if err != test.wantErr {
+			t.Errorf("%s: wrong error returned. Want %#v. Got %#v", test.givenTestCase, test.wantErr, err)
+		}
+		if status != nil {
+			t.Errorf("%s: unexpected non-nil status: %#v", test.givenTestCase, status)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package encodingcom
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/NYTimes/encoding-wrapper/encodingcom"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+const thumbnailOutput = "thumbnail"
+
+// thumbnailCapabilities describes the thumbnails generated by the thumbnail
+// output of Encoding.com.
+var thumbnailCapabilities = provider.ThumbnailCapabilities{Formats: []string{"jpg"}, Timecodes: true}
+
+// thumbnailFormat is the format of the thumbnail output of a media, taking
+// thumbnails every given number of seconds or at the given times. The
+// Encoding.com client doesn't support the options of thumbnails, so medias
+// with thumbnails are added through the API directly (see addMedia).
+type thumbnailFormat struct {
+	Output      []string `json:"output"`
+	Every       string   `json:"every,omitempty"`
+	Time        string   `json:"time,omitempty"`
+	Width       string   `json:"width,omitempty"`
+	Height      string   `json:"height,omitempty"`
+	Destination []string `json:"destination"`
+}
+
+func (e *encodingComProvider) thumbnailFormat(job *db.Job) (thumbnailFormat, error) {
+	destinations, err := e.getDestinations(job, path.Join(job.Thumbnails.Path, "thumbnail.jpg"))
+	if err != nil {
+		return thumbnailFormat{}, err
+	}
+	format := thumbnailFormat{
+		Output:      []string{thumbnailOutput},
+		Time:        strings.Join(job.Thumbnails.Timecodes, ","),
+		Destination: destinations,
+	}
+	if job.Thumbnails.Interval > 0 {
+		format.Every = strconv.Itoa(int(job.Thumbnails.Interval))
+	}
+	if job.Thumbnails.Width > 0 {
+		format.Width = strconv.Itoa(int(job.Thumbnails.Width))
+	}
+	if job.Thumbnails.Height > 0 {
+		format.Height = strconv.Itoa(int(job.Thumbnails.Height))
+	}
+	return format, nil
+}
+
+// addMedia adds the media transcoding the given job to the given formats,
+// along with the thumbnail output of the job, if any.
+func (e *encodingComProvider) addMedia(job *db.Job, formats []encodingcom.Format) (*encodingcom.AddMediaResponse, error) {
+	source := []string{e.sourceMedia(job.SourceMedia)}
+	if job.Thumbnails == nil {
+		return e.client.AddMedia(source, formats, e.config.EncodingCom.Region)
+	}
+	thumbnails, err := e.thumbnailFormat(job)
+	if err != nil {
+		return nil, err
+	}
+	allFormats := make([]interface{}, 0, len(formats)+1)
+	for _, format := range formats {
+		allFormats = append(allFormats, format)
+	}
+	query := map[string]interface{}{
+		"userid":  e.client.UserID,
+		"userkey": e.client.UserKey,
+		"action":  "AddMedia",
+		"source":  source,
+		"format":  append(allFormats, thumbnails),
+	}
+	if e.config.EncodingCom.Region != "" {
+		query["region"] = e.config.EncodingCom.Region
+	}
+	data, err := json.Marshal(map[string]interface{}{"query": query})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.PostForm(e.client.Endpoint, url.Values{"json": []string{string(data)}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Response struct {
+			encodingcom.AddMediaResponse
+			Errors struct {
+				Error string `json:"error"`
+			} `json:"errors"`
+		} `json:"response"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Response.Errors.Error != "" {
+		return nil, errors.New(result.Response.Errors.Error)
+	}
+	return &result.Response.AddMediaResponse, nil
+}
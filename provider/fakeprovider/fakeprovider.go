@@ -0,0 +1,321 @@
+// Package fakeprovider provides a implementation of the provider that
+// simulates the lifecycle of jobs in memory, without transcoding anything.
+// It's meant to be used in integration tests of clients of the API, that
+// can then run without credentials for any vendor.
+//
+// It doesn't expose any public type. In order to use the provider, one must
+// import this package, enable the provider with the FAKE_PROVIDER_ENABLED
+// environment variable and then grab the factory from the provider package:
+//
+//     import (
+//         "github.com/NYTimes/video-transcoding-api/provider"
+//         "github.com/NYTimes/video-transcoding-api/provider/fakeprovider"
+//     )
+//
+//     func UseProvider() {
+//         factory, err := provider.GetProviderFactory(fakeprovider.Name)
+//         // handle err and use factory to get an instance of the provider.
+//     }
+//
+// Presets and jobs are kept in memory, so they're lost when the API is
+// restarted.
+package fakeprovider
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+// Name is the name used for registering the fake provider in the registry of
+// providers.
+const Name = "fake"
+
+// sourceDuration is the duration reported for the source media of all jobs,
+// used for computing the size of the output files.
+const sourceDuration = time.Minute
+
+var (
+	errFakeProviderDisabled = provider.InvalidConfigError("the fake provider is disabled. Please define the environment variable FAKE_PROVIDER_ENABLED or set this value in the configuration file")
+	errPresetNotFound       = errors.New("preset not found")
+
+	defaultStore = newStore()
+)
+
+func init() {
+	provider.Register(Name, fakeProviderFactory)
+}
+
+// job is a job simulated by the provider. Its status is derived from the
+// time elapsed since its creation.
+type job struct {
+	id           string
+	source       string
+	creationTime time.Time
+	fail         bool
+	canceled     bool
+	files        []provider.OutputFile
+	destination  string
+}
+
+// store keeps the presets and jobs of the provider in memory. Providers are
+// created on every request, so the store is shared.
+type store struct {
+	mtx     sync.Mutex
+	presets map[string]db.Preset
+	jobs    map[string]*job
+	rand    *rand.Rand
+}
+
+func newStore() *store {
+	return &store{
+		presets: make(map[string]db.Preset),
+		jobs:    make(map[string]*job),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+type fakeProvider struct {
+	config *config.FakeProvider
+	store  *store
+	now    func() time.Time
+}
+
+func (p *fakeProvider) Transcode(dbJob *db.Job) (*provider.JobStatus, error) {
+	destination := strings.TrimRight(provider.JobDestination(dbJob, p.config.Destination), "/") + "/" + dbJob.ID + "/"
+	files := make([]provider.OutputFile, 0, len(dbJob.Outputs)+1)
+	streamingOutputs := make(map[string]int)
+	for _, output := range dbJob.Outputs {
+		presetID, ok := output.Preset.ProviderMapping[Name]
+		if !ok {
+			return nil, provider.ErrPresetMapNotFound
+		}
+		preset, err := p.getPreset(presetID)
+		if err != nil {
+			return nil, err
+		}
+		streamingOutputs[preset.Container]++
+		files = append(files, outputFile(destination+output.FileName, preset))
+	}
+	manifestContainer := "m3u8"
+	if dbJob.StreamingParams.Protocol == "dash" {
+		manifestContainer = "mpd"
+	}
+	if n := streamingOutputs[manifestContainer]; n > 0 && dbJob.StreamingParams.PlaylistFileName != "" {
+		files = append(files, provider.OutputFile{
+			Path:      destination + dbJob.StreamingParams.PlaylistFileName,
+			Container: manifestContainer,
+			FileSize:  int64(100 * n),
+		})
+	}
+	fail, err := p.shouldFail(dbJob.SourceMedia)
+	if err != nil {
+		return nil, err
+	}
+	j := job{
+		id:           dbJob.ID,
+		source:       dbJob.SourceMedia,
+		creationTime: p.now(),
+		fail:         fail,
+		files:        files,
+		destination:  destination,
+	}
+	p.store.mtx.Lock()
+	defer p.store.mtx.Unlock()
+	if _, ok := p.store.jobs[j.id]; ok {
+		return nil, fmt.Errorf("job %q already exists", j.id)
+	}
+	p.store.jobs[j.id] = &j
+	return &provider.JobStatus{
+		ProviderName:  Name,
+		ProviderJobID: j.id,
+		Status:        provider.StatusQueued,
+	}, nil
+}
+
+// shouldFail decides whether a job with the given source media should fail,
+// according to the configured failure pattern and rate.
+func (p *fakeProvider) shouldFail(source string) (bool, error) {
+	if p.config.FailurePattern != "" {
+		pattern, err := regexp.Compile(p.config.FailurePattern)
+		if err != nil {
+			return false, fmt.Errorf("invalid failure pattern: %s", err)
+		}
+		if pattern.MatchString(source) {
+			return true, nil
+		}
+	}
+	if p.config.FailureRate <= 0 {
+		return false, nil
+	}
+	p.store.mtx.Lock()
+	defer p.store.mtx.Unlock()
+	return p.store.rand.Float64() < p.config.FailureRate, nil
+}
+
+// outputFile returns the file generated for the given preset. The size of the
+// file is derived from the bitrate of the preset.
+func outputFile(path string, preset db.Preset) provider.OutputFile {
+	width, _ := strconv.ParseInt(preset.Video.Width, 10, 64)
+	height, _ := strconv.ParseInt(preset.Video.Height, 10, 64)
+	videoBitrate, _ := strconv.ParseInt(preset.Video.Bitrate, 10, 64)
+	audioBitrate, _ := strconv.ParseInt(preset.Audio.Bitrate, 10, 64)
+	return provider.OutputFile{
+		Path:       path,
+		Container:  preset.Container,
+		VideoCodec: preset.Video.Codec,
+		Width:      width,
+		Height:     height,
+		FileSize:   (videoBitrate + audioBitrate) / 8 * int64(sourceDuration/time.Second),
+	}
+}
+
+func (p *fakeProvider) JobStatus(dbJob *db.Job) (*provider.JobStatus, error) {
+	p.store.mtx.Lock()
+	defer p.store.mtx.Unlock()
+	j, ok := p.store.jobs[dbJob.ProviderJobID]
+	if !ok {
+		return nil, provider.JobNotFoundError{ID: dbJob.ProviderJobID}
+	}
+	status := provider.JobStatus{
+		ProviderName:   Name,
+		ProviderJobID:  j.id,
+		Output:         provider.JobOutput{Destination: j.destination},
+		ProviderStatus: map[string]interface{}{"sourcefile": j.source},
+	}
+	queueTime := time.Duration(p.config.QueueTime) * time.Millisecond
+	processingTime := time.Duration(p.config.ProcessingTime) * time.Millisecond
+	elapsed := p.now().Sub(j.creationTime)
+	switch {
+	case j.canceled:
+		status.Status = provider.StatusCanceled
+	case elapsed < queueTime:
+		status.Status = provider.StatusQueued
+	case elapsed < queueTime+processingTime:
+		status.Status = provider.StatusStarted
+		status.Progress = float64(elapsed-queueTime) * 100 / float64(processingTime)
+		status.SourceInfo = provider.SourceInfo{Duration: sourceDuration}
+	case j.fail:
+		status.Status = provider.StatusFailed
+		status.StatusMessage = "simulated failure"
+		status.SourceInfo = provider.SourceInfo{Duration: sourceDuration}
+	default:
+		status.Status = provider.StatusFinished
+		status.Progress = 100
+		status.SourceInfo = provider.SourceInfo{Duration: sourceDuration}
+		status.Output.Files = make([]provider.OutputFile, len(j.files))
+		copy(status.Output.Files, j.files)
+	}
+	return &status, nil
+}
+
+func (p *fakeProvider) CancelJob(id string) error {
+	p.store.mtx.Lock()
+	j, ok := p.store.jobs[id]
+	p.store.mtx.Unlock()
+	if !ok {
+		return provider.JobNotFoundError{ID: id}
+	}
+	status, err := p.JobStatus(&db.Job{ProviderJobID: id})
+	if err != nil {
+		return err
+	}
+	switch status.Status {
+	case provider.StatusFinished, provider.StatusFailed:
+		return fmt.Errorf("job %q is already done", id)
+	}
+	p.store.mtx.Lock()
+	j.canceled = true
+	p.store.mtx.Unlock()
+	return nil
+}
+
+func (p *fakeProvider) Healthcheck() error {
+	if p.config.Unhealthy {
+		return errors.New("simulated unhealthy provider")
+	}
+	return nil
+}
+
+func (p *fakeProvider) CreatePreset(preset db.Preset) (string, error) {
+	if preset.Name == "" {
+		return "", errors.New("preset name is required")
+	}
+	p.store.mtx.Lock()
+	defer p.store.mtx.Unlock()
+	p.store.presets[preset.Name] = preset
+	return preset.Name, nil
+}
+
+func (p *fakeProvider) UpdatePreset(presetID string, preset db.Preset) error {
+	p.store.mtx.Lock()
+	defer p.store.mtx.Unlock()
+	if _, ok := p.store.presets[presetID]; !ok {
+		return errPresetNotFound
+	}
+	p.store.presets[presetID] = preset
+	return nil
+}
+
+func (p *fakeProvider) GetPreset(presetID string) (interface{}, error) {
+	preset, err := p.getPreset(presetID)
+	if err != nil {
+		return nil, err
+	}
+	return &preset, nil
+}
+
+func (p *fakeProvider) getPreset(presetID string) (db.Preset, error) {
+	p.store.mtx.Lock()
+	defer p.store.mtx.Unlock()
+	preset, ok := p.store.presets[presetID]
+	if !ok {
+		return db.Preset{}, errPresetNotFound
+	}
+	return preset, nil
+}
+
+func (*fakeProvider) NormalizePreset(preset interface{}) (db.Preset, error) {
+	switch preset := preset.(type) {
+	case db.Preset:
+		return preset, nil
+	case *db.Preset:
+		return *preset, nil
+	default:
+		return db.Preset{}, fmt.Errorf("unexpected preset type %T", preset)
+	}
+}
+
+func (p *fakeProvider) DeletePreset(presetID string) error {
+	p.store.mtx.Lock()
+	defer p.store.mtx.Unlock()
+	if _, ok := p.store.presets[presetID]; !ok {
+		return errPresetNotFound
+	}
+	delete(p.store.presets, presetID)
+	return nil
+}
+
+func (p *fakeProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		InputFormats:  []string{"prores", "h264"},
+		OutputFormats: []string{"mp4", "hls", "dash", "webm"},
+		Destinations:  []string{"s3"},
+	}
+}
+
+func fakeProviderFactory(cfg *config.Config) (provider.TranscodingProvider, error) {
+	if cfg.FakeProvider == nil || !cfg.FakeProvider.Enabled {
+		return nil, errFakeProviderDisabled
+	}
+	return &fakeProvider{config: cfg.FakeProvider, store: defaultStore, now: time.Now}, nil
+}
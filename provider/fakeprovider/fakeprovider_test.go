@@ -0,0 +1,354 @@
+package fakeprovider
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestProvider(t *testing.T, cfg config.FakeProvider) (*fakeProvider, *fakeClock) {
+	clock := fakeClock{now: time.Date(2016, 10, 16, 12, 0, 0, 0, time.UTC)}
+	prov := &fakeProvider{config: &cfg, store: newStore(), now: clock.Now}
+	presets := []db.Preset{
+		{
+			Name:      "mp4_1080p",
+			Container: "mp4",
+			Video:     db.VideoPreset{Width: "1920", Height: "1080", Codec: "h264", Bitrate: "3500000"},
+			Audio:     db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+		},
+		{
+			Name:      "hls_480p",
+			Container: "m3u8",
+			Video:     db.VideoPreset{Width: "854", Height: "480", Codec: "h264", Bitrate: "1000000"},
+			Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+		},
+		{
+			Name:      "dash_720p",
+			Container: "mpd",
+			Video:     db.VideoPreset{Width: "1280", Height: "720", Codec: "h264", Bitrate: "2000000"},
+			Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+		},
+	}
+	for _, preset := range presets {
+		if _, err := prov.CreatePreset(preset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return prov, &clock
+}
+
+func newTestJob(id, source string) *db.Job {
+	return &db.Job{
+		ID:              id,
+		SourceMedia:     source,
+		StreamingParams: db.StreamingParams{Protocol: "hls", PlaylistFileName: "hls/index.m3u8"},
+		Outputs: []db.TranscodeOutput{
+			{FileName: "video_1080p.mp4", Preset: db.PresetMap{Name: "mp4_1080p", ProviderMapping: map[string]string{Name: "mp4_1080p"}}},
+			{FileName: "hls/video_480p.m3u8", Preset: db.PresetMap{Name: "hls_480p", ProviderMapping: map[string]string{Name: "hls_480p"}}},
+		},
+	}
+}
+
+func TestFakeProviderFactory(t *testing.T) {
+	prov, err := fakeProviderFactory(&config.Config{FakeProvider: &config.FakeProvider{}})
+	if prov != nil {
+		t.Errorf("Unexpected non-nil provider: %#v", prov)
+	}
+	if err != errFakeProviderDisabled {
+		t.Errorf("Wrong error returned. Want errFakeProviderDisabled. Got %#v", err)
+	}
+	cfg := config.FakeProvider{Enabled: true, Destination: "s3://fake-bucket/"}
+	prov, err = fakeProviderFactory(&config.Config{FakeProvider: &cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fprov, ok := prov.(*fakeProvider)
+	if !ok {
+		t.Fatalf("Wrong provider returned. Want *fakeProvider. Got %#v", prov)
+	}
+	if fprov.config != &cfg {
+		t.Errorf("Wrong config. Want %#v. Got %#v", &cfg, fprov.config)
+	}
+}
+
+func TestFakeProviderJobLifecycle(t *testing.T) {
+	prov, clock := newTestProvider(t, config.FakeProvider{Destination: "s3://fake-bucket/", QueueTime: 1000, ProcessingTime: 4000})
+	job := newTestJob("job-123", "s3://bucket/source.mov")
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &provider.JobStatus{ProviderName: Name, ProviderJobID: "job-123", Status: provider.StatusQueued}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("wrong job status returned\nwant %#v\ngot  %#v", expected, status)
+	}
+	job.ProviderJobID = status.ProviderJobID
+	var tests = []struct {
+		givenElapsed time.Duration
+		wantStatus   provider.Status
+		wantProgress float64
+	}{
+		{500 * time.Millisecond, provider.StatusQueued, 0},
+		{1500 * time.Millisecond, provider.StatusStarted, 25},
+		{2 * time.Second, provider.StatusStarted, 75},
+		{2 * time.Second, provider.StatusFinished, 100},
+	}
+	for _, test := range tests {
+		clock.Advance(test.givenElapsed)
+		status, err = prov.JobStatus(job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Status != test.wantStatus {
+			t.Errorf("wrong status. Want %q. Got %q", test.wantStatus, status.Status)
+		}
+		if status.Progress != test.wantProgress {
+			t.Errorf("wrong progress. Want %f. Got %f", test.wantProgress, status.Progress)
+		}
+	}
+	expectedOutput := provider.JobOutput{
+		Destination: "s3://fake-bucket/job-123/",
+		Files: []provider.OutputFile{
+			{
+				Path:       "s3://fake-bucket/job-123/video_1080p.mp4",
+				Container:  "mp4",
+				VideoCodec: "h264",
+				Width:      1920,
+				Height:     1080,
+				FileSize:   27210000,
+			},
+			{
+				Path:       "s3://fake-bucket/job-123/hls/video_480p.m3u8",
+				Container:  "m3u8",
+				VideoCodec: "h264",
+				Width:      854,
+				Height:     480,
+				FileSize:   7980000,
+			},
+			{
+				Path:      "s3://fake-bucket/job-123/hls/index.m3u8",
+				Container: "m3u8",
+				FileSize:  100,
+			},
+		},
+	}
+	if !reflect.DeepEqual(status.Output, expectedOutput) {
+		t.Errorf("wrong job output\nwant %#v\ngot  %#v", expectedOutput, status.Output)
+	}
+	if status.SourceInfo.Duration != time.Minute {
+		t.Errorf("wrong source duration. Want %s. Got %s", time.Minute, status.SourceInfo.Duration)
+	}
+}
+
+func TestFakeProviderTranscodeDASH(t *testing.T) {
+	prov, clock := newTestProvider(t, config.FakeProvider{Destination: "s3://fake-bucket/", QueueTime: 1000, ProcessingTime: 4000})
+	job := newTestJob("job-123", "s3://bucket/source.mov")
+	job.StreamingParams = db.StreamingParams{Protocol: "dash", PlaylistFileName: "dash/index.mpd"}
+	job.Outputs = append(job.Outputs, db.TranscodeOutput{
+		FileName: "dash/video_720p.mpd",
+		Preset:   db.PresetMap{Name: "dash_720p", ProviderMapping: map[string]string{Name: "dash_720p"}},
+	})
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.ProviderJobID = status.ProviderJobID
+	clock.Advance(5 * time.Second)
+	status, err = prov.JobStatus(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := status.Output.Files
+	expected := provider.OutputFile{Path: "s3://fake-bucket/job-123/dash/index.mpd", Container: "mpd", FileSize: 100}
+	if len(files) != 4 || files[3] != expected {
+		t.Errorf("wrong output files. Want 4 files, with the manifest %#v. Got %#v", expected, files)
+	}
+}
+
+func TestFakeProviderFailureInjection(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenConfig   config.FakeProvider
+		givenSource   string
+		wantStatus    provider.Status
+	}{
+		{
+			"source matching the failure pattern",
+			config.FakeProvider{FailurePattern: "fail"},
+			"s3://bucket/fail.mov",
+			provider.StatusFailed,
+		},
+		{
+			"source not matching the failure pattern",
+			config.FakeProvider{FailurePattern: "fail"},
+			"s3://bucket/source.mov",
+			provider.StatusFinished,
+		},
+		{
+			"failure rate of 100%",
+			config.FakeProvider{FailureRate: 1},
+			"s3://bucket/source.mov",
+			provider.StatusFailed,
+		},
+	}
+	for _, test := range tests {
+		prov, clock := newTestProvider(t, test.givenConfig)
+		job := newTestJob("job-123", test.givenSource)
+		status, err := prov.Transcode(job)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
+		job.ProviderJobID = status.ProviderJobID
+		clock.Advance(time.Second)
+		status, err = prov.JobStatus(job)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
+		if status.Status != test.wantStatus {
+			t.Errorf("%s: wrong status. Want %q. Got %q", test.givenTestCase, test.wantStatus, status.Status)
+		}
+	}
+}
+
+func TestFakeProviderTranscodeErrors(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenConfig   config.FakeProvider
+		givenJob      *db.Job
+		wantErrMsg    string
+	}{
+		{
+			"missing preset mapping",
+			config.FakeProvider{},
+			&db.Job{
+				ID:      "job-1",
+				Outputs: []db.TranscodeOutput{{FileName: "video.mp4", Preset: db.PresetMap{Name: "mp4_1080p"}}},
+			},
+			provider.ErrPresetMapNotFound.Error(),
+		},
+		{
+			"unknown preset",
+			config.FakeProvider{},
+			&db.Job{
+				ID:      "job-2",
+				Outputs: []db.TranscodeOutput{{FileName: "video.mp4", Preset: db.PresetMap{Name: "mp4_4k", ProviderMapping: map[string]string{Name: "mp4_4k"}}}},
+			},
+			"preset not found",
+		},
+		{
+			"invalid failure pattern",
+			config.FakeProvider{FailurePattern: "fail("},
+			newTestJob("job-3", "s3://bucket/source.mov"),
+			"invalid failure pattern: error parsing regexp: missing closing ): `fail(`",
+		},
+	}
+	for _, test := range tests {
+		prov, _ := newTestProvider(t, test.givenConfig)
+		status, err := prov.Transcode(test.givenJob)
+		if err == nil || err.Error() != test.wantErrMsg {
+			t.Errorf("%s: wrong error returned. Want %q. Got %v", test.givenTestCase, test.wantErrMsg, err)
+		}
+		if status != nil {
+			t.Errorf("%s: unexpected non-nil status: %#v", test.givenTestCase, status)
+		}
+	}
+}
+
+func TestFakeProviderCancelJob(t *testing.T) {
+	prov, clock := newTestProvider(t, config.FakeProvider{QueueTime: 1000, ProcessingTime: 1000})
+	job := newTestJob("job-123", "s3://bucket/source.mov")
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.ProviderJobID = status.ProviderJobID
+	err = prov.CancelJob(job.ProviderJobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	status, err = prov.JobStatus(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != provider.StatusCanceled {
+		t.Errorf("wrong status. Want %q. Got %q", provider.StatusCanceled, status.Status)
+	}
+	err = prov.CancelJob("some-job")
+	if _, ok := err.(provider.JobNotFoundError); !ok {
+		t.Errorf("wrong error returned. Want JobNotFoundError. Got %#v", err)
+	}
+}
+
+func TestFakeProviderPresets(t *testing.T) {
+	prov, _ := newTestProvider(t, config.FakeProvider{})
+	preset, err := prov.GetPreset("mp4_1080p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := preset.(*db.Preset).Name; name != "mp4_1080p" {
+		t.Errorf("wrong preset returned. Want %q. Got %q", "mp4_1080p", name)
+	}
+	normalized, err := prov.NormalizePreset(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(normalized, *preset.(*db.Preset)) {
+		t.Errorf("wrong normalized preset\nwant %#v\ngot  %#v", *preset.(*db.Preset), normalized)
+	}
+	updated := *preset.(*db.Preset)
+	updated.Video.Bitrate = "5000000"
+	err = prov.UpdatePreset("mp4_1080p", updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preset, _ = prov.GetPreset("mp4_1080p")
+	if !reflect.DeepEqual(*preset.(*db.Preset), updated) {
+		t.Errorf("preset not updated\nwant %#v\ngot  %#v", updated, *preset.(*db.Preset))
+	}
+	err = prov.DeletePreset("mp4_1080p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = prov.GetPreset("mp4_1080p")
+	if err != errPresetNotFound {
+		t.Errorf("wrong error returned. Want errPresetNotFound. Got %#v", err)
+	}
+	err = prov.DeletePreset("mp4_1080p")
+	if err != errPresetNotFound {
+		t.Errorf("wrong error returned. Want errPresetNotFound. Got %#v", err)
+	}
+	err = prov.UpdatePreset("mp4_1080p", updated)
+	if err != errPresetNotFound {
+		t.Errorf("wrong error returned. Want errPresetNotFound. Got %#v", err)
+	}
+}
+
+func TestFakeProviderHealthcheck(t *testing.T) {
+	prov, _ := newTestProvider(t, config.FakeProvider{})
+	if err := prov.Healthcheck(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	prov.config.Unhealthy = true
+	if err := prov.Healthcheck(); err == nil {
+		t.Error("unexpected <nil> error for unhealthy provider")
+	}
+}
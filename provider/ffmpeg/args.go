@@ -0,0 +1,434 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+var videoCodecs = map[string]string{
+	"h264": "libx264",
+	"hevc": "libx265",
+	"vp8":  "libvpx",
+	"vp9":  "libvpx-vp9",
+	"av1":  "libaom-av1",
+}
+
+// videoCodecParameters lists the codec-specific parameters that can be
+// translated to each encoder. Two-pass encoding isn't supported, as each
+// output is transcoded in a single run of ffmpeg.
+var videoCodecParameters = map[string][]string{
+	"h264": {"crf", "maxBitrate", "bufferSize", "bFrames", "referenceFrames", "frameRate"},
+	"hevc": {"tier", "bitDepth", "crf", "maxBitrate", "bufferSize", "bFrames", "referenceFrames", "frameRate"},
+	"vp8":  {"crf", "maxBitrate", "bufferSize", "frameRate"},
+	"vp9":  {"bitDepth", "crf", "maxBitrate", "bufferSize", "frameRate"},
+	"av1":  {"bitDepth", "crf", "maxBitrate", "bufferSize", "frameRate"},
+}
+
+// presetConstraints describes the presets supported by ffmpeg. Containers
+// aren't constrained, as they're given as-is to ffmpeg.
+var presetConstraints = provider.PresetConstraints{
+	AudioCodecs:     []string{"aac", "mp3", "opus", "vorbis"},
+	VideoCodecs:     videoCodecParameters,
+	AudioParameters: audioParameters,
+}
+
+// audioParameters lists the optional audio parameters that can be
+// translated to ffmpeg. Additional audio tracks aren't supported in HLS
+// outputs, as each playlist is generated with a single audio stream.
+var (
+	audioParameters    = []string{"channels", "channelLayout", "sampleRate", "language", "loudness", "audioTracks", "audioOnly"}
+	hlsAudioParameters = []string{"channels", "channelLayout", "sampleRate", "language", "loudness", "audioOnly"}
+)
+
+var pixelFormats = map[string]string{
+	"8":  "yuv420p",
+	"10": "yuv420p10le",
+}
+
+var audioCodecs = map[string]string{
+	"aac":    "aac",
+	"mp3":    "libmp3lame",
+	"opus":   "libopus",
+	"vorbis": "libvorbis",
+}
+
+// sourceProtocols lists the protocols that ffmpeg may use for reading the
+// source media, so sources can't reference other protocols (like playlists
+// pointing to other inputs).
+const sourceProtocols = "file,http,https,tcp,tls"
+
+// buildArgs returns the list of arguments for transcoding the given input
+// into the given output using ffmpeg. Progress is reported in the standard
+// output, using the -progress option. When a clip is given, only that
+// segment of the input is transcoded.
+func buildArgs(input, output string, preset db.Preset, clip *db.Clip, segmentDuration uint) ([]string, error) {
+	args := []string{"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", sourceProtocols}
+	args = append(append(args, buildClipArgs(clip)...), "-i", input)
+	videoArgs, err := buildVideoArgs(preset)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, videoArgs...)
+	audioArgs, err := buildAudioArgs(preset)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, audioArgs...)
+	args = append(args, buildContainerArgs(output, preset, segmentDuration)...)
+	return append(args, output), nil
+}
+
+func buildVideoArgs(preset db.Preset) ([]string, error) {
+	var args []string
+	video := preset.Video
+	if video.Codec != "" {
+		codec, ok := videoCodecs[video.Codec]
+		if !ok {
+			return nil, provider.UnsupportedParameterError{Parameter: "video.codec", Value: video.Codec}
+		}
+		args = append(args, "-c:v", codec)
+	}
+	if err := provider.CheckVideoParameters(video, videoCodecParameters[video.Codec]...); err != nil {
+		return nil, err
+	}
+	if video.Bitrate != "" {
+		bitrate, err := strconv.ParseUint(video.Bitrate, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid video bitrate %q", video.Bitrate)
+		}
+		args = append(args, "-b:v", video.Bitrate)
+		if preset.RateControl == "CBR" {
+			bufferSize := video.BufferSize
+			if bufferSize == "" {
+				bufferSize = strconv.FormatUint(bitrate*2, 10)
+			}
+			args = append(args, "-minrate", video.Bitrate, "-maxrate", video.Bitrate, "-bufsize", bufferSize)
+		}
+	} else if video.CRF != "" && (video.Codec == "vp9" || video.Codec == "av1") {
+		// libvpx-vp9 and libaom-av1 only run in constant quality mode
+		// when the target bitrate is zero.
+		args = append(args, "-b:v", "0")
+	}
+	codecArgs, err := buildVideoCodecArgs(preset)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, codecArgs...)
+	if video.FrameRate != "" {
+		if _, err := strconv.ParseFloat(video.FrameRate, 64); err != nil {
+			return nil, fmt.Errorf("invalid frame rate %q", video.FrameRate)
+		}
+		args = append(args, "-r", video.FrameRate)
+	}
+	if video.GopSize != "" {
+		if _, err := strconv.ParseUint(video.GopSize, 10, 32); err != nil {
+			return nil, fmt.Errorf("invalid GOP size %q", video.GopSize)
+		}
+		args = append(args, "-g", video.GopSize)
+		if video.GopMode == "fixed" {
+			args = append(args, "-keyint_min", video.GopSize, "-sc_threshold", "0")
+		}
+	}
+	if video.Codec == "h264" || video.Codec == "hevc" {
+		if video.Profile != "" {
+			args = append(args, "-profile:v", strings.ToLower(video.Profile))
+		}
+		if video.ProfileLevel != "" {
+			args = append(args, "-level:v", video.ProfileLevel)
+		}
+		switch video.Tier {
+		case "", "main":
+		case "high":
+			args = append(args, "-x265-params", "high-tier=1")
+		default:
+			return nil, provider.UnsupportedParameterError{Parameter: "video.tier", Value: video.Tier}
+		}
+	}
+	if video.Codec == "h264" || video.BitDepth != "" {
+		bitDepth := video.BitDepth
+		if bitDepth == "" {
+			bitDepth = "8"
+		}
+		pixelFormat, ok := pixelFormats[bitDepth]
+		if !ok {
+			return nil, provider.UnsupportedParameterError{Parameter: "video.bitDepth", Value: video.BitDepth}
+		}
+		args = append(args, "-pix_fmt", pixelFormat)
+	}
+	filters, err := buildVideoFilters(video)
+	if err != nil {
+		return nil, err
+	}
+	if len(filters) > 0 {
+		args = append(args, "-vf", strings.Join(filters, ","))
+	}
+	return args, nil
+}
+
+// buildVideoCodecArgs returns the arguments for the numeric codec-specific
+// parameters of the preset. Support for each parameter is checked by the
+// caller.
+func buildVideoCodecArgs(preset db.Preset) ([]string, error) {
+	video := preset.Video
+	params := []struct {
+		name  string
+		value string
+		flag  string
+	}{
+		{"CRF", video.CRF, "-crf"},
+		{"max bitrate", video.MaxBitrate, "-maxrate"},
+		{"buffer size", video.BufferSize, "-bufsize"},
+		{"B-frames", video.BFrames, "-bf"},
+		{"reference frames", video.ReferenceFrames, "-refs"},
+	}
+	var args []string
+	for _, param := range params {
+		if param.value == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(param.value, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid %s %q", param.name, param.value)
+		}
+		// with CBR, the max bitrate and the buffer size are derived
+		// from the bitrate.
+		if preset.RateControl == "CBR" && video.Bitrate != "" && (param.flag == "-maxrate" || param.flag == "-bufsize") {
+			continue
+		}
+		args = append(args, param.flag, param.value)
+	}
+	return args, nil
+}
+
+func buildVideoFilters(video db.VideoPreset) ([]string, error) {
+	var filters []string
+	if video.InterlaceMode == "progressive" {
+		filters = append(filters, "yadif")
+	}
+	width, err := parseDimension(video.Width)
+	if err != nil {
+		return nil, fmt.Errorf("invalid width %q", video.Width)
+	}
+	height, err := parseDimension(video.Height)
+	if err != nil {
+		return nil, fmt.Errorf("invalid height %q", video.Height)
+	}
+	if width == 0 && height == 0 {
+		return filters, nil
+	}
+	// -2 keeps the aspect ratio, making sure that the dimension is
+	// divisible by 2, as required by most codecs.
+	scaleWidth, scaleHeight := "-2", "-2"
+	if width > 0 {
+		scaleWidth = strconv.FormatInt(width, 10)
+	}
+	if height > 0 {
+		scaleHeight = strconv.FormatInt(height, 10)
+	}
+	return append(filters, "scale="+scaleWidth+":"+scaleHeight), nil
+}
+
+// buildAudioArgs returns the arguments for the audio renditions of the
+// preset. When the preset has additional audio tracks, each rendition is
+// encoded from the audio stream of the source with the same index.
+func buildAudioArgs(preset db.Preset) ([]string, error) {
+	supported := audioParameters
+	if preset.Container == "m3u8" {
+		supported = hlsAudioParameters
+	}
+	if err := provider.CheckAudioParameters(preset, supported...); err != nil {
+		return nil, err
+	}
+	var args []string
+	if preset.AudioOnly() {
+		args = append(args, "-vn")
+	} else if len(preset.AudioTracks) > 0 {
+		args = append(args, "-map", "0:v:0")
+	}
+	for i, audio := range preset.AudioRenditions() {
+		index := strconv.Itoa(i)
+		stream := ""
+		if len(preset.AudioTracks) > 0 {
+			stream = ":" + index
+			args = append(args, "-map", "0:a:"+index)
+		}
+		if audio.Codec != "" {
+			codec, ok := audioCodecs[audio.Codec]
+			if !ok {
+				return nil, fmt.Errorf("unsupported audio codec %q", audio.Codec)
+			}
+			args = append(args, "-c:a"+stream, codec)
+		}
+		params := []struct {
+			name  string
+			value string
+			flag  string
+		}{
+			{"audio bitrate", audio.Bitrate, "-b:a"},
+			{"number of audio channels", audio.Channels, "-ac"},
+			{"audio sample rate", audio.SampleRate, "-ar"},
+		}
+		for _, param := range params {
+			if param.value == "" {
+				continue
+			}
+			if _, err := strconv.ParseUint(param.value, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid %s %q", param.name, param.value)
+			}
+			args = append(args, param.flag+stream, param.value)
+		}
+		if audio.Language != "" {
+			args = append(args, "-metadata:s:a:"+index, "language="+audio.Language)
+		}
+		filters, err := buildAudioFilters(audio)
+		if err != nil {
+			return nil, err
+		}
+		if len(filters) > 0 {
+			args = append(args, "-filter:a"+stream, strings.Join(filters, ","))
+		}
+	}
+	return args, nil
+}
+
+func buildAudioFilters(audio db.AudioPreset) ([]string, error) {
+	var filters []string
+	if audio.ChannelLayout != "" {
+		filters = append(filters, "aformat=channel_layouts="+audio.ChannelLayout)
+	}
+	if audio.Loudness != "" {
+		if _, err := strconv.ParseFloat(audio.Loudness, 64); err != nil {
+			return nil, fmt.Errorf("invalid loudness %q", audio.Loudness)
+		}
+		filters = append(filters, "loudnorm=I="+audio.Loudness)
+	}
+	return filters, nil
+}
+
+func buildContainerArgs(output string, preset db.Preset, segmentDuration uint) []string {
+	switch preset.Container {
+	case "m3u8":
+		args := []string{"-f", "hls", "-hls_playlist_type", "vod", "-hls_segment_filename", segmentFileName(output)}
+		if segmentDuration > 0 {
+			args = append(args, "-hls_time", strconv.FormatUint(uint64(segmentDuration), 10))
+		}
+		return args
+	case "mp4":
+		return []string{"-f", "mp4", "-movflags", "+faststart"}
+	case "mpd":
+		// DASH outputs are packaged in the manifest afterwards, see
+		// buildDASHArgs.
+		return []string{"-f", "mp4"}
+	case "":
+		return nil
+	default:
+		return []string{"-f", preset.Container}
+	}
+}
+
+// buildDASHArgs returns the list of arguments for packaging the given outputs,
+// transcoded to MP4 files, in the given DASH manifest. Streams are copied,
+// grouping the video and the audio representations in one adaptation set
+// each.
+func buildDASHArgs(manifest string, outputs []output, segmentDuration uint) []string {
+	args := []string{"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1"}
+	for _, out := range outputs {
+		args = append(args, "-i", out.path)
+	}
+	var video, audio bool
+	for i, out := range outputs {
+		input := strconv.Itoa(i)
+		if !out.preset.AudioOnly() {
+			args = append(args, "-map", input+":v")
+			video = true
+		}
+		if out.preset.Audio.Codec != "" {
+			args = append(args, "-map", input+":a")
+			audio = true
+		}
+	}
+	var adaptationSets []string
+	if video {
+		adaptationSets = append(adaptationSets, "id=0,streams=v")
+	}
+	if audio {
+		adaptationSets = append(adaptationSets, "id="+strconv.Itoa(len(adaptationSets))+",streams=a")
+	}
+	args = append(args, "-c", "copy", "-f", "dash", "-adaptation_sets", strings.Join(adaptationSets, " "))
+	if segmentDuration > 0 {
+		args = append(args, "-seg_duration", strconv.FormatUint(uint64(segmentDuration), 10))
+	}
+	name := strings.TrimSuffix(filepath.Base(manifest), ".mpd")
+	args = append(args,
+		"-init_seg_name", name+"_init_$RepresentationID$.m4s",
+		"-media_seg_name", name+"_$RepresentationID$_$Number%05d$.m4s",
+	)
+	return append(args, manifest)
+}
+
+// buildClipArgs returns the input options that seek to the start of the
+// given clip and limit the input to its duration.
+func buildClipArgs(clip *db.Clip) []string {
+	if clip == nil {
+		return nil
+	}
+	var args []string
+	if clip.Start != "" {
+		args = append(args, "-ss", provider.FormatSeconds(clip.StartTime()))
+	}
+	if clip.End != "" {
+		args = append(args, "-t", provider.FormatSeconds(clip.Duration()))
+	}
+	return args
+}
+
+// buildThumbnailArgs returns the list of arguments for extracting thumbnails
+// from the given input using ffmpeg. Thumbnails are taken at the interval of
+// the given thumbnails, writing them to the output pattern, unless a timecode
+// is given, in which case a single thumbnail is taken at that position.
+func buildThumbnailArgs(input, output string, thumbnails *db.Thumbnails, timecode string) []string {
+	args := []string{"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", sourceProtocols}
+	var filters []string
+	if timecode != "" {
+		args = append(args, "-ss", timecode, "-i", input, "-frames:v", "1")
+	} else {
+		args = append(args, "-i", input)
+		filters = append(filters, "fps=1/"+strconv.FormatUint(uint64(thumbnails.Interval), 10))
+	}
+	if thumbnails.Width > 0 || thumbnails.Height > 0 {
+		// unlike videos, images may have odd dimensions.
+		scaleWidth, scaleHeight := "-1", "-1"
+		if thumbnails.Width > 0 {
+			scaleWidth = strconv.FormatUint(uint64(thumbnails.Width), 10)
+		}
+		if thumbnails.Height > 0 {
+			scaleHeight = strconv.FormatUint(uint64(thumbnails.Height), 10)
+		}
+		filters = append(filters, "scale="+scaleWidth+":"+scaleHeight)
+	}
+	if len(filters) > 0 {
+		args = append(args, "-vf", strings.Join(filters, ","))
+	}
+	args = append(args, "-an")
+	if thumbnails.Format == "jpg" {
+		args = append(args, "-q:v", "2")
+	}
+	return append(args, "-f", "image2", output)
+}
+
+// segmentFileName returns the pattern used for naming the segments of the
+// given HLS playlist, which are stored alongside the playlist.
+func segmentFileName(playlist string) string {
+	return strings.TrimSuffix(playlist, ".m3u8") + "_%05d.ts"
+}
+
+func parseDimension(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 32)
+}
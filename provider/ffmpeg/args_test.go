@@ -0,0 +1,470 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+func TestBuildArgs(t *testing.T) {
+	var tests = []struct {
+		givenTestCase   string
+		givenOutput     string
+		givenPreset     db.Preset
+		givenSegmentDur uint
+		wantArgs        []string
+	}{
+		{
+			"mp4 h264 preset",
+			"/tmp/job-123/video_1080p.mp4",
+			db.Preset{
+				Name:        "mp4_1080p",
+				Container:   "mp4",
+				RateControl: "CBR",
+				Video: db.VideoPreset{
+					Profile:       "Main",
+					ProfileLevel:  "3.1",
+					Width:         "1920",
+					Height:        "1080",
+					Codec:         "h264",
+					Bitrate:       "3500000",
+					GopSize:       "90",
+					GopMode:       "fixed",
+					InterlaceMode: "progressive",
+				},
+				Audio: db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+			},
+			0,
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", "file,http,https,tcp,tls", "-i", "/tmp/source.mov",
+				"-c:v", "libx264", "-b:v", "3500000", "-minrate", "3500000", "-maxrate", "3500000", "-bufsize", "7000000",
+				"-g", "90", "-keyint_min", "90", "-sc_threshold", "0",
+				"-profile:v", "main", "-level:v", "3.1", "-pix_fmt", "yuv420p",
+				"-vf", "yadif,scale=1920:1080",
+				"-c:a", "aac", "-b:a", "128000",
+				"-f", "mp4", "-movflags", "+faststart",
+				"/tmp/job-123/video_1080p.mp4",
+			},
+		},
+		{
+			"webm vp8 preset keeping the aspect ratio",
+			"/tmp/job-123/video_720p.webm",
+			db.Preset{
+				Name:      "webm_720p",
+				Container: "webm",
+				Video:     db.VideoPreset{Height: "720", Codec: "vp8", Bitrate: "1000000", GopSize: "90", FrameRate: "30"},
+				Audio:     db.AudioPreset{Codec: "vorbis", Bitrate: "64000"},
+			},
+			0,
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", "file,http,https,tcp,tls", "-i", "/tmp/source.mov",
+				"-c:v", "libvpx", "-b:v", "1000000", "-r", "30", "-g", "90",
+				"-vf", "scale=-2:720",
+				"-c:a", "libvorbis", "-b:a", "64000",
+				"-f", "webm",
+				"/tmp/job-123/video_720p.webm",
+			},
+		},
+		{
+			"hls preset",
+			"/tmp/job-123/hls/video_480p.m3u8",
+			db.Preset{
+				Name:      "hls_480p",
+				Container: "m3u8",
+				Video:     db.VideoPreset{Width: "854", Codec: "h264", Bitrate: "1000000"},
+				Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+			},
+			4,
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", "file,http,https,tcp,tls", "-i", "/tmp/source.mov",
+				"-c:v", "libx264", "-b:v", "1000000", "-pix_fmt", "yuv420p",
+				"-vf", "scale=854:-2",
+				"-c:a", "aac", "-b:a", "64000",
+				"-f", "hls", "-hls_playlist_type", "vod", "-hls_segment_filename", "/tmp/job-123/hls/video_480p_%05d.ts", "-hls_time", "4",
+				"/tmp/job-123/hls/video_480p.m3u8",
+			},
+		},
+		{
+			"mp4 hevc 10-bit preset",
+			"/tmp/job-123/video_2160p.mp4",
+			db.Preset{
+				Name:      "hevc_2160p",
+				Container: "mp4",
+				Video: db.VideoPreset{
+					Profile:         "Main10",
+					Height:          "2160",
+					Codec:           "hevc",
+					Bitrate:         "12000000",
+					Tier:            "high",
+					BitDepth:        "10",
+					MaxBitrate:      "16000000",
+					BufferSize:      "24000000",
+					BFrames:         "4",
+					ReferenceFrames: "3",
+				},
+				Audio: db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+			},
+			0,
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", "file,http,https,tcp,tls", "-i", "/tmp/source.mov",
+				"-c:v", "libx265", "-b:v", "12000000", "-maxrate", "16000000", "-bufsize", "24000000", "-bf", "4", "-refs", "3",
+				"-profile:v", "main10", "-x265-params", "high-tier=1", "-pix_fmt", "yuv420p10le",
+				"-vf", "scale=-2:2160",
+				"-c:a", "aac", "-b:a", "128000",
+				"-f", "mp4", "-movflags", "+faststart",
+				"/tmp/job-123/video_2160p.mp4",
+			},
+		},
+		{
+			"webm vp9 constant quality preset",
+			"/tmp/job-123/video_1080p.webm",
+			db.Preset{
+				Name:      "vp9_1080p",
+				Container: "webm",
+				Video:     db.VideoPreset{Height: "1080", Codec: "vp9", CRF: "31", GopSize: "120"},
+				Audio:     db.AudioPreset{Codec: "opus", Bitrate: "96000"},
+			},
+			0,
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", "file,http,https,tcp,tls", "-i", "/tmp/source.mov",
+				"-c:v", "libvpx-vp9", "-b:v", "0", "-crf", "31", "-g", "120",
+				"-vf", "scale=-2:1080",
+				"-c:a", "libopus", "-b:a", "96000",
+				"-f", "webm",
+				"/tmp/job-123/video_1080p.webm",
+			},
+		},
+		{
+			"mp4 av1 10-bit preset with CBR",
+			"/tmp/job-123/video_720p.mp4",
+			db.Preset{
+				Name:        "av1_720p",
+				Container:   "mp4",
+				RateControl: "CBR",
+				Video:       db.VideoPreset{Height: "720", Codec: "av1", Bitrate: "2000000", BitDepth: "10", BufferSize: "3000000", MaxBitrate: "2500000"},
+				Audio:       db.AudioPreset{Codec: "opus", Bitrate: "96000"},
+			},
+			0,
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", "file,http,https,tcp,tls", "-i", "/tmp/source.mov",
+				"-c:v", "libaom-av1", "-b:v", "2000000", "-minrate", "2000000", "-maxrate", "2000000", "-bufsize", "3000000",
+				"-pix_fmt", "yuv420p10le",
+				"-vf", "scale=-2:720",
+				"-c:a", "libopus", "-b:a", "96000",
+				"-f", "mp4", "-movflags", "+faststart",
+				"/tmp/job-123/video_720p.mp4",
+			},
+		},
+		{
+			"hls audio-only preset",
+			"/tmp/job-123/hls/audio_es.m3u8",
+			db.Preset{
+				Name:      "hls_audio_es",
+				Container: "m3u8",
+				Audio:     db.AudioPreset{Codec: "aac", Bitrate: "96000", Channels: "2", SampleRate: "48000", Language: "es", Loudness: "-16"},
+			},
+			0,
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", "file,http,https,tcp,tls", "-i", "/tmp/source.mov",
+				"-vn", "-c:a", "aac", "-b:a", "96000", "-ac", "2", "-ar", "48000",
+				"-metadata:s:a:0", "language=es", "-filter:a", "loudnorm=I=-16",
+				"-f", "hls", "-hls_playlist_type", "vod", "-hls_segment_filename", "/tmp/job-123/hls/audio_es_%05d.ts",
+				"/tmp/job-123/hls/audio_es.m3u8",
+			},
+		},
+		{
+			"mp4 preset with multiple audio tracks",
+			"/tmp/job-123/video_720p.mp4",
+			db.Preset{
+				Name:        "mp4_720p",
+				Container:   "mp4",
+				Video:       db.VideoPreset{Codec: "h264", Bitrate: "2000000"},
+				Audio:       db.AudioPreset{Codec: "aac", Bitrate: "128000", ChannelLayout: "stereo", Language: "en"},
+				AudioTracks: []db.AudioPreset{{Codec: "aac", Bitrate: "384000", ChannelLayout: "5.1", Language: "es"}},
+			},
+			0,
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", "file,http,https,tcp,tls", "-i", "/tmp/source.mov",
+				"-c:v", "libx264", "-b:v", "2000000", "-pix_fmt", "yuv420p",
+				"-map", "0:v:0",
+				"-map", "0:a:0", "-c:a:0", "aac", "-b:a:0", "128000",
+				"-metadata:s:a:0", "language=en", "-filter:a:0", "aformat=channel_layouts=stereo",
+				"-map", "0:a:1", "-c:a:1", "aac", "-b:a:1", "384000",
+				"-metadata:s:a:1", "language=es", "-filter:a:1", "aformat=channel_layouts=5.1",
+				"-f", "mp4", "-movflags", "+faststart",
+				"/tmp/job-123/video_720p.mp4",
+			},
+		},
+	}
+	for _, test := range tests {
+		args, err := buildArgs("/tmp/source.mov", test.givenOutput, test.givenPreset, nil, test.givenSegmentDur)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, test.wantArgs) {
+			t.Errorf("%s: wrong args returned\nwant %q\ngot  %q", test.givenTestCase, test.wantArgs, args)
+		}
+	}
+}
+
+func TestBuildArgsClip(t *testing.T) {
+	preset := db.Preset{Container: "mp4", Video: db.VideoPreset{Codec: "h264"}}
+	var tests = []struct {
+		givenTestCase string
+		givenClip     *db.Clip
+		wantInputArgs []string
+	}{
+		{"start and end", &db.Clip{Start: "10", End: "25.5"}, []string{"-ss", "10", "-t", "15.5"}},
+		{"start only", &db.Clip{Start: "60"}, []string{"-ss", "60"}},
+		{"end only", &db.Clip{End: "30"}, []string{"-t", "30"}},
+		{"no clip", nil, nil},
+	}
+	for _, test := range tests {
+		args, err := buildArgs("/tmp/source.mov", "/tmp/output.mp4", preset, test.givenClip, 0)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
+		wantArgs := append([]string{"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", "file,http,https,tcp,tls"}, test.wantInputArgs...)
+		wantArgs = append(wantArgs, "-i", "/tmp/source.mov", "-c:v", "libx264", "-pix_fmt", "yuv420p", "-f", "mp4", "-movflags", "+faststart", "/tmp/output.mp4")
+		if !reflect.DeepEqual(args, wantArgs) {
+			t.Errorf("%s: wrong args returned\nwant %q\ngot  %q", test.givenTestCase, wantArgs, args)
+		}
+	}
+}
+
+func TestBuildThumbnailArgs(t *testing.T) {
+	var tests = []struct {
+		givenTestCase   string
+		givenOutput     string
+		givenThumbnails db.Thumbnails
+		givenTimecode   string
+		wantArgs        []string
+	}{
+		{
+			"interval",
+			"/tmp/job-123/thumbnails/thumbnail-%05d.jpg",
+			db.Thumbnails{Interval: 10, Format: "jpg"},
+			"",
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", "file,http,https,tcp,tls", "-i", "/tmp/source.mov",
+				"-vf", "fps=1/10", "-an", "-q:v", "2",
+				"-f", "image2", "/tmp/job-123/thumbnails/thumbnail-%05d.jpg",
+			},
+		},
+		{
+			"interval keeping the aspect ratio",
+			"/tmp/job-123/thumbnails/thumbnail-%05d.png",
+			db.Thumbnails{Interval: 5, Height: 90, Format: "png"},
+			"",
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", "file,http,https,tcp,tls", "-i", "/tmp/source.mov",
+				"-vf", "fps=1/5,scale=-1:90", "-an",
+				"-f", "image2", "/tmp/job-123/thumbnails/thumbnail-%05d.png",
+			},
+		},
+		{
+			"timecode",
+			"/tmp/job-123/thumbnails/thumbnail-00002.jpg",
+			db.Thumbnails{Timecodes: []string{"0", "12.5"}, Width: 320, Height: 180, Format: "jpg"},
+			"12.5",
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1", "-protocol_whitelist", "file,http,https,tcp,tls",
+				"-ss", "12.5", "-i", "/tmp/source.mov", "-frames:v", "1",
+				"-vf", "scale=320:180", "-an", "-q:v", "2",
+				"-f", "image2", "/tmp/job-123/thumbnails/thumbnail-00002.jpg",
+			},
+		},
+	}
+	for _, test := range tests {
+		args := buildThumbnailArgs("/tmp/source.mov", test.givenOutput, &test.givenThumbnails, test.givenTimecode)
+		if !reflect.DeepEqual(args, test.wantArgs) {
+			t.Errorf("%s: wrong args returned\nwant %q\ngot  %q", test.givenTestCase, test.wantArgs, args)
+		}
+	}
+}
+
+func TestBuildDASHArgs(t *testing.T) {
+	outputs := []output{
+		{
+			path: "/tmp/job-123/dash/video_1080p.mpd.mp4",
+			preset: db.Preset{
+				Container: "mpd",
+				Video:     db.VideoPreset{Codec: "h264"},
+				Audio:     db.AudioPreset{Codec: "aac"},
+			},
+		},
+		{
+			path:   "/tmp/job-123/dash/video_480p.mpd.mp4",
+			preset: db.Preset{Container: "mpd", Video: db.VideoPreset{Codec: "h264"}},
+		},
+		{
+			path:   "/tmp/job-123/dash/audio.mpd.mp4",
+			preset: db.Preset{Container: "mpd", Audio: db.AudioPreset{Codec: "aac"}},
+		},
+	}
+	var tests = []struct {
+		givenTestCase string
+		givenOutputs  []output
+		wantArgs      []string
+	}{
+		{
+			"video and audio",
+			outputs,
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1",
+				"-i", "/tmp/job-123/dash/video_1080p.mpd.mp4",
+				"-i", "/tmp/job-123/dash/video_480p.mpd.mp4",
+				"-i", "/tmp/job-123/dash/audio.mpd.mp4",
+				"-map", "0:v", "-map", "0:a", "-map", "1:v", "-map", "2:a",
+				"-c", "copy", "-f", "dash", "-adaptation_sets", "id=0,streams=v id=1,streams=a",
+				"-seg_duration", "4",
+				"-init_seg_name", "index_init_$RepresentationID$.m4s",
+				"-media_seg_name", "index_$RepresentationID$_$Number%05d$.m4s",
+				"/tmp/job-123/dash/index.mpd",
+			},
+		},
+		{
+			"audio only",
+			outputs[2:],
+			[]string{
+				"-hide_banner", "-nostdin", "-nostats", "-y", "-progress", "pipe:1",
+				"-i", "/tmp/job-123/dash/audio.mpd.mp4",
+				"-map", "0:a",
+				"-c", "copy", "-f", "dash", "-adaptation_sets", "id=0,streams=a",
+				"-seg_duration", "4",
+				"-init_seg_name", "index_init_$RepresentationID$.m4s",
+				"-media_seg_name", "index_$RepresentationID$_$Number%05d$.m4s",
+				"/tmp/job-123/dash/index.mpd",
+			},
+		},
+	}
+	for _, test := range tests {
+		args := buildDASHArgs("/tmp/job-123/dash/index.mpd", test.givenOutputs, 4)
+		if !reflect.DeepEqual(args, test.wantArgs) {
+			t.Errorf("%s: wrong args returned\nwant %q\ngot  %q", test.givenTestCase, test.wantArgs, args)
+		}
+	}
+}
+
+func TestBuildArgsErrors(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenPreset   db.Preset
+		wantErrMsg    string
+	}{
+		{
+			"unsupported video codec",
+			db.Preset{Container: "mp4", Video: db.VideoPreset{Codec: "mpeg1"}},
+			`unsupported value "mpeg1" for video.codec`,
+		},
+		{
+			"two-pass encoding",
+			db.Preset{Container: "mp4", Video: db.VideoPreset{Codec: "hevc", Bitrate: "5000000", TwoPass: true}},
+			`unsupported value "true" for video.twoPass`,
+		},
+		{
+			"B-frames in vp9",
+			db.Preset{Container: "webm", Video: db.VideoPreset{Codec: "vp9", BFrames: "2"}},
+			`unsupported value "2" for video.bFrames`,
+		},
+		{
+			"tier in h264",
+			db.Preset{Container: "mp4", Video: db.VideoPreset{Codec: "h264", Tier: "high"}},
+			`unsupported value "high" for video.tier`,
+		},
+		{
+			"unsupported hevc tier",
+			db.Preset{Container: "mp4", Video: db.VideoPreset{Codec: "hevc", Tier: "ultra"}},
+			`unsupported value "ultra" for video.tier`,
+		},
+		{
+			"unsupported bit depth",
+			db.Preset{Container: "mp4", Video: db.VideoPreset{Codec: "av1", BitDepth: "12"}},
+			`unsupported value "12" for video.bitDepth`,
+		},
+		{
+			"invalid CRF",
+			db.Preset{Container: "mp4", Video: db.VideoPreset{Codec: "hevc", CRF: "high"}},
+			`invalid CRF "high"`,
+		},
+		{
+			"unsupported audio codec",
+			db.Preset{Container: "mp4", Audio: db.AudioPreset{Codec: "ac3"}},
+			`unsupported audio codec "ac3"`,
+		},
+		{
+			"multiple audio tracks in hls",
+			db.Preset{Container: "m3u8", Video: db.VideoPreset{Codec: "h264"}, AudioTracks: []db.AudioPreset{{Codec: "aac"}}},
+			`unsupported value "1" for audioTracks`,
+		},
+		{
+			"invalid sample rate",
+			db.Preset{Container: "mp4", Audio: db.AudioPreset{Codec: "aac", SampleRate: "44.1k"}},
+			`invalid audio sample rate "44.1k"`,
+		},
+		{
+			"invalid video bitrate",
+			db.Preset{Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "3.5m"}},
+			`invalid video bitrate "3.5m"`,
+		},
+		{
+			"invalid GOP size",
+			db.Preset{Container: "mp4", Video: db.VideoPreset{Codec: "h264", GopSize: "2s"}},
+			`invalid GOP size "2s"`,
+		},
+		{
+			"invalid frame rate",
+			db.Preset{Container: "mp4", Video: db.VideoPreset{Codec: "h264", FrameRate: "30fps"}},
+			`invalid frame rate "30fps"`,
+		},
+		{
+			"invalid width",
+			db.Preset{Container: "mp4", Video: db.VideoPreset{Codec: "h264", Width: "auto"}},
+			`invalid width "auto"`,
+		},
+	}
+	for _, test := range tests {
+		args, err := buildArgs("/tmp/source.mov", "/tmp/output.mp4", test.givenPreset, nil, 0)
+		if err == nil || err.Error() != test.wantErrMsg {
+			t.Errorf("%s: wrong error returned. Want %q. Got %v", test.givenTestCase, test.wantErrMsg, err)
+		}
+		if args != nil {
+			t.Errorf("%s: unexpected non-nil args: %q", test.givenTestCase, args)
+		}
+	}
+}
+
+func TestReadProgress(t *testing.T) {
+	input := "frame=10\nout_time_us=1500000\nprogress=continue\nout_time_ms=N/A\nout_time_ms=3000000\nprogress=end\n"
+	var got []time.Duration
+	readProgress(strings.NewReader(input), func(d time.Duration) {
+		got = append(got, d)
+	})
+	want := []time.Duration{1500 * time.Millisecond, 3 * time.Second}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong progress reported\nwant %v\ngot  %v", want, got)
+	}
+}
+
+func TestReadStderr(t *testing.T) {
+	input := `Input #0, mov,mp4,m4a,3gp,3g2,mj2, from '/tmp/source.mov':
+  Duration: 00:01:02.50, start: 0.000000, bitrate: 2500 kb/s
+
+/tmp/source.mov: Invalid data found when processing input
+`
+	var duration time.Duration
+	lastLine := readStderr(strings.NewReader(input), func(d time.Duration) {
+		duration = d
+	})
+	if duration != 62500*time.Millisecond {
+		t.Errorf("wrong duration. Want %s. Got %s", 62500*time.Millisecond, duration)
+	}
+	if want := "/tmp/source.mov: Invalid data found when processing input"; lastLine != want {
+		t.Errorf("wrong last line. Want %q. Got %q", want, lastLine)
+	}
+}
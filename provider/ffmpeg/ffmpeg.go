@@ -0,0 +1,291 @@
+// Package ffmpeg provides a implementation of the provider that transcodes
+// media files locally, using the ffmpeg binary.
+//
+// It doesn't expose any public type. In order to use the provider, one must
+// import this package and then grab the factory from the provider package:
+//
+//     import (
+//         "github.com/NYTimes/video-transcoding-api/provider"
+//         "github.com/NYTimes/video-transcoding-api/provider/ffmpeg"
+//     )
+//
+//     func UseProvider() {
+//         factory, err := provider.GetProviderFactory(ffmpeg.Name)
+//         // handle err and use factory to get an instance of the provider.
+//     }
+//
+// Jobs are executed by a pool of workers, whose size is defined by the
+// FFMPEG_CONCURRENCY environment variable, and their state is kept in
+// memory, so jobs are lost when the API is restarted.
+package ffmpeg
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis"
+	"github.com/NYTimes/video-transcoding-api/probe"
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+// Name is the name used for registering the FFmpeg provider in the registry
+// of providers.
+const Name = "ffmpeg"
+
+var errFFmpegInvalidConfig = provider.InvalidConfigError("missing FFmpeg destination. Please define the environment variable FFMPEG_DESTINATION or set this value in the configuration file")
+
+func init() {
+	provider.Register(Name, ffmpegFactory)
+}
+
+type ffmpegProvider struct {
+	config *config.FFmpeg
+	db     db.Repository
+	pool   *workerPool
+}
+
+func (p *ffmpegProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
+	source, err := p.sourcePath(job.SourceMedia)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(destinationDir(provider.JobDestination(job, p.config.Destination)), job.ID)
+	state := jobState{
+		id:              job.ID,
+		source:          source,
+		dir:             dir,
+		outputs:         make([]output, len(job.Outputs)),
+		segmentDuration: job.StreamingParams.SegmentDuration,
+	}
+	var hlsOutputs, dashOutputs int
+	for i, jobOutput := range job.Outputs {
+		localPreset, err := p.db.GetLocalPreset(jobOutput.Preset.ProviderMapping[Name])
+		if err != nil {
+			return nil, fmt.Errorf("Error getting localpreset: %s", err)
+		}
+		outputPath, err := jobPath(dir, jobOutput.FileName)
+		if err != nil {
+			return nil, err
+		}
+		switch localPreset.Preset.Container {
+		case "m3u8":
+			hlsOutputs++
+		case "mpd":
+			// DASH outputs are transcoded to MP4 files, which are
+			// then packaged in the manifest of the job.
+			dashOutputs++
+			outputPath += ".mp4"
+		}
+		state.outputs[i] = output{preset: localPreset.Preset, path: outputPath, clip: job.OutputClip(jobOutput)}
+	}
+	if job.Thumbnails != nil {
+		state.thumbnails = job.Thumbnails
+		state.thumbnailsDir, err = jobPath(dir, job.Thumbnails.Path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if playlist := playlistFileName(job, "hls"); hlsOutputs > 0 && playlist != "" {
+		state.playlist, err = jobPath(dir, playlist)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dashOutputs > 0 {
+		state.manifest, err = jobPath(dir, playlistFileName(job, "dash"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = p.pool.submit(&state)
+	if err != nil {
+		return nil, err
+	}
+	return &provider.JobStatus{
+		ProviderName:  Name,
+		ProviderJobID: job.ID,
+		Status:        provider.StatusQueued,
+	}, nil
+}
+
+// playlistFileName returns the name of the playlist of the given adaptive
+// streaming protocol: the playlist of the job for its streaming protocol, or
+// a default name for the other protocol. The playlist of jobs without
+// streaming protocol is an HLS playlist, and HLS outputs of jobs without
+// playlist don't get a master playlist, while DASH outputs always get a
+// manifest, as they can't be played without it.
+func playlistFileName(job *db.Job, protocol string) string {
+	params := job.StreamingParams
+	jobProtocol := params.Protocol
+	if jobProtocol == "" {
+		jobProtocol = "hls"
+	}
+	if jobProtocol == protocol && params.PlaylistFileName != "" {
+		return params.PlaylistFileName
+	}
+	switch {
+	case protocol == "dash":
+		return "dash/index.mpd"
+	case params.Protocol == "dash":
+		return "hls/index.m3u8"
+	default:
+		return ""
+	}
+}
+
+// sourcePath returns the input used in ffmpeg for the given source media.
+// Only local files within the source root and HTTP URLs of external hosts
+// are supported.
+func (p *ffmpegProvider) sourcePath(source string) (string, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid source %q: %s", source, err)
+	}
+	switch u.Scheme {
+	case "file":
+		path, err := probe.LocalPath(p.config.SourceRoot, u.Path)
+		switch err {
+		case nil:
+			return path, nil
+		case probe.ErrUnsupportedSource:
+			return "", fmt.Errorf("unsupported source %q: file:// sources must be within the source root", source)
+		case probe.ErrSourceNotFound:
+			return "", fmt.Errorf("source %q not found", source)
+		default:
+			return "", fmt.Errorf("invalid source %q: %s", source, err)
+		}
+	case "http", "https":
+		if err := provider.CheckExternalHost(u.Hostname()); err != nil {
+			return "", fmt.Errorf("unsupported source %q: %s", source, err)
+		}
+		return source, nil
+	default:
+		return "", fmt.Errorf("unsupported source %q: only file://, http:// and https:// sources are supported", source)
+	}
+}
+
+func destinationDir(destination string) string {
+	return strings.TrimPrefix(destination, "file://")
+}
+
+// jobPath returns the path of the given file in the directory of the job,
+// making sure that it doesn't point to a file outside of the directory.
+func jobPath(dir, fileName string) (string, error) {
+	filePath := filepath.Join(dir, fileName)
+	if !strings.HasPrefix(filePath, dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid file name %q", fileName)
+	}
+	return filePath, nil
+}
+
+func (p *ffmpegProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
+	state := p.pool.get(job.ProviderJobID)
+	if state == nil {
+		return nil, provider.JobNotFoundError{ID: job.ProviderJobID}
+	}
+	return state.jobStatus(), nil
+}
+
+func (p *ffmpegProvider) CancelJob(id string) error {
+	state := p.pool.get(id)
+	if state == nil {
+		return provider.JobNotFoundError{ID: id}
+	}
+	state.cancel()
+	return nil
+}
+
+func (p *ffmpegProvider) Healthcheck() error {
+	_, err := exec.LookPath(p.config.BinaryPath)
+	if err != nil {
+		return fmt.Errorf("ffmpeg binary not found: %s", err)
+	}
+	info, err := os.Stat(destinationDir(p.config.Destination))
+	if err != nil {
+		return fmt.Errorf("invalid destination: %s", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("invalid destination: %q is not a directory", p.config.Destination)
+	}
+	return nil
+}
+
+func (p *ffmpegProvider) CreatePreset(preset db.Preset) (string, error) {
+	if _, err := buildArgs("input", "output", preset, nil, 0); err != nil {
+		return "", err
+	}
+	err := p.db.CreateLocalPreset(&db.LocalPreset{
+		Name:   preset.Name,
+		Preset: preset,
+	})
+	if err != nil {
+		return "", err
+	}
+	return preset.Name, nil
+}
+
+func (p *ffmpegProvider) UpdatePreset(presetID string, preset db.Preset) error {
+	if _, err := buildArgs("input", "output", preset, nil, 0); err != nil {
+		return err
+	}
+	return p.db.UpdateLocalPreset(&db.LocalPreset{
+		Name:   presetID,
+		Preset: preset,
+	})
+}
+
+func (p *ffmpegProvider) GetPreset(presetID string) (interface{}, error) {
+	return p.db.GetLocalPreset(presetID)
+}
+
+func (*ffmpegProvider) NormalizePreset(preset interface{}) (db.Preset, error) {
+	switch preset := preset.(type) {
+	case db.Preset:
+		return preset, nil
+	case *db.LocalPreset:
+		return preset.Preset, nil
+	default:
+		return db.Preset{}, fmt.Errorf("unexpected preset type %T", preset)
+	}
+}
+
+func (p *ffmpegProvider) DeletePreset(presetID string) error {
+	preset, err := p.db.GetLocalPreset(presetID)
+	if err != nil {
+		return err
+	}
+	return p.db.DeleteLocalPreset(preset)
+}
+
+func (p *ffmpegProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		InputFormats:  []string{"prores", "h264"},
+		OutputFormats: []string{"mp4", "hls", "dash", "webm"},
+		Destinations:  []string{"local"},
+
+		PresetConstraints: &presetConstraints,
+		Thumbnails:        &thumbnailCapabilities,
+		Clipping:          &provider.ClippingCapabilities{PerOutput: true},
+	}
+}
+
+func ffmpegFactory(cfg *config.Config) (provider.TranscodingProvider, error) {
+	if cfg.FFmpeg == nil || cfg.FFmpeg.Destination == "" {
+		return nil, errFFmpegInvalidConfig
+	}
+	dbRepo, err := redis.NewRepository(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("Error initializing ffmpeg wrapper: %s", err)
+	}
+	return &ffmpegProvider{
+		config: cfg.FFmpeg,
+		db:     dbRepo,
+		pool:   getWorkerPool(*cfg.FFmpeg),
+	}, nil
+}
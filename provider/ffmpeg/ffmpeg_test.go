@@ -0,0 +1,668 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+// fakeFFmpeg is a shell script that behaves like ffmpeg: it reports the
+// duration of the input and the progress of the output, and then writes the
+// output file (the last argument). Thumbnails are copied from the image
+// stored alongside the script, three of them when given a pattern.
+const fakeFFmpeg = `#!/bin/sh
+for last; do true; done
+case "$last" in
+*thumbnail-%05d.*)
+	for i in 1 2 3; do cp "$0.image" "$(printf "$last" $i)"; done
+	exit 0
+	;;
+*thumbnail-*)
+	cp "$0.image" "$last"
+	exit 0
+	;;
+*fail*)
+	echo "Conversion failed!" >&2
+	exit 1
+	;;
+*slow*)
+	exec sleep 10
+	;;
+esac
+echo "  Duration: 00:00:10.00, start: 0.000000, bitrate: 1000 kb/s" >&2
+echo "out_time_us=5000000"
+echo "progress=continue"
+echo "out_time_us=10000000"
+echo "progress=end"
+echo "some data" > "$last"
+`
+
+func newTestProvider(t *testing.T) (*ffmpegProvider, string) {
+	dir, err := ioutil.TempDir("", "ffmpeg-provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, "ffmpeg")
+	err = ioutil.WriteFile(binary, []byte(fakeFFmpeg), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(dir, "output")
+	err = os.Mkdir(destination, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := filepath.Join(dir, "sources")
+	err = os.Mkdir(sources, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(sources, "source.mov"), []byte("source"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.FFmpeg{BinaryPath: binary, Destination: "file://" + destination, Concurrency: 2, SourceRoot: sources}
+	repo := dbtest.NewFakeRepository(false)
+	presets := []db.Preset{
+		{
+			Name:      "mp4_1080p",
+			Container: "mp4",
+			Video:     db.VideoPreset{Width: "1920", Height: "1080", Codec: "h264", Bitrate: "3500000"},
+			Audio:     db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+		},
+		{
+			Name:      "hls_480p",
+			Container: "m3u8",
+			Video:     db.VideoPreset{Width: "854", Height: "480", Codec: "h264", Bitrate: "1000000"},
+			Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+		},
+		{
+			Name:      "dash_480p",
+			Container: "mpd",
+			Video:     db.VideoPreset{Width: "854", Height: "480", Codec: "h264", Bitrate: "1000000"},
+			Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+		},
+	}
+	for _, preset := range presets {
+		repo.CreateLocalPreset(&db.LocalPreset{Name: preset.Name, Preset: preset})
+	}
+	return &ffmpegProvider{config: &cfg, db: repo, pool: getWorkerPool(cfg)}, dir
+}
+
+func waitJob(t *testing.T, prov *ffmpegProvider, job *db.Job) *provider.JobStatus {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, err := prov.JobStatus(job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch status.Status {
+		case provider.StatusFinished, provider.StatusFailed, provider.StatusCanceled:
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %q didn't finish", job.ID)
+	return nil
+}
+
+// newTestJob returns a job transcoding the source in the directory of the
+// test provider to the given files.
+func newTestJob(dir, id string, fileNames ...string) *db.Job {
+	job := db.Job{
+		ID:              id,
+		SourceMedia:     "file://" + filepath.Join(dir, "sources", "source.mov"),
+		StreamingParams: db.StreamingParams{Protocol: "hls", PlaylistFileName: "hls/index.m3u8", SegmentDuration: 4},
+	}
+	for _, fileName := range fileNames {
+		presetName := "mp4_1080p"
+		switch filepath.Ext(fileName) {
+		case ".m3u8":
+			presetName = "hls_480p"
+		case ".mpd":
+			presetName = "dash_480p"
+		}
+		job.Outputs = append(job.Outputs, db.TranscodeOutput{
+			FileName: fileName,
+			Preset:   db.PresetMap{Name: presetName, ProviderMapping: map[string]string{Name: presetName}},
+		})
+	}
+	return &job
+}
+
+func TestFFmpegFactoryValidation(t *testing.T) {
+	prov, err := ffmpegFactory(&config.Config{FFmpeg: &config.FFmpeg{BinaryPath: "ffmpeg"}})
+	if prov != nil {
+		t.Errorf("Unexpected non-nil provider: %#v", prov)
+	}
+	if err != errFFmpegInvalidConfig {
+		t.Errorf("Wrong error returned. Want errFFmpegInvalidConfig. Got %#v", err)
+	}
+}
+
+func TestFFmpegTranscode(t *testing.T) {
+	prov, dir := newTestProvider(t)
+	defer os.RemoveAll(dir)
+	job := newTestJob(dir, "job-123", "video_1080p.mp4", "hls/video_480p.m3u8")
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &provider.JobStatus{ProviderName: Name, ProviderJobID: "job-123", Status: provider.StatusQueued}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("wrong job status returned\nwant %#v\ngot  %#v", expected, status)
+	}
+	job.ProviderJobID = status.ProviderJobID
+	status = waitJob(t, prov, job)
+	jobDir := filepath.Join(dir, "output", "job-123")
+	expected = &provider.JobStatus{
+		ProviderName:   Name,
+		ProviderJobID:  "job-123",
+		Status:         provider.StatusFinished,
+		Progress:       100,
+		SourceInfo:     provider.SourceInfo{Duration: 10 * time.Second},
+		ProviderStatus: map[string]interface{}{"sourcefile": filepath.Join(dir, "sources", "source.mov")},
+		Output: provider.JobOutput{
+			Destination: "file://" + jobDir + "/",
+			Files: []provider.OutputFile{
+				{
+					Path:       "file://" + jobDir + "/video_1080p.mp4",
+					Container:  "mp4",
+					VideoCodec: "h264",
+					Width:      1920,
+					Height:     1080,
+					FileSize:   10,
+				},
+				{
+					Path:       "file://" + jobDir + "/hls/video_480p.m3u8",
+					Container:  "m3u8",
+					VideoCodec: "h264",
+					Width:      854,
+					Height:     480,
+					FileSize:   10,
+				},
+				{
+					Path:      "file://" + jobDir + "/hls/index.m3u8",
+					Container: "m3u8",
+					FileSize:  79,
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("wrong job status returned\nwant %#v\ngot  %#v", expected, status)
+	}
+	playlist, err := ioutil.ReadFile(filepath.Join(jobDir, "hls", "index.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPlaylist := "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1064000,RESOLUTION=854x480\nvideo_480p.m3u8\n"
+	if string(playlist) != expectedPlaylist {
+		t.Errorf("wrong master playlist\nwant %q\ngot  %q", expectedPlaylist, playlist)
+	}
+}
+
+func TestFFmpegTranscodeDASH(t *testing.T) {
+	prov, dir := newTestProvider(t)
+	defer os.RemoveAll(dir)
+	job := newTestJob(dir, "job-123", "video_1080p.mp4", "dash/video_480p.mpd")
+	job.StreamingParams = db.StreamingParams{Protocol: "dash", PlaylistFileName: "dash/manifest.mpd", SegmentDuration: 4}
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.ProviderJobID = status.ProviderJobID
+	status = waitJob(t, prov, job)
+	if status.Status != provider.StatusFinished {
+		t.Fatalf("wrong job status. Want %q. Got %q (%s)", provider.StatusFinished, status.Status, status.StatusMessage)
+	}
+	jobDir := filepath.Join(dir, "output", "job-123")
+	expectedFiles := []provider.OutputFile{
+		{
+			Path:       "file://" + jobDir + "/video_1080p.mp4",
+			Container:  "mp4",
+			VideoCodec: "h264",
+			Width:      1920,
+			Height:     1080,
+			FileSize:   10,
+		},
+		{
+			Path:      "file://" + jobDir + "/dash/manifest.mpd",
+			Container: "mpd",
+			FileSize:  10,
+		},
+	}
+	if !reflect.DeepEqual(status.Output.Files, expectedFiles) {
+		t.Errorf("wrong output files\nwant %#v\ngot  %#v", expectedFiles, status.Output.Files)
+	}
+	if _, err := os.Stat(filepath.Join(jobDir, "dash", "video_480p.mpd.mp4")); !os.IsNotExist(err) {
+		t.Errorf("the intermediate MP4 file of the DASH output wasn't removed: %v", err)
+	}
+}
+
+func TestFFmpegTranscodeThumbnails(t *testing.T) {
+	var tests = []struct {
+		givenTestCase   string
+		givenThumbnails db.Thumbnails
+		wantFiles       []string
+		wantTrack       string
+	}{
+		{
+			"timecodes",
+			db.Thumbnails{Timecodes: []string{"1", "2.5"}, Format: "jpg", Path: "thumbnails"},
+			[]string{"thumbnails/thumbnail-00001.jpg", "thumbnails/thumbnail-00002.jpg"},
+			"",
+		},
+		{
+			"interval with sprite sheets",
+			db.Thumbnails{Interval: 5, Format: "png", Path: "images/thumbs", Sprite: &db.ThumbnailSprite{Columns: 2, Rows: 1}},
+			[]string{
+				"images/thumbs/thumbnail-00001.png",
+				"images/thumbs/thumbnail-00002.png",
+				"images/thumbs/thumbnail-00003.png",
+				"images/thumbs/sprite-00001.png",
+				"images/thumbs/sprite-00002.png",
+				"images/thumbs/sprite.vtt",
+			},
+			"WEBVTT\n" +
+				"\n00:00:00.000 --> 00:00:05.000\nsprite-00001.png#xywh=0,0,4,3\n" +
+				"\n00:00:05.000 --> 00:00:10.000\nsprite-00001.png#xywh=4,0,4,3\n" +
+				"\n00:00:10.000 --> 00:00:15.000\nsprite-00002.png#xywh=0,0,4,3\n",
+		},
+	}
+	for i, test := range tests {
+		prov, dir := newTestProvider(t)
+		defer os.RemoveAll(dir)
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 3)))
+		err := ioutil.WriteFile(prov.config.BinaryPath+".image", buf.Bytes(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job := newTestJob(dir, fmt.Sprintf("job-thumbnails-%d", i), "video_1080p.mp4")
+		job.Thumbnails = &test.givenThumbnails
+		status, err := prov.Transcode(job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.ProviderJobID = status.ProviderJobID
+		status = waitJob(t, prov, job)
+		if status.Status != provider.StatusFinished {
+			t.Errorf("%s: wrong status. Want %q. Got %q: %s", test.givenTestCase, provider.StatusFinished, status.Status, status.StatusMessage)
+			continue
+		}
+		jobDir := filepath.Join(dir, "output", job.ID)
+		var files []string
+		for _, file := range status.Output.Thumbnails {
+			files = append(files, strings.TrimPrefix(file.Path, "file://"+jobDir+"/"))
+			if file.FileSize == 0 {
+				t.Errorf("%s: missing size of %q", test.givenTestCase, file.Path)
+			}
+			if file.Container != "vtt" && (file.Width == 0 || file.Height != 3) {
+				t.Errorf("%s: wrong dimensions of %q: %dx%d", test.givenTestCase, file.Path, file.Width, file.Height)
+			}
+		}
+		if !reflect.DeepEqual(files, test.wantFiles) {
+			t.Errorf("%s: wrong thumbnails\nwant %q\ngot  %q", test.givenTestCase, test.wantFiles, files)
+		}
+		if test.wantTrack == "" {
+			continue
+		}
+		track, err := ioutil.ReadFile(filepath.Join(jobDir, test.givenThumbnails.Path, "sprite.vtt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(track) != test.wantTrack {
+			t.Errorf("%s: wrong WebVTT track\nwant %q\ngot  %q", test.givenTestCase, test.wantTrack, track)
+		}
+	}
+}
+
+func TestFFmpegTranscodeClip(t *testing.T) {
+	prov, dir := newTestProvider(t)
+	defer os.RemoveAll(dir)
+	job := newTestJob(dir, "job-clip", "video_1080p.mp4", "preview.mp4")
+	job.Clip = &db.Clip{Start: "2", End: "7"}
+	job.Outputs[1].Clip = &db.Clip{End: "3"}
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.ProviderJobID = status.ProviderJobID
+	prov.pool.mtx.Lock()
+	state := prov.pool.jobs[job.ProviderJobID]
+	prov.pool.mtx.Unlock()
+	wantClips := []*db.Clip{job.Clip, job.Outputs[1].Clip}
+	for i, out := range state.outputs {
+		if out.clip != wantClips[i] {
+			t.Errorf("output %d: wrong clip. Want %#v. Got %#v", i, wantClips[i], out.clip)
+		}
+	}
+	status = waitJob(t, prov, job)
+	if status.Status != provider.StatusFinished || status.Progress != 100 {
+		t.Errorf("wrong status. Want %q (100%%). Got %q (%f%%): %s", provider.StatusFinished, status.Status, status.Progress, status.StatusMessage)
+	}
+}
+
+func TestOutputDuration(t *testing.T) {
+	var tests = []struct {
+		clip *db.Clip
+		want time.Duration
+	}{
+		{nil, time.Minute},
+		{&db.Clip{Start: "10", End: "25"}, 15 * time.Second},
+		{&db.Clip{Start: "45"}, 15 * time.Second},
+		{&db.Clip{End: "20"}, 20 * time.Second},
+	}
+	for _, test := range tests {
+		out := output{clip: test.clip}
+		if got := out.duration(time.Minute); got != test.want {
+			t.Errorf("%#v: wrong duration. Want %s. Got %s", test.clip, test.want, got)
+		}
+	}
+}
+
+func TestFFmpegTranscodeFailure(t *testing.T) {
+	prov, dir := newTestProvider(t)
+	defer os.RemoveAll(dir)
+	job := newTestJob(dir, "job-fail", "video_fail.mp4")
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.ProviderJobID = status.ProviderJobID
+	status = waitJob(t, prov, job)
+	if status.Status != provider.StatusFailed {
+		t.Errorf("wrong status. Want %q. Got %q", provider.StatusFailed, status.Status)
+	}
+	if expected := "ffmpeg failed: exit status 1: Conversion failed!"; status.StatusMessage != expected {
+		t.Errorf("wrong status message. Want %q. Got %q", expected, status.StatusMessage)
+	}
+}
+
+func TestFFmpegTranscodeInvalidInput(t *testing.T) {
+	prov, dir := newTestProvider(t)
+	defer os.RemoveAll(dir)
+	var tests = []struct {
+		givenTestCase string
+		givenJob      *db.Job
+		wantErrMsg    string
+	}{
+		{
+			"unsupported source",
+			&db.Job{ID: "job-1", SourceMedia: "s3://bucket/source.mov"},
+			`unsupported source "s3://bucket/source.mov": only file://, http:// and https:// sources are supported`,
+		},
+		{
+			"file outside of the job directory",
+			newTestJob(dir, "job-2", "../job-3/video.mp4"),
+			`invalid file name "../job-3/video.mp4"`,
+		},
+		{
+			"missing preset",
+			&db.Job{
+				ID:          "job-4",
+				SourceMedia: "file://" + filepath.Join(dir, "sources", "source.mov"),
+				Outputs: []db.TranscodeOutput{
+					{FileName: "video.mp4", Preset: db.PresetMap{Name: "mp4_4k", ProviderMapping: map[string]string{Name: "mp4_4k"}}},
+				},
+			},
+			"Error getting localpreset: local preset not found",
+		},
+		{
+			"local source outside of the source root",
+			&db.Job{ID: "job-5", SourceMedia: "file://" + filepath.Join(dir, "ffmpeg")},
+			fmt.Sprintf("unsupported source %q: file:// sources must be within the source root", "file://"+filepath.Join(dir, "ffmpeg")),
+		},
+		{
+			"local source linking outside of the source root",
+			&db.Job{ID: "job-6", SourceMedia: "file://" + filepath.Join(dir, "sources", "link.mov")},
+			fmt.Sprintf("unsupported source %q: file:// sources must be within the source root", "file://"+filepath.Join(dir, "sources", "link.mov")),
+		},
+		{
+			"missing local source",
+			&db.Job{ID: "job-7", SourceMedia: "file://" + filepath.Join(dir, "sources", "missing.mov")},
+			fmt.Sprintf("source %q not found", "file://"+filepath.Join(dir, "sources", "missing.mov")),
+		},
+		{
+			"internal host",
+			&db.Job{ID: "job-8", SourceMedia: "http://127.0.0.1:8080/source.mov"},
+			`unsupported source "http://127.0.0.1:8080/source.mov": host "127.0.0.1" resolves to the internal address 127.0.0.1`,
+		},
+		{
+			"localhost",
+			&db.Job{ID: "job-9", SourceMedia: "https://localhost/source.mov"},
+			`unsupported source "https://localhost/source.mov": host "localhost" is internal`,
+		},
+	}
+	err := os.Symlink(filepath.Join(dir, "ffmpeg"), filepath.Join(dir, "sources", "link.mov"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		status, err := prov.Transcode(test.givenJob)
+		if err == nil || err.Error() != test.wantErrMsg {
+			t.Errorf("%s: wrong error returned. Want %q. Got %v", test.givenTestCase, test.wantErrMsg, err)
+		}
+		if status != nil {
+			t.Errorf("%s: unexpected non-nil status: %#v", test.givenTestCase, status)
+		}
+	}
+}
+
+func TestFFmpegCancelJob(t *testing.T) {
+	prov, dir := newTestProvider(t)
+	defer os.RemoveAll(dir)
+	job := newTestJob(dir, "job-slow", "video_slow.mp4")
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.ProviderJobID = status.ProviderJobID
+	time.Sleep(100 * time.Millisecond)
+	err = prov.CancelJob(job.ProviderJobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	status = waitJob(t, prov, job)
+	if status.Status != provider.StatusCanceled {
+		t.Errorf("wrong status. Want %q. Got %q", provider.StatusCanceled, status.Status)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("ffmpeg was not killed, job took %s to be canceled", elapsed)
+	}
+	err = prov.CancelJob("some-job")
+	if _, ok := err.(provider.JobNotFoundError); !ok {
+		t.Errorf("wrong error returned. Want JobNotFoundError. Got %#v", err)
+	}
+}
+
+func TestFFmpegJobStatusNotFound(t *testing.T) {
+	prov, dir := newTestProvider(t)
+	defer os.RemoveAll(dir)
+	status, err := prov.JobStatus(&db.Job{ID: "job-unknown", ProviderJobID: "job-unknown"})
+	if _, ok := err.(provider.JobNotFoundError); !ok {
+		t.Errorf("wrong error returned. Want JobNotFoundError. Got %#v", err)
+	}
+	if status != nil {
+		t.Errorf("unexpected non-nil status: %#v", status)
+	}
+}
+
+func TestFFmpegPrunesFinishedJobs(t *testing.T) {
+	prov, dir := newTestProvider(t)
+	defer os.RemoveAll(dir)
+	defer func(ttl time.Duration) {
+		finishedJobTTL = ttl
+	}(finishedJobTTL)
+	finishedJobTTL = 50 * time.Millisecond
+	job := newTestJob(dir, "job-pruned", "video_1080p.mp4")
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.ProviderJobID = status.ProviderJobID
+	waitJob(t, prov, job)
+	if _, err = prov.JobStatus(job); err != nil {
+		t.Fatalf("finished job pruned before its ttl: %s", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	_, err = prov.Transcode(newTestJob(dir, "job-after-pruning", "video_1080p.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = prov.JobStatus(job)
+	if _, ok := err.(provider.JobNotFoundError); !ok {
+		t.Errorf("wrong error returned for pruned job. Want JobNotFoundError. Got %#v", err)
+	}
+}
+
+func TestFFmpegUpdatePreset(t *testing.T) {
+	prov, dir := newTestProvider(t)
+	defer os.RemoveAll(dir)
+	preset := db.Preset{
+		Name:      "mp4_1080p",
+		Container: "mp4",
+		Video:     db.VideoPreset{Width: "1920", Height: "1080", Codec: "hevc", Bitrate: "2500000"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+	}
+	err := prov.UpdatePreset("mp4_1080p", preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localPreset, err := prov.db.GetLocalPreset("mp4_1080p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(localPreset.Preset, preset) {
+		t.Errorf("preset not updated\nwant %#v\ngot  %#v", preset, localPreset.Preset)
+	}
+	err = prov.UpdatePreset("mp4_720p", preset)
+	if err != db.ErrLocalPresetNotFound {
+		t.Errorf("wrong error returned. Want ErrLocalPresetNotFound. Got %#v", err)
+	}
+	preset.Video.Codec = "mpeg2"
+	err = prov.UpdatePreset("mp4_1080p", preset)
+	if err == nil {
+		t.Error("unexpected <nil> error when updating the preset with an unsupported codec")
+	}
+}
+
+func TestFFmpegNormalizePreset(t *testing.T) {
+	prov, dir := newTestProvider(t)
+	defer os.RemoveAll(dir)
+	localPreset, err := prov.GetPreset("mp4_1080p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized, err := prov.NormalizePreset(localPreset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(normalized, localPreset.(*db.LocalPreset).Preset) {
+		t.Errorf("wrong normalized preset\nwant %#v\ngot  %#v", localPreset.(*db.LocalPreset).Preset, normalized)
+	}
+	_, err = prov.NormalizePreset("mp4_1080p")
+	if err == nil {
+		t.Error("unexpected <nil> error for invalid preset type")
+	}
+}
+
+func TestFFmpegHealthcheck(t *testing.T) {
+	prov, dir := newTestProvider(t)
+	defer os.RemoveAll(dir)
+	if err := prov.Healthcheck(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	prov.config = &config.FFmpeg{BinaryPath: prov.config.BinaryPath, Destination: filepath.Join(dir, "missing")}
+	if err := prov.Healthcheck(); err == nil {
+		t.Error("unexpected <nil> error for missing destination")
+	}
+	prov.config = &config.FFmpeg{BinaryPath: filepath.Join(dir, "missing-ffmpeg"), Destination: dir}
+	if err := prov.Healthcheck(); err == nil {
+		t.Error("unexpected <nil> error for missing binary")
+	}
+}
+
+func TestFFmpegCapabilities(t *testing.T) {
+	var prov ffmpegProvider
+	expected := provider.Capabilities{
+		InputFormats:  []string{"prores", "h264"},
+		OutputFormats: []string{"mp4", "hls", "dash", "webm"},
+		Destinations:  []string{"local"},
+
+		Thumbnails: &provider.ThumbnailCapabilities{
+			Formats:   []string{"jpg", "png"},
+			Timecodes: true,
+			Sprites:   true,
+		},
+		Clipping: &provider.ClippingCapabilities{PerOutput: true},
+		PresetConstraints: &provider.PresetConstraints{
+			AudioCodecs: []string{"aac", "mp3", "opus", "vorbis"},
+			VideoCodecs: map[string][]string{
+				"h264": {"crf", "maxBitrate", "bufferSize", "bFrames", "referenceFrames", "frameRate"},
+				"hevc": {"tier", "bitDepth", "crf", "maxBitrate", "bufferSize", "bFrames", "referenceFrames", "frameRate"},
+				"vp8":  {"crf", "maxBitrate", "bufferSize", "frameRate"},
+				"vp9":  {"bitDepth", "crf", "maxBitrate", "bufferSize", "frameRate"},
+				"av1":  {"bitDepth", "crf", "maxBitrate", "bufferSize", "frameRate"},
+			},
+			AudioParameters: []string{"channels", "channelLayout", "sampleRate", "language", "loudness", "audioTracks", "audioOnly"},
+		},
+	}
+	cap := prov.Capabilities()
+	if !reflect.DeepEqual(cap, expected) {
+		t.Errorf("Capabilities: want %#v. Got %#v", expected, cap)
+	}
+}
+
+func TestWriteMasterPlaylistAlternateAudio(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ffmpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outputs := []output{
+		{
+			preset: db.Preset{Name: "hls_480p", Container: "m3u8", Video: db.VideoPreset{Codec: "h264", Bitrate: "1000000", Width: "854", Height: "480"}},
+			path:   filepath.Join(dir, "hls", "video_480p.m3u8"),
+		},
+		{
+			preset: db.Preset{Name: "hls_audio_en", Container: "m3u8", Audio: db.AudioPreset{Codec: "aac", Bitrate: "64000", Language: "en"}},
+			path:   filepath.Join(dir, "hls", "audio_en.m3u8"),
+		},
+		{
+			preset: db.Preset{Name: "hls_audio_es", Container: "m3u8", Audio: db.AudioPreset{Codec: "aac", Bitrate: "96000", Language: "es"}},
+			path:   filepath.Join(dir, "hls", "audio_es.m3u8"),
+		},
+	}
+	playlist := filepath.Join(dir, "hls", "index.m3u8")
+	if _, err = writeMasterPlaylist(playlist, outputs); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(playlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "#EXTM3U\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",LANGUAGE="en",NAME="en",DEFAULT=YES,AUTOSELECT=YES,URI="audio_en.m3u8"` + "\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",LANGUAGE="es",NAME="es",AUTOSELECT=YES,URI="audio_es.m3u8"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=1096000,RESOLUTION=854x480,AUDIO="audio"` + "\n" +
+		"video_480p.m3u8\n"
+	if string(content) != expected {
+		t.Errorf("wrong master playlist\nwant %q\ngot  %q", expected, content)
+	}
+}
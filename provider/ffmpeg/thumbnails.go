@@ -0,0 +1,192 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+// thumbnailCapabilities describes the thumbnails generated by ffmpeg. Sprite
+// sheets and their WebVTT tracks are generated by the provider, combining
+// the thumbnails extracted by ffmpeg.
+var thumbnailCapabilities = provider.ThumbnailCapabilities{
+	Formats:   []string{"jpg", "png"},
+	Timecodes: true,
+	Sprites:   true,
+}
+
+// generateThumbnails extracts the thumbnails of the job, generating the
+// sprite sheets when requested, and returns the generated files.
+func (p *workerPool) generateThumbnails(j *jobState) ([]provider.OutputFile, error) {
+	thumbnails := j.thumbnails
+	err := os.MkdirAll(j.thumbnailsDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	pattern := filepath.Join(j.thumbnailsDir, "thumbnail-%05d."+thumbnails.Format)
+	noProgress := func(time.Duration) {}
+	if len(thumbnails.Timecodes) > 0 {
+		for i, timecode := range thumbnails.Timecodes {
+			args := buildThumbnailArgs(j.source, fmt.Sprintf(pattern, i+1), thumbnails, timecode)
+			if err = p.runFFmpeg(j, args, noProgress); err != nil {
+				return nil, err
+			}
+		}
+	} else if err = p.runFFmpeg(j, buildThumbnailArgs(j.source, pattern, thumbnails, ""), noProgress); err != nil {
+		return nil, err
+	}
+	// thumbnails are numbered with leading zeros, so the list is sorted.
+	paths, err := filepath.Glob(filepath.Join(j.thumbnailsDir, "thumbnail-*."+thumbnails.Format))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]provider.OutputFile, 0, len(paths))
+	for _, path := range paths {
+		file, err := imageFile(path, thumbnails.Format)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	if thumbnails.Sprite != nil && len(paths) > 0 {
+		sprites, err := writeSprites(j.thumbnailsDir, paths, thumbnails)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, sprites...)
+	}
+	return files, nil
+}
+
+// writeSprites combines the given thumbnails in sprite sheets, using the
+// size of the first thumbnail for all of them, and writes the WebVTT track
+// that maps each interval of the media to its thumbnail in the sheets.
+func writeSprites(dir string, paths []string, thumbnails *db.Thumbnails) ([]provider.OutputFile, error) {
+	columns := int(thumbnails.Sprite.Columns)
+	perSheet := columns * int(thumbnails.Sprite.Rows)
+	var width, height int
+	var files []provider.OutputFile
+	vtt := "WEBVTT\n"
+	for start := 0; start < len(paths); start += perSheet {
+		end := start + perSheet
+		if end > len(paths) {
+			end = len(paths)
+		}
+		var sheet *image.RGBA
+		sheetName := fmt.Sprintf("sprite-%05d.%s", start/perSheet+1, thumbnails.Format)
+		for i, path := range paths[start:end] {
+			img, err := decodeImage(path)
+			if err != nil {
+				return nil, err
+			}
+			if width == 0 {
+				width, height = img.Bounds().Dx(), img.Bounds().Dy()
+			}
+			if sheet == nil {
+				rows := (end - start + columns - 1) / columns
+				sheetColumns := columns
+				if end-start < columns {
+					sheetColumns = end - start
+				}
+				sheet = image.NewRGBA(image.Rect(0, 0, sheetColumns*width, rows*height))
+			}
+			x, y := (i%columns)*width, (i/columns)*height
+			draw.Draw(sheet, image.Rect(x, y, x+width, y+height), img, img.Bounds().Min, draw.Src)
+			cueStart := uint(start+i) * thumbnails.Interval
+			vtt += fmt.Sprintf("\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+				vttTimestamp(cueStart), vttTimestamp(cueStart+thumbnails.Interval), sheetName, x, y, width, height)
+		}
+		file, err := writeImage(filepath.Join(dir, sheetName), sheet, thumbnails.Format)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	trackPath := filepath.Join(dir, "sprite.vtt")
+	err := ioutil.WriteFile(trackPath, []byte(vtt), 0644)
+	if err != nil {
+		return nil, err
+	}
+	return append(files, provider.OutputFile{
+		Path:      fileURL(trackPath),
+		Container: "vtt",
+		FileSize:  int64(len(vtt)),
+	}), nil
+}
+
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid thumbnail %q: %s", path, err)
+	}
+	return img, nil
+}
+
+func writeImage(path string, img image.Image, format string) (provider.OutputFile, error) {
+	var buf bytes.Buffer
+	var err error
+	if format == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	}
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	err = ioutil.WriteFile(path, buf.Bytes(), 0644)
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	return provider.OutputFile{
+		Path:      fileURL(path),
+		Container: format,
+		Width:     int64(img.Bounds().Dx()),
+		Height:    int64(img.Bounds().Dy()),
+		FileSize:  int64(buf.Len()),
+	}, nil
+}
+
+// imageFile returns the description of the given image, reading its
+// dimensions from the file.
+func imageFile(path, format string) (provider.OutputFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return provider.OutputFile{}, fmt.Errorf("invalid thumbnail %q: %s", path, err)
+	}
+	return provider.OutputFile{
+		Path:      fileURL(path),
+		Container: format,
+		Width:     int64(cfg.Width),
+		Height:    int64(cfg.Height),
+		FileSize:  info.Size(),
+	}, nil
+}
+
+// vttTimestamp formats the given number of seconds as a WebVTT timestamp.
+func vttTimestamp(seconds uint) string {
+	return fmt.Sprintf("%02d:%02d:%02d.000", seconds/3600, seconds/60%60, seconds%60)
+}
@@ -0,0 +1,530 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+var (
+	durationRegexp = regexp.MustCompile(`Duration: (\d+):(\d{2}):(\d{2}(?:\.\d+)?)`)
+	errCanceled    = errors.New("job canceled")
+
+	poolsMtx sync.Mutex
+	pools    = make(map[config.FFmpeg]*workerPool)
+
+	// finishedJobTTL is how long the state of jobs is kept in the worker
+	// pool after they're done, giving the API enough time to read their
+	// final status.
+	finishedJobTTL = 24 * time.Hour
+)
+
+// output is an output of a job, along with the preset used for generating it.
+type output struct {
+	preset db.Preset
+	path   string
+	clip   *db.Clip
+}
+
+// jobState keeps the state of a job submitted to the worker pool.
+type jobState struct {
+	id              string
+	source          string
+	dir             string
+	outputs         []output
+	playlist        string
+	manifest        string
+	segmentDuration uint
+
+	// thumbnails of the job and the directory they're written to
+	thumbnails    *db.Thumbnails
+	thumbnailsDir string
+
+	mtx      sync.Mutex
+	status   provider.Status
+	message  string
+	progress float64
+	duration time.Duration
+	files    []provider.OutputFile
+	images   []provider.OutputFile
+	cmd      *exec.Cmd
+
+	// time when the job reached a terminal status
+	doneTime time.Time
+}
+
+func (j *jobState) jobStatus() *provider.JobStatus {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	files := make([]provider.OutputFile, len(j.files))
+	copy(files, j.files)
+	var images []provider.OutputFile
+	if len(j.images) > 0 {
+		images = make([]provider.OutputFile, len(j.images))
+		copy(images, j.images)
+	}
+	return &provider.JobStatus{
+		ProviderName:  Name,
+		ProviderJobID: j.id,
+		Status:        j.status,
+		StatusMessage: j.message,
+		Progress:      j.progress,
+		SourceInfo:    provider.SourceInfo{Duration: j.duration},
+		Output: provider.JobOutput{
+			Destination: fileURL(j.dir) + "/",
+			Files:       files,
+			Thumbnails:  images,
+		},
+		ProviderStatus: map[string]interface{}{"sourcefile": j.source},
+	}
+}
+
+// setStatus changes the status of the job, unless it has been canceled.
+func (j *jobState) setStatus(status provider.Status, message string) bool {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	if j.status == provider.StatusCanceled {
+		return false
+	}
+	j.status = status
+	j.message = message
+	if status == provider.StatusFinished {
+		j.progress = 100
+	}
+	if status == provider.StatusFinished || status == provider.StatusFailed {
+		j.doneTime = time.Now()
+	}
+	return true
+}
+
+// startCmd starts the given ffmpeg process, unless the job has been
+// canceled, keeping track of it so it can be killed when the job is canceled.
+func (j *jobState) startCmd(cmd *exec.Cmd) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	if j.status == provider.StatusCanceled {
+		return errCanceled
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %s", err)
+	}
+	j.cmd = cmd
+	return nil
+}
+
+func (j *jobState) cancel() {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	if j.status == provider.StatusFinished || j.status == provider.StatusFailed {
+		return
+	}
+	j.status = provider.StatusCanceled
+	j.message = ""
+	j.doneTime = time.Now()
+	if j.cmd != nil && j.cmd.Process != nil {
+		j.cmd.Process.Kill()
+	}
+}
+
+// expired checks whether the job has been done for longer than
+// finishedJobTTL at the given time.
+func (j *jobState) expired(now time.Time) bool {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	return !j.doneTime.IsZero() && now.Sub(j.doneTime) > finishedJobTTL
+}
+
+// workerPool runs ffmpeg for the jobs submitted to it, limiting the number
+// of processes running concurrently.
+type workerPool struct {
+	cfg config.FFmpeg
+
+	mtx     sync.Mutex
+	cond    *sync.Cond
+	pending []*jobState
+	jobs    map[string]*jobState
+}
+
+// getWorkerPool returns the worker pool for the given configuration, starting
+// it if needed. Providers are created on every request, so pools are shared.
+func getWorkerPool(cfg config.FFmpeg) *workerPool {
+	poolsMtx.Lock()
+	defer poolsMtx.Unlock()
+	if pool, ok := pools[cfg]; ok {
+		return pool
+	}
+	pool := &workerPool{cfg: cfg, jobs: make(map[string]*jobState)}
+	pool.cond = sync.NewCond(&pool.mtx)
+	workers := int(cfg.Concurrency)
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go pool.work()
+	}
+	pools[cfg] = pool
+	return pool
+}
+
+func (p *workerPool) submit(j *jobState) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.prune(time.Now())
+	if _, ok := p.jobs[j.id]; ok {
+		return fmt.Errorf("job %q already exists", j.id)
+	}
+	j.status = provider.StatusQueued
+	p.jobs[j.id] = j
+	p.pending = append(p.pending, j)
+	p.cond.Signal()
+	return nil
+}
+
+// prune removes the jobs that expired at the given time, so the pool doesn't
+// keep the state of every job it ever ran. The caller must hold p.mtx.
+func (p *workerPool) prune(now time.Time) {
+	for id, j := range p.jobs {
+		if j.expired(now) {
+			delete(p.jobs, id)
+		}
+	}
+}
+
+func (p *workerPool) get(id string) *jobState {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.jobs[id]
+}
+
+func (p *workerPool) work() {
+	for {
+		p.mtx.Lock()
+		for len(p.pending) == 0 {
+			p.cond.Wait()
+		}
+		j := p.pending[0]
+		p.pending = p.pending[1:]
+		p.mtx.Unlock()
+		p.run(j)
+	}
+}
+
+func (p *workerPool) run(j *jobState) {
+	if !j.setStatus(provider.StatusStarted, "") {
+		return
+	}
+	for i := range j.outputs {
+		file, err := p.transcode(j, i)
+		if err != nil {
+			if err != errCanceled {
+				j.setStatus(provider.StatusFailed, err.Error())
+			}
+			return
+		}
+		if j.outputs[i].preset.Container == "mpd" {
+			continue
+		}
+		j.mtx.Lock()
+		j.files = append(j.files, file)
+		j.mtx.Unlock()
+	}
+	if j.playlist != "" {
+		file, err := writeMasterPlaylist(j.playlist, j.outputs)
+		if err != nil {
+			j.setStatus(provider.StatusFailed, err.Error())
+			return
+		}
+		j.mtx.Lock()
+		j.files = append(j.files, file)
+		j.mtx.Unlock()
+	}
+	if j.manifest != "" {
+		file, err := p.packageDASH(j)
+		if err != nil {
+			if err != errCanceled {
+				j.setStatus(provider.StatusFailed, err.Error())
+			}
+			return
+		}
+		j.mtx.Lock()
+		j.files = append(j.files, file)
+		j.mtx.Unlock()
+	}
+	if j.thumbnails != nil {
+		images, err := p.generateThumbnails(j)
+		if err != nil {
+			if err != errCanceled {
+				j.setStatus(provider.StatusFailed, err.Error())
+			}
+			return
+		}
+		j.mtx.Lock()
+		j.images = images
+		j.mtx.Unlock()
+	}
+	j.setStatus(provider.StatusFinished, "")
+}
+
+// transcode runs ffmpeg for the given output of the job, updating the
+// progress of the job as reported by ffmpeg.
+func (p *workerPool) transcode(j *jobState, index int) (provider.OutputFile, error) {
+	out := j.outputs[index]
+	args, err := buildArgs(j.source, out.path, out.preset, out.clip, j.segmentDuration)
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	err = os.MkdirAll(filepath.Dir(out.path), 0755)
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	err = p.runFFmpeg(j, args, func(outTime time.Duration) {
+		j.mtx.Lock()
+		defer j.mtx.Unlock()
+		if duration := out.duration(j.duration); duration > 0 {
+			done := float64(outTime) / float64(duration)
+			if done > 1 {
+				done = 1
+			}
+			j.progress = (float64(index) + done) * 100 / float64(len(j.outputs))
+		}
+	})
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	return outputFile(out)
+}
+
+// packageDASH packages the DASH outputs of the job, transcoded to MP4 files,
+// in the DASH manifest of the job, removing the MP4 files afterwards.
+func (p *workerPool) packageDASH(j *jobState) (provider.OutputFile, error) {
+	var outputs []output
+	for _, out := range j.outputs {
+		if out.preset.Container == "mpd" {
+			outputs = append(outputs, out)
+		}
+	}
+	err := os.MkdirAll(filepath.Dir(j.manifest), 0755)
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	err = p.runFFmpeg(j, buildDASHArgs(j.manifest, outputs, j.segmentDuration), func(time.Duration) {})
+	for _, out := range outputs {
+		os.Remove(out.path)
+	}
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	info, err := os.Stat(j.manifest)
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	return provider.OutputFile{
+		Path:      fileURL(j.manifest),
+		Container: "mpd",
+		FileSize:  info.Size(),
+	}, nil
+}
+
+// runFFmpeg runs ffmpeg with the given arguments, calling onProgress with the
+// position of the output as reported by ffmpeg.
+func (p *workerPool) runFFmpeg(j *jobState, args []string, onProgress func(time.Duration)) error {
+	cmd := exec.Command(p.cfg.BinaryPath, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err = j.startCmd(cmd); err != nil {
+		return err
+	}
+	var lastLine string
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		lastLine = readStderr(stderr, func(duration time.Duration) {
+			j.mtx.Lock()
+			j.duration = duration
+			j.mtx.Unlock()
+		})
+	}()
+	readProgress(stdout, onProgress)
+	wg.Wait()
+	err = cmd.Wait()
+	j.mtx.Lock()
+	j.cmd = nil
+	canceled := j.status == provider.StatusCanceled
+	j.mtx.Unlock()
+	if err != nil {
+		if canceled {
+			return errCanceled
+		}
+		return fmt.Errorf("ffmpeg failed: %s: %s", err, lastLine)
+	}
+	return nil
+}
+
+// readProgress parses the progress reported by ffmpeg through the -progress
+// option, calling fn with the position of the output.
+func readProgress(r io.Reader, fn func(time.Duration)) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		// despite the name, out_time_ms is reported in microseconds.
+		switch parts[0] {
+		case "out_time_us", "out_time_ms":
+			if us, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+				fn(time.Duration(us) * time.Microsecond)
+			}
+		}
+	}
+	io.Copy(ioutil.Discard, r)
+}
+
+// readStderr reads the log of ffmpeg, calling fn with the duration of the
+// input media, and returns the last line of the log.
+func readStderr(r io.Reader, fn func(time.Duration)) string {
+	var lastLine string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		lastLine = line
+		if parts := durationRegexp.FindStringSubmatch(line); len(parts) > 0 {
+			hours, _ := strconv.ParseInt(parts[1], 10, 64)
+			minutes, _ := strconv.ParseInt(parts[2], 10, 64)
+			seconds, _ := strconv.ParseFloat(parts[3], 64)
+			fn(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second)))
+		}
+	}
+	io.Copy(ioutil.Discard, r)
+	return lastLine
+}
+
+// duration returns the expected duration of the output, given the duration of
+// the source reported by ffmpeg, taking the clip of the output into account.
+func (out output) duration(source time.Duration) time.Duration {
+	if out.clip == nil {
+		return source
+	}
+	if d := out.clip.Duration(); d > 0 {
+		return d
+	}
+	return source - out.clip.StartTime()
+}
+
+func outputFile(out output) (provider.OutputFile, error) {
+	info, err := os.Stat(out.path)
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	width, _ := parseDimension(out.preset.Video.Width)
+	height, _ := parseDimension(out.preset.Video.Height)
+	return provider.OutputFile{
+		Path:       fileURL(out.path),
+		Container:  out.preset.Container,
+		VideoCodec: out.preset.Video.Codec,
+		Width:      width,
+		Height:     height,
+		FileSize:   info.Size(),
+	}, nil
+}
+
+// writeMasterPlaylist writes the HLS master playlist, referencing the
+// playlists of all HLS outputs. When the job has both video and audio-only
+// HLS outputs, the audio-only outputs are listed as an alternate audio group
+// of the video streams.
+func writeMasterPlaylist(playlist string, outputs []output) (provider.OutputFile, error) {
+	var videoOutputs, audioOutputs []output
+	for _, out := range outputs {
+		if out.preset.Container != "m3u8" {
+			continue
+		}
+		if out.preset.AudioOnly() {
+			audioOutputs = append(audioOutputs, out)
+		} else {
+			videoOutputs = append(videoOutputs, out)
+		}
+	}
+	var audioGroup string
+	var audioGroupBitrate int64
+	if len(videoOutputs) == 0 {
+		videoOutputs, audioOutputs = audioOutputs, nil
+	}
+	for i, out := range audioOutputs {
+		uri, err := filepath.Rel(filepath.Dir(playlist), out.path)
+		if err != nil {
+			return provider.OutputFile{}, err
+		}
+		audio := out.preset.Audio
+		media := `TYPE=AUDIO,GROUP-ID="audio"`
+		if audio.Language != "" {
+			media += `,LANGUAGE="` + audio.Language + `",NAME="` + audio.Language + `"`
+		} else {
+			media += `,NAME="` + out.preset.Name + `"`
+		}
+		if i == 0 {
+			media += ",DEFAULT=YES"
+		}
+		audioGroup += "#EXT-X-MEDIA:" + media + `,AUTOSELECT=YES,URI="` + filepath.ToSlash(uri) + "\"\n"
+		if bitrate, _ := strconv.ParseInt(audio.Bitrate, 10, 64); bitrate > audioGroupBitrate {
+			audioGroupBitrate = bitrate
+		}
+	}
+	content := "#EXTM3U\n" + audioGroup
+	for _, out := range videoOutputs {
+		videoBitrate, _ := strconv.ParseInt(out.preset.Video.Bitrate, 10, 64)
+		audioBitrate, _ := strconv.ParseInt(out.preset.Audio.Bitrate, 10, 64)
+		streamInfo := "BANDWIDTH=" + strconv.FormatInt(videoBitrate+audioBitrate+audioGroupBitrate, 10)
+		if out.preset.Video.Width != "" && out.preset.Video.Height != "" {
+			streamInfo += ",RESOLUTION=" + out.preset.Video.Width + "x" + out.preset.Video.Height
+		}
+		if audioGroup != "" {
+			streamInfo += `,AUDIO="audio"`
+		}
+		uri, err := filepath.Rel(filepath.Dir(playlist), out.path)
+		if err != nil {
+			return provider.OutputFile{}, err
+		}
+		content += "#EXT-X-STREAM-INF:" + streamInfo + "\n" + filepath.ToSlash(uri) + "\n"
+	}
+	err := os.MkdirAll(filepath.Dir(playlist), 0755)
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	err = ioutil.WriteFile(playlist, []byte(content), 0644)
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	return provider.OutputFile{
+		Path:      fileURL(playlist),
+		Container: "m3u8",
+		FileSize:  int64(len(content)),
+	}, nil
+}
+
+func fileURL(path string) string {
+	return "file://" + filepath.ToSlash(path)
+}
@@ -0,0 +1,281 @@
+package gcptranscoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// jobTemplate is a job template of the Transcoder API, holding the
+// configuration generated from a preset.
+type jobTemplate struct {
+	Name   string     `json:"name,omitempty"`
+	Config *jobConfig `json:"config"`
+}
+
+// job is a job of the Transcoder API.
+type job struct {
+	Name      string     `json:"name,omitempty"`
+	InputURI  string     `json:"inputUri"`
+	OutputURI string     `json:"outputUri"`
+	Config    *jobConfig `json:"config,omitempty"`
+	State     string     `json:"state,omitempty"`
+	StartTime string     `json:"startTime,omitempty"`
+	EndTime   string     `json:"endTime,omitempty"`
+	Error     *apiError  `json:"error,omitempty"`
+}
+
+type jobConfig struct {
+	Inputs            []input            `json:"inputs,omitempty"`
+	EditList          []editAtom         `json:"editList,omitempty"`
+	ElementaryStreams []elementaryStream `json:"elementaryStreams,omitempty"`
+	MuxStreams        []muxStream        `json:"muxStreams,omitempty"`
+	Manifests         []manifest         `json:"manifests,omitempty"`
+	SpriteSheets      []spriteSheet      `json:"spriteSheets,omitempty"`
+}
+
+type input struct {
+	Key string `json:"key"`
+	URI string `json:"uri,omitempty"`
+}
+
+// editAtom is an entry of the edit list of a job, selecting a segment of the
+// given inputs. Offsets are durations, like "12.5s".
+type editAtom struct {
+	Key             string   `json:"key"`
+	Inputs          []string `json:"inputs"`
+	StartTimeOffset string   `json:"startTimeOffset,omitempty"`
+	EndTimeOffset   string   `json:"endTimeOffset,omitempty"`
+}
+
+type elementaryStream struct {
+	Key         string       `json:"key"`
+	VideoStream *videoStream `json:"videoStream,omitempty"`
+	AudioStream *audioStream `json:"audioStream,omitempty"`
+}
+
+type videoStream struct {
+	H264 *videoSettings `json:"h264,omitempty"`
+	H265 *videoSettings `json:"h265,omitempty"`
+	VP9  *videoSettings `json:"vp9,omitempty"`
+}
+
+// videoSettings holds the settings of a video codec.
+type videoSettings struct {
+	WidthPixels     int64   `json:"widthPixels,omitempty"`
+	HeightPixels    int64   `json:"heightPixels,omitempty"`
+	FrameRate       float64 `json:"frameRate"`
+	BitrateBps      int64   `json:"bitrateBps"`
+	RateControlMode string  `json:"rateControlMode,omitempty"`
+	VbvSizeBits     int64   `json:"vbvSizeBits,omitempty"`
+	GopFrameCount   int64   `json:"gopFrameCount,omitempty"`
+	Profile         string  `json:"profile,omitempty"`
+	PixelFormat     string  `json:"pixelFormat,omitempty"`
+	CrfLevel        int64   `json:"crfLevel,omitempty"`
+	BFrameCount     int64   `json:"bFrameCount,omitempty"`
+	EnableTwoPass   bool    `json:"enableTwoPass,omitempty"`
+}
+
+// settings returns the settings of the codec of the stream, or nil for
+// non-video streams.
+func (s *videoStream) settings() *videoSettings {
+	switch {
+	case s == nil:
+		return nil
+	case s.H265 != nil:
+		return s.H265
+	case s.VP9 != nil:
+		return s.VP9
+	default:
+		return s.H264
+	}
+}
+
+type audioStream struct {
+	Codec           string `json:"codec"`
+	BitrateBps      int64  `json:"bitrateBps"`
+	ChannelCount    int64  `json:"channelCount,omitempty"`
+	SampleRateHertz int64  `json:"sampleRateHertz,omitempty"`
+	LanguageCode    string `json:"languageCode,omitempty"`
+}
+
+type muxStream struct {
+	Key               string           `json:"key"`
+	FileName          string           `json:"fileName,omitempty"`
+	Container         string           `json:"container"`
+	ElementaryStreams []string         `json:"elementaryStreams"`
+	SegmentSettings   *segmentSettings `json:"segmentSettings,omitempty"`
+}
+
+type segmentSettings struct {
+	SegmentDuration string `json:"segmentDuration,omitempty"`
+}
+
+type manifest struct {
+	FileName   string   `json:"fileName"`
+	Type       string   `json:"type"`
+	MuxStreams []string `json:"muxStreams"`
+}
+
+// spriteSheet generates images from the video of the job, combining them in
+// sheets of the given number of columns and rows.
+type spriteSheet struct {
+	Format             string `json:"format,omitempty"`
+	FilePrefix         string `json:"filePrefix"`
+	SpriteWidthPixels  int64  `json:"spriteWidthPixels,omitempty"`
+	SpriteHeightPixels int64  `json:"spriteHeightPixels,omitempty"`
+	ColumnCount        int64  `json:"columnCount,omitempty"`
+	RowCount           int64  `json:"rowCount,omitempty"`
+	Interval           string `json:"interval,omitempty"`
+}
+
+// storageObject is an object of Google Cloud Storage, as listed by the JSON
+// API of Cloud Storage.
+type storageObject struct {
+	Name string `json:"name"`
+	Size string `json:"size"`
+}
+
+// apiError is the error returned by the Transcoder API.
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status,omitempty"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("Transcoder API error (%d): %s", e.Code, e.Message)
+}
+
+// client is a minimal client for the REST API of the Transcoder API, scoped
+// to a project and location. It also lists the files generated by jobs,
+// using the JSON API of Cloud Storage.
+type client struct {
+	httpClient      *http.Client
+	endpoint        string
+	storageEndpoint string
+	parent          string
+}
+
+func (c *client) createJobTemplate(id string, template *jobTemplate) (*jobTemplate, error) {
+	var result jobTemplate
+	err := c.do(http.MethodPost, "jobTemplates?jobTemplateId="+url.QueryEscape(id), template, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *client) getJobTemplate(id string) (*jobTemplate, error) {
+	var result jobTemplate
+	err := c.do(http.MethodGet, "jobTemplates/"+id, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *client) deleteJobTemplate(id string) error {
+	return c.do(http.MethodDelete, "jobTemplates/"+id, nil, nil)
+}
+
+func (c *client) listJobTemplates() error {
+	return c.do(http.MethodGet, "jobTemplates?pageSize=1", nil, nil)
+}
+
+func (c *client) createJob(j *job) (*job, error) {
+	var result job
+	err := c.do(http.MethodPost, "jobs", j, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *client) getJob(id string) (*job, error) {
+	var result job
+	err := c.do(http.MethodGet, "jobs/"+id, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *client) deleteJob(id string) error {
+	return c.do(http.MethodDelete, "jobs/"+id, nil, nil)
+}
+
+// listObjects returns the objects of the given bucket whose names start with
+// the given prefix.
+func (c *client) listObjects(bucket, prefix string) ([]storageObject, error) {
+	var objects []storageObject
+	var pageToken string
+	for {
+		query := url.Values{"prefix": {prefix}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		var page struct {
+			Items         []storageObject `json:"items"`
+			NextPageToken string          `json:"nextPageToken"`
+		}
+		reqURL := strings.TrimRight(c.storageEndpoint, "/") + "/b/" + url.QueryEscape(bucket) + "/o?" + query.Encode()
+		if err := c.send(http.MethodGet, reqURL, nil, &page); err != nil {
+			return nil, err
+		}
+		objects = append(objects, page.Items...)
+		if page.NextPageToken == "" {
+			return objects, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+func (c *client) do(method, path string, body, result interface{}) error {
+	return c.send(method, strings.TrimRight(c.endpoint, "/")+"/"+c.parent+"/"+path, body, result)
+}
+
+func (c *client) send(method, reqURL string, body, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, reqURL, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		var errResp struct {
+			Error *apiError `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) != nil || errResp.Error == nil {
+			return &apiError{Code: resp.StatusCode, Message: resp.Status}
+		}
+		return errResp.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.Code == http.StatusNotFound
+}
@@ -0,0 +1,165 @@
+package gcptranscoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const testParent = "projects/my-project/locations/us-central1"
+
+// transcoderServer is a fake implementation of the REST API of the
+// Transcoder API, supporting the operations used by the provider. It also
+// serves the listing of objects from the JSON API of Cloud Storage.
+type transcoderServer struct {
+	*httptest.Server
+
+	mtx       sync.Mutex
+	templates map[string]*jobTemplate
+	jobs      map[string]*job
+	createdID int
+
+	// objects maps Cloud Storage URLs to the size of the objects
+	objects map[string]int64
+}
+
+func newTranscoderServer() *transcoderServer {
+	s := transcoderServer{
+		templates: make(map[string]*jobTemplate),
+		jobs:      make(map[string]*job),
+		objects:   make(map[string]int64),
+	}
+	s.Server = httptest.NewServer(&s)
+	return &s
+}
+
+func (s *transcoderServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if strings.HasPrefix(r.URL.Path, "/storage/v1/b/") {
+		s.listObjects(w, r)
+		return
+	}
+	prefix := "/v1/" + testParent + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		s.error(w, http.StatusNotFound, "not found")
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, prefix), "/", 2)
+	resource := parts[0]
+	var id string
+	if len(parts) > 1 {
+		id = parts[1]
+	}
+	switch {
+	case resource == "jobTemplates" && id == "" && r.Method == http.MethodPost:
+		var template jobTemplate
+		if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+			s.error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		id = r.URL.Query().Get("jobTemplateId")
+		if _, ok := s.templates[id]; ok {
+			s.error(w, http.StatusConflict, "job template already exists")
+			return
+		}
+		template.Name = testParent + "/jobTemplates/" + id
+		s.templates[id] = &template
+		s.respond(w, &template)
+	case resource == "jobTemplates" && id == "" && r.Method == http.MethodGet:
+		s.respond(w, map[string]interface{}{})
+	case resource == "jobTemplates" && r.Method == http.MethodGet:
+		template, ok := s.templates[id]
+		if !ok {
+			s.error(w, http.StatusNotFound, "job template not found")
+			return
+		}
+		s.respond(w, template)
+	case resource == "jobTemplates" && r.Method == http.MethodDelete:
+		if _, ok := s.templates[id]; !ok {
+			s.error(w, http.StatusNotFound, "job template not found")
+			return
+		}
+		delete(s.templates, id)
+		s.respond(w, map[string]interface{}{})
+	case resource == "jobs" && id == "" && r.Method == http.MethodPost:
+		var j job
+		if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
+			s.error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.createdID++
+		id = fmt.Sprintf("job-%d", s.createdID)
+		j.Name = testParent + "/jobs/" + id
+		j.State = statePending
+		s.jobs[id] = &j
+		s.respond(w, &j)
+	case resource == "jobs" && r.Method == http.MethodGet:
+		j, ok := s.jobs[id]
+		if !ok {
+			s.error(w, http.StatusNotFound, "job not found")
+			return
+		}
+		s.respond(w, j)
+	case resource == "jobs" && r.Method == http.MethodDelete:
+		if _, ok := s.jobs[id]; !ok {
+			s.error(w, http.StatusNotFound, "job not found")
+			return
+		}
+		delete(s.jobs, id)
+		s.respond(w, map[string]interface{}{})
+	default:
+		s.error(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *transcoderServer) listObjects(w http.ResponseWriter, r *http.Request) {
+	bucket := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/storage/v1/b/"), "/o")
+	prefix := "gs://" + bucket + "/" + r.URL.Query().Get("prefix")
+	var urls []string
+	for u := range s.objects {
+		if strings.HasPrefix(u, prefix) {
+			urls = append(urls, u)
+		}
+	}
+	sort.Strings(urls)
+	items := []storageObject{}
+	for _, u := range urls {
+		name := strings.TrimPrefix(u, "gs://"+bucket+"/")
+		items = append(items, storageObject{Name: name, Size: strconv.FormatInt(s.objects[u], 10)})
+	}
+	s.respond(w, map[string]interface{}{"items": items})
+}
+
+func (s *transcoderServer) respond(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *transcoderServer) error(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]*apiError{
+		"error": {Code: status, Message: message},
+	})
+}
+
+// getJob returns the job with the given id, for inspection in tests.
+func (s *transcoderServer) getJob(id string) *job {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.jobs[id]
+}
+
+// updateJob changes the job with the given id, simulating the progress of
+// the job in the Transcoder API.
+func (s *transcoderServer) updateJob(id string, fn func(*job)) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	fn(s.jobs[id])
+}
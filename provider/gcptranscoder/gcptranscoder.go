@@ -0,0 +1,584 @@
+// Package gcptranscoder provides a implementation of the provider that uses
+// the Google Cloud Transcoder API for transcoding media files.
+//
+// It doesn't expose any public type. In order to use the provider, one must
+// import this package and then grab the factory from the provider package:
+//
+//	import (
+//	    "github.com/NYTimes/video-transcoding-api/provider"
+//	    "github.com/NYTimes/video-transcoding-api/provider/gcptranscoder"
+//	)
+//
+//	func UseProvider() {
+//	    factory, err := provider.GetProviderFactory(gcptranscoder.Name)
+//	    // handle err and use factory to get an instance of the provider.
+//	}
+//
+// Presets are stored as job templates, identified by the name of the preset.
+// When a job is created, the templates of all its outputs are merged in a
+// single job configuration, along with the HLS and DASH manifests. Both the
+// source and the destination of jobs must be in Google Cloud Storage.
+//
+// Thumbnails are generated as sprite sheets holding a single image each. The
+// Transcoder API doesn't report the generated images, so they're listed from
+// Cloud Storage once the job is finished.
+//
+// Clips are translated to the edit list of the job, which applies to all its
+// outputs, so outputs can't have their own clips.
+package gcptranscoder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// Name is the name used for registering the Transcoder API provider in
+	// the registry of providers.
+	Name = "gcptranscoder"
+
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+	defaultStorageEndpoint = "https://storage.googleapis.com/storage/v1/"
+
+	// defaultFrameRate is the frame rate of outputs of presets that don't
+	// define one, as the Transcoder API requires it.
+	defaultFrameRate = 30
+
+	stateSucceeded = "SUCCEEDED"
+	stateFailed    = "FAILED"
+	stateRunning   = "RUNNING"
+	statePending   = "PENDING"
+)
+
+var (
+	errGCPTranscoderInvalidConfig = provider.InvalidConfigError("missing Transcoder API project id or destination. Please define the environment variables GCP_TRANSCODER_PROJECT_ID and GCP_TRANSCODER_DESTINATION or set these values in the configuration file")
+	errMisconfiguredTemplate      = errors.New("misconfigured job template: missing config")
+
+	httpClientsMtx sync.Mutex
+	httpClients    = make(map[string]*http.Client)
+
+	// videoCodecs lists the codec-specific parameters supported by each
+	// video codec.
+	videoCodecs = map[string][]string{
+		"h264": {"crf", "bufferSize", "bFrames", "twoPass", "frameRate"},
+		"hevc": {"bitDepth", "crf", "bufferSize", "bFrames", "twoPass", "frameRate"},
+		"vp9":  {"bitDepth", "frameRate"},
+	}
+	pixelFormats = map[string]string{"8": "yuv420p", "10": "yuv420p10"}
+
+	// audioParameters lists the optional audio parameters supported by
+	// the Transcoder API.
+	audioParameters = []string{"channels", "sampleRate", "language"}
+
+	presetConstraints = provider.PresetConstraints{
+		Containers:      []string{"mp4", "m3u8", "mpd"},
+		AudioCodecs:     []string{"aac", "mp3"},
+		VideoCodecs:     videoCodecs,
+		AudioParameters: audioParameters,
+	}
+	audioCodecs = map[string]bool{"aac": true, "mp3": true}
+
+	// thumbnailCapabilities describes the thumbnails generated by the
+	// Transcoder API: JPEG images at a regular interval. Its sprite sheets
+	// don't come with a WebVTT track, so they're not exposed.
+	thumbnailCapabilities = provider.ThumbnailCapabilities{Formats: []string{"jpg"}}
+)
+
+func init() {
+	provider.Register(Name, gcpTranscoderFactory)
+}
+
+type gcpProvider struct {
+	config *config.GCPTranscoder
+	client *client
+}
+
+func (p *gcpProvider) Transcode(dbJob *db.Job) (*provider.JobStatus, error) {
+	if !strings.HasPrefix(dbJob.SourceMedia, "gs://") {
+		return nil, fmt.Errorf("unsupported source %q: only gs:// sources are supported", dbJob.SourceMedia)
+	}
+	var cfg jobConfig
+	var hlsStreams, dashStreams []string
+	for i, output := range dbJob.Outputs {
+		templateID, ok := output.Preset.ProviderMapping[Name]
+		if !ok {
+			return nil, provider.ErrPresetMapNotFound
+		}
+		template, err := p.client.getJobTemplate(templateID)
+		if err != nil {
+			return nil, err
+		}
+		if template.Config == nil {
+			return nil, errMisconfiguredTemplate
+		}
+		prefix := "output" + strconv.Itoa(i) + "-"
+		for _, stream := range template.Config.ElementaryStreams {
+			stream.Key = prefix + stream.Key
+			cfg.ElementaryStreams = append(cfg.ElementaryStreams, stream)
+		}
+		for _, stream := range template.Config.MuxStreams {
+			stream.Key = prefix + stream.Key
+			keys := make([]string, len(stream.ElementaryStreams))
+			for j, key := range stream.ElementaryStreams {
+				keys[j] = prefix + key
+			}
+			stream.ElementaryStreams = keys
+			switch stream.Container {
+			case "ts":
+				hlsStreams = append(hlsStreams, stream.Key)
+				stream.SegmentSettings = segments(dbJob.StreamingParams)
+			case "fmp4":
+				dashStreams = append(dashStreams, stream.Key)
+				stream.SegmentSettings = segments(dbJob.StreamingParams)
+			default:
+				stream.FileName = output.FileName
+			}
+			cfg.MuxStreams = append(cfg.MuxStreams, stream)
+		}
+	}
+	if len(hlsStreams) > 0 {
+		cfg.Manifests = append(cfg.Manifests, manifest{
+			FileName:   manifestName(dbJob.StreamingParams, "hls", "hls/index.m3u8"),
+			Type:       "HLS",
+			MuxStreams: hlsStreams,
+		})
+	}
+	if len(dashStreams) > 0 {
+		cfg.Manifests = append(cfg.Manifests, manifest{
+			FileName:   manifestName(dbJob.StreamingParams, "dash", "dash/index.mpd"),
+			Type:       "DASH",
+			MuxStreams: dashStreams,
+		})
+	}
+	if dbJob.Thumbnails != nil {
+		cfg.SpriteSheets = []spriteSheet{thumbnailsSpriteSheet(dbJob.Thumbnails, cfg.ElementaryStreams)}
+	}
+	if dbJob.Clip != nil {
+		cfg.Inputs = []input{{Key: "input0", URI: dbJob.SourceMedia}}
+		cfg.EditList = []editAtom{clipAtom(dbJob.Clip, "input0")}
+	}
+	created, err := p.client.createJob(&job{
+		InputURI:  dbJob.SourceMedia,
+		OutputURI: p.destination(dbJob),
+		Config:    &cfg,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &provider.JobStatus{
+		ProviderName:  Name,
+		ProviderJobID: path.Base(created.Name),
+		Status:        provider.StatusQueued,
+	}, nil
+}
+
+// clipAtom returns the edit atom that selects the given clip of the input.
+func clipAtom(clip *db.Clip, inputKey string) editAtom {
+	atom := editAtom{Key: "atom0", Inputs: []string{inputKey}}
+	if clip.Start != "" {
+		atom.StartTimeOffset = provider.FormatSeconds(clip.StartTime()) + "s"
+	}
+	if clip.End != "" {
+		atom.EndTimeOffset = provider.FormatSeconds(clip.EndTime()) + "s"
+	}
+	return atom
+}
+
+// thumbnailsSpriteSheet returns the sprite sheet that generates the given
+// thumbnails, with a single image per sheet. Thumbnails without dimensions
+// take the dimensions of the first video stream of the job, as the
+// Transcoder API requires them.
+func thumbnailsSpriteSheet(thumbnails *db.Thumbnails, streams []elementaryStream) spriteSheet {
+	sheet := spriteSheet{
+		Format:             "jpeg",
+		FilePrefix:         strings.Trim(thumbnails.Path, "/") + "/thumbnail-",
+		SpriteWidthPixels:  int64(thumbnails.Width),
+		SpriteHeightPixels: int64(thumbnails.Height),
+		ColumnCount:        1,
+		RowCount:           1,
+		Interval:           strconv.Itoa(int(thumbnails.Interval)) + "s",
+	}
+	if sheet.SpriteWidthPixels > 0 || sheet.SpriteHeightPixels > 0 {
+		return sheet
+	}
+	for _, stream := range streams {
+		if settings := stream.VideoStream.settings(); settings != nil {
+			sheet.SpriteWidthPixels = settings.WidthPixels
+			sheet.SpriteHeightPixels = settings.HeightPixels
+			break
+		}
+	}
+	return sheet
+}
+
+func (p *gcpProvider) destination(dbJob *db.Job) string {
+	return strings.TrimRight(provider.JobDestination(dbJob, p.config.Destination), "/") + "/" + dbJob.ID + "/"
+}
+
+func segments(params db.StreamingParams) *segmentSettings {
+	if params.SegmentDuration == 0 {
+		return nil
+	}
+	return &segmentSettings{SegmentDuration: strconv.Itoa(int(params.SegmentDuration)) + "s"}
+}
+
+// manifestName returns the name of the manifest used for the given streaming
+// protocol.
+func manifestName(params db.StreamingParams, protocol, defaultName string) string {
+	if params.Protocol == protocol && params.PlaylistFileName != "" {
+		return params.PlaylistFileName
+	}
+	return defaultName
+}
+
+func (p *gcpProvider) JobStatus(dbJob *db.Job) (*provider.JobStatus, error) {
+	gcpJob, err := p.client.getJob(dbJob.ProviderJobID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, provider.JobNotFoundError{ID: dbJob.ProviderJobID}
+		}
+		return nil, err
+	}
+	status := provider.JobStatus{
+		ProviderName:   Name,
+		ProviderJobID:  path.Base(gcpJob.Name),
+		Status:         statusMap(gcpJob.State),
+		ProviderStatus: map[string]interface{}{"state": gcpJob.State},
+		Output:         provider.JobOutput{Destination: gcpJob.OutputURI},
+	}
+	if gcpJob.StartTime != "" {
+		status.ProviderStatus["startTime"] = gcpJob.StartTime
+	}
+	if gcpJob.EndTime != "" {
+		status.ProviderStatus["endTime"] = gcpJob.EndTime
+	}
+	if gcpJob.Error != nil {
+		status.StatusMessage = gcpJob.Error.Message
+	}
+	if status.Status == provider.StatusFinished {
+		status.Progress = 100
+		status.Output.Files = outputFiles(gcpJob)
+		status.Output.Thumbnails, err = p.thumbnailFiles(gcpJob)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &status, nil
+}
+
+// outputFiles returns the files generated by the given job: the files of
+// non-segmented outputs, and the manifests.
+func outputFiles(gcpJob *job) []provider.OutputFile {
+	if gcpJob.Config == nil {
+		return nil
+	}
+	videoStreams := make(map[string]*videoSettings)
+	for _, stream := range gcpJob.Config.ElementaryStreams {
+		if stream.VideoStream != nil && stream.VideoStream.H264 != nil {
+			videoStreams[stream.Key] = stream.VideoStream.H264
+		}
+	}
+	var files []provider.OutputFile
+	for _, stream := range gcpJob.Config.MuxStreams {
+		if stream.Container == "ts" || stream.Container == "fmp4" {
+			continue
+		}
+		fileName := stream.FileName
+		if fileName == "" {
+			fileName = stream.Key + "." + stream.Container
+		}
+		file := provider.OutputFile{Path: gcpJob.OutputURI + fileName, Container: stream.Container}
+		for _, key := range stream.ElementaryStreams {
+			if settings, ok := videoStreams[key]; ok {
+				file.VideoCodec = "h264"
+				file.Width = settings.WidthPixels
+				file.Height = settings.HeightPixels
+			}
+		}
+		files = append(files, file)
+	}
+	for _, m := range gcpJob.Config.Manifests {
+		container := "m3u8"
+		if m.Type == "DASH" {
+			container = "mpd"
+		}
+		files = append(files, provider.OutputFile{Path: gcpJob.OutputURI + m.FileName, Container: container})
+	}
+	return files
+}
+
+// thumbnailFiles returns the images generated by the sprite sheets of the
+// given job, listing them from Cloud Storage.
+func (p *gcpProvider) thumbnailFiles(gcpJob *job) ([]provider.OutputFile, error) {
+	if gcpJob.Config == nil {
+		return nil, nil
+	}
+	var files []provider.OutputFile
+	for _, sheet := range gcpJob.Config.SpriteSheets {
+		parts := strings.SplitN(strings.TrimPrefix(gcpJob.OutputURI+sheet.FilePrefix, "gs://"), "/", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		objects, err := p.client.listObjects(parts[0], parts[1])
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			size, _ := strconv.ParseInt(object.Size, 10, 64)
+			files = append(files, provider.OutputFile{
+				Path:      "gs://" + parts[0] + "/" + object.Name,
+				Container: "jpg",
+				Width:     sheet.SpriteWidthPixels,
+				Height:    sheet.SpriteHeightPixels,
+				FileSize:  size,
+			})
+		}
+	}
+	return files, nil
+}
+
+func statusMap(state string) provider.Status {
+	switch state {
+	case statePending:
+		return provider.StatusQueued
+	case stateRunning:
+		return provider.StatusStarted
+	case stateSucceeded:
+		return provider.StatusFinished
+	case stateFailed:
+		return provider.StatusFailed
+	default:
+		return provider.StatusUnknown
+	}
+}
+
+// CancelJob deletes the job, as the Transcoder API doesn't support canceling
+// jobs. Deleting a running job stops it.
+func (p *gcpProvider) CancelJob(id string) error {
+	err := p.client.deleteJob(id)
+	if err != nil && isNotFound(err) {
+		return provider.JobNotFoundError{ID: id}
+	}
+	return err
+}
+
+func (p *gcpProvider) Healthcheck() error {
+	return p.client.listJobTemplates()
+}
+
+func (p *gcpProvider) CreatePreset(preset db.Preset) (string, error) {
+	cfg, err := templateConfig(preset)
+	if err != nil {
+		return "", err
+	}
+	_, err = p.client.createJobTemplate(preset.Name, &jobTemplate{Config: cfg})
+	if err != nil {
+		return "", err
+	}
+	return preset.Name, nil
+}
+
+// templateConfig translates the given preset to the configuration of a job
+// template with a single output.
+func templateConfig(preset db.Preset) (*jobConfig, error) {
+	if err := provider.CheckAudioParameters(preset, audioParameters...); err != nil {
+		return nil, err
+	}
+	params, ok := videoCodecs[preset.Video.Codec]
+	if !ok {
+		return nil, provider.UnsupportedParameterError{Parameter: "video.codec", Value: preset.Video.Codec}
+	}
+	if err := provider.CheckVideoParameters(preset.Video, params...); err != nil {
+		return nil, err
+	}
+	if !audioCodecs[preset.Audio.Codec] {
+		return nil, fmt.Errorf("unsupported audio codec %q", preset.Audio.Codec)
+	}
+	video := videoSettings{FrameRate: defaultFrameRate, RateControlMode: "vbr"}
+	var err error
+	if video.BitrateBps, err = parseInt(preset.Video.Bitrate, "video bitrate"); err != nil {
+		return nil, err
+	}
+	if video.WidthPixels, err = parseInt(preset.Video.Width, "width"); err != nil {
+		return nil, err
+	}
+	if video.HeightPixels, err = parseInt(preset.Video.Height, "height"); err != nil {
+		return nil, err
+	}
+	if video.GopFrameCount, err = parseInt(preset.Video.GopSize, "GOP size"); err != nil {
+		return nil, err
+	}
+	if preset.Video.FrameRate != "" {
+		if video.FrameRate, err = strconv.ParseFloat(preset.Video.FrameRate, 64); err != nil {
+			return nil, fmt.Errorf("invalid frame rate %q", preset.Video.FrameRate)
+		}
+	}
+	if video.VbvSizeBits, err = parseInt(preset.Video.BufferSize, "buffer size"); err != nil {
+		return nil, err
+	}
+	if video.VbvSizeBits == 0 && strings.ToUpper(preset.RateControl) == "CBR" && preset.Video.Codec != "vp9" {
+		// constant bitrate is approximated with a one second buffer.
+		video.VbvSizeBits = video.BitrateBps
+	}
+	if video.CrfLevel, err = parseInt(preset.Video.CRF, "CRF"); err != nil {
+		return nil, err
+	}
+	if video.CrfLevel > 0 {
+		video.RateControlMode = "crf"
+	}
+	if video.BFrameCount, err = parseInt(preset.Video.BFrames, "B-frames"); err != nil {
+		return nil, err
+	}
+	video.EnableTwoPass = preset.Video.TwoPass
+	video.Profile = strings.ToLower(preset.Video.Profile)
+	if preset.Video.BitDepth != "" {
+		if video.PixelFormat, ok = pixelFormats[preset.Video.BitDepth]; !ok {
+			return nil, provider.UnsupportedParameterError{Parameter: "video.bitDepth", Value: preset.Video.BitDepth}
+		}
+	}
+	stream := videoStream{H264: &video}
+	switch preset.Video.Codec {
+	case "hevc":
+		stream = videoStream{H265: &video}
+	case "vp9":
+		// 10-bit VP9 requires the profile 2.
+		if preset.Video.BitDepth == "10" {
+			video.Profile = "profile2"
+		}
+		stream = videoStream{VP9: &video}
+	}
+	audio := audioStream{Codec: preset.Audio.Codec, LanguageCode: preset.Audio.Language}
+	if audio.BitrateBps, err = parseInt(preset.Audio.Bitrate, "audio bitrate"); err != nil {
+		return nil, err
+	}
+	if audio.ChannelCount, err = parseInt(preset.Audio.Channels, "audio channels"); err != nil {
+		return nil, err
+	}
+	if audio.SampleRateHertz, err = parseInt(preset.Audio.SampleRate, "audio sample rate"); err != nil {
+		return nil, err
+	}
+	cfg := jobConfig{
+		ElementaryStreams: []elementaryStream{
+			{Key: "video", VideoStream: &stream},
+			{Key: "audio", AudioStream: &audio},
+		},
+	}
+	switch preset.Container {
+	case "mp4":
+		cfg.MuxStreams = []muxStream{{Key: "output", Container: "mp4", ElementaryStreams: []string{"video", "audio"}}}
+	case "m3u8":
+		cfg.MuxStreams = []muxStream{{Key: "output", Container: "ts", ElementaryStreams: []string{"video", "audio"}}}
+	case "mpd":
+		// fragmented MP4 streams can't mix audio and video.
+		cfg.MuxStreams = []muxStream{
+			{Key: "video", Container: "fmp4", ElementaryStreams: []string{"video"}},
+			{Key: "audio", Container: "fmp4", ElementaryStreams: []string{"audio"}},
+		}
+	default:
+		return nil, fmt.Errorf("unsupported container %q", preset.Container)
+	}
+	return &cfg, nil
+}
+
+// parseInt parses the given integer parameter of a preset, returning zero if
+// the parameter is not defined.
+func parseInt(value, name string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
+
+func (p *gcpProvider) GetPreset(presetID string) (interface{}, error) {
+	return p.client.getJobTemplate(presetID)
+}
+
+func (p *gcpProvider) DeletePreset(presetID string) error {
+	return p.client.deleteJobTemplate(presetID)
+}
+
+func (p *gcpProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		InputFormats:  []string{"prores", "h264"},
+		OutputFormats: []string{"mp4", "hls", "dash"},
+		Destinations:  []string{"gcs"},
+
+		PresetConstraints: &presetConstraints,
+		Thumbnails:        &thumbnailCapabilities,
+		Clipping:          &provider.ClippingCapabilities{},
+	}
+}
+
+func gcpTranscoderFactory(cfg *config.Config) (provider.TranscodingProvider, error) {
+	gcpConfig := cfg.GCPTranscoder
+	if gcpConfig == nil || gcpConfig.ProjectID == "" || gcpConfig.Destination == "" {
+		return nil, errGCPTranscoderInvalidConfig
+	}
+	httpClient, err := getHTTPClient(gcpConfig.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error initializing Transcoder API client: %s", err)
+	}
+	return &gcpProvider{
+		config: gcpConfig,
+		client: &client{
+			httpClient:      httpClient,
+			endpoint:        gcpConfig.Endpoint,
+			storageEndpoint: defaultStorageEndpoint,
+			parent:          "projects/" + gcpConfig.ProjectID + "/locations/" + gcpConfig.Location,
+		},
+	}, nil
+}
+
+// getHTTPClient returns the HTTP client for the given credentials file,
+// creating it if needed. Providers are created on every request, so clients
+// are shared, reusing their connections and OAuth tokens.
+func getHTTPClient(credentialsFile string) (*http.Client, error) {
+	httpClientsMtx.Lock()
+	defer httpClientsMtx.Unlock()
+	if httpClient, ok := httpClients[credentialsFile]; ok {
+		return httpClient, nil
+	}
+	httpClient, err := newHTTPClient(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	httpClients[credentialsFile] = httpClient
+	return httpClient, nil
+}
+
+// newHTTPClient returns an HTTP client authenticated with the given service
+// account key file, or with the application default credentials when no file
+// is given.
+func newHTTPClient(credentialsFile string) (*http.Client, error) {
+	ctx := context.Background()
+	if credentialsFile == "" {
+		return google.DefaultClient(ctx, cloudPlatformScope)
+	}
+	data, err := ioutil.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	jwtConfig, err := google.JWTConfigFromJSON(data, cloudPlatformScope)
+	if err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(ctx, jwtConfig.TokenSource(ctx)), nil
+}
@@ -0,0 +1,806 @@
+package gcptranscoder
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+var testPresets = []db.Preset{
+	{
+		Name:        "mp4_1080p",
+		Description: "MP4 1080p",
+		Container:   "mp4",
+		RateControl: "CBR",
+		Video: db.VideoPreset{
+			Profile: "Main",
+			Width:   "1920",
+			Height:  "1080",
+			Codec:   "h264",
+			Bitrate: "3500000",
+			GopSize: "90",
+		},
+		Audio: db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+	},
+	{
+		Name:      "hls_480p",
+		Container: "m3u8",
+		Video:     db.VideoPreset{Height: "480", Codec: "h264", Bitrate: "1000000"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+	},
+	{
+		Name:      "dash_720p",
+		Container: "mpd",
+		Video:     db.VideoPreset{Height: "720", Codec: "h264", Bitrate: "2000000"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+	},
+}
+
+func newTestProvider(t *testing.T, server *transcoderServer) *gcpProvider {
+	prov := &gcpProvider{
+		config: &config.GCPTranscoder{
+			ProjectID:   "my-project",
+			Location:    "us-central1",
+			Destination: "gs://some-bucket/",
+		},
+		client: &client{
+			httpClient:      http.DefaultClient,
+			endpoint:        server.URL + "/v1/",
+			storageEndpoint: server.URL + "/storage/v1/",
+			parent:          testParent,
+		},
+	}
+	for _, preset := range testPresets {
+		if _, err := prov.CreatePreset(preset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return prov
+}
+
+// newTestJob returns a job with the given outputs, in the format
+// "preset:fileName".
+func newTestJob(id string, outputs ...string) *db.Job {
+	job := db.Job{
+		ID:              id,
+		SourceMedia:     "gs://some-bucket/source.mov",
+		StreamingParams: db.StreamingParams{Protocol: "hls", PlaylistFileName: "hls/master.m3u8", SegmentDuration: 4},
+	}
+	for _, output := range outputs {
+		parts := strings.SplitN(output, ":", 2)
+		job.Outputs = append(job.Outputs, db.TranscodeOutput{
+			FileName: parts[1],
+			Preset:   db.PresetMap{Name: parts[0], ProviderMapping: map[string]string{Name: parts[0]}},
+		})
+	}
+	return &job
+}
+
+func TestFactoryIsRegistered(t *testing.T) {
+	_, err := provider.GetProviderFactory(Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGCPTranscoderFactory(t *testing.T) {
+	credentials, err := ioutil.TempFile("", "gcptranscoder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(credentials.Name())
+	credentials.WriteString(`{"type":"service_account","client_email":"transcoding@my-project.iam.gserviceaccount.com","private_key":"not-really-a-key","token_uri":"https://oauth2.googleapis.com/token"}`)
+	credentials.Close()
+	cfg := config.Config{
+		GCPTranscoder: &config.GCPTranscoder{
+			ProjectID:       "my-project",
+			Location:        "europe-west1",
+			CredentialsFile: credentials.Name(),
+			Endpoint:        "https://transcoder.googleapis.com/v1/",
+			Destination:     "gs://some-bucket/",
+		},
+	}
+	prov, err := gcpTranscoderFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcpProv := prov.(*gcpProvider)
+	if !reflect.DeepEqual(*gcpProv.config, *cfg.GCPTranscoder) {
+		t.Errorf("GCPTranscoder: did not store the proper config. Want %#v. Got %#v.", cfg.GCPTranscoder, gcpProv.config)
+	}
+	if gcpProv.client.endpoint != cfg.GCPTranscoder.Endpoint {
+		t.Errorf("GCPTranscoder: wrong endpoint. Want %q. Got %q.", cfg.GCPTranscoder.Endpoint, gcpProv.client.endpoint)
+	}
+	expectedParent := "projects/my-project/locations/europe-west1"
+	if gcpProv.client.parent != expectedParent {
+		t.Errorf("GCPTranscoder: wrong parent. Want %q. Got %q.", expectedParent, gcpProv.client.parent)
+	}
+	if gcpProv.client.storageEndpoint != defaultStorageEndpoint {
+		t.Errorf("GCPTranscoder: wrong storage endpoint. Want %q. Got %q.", defaultStorageEndpoint, gcpProv.client.storageEndpoint)
+	}
+	prov, err = gcpTranscoderFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if httpClient := prov.(*gcpProvider).client.httpClient; httpClient != gcpProv.client.httpClient {
+		t.Error("GCPTranscoder: did not reuse the HTTP client of the credentials file")
+	}
+}
+
+func TestGCPTranscoderFactoryCredentialsError(t *testing.T) {
+	cfg := config.Config{
+		GCPTranscoder: &config.GCPTranscoder{
+			ProjectID:       "my-project",
+			CredentialsFile: "/non/existent/credentials.json",
+			Destination:     "gs://some-bucket/",
+		},
+	}
+	for i := 0; i < 2; i++ {
+		prov, err := gcpTranscoderFactory(&cfg)
+		if err == nil {
+			t.Fatalf("got unexpected <nil> error, provider: %#v", prov)
+		}
+	}
+	if _, ok := httpClients[cfg.GCPTranscoder.CredentialsFile]; ok {
+		t.Error("GCPTranscoder: cached the HTTP client of invalid credentials")
+	}
+}
+
+func TestGCPTranscoderFactoryValidation(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenConfig   *config.GCPTranscoder
+	}{
+		{"missing config", nil},
+		{"missing project", &config.GCPTranscoder{Location: "us-central1", Destination: "gs://bucket/"}},
+		{"missing destination", &config.GCPTranscoder{ProjectID: "my-project", Location: "us-central1"}},
+	}
+	for _, test := range tests {
+		prov, err := gcpTranscoderFactory(&config.Config{GCPTranscoder: test.givenConfig})
+		if prov != nil {
+			t.Errorf("%s: got unexpected non-nil provider: %#v", test.givenTestCase, prov)
+		}
+		if err != errGCPTranscoderInvalidConfig {
+			t.Errorf("%s: wrong error returned. Want errGCPTranscoderInvalidConfig. Got %#v", test.givenTestCase, err)
+		}
+	}
+}
+
+func TestGCPTranscoderCreatePreset(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	newTestProvider(t, server)
+	var tests = []struct {
+		givenPreset    string
+		expectedConfig *jobConfig
+	}{
+		{
+			"mp4_1080p",
+			&jobConfig{
+				ElementaryStreams: []elementaryStream{
+					{Key: "video", VideoStream: &videoStream{H264: &videoSettings{
+						WidthPixels:     1920,
+						HeightPixels:    1080,
+						FrameRate:       30,
+						BitrateBps:      3500000,
+						RateControlMode: "vbr",
+						VbvSizeBits:     3500000,
+						GopFrameCount:   90,
+						Profile:         "main",
+					}}},
+					{Key: "audio", AudioStream: &audioStream{Codec: "aac", BitrateBps: 128000}},
+				},
+				MuxStreams: []muxStream{
+					{Key: "output", Container: "mp4", ElementaryStreams: []string{"video", "audio"}},
+				},
+			},
+		},
+		{
+			"dash_720p",
+			&jobConfig{
+				ElementaryStreams: []elementaryStream{
+					{Key: "video", VideoStream: &videoStream{H264: &videoSettings{
+						HeightPixels:    720,
+						FrameRate:       30,
+						BitrateBps:      2000000,
+						RateControlMode: "vbr",
+					}}},
+					{Key: "audio", AudioStream: &audioStream{Codec: "aac", BitrateBps: 64000}},
+				},
+				MuxStreams: []muxStream{
+					{Key: "video", Container: "fmp4", ElementaryStreams: []string{"video"}},
+					{Key: "audio", Container: "fmp4", ElementaryStreams: []string{"audio"}},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		template, ok := server.templates[test.givenPreset]
+		if !ok {
+			t.Errorf("%s: job template not created", test.givenPreset)
+			continue
+		}
+		if !reflect.DeepEqual(template.Config, test.expectedConfig) {
+			t.Errorf("%s: wrong job template config\nwant %#v\ngot  %#v", test.givenPreset, test.expectedConfig, template.Config)
+		}
+	}
+}
+
+func TestTemplateConfigVideoCodecs(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenVideo    db.VideoPreset
+		wantStream    *videoStream
+	}{
+		{
+			"HEVC 10-bit with CRF",
+			db.VideoPreset{Codec: "hevc", Profile: "Main10", Height: "2160", Bitrate: "12000000", BitDepth: "10", CRF: "24", BFrames: "4"},
+			&videoStream{H265: &videoSettings{
+				HeightPixels:    2160,
+				FrameRate:       30,
+				BitrateBps:      12000000,
+				RateControlMode: "crf",
+				CrfLevel:        24,
+				BFrameCount:     4,
+				Profile:         "main10",
+				PixelFormat:     "yuv420p10",
+			}},
+		},
+		{
+			"two-pass H.264 with buffer size",
+			db.VideoPreset{Codec: "h264", Bitrate: "5000000", BufferSize: "10000000", TwoPass: true},
+			&videoStream{H264: &videoSettings{
+				FrameRate:       30,
+				BitrateBps:      5000000,
+				RateControlMode: "vbr",
+				VbvSizeBits:     10000000,
+				EnableTwoPass:   true,
+			}},
+		},
+		{
+			"H.264 at NTSC frame rate",
+			db.VideoPreset{Codec: "h264", Bitrate: "2000000", FrameRate: "29.97"},
+			&videoStream{H264: &videoSettings{
+				FrameRate:       29.97,
+				BitrateBps:      2000000,
+				RateControlMode: "vbr",
+			}},
+		},
+		{
+			"VP9 10-bit",
+			db.VideoPreset{Codec: "vp9", Bitrate: "3000000", BitDepth: "10"},
+			&videoStream{VP9: &videoSettings{
+				FrameRate:       30,
+				BitrateBps:      3000000,
+				RateControlMode: "vbr",
+				Profile:         "profile2",
+				PixelFormat:     "yuv420p10",
+			}},
+		},
+	}
+	for _, test := range tests {
+		cfg, err := templateConfig(db.Preset{Container: "mp4", Video: test.givenVideo, Audio: db.AudioPreset{Codec: "aac"}})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
+		if stream := cfg.ElementaryStreams[0].VideoStream; !reflect.DeepEqual(stream, test.wantStream) {
+			t.Errorf("%s: wrong video stream\nwant %#v\ngot  %#v", test.givenTestCase, test.wantStream, stream)
+		}
+	}
+}
+
+func TestGCPTranscoderCreatePresetErrors(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	var tests = []struct {
+		givenTestCase string
+		givenPreset   db.Preset
+		wantErrMsg    string
+	}{
+		{
+			"unsupported container",
+			db.Preset{Name: "preset", Container: "webm", Video: db.VideoPreset{Codec: "h264"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`unsupported container "webm"`,
+		},
+		{
+			"unsupported video codec",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "vp8"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`unsupported value "vp8" for video.codec`,
+		},
+		{
+			"unsupported max bitrate",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "hevc", MaxBitrate: "5000000"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`unsupported value "5000000" for video.maxBitrate`,
+		},
+		{
+			"unsupported two-pass VP9",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "vp9", TwoPass: true}, Audio: db.AudioPreset{Codec: "aac"}},
+			`unsupported value "true" for video.twoPass`,
+		},
+		{
+			"unsupported bit depth",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "hevc", BitDepth: "12"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`unsupported value "12" for video.bitDepth`,
+		},
+		{
+			"invalid CRF",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "h264", CRF: "best"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`invalid CRF "best"`,
+		},
+		{
+			"unsupported audio codec",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "h264"}, Audio: db.AudioPreset{Codec: "vorbis"}},
+			`unsupported audio codec "vorbis"`,
+		},
+		{
+			"invalid video bitrate",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "3.5m"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`invalid video bitrate "3.5m"`,
+		},
+		{
+			"unsupported loudness normalization",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "h264"}, Audio: db.AudioPreset{Codec: "aac", Loudness: "-23"}},
+			`unsupported value "-23" for audio.loudness`,
+		},
+		{
+			"unsupported audio-only preset",
+			db.Preset{Name: "preset", Container: "mp4", Audio: db.AudioPreset{Codec: "aac"}},
+			`unsupported value "none" for video`,
+		},
+		{
+			"duplicate preset",
+			testPresets[0],
+			"Transcoder API error (409): job template already exists",
+		},
+	}
+	for _, test := range tests {
+		presetID, err := prov.CreatePreset(test.givenPreset)
+		if err == nil || err.Error() != test.wantErrMsg {
+			t.Errorf("%s: wrong error returned. Want %q. Got %v", test.givenTestCase, test.wantErrMsg, err)
+		}
+		if presetID != "" {
+			t.Errorf("%s: unexpected non-empty preset id: %q", test.givenTestCase, presetID)
+		}
+	}
+}
+
+func TestGCPTranscoderGetAndDeletePreset(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	preset, err := prov.GetPreset("hls_480p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedName := testParent + "/jobTemplates/hls_480p"
+	if name := preset.(*jobTemplate).Name; name != expectedName {
+		t.Errorf("wrong preset returned. Want %q. Got %q", expectedName, name)
+	}
+	err = prov.DeletePreset("hls_480p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.templates["hls_480p"]; ok {
+		t.Error("preset was not deleted")
+	}
+	_, err = prov.GetPreset("hls_480p")
+	if err == nil {
+		t.Error("unexpected <nil> error when getting deleted preset")
+	}
+}
+
+func TestGCPTranscoderNormalizePreset(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	expected := db.Preset{
+		Container: "mp4",
+		Video: db.VideoPreset{
+			Profile:    "main",
+			Width:      "1920",
+			Height:     "1080",
+			Codec:      "h264",
+			Bitrate:    "3500000",
+			GopSize:    "90",
+			BufferSize: "3500000",
+		},
+		Audio: db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+	}
+	normalized, err := prov.NormalizePreset(testPresets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(normalized, expected) {
+		t.Errorf("wrong normalized preset\nwant %#v\ngot  %#v", expected, normalized)
+	}
+	preset, err := prov.GetPreset("mp4_1080p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized, err = prov.NormalizePreset(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(normalized, expected) {
+		t.Errorf("wrong normalized preset\nwant %#v\ngot  %#v", expected, normalized)
+	}
+	normalized, err = prov.NormalizePreset(db.Preset{
+		Container: "mpd",
+		Video:     db.VideoPreset{Codec: "vp9", Bitrate: "1000000", BitDepth: "10"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000", Channels: "2", SampleRate: "48000", Language: "en-US"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = db.Preset{
+		Container: "mpd",
+		Video:     db.VideoPreset{Codec: "vp9", Bitrate: "1000000", BitDepth: "10", Profile: "profile2"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000", Channels: "2", SampleRate: "48000", Language: "en-US"},
+	}
+	if !reflect.DeepEqual(normalized, expected) {
+		t.Errorf("wrong normalized preset\nwant %#v\ngot  %#v", expected, normalized)
+	}
+	_, err = prov.NormalizePreset(&jobTemplate{})
+	if err != errMisconfiguredTemplate {
+		t.Errorf("wrong error returned. Want errMisconfiguredTemplate. Got %#v", err)
+	}
+}
+
+func TestGCPTranscoderTranscode(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	dbJob := newTestJob("job-123", "mp4_1080p:video_1080p.mp4", "hls_480p:hls/video_480p.m3u8", "dash_720p:dash/video_720p.mpd")
+	status, err := prov.Transcode(dbJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedStatus := &provider.JobStatus{ProviderName: Name, ProviderJobID: "job-1", Status: provider.StatusQueued}
+	if !reflect.DeepEqual(status, expectedStatus) {
+		t.Errorf("wrong job status returned\nwant %#v\ngot  %#v", expectedStatus, status)
+	}
+	gcpJob := server.getJob("job-1")
+	if gcpJob.InputURI != dbJob.SourceMedia {
+		t.Errorf("wrong input. Want %q. Got %q", dbJob.SourceMedia, gcpJob.InputURI)
+	}
+	if expected := "gs://some-bucket/job-123/"; gcpJob.OutputURI != expected {
+		t.Errorf("wrong output. Want %q. Got %q", expected, gcpJob.OutputURI)
+	}
+	var keys []string
+	for _, stream := range gcpJob.Config.ElementaryStreams {
+		keys = append(keys, stream.Key)
+	}
+	expectedKeys := []string{"output0-video", "output0-audio", "output1-video", "output1-audio", "output2-video", "output2-audio"}
+	if !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("wrong elementary streams\nwant %#v\ngot  %#v", expectedKeys, keys)
+	}
+	segments := &segmentSettings{SegmentDuration: "4s"}
+	expectedMuxStreams := []muxStream{
+		{Key: "output0-output", FileName: "video_1080p.mp4", Container: "mp4", ElementaryStreams: []string{"output0-video", "output0-audio"}},
+		{Key: "output1-output", Container: "ts", ElementaryStreams: []string{"output1-video", "output1-audio"}, SegmentSettings: segments},
+		{Key: "output2-video", Container: "fmp4", ElementaryStreams: []string{"output2-video"}, SegmentSettings: segments},
+		{Key: "output2-audio", Container: "fmp4", ElementaryStreams: []string{"output2-audio"}, SegmentSettings: segments},
+	}
+	if !reflect.DeepEqual(gcpJob.Config.MuxStreams, expectedMuxStreams) {
+		t.Errorf("wrong mux streams\nwant %#v\ngot  %#v", expectedMuxStreams, gcpJob.Config.MuxStreams)
+	}
+	expectedManifests := []manifest{
+		{FileName: "hls/master.m3u8", Type: "HLS", MuxStreams: []string{"output1-output"}},
+		{FileName: "dash/index.mpd", Type: "DASH", MuxStreams: []string{"output2-video", "output2-audio"}},
+	}
+	if !reflect.DeepEqual(gcpJob.Config.Manifests, expectedManifests) {
+		t.Errorf("wrong manifests\nwant %#v\ngot  %#v", expectedManifests, gcpJob.Config.Manifests)
+	}
+}
+
+func TestGCPTranscoderTranscodeThumbnails(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	var tests = []struct {
+		givenTestCase   string
+		givenThumbnails db.Thumbnails
+		expectedSheet   spriteSheet
+	}{
+		{
+			"explicit size",
+			db.Thumbnails{Interval: 10, Width: 320, Format: "jpg", Path: "thumbnails"},
+			spriteSheet{
+				Format:            "jpeg",
+				FilePrefix:        "thumbnails/thumbnail-",
+				SpriteWidthPixels: 320,
+				ColumnCount:       1,
+				RowCount:          1,
+				Interval:          "10s",
+			},
+		},
+		{
+			"size of the first video stream",
+			db.Thumbnails{Interval: 5, Format: "jpg", Path: "images/thumbs/"},
+			spriteSheet{
+				Format:             "jpeg",
+				FilePrefix:         "images/thumbs/thumbnail-",
+				SpriteWidthPixels:  1920,
+				SpriteHeightPixels: 1080,
+				ColumnCount:        1,
+				RowCount:           1,
+				Interval:           "5s",
+			},
+		},
+	}
+	for _, test := range tests {
+		dbJob := newTestJob("job-123", "mp4_1080p:video_1080p.mp4")
+		dbJob.Thumbnails = &test.givenThumbnails
+		status, err := prov.Transcode(dbJob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gcpJob := server.getJob(status.ProviderJobID)
+		expected := []spriteSheet{test.expectedSheet}
+		if !reflect.DeepEqual(gcpJob.Config.SpriteSheets, expected) {
+			t.Errorf("%s: wrong sprite sheets\nwant %#v\ngot  %#v", test.givenTestCase, expected, gcpJob.Config.SpriteSheets)
+		}
+	}
+}
+
+func TestGCPTranscoderTranscodeClip(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	var tests = []struct {
+		givenClip    db.Clip
+		expectedAtom editAtom
+	}{
+		{
+			db.Clip{Start: "10", End: "25.5"},
+			editAtom{Key: "atom0", Inputs: []string{"input0"}, StartTimeOffset: "10s", EndTimeOffset: "25.5s"},
+		},
+		{
+			db.Clip{Start: "60"},
+			editAtom{Key: "atom0", Inputs: []string{"input0"}, StartTimeOffset: "60s"},
+		},
+	}
+	for _, test := range tests {
+		dbJob := newTestJob("job-123", "mp4_1080p:video_1080p.mp4")
+		dbJob.Clip = &test.givenClip
+		status, err := prov.Transcode(dbJob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gcpJob := server.getJob(status.ProviderJobID)
+		expectedInputs := []input{{Key: "input0", URI: dbJob.SourceMedia}}
+		if !reflect.DeepEqual(gcpJob.Config.Inputs, expectedInputs) {
+			t.Errorf("%#v: wrong inputs\nwant %#v\ngot  %#v", test.givenClip, expectedInputs, gcpJob.Config.Inputs)
+		}
+		expectedEditList := []editAtom{test.expectedAtom}
+		if !reflect.DeepEqual(gcpJob.Config.EditList, expectedEditList) {
+			t.Errorf("%#v: wrong edit list\nwant %#v\ngot  %#v", test.givenClip, expectedEditList, gcpJob.Config.EditList)
+		}
+	}
+}
+
+func TestGCPTranscoderTranscodeErrors(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	s3Job := newTestJob("job-123", "mp4_1080p:video_1080p.mp4")
+	s3Job.SourceMedia = "s3://some-bucket/source.mov"
+	var tests = []struct {
+		givenTestCase string
+		givenJob      *db.Job
+		wantErrMsg    string
+	}{
+		{"non-gcs source", s3Job, `unsupported source "s3://some-bucket/source.mov": only gs:// sources are supported`},
+		{"unknown preset", newTestJob("job-123", "mp4_4k:video_4k.mp4"), "Transcoder API error (404): job template not found"},
+	}
+	for _, test := range tests {
+		status, err := prov.Transcode(test.givenJob)
+		if err == nil || err.Error() != test.wantErrMsg {
+			t.Errorf("%s: wrong error returned. Want %q. Got %v", test.givenTestCase, test.wantErrMsg, err)
+		}
+		if status != nil {
+			t.Errorf("%s: unexpected non-nil status: %#v", test.givenTestCase, status)
+		}
+	}
+}
+
+func TestGCPTranscoderJobStatus(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	dbJob := newTestJob("job-123", "mp4_1080p:video_1080p.mp4", "hls_480p:hls/video_480p.m3u8")
+	status, err := prov.Transcode(dbJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbJob.ProviderJobID = status.ProviderJobID
+	var tests = []struct {
+		givenState     string
+		expectedStatus provider.Status
+	}{
+		{"PENDING", provider.StatusQueued},
+		{"RUNNING", provider.StatusStarted},
+		{"SUCCEEDED", provider.StatusFinished},
+		{"PROCESSING_STATE_UNSPECIFIED", provider.StatusUnknown},
+	}
+	for _, test := range tests {
+		server.updateJob(dbJob.ProviderJobID, func(gcpJob *job) {
+			gcpJob.State = test.givenState
+		})
+		status, err := prov.JobStatus(dbJob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Status != test.expectedStatus {
+			t.Errorf("%s: wrong status. Want %q. Got %q", test.givenState, test.expectedStatus, status.Status)
+		}
+	}
+	server.updateJob(dbJob.ProviderJobID, func(gcpJob *job) {
+		gcpJob.State = "SUCCEEDED"
+		gcpJob.StartTime = "2018-01-10T15:00:00Z"
+		gcpJob.EndTime = "2018-01-10T15:05:00Z"
+	})
+	status, err = prov.JobStatus(dbJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &provider.JobStatus{
+		ProviderName:  Name,
+		ProviderJobID: "job-1",
+		Status:        provider.StatusFinished,
+		Progress:      100,
+		ProviderStatus: map[string]interface{}{
+			"state":     "SUCCEEDED",
+			"startTime": "2018-01-10T15:00:00Z",
+			"endTime":   "2018-01-10T15:05:00Z",
+		},
+		Output: provider.JobOutput{
+			Destination: "gs://some-bucket/job-123/",
+			Files: []provider.OutputFile{
+				{Path: "gs://some-bucket/job-123/video_1080p.mp4", Container: "mp4", VideoCodec: "h264", Width: 1920, Height: 1080},
+				{Path: "gs://some-bucket/job-123/hls/master.m3u8", Container: "m3u8"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("wrong job status returned\nwant %#v\ngot  %#v", expected, status)
+	}
+}
+
+func TestGCPTranscoderJobStatusThumbnails(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	server.objects["gs://some-bucket/job-123/video_1080p.mp4"] = 4096
+	server.objects["gs://some-bucket/job-123/thumbnails/thumbnail-0000000000.jpeg"] = 1024
+	server.objects["gs://some-bucket/job-123/thumbnails/thumbnail-0000000001.jpeg"] = 1024
+	dbJob := newTestJob("job-123", "mp4_1080p:video_1080p.mp4")
+	dbJob.Thumbnails = &db.Thumbnails{Interval: 10, Width: 320, Height: 180, Format: "jpg", Path: "thumbnails"}
+	status, err := prov.Transcode(dbJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbJob.ProviderJobID = status.ProviderJobID
+	server.updateJob(dbJob.ProviderJobID, func(gcpJob *job) {
+		gcpJob.State = "SUCCEEDED"
+	})
+	status, err = prov.JobStatus(dbJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []provider.OutputFile{
+		{Path: "gs://some-bucket/job-123/thumbnails/thumbnail-0000000000.jpeg", Container: "jpg", Width: 320, Height: 180, FileSize: 1024},
+		{Path: "gs://some-bucket/job-123/thumbnails/thumbnail-0000000001.jpeg", Container: "jpg", Width: 320, Height: 180, FileSize: 1024},
+	}
+	if !reflect.DeepEqual(status.Output.Thumbnails, expected) {
+		t.Errorf("wrong thumbnails\nwant %#v\ngot  %#v", expected, status.Output.Thumbnails)
+	}
+}
+
+func TestGCPTranscoderJobStatusFailed(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	dbJob := newTestJob("job-123", "mp4_1080p:video_1080p.mp4")
+	status, err := prov.Transcode(dbJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbJob.ProviderJobID = status.ProviderJobID
+	server.updateJob(dbJob.ProviderJobID, func(gcpJob *job) {
+		gcpJob.State = "FAILED"
+		gcpJob.Error = &apiError{Code: 3, Message: "Input file not found"}
+	})
+	status, err = prov.JobStatus(dbJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != provider.StatusFailed {
+		t.Errorf("wrong status. Want %q. Got %q", provider.StatusFailed, status.Status)
+	}
+	if status.StatusMessage != "Input file not found" {
+		t.Errorf("wrong status message. Want %q. Got %q", "Input file not found", status.StatusMessage)
+	}
+}
+
+func TestGCPTranscoderJobStatusNotFound(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	status, err := prov.JobStatus(&db.Job{ID: "job-123", ProviderJobID: "job-unknown"})
+	if _, ok := err.(provider.JobNotFoundError); !ok {
+		t.Errorf("wrong error returned. Want JobNotFoundError. Got %#v", err)
+	}
+	if status != nil {
+		t.Errorf("unexpected non-nil status: %#v", status)
+	}
+}
+
+func TestGCPTranscoderCancelJob(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	status, err := prov.Transcode(newTestJob("job-123", "mp4_1080p:video_1080p.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = prov.CancelJob(status.ProviderJobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gcpJob := server.getJob(status.ProviderJobID); gcpJob != nil {
+		t.Errorf("job was not deleted: %#v", gcpJob)
+	}
+	err = prov.CancelJob("job-unknown")
+	if _, ok := err.(provider.JobNotFoundError); !ok {
+		t.Errorf("wrong error returned. Want JobNotFoundError. Got %#v", err)
+	}
+}
+
+func TestGCPTranscoderHealthcheck(t *testing.T) {
+	server := newTranscoderServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	if err := prov.Healthcheck(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	prov.client.parent = "projects/other-project/locations/us-central1"
+	if err := prov.Healthcheck(); err == nil {
+		t.Error("unexpected <nil> error for unknown project")
+	}
+}
+
+func TestGCPTranscoderCapabilities(t *testing.T) {
+	var prov gcpProvider
+	expected := provider.Capabilities{
+		InputFormats:  []string{"prores", "h264"},
+		OutputFormats: []string{"mp4", "hls", "dash"},
+		Destinations:  []string{"gcs"},
+
+		PresetConstraints: &provider.PresetConstraints{
+			Containers:  []string{"mp4", "m3u8", "mpd"},
+			AudioCodecs: []string{"aac", "mp3"},
+			VideoCodecs: map[string][]string{
+				"h264": {"crf", "bufferSize", "bFrames", "twoPass", "frameRate"},
+				"hevc": {"bitDepth", "crf", "bufferSize", "bFrames", "twoPass", "frameRate"},
+				"vp9":  {"bitDepth", "frameRate"},
+			},
+			AudioParameters: []string{"channels", "sampleRate", "language"},
+		},
+		Thumbnails: &provider.ThumbnailCapabilities{Formats: []string{"jpg"}},
+		Clipping:   &provider.ClippingCapabilities{},
+	}
+	cap := prov.Capabilities()
+	if !reflect.DeepEqual(cap, expected) {
+		t.Errorf("Capabilities: want %#v. Got %#v", expected, cap)
+	}
+}
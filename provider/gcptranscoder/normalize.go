@@ -0,0 +1,94 @@
+package gcptranscoder
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+// muxContainers maps the containers of mux streams back to the containers of
+// presets.
+var muxContainers = map[string]string{"mp4": "mp4", "ts": "m3u8", "fmp4": "mpd"}
+
+func (p *gcpProvider) NormalizePreset(preset interface{}) (db.Preset, error) {
+	var cfg *jobConfig
+	switch preset := preset.(type) {
+	case db.Preset:
+		var err error
+		if cfg, err = templateConfig(preset); err != nil {
+			return db.Preset{}, err
+		}
+	case *jobTemplate:
+		if preset.Config == nil {
+			return db.Preset{}, errMisconfiguredTemplate
+		}
+		cfg = preset.Config
+	default:
+		return db.Preset{}, fmt.Errorf("unexpected preset type %T", preset)
+	}
+	var normalized db.Preset
+	if len(cfg.MuxStreams) > 0 {
+		normalized.Container = muxContainers[cfg.MuxStreams[0].Container]
+	}
+	for _, stream := range cfg.ElementaryStreams {
+		switch {
+		case stream.VideoStream != nil:
+			normalized.Video = normalizeVideo(stream.VideoStream)
+		case stream.AudioStream != nil:
+			normalized.Audio = db.AudioPreset{
+				Codec:      stream.AudioStream.Codec,
+				Bitrate:    formatInt(stream.AudioStream.BitrateBps),
+				Channels:   formatInt(stream.AudioStream.ChannelCount),
+				SampleRate: formatInt(stream.AudioStream.SampleRateHertz),
+				Language:   stream.AudioStream.LanguageCode,
+			}
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeVideo translates the given video stream back to the video
+// parameters of a preset, reverting templateConfig.
+func normalizeVideo(stream *videoStream) db.VideoPreset {
+	var video db.VideoPreset
+	var settings *videoSettings
+	switch {
+	case stream.H264 != nil:
+		video.Codec, settings = "h264", stream.H264
+	case stream.H265 != nil:
+		video.Codec, settings = "hevc", stream.H265
+	case stream.VP9 != nil:
+		video.Codec, settings = "vp9", stream.VP9
+	default:
+		return video
+	}
+	video.Width = formatInt(settings.WidthPixels)
+	video.Height = formatInt(settings.HeightPixels)
+	video.Bitrate = formatInt(settings.BitrateBps)
+	video.GopSize = formatInt(settings.GopFrameCount)
+	// the default frame rate is set on presets that don't define one.
+	if settings.FrameRate != defaultFrameRate && settings.FrameRate != 0 {
+		video.FrameRate = strconv.FormatFloat(settings.FrameRate, 'f', -1, 64)
+	}
+	video.BufferSize = formatInt(settings.VbvSizeBits)
+	video.CRF = formatInt(settings.CrfLevel)
+	video.BFrames = formatInt(settings.BFrameCount)
+	video.TwoPass = settings.EnableTwoPass
+	video.Profile = settings.Profile
+	for bitDepth, pixelFormat := range pixelFormats {
+		if pixelFormat == settings.PixelFormat {
+			video.BitDepth = bitDepth
+		}
+	}
+	return video
+}
+
+// formatInt formats the given parameter, returning an empty string for zero,
+// as templateConfig leaves undefined parameters as zero.
+func formatInt(value int64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatInt(value, 10)
+}
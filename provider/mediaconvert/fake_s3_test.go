@@ -0,0 +1,37 @@
+package mediaconvert
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// fakeS3 is a fake implementation of the S3 API, supporting the listing of
+// objects used by the provider.
+type fakeS3 struct {
+	s3iface.S3API
+
+	// objects maps S3 URLs to the size of the objects
+	objects map[string]int64
+}
+
+func (s *fakeS3) ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	prefix := "s3://" + aws.StringValue(input.Bucket) + "/" + aws.StringValue(input.Prefix)
+	var urls []string
+	for u := range s.objects {
+		if strings.HasPrefix(u, prefix) {
+			urls = append(urls, u)
+		}
+	}
+	sort.Strings(urls)
+	var output s3.ListObjectsV2Output
+	for _, u := range urls {
+		_, key := parseS3URL(u)
+		output.Contents = append(output.Contents, &s3.Object{Key: aws.String(key), Size: aws.Int64(s.objects[u])})
+	}
+	fn(&output, true)
+	return nil
+}
@@ -0,0 +1,166 @@
+package mediaconvert
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+)
+
+const apiPrefix = "/2017-08-29/"
+
+// mediaConvertServer is a fake implementation of the MediaConvert REST API,
+// supporting the operations used by the provider.
+type mediaConvertServer struct {
+	*httptest.Server
+
+	mtx       sync.Mutex
+	presets   map[string]*mediaconvert.Preset
+	jobs      map[string]*mediaconvert.Job
+	queues    map[string]*mediaconvert.Queue
+	createdID int
+}
+
+func newMediaConvertServer() *mediaConvertServer {
+	s := mediaConvertServer{
+		presets: make(map[string]*mediaconvert.Preset),
+		jobs:    make(map[string]*mediaconvert.Job),
+		queues: map[string]*mediaconvert.Queue{
+			"Default": {Name: aws.String("Default"), Status: aws.String(mediaconvert.QueueStatusActive)},
+		},
+	}
+	s.Server = httptest.NewServer(&s)
+	return &s
+}
+
+func (s *mediaConvertServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, apiPrefix), "/", 2)
+	resource := parts[0]
+	var id string
+	if len(parts) > 1 {
+		id = parts[1]
+	}
+	switch {
+	case resource == "presets" && id == "" && r.Method == http.MethodPost:
+		var input mediaconvert.CreatePresetInput
+		if err := jsonutil.UnmarshalJSON(&input, r.Body); err != nil {
+			s.error(w, http.StatusBadRequest, "BadRequestException", err.Error())
+			return
+		}
+		name := aws.StringValue(input.Name)
+		if _, ok := s.presets[name]; ok {
+			s.error(w, http.StatusConflict, "ConflictException", "preset already exists")
+			return
+		}
+		s.presets[name] = &mediaconvert.Preset{Name: input.Name, Description: input.Description, Settings: input.Settings}
+		s.respond(w, &mediaconvert.CreatePresetOutput{Preset: s.presets[name]})
+	case resource == "presets" && r.Method == http.MethodPut:
+		var input mediaconvert.UpdatePresetInput
+		if err := jsonutil.UnmarshalJSON(&input, r.Body); err != nil {
+			s.error(w, http.StatusBadRequest, "BadRequestException", err.Error())
+			return
+		}
+		preset, ok := s.presets[id]
+		if !ok {
+			s.error(w, http.StatusNotFound, "NotFoundException", "preset not found")
+			return
+		}
+		preset.Description = input.Description
+		preset.Settings = input.Settings
+		s.respond(w, &mediaconvert.UpdatePresetOutput{Preset: preset})
+	case resource == "presets" && r.Method == http.MethodGet:
+		preset, ok := s.presets[id]
+		if !ok {
+			s.error(w, http.StatusNotFound, "NotFoundException", "preset not found")
+			return
+		}
+		s.respond(w, &mediaconvert.GetPresetOutput{Preset: preset})
+	case resource == "presets" && r.Method == http.MethodDelete:
+		if _, ok := s.presets[id]; !ok {
+			s.error(w, http.StatusNotFound, "NotFoundException", "preset not found")
+			return
+		}
+		delete(s.presets, id)
+		s.respond(w, &mediaconvert.DeletePresetOutput{})
+	case resource == "jobs" && id == "" && r.Method == http.MethodPost:
+		var input mediaconvert.CreateJobInput
+		if err := jsonutil.UnmarshalJSON(&input, r.Body); err != nil {
+			s.error(w, http.StatusBadRequest, "BadRequestException", err.Error())
+			return
+		}
+		s.createdID++
+		job := mediaconvert.Job{
+			Id:           aws.String(fmt.Sprintf("job-%d", s.createdID)),
+			Queue:        input.Queue,
+			Role:         input.Role,
+			Settings:     input.Settings,
+			UserMetadata: input.UserMetadata,
+			Status:       aws.String(mediaconvert.JobStatusSubmitted),
+		}
+		s.jobs[*job.Id] = &job
+		s.respond(w, &mediaconvert.CreateJobOutput{Job: &job})
+	case resource == "jobs" && r.Method == http.MethodGet:
+		job, ok := s.jobs[id]
+		if !ok {
+			s.error(w, http.StatusNotFound, "NotFoundException", "job not found")
+			return
+		}
+		s.respond(w, &mediaconvert.GetJobOutput{Job: job})
+	case resource == "jobs" && r.Method == http.MethodDelete:
+		job, ok := s.jobs[id]
+		if !ok {
+			s.error(w, http.StatusNotFound, "NotFoundException", "job not found")
+			return
+		}
+		job.Status = aws.String(mediaconvert.JobStatusCanceled)
+		s.respond(w, &mediaconvert.CancelJobOutput{})
+	case resource == "queues" && r.Method == http.MethodGet:
+		queue, ok := s.queues[id]
+		if !ok {
+			s.error(w, http.StatusNotFound, "NotFoundException", "queue not found")
+			return
+		}
+		s.respond(w, &mediaconvert.GetQueueOutput{Queue: queue})
+	default:
+		s.error(w, http.StatusNotFound, "NotFoundException", "not found")
+	}
+}
+
+func (s *mediaConvertServer) respond(w http.ResponseWriter, v interface{}) {
+	body, err := jsonutil.BuildJSON(v)
+	if err != nil {
+		s.error(w, http.StatusInternalServerError, "InternalServerErrorException", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func (s *mediaConvertServer) error(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Amzn-Errortype", code)
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"message":%q}`, message)
+}
+
+// getJob returns the job with the given id, for inspection in tests.
+func (s *mediaConvertServer) getJob(id string) *mediaconvert.Job {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.jobs[id]
+}
+
+// updateJob changes the job with the given id, simulating the progress of
+// the job in MediaConvert.
+func (s *mediaConvertServer) updateJob(id string, fn func(*mediaconvert.Job)) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	fn(s.jobs[id])
+}
@@ -0,0 +1,998 @@
+// Package mediaconvert provides a implementation of the provider that uses
+// AWS Elemental MediaConvert for transcoding media files.
+//
+// It doesn't expose any public type. In order to use the provider, one must
+// import this package and then grab the factory from the provider package:
+//
+//	import (
+//	    "github.com/NYTimes/video-transcoding-api/provider"
+//	    "github.com/NYTimes/video-transcoding-api/provider/mediaconvert"
+//	)
+//
+//	func UseProvider() {
+//	    factory, err := provider.GetProviderFactory(mediaconvert.Name)
+//	    // handle err and use factory to get an instance of the provider.
+//	}
+//
+// Presets are stored as MediaConvert output presets, identified by their
+// names. Each non-streaming output is generated by its own file output group,
+// while HLS and DASH outputs are grouped in a single output group for each
+// protocol, named after the playlist of the job. MediaConvert names the
+// variant playlists after the master playlist, so the playlist of the output
+// "video_480p.m3u8" with the master playlist "hls/index.m3u8" is generated as
+// "hls/index_video_480p.m3u8".
+//
+// Thumbnails are captured by an additional file output group, using the
+// frame capture codec. MediaConvert doesn't report the captured images, so
+// they're listed from the S3 destination of the job once it's finished.
+package mediaconvert
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/aws/aws-sdk-go/service/mediaconvert/mediaconvertiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+const (
+	// Name is the name used for registering the MediaConvert provider in
+	// the registry of providers.
+	Name = "mediaconvert"
+
+	defaultAWSRegion       = "us-east-1"
+	defaultQueue           = "Default"
+	defaultSegmentDuration = 10
+	audioSelectorName      = "Audio Selector 1"
+	thumbnailsGroupName    = "Thumbnails"
+	thumbnailQuality       = 80
+)
+
+var (
+	errMediaConvertInvalidConfig = provider.InvalidConfigError("missing MediaConvert configuration. Please define the environment variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, MEDIACONVERT_ENDPOINT, MEDIACONVERT_ROLE_ARN and MEDIACONVERT_DESTINATION or set these values in the configuration file")
+	errMisconfiguredPreset       = errors.New("misconfigured preset: missing container settings")
+
+	containers = map[string]string{
+		"mp4":  mediaconvert.ContainerTypeMp4,
+		"webm": mediaconvert.ContainerTypeWebm,
+		"m3u8": mediaconvert.ContainerTypeM3u8,
+		"mpd":  mediaconvert.ContainerTypeMpd,
+	}
+	videoCodecs = map[string]string{
+		"h264": mediaconvert.VideoCodecH264,
+		"hevc": mediaconvert.VideoCodecH265,
+		"vp8":  mediaconvert.VideoCodecVp8,
+		"vp9":  mediaconvert.VideoCodecVp9,
+		"av1":  mediaconvert.VideoCodecAv1,
+	}
+	// videoCodecParameters lists the codec-specific parameters supported
+	// by each codec. MediaConvert has no CRF mode: its quality-defined
+	// mode (QVBR) uses a different scale.
+	videoCodecParameters = map[string][]string{
+		"h264": {"maxBitrate", "bufferSize", "bFrames", "referenceFrames", "twoPass", "frameRate"},
+		"hevc": {"tier", "bitDepth", "maxBitrate", "bufferSize", "bFrames", "referenceFrames", "twoPass", "frameRate"},
+		"vp8":  {"frameRate"},
+		"vp9":  {"maxBitrate", "bufferSize", "twoPass", "frameRate"},
+		"av1":  {"bitDepth", "maxBitrate", "bFrames", "frameRate"},
+	}
+	// audioParameters lists the optional audio parameters supported by
+	// MediaConvert. Additional audio tracks aren't supported, as jobs
+	// select a single audio track of the source.
+	audioParameters   = []string{"channels", "channelLayout", "sampleRate", "language", "loudness", "audioOnly"}
+	presetConstraints = provider.PresetConstraints{
+		Containers:      []string{"mp4", "webm", "m3u8", "mpd"},
+		AudioCodecs:     []string{"aac", "mp3", "opus", "vorbis"},
+		VideoCodecs:     videoCodecParameters,
+		AudioParameters: audioParameters,
+	}
+	audioCodecs = map[string]string{
+		"aac":    mediaconvert.AudioCodecAac,
+		"mp3":    mediaconvert.AudioCodecMp3,
+		"opus":   mediaconvert.AudioCodecOpus,
+		"vorbis": mediaconvert.AudioCodecVorbis,
+	}
+
+	// aacCodingModes maps numbers of channels to the AAC coding modes.
+	aacCodingModes = map[string]string{
+		"1": mediaconvert.AacCodingModeCodingMode10,
+		"2": mediaconvert.AacCodingModeCodingMode20,
+		"6": mediaconvert.AacCodingModeCodingMode51,
+	}
+	layoutChannels = map[string]string{"mono": "1", "stereo": "2", "5.1": "6", "7.1": "8"}
+
+	// thumbnailCapabilities describes the thumbnails captured by
+	// MediaConvert: JPEG images at a regular interval.
+	thumbnailCapabilities = provider.ThumbnailCapabilities{Formats: []string{"jpg"}}
+)
+
+func init() {
+	provider.Register(Name, mediaConvertFactory)
+}
+
+type mcProvider struct {
+	c      mediaconvertiface.MediaConvertAPI
+	s3     s3iface.S3API
+	config *config.MediaConvert
+}
+
+func (p *mcProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
+	destination := p.destination(job)
+	var outputGroups []*mediaconvert.OutputGroup
+	var hlsOutputs, dashOutputs []*mediaconvert.Output
+	for _, output := range job.Outputs {
+		presetName, ok := output.Preset.ProviderMapping[Name]
+		if !ok {
+			return nil, provider.ErrPresetMapNotFound
+		}
+		preset, err := p.getPreset(presetName)
+		if err != nil {
+			return nil, err
+		}
+		fileName := strings.TrimSuffix(output.FileName, path.Ext(output.FileName))
+		switch aws.StringValue(preset.Settings.ContainerSettings.Container) {
+		case mediaconvert.ContainerTypeM3u8:
+			hlsOutputs = append(hlsOutputs, &mediaconvert.Output{
+				Preset:       aws.String(presetName),
+				NameModifier: aws.String("_" + path.Base(fileName)),
+			})
+		case mediaconvert.ContainerTypeMpd:
+			dashOutputs = append(dashOutputs, &mediaconvert.Output{
+				Preset:       aws.String(presetName),
+				NameModifier: aws.String("_" + path.Base(fileName)),
+			})
+		default:
+			outputGroups = append(outputGroups, &mediaconvert.OutputGroup{
+				Name: aws.String("File Group"),
+				OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+					Type:              aws.String(mediaconvert.OutputGroupTypeFileGroupSettings),
+					FileGroupSettings: &mediaconvert.FileGroupSettings{Destination: aws.String(destination + fileName)},
+				},
+				Outputs: []*mediaconvert.Output{{
+					Preset:    aws.String(presetName),
+					Extension: aws.String(strings.TrimPrefix(path.Ext(output.FileName), ".")),
+				}},
+			})
+		}
+	}
+	segmentDuration := int64(job.StreamingParams.SegmentDuration)
+	if segmentDuration == 0 {
+		segmentDuration = defaultSegmentDuration
+	}
+	if len(hlsOutputs) > 0 {
+		manifest := manifestName(job.StreamingParams, "hls", "hls/index.m3u8")
+		outputGroups = append(outputGroups, &mediaconvert.OutputGroup{
+			Name: aws.String("Apple HLS"),
+			OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+				Type: aws.String(mediaconvert.OutputGroupTypeHlsGroupSettings),
+				HlsGroupSettings: &mediaconvert.HlsGroupSettings{
+					Destination:      aws.String(destination + manifest),
+					SegmentLength:    aws.Int64(segmentDuration),
+					MinSegmentLength: aws.Int64(0),
+				},
+			},
+			Outputs: hlsOutputs,
+		})
+	}
+	if len(dashOutputs) > 0 {
+		manifest := manifestName(job.StreamingParams, "dash", "dash/index.mpd")
+		outputGroups = append(outputGroups, &mediaconvert.OutputGroup{
+			Name: aws.String("DASH ISO"),
+			OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+				Type: aws.String(mediaconvert.OutputGroupTypeDashIsoGroupSettings),
+				DashIsoGroupSettings: &mediaconvert.DashIsoGroupSettings{
+					Destination:    aws.String(destination + manifest),
+					SegmentLength:  aws.Int64(segmentDuration),
+					FragmentLength: aws.Int64(segmentDuration),
+				},
+			},
+			Outputs: dashOutputs,
+		})
+	}
+	if job.Thumbnails != nil {
+		outputGroups = append(outputGroups, thumbnailsGroup(destination, job.Thumbnails))
+	}
+	input := mediaconvert.Input{
+		FileInput: aws.String(job.SourceMedia),
+		AudioSelectors: map[string]*mediaconvert.AudioSelector{
+			audioSelectorName: {DefaultSelection: aws.String(mediaconvert.AudioDefaultSelectionDefault)},
+		},
+	}
+	if job.Clip != nil {
+		input.TimecodeSource = aws.String(mediaconvert.InputTimecodeSourceZerobased)
+		input.InputClippings = []*mediaconvert.InputClipping{inputClipping(job.Clip)}
+	}
+	params := mediaconvert.CreateJobInput{
+		Role: aws.String(p.config.Role),
+		Settings: &mediaconvert.JobSettings{
+			Inputs:       []*mediaconvert.Input{&input},
+			OutputGroups: outputGroups,
+		},
+		UserMetadata: map[string]*string{"jobID": aws.String(job.ID)},
+	}
+	if p.config.Queue != "" {
+		params.Queue = aws.String(p.config.Queue)
+	}
+	resp, err := p.c.CreateJob(&params)
+	if err != nil {
+		return nil, err
+	}
+	return &provider.JobStatus{
+		ProviderName:  Name,
+		ProviderJobID: aws.StringValue(resp.Job.Id),
+		Status:        provider.StatusQueued,
+	}, nil
+}
+
+// thumbnailsGroup returns the output group that captures the thumbnails of
+// the job, named "thumbnail.0000000.jpg", "thumbnail.0000001.jpg" and so on
+// by MediaConvert.
+func thumbnailsGroup(destination string, thumbnails *db.Thumbnails) *mediaconvert.OutputGroup {
+	video := mediaconvert.VideoDescription{
+		CodecSettings: &mediaconvert.VideoCodecSettings{
+			Codec: aws.String(mediaconvert.VideoCodecFrameCapture),
+			FrameCaptureSettings: &mediaconvert.FrameCaptureSettings{
+				FramerateNumerator:   aws.Int64(1),
+				FramerateDenominator: aws.Int64(int64(thumbnails.Interval)),
+				Quality:              aws.Int64(thumbnailQuality),
+			},
+		},
+	}
+	if thumbnails.Width > 0 {
+		video.Width = aws.Int64(int64(thumbnails.Width))
+	}
+	if thumbnails.Height > 0 {
+		video.Height = aws.Int64(int64(thumbnails.Height))
+	}
+	return &mediaconvert.OutputGroup{
+		Name: aws.String(thumbnailsGroupName),
+		OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+			Type: aws.String(mediaconvert.OutputGroupTypeFileGroupSettings),
+			FileGroupSettings: &mediaconvert.FileGroupSettings{
+				Destination: aws.String(destination + strings.Trim(thumbnails.Path, "/") + "/thumbnail"),
+			},
+		},
+		Outputs: []*mediaconvert.Output{{
+			ContainerSettings: &mediaconvert.ContainerSettings{Container: aws.String(mediaconvert.ContainerTypeRaw)},
+			VideoDescription:  &video,
+			Extension:         aws.String("jpg"),
+		}},
+	}
+}
+
+func (p *mcProvider) destination(job *db.Job) string {
+	return strings.TrimRight(provider.JobDestination(job, p.config.Destination), "/") + "/" + job.ID + "/"
+}
+
+// manifestName returns the name of the manifest used for the given streaming
+// protocol, without extension.
+func manifestName(params db.StreamingParams, protocol, defaultName string) string {
+	name := defaultName
+	if params.Protocol == protocol && params.PlaylistFileName != "" {
+		name = params.PlaylistFileName
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+func (p *mcProvider) getPreset(name string) (*mediaconvert.Preset, error) {
+	resp, err := p.c.GetPreset(&mediaconvert.GetPresetInput{Name: aws.String(name)})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Preset == nil || resp.Preset.Settings == nil || resp.Preset.Settings.ContainerSettings == nil {
+		return nil, errMisconfiguredPreset
+	}
+	return resp.Preset, nil
+}
+
+func (p *mcProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
+	resp, err := p.c.GetJob(&mediaconvert.GetJobInput{Id: aws.String(job.ProviderJobID)})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, provider.JobNotFoundError{ID: job.ProviderJobID}
+		}
+		return nil, err
+	}
+	mcJob := resp.Job
+	status := provider.JobStatus{
+		ProviderName:  Name,
+		ProviderJobID: aws.StringValue(mcJob.Id),
+		Status:        statusMap(aws.StringValue(mcJob.Status)),
+		StatusMessage: aws.StringValue(mcJob.ErrorMessage),
+		Progress:      float64(aws.Int64Value(mcJob.JobPercentComplete)),
+		ProviderStatus: map[string]interface{}{
+			"status":       aws.StringValue(mcJob.Status),
+			"currentPhase": aws.StringValue(mcJob.CurrentPhase),
+			"errorCode":    aws.Int64Value(mcJob.ErrorCode),
+		},
+		Output: provider.JobOutput{Destination: p.destination(job)},
+	}
+	if status.Status != provider.StatusFinished {
+		return &status, nil
+	}
+	status.Progress = 100
+	status.Output.Files, err = p.outputFiles(mcJob)
+	if err != nil {
+		return nil, err
+	}
+	status.Output.Thumbnails, err = p.thumbnailFiles(mcJob)
+	if err != nil {
+		return nil, err
+	}
+	for _, groupDetail := range mcJob.OutputGroupDetails {
+		if len(groupDetail.OutputDetails) > 0 {
+			status.SourceInfo.Duration = time.Duration(aws.Int64Value(groupDetail.OutputDetails[0].DurationInMs)) * time.Millisecond
+			break
+		}
+	}
+	return &status, nil
+}
+
+// outputFiles returns the files generated by the given job, based on the
+// output groups of the job and the details reported by MediaConvert.
+func (p *mcProvider) outputFiles(mcJob *mediaconvert.Job) ([]provider.OutputFile, error) {
+	if mcJob.Settings == nil {
+		return nil, nil
+	}
+	var files []provider.OutputFile
+	for i, group := range mcJob.Settings.OutputGroups {
+		var details []*mediaconvert.OutputDetail
+		if i < len(mcJob.OutputGroupDetails) {
+			details = mcJob.OutputGroupDetails[i].OutputDetails
+		}
+		if isThumbnailsGroup(group) {
+			continue
+		}
+		settings := group.OutputGroupSettings
+		switch aws.StringValue(settings.Type) {
+		case mediaconvert.OutputGroupTypeHlsGroupSettings:
+			destination := aws.StringValue(settings.HlsGroupSettings.Destination)
+			for j, output := range group.Outputs {
+				file, err := p.outputFile(destination+aws.StringValue(output.NameModifier)+".m3u8", output, details, j)
+				if err != nil {
+					return nil, err
+				}
+				files = append(files, file)
+			}
+			files = append(files, provider.OutputFile{Path: destination + ".m3u8", Container: "m3u8"})
+		case mediaconvert.OutputGroupTypeDashIsoGroupSettings:
+			destination := aws.StringValue(settings.DashIsoGroupSettings.Destination)
+			files = append(files, provider.OutputFile{Path: destination + ".mpd", Container: "mpd"})
+		case mediaconvert.OutputGroupTypeFileGroupSettings:
+			destination := aws.StringValue(settings.FileGroupSettings.Destination)
+			for j, output := range group.Outputs {
+				file, err := p.outputFile(destination+"."+aws.StringValue(output.Extension), output, details, j)
+				if err != nil {
+					return nil, err
+				}
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+// thumbnailFiles returns the images captured by the thumbnails output group
+// of the given job, listing them from S3.
+func (p *mcProvider) thumbnailFiles(mcJob *mediaconvert.Job) ([]provider.OutputFile, error) {
+	if mcJob.Settings == nil {
+		return nil, nil
+	}
+	for i, group := range mcJob.Settings.OutputGroups {
+		if !isThumbnailsGroup(group) {
+			continue
+		}
+		var width, height int64
+		if i < len(mcJob.OutputGroupDetails) {
+			if details := mcJob.OutputGroupDetails[i].OutputDetails; len(details) > 0 && details[0].VideoDetails != nil {
+				width = aws.Int64Value(details[0].VideoDetails.WidthInPx)
+				height = aws.Int64Value(details[0].VideoDetails.HeightInPx)
+			}
+		}
+		destination := aws.StringValue(group.OutputGroupSettings.FileGroupSettings.Destination)
+		bucket, key := parseS3URL(destination)
+		var files []provider.OutputFile
+		err := p.s3.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+			Bucket: aws.String(bucket),
+			Prefix: aws.String(key),
+		}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, object := range page.Contents {
+				files = append(files, provider.OutputFile{
+					Path:      "s3://" + bucket + "/" + aws.StringValue(object.Key),
+					Container: "jpg",
+					Width:     width,
+					Height:    height,
+					FileSize:  aws.Int64Value(object.Size),
+				})
+			}
+			return true
+		})
+		return files, err
+	}
+	return nil, nil
+}
+
+func isThumbnailsGroup(group *mediaconvert.OutputGroup) bool {
+	return aws.StringValue(group.Name) == thumbnailsGroupName
+}
+
+// parseS3URL splits the given S3 URL (s3://bucket/key) in bucket and key.
+func parseS3URL(s3URL string) (bucket, key string) {
+	parts := strings.SplitN(strings.TrimPrefix(s3URL, "s3://"), "/", 2)
+	if len(parts) > 1 {
+		key = parts[1]
+	}
+	return parts[0], key
+}
+
+func (p *mcProvider) outputFile(filePath string, output *mediaconvert.Output, details []*mediaconvert.OutputDetail, index int) (provider.OutputFile, error) {
+	preset, err := p.getPreset(aws.StringValue(output.Preset))
+	if err != nil {
+		return provider.OutputFile{}, err
+	}
+	file := provider.OutputFile{
+		Path:      filePath,
+		Container: lookup(containers, aws.StringValue(preset.Settings.ContainerSettings.Container)),
+	}
+	if video := preset.Settings.VideoDescription; video != nil && video.CodecSettings != nil {
+		file.VideoCodec = lookup(videoCodecs, aws.StringValue(video.CodecSettings.Codec))
+	}
+	if index < len(details) && details[index].VideoDetails != nil {
+		file.Width = aws.Int64Value(details[index].VideoDetails.WidthInPx)
+		file.Height = aws.Int64Value(details[index].VideoDetails.HeightInPx)
+	}
+	return file, nil
+}
+
+// lookup returns the key of the given value in the map, used for translating
+// MediaConvert values back to the values used in the API.
+func lookup(m map[string]string, value string) string {
+	for k, v := range m {
+		if v == value {
+			return k
+		}
+	}
+	return strings.ToLower(value)
+}
+
+func statusMap(mcStatus string) provider.Status {
+	switch mcStatus {
+	case mediaconvert.JobStatusSubmitted:
+		return provider.StatusQueued
+	case mediaconvert.JobStatusProgressing:
+		return provider.StatusStarted
+	case mediaconvert.JobStatusComplete:
+		return provider.StatusFinished
+	case mediaconvert.JobStatusCanceled:
+		return provider.StatusCanceled
+	default:
+		return provider.StatusFailed
+	}
+}
+
+func isNotFound(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == mediaconvert.ErrCodeNotFoundException
+}
+
+func (p *mcProvider) CancelJob(id string) error {
+	_, err := p.c.CancelJob(&mediaconvert.CancelJobInput{Id: aws.String(id)})
+	if err != nil && isNotFound(err) {
+		return provider.JobNotFoundError{ID: id}
+	}
+	return err
+}
+
+func (p *mcProvider) Healthcheck() error {
+	queue := defaultQueue
+	if p.config.Queue != "" {
+		parts := strings.Split(p.config.Queue, "/")
+		queue = parts[len(parts)-1]
+	}
+	_, err := p.c.GetQueue(&mediaconvert.GetQueueInput{Name: aws.String(queue)})
+	return err
+}
+
+func (p *mcProvider) CreatePreset(preset db.Preset) (string, error) {
+	settings, err := presetSettings(preset)
+	if err != nil {
+		return "", err
+	}
+	input := mediaconvert.CreatePresetInput{
+		Name:     aws.String(preset.Name),
+		Settings: settings,
+	}
+	if preset.Description != "" {
+		input.Description = aws.String(preset.Description)
+	}
+	resp, err := p.c.CreatePreset(&input)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(resp.Preset.Name), nil
+}
+
+func (p *mcProvider) UpdatePreset(presetID string, preset db.Preset) error {
+	settings, err := presetSettings(preset)
+	if err != nil {
+		return err
+	}
+	input := mediaconvert.UpdatePresetInput{
+		Name:     aws.String(presetID),
+		Settings: settings,
+	}
+	if preset.Description != "" {
+		input.Description = aws.String(preset.Description)
+	}
+	_, err = p.c.UpdatePreset(&input)
+	return err
+}
+
+// presetSettings translates the given preset to the settings of a
+// MediaConvert output preset.
+func presetSettings(preset db.Preset) (*mediaconvert.PresetSettings, error) {
+	container, ok := containers[preset.Container]
+	if !ok {
+		return nil, fmt.Errorf("unsupported container %q", preset.Container)
+	}
+	if err := provider.CheckAudioParameters(preset, audioParameters...); err != nil {
+		return nil, err
+	}
+	audio, err := audioDescription(preset.Audio)
+	if err != nil {
+		return nil, err
+	}
+	settings := mediaconvert.PresetSettings{
+		ContainerSettings: &mediaconvert.ContainerSettings{Container: aws.String(container)},
+		AudioDescriptions: []*mediaconvert.AudioDescription{audio},
+	}
+	if !preset.AudioOnly() {
+		if settings.VideoDescription, err = videoDescription(preset); err != nil {
+			return nil, err
+		}
+	}
+	return &settings, nil
+}
+
+func videoDescription(preset db.Preset) (*mediaconvert.VideoDescription, error) {
+	codec, ok := videoCodecs[preset.Video.Codec]
+	if !ok {
+		return nil, provider.UnsupportedParameterError{Parameter: "video.codec", Value: preset.Video.Codec}
+	}
+	if err := provider.CheckVideoParameters(preset.Video, videoCodecParameters[preset.Video.Codec]...); err != nil {
+		return nil, err
+	}
+	bitrate, err := parseInt(preset.Video.Bitrate, "video bitrate")
+	if err != nil {
+		return nil, err
+	}
+	params, err := parseCodecParameters(preset.Video)
+	if err != nil {
+		return nil, err
+	}
+	var gopSize *float64
+	if preset.Video.GopSize != "" {
+		size, err := strconv.ParseFloat(preset.Video.GopSize, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GOP size %q", preset.Video.GopSize)
+		}
+		gopSize = aws.Float64(size)
+	}
+	description := mediaconvert.VideoDescription{
+		CodecSettings: &mediaconvert.VideoCodecSettings{Codec: aws.String(codec)},
+	}
+	if description.Width, err = parseInt(preset.Video.Width, "width"); err != nil {
+		return nil, err
+	}
+	if description.Height, err = parseInt(preset.Video.Height, "height"); err != nil {
+		return nil, err
+	}
+	switch codec {
+	case mediaconvert.VideoCodecH264:
+		settings := mediaconvert.H264Settings{
+			Bitrate:         bitrate,
+			RateControlMode: aws.String(mediaconvert.H264RateControlModeVbr),
+			GopSize:         gopSize,
+			CodecLevel:      aws.String(mediaconvert.H264CodecLevelAuto),
+		}
+		if gopSize != nil {
+			settings.GopSizeUnits = aws.String(mediaconvert.H264GopSizeUnitsFrames)
+		}
+		if strings.ToUpper(preset.RateControl) == "CBR" {
+			settings.RateControlMode = aws.String(mediaconvert.H264RateControlModeCbr)
+		}
+		if preset.Video.Profile != "" {
+			settings.CodecProfile = aws.String(strings.ToUpper(preset.Video.Profile))
+		}
+		if preset.Video.ProfileLevel != "" {
+			settings.CodecLevel = aws.String("LEVEL_" + strings.Replace(preset.Video.ProfileLevel, ".", "_", -1))
+		}
+		if preset.Video.GopMode == "fixed" {
+			settings.SceneChangeDetect = aws.String(mediaconvert.H264SceneChangeDetectDisabled)
+		}
+		if preset.Video.InterlaceMode == "progressive" {
+			settings.InterlaceMode = aws.String(mediaconvert.H264InterlaceModeProgressive)
+		}
+		settings.MaxBitrate = params.maxBitrate
+		settings.HrdBufferSize = params.bufferSize
+		settings.NumberBFramesBetweenReferenceFrames = params.bFrames
+		settings.NumberReferenceFrames = params.referenceFrames
+		if preset.Video.TwoPass {
+			settings.QualityTuningLevel = aws.String(mediaconvert.H264QualityTuningLevelMultiPassHq)
+		}
+		if params.frameRateNumerator != nil {
+			settings.FramerateControl = aws.String(mediaconvert.H264FramerateControlSpecified)
+			settings.FramerateNumerator = params.frameRateNumerator
+			settings.FramerateDenominator = params.frameRateDenominator
+		}
+		description.CodecSettings.H264Settings = &settings
+	case mediaconvert.VideoCodecH265:
+		profile, err := h265Profile(preset.Video)
+		if err != nil {
+			return nil, err
+		}
+		settings := mediaconvert.H265Settings{
+			Bitrate:                             bitrate,
+			RateControlMode:                     aws.String(mediaconvert.H265RateControlModeVbr),
+			GopSize:                             gopSize,
+			CodecProfile:                        aws.String(profile),
+			CodecLevel:                          aws.String(mediaconvert.H265CodecLevelAuto),
+			MaxBitrate:                          params.maxBitrate,
+			HrdBufferSize:                       params.bufferSize,
+			NumberBFramesBetweenReferenceFrames: params.bFrames,
+			NumberReferenceFrames:               params.referenceFrames,
+		}
+		if gopSize != nil {
+			settings.GopSizeUnits = aws.String(mediaconvert.H265GopSizeUnitsFrames)
+		}
+		if strings.ToUpper(preset.RateControl) == "CBR" {
+			settings.RateControlMode = aws.String(mediaconvert.H265RateControlModeCbr)
+		}
+		if preset.Video.ProfileLevel != "" {
+			settings.CodecLevel = aws.String("LEVEL_" + strings.Replace(preset.Video.ProfileLevel, ".", "_", -1))
+		}
+		if preset.Video.GopMode == "fixed" {
+			settings.SceneChangeDetect = aws.String(mediaconvert.H265SceneChangeDetectDisabled)
+		}
+		if preset.Video.InterlaceMode == "progressive" {
+			settings.InterlaceMode = aws.String(mediaconvert.H265InterlaceModeProgressive)
+		}
+		if preset.Video.TwoPass {
+			settings.QualityTuningLevel = aws.String(mediaconvert.H265QualityTuningLevelMultiPassHq)
+		}
+		if params.frameRateNumerator != nil {
+			settings.FramerateControl = aws.String(mediaconvert.H265FramerateControlSpecified)
+			settings.FramerateNumerator = params.frameRateNumerator
+			settings.FramerateDenominator = params.frameRateDenominator
+		}
+		description.CodecSettings.H265Settings = &settings
+	case mediaconvert.VideoCodecVp8:
+		settings := mediaconvert.Vp8Settings{
+			Bitrate:         bitrate,
+			RateControlMode: aws.String(mediaconvert.Vp8RateControlModeVbr),
+			GopSize:         gopSize,
+		}
+		if params.frameRateNumerator != nil {
+			settings.FramerateControl = aws.String(mediaconvert.Vp8FramerateControlSpecified)
+			settings.FramerateNumerator = params.frameRateNumerator
+			settings.FramerateDenominator = params.frameRateDenominator
+		}
+		description.CodecSettings.Vp8Settings = &settings
+	case mediaconvert.VideoCodecVp9:
+		settings := mediaconvert.Vp9Settings{
+			Bitrate:         bitrate,
+			RateControlMode: aws.String(mediaconvert.Vp9RateControlModeVbr),
+			GopSize:         gopSize,
+			MaxBitrate:      params.maxBitrate,
+			HrdBufferSize:   params.bufferSize,
+		}
+		if preset.Video.TwoPass {
+			settings.QualityTuningLevel = aws.String(mediaconvert.Vp9QualityTuningLevelMultiPassHq)
+		}
+		if params.frameRateNumerator != nil {
+			settings.FramerateControl = aws.String(mediaconvert.Vp9FramerateControlSpecified)
+			settings.FramerateNumerator = params.frameRateNumerator
+			settings.FramerateDenominator = params.frameRateDenominator
+		}
+		description.CodecSettings.Vp9Settings = &settings
+	case mediaconvert.VideoCodecAv1:
+		bitDepth := mediaconvert.Av1BitDepthBit8
+		switch preset.Video.BitDepth {
+		case "", "8":
+		case "10":
+			bitDepth = mediaconvert.Av1BitDepthBit10
+		default:
+			return nil, provider.UnsupportedParameterError{Parameter: "video.bitDepth", Value: preset.Video.BitDepth}
+		}
+		// AV1 is only encoded in quality-defined mode, limited by
+		// the max bitrate, which defaults to the bitrate of the
+		// preset.
+		maxBitrate := params.maxBitrate
+		if maxBitrate == nil {
+			maxBitrate = bitrate
+		}
+		settings := mediaconvert.Av1Settings{
+			RateControlMode:                     aws.String(mediaconvert.Av1RateControlModeQvbr),
+			GopSize:                             gopSize,
+			BitDepth:                            aws.String(bitDepth),
+			MaxBitrate:                          maxBitrate,
+			NumberBFramesBetweenReferenceFrames: params.bFrames,
+		}
+		if params.frameRateNumerator != nil {
+			settings.FramerateControl = aws.String(mediaconvert.Av1FramerateControlSpecified)
+			settings.FramerateNumerator = params.frameRateNumerator
+			settings.FramerateDenominator = params.frameRateDenominator
+		}
+		description.CodecSettings.Av1Settings = &settings
+	}
+	return &description, nil
+}
+
+// codecParameters holds the numeric codec-specific parameters of a preset.
+// The frame rate is given as a fraction, which is nil when the frame rate of
+// the source is kept.
+type codecParameters struct {
+	maxBitrate           *int64
+	bufferSize           *int64
+	bFrames              *int64
+	referenceFrames      *int64
+	frameRateNumerator   *int64
+	frameRateDenominator *int64
+}
+
+func parseCodecParameters(video db.VideoPreset) (params codecParameters, err error) {
+	if params.maxBitrate, err = parseInt(video.MaxBitrate, "max bitrate"); err != nil {
+		return params, err
+	}
+	if params.bufferSize, err = parseInt(video.BufferSize, "buffer size"); err != nil {
+		return params, err
+	}
+	if params.bFrames, err = parseInt(video.BFrames, "B-frames"); err != nil {
+		return params, err
+	}
+	if params.referenceFrames, err = parseInt(video.ReferenceFrames, "reference frames"); err != nil {
+		return params, err
+	}
+	params.frameRateNumerator, params.frameRateDenominator, err = parseFrameRate(video.FrameRate)
+	return params, err
+}
+
+// parseFrameRate translates the given frame rate to the fraction used by
+// MediaConvert. NTSC rates (e.g.: 29.97) are mapped to their exact fractions
+// (e.g.: 30000/1001), other fractional rates to thousandths.
+func parseFrameRate(value string) (numerator, denominator *int64, err error) {
+	if value == "" {
+		return nil, nil, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate <= 0 {
+		return nil, nil, fmt.Errorf("invalid frame rate %q", value)
+	}
+	if rate == math.Trunc(rate) {
+		return aws.Int64(int64(rate)), aws.Int64(1), nil
+	}
+	if ntsc := math.Round(rate * 1001 / 1000); math.Abs(ntsc*1000/1001-rate) < 0.01 {
+		return aws.Int64(int64(ntsc) * 1000), aws.Int64(1001), nil
+	}
+	return aws.Int64(int64(math.Round(rate * 1000))), aws.Int64(1000), nil
+}
+
+// h265Profile returns the H.265 profile for the given preset. MediaConvert
+// combines the profile, the bit depth and the tier in a single setting
+// (e.g.: MAIN10_HIGH).
+func h265Profile(video db.VideoPreset) (string, error) {
+	profile := "MAIN"
+	switch strings.ToLower(video.Profile) {
+	case "", "main":
+	case "main10":
+		profile = "MAIN10"
+	default:
+		return "", provider.UnsupportedParameterError{Parameter: "video.profile", Value: video.Profile}
+	}
+	switch video.BitDepth {
+	case "", "8":
+	case "10":
+		profile = "MAIN10"
+	default:
+		return "", provider.UnsupportedParameterError{Parameter: "video.bitDepth", Value: video.BitDepth}
+	}
+	switch strings.ToLower(video.Tier) {
+	case "", "main":
+		return profile + "_MAIN", nil
+	case "high":
+		return profile + "_HIGH", nil
+	default:
+		return "", provider.UnsupportedParameterError{Parameter: "video.tier", Value: video.Tier}
+	}
+}
+
+func audioDescription(audio db.AudioPreset) (*mediaconvert.AudioDescription, error) {
+	codec, ok := audioCodecs[audio.Codec]
+	if !ok {
+		return nil, fmt.Errorf("unsupported audio codec %q", audio.Codec)
+	}
+	bitrate, err := parseInt(audio.Bitrate, "audio bitrate")
+	if err != nil {
+		return nil, err
+	}
+	sampleRate, err := parseInt(audio.SampleRate, "audio sample rate")
+	if err != nil {
+		return nil, err
+	}
+	channels, err := audioChannels(audio)
+	if err != nil {
+		return nil, err
+	}
+	settings := mediaconvert.AudioCodecSettings{Codec: aws.String(codec)}
+	switch codec {
+	case mediaconvert.AudioCodecAac:
+		codingMode := mediaconvert.AacCodingModeCodingMode20
+		if channels != "" {
+			if codingMode, ok = aacCodingModes[channels]; !ok {
+				return nil, unsupportedChannels(audio)
+			}
+		}
+		if sampleRate == nil {
+			sampleRate = aws.Int64(48000)
+		}
+		settings.AacSettings = &mediaconvert.AacSettings{
+			Bitrate:    bitrate,
+			CodingMode: aws.String(codingMode),
+			SampleRate: sampleRate,
+		}
+	default:
+		// MP3, Opus and Vorbis outputs are either mono or stereo.
+		var numChannels *int64
+		switch channels {
+		case "":
+		case "1", "2":
+			numChannels, _ = parseInt(channels, "audio channels")
+		default:
+			return nil, unsupportedChannels(audio)
+		}
+		switch codec {
+		case mediaconvert.AudioCodecMp3:
+			settings.Mp3Settings = &mediaconvert.Mp3Settings{Bitrate: bitrate, Channels: numChannels, SampleRate: sampleRate}
+		case mediaconvert.AudioCodecOpus:
+			settings.OpusSettings = &mediaconvert.OpusSettings{Bitrate: bitrate, Channels: numChannels, SampleRate: sampleRate}
+		case mediaconvert.AudioCodecVorbis:
+			// Vorbis is always encoded with variable bitrate, so the
+			// bitrate of the preset is ignored.
+			settings.VorbisSettings = &mediaconvert.VorbisSettings{Channels: numChannels, SampleRate: sampleRate}
+		}
+	}
+	description := mediaconvert.AudioDescription{
+		AudioSourceName: aws.String(audioSelectorName),
+		CodecSettings:   &settings,
+	}
+	if audio.Language != "" {
+		description.CustomLanguageCode = aws.String(audio.Language)
+		description.LanguageCodeControl = aws.String(mediaconvert.AudioLanguageCodeControlUseConfigured)
+	}
+	if audio.Loudness != "" {
+		loudness, err := strconv.ParseFloat(audio.Loudness, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid loudness %q", audio.Loudness)
+		}
+		description.AudioNormalizationSettings = &mediaconvert.AudioNormalizationSettings{
+			Algorithm:        aws.String(mediaconvert.AudioNormalizationAlgorithmItuBs17702),
+			AlgorithmControl: aws.String(mediaconvert.AudioNormalizationAlgorithmControlCorrectAudio),
+			TargetLkfs:       aws.Float64(loudness),
+		}
+	}
+	return &description, nil
+}
+
+// audioChannels returns the number of channels of the given audio preset,
+// derived from the channel layout when the number of channels isn't
+// defined.
+func audioChannels(audio db.AudioPreset) (string, error) {
+	if audio.Channels != "" || audio.ChannelLayout == "" {
+		return audio.Channels, nil
+	}
+	channels, ok := layoutChannels[audio.ChannelLayout]
+	if !ok {
+		return "", provider.UnsupportedParameterError{Parameter: "audio.channelLayout", Value: audio.ChannelLayout}
+	}
+	return channels, nil
+}
+
+func unsupportedChannels(audio db.AudioPreset) error {
+	if audio.ChannelLayout != "" {
+		return provider.UnsupportedParameterError{Parameter: "audio.channelLayout", Value: audio.ChannelLayout}
+	}
+	return provider.UnsupportedParameterError{Parameter: "audio.channels", Value: audio.Channels}
+}
+
+// parseInt parses the given integer parameter of a preset, returning nil if
+// the parameter is not defined.
+func parseInt(value, name string) (*int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return aws.Int64(n), nil
+}
+
+func (p *mcProvider) GetPreset(presetID string) (interface{}, error) {
+	resp, err := p.c.GetPreset(&mediaconvert.GetPresetInput{Name: aws.String(presetID)})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (p *mcProvider) DeletePreset(presetID string) error {
+	_, err := p.c.DeletePreset(&mediaconvert.DeletePresetInput{Name: aws.String(presetID)})
+	return err
+}
+
+// inputClipping returns the clipping of the input for the given clip.
+// MediaConvert timecodes count frames, and the frame rate of the source isn't
+// known, so the clip is widened to whole seconds: the start is rounded down
+// and the end is rounded up.
+func inputClipping(clip *db.Clip) *mediaconvert.InputClipping {
+	var clipping mediaconvert.InputClipping
+	if clip.Start != "" {
+		clipping.StartTimecode = aws.String(timecode(clip.StartTime() / time.Second))
+	}
+	if clip.End != "" {
+		clipping.EndTimecode = aws.String(timecode((clip.EndTime() + time.Second - 1) / time.Second))
+	}
+	return &clipping
+}
+
+// timecode formats the given number of seconds as a HH:MM:SS:FF timecode.
+func timecode(seconds time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d:00", seconds/3600, seconds/60%60, seconds%60)
+}
+
+func (p *mcProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		InputFormats:  []string{"prores", "h264"},
+		OutputFormats: []string{"mp4", "hls", "dash", "webm"},
+		Destinations:  []string{"s3"},
+
+		PresetConstraints: &presetConstraints,
+		Thumbnails:        &thumbnailCapabilities,
+		Clipping:          &provider.ClippingCapabilities{},
+	}
+}
+
+func mediaConvertFactory(cfg *config.Config) (provider.TranscodingProvider, error) {
+	mcConfig := cfg.MediaConvert
+	if mcConfig == nil || mcConfig.AccessKeyID == "" || mcConfig.SecretAccessKey == "" ||
+		mcConfig.Endpoint == "" || mcConfig.Role == "" || mcConfig.Destination == "" {
+		return nil, errMediaConvertInvalidConfig
+	}
+	creds := credentials.NewStaticCredentials(mcConfig.AccessKeyID, mcConfig.SecretAccessKey, "")
+	region := mcConfig.Region
+	if region == "" {
+		region = defaultAWSRegion
+	}
+	awsSession, err := session.NewSession(aws.NewConfig().WithCredentials(creds).WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	return &mcProvider{
+		c:      mediaconvert.New(awsSession, aws.NewConfig().WithEndpoint(mcConfig.Endpoint)),
+		s3:     s3.New(awsSession),
+		config: mcConfig,
+	}, nil
+}
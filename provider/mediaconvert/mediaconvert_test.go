@@ -0,0 +1,1007 @@
+package mediaconvert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var testPresets = []db.Preset{
+	{
+		Name:        "mp4_1080p",
+		Description: "MP4 1080p",
+		Container:   "mp4",
+		RateControl: "CBR",
+		Video: db.VideoPreset{
+			Profile:       "Main",
+			ProfileLevel:  "3.1",
+			Width:         "1920",
+			Height:        "1080",
+			Codec:         "h264",
+			Bitrate:       "3500000",
+			GopSize:       "90",
+			GopMode:       "fixed",
+			InterlaceMode: "progressive",
+		},
+		Audio: db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+	},
+	{
+		Name:      "hls_480p",
+		Container: "m3u8",
+		Video:     db.VideoPreset{Height: "480", Codec: "h264", Bitrate: "1000000"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+	},
+	{
+		Name:      "hls_720p",
+		Container: "m3u8",
+		Video:     db.VideoPreset{Height: "720", Codec: "h264", Bitrate: "2000000"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+	},
+	{
+		Name:      "dash_720p",
+		Container: "mpd",
+		Video:     db.VideoPreset{Height: "720", Codec: "h264", Bitrate: "2000000"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+	},
+}
+
+func newTestProvider(t *testing.T, server *mediaConvertServer) *mcProvider {
+	creds := credentials.NewStaticCredentials("AKIANOTREALLY", "really-secret", "")
+	awsSession, err := session.NewSession(aws.NewConfig().WithCredentials(creds).WithRegion("us-east-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prov := &mcProvider{
+		c:  mediaconvert.New(awsSession, aws.NewConfig().WithEndpoint(server.URL)),
+		s3: &fakeS3{},
+		config: &config.MediaConvert{
+			Role:        "arn:aws:iam::123456789012:role/MediaConvert",
+			Destination: "s3://some-bucket/",
+		},
+	}
+	for _, preset := range testPresets {
+		if _, err := prov.CreatePreset(preset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return prov
+}
+
+// newTestJob returns a job with the given outputs, in the format
+// "preset:fileName".
+func newTestJob(id string, outputs ...string) *db.Job {
+	job := db.Job{
+		ID:              id,
+		SourceMedia:     "s3://some-bucket/source.mov",
+		StreamingParams: db.StreamingParams{Protocol: "hls", PlaylistFileName: "hls/index.m3u8", SegmentDuration: 4},
+	}
+	for _, output := range outputs {
+		parts := strings.SplitN(output, ":", 2)
+		job.Outputs = append(job.Outputs, db.TranscodeOutput{
+			FileName: parts[1],
+			Preset:   db.PresetMap{Name: parts[0], ProviderMapping: map[string]string{Name: parts[0]}},
+		})
+	}
+	return &job
+}
+
+func TestFactoryIsRegistered(t *testing.T) {
+	_, err := provider.GetProviderFactory(Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMediaConvertFactory(t *testing.T) {
+	cfg := config.Config{
+		MediaConvert: &config.MediaConvert{
+			AccessKeyID:     "AKIANOTREALLY",
+			SecretAccessKey: "really-secret",
+			Region:          "sa-east-1",
+			Endpoint:        "https://abcd1234.mediaconvert.sa-east-1.amazonaws.com",
+			Role:            "arn:aws:iam::123456789012:role/MediaConvert",
+			Destination:     "s3://some-bucket/",
+		},
+	}
+	prov, err := mediaConvertFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcProv := prov.(*mcProvider)
+	if !reflect.DeepEqual(*mcProv.config, *cfg.MediaConvert) {
+		t.Errorf("MediaConvert: did not store the proper config. Want %#v. Got %#v.", cfg.MediaConvert, mcProv.config)
+	}
+	client := mcProv.c.(*mediaconvert.MediaConvert)
+	expectedCreds := credentials.Value{AccessKeyID: "AKIANOTREALLY", SecretAccessKey: "really-secret"}
+	creds, err := client.Config.Credentials.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// provider is not relevant
+	creds.ProviderName = expectedCreds.ProviderName
+	if !reflect.DeepEqual(creds, expectedCreds) {
+		t.Errorf("MediaConvert: wrong credentials. Want %#v. Got %#v.", expectedCreds, creds)
+	}
+	if region := aws.StringValue(client.Config.Region); region != "sa-east-1" {
+		t.Errorf("MediaConvert: wrong region. Want %q. Got %q.", "sa-east-1", region)
+	}
+	if endpoint := aws.StringValue(client.Config.Endpoint); endpoint != cfg.MediaConvert.Endpoint {
+		t.Errorf("MediaConvert: wrong endpoint. Want %q. Got %q.", cfg.MediaConvert.Endpoint, endpoint)
+	}
+	s3Client := mcProv.s3.(*s3.S3)
+	if region := aws.StringValue(s3Client.Config.Region); region != "sa-east-1" {
+		t.Errorf("S3: wrong region. Want %q. Got %q.", "sa-east-1", region)
+	}
+}
+
+func TestMediaConvertFactoryValidation(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenConfig   *config.MediaConvert
+	}{
+		{"missing config", nil},
+		{"missing credentials", &config.MediaConvert{Endpoint: "https://mediaconvert", Role: "role", Destination: "s3://bucket/"}},
+		{"missing endpoint", &config.MediaConvert{AccessKeyID: "AKIANOTREALLY", SecretAccessKey: "secret", Role: "role", Destination: "s3://bucket/"}},
+		{"missing role", &config.MediaConvert{AccessKeyID: "AKIANOTREALLY", SecretAccessKey: "secret", Endpoint: "https://mediaconvert", Destination: "s3://bucket/"}},
+		{"missing destination", &config.MediaConvert{AccessKeyID: "AKIANOTREALLY", SecretAccessKey: "secret", Endpoint: "https://mediaconvert", Role: "role"}},
+	}
+	for _, test := range tests {
+		prov, err := mediaConvertFactory(&config.Config{MediaConvert: test.givenConfig})
+		if prov != nil {
+			t.Errorf("%s: got unexpected non-nil provider: %#v", test.givenTestCase, prov)
+		}
+		if err != errMediaConvertInvalidConfig {
+			t.Errorf("%s: wrong error returned. Want errMediaConvertInvalidConfig. Got %#v", test.givenTestCase, err)
+		}
+	}
+}
+
+func TestMediaConvertCreatePreset(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	newTestProvider(t, server)
+	expected := &mediaconvert.PresetSettings{
+		ContainerSettings: &mediaconvert.ContainerSettings{Container: aws.String("MP4")},
+		VideoDescription: &mediaconvert.VideoDescription{
+			Width:  aws.Int64(1920),
+			Height: aws.Int64(1080),
+			CodecSettings: &mediaconvert.VideoCodecSettings{
+				Codec: aws.String("H_264"),
+				H264Settings: &mediaconvert.H264Settings{
+					Bitrate:           aws.Int64(3500000),
+					RateControlMode:   aws.String("CBR"),
+					GopSize:           aws.Float64(90),
+					GopSizeUnits:      aws.String("FRAMES"),
+					CodecProfile:      aws.String("MAIN"),
+					CodecLevel:        aws.String("LEVEL_3_1"),
+					SceneChangeDetect: aws.String("DISABLED"),
+					InterlaceMode:     aws.String("PROGRESSIVE"),
+				},
+			},
+		},
+		AudioDescriptions: []*mediaconvert.AudioDescription{
+			{
+				AudioSourceName: aws.String("Audio Selector 1"),
+				CodecSettings: &mediaconvert.AudioCodecSettings{
+					Codec: aws.String("AAC"),
+					AacSettings: &mediaconvert.AacSettings{
+						Bitrate:    aws.Int64(128000),
+						CodingMode: aws.String("CODING_MODE_2_0"),
+						SampleRate: aws.Int64(48000),
+					},
+				},
+			},
+		},
+	}
+	preset := server.presets["mp4_1080p"]
+	if description := aws.StringValue(preset.Description); description != "MP4 1080p" {
+		t.Errorf("wrong preset description. Want %q. Got %q", "MP4 1080p", description)
+	}
+	if !reflect.DeepEqual(preset.Settings, expected) {
+		t.Errorf("wrong preset settings\nwant %s\ngot  %s", expected, preset.Settings)
+	}
+}
+
+func TestMediaConvertAudioOnlyPreset(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	presetID, err := prov.CreatePreset(db.Preset{
+		Name:      "hls_audio_es",
+		Container: "m3u8",
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "96000", Channels: "6", SampleRate: "44100", Language: "es", Loudness: "-16"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &mediaconvert.PresetSettings{
+		ContainerSettings: &mediaconvert.ContainerSettings{Container: aws.String("M3U8")},
+		AudioDescriptions: []*mediaconvert.AudioDescription{
+			{
+				AudioSourceName: aws.String("Audio Selector 1"),
+				CodecSettings: &mediaconvert.AudioCodecSettings{
+					Codec: aws.String("AAC"),
+					AacSettings: &mediaconvert.AacSettings{
+						Bitrate:    aws.Int64(96000),
+						CodingMode: aws.String("CODING_MODE_5_1"),
+						SampleRate: aws.Int64(44100),
+					},
+				},
+				CustomLanguageCode:  aws.String("es"),
+				LanguageCodeControl: aws.String("USE_CONFIGURED"),
+				AudioNormalizationSettings: &mediaconvert.AudioNormalizationSettings{
+					Algorithm:        aws.String("ITU_BS_1770_2"),
+					AlgorithmControl: aws.String("CORRECT_AUDIO"),
+					TargetLkfs:       aws.Float64(-16),
+				},
+			},
+		},
+	}
+	preset := server.presets[presetID]
+	if !reflect.DeepEqual(preset.Settings, expected) {
+		t.Errorf("wrong preset settings\nwant %s\ngot  %s", expected, preset.Settings)
+	}
+}
+
+func TestMediaConvertVideoDescription(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenPreset   db.Preset
+		wantSettings  *mediaconvert.VideoCodecSettings
+	}{
+		{
+			"HEVC 10-bit high tier",
+			db.Preset{
+				Container:   "mp4",
+				RateControl: "CBR",
+				Video: db.VideoPreset{
+					Codec:           "hevc",
+					Bitrate:         "12000000",
+					GopSize:         "120",
+					ProfileLevel:    "5.1",
+					Tier:            "high",
+					BitDepth:        "10",
+					BufferSize:      "24000000",
+					BFrames:         "3",
+					ReferenceFrames: "4",
+					TwoPass:         true,
+				},
+			},
+			&mediaconvert.VideoCodecSettings{
+				Codec: aws.String("H_265"),
+				H265Settings: &mediaconvert.H265Settings{
+					Bitrate:                             aws.Int64(12000000),
+					RateControlMode:                     aws.String("CBR"),
+					GopSize:                             aws.Float64(120),
+					GopSizeUnits:                        aws.String("FRAMES"),
+					CodecProfile:                        aws.String("MAIN10_HIGH"),
+					CodecLevel:                          aws.String("LEVEL_5_1"),
+					HrdBufferSize:                       aws.Int64(24000000),
+					NumberBFramesBetweenReferenceFrames: aws.Int64(3),
+					NumberReferenceFrames:               aws.Int64(4),
+					QualityTuningLevel:                  aws.String("MULTI_PASS_HQ"),
+				},
+			},
+		},
+		{
+			"VP9 with max bitrate",
+			db.Preset{
+				Container: "webm",
+				Video:     db.VideoPreset{Codec: "vp9", Bitrate: "3000000", MaxBitrate: "4500000", TwoPass: true},
+			},
+			&mediaconvert.VideoCodecSettings{
+				Codec: aws.String("VP9"),
+				Vp9Settings: &mediaconvert.Vp9Settings{
+					Bitrate:            aws.Int64(3000000),
+					RateControlMode:    aws.String("VBR"),
+					MaxBitrate:         aws.Int64(4500000),
+					QualityTuningLevel: aws.String("MULTI_PASS_HQ"),
+				},
+			},
+		},
+		{
+			"VP8 at NTSC frame rate",
+			db.Preset{
+				Container: "webm",
+				Video:     db.VideoPreset{Codec: "vp8", Bitrate: "1000000", FrameRate: "29.97"},
+			},
+			&mediaconvert.VideoCodecSettings{
+				Codec: aws.String("VP8"),
+				Vp8Settings: &mediaconvert.Vp8Settings{
+					Bitrate:              aws.Int64(1000000),
+					RateControlMode:      aws.String("VBR"),
+					FramerateControl:     aws.String("SPECIFIED"),
+					FramerateNumerator:   aws.Int64(30000),
+					FramerateDenominator: aws.Int64(1001),
+				},
+			},
+		},
+		{
+			"AV1 10-bit",
+			db.Preset{
+				Container: "mp4",
+				Video:     db.VideoPreset{Codec: "av1", Bitrate: "2000000", BitDepth: "10", BFrames: "7"},
+			},
+			&mediaconvert.VideoCodecSettings{
+				Codec: aws.String("AV1"),
+				Av1Settings: &mediaconvert.Av1Settings{
+					RateControlMode:                     aws.String("QVBR"),
+					BitDepth:                            aws.String("BIT_10"),
+					MaxBitrate:                          aws.Int64(2000000),
+					NumberBFramesBetweenReferenceFrames: aws.Int64(7),
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		description, err := videoDescription(test.givenPreset)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
+		if !reflect.DeepEqual(description.CodecSettings, test.wantSettings) {
+			t.Errorf("%s: wrong codec settings\nwant %s\ngot  %s", test.givenTestCase, test.wantSettings, description.CodecSettings)
+		}
+	}
+}
+
+func TestMediaConvertCreatePresetErrors(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	var tests = []struct {
+		givenTestCase string
+		givenPreset   db.Preset
+		wantErrMsg    string
+	}{
+		{
+			"unsupported container",
+			db.Preset{Name: "preset", Container: "avi", Video: db.VideoPreset{Codec: "h264"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`unsupported container "avi"`,
+		},
+		{
+			"unsupported video codec",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "mpeg1"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`unsupported value "mpeg1" for video.codec`,
+		},
+		{
+			"CRF",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "hevc", CRF: "28"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`unsupported value "28" for video.crf`,
+		},
+		{
+			"two-pass AV1",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "av1", Bitrate: "2000000", TwoPass: true}, Audio: db.AudioPreset{Codec: "aac"}},
+			`unsupported value "true" for video.twoPass`,
+		},
+		{
+			"12-bit HEVC",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "hevc", BitDepth: "12"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`unsupported value "12" for video.bitDepth`,
+		},
+		{
+			"invalid HEVC tier",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "hevc", Tier: "ultra"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`unsupported value "ultra" for video.tier`,
+		},
+		{
+			"invalid B-frames",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "h264", BFrames: "many"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`invalid B-frames "many"`,
+		},
+		{
+			"unsupported audio codec",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "h264"}, Audio: db.AudioPreset{Codec: "ac3"}},
+			`unsupported audio codec "ac3"`,
+		},
+		{
+			"7.1 AAC",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "h264"}, Audio: db.AudioPreset{Codec: "aac", ChannelLayout: "7.1"}},
+			`unsupported value "7.1" for audio.channelLayout`,
+		},
+		{
+			"surround MP3",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "h264"}, Audio: db.AudioPreset{Codec: "mp3", Channels: "6"}},
+			`unsupported value "6" for audio.channels`,
+		},
+		{
+			"multiple audio tracks",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "h264"}, Audio: db.AudioPreset{Codec: "aac"}, AudioTracks: []db.AudioPreset{{Codec: "aac"}}},
+			`unsupported value "1" for audioTracks`,
+		},
+		{
+			"invalid video bitrate",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "3.5m"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`invalid video bitrate "3.5m"`,
+		},
+		{
+			"invalid GOP size",
+			db.Preset{Name: "preset", Container: "mp4", Video: db.VideoPreset{Codec: "h264", GopSize: "2s"}, Audio: db.AudioPreset{Codec: "aac"}},
+			`invalid GOP size "2s"`,
+		},
+	}
+	for _, test := range tests {
+		presetID, err := prov.CreatePreset(test.givenPreset)
+		if err == nil || err.Error() != test.wantErrMsg {
+			t.Errorf("%s: wrong error returned. Want %q. Got %v", test.givenTestCase, test.wantErrMsg, err)
+		}
+		if presetID != "" {
+			t.Errorf("%s: unexpected non-empty preset id: %q", test.givenTestCase, presetID)
+		}
+	}
+}
+
+func TestMediaConvertGetAndDeletePreset(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	preset, err := prov.GetPreset("hls_480p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := aws.StringValue(preset.(*mediaconvert.GetPresetOutput).Preset.Name); name != "hls_480p" {
+		t.Errorf("wrong preset returned. Want %q. Got %q", "hls_480p", name)
+	}
+	err = prov.DeletePreset("hls_480p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.presets["hls_480p"]; ok {
+		t.Error("preset was not deleted")
+	}
+	_, err = prov.GetPreset("hls_480p")
+	if err == nil {
+		t.Error("unexpected <nil> error when getting deleted preset")
+	}
+}
+
+func TestMediaConvertUpdatePreset(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	preset := db.Preset{
+		Name:        "hls_480p",
+		Description: "updated preset",
+		Container:   "m3u8",
+		RateControl: "VBR",
+		Video:       db.VideoPreset{Height: "480", Codec: "h264", Bitrate: "1200000", GopSize: "90"},
+		Audio:       db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+	}
+	err := prov.UpdatePreset("hls_480p", preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := server.presets["hls_480p"]
+	if description := aws.StringValue(updated.Description); description != preset.Description {
+		t.Errorf("wrong description. Want %q. Got %q", preset.Description, description)
+	}
+	bitrate := aws.Int64Value(updated.Settings.VideoDescription.CodecSettings.H264Settings.Bitrate)
+	if bitrate != 1200000 {
+		t.Errorf("wrong bitrate. Want 1200000. Got %d", bitrate)
+	}
+	err = prov.UpdatePreset("mp4_720p", preset)
+	if err == nil {
+		t.Error("unexpected <nil> error when updating an unknown preset")
+	}
+}
+
+func TestMediaConvertNormalizePreset(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	expected := db.Preset{
+		Description: "MP4 1080p",
+		Container:   "mp4",
+		RateControl: "CBR",
+		Video: db.VideoPreset{
+			Profile:       "main",
+			ProfileLevel:  "3.1",
+			Width:         "1920",
+			Height:        "1080",
+			Codec:         "h264",
+			Bitrate:       "3500000",
+			GopSize:       "90",
+			GopMode:       "fixed",
+			InterlaceMode: "progressive",
+		},
+		Audio: db.AudioPreset{Codec: "aac", Bitrate: "128000", Channels: "2", SampleRate: "48000"},
+	}
+	normalized, err := prov.NormalizePreset(testPresets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(normalized, expected) {
+		t.Errorf("wrong normalized preset\nwant %#v\ngot  %#v", expected, normalized)
+	}
+	preset, err := prov.GetPreset("mp4_1080p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized, err = prov.NormalizePreset(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(normalized, expected) {
+		t.Errorf("wrong normalized preset\nwant %#v\ngot  %#v", expected, normalized)
+	}
+	hevcPreset := db.Preset{
+		Container: "mp4",
+		Video:     db.VideoPreset{Codec: "hevc", Bitrate: "2000000", BitDepth: "10", Tier: "high", FrameRate: "23.976"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+	}
+	normalized, err = prov.NormalizePreset(hevcPreset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedVideo := db.VideoPreset{Codec: "hevc", Bitrate: "2000000", Profile: "main10", BitDepth: "10", Tier: "high", FrameRate: "23.976"}
+	if !reflect.DeepEqual(normalized.Video, expectedVideo) {
+		t.Errorf("wrong normalized video\nwant %#v\ngot  %#v", expectedVideo, normalized.Video)
+	}
+	audioPreset := db.Preset{
+		Container: "m3u8",
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000", ChannelLayout: "mono", Language: "spa", Loudness: "-23"},
+	}
+	normalized, err = prov.NormalizePreset(audioPreset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedAudioPreset := db.Preset{
+		Container: "m3u8",
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000", Channels: "1", SampleRate: "48000", Language: "spa", Loudness: "-23"},
+	}
+	if !reflect.DeepEqual(normalized, expectedAudioPreset) {
+		t.Errorf("wrong normalized audio preset\nwant %#v\ngot  %#v", expectedAudioPreset, normalized)
+	}
+	_, err = prov.NormalizePreset("mp4_1080p")
+	if err == nil {
+		t.Error("unexpected <nil> error for invalid preset type")
+	}
+}
+
+func TestMediaConvertTranscode(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	job := newTestJob("job-123", "mp4_1080p:video_1080p.mp4", "hls_480p:hls/video_480p.m3u8", "hls_720p:hls/video_720p.m3u8")
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedStatus := &provider.JobStatus{ProviderName: Name, ProviderJobID: "job-1", Status: provider.StatusQueued}
+	if !reflect.DeepEqual(status, expectedStatus) {
+		t.Errorf("wrong job status returned\nwant %#v\ngot  %#v", expectedStatus, status)
+	}
+	mcJob := server.getJob("job-1")
+	if role := aws.StringValue(mcJob.Role); role != prov.config.Role {
+		t.Errorf("wrong role. Want %q. Got %q", prov.config.Role, role)
+	}
+	if mcJob.Queue != nil {
+		t.Errorf("unexpected non-nil queue: %q", aws.StringValue(mcJob.Queue))
+	}
+	expectedSettings := &mediaconvert.JobSettings{
+		Inputs: []*mediaconvert.Input{{
+			FileInput: aws.String("s3://some-bucket/source.mov"),
+			AudioSelectors: map[string]*mediaconvert.AudioSelector{
+				"Audio Selector 1": {DefaultSelection: aws.String("DEFAULT")},
+			},
+		}},
+		OutputGroups: []*mediaconvert.OutputGroup{
+			{
+				Name: aws.String("File Group"),
+				OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+					Type:              aws.String("FILE_GROUP_SETTINGS"),
+					FileGroupSettings: &mediaconvert.FileGroupSettings{Destination: aws.String("s3://some-bucket/job-123/video_1080p")},
+				},
+				Outputs: []*mediaconvert.Output{{Preset: aws.String("mp4_1080p"), Extension: aws.String("mp4")}},
+			},
+			{
+				Name: aws.String("Apple HLS"),
+				OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+					Type: aws.String("HLS_GROUP_SETTINGS"),
+					HlsGroupSettings: &mediaconvert.HlsGroupSettings{
+						Destination:      aws.String("s3://some-bucket/job-123/hls/index"),
+						SegmentLength:    aws.Int64(4),
+						MinSegmentLength: aws.Int64(0),
+					},
+				},
+				Outputs: []*mediaconvert.Output{
+					{Preset: aws.String("hls_480p"), NameModifier: aws.String("_video_480p")},
+					{Preset: aws.String("hls_720p"), NameModifier: aws.String("_video_720p")},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(mcJob.Settings, expectedSettings) {
+		t.Errorf("wrong job settings\nwant %s\ngot  %s", expectedSettings, mcJob.Settings)
+	}
+	if jobID := aws.StringValue(mcJob.UserMetadata["jobID"]); jobID != "job-123" {
+		t.Errorf("wrong job id in the metadata. Want %q. Got %q", "job-123", jobID)
+	}
+}
+
+func TestMediaConvertTranscodeDASH(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	prov.config.Queue = "arn:aws:mediaconvert:us-east-1:123456789012:queues/transcoding"
+	job := newTestJob("job-123", "dash_720p:video_720p.mpd")
+	job.StreamingParams = db.StreamingParams{Protocol: "dash", PlaylistFileName: "dash/manifest.mpd"}
+	_, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcJob := server.getJob("job-1")
+	if queue := aws.StringValue(mcJob.Queue); queue != prov.config.Queue {
+		t.Errorf("wrong queue. Want %q. Got %q", prov.config.Queue, queue)
+	}
+	expectedGroups := []*mediaconvert.OutputGroup{
+		{
+			Name: aws.String("DASH ISO"),
+			OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+				Type: aws.String("DASH_ISO_GROUP_SETTINGS"),
+				DashIsoGroupSettings: &mediaconvert.DashIsoGroupSettings{
+					Destination:    aws.String("s3://some-bucket/job-123/dash/manifest"),
+					SegmentLength:  aws.Int64(10),
+					FragmentLength: aws.Int64(10),
+				},
+			},
+			Outputs: []*mediaconvert.Output{{Preset: aws.String("dash_720p"), NameModifier: aws.String("_video_720p")}},
+		},
+	}
+	if !reflect.DeepEqual(mcJob.Settings.OutputGroups, expectedGroups) {
+		t.Errorf("wrong output groups\nwant %s\ngot  %s", expectedGroups, mcJob.Settings.OutputGroups)
+	}
+}
+
+func TestMediaConvertTranscodeThumbnails(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	job := newTestJob("job-123", "mp4_1080p:video_1080p.mp4")
+	job.Thumbnails = &db.Thumbnails{Interval: 10, Width: 320, Format: "jpg", Path: "thumbnails"}
+	_, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcJob := server.getJob("job-1")
+	if len(mcJob.Settings.OutputGroups) != 2 {
+		t.Fatalf("wrong number of output groups. Want 2. Got %d", len(mcJob.Settings.OutputGroups))
+	}
+	expectedGroup := &mediaconvert.OutputGroup{
+		Name: aws.String("Thumbnails"),
+		OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+			Type:              aws.String("FILE_GROUP_SETTINGS"),
+			FileGroupSettings: &mediaconvert.FileGroupSettings{Destination: aws.String("s3://some-bucket/job-123/thumbnails/thumbnail")},
+		},
+		Outputs: []*mediaconvert.Output{{
+			ContainerSettings: &mediaconvert.ContainerSettings{Container: aws.String("RAW")},
+			Extension:         aws.String("jpg"),
+			VideoDescription: &mediaconvert.VideoDescription{
+				Width: aws.Int64(320),
+				CodecSettings: &mediaconvert.VideoCodecSettings{
+					Codec: aws.String("FRAME_CAPTURE"),
+					FrameCaptureSettings: &mediaconvert.FrameCaptureSettings{
+						FramerateNumerator:   aws.Int64(1),
+						FramerateDenominator: aws.Int64(10),
+						Quality:              aws.Int64(80),
+					},
+				},
+			},
+		}},
+	}
+	if group := mcJob.Settings.OutputGroups[1]; !reflect.DeepEqual(group, expectedGroup) {
+		t.Errorf("wrong thumbnails output group\nwant %s\ngot  %s", expectedGroup, group)
+	}
+}
+
+func TestMediaConvertTranscodeClip(t *testing.T) {
+	var tests = []struct {
+		givenClip    db.Clip
+		wantClipping *mediaconvert.InputClipping
+	}{
+		{
+			db.Clip{Start: "10", End: "25"},
+			&mediaconvert.InputClipping{StartTimecode: aws.String("00:00:10:00"), EndTimecode: aws.String("00:00:25:00")},
+		},
+		{
+			db.Clip{Start: "3725.5", End: "3790.2"},
+			&mediaconvert.InputClipping{StartTimecode: aws.String("01:02:05:00"), EndTimecode: aws.String("01:03:11:00")},
+		},
+		{
+			db.Clip{End: "30"},
+			&mediaconvert.InputClipping{EndTimecode: aws.String("00:00:30:00")},
+		},
+	}
+	for i, test := range tests {
+		server := newMediaConvertServer()
+		prov := newTestProvider(t, server)
+		job := newTestJob("job-123", "mp4_1080p:video_1080p.mp4")
+		job.Clip = &test.givenClip
+		_, err := prov.Transcode(job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input := server.getJob("job-1").Settings.Inputs[0]
+		if source := aws.StringValue(input.TimecodeSource); source != "ZEROBASED" {
+			t.Errorf("%d: wrong timecode source. Want ZEROBASED. Got %q", i, source)
+		}
+		expected := []*mediaconvert.InputClipping{test.wantClipping}
+		if !reflect.DeepEqual(input.InputClippings, expected) {
+			t.Errorf("%d: wrong input clippings\nwant %s\ngot  %s", i, expected, input.InputClippings)
+		}
+		server.Close()
+	}
+}
+
+func TestMediaConvertTranscodeErrors(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	job := newTestJob("job-123", "mp4_1080p:video_1080p.mp4")
+	job.Outputs[0].Preset.ProviderMapping = map[string]string{"zencoder": "123"}
+	status, err := prov.Transcode(job)
+	if err != provider.ErrPresetMapNotFound {
+		t.Errorf("wrong error returned. Want ErrPresetMapNotFound. Got %#v", err)
+	}
+	if status != nil {
+		t.Errorf("unexpected non-nil status: %#v", status)
+	}
+	status, err = prov.Transcode(newTestJob("job-123", "mp4_4k:video_4k.mp4"))
+	if err == nil {
+		t.Error("unexpected <nil> error for missing preset")
+	}
+	if status != nil {
+		t.Errorf("unexpected non-nil status: %#v", status)
+	}
+}
+
+func TestMediaConvertJobStatus(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	job := newTestJob("job-123", "mp4_1080p:video_1080p.mp4", "hls_480p:hls/video_480p.m3u8")
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.ProviderJobID = status.ProviderJobID
+	server.updateJob(job.ProviderJobID, func(mcJob *mediaconvert.Job) {
+		mcJob.Status = aws.String("PROGRESSING")
+		mcJob.CurrentPhase = aws.String("TRANSCODING")
+		mcJob.JobPercentComplete = aws.Int64(42)
+	})
+	status, err = prov.JobStatus(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &provider.JobStatus{
+		ProviderName:   Name,
+		ProviderJobID:  "job-1",
+		Status:         provider.StatusStarted,
+		Progress:       42,
+		ProviderStatus: map[string]interface{}{"status": "PROGRESSING", "currentPhase": "TRANSCODING", "errorCode": int64(0)},
+		Output:         provider.JobOutput{Destination: "s3://some-bucket/job-123/"},
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("wrong job status returned\nwant %#v\ngot  %#v", expected, status)
+	}
+	server.updateJob(job.ProviderJobID, func(mcJob *mediaconvert.Job) {
+		mcJob.Status = aws.String("COMPLETE")
+		mcJob.CurrentPhase = nil
+		mcJob.JobPercentComplete = nil
+		mcJob.OutputGroupDetails = []*mediaconvert.OutputGroupDetail{
+			{
+				OutputDetails: []*mediaconvert.OutputDetail{
+					{DurationInMs: aws.Int64(62500), VideoDetails: &mediaconvert.VideoDetail{WidthInPx: aws.Int64(1920), HeightInPx: aws.Int64(1080)}},
+				},
+			},
+			{
+				OutputDetails: []*mediaconvert.OutputDetail{
+					{DurationInMs: aws.Int64(62500), VideoDetails: &mediaconvert.VideoDetail{WidthInPx: aws.Int64(854), HeightInPx: aws.Int64(480)}},
+				},
+			},
+		}
+	})
+	status, err = prov.JobStatus(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = &provider.JobStatus{
+		ProviderName:   Name,
+		ProviderJobID:  "job-1",
+		Status:         provider.StatusFinished,
+		Progress:       100,
+		ProviderStatus: map[string]interface{}{"status": "COMPLETE", "currentPhase": "", "errorCode": int64(0)},
+		SourceInfo:     provider.SourceInfo{Duration: 62500 * time.Millisecond},
+		Output: provider.JobOutput{
+			Destination: "s3://some-bucket/job-123/",
+			Files: []provider.OutputFile{
+				{
+					Path:       "s3://some-bucket/job-123/video_1080p.mp4",
+					Container:  "mp4",
+					VideoCodec: "h264",
+					Width:      1920,
+					Height:     1080,
+				},
+				{
+					Path:       "s3://some-bucket/job-123/hls/index_video_480p.m3u8",
+					Container:  "m3u8",
+					VideoCodec: "h264",
+					Width:      854,
+					Height:     480,
+				},
+				{
+					Path:      "s3://some-bucket/job-123/hls/index.m3u8",
+					Container: "m3u8",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("wrong job status returned\nwant %#v\ngot  %#v", expected, status)
+	}
+}
+
+func TestMediaConvertJobStatusThumbnails(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	prov.s3 = &fakeS3{objects: map[string]int64{
+		"s3://some-bucket/job-123/video_1080p.mp4":                  4096,
+		"s3://some-bucket/job-123/thumbnails/thumbnail.0000000.jpg": 1024,
+	}}
+	job := newTestJob("job-123", "mp4_1080p:video_1080p.mp4")
+	job.Thumbnails = &db.Thumbnails{Interval: 10, Format: "jpg", Path: "thumbnails"}
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.ProviderJobID = status.ProviderJobID
+	server.updateJob(job.ProviderJobID, func(mcJob *mediaconvert.Job) {
+		mcJob.Status = aws.String("COMPLETE")
+		mcJob.OutputGroupDetails = []*mediaconvert.OutputGroupDetail{
+			{
+				OutputDetails: []*mediaconvert.OutputDetail{
+					{DurationInMs: aws.Int64(62500), VideoDetails: &mediaconvert.VideoDetail{WidthInPx: aws.Int64(1920), HeightInPx: aws.Int64(1080)}},
+				},
+			},
+			{
+				OutputDetails: []*mediaconvert.OutputDetail{
+					{VideoDetails: &mediaconvert.VideoDetail{WidthInPx: aws.Int64(1280), HeightInPx: aws.Int64(720)}},
+				},
+			},
+		}
+	})
+	status, err = prov.JobStatus(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFiles := []provider.OutputFile{
+		{Path: "s3://some-bucket/job-123/video_1080p.mp4", Container: "mp4", VideoCodec: "h264", Width: 1920, Height: 1080},
+	}
+	if !reflect.DeepEqual(status.Output.Files, expectedFiles) {
+		t.Errorf("wrong output files\nwant %#v\ngot  %#v", expectedFiles, status.Output.Files)
+	}
+	expectedThumbnails := []provider.OutputFile{
+		{Path: "s3://some-bucket/job-123/thumbnails/thumbnail.0000000.jpg", Container: "jpg", Width: 1280, Height: 720, FileSize: 1024},
+	}
+	if !reflect.DeepEqual(status.Output.Thumbnails, expectedThumbnails) {
+		t.Errorf("wrong thumbnails\nwant %#v\ngot  %#v", expectedThumbnails, status.Output.Thumbnails)
+	}
+}
+
+func TestMediaConvertJobStatusFailed(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	job := newTestJob("job-123", "mp4_1080p:video_1080p.mp4")
+	status, err := prov.Transcode(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.ProviderJobID = status.ProviderJobID
+	server.updateJob(job.ProviderJobID, func(mcJob *mediaconvert.Job) {
+		mcJob.Status = aws.String("ERROR")
+		mcJob.ErrorCode = aws.Int64(1010)
+		mcJob.ErrorMessage = aws.String("Unable to open input file")
+	})
+	status, err = prov.JobStatus(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != provider.StatusFailed {
+		t.Errorf("wrong status. Want %q. Got %q", provider.StatusFailed, status.Status)
+	}
+	if status.StatusMessage != "Unable to open input file" {
+		t.Errorf("wrong status message. Want %q. Got %q", "Unable to open input file", status.StatusMessage)
+	}
+	if errorCode := status.ProviderStatus["errorCode"]; errorCode != int64(1010) {
+		t.Errorf("wrong error code. Want 1010. Got %#v", errorCode)
+	}
+}
+
+func TestMediaConvertJobStatusNotFound(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	status, err := prov.JobStatus(&db.Job{ID: "job-123", ProviderJobID: "job-unknown"})
+	if _, ok := err.(provider.JobNotFoundError); !ok {
+		t.Errorf("wrong error returned. Want JobNotFoundError. Got %#v", err)
+	}
+	if status != nil {
+		t.Errorf("unexpected non-nil status: %#v", status)
+	}
+}
+
+func TestMediaConvertCancelJob(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	status, err := prov.Transcode(newTestJob("job-123", "mp4_1080p:video_1080p.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = prov.CancelJob(status.ProviderJobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mcStatus := aws.StringValue(server.getJob(status.ProviderJobID).Status); mcStatus != "CANCELED" {
+		t.Errorf("wrong status. Want %q. Got %q", "CANCELED", mcStatus)
+	}
+	err = prov.CancelJob("job-unknown")
+	if _, ok := err.(provider.JobNotFoundError); !ok {
+		t.Errorf("wrong error returned. Want JobNotFoundError. Got %#v", err)
+	}
+}
+
+func TestMediaConvertHealthcheck(t *testing.T) {
+	server := newMediaConvertServer()
+	defer server.Close()
+	prov := newTestProvider(t, server)
+	if err := prov.Healthcheck(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	prov.config.Queue = "arn:aws:mediaconvert:us-east-1:123456789012:queues/transcoding"
+	if err := prov.Healthcheck(); err == nil {
+		t.Error("unexpected <nil> error for missing queue")
+	}
+}
+
+func TestMediaConvertCapabilities(t *testing.T) {
+	var prov mcProvider
+	expected := provider.Capabilities{
+		InputFormats:  []string{"prores", "h264"},
+		OutputFormats: []string{"mp4", "hls", "dash", "webm"},
+		Destinations:  []string{"s3"},
+
+		PresetConstraints: &provider.PresetConstraints{
+			Containers:  []string{"mp4", "webm", "m3u8", "mpd"},
+			AudioCodecs: []string{"aac", "mp3", "opus", "vorbis"},
+			VideoCodecs: map[string][]string{
+				"h264": {"maxBitrate", "bufferSize", "bFrames", "referenceFrames", "twoPass", "frameRate"},
+				"hevc": {"tier", "bitDepth", "maxBitrate", "bufferSize", "bFrames", "referenceFrames", "twoPass", "frameRate"},
+				"vp8":  {"frameRate"},
+				"vp9":  {"maxBitrate", "bufferSize", "twoPass", "frameRate"},
+				"av1":  {"bitDepth", "maxBitrate", "bFrames", "frameRate"},
+			},
+			AudioParameters: []string{"channels", "channelLayout", "sampleRate", "language", "loudness", "audioOnly"},
+		},
+		Thumbnails: &provider.ThumbnailCapabilities{Formats: []string{"jpg"}},
+		Clipping:   &provider.ClippingCapabilities{},
+	}
+	cap := prov.Capabilities()
+	if !reflect.DeepEqual(cap, expected) {
+		t.Errorf("Capabilities: want %#v. Got %#v", expected, cap)
+	}
+}
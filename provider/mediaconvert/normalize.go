@@ -0,0 +1,206 @@
+package mediaconvert
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+)
+
+func (p *mcProvider) NormalizePreset(preset interface{}) (db.Preset, error) {
+	var settings *mediaconvert.PresetSettings
+	var description string
+	switch preset := preset.(type) {
+	case db.Preset:
+		var err error
+		if settings, err = presetSettings(preset); err != nil {
+			return db.Preset{}, err
+		}
+		description = preset.Description
+	case *mediaconvert.GetPresetOutput:
+		if preset.Preset == nil || preset.Preset.Settings == nil {
+			return db.Preset{}, errMisconfiguredPreset
+		}
+		settings = preset.Preset.Settings
+		description = aws.StringValue(preset.Preset.Description)
+	default:
+		return db.Preset{}, fmt.Errorf("unexpected preset type %T", preset)
+	}
+	normalized := db.Preset{Description: description}
+	if settings.ContainerSettings != nil {
+		normalized.Container = lookupKey(containers, aws.StringValue(settings.ContainerSettings.Container))
+	}
+	if settings.VideoDescription != nil {
+		normalizeVideo(settings.VideoDescription, &normalized)
+	}
+	if len(settings.AudioDescriptions) > 0 && settings.AudioDescriptions[0].CodecSettings != nil {
+		normalized.Audio = normalizeAudio(settings.AudioDescriptions[0])
+	}
+	return normalized, nil
+}
+
+// normalizeVideo translates the given video description back to the video
+// parameters of a preset, reverting videoDescription.
+func normalizeVideo(description *mediaconvert.VideoDescription, preset *db.Preset) {
+	video := &preset.Video
+	video.Width = formatInt(description.Width)
+	video.Height = formatInt(description.Height)
+	codecSettings := description.CodecSettings
+	if codecSettings == nil {
+		return
+	}
+	video.Codec = lookupKey(videoCodecs, aws.StringValue(codecSettings.Codec))
+	switch {
+	case codecSettings.H264Settings != nil:
+		settings := codecSettings.H264Settings
+		preset.RateControl = aws.StringValue(settings.RateControlMode)
+		video.Bitrate = formatInt(settings.Bitrate)
+		video.GopSize = formatFloat(settings.GopSize)
+		video.Profile = strings.ToLower(aws.StringValue(settings.CodecProfile))
+		video.ProfileLevel = codecLevel(settings.CodecLevel)
+		if aws.StringValue(settings.SceneChangeDetect) == mediaconvert.H264SceneChangeDetectDisabled {
+			video.GopMode = "fixed"
+		}
+		if aws.StringValue(settings.InterlaceMode) == mediaconvert.H264InterlaceModeProgressive {
+			video.InterlaceMode = "progressive"
+		}
+		video.MaxBitrate = formatInt(settings.MaxBitrate)
+		video.BufferSize = formatInt(settings.HrdBufferSize)
+		video.BFrames = formatInt(settings.NumberBFramesBetweenReferenceFrames)
+		video.ReferenceFrames = formatInt(settings.NumberReferenceFrames)
+		video.TwoPass = aws.StringValue(settings.QualityTuningLevel) == mediaconvert.H264QualityTuningLevelMultiPassHq
+		video.FrameRate = formatFrameRate(settings.FramerateNumerator, settings.FramerateDenominator)
+	case codecSettings.H265Settings != nil:
+		settings := codecSettings.H265Settings
+		preset.RateControl = aws.StringValue(settings.RateControlMode)
+		video.Bitrate = formatInt(settings.Bitrate)
+		video.GopSize = formatFloat(settings.GopSize)
+		// the profile combines the profile, the bit depth and the
+		// tier (e.g.: MAIN10_HIGH).
+		parts := strings.SplitN(strings.ToLower(aws.StringValue(settings.CodecProfile)), "_", 2)
+		video.Profile = parts[0]
+		video.BitDepth = "8"
+		if video.Profile == "main10" {
+			video.BitDepth = "10"
+		}
+		if len(parts) > 1 {
+			video.Tier = parts[1]
+		}
+		video.ProfileLevel = codecLevel(settings.CodecLevel)
+		if aws.StringValue(settings.SceneChangeDetect) == mediaconvert.H265SceneChangeDetectDisabled {
+			video.GopMode = "fixed"
+		}
+		if aws.StringValue(settings.InterlaceMode) == mediaconvert.H265InterlaceModeProgressive {
+			video.InterlaceMode = "progressive"
+		}
+		video.MaxBitrate = formatInt(settings.MaxBitrate)
+		video.BufferSize = formatInt(settings.HrdBufferSize)
+		video.BFrames = formatInt(settings.NumberBFramesBetweenReferenceFrames)
+		video.ReferenceFrames = formatInt(settings.NumberReferenceFrames)
+		video.TwoPass = aws.StringValue(settings.QualityTuningLevel) == mediaconvert.H265QualityTuningLevelMultiPassHq
+		video.FrameRate = formatFrameRate(settings.FramerateNumerator, settings.FramerateDenominator)
+	case codecSettings.Vp8Settings != nil:
+		settings := codecSettings.Vp8Settings
+		preset.RateControl = aws.StringValue(settings.RateControlMode)
+		video.Bitrate = formatInt(settings.Bitrate)
+		video.GopSize = formatFloat(settings.GopSize)
+		video.FrameRate = formatFrameRate(settings.FramerateNumerator, settings.FramerateDenominator)
+	case codecSettings.Vp9Settings != nil:
+		settings := codecSettings.Vp9Settings
+		preset.RateControl = aws.StringValue(settings.RateControlMode)
+		video.Bitrate = formatInt(settings.Bitrate)
+		video.GopSize = formatFloat(settings.GopSize)
+		video.MaxBitrate = formatInt(settings.MaxBitrate)
+		video.BufferSize = formatInt(settings.HrdBufferSize)
+		video.TwoPass = aws.StringValue(settings.QualityTuningLevel) == mediaconvert.Vp9QualityTuningLevelMultiPassHq
+		video.FrameRate = formatFrameRate(settings.FramerateNumerator, settings.FramerateDenominator)
+	case codecSettings.Av1Settings != nil:
+		settings := codecSettings.Av1Settings
+		preset.RateControl = aws.StringValue(settings.RateControlMode)
+		video.GopSize = formatFloat(settings.GopSize)
+		video.BitDepth = strings.TrimPrefix(aws.StringValue(settings.BitDepth), "BIT_")
+		video.MaxBitrate = formatInt(settings.MaxBitrate)
+		video.BFrames = formatInt(settings.NumberBFramesBetweenReferenceFrames)
+		video.FrameRate = formatFrameRate(settings.FramerateNumerator, settings.FramerateDenominator)
+	}
+}
+
+// normalizeAudio translates the given audio description back to the audio
+// parameters of a preset, reverting audioDescription. The channel layout is
+// normalized to the number of channels.
+func normalizeAudio(description *mediaconvert.AudioDescription) db.AudioPreset {
+	settings := description.CodecSettings
+	audio := db.AudioPreset{
+		Codec:    lookupKey(audioCodecs, aws.StringValue(settings.Codec)),
+		Language: aws.StringValue(description.CustomLanguageCode),
+	}
+	switch {
+	case settings.AacSettings != nil:
+		audio.Bitrate = formatInt(settings.AacSettings.Bitrate)
+		audio.Channels = lookupKey(aacCodingModes, aws.StringValue(settings.AacSettings.CodingMode))
+		audio.SampleRate = formatInt(settings.AacSettings.SampleRate)
+	case settings.Mp3Settings != nil:
+		audio.Bitrate = formatInt(settings.Mp3Settings.Bitrate)
+		audio.Channels = formatInt(settings.Mp3Settings.Channels)
+		audio.SampleRate = formatInt(settings.Mp3Settings.SampleRate)
+	case settings.OpusSettings != nil:
+		audio.Bitrate = formatInt(settings.OpusSettings.Bitrate)
+		audio.Channels = formatInt(settings.OpusSettings.Channels)
+		audio.SampleRate = formatInt(settings.OpusSettings.SampleRate)
+	case settings.VorbisSettings != nil:
+		audio.Channels = formatInt(settings.VorbisSettings.Channels)
+		audio.SampleRate = formatInt(settings.VorbisSettings.SampleRate)
+	}
+	if normalization := description.AudioNormalizationSettings; normalization != nil {
+		audio.Loudness = formatFloat(normalization.TargetLkfs)
+	}
+	return audio
+}
+
+// codecLevel translates levels like LEVEL_3_1 to 3.1.
+func codecLevel(level *string) string {
+	value := aws.StringValue(level)
+	if !strings.HasPrefix(value, "LEVEL_") {
+		return ""
+	}
+	return strings.Replace(strings.TrimPrefix(value, "LEVEL_"), "_", ".", -1)
+}
+
+// lookupKey returns the key of the given value in the map, or the value
+// itself, in lowercase, if it's not in the map.
+func lookupKey(m map[string]string, value string) string {
+	for k, v := range m {
+		if v == value {
+			return k
+		}
+	}
+	return strings.ToLower(value)
+}
+
+func formatInt(value *int64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatInt(*value, 10)
+}
+
+// formatFrameRate formats the given fraction as a frame rate, rounded to
+// thousandths (e.g.: 30000/1001 is formatted as 29.97).
+func formatFrameRate(numerator, denominator *int64) string {
+	if numerator == nil || denominator == nil || *denominator == 0 {
+		return ""
+	}
+	rate := math.Round(float64(*numerator)/float64(*denominator)*1000) / 1000
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+func formatFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
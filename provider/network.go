@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// internalNetworks are the private and shared address ranges that callback
+// URLs and sources can't point to, besides loopback and link-local
+// addresses.
+var internalNetworks = []*net.IPNet{
+	mustParseCIDR("10.0.0.0/8"),
+	mustParseCIDR("172.16.0.0/12"),
+	mustParseCIDR("192.168.0.0/16"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("fc00::/7"),
+}
+
+// IsInternalIP returns whether the given address is a loopback, link-local,
+// private or shared address.
+func IsInternalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckExternalHost returns an error if the given host is localhost or
+// resolves to an internal address.
+func CheckExternalHost(host string) error {
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("host %q is internal", host)
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if IsInternalIP(ip) {
+			return fmt.Errorf("host %q resolves to the internal address %s", host, ip)
+		}
+	}
+	return nil
+}
+
+func mustParseCIDR(value string) *net.IPNet {
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
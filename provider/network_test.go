@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsInternalIP(t *testing.T) {
+	var tests = []struct {
+		ip       string
+		internal bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"169.254.169.254", true},
+		{"10.0.0.5", true},
+		{"172.20.1.1", true},
+		{"192.168.1.10", true},
+		{"100.64.0.1", true},
+		{"fd00::1", true},
+		{"0.0.0.0", true},
+		{"93.184.216.34", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+	}
+	for _, test := range tests {
+		if internal := IsInternalIP(net.ParseIP(test.ip)); internal != test.internal {
+			t.Errorf("IsInternalIP(%q): want %v. Got %v", test.ip, test.internal, internal)
+		}
+	}
+}
+
+func TestCheckExternalHost(t *testing.T) {
+	var tests = []struct {
+		host    string
+		wantErr bool
+	}{
+		{"localhost", true},
+		{"media.localhost", true},
+		{"127.0.0.1", true},
+		{"[::1]", true},
+		{"10.1.2.3", true},
+		{"93.184.216.34", false},
+	}
+	for _, test := range tests {
+		err := CheckExternalHost(test.host)
+		if (err != nil) != test.wantErr {
+			t.Errorf("CheckExternalHost(%q): want error %v. Got %v", test.host, test.wantErr, err)
+		}
+	}
+}
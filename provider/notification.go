@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+)
+
+// ErrNotificationUnauthorized is the error returned when a notification
+// pushed to the API can't be authenticated.
+var ErrNotificationUnauthorized = errors.New("notification could not be authenticated")
+
+// NotificationHandler is an optional interface implemented by providers that
+// are able to push notifications about the status of jobs, instead of being
+// polled with JobStatus.
+type NotificationHandler interface {
+	// HandleNotification parses and authenticates a notification in the
+	// native format of the provider, returning the status of the job it
+	// refers to. The status must include the ProviderJobID.
+	//
+	// It may return a nil status for notifications that don't carry
+	// the status of a job, like subscription confirmations.
+	HandleNotification(r *http.Request) (*JobStatus, error)
+}
+
+// InvalidNotificationError is returned when a notification pushed to the
+// API can't be parsed.
+type InvalidNotificationError string
+
+func (err InvalidNotificationError) Error() string {
+	return "invalid notification: " + string(err)
+}
+
+// CheckNotificationToken checks that the "token" parameter in the query string
+// of the request matches the given token. An empty token means that
+// notifications are not configured, so all requests are rejected.
+func CheckNotificationToken(r *http.Request, token string) error {
+	given := r.URL.Query().Get("token")
+	if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		return ErrNotificationUnauthorized
+	}
+	return nil
+}
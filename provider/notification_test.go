@@ -0,0 +1,27 @@
+package provider
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCheckNotificationToken(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenURL      string
+		givenToken    string
+		wantErr       error
+	}{
+		{"valid token", "http://transcoding.api/notifications/zencoder?token=s3cr3t", "s3cr3t", nil},
+		{"wrong token", "http://transcoding.api/notifications/zencoder?token=guess", "s3cr3t", ErrNotificationUnauthorized},
+		{"missing token", "http://transcoding.api/notifications/zencoder", "s3cr3t", ErrNotificationUnauthorized},
+		{"notifications not configured", "http://transcoding.api/notifications/zencoder?token=", "", ErrNotificationUnauthorized},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("POST", test.givenURL, nil)
+		err := CheckNotificationToken(r, test.givenToken)
+		if err != test.wantErr {
+			t.Errorf("%s: wrong error returned. Want %#v. Got %#v", test.givenTestCase, test.wantErr, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/NYTimes/video-transcoding-api/config"
@@ -58,6 +59,16 @@ type JobNotFoundError struct {
 	ID string
 }
 
+// UnsupportedParameterError is returned by CreatePreset when the preset
+// defines a parameter, or a value for a parameter, that can't be translated
+// to the provider.
+type UnsupportedParameterError struct {
+	// Parameter is the JSON path of the parameter in the preset, like
+	// "video.codec" or "video.twoPass".
+	Parameter string `json:"parameter"`
+	Value     string `json:"value"`
+}
+
 func (err InvalidConfigError) Error() string {
 	return string(err)
 }
@@ -66,6 +77,122 @@ func (err JobNotFoundError) Error() string {
 	return fmt.Sprintf("could not found job with id: %s", err.ID)
 }
 
+func (err UnsupportedParameterError) Error() string {
+	return fmt.Sprintf("unsupported value %q for %s", err.Value, err.Parameter)
+}
+
+// CheckVideoParameters returns an UnsupportedParameterError for the first
+// codec-specific parameter that is defined in the given video preset, but is
+// not listed in supported. Parameters are identified by their JSON name
+// (e.g.: "crf" or "twoPass"). A bit depth of 8 is always supported.
+func CheckVideoParameters(video db.VideoPreset, supported ...string) error {
+	if errs := unsupportedVideoParameters(video, supported); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// unsupportedVideoParameters returns an UnsupportedParameterError for each
+// codec-specific parameter of the given video preset that is not listed in
+// supported.
+func unsupportedVideoParameters(video db.VideoPreset, supported []string) []UnsupportedParameterError {
+	var twoPass string
+	if video.TwoPass {
+		twoPass = "true"
+	}
+	params := []struct{ name, value string }{
+		{"tier", video.Tier},
+		{"bitDepth", video.BitDepth},
+		{"crf", video.CRF},
+		{"maxBitrate", video.MaxBitrate},
+		{"bufferSize", video.BufferSize},
+		{"bFrames", video.BFrames},
+		{"referenceFrames", video.ReferenceFrames},
+		{"twoPass", twoPass},
+		{"frameRate", video.FrameRate},
+	}
+	var errs []UnsupportedParameterError
+	for _, param := range params {
+		if param.value == "" || (param.name == "bitDepth" && param.value == "8") {
+			continue
+		}
+		if !isSupported(param.name, supported) {
+			errs = append(errs, UnsupportedParameterError{Parameter: "video." + param.name, Value: param.value})
+		}
+	}
+	return errs
+}
+
+// CheckAudioParameters returns an UnsupportedParameterError for the first
+// optional audio parameter that is defined in the given preset, but is not
+// listed in supported. Besides the optional parameters of each audio
+// rendition (e.g.: "channels" or "loudness"), "audioTracks" stands for
+// additional audio renditions and "audioOnly" for presets without video.
+func CheckAudioParameters(preset db.Preset, supported ...string) error {
+	if errs := unsupportedAudioParameters(preset, supported); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// unsupportedAudioParameters returns an UnsupportedParameterError for each
+// optional audio parameter of the given preset that is not listed in
+// supported.
+func unsupportedAudioParameters(preset db.Preset, supported []string) []UnsupportedParameterError {
+	var errs []UnsupportedParameterError
+	if preset.AudioOnly() && !isSupported("audioOnly", supported) {
+		errs = append(errs, UnsupportedParameterError{Parameter: "video", Value: "none"})
+	}
+	if len(preset.AudioTracks) > 0 && !isSupported("audioTracks", supported) {
+		errs = append(errs, UnsupportedParameterError{Parameter: "audioTracks", Value: strconv.Itoa(len(preset.AudioTracks))})
+	}
+	for i, audio := range preset.AudioRenditions() {
+		prefix := "audio."
+		if i > 0 {
+			prefix = fmt.Sprintf("audioTracks[%d].", i-1)
+		}
+		params := []struct{ name, value string }{
+			{"channels", audio.Channels},
+			{"channelLayout", audio.ChannelLayout},
+			{"sampleRate", audio.SampleRate},
+			{"language", audio.Language},
+			{"loudness", audio.Loudness},
+		}
+		for _, param := range params {
+			if param.value != "" && !isSupported(param.name, supported) {
+				errs = append(errs, UnsupportedParameterError{Parameter: prefix + param.name, Value: param.value})
+			}
+		}
+	}
+	return errs
+}
+
+func isSupported(name string, supported []string) bool {
+	for _, s := range supported {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// PresetUpdater is an optional interface implemented by providers that are
+// able to update presets in place, keeping their IDs.
+type PresetUpdater interface {
+	UpdatePreset(presetID string, preset db.Preset) error
+}
+
+// PresetNormalizer is an optional interface implemented by providers that are
+// able to translate their presets back to db.Preset, allowing the detection
+// of presets changed directly in the provider.
+type PresetNormalizer interface {
+	// NormalizePreset translates the given preset to the form the
+	// provider keeps it in. The preset is either a db.Preset or a preset
+	// returned by GetPreset, so comparing the normalized version of both
+	// ignores parameters that the provider doesn't keep.
+	NormalizePreset(preset interface{}) (db.Preset, error)
+}
+
 // JobStatus is the representation of the status as the provide sees it. The
 // provider is able to add customized information in the ProviderStatus field.
 //
@@ -79,12 +206,18 @@ type JobStatus struct {
 	ProviderStatus map[string]interface{} `json:"providerStatus,omitempty"`
 	Output         JobOutput              `json:"output"`
 	SourceInfo     SourceInfo             `json:"sourceInfo,omitempty"`
+
+	// attempts of submitting the job to providers, filled by the API
+	ProviderAttempts []db.ProviderAttempt `json:"providerAttempts,omitempty"`
 }
 
 // JobOutput represents information about a job output.
 type JobOutput struct {
 	Destination string       `json:"destination,omitempty"`
 	Files       []OutputFile `json:"files,omitempty"`
+
+	// images and WebVTT tracks generated for the thumbnails of the job
+	Thumbnails []OutputFile `json:"thumbnails,omitempty"`
 }
 
 // OutputFile represents an output file in a given job.
@@ -109,6 +242,23 @@ type SourceInfo struct {
 
 	// Codec used for video medias
 	VideoCodec string `json:"videoCodec,omitempty"`
+
+	// Overall bitrate of the media, in bits per second
+	Bitrate int64 `json:"bitrate,omitempty"`
+
+	// Audio tracks of the media
+	AudioTracks []AudioTrackInfo `json:"audioTracks,omitempty"`
+}
+
+// AudioTrackInfo contains information about an audio track of media
+// transcoded using the Transcoding API.
+type AudioTrackInfo struct {
+	Codec      string `json:"codec,omitempty"`
+	Channels   int64  `json:"channels,omitempty"`
+	SampleRate int64  `json:"sampleRate,omitempty"`
+
+	// ISO 639 code of the language of the track
+	Language string `json:"language,omitempty"`
 }
 
 // Status is the status of a transcoding job.
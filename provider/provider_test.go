@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
 )
 
 func noopFactory(*config.Config) (TranscodingProvider, error) {
@@ -169,3 +170,113 @@ func TestDescribeProviderNotFound(t *testing.T) {
 		t.Errorf("Unexpected non-nil description: %#v", description)
 	}
 }
+
+func TestCheckVideoParameters(t *testing.T) {
+	var tests = []struct {
+		description string
+		video       db.VideoPreset
+		supported   []string
+		expected    error
+	}{
+		{
+			"no codec-specific parameters",
+			db.VideoPreset{Codec: "h264", Bitrate: "1000000"},
+			nil,
+			nil,
+		},
+		{
+			"supported parameters",
+			db.VideoPreset{Codec: "hevc", CRF: "28", BFrames: "3", TwoPass: true},
+			[]string{"crf", "bFrames", "twoPass"},
+			nil,
+		},
+		{
+			"8-bit depth is always supported",
+			db.VideoPreset{Codec: "h264", BitDepth: "8"},
+			nil,
+			nil,
+		},
+		{
+			"10-bit depth",
+			db.VideoPreset{Codec: "hevc", BitDepth: "10"},
+			[]string{"crf"},
+			UnsupportedParameterError{Parameter: "video.bitDepth", Value: "10"},
+		},
+		{
+			"unsupported two-pass",
+			db.VideoPreset{Codec: "vp9", CRF: "31", TwoPass: true},
+			[]string{"crf"},
+			UnsupportedParameterError{Parameter: "video.twoPass", Value: "true"},
+		},
+		{
+			"first unsupported parameter",
+			db.VideoPreset{Codec: "av1", Tier: "high", MaxBitrate: "3000000"},
+			nil,
+			UnsupportedParameterError{Parameter: "video.tier", Value: "high"},
+		},
+	}
+	for _, test := range tests {
+		err := CheckVideoParameters(test.video, test.supported...)
+		if !reflect.DeepEqual(err, test.expected) {
+			t.Errorf("%s: wrong error returned. Want %#v. Got %#v", test.description, test.expected, err)
+		}
+	}
+}
+
+func TestCheckAudioParameters(t *testing.T) {
+	var tests = []struct {
+		description string
+		preset      db.Preset
+		supported   []string
+		expected    error
+	}{
+		{
+			"no optional parameters",
+			db.Preset{Video: db.VideoPreset{Codec: "h264"}, Audio: db.AudioPreset{Codec: "aac", Bitrate: "128000"}},
+			nil,
+			nil,
+		},
+		{
+			"supported parameters",
+			db.Preset{
+				Video:       db.VideoPreset{Codec: "h264"},
+				Audio:       db.AudioPreset{Codec: "aac", Channels: "2", SampleRate: "48000"},
+				AudioTracks: []db.AudioPreset{{Codec: "aac", Language: "es"}},
+			},
+			[]string{"channels", "sampleRate", "language", "audioTracks"},
+			nil,
+		},
+		{
+			"unsupported audio-only preset",
+			db.Preset{Audio: db.AudioPreset{Codec: "aac"}},
+			[]string{"channels"},
+			UnsupportedParameterError{Parameter: "video", Value: "none"},
+		},
+		{
+			"unsupported audio tracks",
+			db.Preset{Video: db.VideoPreset{Codec: "h264"}, AudioTracks: []db.AudioPreset{{Codec: "aac"}, {Codec: "aac"}}},
+			nil,
+			UnsupportedParameterError{Parameter: "audioTracks", Value: "2"},
+		},
+		{
+			"unsupported parameter of an audio track",
+			db.Preset{Video: db.VideoPreset{Codec: "h264"}, AudioTracks: []db.AudioPreset{{Codec: "aac", Loudness: "-16"}}},
+			[]string{"audioTracks"},
+			UnsupportedParameterError{Parameter: "audioTracks[0].loudness", Value: "-16"},
+		},
+	}
+	for _, test := range tests {
+		err := CheckAudioParameters(test.preset, test.supported...)
+		if !reflect.DeepEqual(err, test.expected) {
+			t.Errorf("%s: wrong error returned. Want %#v. Got %#v", test.description, test.expected, err)
+		}
+	}
+}
+
+func TestUnsupportedParameterError(t *testing.T) {
+	err := UnsupportedParameterError{Parameter: "video.codec", Value: "av1"}
+	expected := `unsupported value "av1" for video.codec`
+	if err.Error() != expected {
+		t.Errorf("wrong error message. Want %q. Got %q", expected, err.Error())
+	}
+}
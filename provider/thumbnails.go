@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+// ErrThumbnailsNotSupported is the error returned when checking thumbnails
+// against the capabilities of a provider that can't generate them.
+var ErrThumbnailsNotSupported = errors.New("thumbnails are not supported")
+
+// ThumbnailCapabilities describes the thumbnails that a provider is able to
+// generate natively.
+type ThumbnailCapabilities struct {
+	// image formats supported by the provider (jpg, png)
+	Formats []string `json:"formats"`
+
+	// whether thumbnails can be taken at given timecodes, instead of at a
+	// regular interval
+	Timecodes bool `json:"timecodes"`
+
+	// whether the provider generates sprite sheets, along with their
+	// WebVTT tracks
+	Sprites bool `json:"sprites"`
+}
+
+// Check returns an error if the given thumbnails can't be generated by the
+// provider. It's safe to call it on nil capabilities, meaning that the
+// provider doesn't support thumbnails at all.
+func (c *ThumbnailCapabilities) Check(thumbnails *db.Thumbnails) error {
+	if c == nil {
+		return ErrThumbnailsNotSupported
+	}
+	format := thumbnails.Format
+	if format == "" {
+		format = "jpg"
+	}
+	if !isSupported(format, c.Formats) {
+		return UnsupportedParameterError{Parameter: "thumbnails.format", Value: format}
+	}
+	if len(thumbnails.Timecodes) > 0 && !c.Timecodes {
+		return UnsupportedParameterError{Parameter: "thumbnails.timecodes", Value: strconv.Itoa(len(thumbnails.Timecodes))}
+	}
+	if thumbnails.Sprite != nil && !c.Sprites {
+		return UnsupportedParameterError{
+			Parameter: "thumbnails.sprite",
+			Value:     fmt.Sprintf("%dx%d", thumbnails.Sprite.Columns, thumbnails.Sprite.Rows),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+func TestThumbnailCapabilitiesCheck(t *testing.T) {
+	capabilities := ThumbnailCapabilities{Formats: []string{"jpg"}}
+	var tests = []struct {
+		testCase     string
+		capabilities *ThumbnailCapabilities
+		thumbnails   db.Thumbnails
+		want         error
+	}{
+		{
+			"supported thumbnails",
+			&capabilities,
+			db.Thumbnails{Interval: 10, Format: "jpg"},
+			nil,
+		},
+		{
+			"default format",
+			&capabilities,
+			db.Thumbnails{Interval: 10},
+			nil,
+		},
+		{
+			"unsupported format",
+			&capabilities,
+			db.Thumbnails{Interval: 10, Format: "png"},
+			UnsupportedParameterError{Parameter: "thumbnails.format", Value: "png"},
+		},
+		{
+			"unsupported timecodes",
+			&capabilities,
+			db.Thumbnails{Timecodes: []string{"1", "5"}},
+			UnsupportedParameterError{Parameter: "thumbnails.timecodes", Value: "2"},
+		},
+		{
+			"unsupported sprite sheets",
+			&capabilities,
+			db.Thumbnails{Interval: 10, Sprite: &db.ThumbnailSprite{Columns: 10, Rows: 5}},
+			UnsupportedParameterError{Parameter: "thumbnails.sprite", Value: "10x5"},
+		},
+		{
+			"sprite sheets and timecodes",
+			&ThumbnailCapabilities{Formats: []string{"jpg", "png"}, Timecodes: true, Sprites: true},
+			db.Thumbnails{Interval: 10, Format: "png", Sprite: &db.ThumbnailSprite{Columns: 10, Rows: 5}},
+			nil,
+		},
+		{
+			"provider without thumbnails",
+			nil,
+			db.Thumbnails{Interval: 10},
+			ErrThumbnailsNotSupported,
+		},
+	}
+	for _, test := range tests {
+		err := test.capabilities.Check(&test.thumbnails)
+		if !reflect.DeepEqual(err, test.want) {
+			t.Errorf("%s: wrong error returned. Want %#v. Got %#v", test.testCase, test.want, err)
+		}
+	}
+}
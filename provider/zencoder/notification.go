@@ -0,0 +1,106 @@
+package zencoder
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/flavioribeiro/zencoder"
+)
+
+// notification is the body of the job and output notifications sent by
+// Zencoder.
+type notification struct {
+	Job struct {
+		ID          int64  `json:"id"`
+		State       string `json:"state"`
+		CreatedAt   string `json:"created_at"`
+		UpdatedAt   string `json:"updated_at"`
+		SubmittedAt string `json:"submitted_at"`
+	} `json:"job"`
+	Input   *notificationMediaFile  `json:"input"`
+	Output  *notificationMediaFile  `json:"output"`
+	Outputs []notificationMediaFile `json:"outputs"`
+}
+
+type notificationMediaFile struct {
+	ID              int64  `json:"id"`
+	State           string `json:"state"`
+	URL             string `json:"url"`
+	Format          string `json:"format"`
+	VideoCodec      string `json:"video_codec"`
+	Width           int64  `json:"width"`
+	Height          int64  `json:"height"`
+	FileSizeInBytes int64  `json:"file_size_in_bytes"`
+	DurationInMs    int64  `json:"duration_in_ms"`
+	ErrorMessage    string `json:"error_message"`
+}
+
+// HandleNotification handles notifications sent by Zencoder. Notifications
+// are authenticated using the token defined in the ZENCODER_NOTIFICATION_TOKEN
+// environment variable, which must be included in the notification URL as the
+// "token" parameter.
+func (z *zencoderProvider) HandleNotification(r *http.Request) (*provider.JobStatus, error) {
+	err := provider.CheckNotificationToken(r, z.config.Zencoder.NotificationToken)
+	if err != nil {
+		return nil, err
+	}
+	var n notification
+	err = json.NewDecoder(r.Body).Decode(&n)
+	if err != nil {
+		return nil, provider.InvalidNotificationError(err.Error())
+	}
+	if n.Job.ID == 0 || n.Job.State == "" {
+		return nil, provider.InvalidNotificationError("missing job id or state")
+	}
+	status := provider.JobStatus{
+		ProviderName:  Name,
+		ProviderJobID: strconv.FormatInt(n.Job.ID, 10),
+		Status:        z.statusMap(zencoder.JobState(n.Job.State)),
+		ProviderStatus: map[string]interface{}{
+			"updated": n.Job.UpdatedAt,
+			"created": n.Job.SubmittedAt,
+			"started": n.Job.CreatedAt,
+		},
+	}
+	if status.Status == provider.StatusFinished {
+		status.Progress = 100
+	}
+	if n.Input != nil {
+		status.ProviderStatus["sourcefile"] = n.Input.URL
+		status.SourceInfo = provider.SourceInfo{
+			Duration:   time.Duration(n.Input.DurationInMs) * time.Millisecond,
+			Height:     n.Input.Height,
+			Width:      n.Input.Width,
+			VideoCodec: n.Input.VideoCodec,
+		}
+	}
+	outputs := n.Outputs
+	if n.Output != nil {
+		outputs = append(outputs, *n.Output)
+	}
+	for _, output := range outputs {
+		if output.ErrorMessage != "" {
+			status.StatusMessage = output.ErrorMessage
+		}
+		if output.State != string(zencoder.JobStateFinished) {
+			continue
+		}
+		file := provider.OutputFile{
+			Path:       z.S3Url(output.URL),
+			Container:  output.Format,
+			VideoCodec: output.VideoCodec,
+			Width:      output.Width,
+			Height:     output.Height,
+			FileSize:   output.FileSizeInBytes,
+		}
+		if output.Format == "" && strings.HasSuffix(output.URL, "m3u8") {
+			file.Container = "m3u8"
+		}
+		status.Output.Files = append(status.Output.Files, file)
+	}
+	return &status, nil
+}
@@ -0,0 +1,137 @@
+package zencoder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/kr/pretty"
+)
+
+func TestZencoderHandleNotification(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenFile     string
+		wantStatus    *provider.JobStatus
+	}{
+		{
+			"job finished",
+			"testdata/job_finished.json",
+			&provider.JobStatus{
+				ProviderName:  Name,
+				ProviderJobID: "362871439",
+				Status:        provider.StatusFinished,
+				Progress:      100,
+				ProviderStatus: map[string]interface{}{
+					"sourcefile": "https://mybucket.s3.amazonaws.com/source/video.mov",
+					"started":    "2017-03-21T18:41:37Z",
+					"updated":    "2017-03-21T18:44:05Z",
+					"created":    "2017-03-21T18:41:37Z",
+				},
+				Output: provider.JobOutput{
+					Files: []provider.OutputFile{
+						{
+							Path:       "s3://mybucket/destination-dir/job-123/output_720p.mp4",
+							Container:  "mpeg4",
+							VideoCodec: "h264",
+							Width:      1280,
+							Height:     720,
+							FileSize:   45261312,
+						},
+						{
+							Path:      "s3://mybucket/destination-dir/job-123/hls/video.m3u8",
+							Container: "m3u8",
+						},
+					},
+				},
+				SourceInfo: provider.SourceInfo{
+					Duration:   183017 * time.Millisecond,
+					Width:      1920,
+					Height:     1080,
+					VideoCodec: "prores",
+				},
+			},
+		},
+		{
+			"output failed",
+			"testdata/output_failed.json",
+			&provider.JobStatus{
+				ProviderName:  Name,
+				ProviderJobID: "362871440",
+				Status:        provider.StatusFailed,
+				StatusMessage: "The file is an unrecognized format.",
+				ProviderStatus: map[string]interface{}{
+					"started": "2017-03-21T18:41:37Z",
+					"updated": "2017-03-21T18:42:51Z",
+					"created": "2017-03-21T18:41:37Z",
+				},
+			},
+		},
+	}
+	prov := &zencoderProvider{
+		config: &config.Config{Zencoder: &config.Zencoder{NotificationToken: "s3cr3t"}},
+	}
+	for _, test := range tests {
+		body, err := ioutil.ReadFile(test.givenFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, _ := http.NewRequest("POST", "http://transcoding.api/notifications/zencoder?token=s3cr3t", bytes.NewReader(body))
+		status, err := prov.HandleNotification(r)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
+		if !reflect.DeepEqual(status, test.wantStatus) {
+			pretty.Fdiff(os.Stderr, test.wantStatus, status)
+			t.Errorf("%s: wrong status returned\nwant %#v\ngot  %#v", test.givenTestCase, test.wantStatus, status)
+		}
+	}
+}
+
+func TestZencoderHandleNotificationErrors(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenURL      string
+		givenBody     string
+		wantErr       error
+	}{
+		{
+			"invalid token",
+			"http://transcoding.api/notifications/zencoder?token=guess",
+			`{"job":{"id":1,"state":"finished"}}`,
+			provider.ErrNotificationUnauthorized,
+		},
+		{
+			"invalid body",
+			"http://transcoding.api/notifications/zencoder?token=s3cr3t",
+			`{"job":`,
+			provider.InvalidNotificationError("unexpected EOF"),
+		},
+		{
+			"missing job id",
+			"http://transcoding.api/notifications/zencoder?token=s3cr3t",
+			`{"job":{"state":"finished"}}`,
+			provider.InvalidNotificationError("missing job id or state"),
+		},
+	}
+	prov := &zencoderProvider{
+		config: &config.Config{Zencoder: &config.Zencoder{NotificationToken: "s3cr3t"}},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("POST", test.givenURL, bytes.NewBufferString(test.givenBody))
+		status, err := prov.HandleNotification(r)
+		if err != test.wantErr {
+			t.Errorf("%s: wrong error returned. Want %#v. Got %#v", test.givenTestCase, test.wantErr, err)
+		}
+		if status != nil {
+			t.Errorf("%s: unexpected non-nil status: %#v", test.givenTestCase, status)
+		}
+	}
+}
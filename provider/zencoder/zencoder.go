@@ -16,6 +16,7 @@
 package zencoder
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"path"
@@ -36,8 +37,33 @@ import (
 // registry of providers.
 const Name = "zencoder"
 
+// hlsAudioGroup is the group of the alternate audio renditions of HLS
+// playlists.
+const hlsAudioGroup = "audio"
+
 var errZencoderInvalidConfig = provider.InvalidConfigError("missing Zencoder API key. Please define the environment variables ZENCODER_API_KEY or set these values in the configuration file")
 
+var (
+	// audioParameters lists the optional audio parameters supported by
+	// Zencoder. Audio-only HLS outputs are listed in the alternate audio
+	// group of the video streams of the master playlist, labeled with
+	// their language. Audio tracks aren't supported, as the audio streams
+	// of the source can't be selected.
+	audioParameters = []string{"channels", "channelLayout", "sampleRate", "language", "audioOnly"}
+
+	// videoParameters lists the codec-specific parameters supported by
+	// Zencoder.
+	videoParameters = []string{"frameRate"}
+
+	audioChannels = map[string]int32{"1": 1, "2": 2, "mono": 1, "stereo": 2}
+
+	// thumbnailCapabilities describes the thumbnails generated by Zencoder,
+	// which are taken along with one of the video outputs of the job.
+	thumbnailCapabilities = provider.ThumbnailCapabilities{Formats: []string{"jpg", "png"}, Timecodes: true}
+
+	errNoVideoOutput = errors.New("thumbnails require at least one output with video")
+)
+
 func init() {
 	provider.Register(Name, zencoderFactory)
 }
@@ -82,7 +108,7 @@ func (z *zencoderProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
 
 func (z *zencoderProvider) buildOutputs(job *db.Job) ([]*zencoder.OutputSettings, error) {
 	zencoderOutputs := make([]*zencoder.OutputSettings, 0, len(job.Outputs))
-	hlsOutputs := 0
+	hlsOutputs, dashOutputs := 0, 0
 	for _, output := range job.Outputs {
 		localPresetOutput, err := z.GetPreset(output.Preset.Name)
 		if err != nil {
@@ -93,9 +119,20 @@ func (z *zencoderProvider) buildOutputs(job *db.Job) ([]*zencoder.OutputSettings
 		if err != nil {
 			return nil, fmt.Errorf("Error building output: %s", err.Error())
 		}
+		if clip := job.OutputClip(output); clip != nil {
+			if clip.Start != "" {
+				zencoderOutput.StartClip = provider.FormatSeconds(clip.StartTime())
+			}
+			if clip.End != "" {
+				zencoderOutput.ClipLength = provider.FormatSeconds(clip.Duration())
+			}
+		}
 		if zencoderOutput.Format == "ts" {
 			hlsOutputs++
 		}
+		if zencoderOutput.StreamingDeliveryFormat == "dash" {
+			dashOutputs++
+		}
 		zencoderOutputs = append(zencoderOutputs, &zencoderOutput)
 	}
 	if hlsOutputs > 0 {
@@ -110,16 +147,66 @@ func (z *zencoderProvider) buildOutputs(job *db.Job) ([]*zencoder.OutputSettings
 			return nil, fmt.Errorf("Error building hls master playlist: %s", err.Error())
 		}
 		outputsWithHLSPlaylist[len(optimizedOutputs)] = &hlsPlaylist
-		return outputsWithHLSPlaylist, nil
+		zencoderOutputs = outputsWithHLSPlaylist
+	}
+	if dashOutputs > 0 {
+		dashManifest, err := z.buildDASHManifest(zencoderOutputs, dashOutputs, job)
+		if err != nil {
+			return nil, fmt.Errorf("Error building dash manifest: %s", err.Error())
+		}
+		zencoderOutputs = append(zencoderOutputs, &dashManifest)
+	}
+	if job.Thumbnails != nil {
+		if err := z.addThumbnails(job, zencoderOutputs); err != nil {
+			return nil, err
+		}
 	}
 	return zencoderOutputs, nil
 }
 
+// addThumbnails adds the thumbnails of the job to the first output that
+// encodes video. Outputs copying the streams of other outputs, like HLS
+// outputs optimized for segmenting, can't take thumbnails.
+func (z *zencoderProvider) addThumbnails(job *db.Job, outputs []*zencoder.OutputSettings) error {
+	for _, output := range outputs {
+		if output.SkipVideo || output.CopyVideo || output.Type == "playlist" {
+			continue
+		}
+		destinationURL, err := z.baseURL(job)
+		if err != nil {
+			return err
+		}
+		destinationURL.Path = path.Join(destinationURL.Path, job.Thumbnails.Path)
+		thumbnails := zencoder.ThumbnailSettings{
+			Label:    "thumbnails",
+			Format:   job.Thumbnails.Format,
+			Interval: int32(job.Thumbnails.Interval),
+			Width:    int32(job.Thumbnails.Width),
+			Height:   int32(job.Thumbnails.Height),
+			BaseUrl:  destinationURL.String(),
+			Prefix:   "thumbnail",
+			Public:   true,
+		}
+		for _, timecode := range job.Thumbnails.Timecodes {
+			seconds, err := strconv.ParseFloat(timecode, 64)
+			if err != nil {
+				return fmt.Errorf("invalid thumbnail timecode %q: %s", timecode, err)
+			}
+			thumbnails.Times = append(thumbnails.Times, seconds)
+		}
+		output.Thumbnails = []*zencoder.ThumbnailSettings{&thumbnails}
+		return nil
+	}
+	return errNoVideoOutput
+}
+
 func (z *zencoderProvider) optimizeOutputsForHLS(outputs []*zencoder.OutputSettings) ([]*zencoder.OutputSettings, error) {
 	for i, hlsOutput := range outputs {
 		if hlsOutput.Format == "ts" {
 			for _, mp4Output := range outputs {
-				if mp4Output.Format == "mp4" {
+				// segmented outputs copy the streams of the mp4
+				// output, so they must use the same clip.
+				if mp4Output.Format == "mp4" && mp4Output.Type == "" && mp4Output.StartClip == hlsOutput.StartClip && mp4Output.ClipLength == hlsOutput.ClipLength {
 					isCompatible, err := z.isOutputCompatible(hlsOutput, mp4Output)
 					if err != nil {
 						return nil, err
@@ -130,6 +217,7 @@ func (z *zencoderProvider) optimizeOutputsForHLS(outputs []*zencoder.OutputSetti
 							BaseUrl:   hlsOutput.BaseUrl,
 							Format:    hlsOutput.Format,
 							Source:    mp4Output.Label,
+							SkipVideo: hlsOutput.SkipVideo,
 							CopyAudio: true,
 							CopyVideo: true,
 							Type:      "segmented",
@@ -164,22 +252,118 @@ func (z *zencoderProvider) isOutputCompatible(hlsOutput, mp4Output *zencoder.Out
 	return isCompatible, nil
 }
 
-func (z *zencoderProvider) buildHLSPlaylist(outputs []*zencoder.OutputSettings, hlsOutputs int, job *db.Job) (zencoder.OutputSettings, error) {
-	destinationURL, err := url.Parse(z.config.Zencoder.Destination)
+// baseURL returns the base URL of the outputs of the given job. Azure
+// destinations are translated to the format taken by Zencoder,
+// azure://<account>:<key>@<container>/path, using the access key configured
+// for the account.
+func (z *zencoderProvider) baseURL(job *db.Job) (*url.URL, error) {
+	destination := provider.JobDestination(job, z.config.Zencoder.Destination)
+	destinationURL, err := url.Parse(destination)
 	if err != nil {
-		return zencoder.OutputSettings{}, fmt.Errorf("error parsing destination (%q)", z.config.Zencoder.Destination)
+		return nil, fmt.Errorf("error parsing destination (%q)", destination)
+	}
+	if strings.ToLower(destinationURL.Scheme) == "azure" {
+		account, key, blobPath, err := provider.AzureBlob(destination, z.config.AzureStorageAccounts)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.SplitN(blobPath, "/", 2)
+		destinationURL = &url.URL{Scheme: "azure", User: url.UserPassword(account, key), Host: parts[0], Path: "/"}
+		if len(parts) > 1 {
+			destinationURL.Path += parts[1]
+		}
 	}
 	destinationURL.Path = path.Join(destinationURL.Path, job.ID)
+	return destinationURL, nil
+}
+
+func (z *zencoderProvider) buildHLSPlaylist(outputs []*zencoder.OutputSettings, hlsOutputs int, job *db.Job) (zencoder.OutputSettings, error) {
+	destinationURL, err := z.baseURL(job)
+	if err != nil {
+		return zencoder.OutputSettings{}, err
+	}
 	output := zencoder.OutputSettings{
 		BaseUrl:  destinationURL.String(),
-		Filename: job.StreamingParams.PlaylistFileName,
+		Filename: manifestName(job.StreamingParams, "hls", "hls/index.m3u8"),
 		Type:     "playlist",
 	}
+	hasVideo := false
+	for _, output := range outputs {
+		if output.Format == "ts" && !output.SkipVideo {
+			hasVideo = true
+		}
+	}
 	streams := make([]*zencoder.StreamSettings, 0, hlsOutputs)
+	var audioRenditions []*zencoder.AlternateAudioSettings
+	for _, output := range outputs {
+		if output.Format != "ts" {
+			continue
+		}
+		if output.SkipVideo && hasVideo {
+			// audio-only outputs are listed in the alternate audio
+			// group of the video streams.
+			rendition, err := z.buildAlternateAudio(output, len(audioRenditions) == 0)
+			if err != nil {
+				return zencoder.OutputSettings{}, err
+			}
+			audioRenditions = append(audioRenditions, rendition)
+			continue
+		}
+		stream := zencoder.StreamSettings{
+			Path:   output.Label + "/video.m3u8",
+			Source: output.Label,
+		}
+		streams = append(streams, &stream)
+	}
+	if len(audioRenditions) > 0 {
+		output.AlternateAudio = map[string][]*zencoder.AlternateAudioSettings{hlsAudioGroup: audioRenditions}
+		for _, stream := range streams {
+			stream.AlternateAudio = hlsAudioGroup
+		}
+	}
+	output.Streams = streams
+	return output, nil
+}
+
+// buildAlternateAudio returns the EXT-X-MEDIA rendition of the given
+// audio-only output, labeled with the language of its preset.
+func (z *zencoderProvider) buildAlternateAudio(output *zencoder.OutputSettings, isDefault bool) (*zencoder.AlternateAudioSettings, error) {
+	localPreset, err := z.GetPreset(output.Label)
+	if err != nil {
+		return nil, err
+	}
+	audio := localPreset.(*db.LocalPreset).Preset.Audio
+	rendition := zencoder.AlternateAudioSettings{
+		Path:     output.Label + "/video.m3u8",
+		Source:   output.Label,
+		Name:     output.Label,
+		Language: audio.Language,
+		Default:  isDefault,
+	}
+	if audio.Language != "" {
+		rendition.Name = audio.Language
+	}
+	return &rendition, nil
+}
+
+// buildDASHManifest returns the output of the DASH manifest listing the given
+// DASH outputs.
+func (z *zencoderProvider) buildDASHManifest(outputs []*zencoder.OutputSettings, dashOutputs int, job *db.Job) (zencoder.OutputSettings, error) {
+	destinationURL, err := z.baseURL(job)
+	if err != nil {
+		return zencoder.OutputSettings{}, err
+	}
+	output := zencoder.OutputSettings{
+		BaseUrl:                 destinationURL.String(),
+		Filename:                manifestName(job.StreamingParams, "dash", "dash/index.mpd"),
+		Type:                    "playlist",
+		StreamingDeliveryFormat: "dash",
+	}
+	streams := make([]*zencoder.StreamSettings, 0, dashOutputs)
 	for _, output := range outputs {
-		if output.Format == "ts" {
+		if output.Type == "segmented" && output.StreamingDeliveryFormat == "dash" {
 			stream := zencoder.StreamSettings{
-				Path:   output.Label + "/video.m3u8",
+				Path:   output.Label + "/video.mpd",
 				Source: output.Label,
 			}
 			streams = append(streams, &stream)
@@ -189,6 +373,20 @@ func (z *zencoderProvider) buildHLSPlaylist(outputs []*zencoder.OutputSettings,
 	return output, nil
 }
 
+// manifestName returns the name of the manifest used for the given streaming
+// protocol. The playlist of jobs without streaming protocol is an HLS
+// playlist.
+func manifestName(params db.StreamingParams, protocol, defaultName string) string {
+	jobProtocol := params.Protocol
+	if jobProtocol == "" {
+		jobProtocol = "hls"
+	}
+	if jobProtocol == protocol && params.PlaylistFileName != "" {
+		return params.PlaylistFileName
+	}
+	return defaultName
+}
+
 func (z *zencoderProvider) getResolution(preset db.Preset) (int32, int32) {
 	var width, height int64
 	width, err := strconv.ParseInt(preset.Video.Width, 10, 32)
@@ -210,24 +408,49 @@ func (z *zencoderProvider) buildOutput(job *db.Job, preset db.Preset, filename s
 		Filename:   filename,
 		MakePublic: true,
 	}
-	zencoderOutput.Width, zencoderOutput.Height = z.getResolution(preset)
-	videoBitrate, err := strconv.ParseInt(preset.Video.Bitrate, 10, 32)
-	if err != nil {
-		return zencoder.OutputSettings{}, fmt.Errorf("error converting preset video bitrate (%q): %s", preset.Video.Bitrate, err)
-	}
-	zencoderOutput.VideoBitrate = int32(videoBitrate) / 1000
+	if preset.AudioOnly() {
+		zencoderOutput.SkipVideo = true
+	} else {
+		zencoderOutput.Width, zencoderOutput.Height = z.getResolution(preset)
+		videoBitrate, err := strconv.ParseInt(preset.Video.Bitrate, 10, 32)
+		if err != nil {
+			return zencoder.OutputSettings{}, fmt.Errorf("error converting preset video bitrate (%q): %s", preset.Video.Bitrate, err)
+		}
+		zencoderOutput.VideoBitrate = int32(videoBitrate) / 1000
 
-	keyframeInterval, err := strconv.ParseInt(preset.Video.GopSize, 10, 32)
-	if err != nil {
-		return zencoder.OutputSettings{}, fmt.Errorf("error converting preset keyframe interval (%q): %s", preset.Video.GopSize, err)
+		keyframeInterval, err := strconv.ParseInt(preset.Video.GopSize, 10, 32)
+		if err != nil {
+			return zencoder.OutputSettings{}, fmt.Errorf("error converting preset keyframe interval (%q): %s", preset.Video.GopSize, err)
+		}
+		zencoderOutput.KeyframeInterval = int32(keyframeInterval)
+
+		if preset.Video.FrameRate != "" {
+			frameRate, err := strconv.ParseFloat(preset.Video.FrameRate, 64)
+			if err != nil {
+				return zencoder.OutputSettings{}, fmt.Errorf("error converting preset frame rate (%q): %s", preset.Video.FrameRate, err)
+			}
+			zencoderOutput.FrameRate = frameRate
+		}
 	}
-	zencoderOutput.KeyframeInterval = int32(keyframeInterval)
 
 	audioBitrate, err := strconv.ParseInt(preset.Audio.Bitrate, 10, 32)
 	if err != nil {
 		return zencoder.OutputSettings{}, fmt.Errorf("error converting preset audio bitrate (%q): %s", preset.Audio.Bitrate, err)
 	}
 	zencoderOutput.AudioBitrate = int32(audioBitrate) / 1000
+	if preset.Audio.SampleRate != "" {
+		sampleRate, err := strconv.ParseInt(preset.Audio.SampleRate, 10, 32)
+		if err != nil {
+			return zencoder.OutputSettings{}, fmt.Errorf("error converting preset audio sample rate (%q): %s", preset.Audio.SampleRate, err)
+		}
+		zencoderOutput.AudioSampleRate = int32(sampleRate)
+	}
+	if preset.Audio.ChannelLayout != "" {
+		zencoderOutput.AudioChannels = audioChannels[preset.Audio.ChannelLayout]
+	}
+	if preset.Audio.Channels != "" {
+		zencoderOutput.AudioChannels = audioChannels[preset.Audio.Channels]
+	}
 
 	if preset.Video.GopMode == "fixed" {
 		zencoderOutput.FixedKeyframeInterval = true
@@ -239,11 +462,10 @@ func (z *zencoderProvider) buildOutput(job *db.Job, preset db.Preset, filename s
 	if preset.RateControl == "CBR" {
 		zencoderOutput.ConstantBitrate = true
 	}
-	destinationURL, err := url.Parse(z.config.Zencoder.Destination)
+	destinationURL, err := z.baseURL(job)
 	if err != nil {
-		return zencoder.OutputSettings{}, fmt.Errorf("error parsing destination (%q)", z.config.Zencoder.Destination)
+		return zencoder.OutputSettings{}, err
 	}
-	destinationURL.Path = path.Join(destinationURL.Path, job.ID)
 	if preset.Container == "m3u8" {
 		zencoderOutput.Type = "segmented"
 		zencoderOutput.Format = "ts"
@@ -251,6 +473,13 @@ func (z *zencoderProvider) buildOutput(job *db.Job, preset db.Preset, filename s
 		parts := strings.Split(filename, "/")
 		finalFilename := parts[0] + "/" + preset.Name + "/video.m3u8"
 		zencoderOutput.Filename = finalFilename
+	} else if preset.Container == "mpd" {
+		zencoderOutput.Type = "segmented"
+		zencoderOutput.Format = "mp4"
+		zencoderOutput.StreamingDeliveryFormat = "dash"
+		zencoderOutput.SegmentSeconds = int32(job.StreamingParams.SegmentDuration)
+		parts := strings.Split(filename, "/")
+		zencoderOutput.Filename = parts[0] + "/" + preset.Name + "/video.mpd"
 	} else {
 		zencoderOutput.Format = preset.Container
 	}
@@ -272,6 +501,7 @@ func (z *zencoderProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error getting job outputs: %s", err)
 	}
+	jobOutputs.Thumbnails = z.getThumbnailFiles(jobDetails.Job.Thumbnails)
 	progress, err := z.client.GetJobProgress(jobID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting job progress: %s", err)
@@ -320,6 +550,9 @@ func (z *zencoderProvider) statusMap(zencoderState zencoder.JobState) provider.S
 func (z *zencoderProvider) S3Url(input string) string {
 	var httpS3Regexp = regexp.MustCompile(`https?://([^/_.]+)\.s3\.amazonaws\.com/(.+)$`)
 	parts := httpS3Regexp.FindStringSubmatch(input)
+	if len(parts) == 0 {
+		return input
+	}
 	return fmt.Sprintf("s3://%s/%s", parts[1], parts[2])
 }
 
@@ -334,14 +567,20 @@ func (z *zencoderProvider) getJobOutputs(job *db.Job, outputMediaFiles []*zencod
 			Height:     int64(mediaFile.Height),
 			FileSize:   mediaFile.FileSizeInBytes,
 		}
-		if mediaFile.State == "finished" && mediaFile.Format == "" && strings.HasSuffix(mediaFile.Url, "m3u8") {
-			file.Container = "m3u8"
+		if mediaFile.State == "finished" && mediaFile.Format == "" {
+			switch {
+			case strings.HasSuffix(mediaFile.Url, "m3u8"):
+				file.Container = "m3u8"
+			case strings.HasSuffix(mediaFile.Url, "mpd"):
+				file.Container = "mpd"
+			}
 		}
 		files = append(files, file)
 	}
-	destinationURL, err := url.Parse(z.config.Zencoder.Destination)
+	destination := provider.JobDestination(job, z.config.Zencoder.Destination)
+	destinationURL, err := url.Parse(destination)
 	if err != nil {
-		return provider.JobOutput{}, fmt.Errorf("error parsing destination (%q)", z.config.Zencoder.Destination)
+		return provider.JobOutput{}, fmt.Errorf("error parsing destination (%q)", destination)
 	}
 
 	destinationURL.Path = path.Join(destinationURL.Path, job.ID) + "/"
@@ -351,6 +590,19 @@ func (z *zencoderProvider) getJobOutputs(job *db.Job, outputMediaFiles []*zencod
 	}, nil
 }
 
+func (z *zencoderProvider) getThumbnailFiles(thumbnails []*zencoder.Thumbnail) []provider.OutputFile {
+	var files []provider.OutputFile
+	for _, thumbnail := range thumbnails {
+		files = append(files, provider.OutputFile{
+			Path:      z.S3Url(thumbnail.Url),
+			Container: strings.TrimPrefix(path.Ext(thumbnail.Url), "."),
+			Width:     int64(thumbnail.Width),
+			Height:    int64(thumbnail.Height),
+		})
+	}
+	return files
+}
+
 func (z *zencoderProvider) CancelJob(id string) error {
 	jobID, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
@@ -365,6 +617,9 @@ func (z *zencoderProvider) Healthcheck() error {
 }
 
 func (z *zencoderProvider) CreatePreset(preset db.Preset) (string, error) {
+	if err := checkPreset(preset); err != nil {
+		return "", err
+	}
 	err := z.db.CreateLocalPreset(&db.LocalPreset{
 		Name:   preset.Name,
 		Preset: preset,
@@ -375,10 +630,53 @@ func (z *zencoderProvider) CreatePreset(preset db.Preset) (string, error) {
 	return preset.Name, nil
 }
 
+func (z *zencoderProvider) UpdatePreset(presetID string, preset db.Preset) error {
+	if err := checkPreset(preset); err != nil {
+		return err
+	}
+	return z.db.UpdateLocalPreset(&db.LocalPreset{
+		Name:   presetID,
+		Preset: preset,
+	})
+}
+
+// checkPreset returns an UnsupportedParameterError if the given preset can't
+// be translated to Zencoder outputs. Zencoder only supports mono and stereo
+// audio.
+func checkPreset(preset db.Preset) error {
+	if preset.Video.Codec == "av1" {
+		return provider.UnsupportedParameterError{Parameter: "video.codec", Value: preset.Video.Codec}
+	}
+	if err := provider.CheckVideoParameters(preset.Video, videoParameters...); err != nil {
+		return err
+	}
+	if err := provider.CheckAudioParameters(preset, audioParameters...); err != nil {
+		return err
+	}
+	if _, ok := audioChannels[preset.Audio.ChannelLayout]; preset.Audio.ChannelLayout != "" && !ok {
+		return provider.UnsupportedParameterError{Parameter: "audio.channelLayout", Value: preset.Audio.ChannelLayout}
+	}
+	if _, ok := audioChannels[preset.Audio.Channels]; preset.Audio.Channels != "" && !ok {
+		return provider.UnsupportedParameterError{Parameter: "audio.channels", Value: preset.Audio.Channels}
+	}
+	return nil
+}
+
 func (z *zencoderProvider) GetPreset(presetID string) (interface{}, error) {
 	return z.db.GetLocalPreset(presetID)
 }
 
+func (*zencoderProvider) NormalizePreset(preset interface{}) (db.Preset, error) {
+	switch preset := preset.(type) {
+	case db.Preset:
+		return preset, nil
+	case *db.LocalPreset:
+		return preset.Preset, nil
+	default:
+		return db.Preset{}, fmt.Errorf("unexpected preset type %T", preset)
+	}
+}
+
 func (z *zencoderProvider) DeletePreset(presetID string) error {
 	preset, err := z.GetPreset(presetID)
 	if err != nil {
@@ -390,8 +688,11 @@ func (z *zencoderProvider) DeletePreset(presetID string) error {
 func (z *zencoderProvider) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
 		InputFormats:  []string{"prores", "h264"},
-		OutputFormats: []string{"mp4", "hls", "webm"},
-		Destinations:  []string{"akamai", "s3"},
+		OutputFormats: []string{"mp4", "hls", "dash", "webm"},
+		Destinations:  []string{"akamai", "s3", "ftp", "azure"},
+
+		Clipping:   &provider.ClippingCapabilities{PerOutput: true},
+		Thumbnails: &thumbnailCapabilities,
 	}
 }
 
@@ -0,0 +1,445 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+	"gopkg.in/yaml.v2"
+)
+
+// actions in the plan of a catalog import.
+const (
+	catalogActionCreate = "create"
+	catalogActionUpdate = "update"
+	catalogActionDelete = "delete"
+	catalogActionNone   = "none"
+)
+
+// swagger:route GET /presets/export presets exportPresets
+//
+// Exports the presets of the API as a catalog, which can be imported with
+// POST /presets/import. Presetmaps without a known preset definition can't
+// be exported and are listed as skipped.
+//
+//     Responses:
+//       200: presetCatalog
+//       500: genericError
+func (s *TranscodingService) exportPresets(r *http.Request) swagger.GizmoJSONResponse {
+	presetMaps, err := s.db.ListPresetMaps()
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	sort.Sort(presetMapsByName(presetMaps))
+	catalog := presetCatalog{Presets: []newPresetInput{}}
+	for _, presetMap := range presetMaps {
+		if presetMap.Preset == nil {
+			catalog.Skipped = append(catalog.Skipped, presetMap.Name)
+			continue
+		}
+		providers := make([]string, 0, len(presetMap.ProviderMapping))
+		for name := range presetMap.ProviderMapping {
+			providers = append(providers, name)
+		}
+		sort.Strings(providers)
+		catalog.Presets = append(catalog.Presets, newPresetInput{
+			Providers:     providers,
+			Preset:        *presetMap.Preset,
+			OutputOptions: presetMap.OutputOpts,
+		})
+	}
+	return &exportPresetsResponse{
+		baseResponse: baseResponse{
+			payload: catalog,
+			status:  http.StatusOK,
+		},
+	}
+}
+
+// swagger:route POST /presets/import presets importPresets
+//
+// Imports a catalog of presets, in JSON or YAML, creating, updating and
+// deleting presets on providers so they match the catalog. Presets that
+// already match the catalog are left untouched, so importing the same
+// catalog again is a no-op.
+//
+//     Responses:
+//       200: presetCatalogPlan
+//       400: invalidPreset
+//       500: presetCatalogPlan
+func (s *TranscodingService) importPresets(r *http.Request) swagger.GizmoJSONResponse {
+	defer r.Body.Close()
+	var params importPresetsInput
+	params.loadParams(r.URL.Query())
+	catalog, err := decodePresetCatalog(r)
+	if err != nil {
+		return newInvalidPresetResponse(err)
+	}
+	if err = s.validatePresetCatalog(catalog); err != nil {
+		return newInvalidPresetResponse(err)
+	}
+	plan, err := s.planPresetCatalog(catalog, params.Prune)
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	plan.DryRun = params.DryRun
+	status := http.StatusOK
+	if !params.DryRun {
+		author := r.Header.Get(authorHeader)
+		for i := range plan.Presets {
+			if err = s.applyPresetChange(&plan.Presets[i], author); err != nil {
+				plan.Presets[i].Error = err.Error()
+				status = http.StatusInternalServerError
+			}
+		}
+	}
+	return &importPresetsResponse{
+		baseResponse: baseResponse{
+			payload: plan,
+			status:  status,
+		},
+	}
+}
+
+// decodePresetCatalog decodes the catalog in the body of the request, which
+// is YAML when the content type says so, and JSON otherwise.
+func decodePresetCatalog(r *http.Request) (*presetCatalog, error) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("invalid YAML catalog: %s", err)
+		}
+	}
+	var catalog presetCatalog
+	if err = json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("invalid catalog: %s", err)
+	}
+	return &catalog, nil
+}
+
+// yamlToJSON converts a YAML document to JSON, so the catalog is decoded
+// using the same field names in both formats.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	value, err := jsonValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// jsonValue converts the maps decoded from YAML, whose keys may be of any
+// type, to maps with string keys. Numbers are converted to strings, as all
+// numeric parameters of presets are strings.
+func jsonValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var name string
+			switch k := key.(type) {
+			case string:
+				name = k
+			case int:
+				name = strconv.Itoa(k)
+			case bool:
+				name = strconv.FormatBool(k)
+			default:
+				return nil, fmt.Errorf("unsupported key %v", key)
+			}
+			item, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			m[name] = item
+		}
+		return m, nil
+	case []interface{}:
+		for i, item := range v {
+			item, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = item
+		}
+		return v, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return value, nil
+	}
+}
+
+func (s *TranscodingService) validatePresetCatalog(catalog *presetCatalog) error {
+	names := make(map[string]bool, len(catalog.Presets))
+	for i := range catalog.Presets {
+		entry := &catalog.Presets[i]
+		if err := entry.Preset.Validate(); err != nil {
+			if entry.Preset.Name == "" {
+				return fmt.Errorf("presets[%d]: %s", i, err)
+			}
+			return fmt.Errorf("preset %q: %s", entry.Preset.Name, err)
+		}
+		if names[entry.Preset.Name] {
+			return fmt.Errorf("preset %q: duplicated in the catalog", entry.Preset.Name)
+		}
+		names[entry.Preset.Name] = true
+		if len(entry.Providers) == 0 {
+			return fmt.Errorf("preset %q: providers are required", entry.Preset.Name)
+		}
+		for _, name := range entry.Providers {
+			if _, err := provider.GetProviderFactory(name); err != nil {
+				return fmt.Errorf("preset %q: provider %q: %s", entry.Preset.Name, name, err)
+			}
+		}
+		entry.OutputOptions.Extension = entry.Preset.Container
+		if err := entry.OutputOptions.Validate(); err != nil {
+			return fmt.Errorf("preset %q: invalid outputOptions: %s", entry.Preset.Name, err)
+		}
+	}
+	return nil
+}
+
+// planPresetCatalog computes the changes needed on each provider to make the
+// presets of the API match the catalog. When prune is true, presets that are
+// not in the catalog are deleted, except for presetmaps without a known
+// preset definition, as they can't be exported.
+func (s *TranscodingService) planPresetCatalog(catalog *presetCatalog, prune bool) (*presetCatalogPlan, error) {
+	presetMaps, err := s.db.ListPresetMaps()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(presetMapsByName(presetMaps))
+	existing := make(map[string]*db.PresetMap, len(presetMaps))
+	for i := range presetMaps {
+		existing[presetMaps[i].Name] = &presetMaps[i]
+	}
+	plan := presetCatalogPlan{Presets: make([]presetChangePlan, 0, len(catalog.Presets))}
+	for i := range catalog.Presets {
+		entry := catalog.Presets[i]
+		change := planPresetChange(existing[entry.Preset.Name], entry)
+		change.entry = &entry
+		plan.Presets = append(plan.Presets, change)
+		delete(existing, entry.Preset.Name)
+	}
+	if !prune {
+		return &plan, nil
+	}
+	for i := range presetMaps {
+		presetMap, ok := existing[presetMaps[i].Name]
+		if !ok || presetMap.Preset == nil {
+			continue
+		}
+		change := presetChangePlan{
+			Name:      presetMap.Name,
+			Action:    catalogActionDelete,
+			Providers: make(map[string]providerChangePlan, len(presetMap.ProviderMapping)),
+		}
+		for name, presetID := range presetMap.ProviderMapping {
+			change.Providers[name] = providerChangePlan{Action: catalogActionDelete, PresetID: presetID}
+		}
+		plan.Presets = append(plan.Presets, change)
+	}
+	return &plan, nil
+}
+
+// planPresetChange computes the changes needed on each provider to make the
+// given presetmap, which may be nil, match the entry of the catalog.
+func planPresetChange(presetMap *db.PresetMap, entry newPresetInput) presetChangePlan {
+	change := presetChangePlan{
+		Name:      entry.Preset.Name,
+		Action:    catalogActionCreate,
+		Providers: make(map[string]providerChangePlan, len(entry.Providers)),
+	}
+	if presetMap == nil {
+		for _, name := range entry.Providers {
+			change.Providers[name] = providerChangePlan{Action: catalogActionCreate}
+		}
+		return change
+	}
+	change.Action = catalogActionNone
+	change.Changes = db.DiffPresetMaps(
+		db.PresetMap{Name: presetMap.Name, Preset: presetMap.Preset, OutputOpts: presetMap.OutputOpts},
+		db.PresetMap{Name: presetMap.Name, Preset: &entry.Preset, OutputOpts: entry.OutputOptions},
+	)
+	presetChanged := presetMap.Preset == nil || len(db.DiffPresets(*presetMap.Preset, entry.Preset)) > 0
+	for _, name := range entry.Providers {
+		providerChange := providerChangePlan{Action: catalogActionCreate}
+		if presetID, ok := presetMap.ProviderMapping[name]; ok {
+			providerChange = providerChangePlan{Action: catalogActionNone, PresetID: presetID}
+			if presetChanged {
+				providerChange.Action = catalogActionUpdate
+			}
+		}
+		change.Providers[name] = providerChange
+	}
+	for name, presetID := range presetMap.ProviderMapping {
+		if _, ok := change.Providers[name]; !ok {
+			change.Providers[name] = providerChangePlan{Action: catalogActionDelete, PresetID: presetID}
+		}
+	}
+	if len(change.Changes) > 0 {
+		change.Action = catalogActionUpdate
+	}
+	for _, providerChange := range change.Providers {
+		if providerChange.Action != catalogActionNone {
+			change.Action = catalogActionUpdate
+		}
+	}
+	return change
+}
+
+// applyPresetChange applies the planned changes of a preset, updating the
+// plan with the resulting IDs of the presets and the errors of each
+// provider. Presets are updated and created before being deleted, so the
+// presetmap is only deleted when the preset is removed from the catalog.
+func (s *TranscodingService) applyPresetChange(change *presetChangePlan, author string) error {
+	if change.Action == catalogActionNone {
+		return nil
+	}
+	if change.entry != nil {
+		if err := s.updateCatalogPreset(change, author); err != nil {
+			return err
+		}
+		if err := s.createCatalogPreset(change, author); err != nil {
+			return err
+		}
+	}
+	return s.deleteCatalogPreset(change, author)
+}
+
+func (s *TranscodingService) updateCatalogPreset(change *presetChangePlan, author string) error {
+	if change.Action != catalogActionUpdate {
+		return nil
+	}
+	names := change.providers(catalogActionUpdate)
+	presetMap, err := s.db.GetPresetMap(change.Name)
+	if err != nil {
+		return err
+	}
+	presetMap.OutputOpts = change.entry.OutputOptions
+	if len(names) == 0 {
+		if len(change.Changes) == 0 {
+			return nil
+		}
+		presetMap.Preset = &change.entry.Preset
+		presetMap.Author = author
+		if err = s.db.UpdatePresetMap(presetMap); err != nil {
+			return fmt.Errorf("updating presetmap: %s", err)
+		}
+		return nil
+	}
+	results, err := s.applyPreset(presetMap, change.entry.Preset, names, author)
+	for name, result := range results {
+		change.setResult(name, result.PresetID, result.Error)
+	}
+	return err
+}
+
+func (s *TranscodingService) createCatalogPreset(change *presetChangePlan, author string) error {
+	names := change.providers(catalogActionCreate)
+	if len(names) == 0 {
+		return nil
+	}
+	input := *change.entry
+	input.Providers = names
+	output, _, err := s.createPreset(input, author)
+	if err != nil {
+		return err
+	}
+	failed := false
+	for _, name := range names {
+		result := output.Results[name]
+		change.setResult(name, result.PresetID, result.Error)
+		if result.Error != "" {
+			failed = true
+		}
+	}
+	if failed {
+		return errors.New("failed to create the preset on some providers")
+	}
+	return nil
+}
+
+func (s *TranscodingService) deleteCatalogPreset(change *presetChangePlan, author string) error {
+	names := change.providers(catalogActionDelete)
+	if len(names) == 0 {
+		return nil
+	}
+	presetMap, err := s.db.GetPresetMap(change.Name)
+	if err != nil {
+		return err
+	}
+	failed := false
+	for _, name := range names {
+		presetID := presetMap.ProviderMapping[name]
+		providerObj, err := s.providerByName(name)
+		if err == nil {
+			err = providerObj.DeletePreset(presetID)
+			if err != nil {
+				err = fmt.Errorf("deleting preset: %s", err)
+			}
+		}
+		if err != nil {
+			change.setResult(name, presetID, err.Error())
+			failed = true
+			continue
+		}
+		delete(presetMap.ProviderMapping, name)
+	}
+	if len(presetMap.ProviderMapping) == 0 {
+		err = s.db.DeletePresetMap(presetMap)
+	} else {
+		presetMap.Author = author
+		err = s.db.UpdatePresetMap(presetMap)
+	}
+	if err != nil {
+		return fmt.Errorf("updating presetmap: %s", err)
+	}
+	if failed {
+		return errors.New("failed to delete the preset on some providers")
+	}
+	return nil
+}
+
+// providers returns the sorted names of the providers with the given
+// action.
+func (c *presetChangePlan) providers(action string) []string {
+	var names []string
+	for name, providerChange := range c.Providers {
+		if providerChange.Action == action {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *presetChangePlan) setResult(name, presetID, err string) {
+	providerChange := c.Providers[name]
+	providerChange.PresetID = presetID
+	providerChange.Error = err
+	c.Providers[name] = providerChange
+}
+
+type presetMapsByName []db.PresetMap
+
+func (s presetMapsByName) Len() int           { return len(s) }
+func (s presetMapsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s presetMapsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,351 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/Sirupsen/logrus"
+)
+
+func TestImportPresets(t *testing.T) {
+	oldPreset := db.Preset{Name: "preset-1", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "1000"}}
+	newPreset := db.Preset{Name: "preset-1", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "2000"}}
+	jsonCatalog := func(providers ...string) string {
+		data, _ := json.Marshal(map[string]interface{}{
+			"presets": []interface{}{
+				map[string]interface{}{"providers": providers, "preset": newPreset},
+			},
+		})
+		return string(data)
+	}
+	tests := []struct {
+		givenTestCase    string
+		givenQuery       string
+		givenContentType string
+		givenCatalog     string
+		givenPresetMaps  []db.PresetMap
+		wantCode         int
+		wantBody         map[string]interface{}
+		wantPresetMaps   map[string]map[string]string
+		wantDeleted      []string
+	}{
+		{
+			"Create presets",
+			"",
+			"application/json",
+			jsonCatalog("fake", "zencoder"),
+			nil,
+			http.StatusOK,
+			map[string]interface{}{
+				"dryRun": false,
+				"presets": []interface{}{
+					map[string]interface{}{
+						"name":   "preset-1",
+						"action": "create",
+						"providers": map[string]interface{}{
+							"fake":     map[string]interface{}{"action": "create", "presetId": "presetID_here"},
+							"zencoder": map[string]interface{}{"action": "create", "presetId": "presetID_here"},
+						},
+					},
+				},
+			},
+			map[string]map[string]string{"preset-1": {"fake": "presetID_here", "zencoder": "presetID_here"}},
+			nil,
+		},
+		{
+			"YAML catalog",
+			"",
+			"application/x-yaml",
+			`presets:
+  - providers: [fake]
+    preset:
+      name: preset-1
+      container: mp4
+      video:
+        codec: h264
+        bitrate: 2000
+`,
+			[]db.PresetMap{
+				{Name: "preset-1", Preset: &newPreset, OutputOpts: db.OutputOptions{Extension: "mp4"}, ProviderMapping: map[string]string{"fake": "id-1"}},
+			},
+			http.StatusOK,
+			map[string]interface{}{
+				"dryRun": false,
+				"presets": []interface{}{
+					map[string]interface{}{
+						"name":   "preset-1",
+						"action": "none",
+						"providers": map[string]interface{}{
+							"fake": map[string]interface{}{"action": "none", "presetId": "id-1"},
+						},
+					},
+				},
+			},
+			map[string]map[string]string{"preset-1": {"fake": "id-1"}},
+			nil,
+		},
+		{
+			"Create preset on new provider",
+			"",
+			"application/json",
+			jsonCatalog("fake", "zencoder"),
+			[]db.PresetMap{
+				{Name: "preset-1", Preset: &newPreset, OutputOpts: db.OutputOptions{Extension: "mp4"}, ProviderMapping: map[string]string{"fake": "id-1"}},
+			},
+			http.StatusOK,
+			map[string]interface{}{
+				"dryRun": false,
+				"presets": []interface{}{
+					map[string]interface{}{
+						"name":   "preset-1",
+						"action": "update",
+						"providers": map[string]interface{}{
+							"fake":     map[string]interface{}{"action": "none", "presetId": "id-1"},
+							"zencoder": map[string]interface{}{"action": "create", "presetId": "presetID_here"},
+						},
+					},
+				},
+			},
+			map[string]map[string]string{"preset-1": {"fake": "id-1", "zencoder": "presetID_here"}},
+			nil,
+		},
+		{
+			"Update and delete presets",
+			"",
+			"application/json",
+			jsonCatalog("fake"),
+			[]db.PresetMap{
+				{Name: "preset-1", Preset: &oldPreset, OutputOpts: db.OutputOptions{Extension: "mp4"}, ProviderMapping: map[string]string{"fake": "id-1", "zencoder": "id-2"}},
+			},
+			http.StatusOK,
+			map[string]interface{}{
+				"dryRun": false,
+				"presets": []interface{}{
+					map[string]interface{}{
+						"name":   "preset-1",
+						"action": "update",
+						"changes": []interface{}{
+							map[string]interface{}{"field": "preset.video.bitrate", "from": "1000", "to": "2000"},
+						},
+						"providers": map[string]interface{}{
+							"fake":     map[string]interface{}{"action": "update", "presetId": "presetID_here"},
+							"zencoder": map[string]interface{}{"action": "delete", "presetId": "id-2"},
+						},
+					},
+				},
+			},
+			map[string]map[string]string{"preset-1": {"fake": "presetID_here"}},
+			[]string{"id-1", "id-2"},
+		},
+		{
+			"Dry run",
+			"?dryRun=true",
+			"application/json",
+			jsonCatalog("fake"),
+			[]db.PresetMap{
+				{Name: "preset-1", Preset: &oldPreset, OutputOpts: db.OutputOptions{Extension: "mp4"}, ProviderMapping: map[string]string{"fake": "id-1", "zencoder": "id-2"}},
+			},
+			http.StatusOK,
+			map[string]interface{}{
+				"dryRun": true,
+				"presets": []interface{}{
+					map[string]interface{}{
+						"name":   "preset-1",
+						"action": "update",
+						"changes": []interface{}{
+							map[string]interface{}{"field": "preset.video.bitrate", "from": "1000", "to": "2000"},
+						},
+						"providers": map[string]interface{}{
+							"fake":     map[string]interface{}{"action": "update", "presetId": "id-1"},
+							"zencoder": map[string]interface{}{"action": "delete", "presetId": "id-2"},
+						},
+					},
+				},
+			},
+			map[string]map[string]string{"preset-1": {"fake": "id-1", "zencoder": "id-2"}},
+			nil,
+		},
+		{
+			"Prune presets",
+			"?prune=true",
+			"application/json",
+			jsonCatalog("fake"),
+			[]db.PresetMap{
+				{Name: "preset-1", Preset: &newPreset, OutputOpts: db.OutputOptions{Extension: "mp4"}, ProviderMapping: map[string]string{"fake": "id-1"}},
+				{Name: "preset-2", Preset: &oldPreset, OutputOpts: db.OutputOptions{Extension: "mp4"}, ProviderMapping: map[string]string{"fake": "id-2"}},
+				{Name: "preset-3", ProviderMapping: map[string]string{"fake": "id-3"}},
+			},
+			http.StatusOK,
+			map[string]interface{}{
+				"dryRun": false,
+				"presets": []interface{}{
+					map[string]interface{}{
+						"name":   "preset-1",
+						"action": "none",
+						"providers": map[string]interface{}{
+							"fake": map[string]interface{}{"action": "none", "presetId": "id-1"},
+						},
+					},
+					map[string]interface{}{
+						"name":   "preset-2",
+						"action": "delete",
+						"providers": map[string]interface{}{
+							"fake": map[string]interface{}{"action": "delete", "presetId": "id-2"},
+						},
+					},
+				},
+			},
+			map[string]map[string]string{"preset-1": {"fake": "id-1"}, "preset-3": {"fake": "id-3"}},
+			[]string{"id-2"},
+		},
+		{
+			"Invalid preset",
+			"",
+			"application/json",
+			`{"presets":[{"providers":["fake"],"preset":{"name":"preset-1"}}]}`,
+			nil,
+			http.StatusBadRequest,
+			map[string]interface{}{"error": `preset "preset-1": invalid preset: container: is required`},
+			map[string]map[string]string{},
+			nil,
+		},
+		{
+			"Unknown provider",
+			"",
+			"application/json",
+			jsonCatalog("unknown"),
+			nil,
+			http.StatusBadRequest,
+			map[string]interface{}{"error": `preset "preset-1": provider "unknown": provider not found`},
+			map[string]map[string]string{},
+			nil,
+		},
+	}
+	for _, test := range tests {
+		fprovider.deletedPresets = nil
+		fakeDB := dbtest.NewFakeRepository(false)
+		for i := range test.givenPresetMaps {
+			presetMap := test.givenPresetMaps[i]
+			presetMap.ProviderMapping = make(map[string]string)
+			for name, presetID := range test.givenPresetMaps[i].ProviderMapping {
+				presetMap.ProviderMapping[name] = presetID
+			}
+			fakeDB.CreatePresetMap(&presetMap)
+		}
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest("POST", "/presets/import"+test.givenQuery, bytes.NewBufferString(test.givenCatalog))
+		r.Header.Set("Content-Type", test.givenContentType)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		err = json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(got, test.wantBody) {
+			t.Errorf("%s: expected response body of\n%#v;\ngot\n%#v", test.givenTestCase, test.wantBody, got)
+		}
+		presetMaps, _ := fakeDB.ListPresetMaps()
+		mappings := make(map[string]map[string]string, len(presetMaps))
+		for _, presetMap := range presetMaps {
+			mappings[presetMap.Name] = presetMap.ProviderMapping
+		}
+		if !reflect.DeepEqual(mappings, test.wantPresetMaps) {
+			t.Errorf("%s: wrong presetmaps.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantPresetMaps, mappings)
+		}
+		if !reflect.DeepEqual(fprovider.deletedPresets, test.wantDeleted) {
+			t.Errorf("%s: wrong deleted presets.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantDeleted, fprovider.deletedPresets)
+		}
+	}
+}
+
+func TestImportPresetsIsIdempotent(t *testing.T) {
+	catalog := `{"presets":[{"providers":["fake"],"preset":{"name":"preset-1","container":"mp4","video":{"bitrate":"1000"}}}]}`
+	fakeDB := dbtest.NewFakeRepository(false)
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	for i, wantAction := range []string{"create", "none"} {
+		r, _ := http.NewRequest("POST", "/presets/import", bytes.NewBufferString(catalog))
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("import %d: wrong response code. Want %d. Got %d", i, http.StatusOK, w.Code)
+		}
+		var plan presetCatalogPlan
+		if err = json.NewDecoder(w.Body).Decode(&plan); err != nil {
+			t.Fatal(err)
+		}
+		if plan.Presets[0].Action != wantAction {
+			t.Errorf("import %d: wrong action. Want %q. Got %q", i, wantAction, plan.Presets[0].Action)
+		}
+	}
+	versions, _ := fakeDB.ListPresetVersions("preset-1")
+	if len(versions) != 1 {
+		t.Errorf("wrong number of versions. Want 1. Got %d", len(versions))
+	}
+}
+
+func TestExportPresets(t *testing.T) {
+	preset := db.Preset{Name: "preset-1", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "1000"}}
+	fakeDB := dbtest.NewFakeRepository(false)
+	fakeDB.CreatePresetMap(&db.PresetMap{
+		Name:            "preset-1",
+		Preset:          &preset,
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		ProviderMapping: map[string]string{"zencoder": "id-2", "fake": "id-1"},
+	})
+	fakeDB.CreatePresetMap(&db.PresetMap{Name: "preset-2", ProviderMapping: map[string]string{"fake": "id-3"}})
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	r, _ := http.NewRequest("GET", "/presets/export", nil)
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	var got presetCatalog
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("unable to JSON decode response body: %s", err)
+	}
+	expected := presetCatalog{
+		Presets: []newPresetInput{
+			{
+				Providers:     []string{"fake", "zencoder"},
+				Preset:        preset,
+				OutputOptions: db.OutputOptions{Extension: "mp4"},
+			},
+		},
+		Skipped: []string{"preset-2"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong catalog.\nWant %#v\nGot  %#v", expected, got)
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultPresetCleanerInterval = 10 * time.Minute
+
+// presetCleaner periodically deletes the retired presets that are no longer
+// used by jobs. It runs independently of the status poller, so retired
+// presets are deleted even when the poller is disabled.
+type presetCleaner struct {
+	service  *TranscodingService
+	interval time.Duration
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newPresetCleaner(s *TranscodingService) *presetCleaner {
+	return &presetCleaner{service: s, interval: defaultPresetCleanerInterval}
+}
+
+func (c *presetCleaner) start() {
+	c.done = make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.service.deleteRetiredPresets()
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+func (c *presetCleaner) stop() {
+	close(c.done)
+	c.wg.Wait()
+}
+
+// StartPresetCleaner starts the background worker that deletes retired
+// presets from providers.
+func (s *TranscodingService) StartPresetCleaner() {
+	s.cleaner = newPresetCleaner(s)
+	s.cleaner.start()
+}
+
+// StopPresetCleaner stops the background worker that deletes retired presets,
+// waiting for the current run to finish.
+func (s *TranscodingService) StopPresetCleaner() {
+	if s.cleaner != nil {
+		s.cleaner.stop()
+		s.cleaner = nil
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/Sirupsen/logrus"
+)
+
+func TestPresetCleaner(t *testing.T) {
+	fprovider.deletedPresets = nil
+	fakeDB := dbtest.NewFakeRepository(false)
+	fakeDB.CreateJob(&db.Job{
+		ID:           "job-1",
+		ProviderName: "fake",
+		Status:       "finished",
+		Outputs:      []db.TranscodeOutput{{Preset: db.PresetMap{Name: "preset-1"}, FileName: "output.mp4"}},
+	})
+	fakeDB.CreateRetiredPreset(&db.RetiredPreset{ProviderName: "fake", PresetID: "old-id", PresetMap: "preset-1"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	cleaner := newPresetCleaner(service)
+	cleaner.interval = 10 * time.Millisecond
+	cleaner.start()
+	time.Sleep(50 * time.Millisecond)
+	cleaner.stop()
+	if !reflect.DeepEqual(fprovider.deletedPresets, []string{"old-id"}) {
+		t.Errorf("wrong deleted presets. Want %#v. Got %#v", []string{"old-id"}, fprovider.deletedPresets)
+	}
+	retired, err := fakeDB.ListRetiredPresets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retired) > 0 {
+		t.Errorf("unexpected retired presets after the cleanup: %#v", retired)
+	}
+}
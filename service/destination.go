@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+var errDestinationNotAllowed = errors.New("jobs can't override the destination: no destinations are allowed")
+
+// parseAllowedDestinations parses the ALLOWED_DESTINATIONS configuration, a
+// comma-separated list of destination URLs. Destinations are returned with a
+// trailing slash, so they only match paths under them.
+func parseAllowedDestinations(value string) ([]string, error) {
+	var destinations []string
+	for _, destination := range strings.Split(value, ",") {
+		destination = strings.TrimSpace(destination)
+		if destination == "" {
+			continue
+		}
+		if _, err := provider.DestinationType(destination); err != nil {
+			return nil, fmt.Errorf("invalid allowed destinations: %s", err)
+		}
+		destination = provider.StripCredentials(destination)
+		destinations = append(destinations, strings.TrimRight(destination, "/")+"/")
+	}
+	return destinations, nil
+}
+
+// checkDestination returns an error when the given destination of a job is not
+// under one of the allowed destinations. Credentials in the destination are
+// ignored when matching it.
+func (s *TranscodingService) checkDestination(destination string) error {
+	if len(s.allowedDestinations) == 0 {
+		return errDestinationNotAllowed
+	}
+	destination = provider.StripCredentials(destination)
+	for _, segment := range strings.Split(destination, "/") {
+		if segment == ".." {
+			return fmt.Errorf("invalid destination %q: relative paths are not allowed", destination)
+		}
+	}
+	destination = strings.TrimRight(destination, "/") + "/"
+	for _, allowed := range s.allowedDestinations {
+		if strings.HasPrefix(destination, allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("destination %q is not allowed", strings.TrimRight(destination, "/"))
+}
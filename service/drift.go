@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/NYTimes/gizmo/web"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+var errPresetUnknown = errors.New("the definition of the preset is unknown, update the preset before checking its drift")
+
+// swagger:route GET /presets/{name}/drift presets getPresetDrift
+//
+// Compares the preset stored in each provider with the preset stored in the
+// API, reporting the parameters that differ.
+//
+//     Responses:
+//       200: presetDrift
+//       404: presetNotFound
+//       409: presetUnknown
+//       500: genericError
+func (s *TranscodingService) getPresetDrift(r *http.Request) swagger.GizmoJSONResponse {
+	return s.presetDrift(r, false)
+}
+
+// swagger:route POST /presets/{name}/drift presets repairPresetDrift
+//
+// Detects the drift of a preset and re-creates the preset in the providers
+// where it drifted, updating the presetmap.
+//
+//     Responses:
+//       200: presetDrift
+//       404: presetNotFound
+//       409: presetUnknown
+//       500: presetDrift
+func (s *TranscodingService) repairPresetDrift(r *http.Request) swagger.GizmoJSONResponse {
+	return s.presetDrift(r, true)
+}
+
+func (s *TranscodingService) presetDrift(r *http.Request, repair bool) swagger.GizmoJSONResponse {
+	var params getPresetMapInput
+	params.loadParams(web.Vars(r))
+	presetMap, err := s.db.GetPresetMap(params.Name)
+	switch err {
+	case nil:
+	case db.ErrPresetMapNotFound:
+		return newPresetMapNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+	drift, err := s.detectDrift(presetMap)
+	if err == errPresetUnknown {
+		return newPresetUnknownResponse(err)
+	}
+	status := http.StatusOK
+	if repair {
+		if err = s.repairDrift(presetMap, drift, r.Header.Get(authorHeader)); err != nil {
+			status = http.StatusInternalServerError
+		}
+	}
+	return &presetDriftResponse{
+		baseResponse: baseResponse{
+			payload: drift,
+			status:  status,
+		},
+	}
+}
+
+// detectDrift compares the preset stored in each provider of the presetmap
+// with the preset stored in the API. Both presets are normalized by the
+// provider, so only parameters supported by the provider are compared.
+func (s *TranscodingService) detectDrift(presetMap *db.PresetMap) (*presetDrift, error) {
+	if presetMap.Preset == nil {
+		return nil, errPresetUnknown
+	}
+	drift := presetDrift{
+		PresetMap: presetMap.Name,
+		Providers: make(map[string]providerPresetDrift, len(presetMap.ProviderMapping)),
+	}
+	for name, presetID := range presetMap.ProviderMapping {
+		result := providerPresetDrift{PresetID: presetID}
+		diffs, err := s.providerDrift(name, presetID, *presetMap.Preset)
+		if err != nil {
+			result.Error = err.Error()
+		} else if len(diffs) > 0 {
+			result.Drifted = true
+			result.Differences = diffs
+			drift.Drifted = true
+		}
+		drift.Providers[name] = result
+	}
+	return &drift, nil
+}
+
+func (s *TranscodingService) providerDrift(name, presetID string, preset db.Preset) ([]db.PresetFieldDiff, error) {
+	providerObj, err := s.providerByName(name)
+	if err != nil {
+		return nil, err
+	}
+	normalizer, ok := providerObj.(provider.PresetNormalizer)
+	if !ok {
+		return nil, errors.New("drift detection is not supported by the provider")
+	}
+	expected, err := normalizer.NormalizePreset(preset)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing preset: %s", err)
+	}
+	providerPreset, err := providerObj.GetPreset(presetID)
+	if err != nil {
+		return nil, fmt.Errorf("getting preset: %s", err)
+	}
+	actual, err := normalizer.NormalizePreset(providerPreset)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing provider preset: %s", err)
+	}
+	// providers may change the name of presets when they're re-created.
+	expected.Name, actual.Name = "", ""
+	return db.DiffPresets(expected, actual), nil
+}
+
+// repairDrift re-creates or updates the preset in the providers where it
+// drifted, recording the result of the repair in the drift.
+func (s *TranscodingService) repairDrift(presetMap *db.PresetMap, drift *presetDrift, author string) error {
+	var names []string
+	for name, result := range drift.Providers {
+		if result.Drifted {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	results, err := s.applyPreset(presetMap, *presetMap.Preset, names, author)
+	for _, name := range names {
+		if repair, ok := results[name]; ok {
+			result := drift.Providers[name]
+			result.Repair = &repair
+			drift.Providers[name] = result
+		}
+	}
+	return err
+}
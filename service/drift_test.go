@@ -0,0 +1,197 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/Sirupsen/logrus"
+)
+
+func TestPresetDrift(t *testing.T) {
+	preset := db.Preset{
+		Name:      "preset-1",
+		Container: "mp4",
+		Video:     db.VideoPreset{Codec: "h264", Bitrate: "2000"},
+	}
+	tests := []struct {
+		givenTestCase string
+		givenMethod   string
+		givenName     string
+		givenPreset   *db.Preset
+		givenMapping  map[string]string
+		wantBody      map[string]interface{}
+		wantCode      int
+		wantMapping   map[string]string
+		wantDeleted   []string
+	}{
+		{
+			"No drift",
+			"GET",
+			"preset-1",
+			&preset,
+			map[string]string{"fake": "preset-id"},
+			map[string]interface{}{
+				"presetMap": "preset-1",
+				"drifted":   false,
+				"providers": map[string]interface{}{
+					"fake": map[string]interface{}{"presetId": "preset-id", "drifted": false},
+				},
+			},
+			http.StatusOK,
+			map[string]string{"fake": "preset-id"},
+			nil,
+		},
+		{
+			"Drifted preset",
+			"GET",
+			"preset-1",
+			&preset,
+			map[string]string{"fake": "drifted", "unknown": "123"},
+			map[string]interface{}{
+				"presetMap": "preset-1",
+				"drifted":   true,
+				"providers": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId": "drifted",
+						"drifted":  true,
+						"differences": []interface{}{
+							map[string]interface{}{"field": "container", "from": "mp4", "to": "webm"},
+						},
+					},
+					"unknown": map[string]interface{}{
+						"presetId": "123",
+						"drifted":  false,
+						"error":    "getting factory: provider not found",
+					},
+				},
+			},
+			http.StatusOK,
+			map[string]string{"fake": "drifted", "unknown": "123"},
+			nil,
+		},
+		{
+			"Preset not found in the provider",
+			"GET",
+			"preset-1",
+			&preset,
+			map[string]string{"fake": "missing"},
+			map[string]interface{}{
+				"presetMap": "preset-1",
+				"drifted":   false,
+				"providers": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId": "missing",
+						"drifted":  false,
+						"error":    "getting preset: preset not found",
+					},
+				},
+			},
+			http.StatusOK,
+			map[string]string{"fake": "missing"},
+			nil,
+		},
+		{
+			"Repair drifted preset",
+			"POST",
+			"preset-1",
+			&preset,
+			map[string]string{"fake": "drifted", "unknown": "123"},
+			map[string]interface{}{
+				"presetMap": "preset-1",
+				"drifted":   true,
+				"providers": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId": "drifted",
+						"drifted":  true,
+						"differences": []interface{}{
+							map[string]interface{}{"field": "container", "from": "mp4", "to": "webm"},
+						},
+						"repair": map[string]interface{}{
+							"presetId":         "presetID_here",
+							"previousPresetId": "drifted",
+						},
+					},
+					"unknown": map[string]interface{}{
+						"presetId": "123",
+						"drifted":  false,
+						"error":    "getting factory: provider not found",
+					},
+				},
+			},
+			http.StatusOK,
+			map[string]string{"fake": "presetID_here", "unknown": "123"},
+			[]string{"drifted"},
+		},
+		{
+			"Unknown preset",
+			"GET",
+			"preset-1",
+			nil,
+			map[string]string{"fake": "preset-id"},
+			map[string]interface{}{"error": errPresetUnknown.Error()},
+			http.StatusConflict,
+			map[string]string{"fake": "preset-id"},
+			nil,
+		},
+		{
+			"Preset not found",
+			"GET",
+			"preset-2",
+			&preset,
+			map[string]string{"fake": "preset-id"},
+			map[string]interface{}{"error": "presetmap not found"},
+			http.StatusNotFound,
+			map[string]string{"fake": "preset-id"},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		fprovider.deletedPresets = nil
+		fakeDB := dbtest.NewFakeRepository(false)
+		fakeDB.CreatePresetMap(&db.PresetMap{
+			Name:            "preset-1",
+			ProviderMapping: test.givenMapping,
+			Preset:          test.givenPreset,
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		})
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest(test.givenMethod, "/presets/"+test.givenName+"/drift", nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		err = json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(got, test.wantBody) {
+			t.Errorf("%s: expected response body of\n%#v;\ngot\n%#v", test.givenTestCase, test.wantBody, got)
+		}
+		presetMap, err := fakeDB.GetPresetMap("preset-1")
+		if err != nil {
+			t.Fatalf("%s: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(presetMap.ProviderMapping, test.wantMapping) {
+			t.Errorf("%s: wrong provider mapping.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantMapping, presetMap.ProviderMapping)
+		}
+		if !reflect.DeepEqual(fprovider.deletedPresets, test.wantDeleted) {
+			t.Errorf("%s: wrong deleted presets.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantDeleted, fprovider.deletedPresets)
+		}
+	}
+}
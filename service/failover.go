@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+// submissionError is returned when the API fails to submit a job to the
+// providers. Invalid errors are caused by the request itself (for example,
+// presets that are not available in the provider), and are reported as bad
+// requests.
+type submissionError struct {
+	err     error
+	invalid bool
+}
+
+func (e *submissionError) Error() string {
+	return e.err.Error()
+}
+
+// parseFailoverPolicies parses the FAILOVER_POLICIES configuration, in the
+// format "name:provider1,provider2;other:provider3".
+func parseFailoverPolicies(value string) (map[string][]string, error) {
+	policies := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, fmt.Errorf("invalid failover policy %q", entry)
+		}
+		var providers []string
+		for _, providerName := range strings.Split(parts[1], ",") {
+			providerName = strings.TrimSpace(providerName)
+			if providerName != "" {
+				providers = append(providers, providerName)
+			}
+		}
+		if len(providers) == 0 {
+			return nil, fmt.Errorf("invalid failover policy %q: missing providers", entry)
+		}
+		policies[name] = providers
+	}
+	return policies, nil
+}
+
+// transcode submits the job to the given providers, in order, until one of
+// them accepts it. Every attempt is recorded in the job.
+func (s *TranscodingService) transcode(job *db.Job, providerNames []string) (*provider.JobStatus, error) {
+	var errs []string
+	invalid := true
+	var lastErr *submissionError
+	for _, providerName := range providerNames {
+		attempt := db.ProviderAttempt{ProviderName: providerName, Time: time.Now().UTC()}
+		jobStatus, err := s.attemptTranscode(job, providerName)
+		if err == nil {
+			job.ProviderAttempts = append(job.ProviderAttempts, attempt)
+			job.ProviderName = providerName
+			jobStatus.ProviderName = providerName
+			return jobStatus, nil
+		}
+		attempt.Error = err.Error()
+		job.ProviderAttempts = append(job.ProviderAttempts, attempt)
+		s.logger.WithError(err).Warnf("failed to submit job %q to provider %q", job.ID, providerName)
+		errs = append(errs, providerName+": "+err.Error())
+		invalid = invalid && err.invalid
+		lastErr = err
+	}
+	if len(providerNames) == 1 {
+		return nil, lastErr
+	}
+	return nil, &submissionError{
+		err:     errors.New("no provider accepted the job: " + strings.Join(errs, "; ")),
+		invalid: invalid,
+	}
+}
+
+func (s *TranscodingService) attemptTranscode(job *db.Job, providerName string) (*provider.JobStatus, *submissionError) {
+	for _, output := range job.Outputs {
+		if _, ok := output.Preset.ProviderMapping[providerName]; !ok {
+			return nil, &submissionError{err: provider.ErrPresetMapNotFound, invalid: true}
+		}
+	}
+	providerFactory, err := provider.GetProviderFactory(providerName)
+	if err != nil {
+		return nil, &submissionError{err: err, invalid: true}
+	}
+	providerObj, err := providerFactory(s.config)
+	if err != nil {
+		_, invalid := err.(provider.InvalidConfigError)
+		formattedErr := fmt.Errorf("Error initializing provider %s for new job: %v %s", providerName, providerObj, err)
+		return nil, &submissionError{err: formattedErr, invalid: invalid}
+	}
+	capabilities := providerObj.Capabilities()
+	if protocol := unsupportedProtocol(job, capabilities); protocol != "" {
+		return nil, &submissionError{err: fmt.Errorf("provider %q doesn't support %s outputs", providerName, protocol), invalid: true}
+	}
+	if job.Destination != "" {
+		destinationType, _ := provider.DestinationType(job.Destination)
+		if !containsString(capabilities.Destinations, destinationType) {
+			return nil, &submissionError{err: fmt.Errorf("provider %q doesn't support %s destinations", providerName, destinationType), invalid: true}
+		}
+	}
+	if job.Thumbnails != nil {
+		if err = capabilities.Thumbnails.Check(job.Thumbnails); err != nil {
+			return nil, &submissionError{err: fmt.Errorf("provider %q doesn't support the thumbnails of the job: %s", providerName, err), invalid: true}
+		}
+	}
+	if err = capabilities.Clipping.Check(job.Clipping()); err != nil {
+		return nil, &submissionError{err: fmt.Errorf("provider %q doesn't support the clipping of the job: %s", providerName, err), invalid: true}
+	}
+	if health := s.providerHealth(providerName, providerObj); !health.OK {
+		return nil, &submissionError{err: fmt.Errorf("provider %q is unhealthy: %s", providerName, health.Message)}
+	}
+	jobStatus, err := providerObj.Transcode(job)
+	if err == provider.ErrPresetMapNotFound {
+		return nil, &submissionError{err: err, invalid: true}
+	}
+	if err != nil {
+		return nil, &submissionError{err: fmt.Errorf("Error with provider %q: %s", providerName, err)}
+	}
+	return jobStatus, nil
+}
+
+// unsupportedProtocol returns the adaptive streaming protocol used by the job,
+// either in its streaming params or in the format of its outputs, that is not
+// listed in the given capabilities. It returns an empty string when all
+// protocols are supported.
+func unsupportedProtocol(job *db.Job, capabilities provider.Capabilities) string {
+	var protocols []string
+	if job.StreamingParams.Protocol != "" {
+		protocols = append(protocols, job.StreamingParams.Protocol)
+	}
+	for _, output := range job.Outputs {
+		format := outputFormat(&output.Preset)
+		if _, ok := defaultPlaylistFileNames[format]; ok {
+			protocols = appendUnique(protocols, format)
+		}
+	}
+	for _, protocol := range protocols {
+		if !containsString(capabilities.OutputFormats, protocol) {
+			return protocol
+		}
+	}
+	return ""
+}
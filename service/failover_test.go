@@ -0,0 +1,254 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/Sirupsen/logrus"
+)
+
+func TestParseFailoverPolicies(t *testing.T) {
+	var tests = []struct {
+		givenValue   string
+		wantPolicies map[string][]string
+		wantErr      bool
+	}{
+		{
+			"",
+			map[string][]string{},
+			false,
+		},
+		{
+			"default:zencoder,encodingcom; hls : bitmovin ,elastictranscoder;",
+			map[string][]string{
+				"default": {"zencoder", "encodingcom"},
+				"hls":     {"bitmovin", "elastictranscoder"},
+			},
+			false,
+		},
+		{
+			"default",
+			nil,
+			true,
+		},
+		{
+			"default:",
+			nil,
+			true,
+		},
+		{
+			":zencoder",
+			nil,
+			true,
+		},
+	}
+	for _, test := range tests {
+		policies, err := parseFailoverPolicies(test.givenValue)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: wrong error returned. Want error: %v. Got %v", test.givenValue, test.wantErr, err)
+		}
+		if !reflect.DeepEqual(policies, test.wantPolicies) {
+			t.Errorf("%q: wrong policies returned\nwant %#v\ngot  %#v", test.givenValue, test.wantPolicies, policies)
+		}
+	}
+}
+
+func TestTranscodeFailover(t *testing.T) {
+	var tests = []struct {
+		givenTestCase      string
+		givenRequestBody   string
+		givenTranscodeErrs []error
+		givenHealthErrs    []error
+
+		wantCode         int
+		wantError        string
+		wantProviderName string
+		wantAttempts     []db.ProviderAttempt
+	}{
+		{
+			"list of providers, first one fails",
+			`{"source":"http://some.source/video.mp4","outputs":[{"preset":"mp4_1080p"}],"providers":["fake","zencoder"]}`,
+			[]error{errors.New("something went wrong")},
+			nil,
+
+			http.StatusOK,
+			"",
+			"zencoder",
+			[]db.ProviderAttempt{
+				{ProviderName: "fake", Error: `Error with provider "fake": something went wrong`},
+				{ProviderName: "zencoder"},
+			},
+		},
+		{
+			"list of providers, first one is unhealthy",
+			`{"source":"http://some.source/video.mp4","outputs":[{"preset":"mp4_1080p"}],"providers":["fake","zencoder"]}`,
+			nil,
+			[]error{errors.New("service unavailable")},
+
+			http.StatusOK,
+			"",
+			"zencoder",
+			[]db.ProviderAttempt{
+				{ProviderName: "fake", Error: `provider "fake" is unhealthy: service unavailable`},
+				{ProviderName: "zencoder"},
+			},
+		},
+		{
+			"list of providers, first one doesn't have the presets",
+			`{"source":"http://some.source/video.mp4","outputs":[{"preset":"mp4_720p"}],"providers":["zencoder","fake"]}`,
+			nil,
+			nil,
+
+			http.StatusOK,
+			"",
+			"fake",
+			[]db.ProviderAttempt{
+				{ProviderName: "zencoder", Error: provider.ErrPresetMapNotFound.Error()},
+				{ProviderName: "fake"},
+			},
+		},
+		{
+			"failover policy",
+			`{"source":"http://some.source/video.mp4","outputs":[{"preset":"mp4_1080p"}],"policy":"default"}`,
+			nil,
+			nil,
+
+			http.StatusOK,
+			"",
+			"zencoder",
+			[]db.ProviderAttempt{{ProviderName: "zencoder"}},
+		},
+		{
+			"all providers fail",
+			`{"source":"http://some.source/video.mp4","outputs":[{"preset":"mp4_1080p"}],"providers":["fake","zencoder"]}`,
+			[]error{errors.New("something went wrong"), errors.New("something else went wrong")},
+			nil,
+
+			http.StatusInternalServerError,
+			`no provider accepted the job: fake: Error with provider "fake": something went wrong; zencoder: Error with provider "zencoder": something else went wrong`,
+			"",
+			nil,
+		},
+		{
+			"no provider supports the presets",
+			`{"source":"http://some.source/video.mp4","outputs":[{"preset":"mp4_360p"}],"providers":["fake","zencoder"]}`,
+			nil,
+			nil,
+
+			http.StatusBadRequest,
+			"no provider accepted the job: fake: preset not found in provider; zencoder: preset not found in provider",
+			"",
+			nil,
+		},
+		{
+			"unknown failover policy",
+			`{"source":"http://some.source/video.mp4","outputs":[{"preset":"mp4_1080p"}],"policy":"premium"}`,
+			nil,
+			nil,
+
+			http.StatusBadRequest,
+			`unknown failover policy "premium"`,
+			"",
+			nil,
+		},
+		{
+			"unknown provider in the list",
+			`{"source":"http://some.source/video.mp4","outputs":[{"preset":"mp4_1080p"}],"providers":["fake","nonexistent-provider"]}`,
+			nil,
+			nil,
+
+			http.StatusBadRequest,
+			"provider not found: nonexistent-provider",
+			"",
+			nil,
+		},
+		{
+			"provider and policy",
+			`{"source":"http://some.source/video.mp4","outputs":[{"preset":"mp4_1080p"}],"provider":"fake","policy":"default"}`,
+			nil,
+			nil,
+
+			http.StatusBadRequest,
+			"provider, providers and policy are mutually exclusive",
+			"",
+			nil,
+		},
+	}
+	for _, test := range tests {
+		fprovider.jobs = nil
+		fprovider.transcodeErrs = test.givenTranscodeErrs
+		fprovider.healthErrs = test.givenHealthErrs
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDBObj := dbtest.NewFakeRepository(false)
+		fakeDBObj.CreatePresetMap(&db.PresetMap{
+			Name:            "mp4_1080p",
+			ProviderMapping: map[string]string{"fake": "18828", "zencoder": "18828"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		})
+		fakeDBObj.CreatePresetMap(&db.PresetMap{
+			Name:            "mp4_720p",
+			ProviderMapping: map[string]string{"fake": "17727"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		})
+		fakeDBObj.CreatePresetMap(&db.PresetMap{
+			Name:            "mp4_360p",
+			ProviderMapping: map[string]string{"elementalconductor": "172712"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		})
+		service, err := NewTranscodingService(&config.Config{FailoverPolicies: "default:zencoder,fake"}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDBObj
+		srvr.Register(service)
+		r, _ := http.NewRequest("POST", "/jobs", strings.NewReader(test.givenRequestBody))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		err = json.Unmarshal(w.Body.Bytes(), &got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+			continue
+		}
+		if test.wantCode != http.StatusOK {
+			if got["error"] != test.wantError {
+				t.Errorf("%s: wrong error returned\nwant %q\ngot  %q", test.givenTestCase, test.wantError, got["error"])
+			}
+			continue
+		}
+		job, err := fakeDBObj.GetJob(got["jobId"].(string))
+		if err != nil {
+			t.Errorf("%s: %s", test.givenTestCase, err)
+			continue
+		}
+		if job.ProviderName != test.wantProviderName {
+			t.Errorf("%s: wrong provider name. Want %q. Got %q", test.givenTestCase, test.wantProviderName, job.ProviderName)
+		}
+		for i := range job.ProviderAttempts {
+			if job.ProviderAttempts[i].Time.IsZero() {
+				t.Errorf("%s: missing time in attempt %d", test.givenTestCase, i)
+			}
+			job.ProviderAttempts[i].Time = time.Time{}
+		}
+		if !reflect.DeepEqual(job.ProviderAttempts, test.wantAttempts) {
+			t.Errorf("%s: wrong provider attempts\nwant %#v\ngot  %#v", test.givenTestCase, test.wantAttempts, job.ProviderAttempts)
+		}
+	}
+	fprovider.transcodeErrs = nil
+	fprovider.healthErrs = nil
+}
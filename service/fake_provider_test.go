@@ -1,6 +1,10 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
 	"github.com/NYTimes/video-transcoding-api/config"
 	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/provider"
@@ -12,13 +16,28 @@ func init() {
 }
 
 type fakeProvider struct {
-	jobs         []*db.Job
-	canceledJobs []string
+	jobs           []*db.Job
+	canceledJobs   []string
+	deletedPresets []string
+
+	// errors returned by the next calls to Transcode and Healthcheck
+	transcodeErrs []error
+	healthErrs    []error
+
+	// whether DASH is listed in the capabilities of the provider
+	dashSupport bool
 }
 
 var fprovider fakeProvider
 
 func (p *fakeProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
+	if len(p.transcodeErrs) > 0 {
+		err := p.transcodeErrs[0]
+		p.transcodeErrs = p.transcodeErrs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
 	for _, output := range job.Outputs {
 		if _, ok := output.Preset.ProviderMapping["fake"]; !ok {
 			return nil, provider.ErrPresetMapNotFound
@@ -37,14 +56,37 @@ func (p *fakeProvider) Transcode(job *db.Job) (*provider.JobStatus, error) {
 }
 
 func (*fakeProvider) CreatePreset(preset db.Preset) (string, error) {
+	if preset.Video.Codec == "av1" {
+		return "", provider.UnsupportedParameterError{Parameter: "video.codec", Value: preset.Video.Codec}
+	}
 	return "presetID_here", nil
 }
 
 func (*fakeProvider) GetPreset(presetID string) (interface{}, error) {
-	return struct{ presetID string }{"presetID_here"}, nil
+	if presetID == "missing" {
+		return nil, errors.New("preset not found")
+	}
+	return map[string]string{"presetID": presetID}, nil
+}
+
+// NormalizePreset only takes the container into account, and presets with ID
+// "drifted" always have the webm container in the provider.
+func (*fakeProvider) NormalizePreset(preset interface{}) (db.Preset, error) {
+	switch preset := preset.(type) {
+	case db.Preset:
+		return db.Preset{Container: preset.Container}, nil
+	case map[string]string:
+		if preset["presetID"] == "drifted" {
+			return db.Preset{Container: "webm"}, nil
+		}
+		return db.Preset{Container: "mp4"}, nil
+	default:
+		return db.Preset{}, errors.New("unexpected preset type")
+	}
 }
 
-func (*fakeProvider) DeletePreset(presetID string) error {
+func (p *fakeProvider) DeletePreset(presetID string) error {
+	p.deletedPresets = append(p.deletedPresets, presetID)
 	return nil
 }
 
@@ -86,16 +128,43 @@ func (p *fakeProvider) CancelJob(id string) error {
 	return provider.JobNotFoundError{ID: id}
 }
 
+func (p *fakeProvider) HandleNotification(r *http.Request) (*provider.JobStatus, error) {
+	err := provider.CheckNotificationToken(r, "fake-token")
+	if err != nil {
+		return nil, err
+	}
+	var status provider.JobStatus
+	err = json.NewDecoder(r.Body).Decode(&status)
+	if err != nil {
+		return nil, provider.InvalidNotificationError(err.Error())
+	}
+	if status.ProviderJobID == "" {
+		return nil, nil
+	}
+	return &status, nil
+}
+
 func (p *fakeProvider) Healthcheck() error {
+	if len(p.healthErrs) > 0 {
+		err := p.healthErrs[0]
+		p.healthErrs = p.healthErrs[1:]
+		return err
+	}
 	return nil
 }
 
 func (p *fakeProvider) Capabilities() provider.Capabilities {
-	return provider.Capabilities{
+	capabilities := provider.Capabilities{
 		InputFormats:  []string{"prores", "h264"},
 		OutputFormats: []string{"mp4", "webm", "hls"},
 		Destinations:  []string{"akamai", "s3"},
+		Thumbnails:    &provider.ThumbnailCapabilities{Formats: []string{"jpg"}},
+		Clipping:      &provider.ClippingCapabilities{},
+	}
+	if p.dashSupport {
+		capabilities.OutputFormats = append(capabilities.OutputFormats, "dash")
 	}
+	return capabilities
 }
 
 func fakeProviderFactory(cfg *config.Config) (provider.TranscodingProvider, error) {
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+const defaultHealthMonitorInterval = time.Minute
+
+// healthMonitor periodically runs the healthcheck of the enabled providers,
+// so job submissions can use the cached health instead of checking the
+// provider on every request.
+type healthMonitor struct {
+	service  *TranscodingService
+	interval time.Duration
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newHealthMonitor(s *TranscodingService) *healthMonitor {
+	return &healthMonitor{service: s, interval: defaultHealthMonitorInterval}
+}
+
+func (m *healthMonitor) start() {
+	m.done = make(chan struct{})
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.service.refreshProviderHealth()
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.service.refreshProviderHealth()
+			case <-m.done:
+				return
+			}
+		}
+	}()
+}
+
+func (m *healthMonitor) stop() {
+	close(m.done)
+	m.wg.Wait()
+}
+
+// StartHealthMonitor starts the background worker that refreshes the health
+// of the providers.
+func (s *TranscodingService) StartHealthMonitor() {
+	s.healthMonitor = newHealthMonitor(s)
+	s.healthMonitor.start()
+}
+
+// StopHealthMonitor stops the background worker that refreshes the health of
+// the providers, waiting for the current run to finish.
+func (s *TranscodingService) StopHealthMonitor() {
+	if s.healthMonitor != nil {
+		s.healthMonitor.stop()
+		s.healthMonitor = nil
+	}
+}
+
+// refreshProviderHealth runs the healthcheck of all enabled providers and
+// caches the results.
+func (s *TranscodingService) refreshProviderHealth() {
+	for _, providerName := range provider.ListProviders(s.config) {
+		providerFactory, err := provider.GetProviderFactory(providerName)
+		if err != nil {
+			continue
+		}
+		providerObj, err := providerFactory(s.config)
+		if err != nil {
+			continue
+		}
+		s.setProviderHealth(providerName, checkHealth(providerObj))
+	}
+}
+
+// providerHealth returns the cached health of the given provider. The
+// healthcheck only runs when the health of the provider wasn't cached yet.
+func (s *TranscodingService) providerHealth(providerName string, providerObj provider.TranscodingProvider) provider.Health {
+	s.healthMtx.RLock()
+	health, ok := s.health[providerName]
+	s.healthMtx.RUnlock()
+	if ok {
+		return health
+	}
+	health = checkHealth(providerObj)
+	s.setProviderHealth(providerName, health)
+	return health
+}
+
+func (s *TranscodingService) setProviderHealth(providerName string, health provider.Health) {
+	s.healthMtx.Lock()
+	defer s.healthMtx.Unlock()
+	if s.health == nil {
+		s.health = make(map[string]provider.Health)
+	}
+	s.health[providerName] = health
+}
+
+func checkHealth(providerObj provider.TranscodingProvider) provider.Health {
+	if err := providerObj.Healthcheck(); err != nil {
+		return provider.Health{OK: false, Message: err.Error()}
+	}
+	return provider.Health{OK: true}
+}
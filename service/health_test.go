@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/Sirupsen/logrus"
+)
+
+func TestHealthMonitor(t *testing.T) {
+	fprovider.healthErrs = []error{errors.New("service unavailable")}
+	defer func() { fprovider.healthErrs = nil }()
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	monitor := newHealthMonitor(service)
+	monitor.interval = time.Hour
+	monitor.start()
+	time.Sleep(50 * time.Millisecond)
+	monitor.stop()
+	want := provider.Health{OK: false, Message: "service unavailable"}
+	if health := service.providerHealth("fake", &fprovider); !reflect.DeepEqual(health, want) {
+		t.Errorf("wrong cached health. Want %#v. Got %#v", want, health)
+	}
+	service.refreshProviderHealth()
+	want = provider.Health{OK: true}
+	if health := service.providerHealth("fake", &fprovider); !reflect.DeepEqual(health, want) {
+		t.Errorf("wrong refreshed health. Want %#v. Got %#v", want, health)
+	}
+}
+
+func TestProviderHealthIsCached(t *testing.T) {
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fprovider.healthErrs = nil
+	if health := service.providerHealth("fake", &fprovider); !health.OK {
+		t.Fatalf("unexpected unhealthy provider: %#v", health)
+	}
+	fprovider.healthErrs = []error{errors.New("service unavailable")}
+	defer func() { fprovider.healthErrs = nil }()
+	if health := service.providerHealth("fake", &fprovider); !health.OK {
+		t.Errorf("healthcheck ran again instead of using the cached health: %#v", health)
+	}
+}
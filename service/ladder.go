@@ -0,0 +1,270 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/NYTimes/gizmo/web"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+// swagger:route POST /ladders ladders newLadder
+//
+// Creates a new ladder in the API. All presetmaps used in the rungs of the
+// ladder must exist.
+//
+//     Responses:
+//       200: ladder
+//       400: invalidLadder
+//       409: ladderAlreadyExists
+//       500: genericError
+func (s *TranscodingService) newLadder(r *http.Request) swagger.GizmoJSONResponse {
+	defer r.Body.Close()
+	var input newLadderInput
+	ladder, err := input.Ladder(r.Body)
+	if err != nil {
+		return newInvalidLadderResponse(err)
+	}
+	missing, err := s.missingLadderPresetMap(&ladder)
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	if missing != "" {
+		return newInvalidLadderResponse(fmt.Errorf("presetmap %q not found", missing))
+	}
+	err = s.db.CreateLadder(&ladder)
+	switch err {
+	case nil:
+		return newLadderResponse(&ladder)
+	case db.ErrLadderAlreadyExists:
+		return newLadderAlreadyExistsResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+}
+
+// swagger:route GET /ladders/{name} ladders getLadder
+//
+// Finds a ladder using its name.
+//
+//     Responses:
+//       200: ladder
+//       404: ladderNotFound
+//       500: genericError
+func (s *TranscodingService) getLadder(r *http.Request) swagger.GizmoJSONResponse {
+	var params getLadderInput
+	params.loadParams(web.Vars(r))
+	ladder, err := s.db.GetLadder(params.Name)
+	switch err {
+	case nil:
+		return newLadderResponse(ladder)
+	case db.ErrLadderNotFound:
+		return newLadderNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+}
+
+// swagger:route PUT /ladders/{name} ladders updateLadder
+//
+// Updates a ladder using its name. Jobs already created keep the rungs they
+// were resolved to.
+//
+//     Responses:
+//       200: ladder
+//       400: invalidLadder
+//       404: ladderNotFound
+//       500: genericError
+func (s *TranscodingService) updateLadder(r *http.Request) swagger.GizmoJSONResponse {
+	defer r.Body.Close()
+	var input updateLadderInput
+	ladder, err := input.Ladder(web.Vars(r), r.Body)
+	if err != nil {
+		return newInvalidLadderResponse(err)
+	}
+	missing, err := s.missingLadderPresetMap(&ladder)
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	if missing != "" {
+		return newInvalidLadderResponse(fmt.Errorf("presetmap %q not found", missing))
+	}
+	err = s.db.UpdateLadder(&ladder)
+	switch err {
+	case nil:
+		return newLadderResponse(&ladder)
+	case db.ErrLadderNotFound:
+		return newLadderNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+}
+
+// swagger:route DELETE /ladders/{name} ladders deleteLadder
+//
+// Deletes a ladder by name.
+//
+//     Responses:
+//       200: emptyResponse
+//       404: ladderNotFound
+//       500: genericError
+func (s *TranscodingService) deleteLadder(r *http.Request) swagger.GizmoJSONResponse {
+	var params getLadderInput
+	params.loadParams(web.Vars(r))
+	err := s.db.DeleteLadder(&db.Ladder{Name: params.Name})
+	switch err {
+	case nil:
+		return emptyResponse(http.StatusOK)
+	case db.ErrLadderNotFound:
+		return newLadderNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+}
+
+// swagger:route GET /ladders ladders listLadders
+//
+// List available ladders on the API.
+//
+//     Responses:
+//       200: listLadders
+//       500: genericError
+func (s *TranscodingService) listLadders(r *http.Request) swagger.GizmoJSONResponse {
+	ladders, err := s.db.ListLadders()
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	return newListLaddersResponse(ladders)
+}
+
+// missingLadderPresetMap returns the name of the first presetmap used in the
+// ladder that doesn't exist, or an empty string when all of them exist.
+func (s *TranscodingService) missingLadderPresetMap(ladder *db.Ladder) (string, error) {
+	for _, rung := range ladder.Rungs {
+		_, err := s.db.GetPresetMap(rung.PresetMap)
+		if err == db.ErrPresetMapNotFound {
+			return rung.PresetMap, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// addLadderOutputs resolves the ladder with the given name using the probed
+// source media, which may be nil when the source couldn't be probed, and adds
+// the chosen rungs to the outputs of the job. Rungs with a capped bitrate use
+// a presetmap generated with the capped bitrate. Rungs whose presetmap can't
+// be capped, as it doesn't have a preset definition, are recorded with their
+// original bitrate.
+func (s *TranscodingService) addLadderOutputs(job *db.Job, name string, source *provider.SourceInfo) error {
+	ladder, err := s.db.GetLadder(name)
+	if err != nil {
+		return err
+	}
+	if source == nil {
+		source = &provider.SourceInfo{}
+	}
+	bitrates := make(map[string]int, len(ladder.Rungs))
+	for _, rung := range ladder.Rungs {
+		bitrates[rung.PresetMap] = rung.Bitrate
+	}
+	resolved := ladder.Resolve(int(source.Width), int(source.Height), int(source.Bitrate), source.Duration)
+	for i, rung := range resolved.Rungs {
+		presetMap, err := s.db.GetPresetMap(rung.PresetMap)
+		if err != nil {
+			return err
+		}
+		presetMap, capped, err := s.cappedPresetMap(presetMap, rung.Bitrate)
+		if err != nil {
+			return err
+		}
+		if !capped {
+			resolved.Rungs[i].Bitrate = bitrates[rung.PresetMap]
+		}
+		job.Outputs = append(job.Outputs, db.TranscodeOutput{
+			FileName:      s.defaultFileName(job.SourceMedia, presetMap),
+			Preset:        *presetMap,
+			PresetVersion: presetMap.Version,
+		})
+	}
+	job.Ladder = &resolved
+	return nil
+}
+
+// cappedPresetMap returns the presetmap to use for a rung with the given
+// bitrate: the given presetmap when its video bitrate doesn't exceed the
+// bitrate, or a presetmap generated from it with the bitrate of the rung,
+// created on the providers of the given presetmap when it doesn't exist yet.
+// It also returns whether the bitrate of the returned presetmap is within the
+// given bitrate, which isn't the case for presetmaps without a preset
+// definition or with an unknown bitrate.
+func (s *TranscodingService) cappedPresetMap(presetMap *db.PresetMap, bitrate int) (*db.PresetMap, bool, error) {
+	if presetMap.Preset == nil {
+		return presetMap, false, nil
+	}
+	presetBitrate, err := strconv.Atoi(presetMap.Preset.Video.Bitrate)
+	if err != nil {
+		return presetMap, false, nil
+	}
+	if presetBitrate <= bitrate {
+		return presetMap, true, nil
+	}
+	preset := *presetMap.Preset
+	preset.Name = fmt.Sprintf("%s_%dk", presetMap.Name, bitrate/1000)
+	preset.Video.Bitrate = strconv.Itoa(bitrate)
+	providers := make([]string, 0, len(presetMap.ProviderMapping))
+	for name := range presetMap.ProviderMapping {
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+	capped, err := s.generatedPresetMap(preset.Name, providers)
+	if capped != nil || err != nil {
+		return capped, true, err
+	}
+	output, _, err := s.createPreset(newPresetInput{
+		Providers:     providers,
+		Preset:        preset,
+		OutputOptions: presetMap.OutputOpts,
+	}, presetMap.Author)
+	if err != nil || output.PresetMap == "" {
+		// concurrent jobs may generate the same presetmap, in which case
+		// creating the preset fails because it already exists.
+		if capped, gerr := s.generatedPresetMap(preset.Name, providers); capped != nil || gerr != nil {
+			return capped, true, gerr
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if output.PresetMap == "" {
+		for _, name := range providers {
+			if result := output.Results[name]; result.Error != "" {
+				return nil, false, fmt.Errorf("error generating preset %q with the capped bitrate on %s: %s", preset.Name, name, result.Error)
+			}
+		}
+	}
+	capped, err = s.db.GetPresetMap(preset.Name)
+	return capped, err == nil, err
+}
+
+// generatedPresetMap returns the presetmap with the given name when it exists
+// and is mapped to all the given providers, or nil otherwise.
+func (s *TranscodingService) generatedPresetMap(name string, providers []string) (*db.PresetMap, error) {
+	presetMap, err := s.db.GetPresetMap(name)
+	if err == db.ErrPresetMapNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(s.getMissingProviders(providers, presetMap.ProviderMapping)) > 0 {
+		return nil, nil
+	}
+	return presetMap, nil
+}
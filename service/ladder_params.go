@@ -0,0 +1,154 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+// JSON-encoded ladder returned on the newLadder, getLadder and updateLadder
+// operations.
+//
+// swagger:response ladder
+type ladderResponse struct {
+	// in: body
+	Payload *db.Ladder
+
+	baseResponse
+}
+
+// swagger:parameters getLadder deleteLadder
+type getLadderInput struct {
+	// in: path
+	// required: true
+	Name string `json:"name"`
+}
+
+// swagger:parameters updateLadder
+type updateLadderInput struct {
+	// in: path
+	// required: true
+	Name string `json:"name"`
+
+	// in: body
+	// required: true
+	Payload db.Ladder
+}
+
+// swagger:parameters newLadder
+type newLadderInput struct {
+	// in: body
+	// required: true
+	Payload db.Ladder
+}
+
+// error returned when the given ladder name is not found on the API.
+//
+// swagger:response ladderNotFound
+type ladderNotFoundResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+// error returned when the given ladder data is not valid.
+//
+// swagger:response invalidLadder
+type invalidLadderResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+// error returned when trying to create a new ladder using a name that is
+// already in-use.
+//
+// swagger:response ladderAlreadyExists
+type ladderAlreadyExistsResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+// response for the listLadders operation. It's actually a JSON-encoded object
+// instead of an array, in the format `ladderName: ladderObject`
+//
+// swagger:response listLadders
+type listLaddersResponse struct {
+	// in: body
+	Ladders map[string]db.Ladder
+
+	baseResponse
+}
+
+func newLadderResponse(ladder *db.Ladder) *ladderResponse {
+	return &ladderResponse{
+		baseResponse: baseResponse{
+			payload: ladder,
+			status:  http.StatusOK,
+		},
+	}
+}
+
+func newLadderNotFoundResponse(err error) *ladderNotFoundResponse {
+	return &ladderNotFoundResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusNotFound)}
+}
+
+func (r *ladderNotFoundResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
+
+func newInvalidLadderResponse(err error) *invalidLadderResponse {
+	return &invalidLadderResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusBadRequest)}
+}
+
+func (r *invalidLadderResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
+
+func newLadderAlreadyExistsResponse(err error) *ladderAlreadyExistsResponse {
+	return &ladderAlreadyExistsResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusConflict)}
+}
+
+func (r *ladderAlreadyExistsResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
+
+func newListLaddersResponse(ladders []db.Ladder) *listLaddersResponse {
+	ladderMap := make(map[string]db.Ladder, len(ladders))
+	for _, ladder := range ladders {
+		ladderMap[ladder.Name] = ladder
+	}
+	return &listLaddersResponse{
+		baseResponse: baseResponse{
+			status:  http.StatusOK,
+			payload: ladderMap,
+		},
+	}
+}
+
+// Ladder loads the input from the request body, validates it and returns the
+// ladder.
+func (p *newLadderInput) Ladder(body io.Reader) (db.Ladder, error) {
+	err := json.NewDecoder(body).Decode(&p.Payload)
+	if err != nil {
+		return p.Payload, err
+	}
+	return p.Payload, p.Payload.Validate()
+}
+
+func (p *getLadderInput) loadParams(paramsMap map[string]string) {
+	p.Name = paramsMap["name"]
+}
+
+// Ladder loads the input from the path and the request body, validates it
+// and returns the ladder.
+func (p *updateLadderInput) Ladder(paramsMap map[string]string, body io.Reader) (db.Ladder, error) {
+	p.Name = paramsMap["name"]
+	err := json.NewDecoder(body).Decode(&p.Payload)
+	if err != nil {
+		return p.Payload, err
+	}
+	p.Payload.Name = p.Name
+	return p.Payload, p.Payload.Validate()
+}
@@ -0,0 +1,616 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/Sirupsen/logrus"
+)
+
+var testLadderRungs = []db.LadderRung{
+	{PresetMap: "mp4_1080p", Width: 1920, Height: 1080, Bitrate: 5000000},
+	{PresetMap: "mp4_720p", Width: 1280, Height: 720, Bitrate: 3000000},
+	{PresetMap: "mp4_360p", Width: 640, Height: 360, Bitrate: 800000},
+}
+
+func newLadderTestDB(triggerError bool) db.Repository {
+	fakeDB := dbtest.NewFakeRepository(triggerError)
+	for _, rung := range testLadderRungs {
+		fakeDB.CreatePresetMap(&db.PresetMap{
+			Name:            rung.PresetMap,
+			ProviderMapping: map[string]string{"fake": "18828"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			Preset: &db.Preset{
+				Name:      rung.PresetMap,
+				Container: "mp4",
+				Video:     db.VideoPreset{Codec: "h264", Height: strconv.Itoa(rung.Height), Bitrate: strconv.Itoa(rung.Bitrate)},
+				Audio:     db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+			},
+		})
+	}
+	fakeDB.CreateLadder(&db.Ladder{Name: "web", Rungs: testLadderRungs})
+	return fakeDB
+}
+
+func TestNewLadder(t *testing.T) {
+	tests := []struct {
+		givenTestCase       string
+		givenRequestData    map[string]interface{}
+		givenTriggerDBError bool
+
+		wantCode  int
+		wantError string
+	}{
+		{
+			"New ladder",
+			map[string]interface{}{
+				"name":  "mobile",
+				"rungs": testLadderRungs[1:],
+			},
+			false,
+			http.StatusOK,
+			"",
+		},
+		{
+			"New ladder duplicate name",
+			map[string]interface{}{
+				"name":  "web",
+				"rungs": testLadderRungs,
+			},
+			false,
+			http.StatusConflict,
+			db.ErrLadderAlreadyExists.Error(),
+		},
+		{
+			"New ladder missing rungs",
+			map[string]interface{}{"name": "mobile"},
+			false,
+			http.StatusBadRequest,
+			"missing field rungs from the request",
+		},
+		{
+			"New ladder invalid rung",
+			map[string]interface{}{
+				"name":  "mobile",
+				"rungs": []map[string]interface{}{{"presetmap": "mp4_720p", "height": 720}},
+			},
+			false,
+			http.StatusBadRequest,
+			"invalid rung 0: bitrate must be positive",
+		},
+		{
+			"New ladder unknown presetmap",
+			map[string]interface{}{
+				"name":  "mobile",
+				"rungs": []map[string]interface{}{{"presetmap": "webm_720p", "height": 720, "bitrate": 3000000}},
+			},
+			false,
+			http.StatusBadRequest,
+			`presetmap "webm_720p" not found`,
+		},
+		{
+			"New ladder DB failure",
+			map[string]interface{}{
+				"name":  "mobile",
+				"rungs": testLadderRungs[1:],
+			},
+			true,
+			http.StatusInternalServerError,
+			"database error",
+		},
+	}
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDB := newLadderTestDB(test.givenTriggerDBError)
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		body, _ := json.Marshal(test.givenRequestData)
+		r, _ := http.NewRequest("POST", "/ladders", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		if test.wantCode != http.StatusOK {
+			var got map[string]interface{}
+			if err = json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+			}
+			if got["error"] != test.wantError {
+				t.Errorf("%s: wrong error. Want %q. Got %q", test.givenTestCase, test.wantError, got["error"])
+			}
+			continue
+		}
+		ladder, err := fakeDB.GetLadder("mobile")
+		if err != nil {
+			t.Errorf("%s: didn't save the ladder in the database: %s", test.givenTestCase, err)
+		} else if !reflect.DeepEqual(ladder.Rungs, testLadderRungs[1:]) {
+			t.Errorf("%s: wrong rungs saved. Want %#v. Got %#v", test.givenTestCase, testLadderRungs[1:], ladder.Rungs)
+		}
+	}
+}
+
+func TestGetLadder(t *testing.T) {
+	tests := []struct {
+		givenTestCase   string
+		givenLadderName string
+
+		wantBody *db.Ladder
+		wantCode int
+	}{
+		{
+			"Get ladder",
+			"web",
+			&db.Ladder{Name: "web", Rungs: testLadderRungs},
+			http.StatusOK,
+		},
+		{
+			"Get ladder not found",
+			"mobile",
+			nil,
+			http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = newLadderTestDB(false)
+		srvr.Register(service)
+		r, _ := http.NewRequest("GET", "/ladders/"+test.givenLadderName, nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		if test.wantBody != nil {
+			var gotLadder db.Ladder
+			err := json.NewDecoder(w.Body).Decode(&gotLadder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotLadder, *test.wantBody) {
+				t.Errorf("%s: wrong body. Want %#v. Got %#v", test.givenTestCase, *test.wantBody, gotLadder)
+			}
+		}
+	}
+}
+
+func TestUpdateLadder(t *testing.T) {
+	tests := []struct {
+		givenTestCase   string
+		givenLadderName string
+		givenRungs      []db.LadderRung
+
+		wantCode int
+	}{
+		{
+			"Update ladder",
+			"web",
+			testLadderRungs[1:],
+			http.StatusOK,
+		},
+		{
+			"Update ladder not found",
+			"mobile",
+			testLadderRungs[1:],
+			http.StatusNotFound,
+		},
+		{
+			"Update ladder unknown presetmap",
+			"web",
+			[]db.LadderRung{{PresetMap: "webm_720p", Height: 720, Bitrate: 3000000}},
+			http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDB := newLadderTestDB(false)
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		data, _ := json.Marshal(map[string]interface{}{"rungs": test.givenRungs})
+		r, _ := http.NewRequest("PUT", "/ladders/"+test.givenLadderName, bytes.NewReader(data))
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		if test.wantCode == http.StatusOK {
+			ladder, err := fakeDB.GetLadder(test.givenLadderName)
+			if err != nil {
+				t.Error(err)
+			} else if !reflect.DeepEqual(ladder.Rungs, test.givenRungs) {
+				t.Errorf("%s: didn't update the ladder in the database. Want %#v. Got %#v", test.givenTestCase, test.givenRungs, ladder.Rungs)
+			}
+		}
+	}
+}
+
+func TestDeleteLadder(t *testing.T) {
+	tests := []struct {
+		givenTestCase   string
+		givenLadderName string
+		wantCode        int
+	}{
+		{
+			"Delete ladder",
+			"web",
+			http.StatusOK,
+		},
+		{
+			"Delete ladder not found",
+			"mobile",
+			http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDB := newLadderTestDB(false)
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest("DELETE", "/ladders/"+test.givenLadderName, nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		if test.wantCode == http.StatusOK {
+			_, err := fakeDB.GetLadder(test.givenLadderName)
+			if err != db.ErrLadderNotFound {
+				t.Errorf("%s: didn't delete the ladder in the database", test.givenTestCase)
+			}
+		}
+	}
+}
+
+func TestListLadders(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = newLadderTestDB(false)
+	srvr.Register(service)
+	r, _ := http.NewRequest("GET", "/ladders", nil)
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	var got map[string]db.Ladder
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]db.Ladder{"web": {Name: "web", Rungs: testLadderRungs}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong body. Want %#v. Got %#v", want, got)
+	}
+}
+
+func TestTranscodeJobWithLadder(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		givenTestCase string
+		givenSource   string
+		givenLadder   string
+		givenOutputs  []map[string]string
+		givenProbe    *bool
+
+		wantCode        int
+		wantError       string
+		wantOutputs     []string
+		wantJobLadder   *db.JobLadder
+		wantOutputFiles []string
+	}{
+		{
+			"1080p source with a lower bitrate",
+			"http://media.example.com/video-1080p.mp4",
+			"web",
+			nil,
+			nil,
+			http.StatusOK,
+			"",
+			[]string{"mp4_1080p_4500k", "mp4_720p", "mp4_360p"},
+			&db.JobLadder{
+				Name:           "web",
+				SourceWidth:    1920,
+				SourceHeight:   1080,
+				SourceBitrate:  4500000,
+				SourceDuration: 60,
+				Rungs: []db.LadderRung{
+					{PresetMap: "mp4_1080p", Width: 1920, Height: 1080, Bitrate: 4500000},
+					testLadderRungs[1],
+					testLadderRungs[2],
+				},
+			},
+			[]string{"video-1080p_mp4_1080p_4500k.mp4", "video-1080p_mp4_720p.mp4", "video-1080p_mp4_360p.mp4"},
+		},
+		{
+			"360p source with explicit outputs",
+			"http://media.example.com/video-360p.ts",
+			"web",
+			[]map[string]string{{"preset": "mp4_720p", "fileName": "upscaled.mp4"}},
+			nil,
+			http.StatusOK,
+			"",
+			[]string{"mp4_720p", "mp4_360p"},
+			&db.JobLadder{
+				Name:           "web",
+				SourceWidth:    640,
+				SourceHeight:   360,
+				SourceDuration: 7200,
+				Rungs:          testLadderRungs[2:],
+				DroppedRungs:   testLadderRungs[:2],
+			},
+			[]string{"upscaled.mp4", "video-360p_mp4_360p.mp4"},
+		},
+		{
+			"probing disabled in the job",
+			"http://media.example.com/video-360p.ts",
+			"web",
+			nil,
+			&disabled,
+			http.StatusOK,
+			"",
+			[]string{"mp4_1080p", "mp4_720p", "mp4_360p"},
+			&db.JobLadder{Name: "web", Rungs: testLadderRungs},
+			[]string{"video-360p_mp4_1080p.mp4", "video-360p_mp4_720p.mp4", "video-360p_mp4_360p.mp4"},
+		},
+		{
+			"source that can't be probed",
+			"s3://some-bucket/video.mp4",
+			"web",
+			nil,
+			nil,
+			http.StatusOK,
+			"",
+			[]string{"mp4_1080p", "mp4_720p", "mp4_360p"},
+			&db.JobLadder{Name: "web", Rungs: testLadderRungs},
+			[]string{"video_mp4_1080p.mp4", "video_mp4_720p.mp4", "video_mp4_360p.mp4"},
+		},
+		{
+			"source not found",
+			"http://media.example.com/missing.mp4",
+			"web",
+			nil,
+			nil,
+			http.StatusBadRequest,
+			`source media "http://media.example.com/missing.mp4" not found`,
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"ladder not found",
+			"http://media.example.com/video-1080p.mp4",
+			"mobile",
+			nil,
+			nil,
+			http.StatusBadRequest,
+			db.ErrLadderNotFound.Error(),
+			nil,
+			nil,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDB := newLadderTestDB(false)
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		service.prober.probe = fakeProbe
+		srvr.Register(service)
+		payload := map[string]interface{}{
+			"source":   test.givenSource,
+			"ladder":   test.givenLadder,
+			"provider": "fake",
+		}
+		if test.givenOutputs != nil {
+			payload["outputs"] = test.givenOutputs
+		}
+		if test.givenProbe != nil {
+			payload["probe"] = *test.givenProbe
+		}
+		body, _ := json.Marshal(payload)
+		r, _ := http.NewRequest("POST", "/jobs", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		if err = json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if test.wantCode != http.StatusOK {
+			if got["error"] != test.wantError {
+				t.Errorf("%s: wrong error. Want %q. Got %q", test.givenTestCase, test.wantError, got["error"])
+			}
+			continue
+		}
+		jobID, _ := got["jobId"].(string)
+		job, err := fakeDB.GetJob(jobID)
+		if err != nil {
+			t.Errorf("%s: %s", test.givenTestCase, err)
+			continue
+		}
+		var gotOutputs, gotOutputFiles []string
+		for _, output := range job.Outputs {
+			gotOutputs = append(gotOutputs, output.Preset.Name)
+			gotOutputFiles = append(gotOutputFiles, output.FileName)
+		}
+		if !reflect.DeepEqual(gotOutputs, test.wantOutputs) {
+			t.Errorf("%s: wrong outputs. Want %#v. Got %#v", test.givenTestCase, test.wantOutputs, gotOutputs)
+		}
+		if !reflect.DeepEqual(gotOutputFiles, test.wantOutputFiles) {
+			t.Errorf("%s: wrong output files. Want %#v. Got %#v", test.givenTestCase, test.wantOutputFiles, gotOutputFiles)
+		}
+		if !reflect.DeepEqual(job.Ladder, test.wantJobLadder) {
+			t.Errorf("%s: wrong job ladder.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantJobLadder, job.Ladder)
+		}
+	}
+}
+
+func TestTranscodeJobWithLadderCapsThePresetBitrate(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDB := newLadderTestDB(false)
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	service.prober.probe = fakeProbe
+	srvr.Register(service)
+	for i := 0; i < 2; i++ {
+		body, _ := json.Marshal(map[string]interface{}{
+			"source":   "http://media.example.com/video-1080p.mp4",
+			"ladder":   "web",
+			"provider": "fake",
+		})
+		r, _ := http.NewRequest("POST", "/jobs", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("wrong response code. Want %d. Got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+	presetMap, err := fakeDB.GetPresetMap("mp4_1080p_4500k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bitrate := presetMap.Preset.Video.Bitrate; bitrate != "4500000" {
+		t.Errorf("wrong bitrate of the generated preset. Want %q. Got %q", "4500000", bitrate)
+	}
+	if height := presetMap.Preset.Video.Height; height != "1080" {
+		t.Errorf("wrong height of the generated preset. Want %q. Got %q", "1080", height)
+	}
+	// the generated preset is mapped to the id returned by the provider.
+	wantMapping := map[string]string{"fake": "presetID_here"}
+	if !reflect.DeepEqual(presetMap.ProviderMapping, wantMapping) {
+		t.Errorf("wrong provider mapping of the generated preset. Want %#v. Got %#v", wantMapping, presetMap.ProviderMapping)
+	}
+	original, err := fakeDB.GetPresetMap("mp4_1080p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bitrate := original.Preset.Video.Bitrate; bitrate != "5000000" {
+		t.Errorf("the original preset was changed. Want bitrate %q. Got %q", "5000000", bitrate)
+	}
+}
+
+func TestTranscodeJobWithLadderWithoutPresetDefinition(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDB := newLadderTestDB(false)
+	presetMap, err := fakeDB.GetPresetMap("mp4_1080p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	presetMap.Preset = nil
+	if err = fakeDB.UpdatePresetMap(presetMap); err != nil {
+		t.Fatal(err)
+	}
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	service.prober.probe = fakeProbe
+	srvr.Register(service)
+	body, _ := json.Marshal(map[string]interface{}{
+		"source":   "http://media.example.com/video-1080p.mp4",
+		"ladder":   "web",
+		"provider": "fake",
+	})
+	r, _ := http.NewRequest("POST", "/jobs", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("wrong response code. Want %d. Got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var got map[string]interface{}
+	if err = json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	job, err := fakeDB.GetJob(got["jobId"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := job.Outputs[0].Preset.Name; name != "mp4_1080p" {
+		t.Errorf("wrong presetmap of the first output. Want %q. Got %q", "mp4_1080p", name)
+	}
+	if rung := job.Ladder.Rungs[0]; !reflect.DeepEqual(rung, testLadderRungs[0]) {
+		t.Errorf("wrong first rung of the job ladder. Want %#v. Got %#v", testLadderRungs[0], rung)
+	}
+}
+
+// racingPresetMapRepository simulates a concurrent job generating a presetmap,
+// reporting the presetmap as missing to the first lookups.
+type racingPresetMapRepository struct {
+	db.Repository
+	name   string
+	misses int
+}
+
+func (r *racingPresetMapRepository) GetPresetMap(name string) (*db.PresetMap, error) {
+	if name == r.name && r.misses > 0 {
+		r.misses--
+		return nil, db.ErrPresetMapNotFound
+	}
+	return r.Repository.GetPresetMap(name)
+}
+
+func TestCappedPresetMapGeneratedConcurrently(t *testing.T) {
+	fakeDB := newLadderTestDB(false)
+	presetMap, err := fakeDB.GetPresetMap("mp4_1080p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := *presetMap
+	generated.Name = "mp4_1080p_4500k"
+	generated.ProviderMapping = map[string]string{"fake": "mp4_1080p_4500k"}
+	if err = fakeDB.CreatePresetMap(&generated); err != nil {
+		t.Fatal(err)
+	}
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = &racingPresetMapRepository{Repository: fakeDB, name: generated.Name, misses: 2}
+	capped, ok, err := service.cappedPresetMap(presetMap, 4500000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("unexpected presetmap over the capped bitrate")
+	}
+	if capped.Name != generated.Name {
+		t.Errorf("wrong capped presetmap. Want %q. Got %q", generated.Name, capped.Name)
+	}
+}
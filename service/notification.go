@@ -0,0 +1,464 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NYTimes/gizmo/web"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	defaultNotificationMaxAttempts    = 5
+	defaultNotificationInitialBackoff = time.Second
+	defaultNotificationMaxBackoff     = time.Minute
+	defaultNotificationTimeout        = 10 * time.Second
+
+	// maxNotificationRedirects is the number of redirects followed when
+	// delivering a notification, like the default of http.Client.
+	maxNotificationRedirects = 10
+
+	// notificationSignatureHeader is the header containing the hex-encoded
+	// HMAC-SHA256 of the body of the notification, using the callback
+	// secret of the job as the key.
+	notificationSignatureHeader = "X-Transcoding-Signature"
+
+	notificationIDHeader = "X-Transcoding-Notification-Id"
+)
+
+// NotificationPayload is the JSON document sent to the callback URL of a job
+// whenever its status changes.
+//
+// swagger:model
+type NotificationPayload struct {
+	// id of the notification, the same id is used in all delivery
+	// attempts
+	NotificationID string `json:"notificationId"`
+
+	// id of the job
+	JobID string `json:"jobId"`
+
+	// time when the status change was detected
+	Timestamp time.Time `json:"timestamp"`
+
+	// status of the job
+	JobStatus *provider.JobStatus `json:"jobStatus"`
+}
+
+// swagger:route GET /jobs/{jobId}/notifications jobs listJobNotifications
+//
+// Lists the notifications sent to the callback URL of the job, including all
+// delivery attempts.
+//
+//     Responses:
+//       200: listJobNotifications
+//       404: jobNotFound
+//       500: genericError
+func (s *TranscodingService) listJobNotifications(r *http.Request) swagger.GizmoJSONResponse {
+	var params listJobNotificationsInput
+	params.loadParams(web.Vars(r))
+	job, err := s.db.GetJob(params.JobID)
+	if err != nil {
+		if err == db.ErrJobNotFound {
+			return newJobNotFoundResponse(err)
+		}
+		return swagger.NewErrorResponse(err)
+	}
+	notifications, err := s.db.ListNotifications(job.ID)
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	return newListJobNotificationsResponse(notifications)
+}
+
+// notifier delivers notifications to the callback URL of jobs, retrying with
+// exponential backoff and recording every attempt in the repository.
+// Notifications that can't be delivered after all attempts are kept in the
+// repository as dead letters.
+//
+// The first attempt is made as soon as the notification is created. Retries
+// are scheduled in the repository and made by the background worker started
+// by start, so pending deliveries are resumed after the API restarts. Once
+// stopped, notifications are only recorded and delivered by the worker after
+// the next start.
+type notifier struct {
+	db             db.Repository
+	logger         *logrus.Logger
+	client         *http.Client
+	genID          func() (string, error)
+	maxAttempts    uint
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	allowedHosts   map[string]bool
+	done           chan struct{}
+	wg             sync.WaitGroup
+
+	// inFlightMtx guards the deliveries in progress, and whether new
+	// deliveries are accepted, so deliveries aren't added while stop
+	// waits for them.
+	inFlightMtx sync.Mutex
+	inFlight    map[string]bool
+	stopped     bool
+	deliveries  sync.WaitGroup
+}
+
+func newNotifier(cfg *config.Notifications, repo db.Repository, logger *logrus.Logger, genID func() (string, error)) *notifier {
+	n := notifier{
+		db:             repo,
+		logger:         logger,
+		genID:          genID,
+		maxAttempts:    defaultNotificationMaxAttempts,
+		initialBackoff: defaultNotificationInitialBackoff,
+		maxBackoff:     defaultNotificationMaxBackoff,
+		allowedHosts:   make(map[string]bool),
+		inFlight:       make(map[string]bool),
+	}
+	if cfg != nil {
+		if cfg.MaxAttempts > 0 {
+			n.maxAttempts = cfg.MaxAttempts
+		}
+		if cfg.InitialBackoff > 0 {
+			n.initialBackoff = time.Duration(cfg.InitialBackoff) * time.Second
+		}
+		if cfg.MaxBackoff > 0 {
+			n.maxBackoff = time.Duration(cfg.MaxBackoff) * time.Second
+		}
+		for _, host := range strings.Split(cfg.AllowedCallbackHosts, ",") {
+			if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+				n.allowedHosts[host] = true
+			}
+		}
+	}
+	timeout := defaultNotificationTimeout
+	if cfg != nil && cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	n.client = &http.Client{
+		Timeout:       timeout,
+		Transport:     &http.Transport{DialContext: n.dialContext},
+		CheckRedirect: n.checkRedirect,
+	}
+	return &n
+}
+
+// notify records a notification for the given status of the job and delivers
+// it in background. It's a no-op for jobs without a callback URL.
+func (n *notifier) notify(job *db.Job, status *provider.JobStatus) {
+	if job.CallbackURL == "" {
+		return
+	}
+	switch status.Status {
+	case provider.StatusQueued, provider.StatusStarted, provider.StatusFinished, provider.StatusFailed, provider.StatusCanceled:
+	default:
+		return
+	}
+	logger := n.logger.WithField("jobId", job.ID)
+	id, err := n.genID()
+	if err != nil {
+		logger.WithError(err).Error("failed to generate the id of the notification")
+		return
+	}
+	now := time.Now().UTC()
+	payload, err := json.Marshal(NotificationPayload{
+		NotificationID: id,
+		JobID:          job.ID,
+		Timestamp:      now,
+		JobStatus:      status,
+	})
+	if err != nil {
+		logger.WithError(err).Error("failed to encode the notification")
+		return
+	}
+	notification := db.Notification{
+		ID:           id,
+		JobID:        job.ID,
+		Status:       string(status.Status),
+		CallbackURL:  job.CallbackURL,
+		Payload:      string(payload),
+		CreationTime: now,
+
+		// the first attempt is made right away, the worker only picks
+		// the notification up if the API stops before recording it.
+		NextAttemptTime: now.Add(n.client.Timeout),
+	}
+	err = n.db.CreateNotification(&notification)
+	if err != nil {
+		logger.WithError(err).Error("failed to store the notification")
+		return
+	}
+	n.deliver(&notification, job.CallbackSecret, time.Time{})
+}
+
+// deliver makes one delivery attempt of the notification in background, unless
+// an attempt is already in progress or the notifier is stopped (see attempt).
+func (n *notifier) deliver(notification *db.Notification, secret string, due time.Time) {
+	n.inFlightMtx.Lock()
+	defer n.inFlightMtx.Unlock()
+	if n.stopped || n.inFlight[notification.ID] {
+		return
+	}
+	n.inFlight[notification.ID] = true
+	n.deliveries.Add(1)
+	go func() {
+		defer n.deliveries.Done()
+		n.attempt(notification.ID, secret, due)
+		n.inFlightMtx.Lock()
+		delete(n.inFlight, notification.ID)
+		n.inFlightMtx.Unlock()
+	}()
+}
+
+// attempt claims the delivery of the notification, so other instances of the
+// API don't deliver it at the same time, then sends it and records the
+// attempt, scheduling the next one when the delivery fails. The notification
+// is reloaded once claimed, and skipped when it's no longer pending or when
+// its next attempt was rescheduled after the given due time in the meantime.
+// A zero due time makes the attempt right away.
+func (n *notifier) attempt(id, secret string, due time.Time) {
+	logger := n.logger.WithField("notificationId", id)
+	lock := "notification:" + id
+	token, err := n.db.AcquireLock(lock, 2*n.client.Timeout)
+	if err != nil {
+		logger.WithError(err).Error("failed to claim the delivery of the notification")
+		return
+	}
+	if token == "" {
+		return
+	}
+	defer func() {
+		if err := n.db.ReleaseLock(lock, token); err != nil {
+			logger.WithError(err).Error("failed to release the delivery of the notification")
+		}
+	}()
+	notification, err := n.db.GetNotification(id)
+	if err != nil {
+		logger.WithError(err).Error("failed to load the notification")
+		return
+	}
+	if !notification.Pending() || (!due.IsZero() && notification.NextAttemptTime.After(due)) {
+		return
+	}
+	logger = logger.WithField("jobId", notification.JobID)
+	result := n.send(notification, secret)
+	notification.Attempts = append(notification.Attempts, result)
+	attempts := uint(len(notification.Attempts))
+	notification.NextAttemptTime = time.Time{}
+	if result.Error == "" && result.StatusCode >= 200 && result.StatusCode < 300 {
+		notification.Delivered = true
+	} else if attempts >= n.maxAttempts {
+		notification.DeadLetter = true
+		logger.Errorf("giving up on delivering notification to %s after %d attempts", notification.CallbackURL, attempts)
+	} else {
+		notification.NextAttemptTime = time.Now().UTC().Add(n.backoff(attempts))
+	}
+	if err := n.db.UpdateNotification(notification); err != nil {
+		logger.WithError(err).Error("failed to update the notification")
+	}
+}
+
+// retry delivers the pending notifications whose next attempt is due at the
+// given time, including the ones left pending by previous runs of the API.
+func (n *notifier) retry(now time.Time) {
+	notifications, err := n.db.ListPendingNotifications(now)
+	if err != nil {
+		n.logger.WithError(err).Error("failed to list pending notifications")
+		return
+	}
+	for i := range notifications {
+		notification := &notifications[i]
+		job, err := n.db.GetJob(notification.JobID)
+		if err == db.ErrJobNotFound {
+			n.logger.WithField("jobId", notification.JobID).WithField("notificationId", notification.ID).Error("giving up on delivering notification of deleted job")
+			notification.DeadLetter = true
+			notification.NextAttemptTime = time.Time{}
+			err = n.db.UpdateNotification(notification)
+		}
+		if err != nil {
+			n.logger.WithError(err).WithField("notificationId", notification.ID).Error("failed to resume the delivery of the notification")
+			continue
+		}
+		n.deliver(notification, job.CallbackSecret, now)
+	}
+}
+
+func (n *notifier) start() {
+	n.inFlightMtx.Lock()
+	n.stopped = false
+	n.inFlightMtx.Unlock()
+	// the worker selects on its own copy of the channel, as stop clears
+	// the field while the worker may still be running.
+	done := make(chan struct{})
+	n.done = done
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.retry(time.Now().UTC())
+		ticker := time.NewTicker(n.initialBackoff)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				n.retry(now.UTC())
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the background worker and waits for the deliveries in progress.
+// Deliveries that are still pending are resumed by the next call to start.
+func (n *notifier) stop() {
+	if n.done != nil {
+		close(n.done)
+		n.done = nil
+	}
+	n.wg.Wait()
+	n.inFlightMtx.Lock()
+	n.stopped = true
+	n.inFlightMtx.Unlock()
+	n.wait()
+}
+
+func (n *notifier) send(notification *db.Notification, secret string) db.NotificationAttempt {
+	attempt := db.NotificationAttempt{Time: time.Now().UTC()}
+	req, err := http.NewRequest("POST", notification.CallbackURL, bytes.NewBufferString(notification.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(notificationIDHeader, notification.ID)
+	if secret != "" {
+		req.Header.Set(notificationSignatureHeader, "sha256="+signPayload([]byte(notification.Payload), secret))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	attempt.StatusCode = resp.StatusCode
+	return attempt
+}
+
+// backoff returns how long to wait after the given attempt, doubling the
+// initial backoff on each attempt, up to maxBackoff.
+func (n *notifier) backoff(attempt uint) time.Duration {
+	backoff := n.initialBackoff
+	for i := uint(1); i < attempt && backoff < n.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > n.maxBackoff {
+		backoff = n.maxBackoff
+	}
+	return backoff
+}
+
+// wait blocks until all deliveries in progress are done.
+func (n *notifier) wait() {
+	n.deliveries.Wait()
+}
+
+// checkCallbackURL returns an error when the given callback URL of a job
+// points to a host that notifications can't be sent to.
+func (n *notifier) checkCallbackURL(callbackURL string) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return err
+	}
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if len(n.allowedHosts) > 0 {
+		if !n.allowedHosts[host] {
+			return fmt.Errorf("invalid callbackUrl: host %q is not allowed", host)
+		}
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("invalid callbackUrl: host %q is not allowed", host)
+	}
+	if ip := net.ParseIP(host); ip != nil && provider.IsInternalIP(ip) {
+		return fmt.Errorf("invalid callbackUrl: host %q is not allowed", host)
+	}
+	return nil
+}
+
+// checkRedirect applies the checks of callback URLs to the redirects of
+// deliveries, so allowed hosts can't redirect notifications to other hosts.
+func (n *notifier) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxNotificationRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxNotificationRedirects)
+	}
+	return n.checkCallbackURL(req.URL.String())
+}
+
+// dialContext dials the callback URL of notifications. Unless the allowed
+// hosts are configured, it refuses to connect to internal addresses, which
+// also covers redirects and names resolving to internal addresses.
+func (n *notifier) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if len(n.allowedHosts) > 0 {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return dialExternal(ctx, network, addr)
+}
+
+// dialExternal dials the given address, refusing to connect to hosts that
+// resolve to internal addresses.
+func dialExternal(ctx context.Context, network, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if provider.IsInternalIP(ip) {
+			return nil, fmt.Errorf("host %q resolves to the internal address %s", host, ip)
+		}
+	}
+	return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].String(), port))
+}
+
+// StartNotifier starts the background worker that retries the delivery of
+// notifications, resuming the deliveries left pending by previous runs.
+func (s *TranscodingService) StartNotifier() {
+	s.notifier.start()
+}
+
+// StopNotifier stops the background worker that retries the delivery of
+// notifications, waiting for the deliveries in progress.
+func (s *TranscodingService) StopNotifier() {
+	s.notifier.stop()
+}
+
+func signPayload(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,560 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/Sirupsen/logrus"
+)
+
+type callbackRequest struct {
+	header http.Header
+	body   []byte
+}
+
+type fakeCallbackServer struct {
+	*httptest.Server
+	mtx         sync.Mutex
+	requests    []callbackRequest
+	statusCodes []int
+}
+
+// newFakeCallbackServer starts a server that responds with the given status
+// codes, in order, repeating the last one after they're exhausted.
+func newFakeCallbackServer(statusCodes ...int) *fakeCallbackServer {
+	s := fakeCallbackServer{statusCodes: statusCodes}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		s.requests = append(s.requests, callbackRequest{header: r.Header, body: body})
+		statusCode := s.statusCodes[len(s.statusCodes)-1]
+		if len(s.requests) <= len(s.statusCodes) {
+			statusCode = s.statusCodes[len(s.requests)-1]
+		}
+		w.WriteHeader(statusCode)
+	}))
+	return &s
+}
+
+func (s *fakeCallbackServer) receivedRequests() []callbackRequest {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.requests
+}
+
+func newTestNotifier(repo db.Repository, maxAttempts uint) *notifier {
+	var service TranscodingService
+	cfg := config.Notifications{MaxAttempts: maxAttempts, AllowedCallbackHosts: "127.0.0.1"}
+	n := newNotifier(&cfg, repo, logrus.New(), service.genID)
+	n.initialBackoff = time.Millisecond
+	n.maxBackoff = 4 * time.Millisecond
+	return n
+}
+
+// deliverAll makes delivery attempts, ignoring the backoff, until there are no
+// pending notifications.
+func deliverAll(n *notifier) {
+	for {
+		n.wait()
+		until := time.Now().Add(time.Hour)
+		pending, err := n.db.ListPendingNotifications(until)
+		if err != nil || len(pending) == 0 {
+			return
+		}
+		n.retry(until)
+	}
+}
+
+func TestNotifierDelivery(t *testing.T) {
+	var tests = []struct {
+		givenTestCase    string
+		givenStatusCodes []int
+		givenMaxAttempts uint
+		wantAttemptCodes []int
+		wantDelivered    bool
+		wantDeadLetter   bool
+	}{
+		{
+			"delivered on first attempt",
+			[]int{http.StatusOK},
+			3,
+			[]int{http.StatusOK},
+			true,
+			false,
+		},
+		{
+			"delivered after retrying",
+			[]int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent},
+			3,
+			[]int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent},
+			true,
+			false,
+		},
+		{
+			"dead letter after exhausting attempts",
+			[]int{http.StatusServiceUnavailable},
+			4,
+			[]int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			false,
+			true,
+		},
+	}
+	for _, test := range tests {
+		callbackServer := newFakeCallbackServer(test.givenStatusCodes...)
+		repo := dbtest.NewFakeRepository(false)
+		n := newTestNotifier(repo, test.givenMaxAttempts)
+		job := db.Job{ID: "job-123", CallbackURL: callbackServer.URL + "/callback"}
+		repo.CreateJob(&job)
+		n.notify(&job, &provider.JobStatus{ProviderJobID: "provider-job-123", Status: provider.StatusStarted})
+		deliverAll(n)
+		callbackServer.Close()
+		notifications, err := repo.ListNotifications(job.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notifications) != 1 {
+			t.Fatalf("%s: wrong number of notifications. Want 1. Got %d", test.givenTestCase, len(notifications))
+		}
+		notification := notifications[0]
+		attemptCodes := make([]int, len(notification.Attempts))
+		for i, attempt := range notification.Attempts {
+			attemptCodes[i] = attempt.StatusCode
+		}
+		if !reflect.DeepEqual(attemptCodes, test.wantAttemptCodes) {
+			t.Errorf("%s: wrong attempts. Want %#v. Got %#v", test.givenTestCase, test.wantAttemptCodes, attemptCodes)
+		}
+		if notification.Delivered != test.wantDelivered {
+			t.Errorf("%s: wrong delivered flag. Want %v. Got %v", test.givenTestCase, test.wantDelivered, notification.Delivered)
+		}
+		if notification.DeadLetter != test.wantDeadLetter {
+			t.Errorf("%s: wrong dead letter flag. Want %v. Got %v", test.givenTestCase, test.wantDeadLetter, notification.DeadLetter)
+		}
+		requests := callbackServer.receivedRequests()
+		if len(requests) != len(test.wantAttemptCodes) {
+			t.Errorf("%s: wrong number of requests. Want %d. Got %d", test.givenTestCase, len(test.wantAttemptCodes), len(requests))
+		}
+		for _, req := range requests {
+			if got := req.header.Get(notificationIDHeader); got != notification.ID {
+				t.Errorf("%s: wrong notification id header. Want %q. Got %q", test.givenTestCase, notification.ID, got)
+			}
+			if string(req.body) != notification.Payload {
+				t.Errorf("%s: wrong body.\nWant %s\nGot  %s", test.givenTestCase, notification.Payload, req.body)
+			}
+		}
+	}
+}
+
+func TestNotifierConnectionError(t *testing.T) {
+	callbackServer := newFakeCallbackServer(http.StatusOK)
+	callbackServer.Close()
+	repo := dbtest.NewFakeRepository(false)
+	n := newTestNotifier(repo, 2)
+	job := db.Job{ID: "job-123", CallbackURL: callbackServer.URL}
+	repo.CreateJob(&job)
+	n.notify(&job, &provider.JobStatus{Status: provider.StatusFailed})
+	deliverAll(n)
+	notifications, err := repo.ListNotifications(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 {
+		t.Fatalf("wrong number of notifications. Want 1. Got %d", len(notifications))
+	}
+	if !notifications[0].DeadLetter {
+		t.Error("notification should be a dead letter, but it isn't")
+	}
+	for _, attempt := range notifications[0].Attempts {
+		if attempt.Error == "" || attempt.StatusCode != 0 {
+			t.Errorf("wrong attempt: %#v", attempt)
+		}
+	}
+}
+
+func TestNotifierSkipsJobsWithoutCallback(t *testing.T) {
+	repo := dbtest.NewFakeRepository(false)
+	n := newTestNotifier(repo, 1)
+	var tests = []struct {
+		job    db.Job
+		status provider.Status
+	}{
+		{db.Job{ID: "job-1"}, provider.StatusFinished},
+		{db.Job{ID: "job-2", CallbackURL: "http://localhost/callback"}, provider.StatusUnknown},
+	}
+	for _, test := range tests {
+		n.notify(&test.job, &provider.JobStatus{Status: test.status})
+		n.wait()
+		notifications, err := repo.ListNotifications(test.job.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notifications) != 0 {
+			t.Errorf("%s: unexpected notifications: %#v", test.job.ID, notifications)
+		}
+	}
+}
+
+func TestNotifierResumesPendingDeliveries(t *testing.T) {
+	callbackServer := newFakeCallbackServer(http.StatusOK)
+	defer callbackServer.Close()
+	repo := dbtest.NewFakeRepository(false)
+	n := newTestNotifier(repo, 3)
+	job := db.Job{ID: "job-123", CallbackURL: callbackServer.URL, CallbackSecret: "s3cr3t"}
+	repo.CreateJob(&job)
+	now := time.Now().UTC()
+	notifications := []db.Notification{
+		{
+			ID:              "notification-due",
+			JobID:           job.ID,
+			Status:          "finished",
+			CallbackURL:     job.CallbackURL,
+			Payload:         `{"jobId":"job-123"}`,
+			Attempts:        []db.NotificationAttempt{{Time: now.Add(-time.Minute), StatusCode: http.StatusBadGateway}},
+			NextAttemptTime: now.Add(-time.Second),
+		},
+		{
+			ID:              "notification-scheduled",
+			JobID:           job.ID,
+			Status:          "started",
+			CallbackURL:     job.CallbackURL,
+			Payload:         `{"jobId":"job-123"}`,
+			Attempts:        []db.NotificationAttempt{{Time: now, StatusCode: http.StatusBadGateway}},
+			NextAttemptTime: now.Add(time.Hour),
+		},
+	}
+	for i := range notifications {
+		repo.CreateNotification(&notifications[i])
+	}
+	n.start()
+	n.stop()
+	requests := callbackServer.receivedRequests()
+	if len(requests) != 1 {
+		t.Fatalf("wrong number of callback requests. Want 1. Got %d", len(requests))
+	}
+	if got := requests[0].header.Get(notificationIDHeader); got != "notification-due" {
+		t.Errorf("wrong notification delivered. Want %q. Got %q", "notification-due", got)
+	}
+	wantSignature := "sha256=" + signPayload(requests[0].body, "s3cr3t")
+	if got := requests[0].header.Get(notificationSignatureHeader); got != wantSignature {
+		t.Errorf("wrong signature. Want %q. Got %q", wantSignature, got)
+	}
+	gotNotifications, err := repo.ListNotifications(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if due := gotNotifications[0]; !due.Delivered || len(due.Attempts) != 2 {
+		t.Errorf("wrong due notification after resuming: %#v", due)
+	}
+	if scheduled := gotNotifications[1]; scheduled.Delivered || len(scheduled.Attempts) != 1 {
+		t.Errorf("wrong scheduled notification after resuming: %#v", scheduled)
+	}
+}
+
+func TestNotifierStopped(t *testing.T) {
+	callbackServer := newFakeCallbackServer(http.StatusOK)
+	defer callbackServer.Close()
+	repo := dbtest.NewFakeRepository(false)
+	n := newTestNotifier(repo, 3)
+	job := db.Job{ID: "job-123", CallbackURL: callbackServer.URL}
+	repo.CreateJob(&job)
+	n.start()
+	n.stop()
+	n.notify(&job, &provider.JobStatus{Status: provider.StatusFinished})
+	n.wait()
+	if requests := callbackServer.receivedRequests(); len(requests) != 0 {
+		t.Errorf("unexpected callback requests after stopping: %#v", requests)
+	}
+	pending, err := repo.ListPendingNotifications(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("wrong number of pending notifications. Want 1. Got %d", len(pending))
+	}
+	pending[0].NextAttemptTime = time.Now().UTC().Add(-time.Second)
+	repo.UpdateNotification(&pending[0])
+	n.start()
+	n.stop()
+	if requests := callbackServer.receivedRequests(); len(requests) != 1 {
+		t.Errorf("wrong number of callback requests after restarting. Want 1. Got %d", len(requests))
+	}
+}
+
+func TestNotifierRefusesInternalAddresses(t *testing.T) {
+	callbackServer := newFakeCallbackServer(http.StatusOK)
+	defer callbackServer.Close()
+	repo := dbtest.NewFakeRepository(false)
+	var service TranscodingService
+	n := newNotifier(&config.Notifications{MaxAttempts: 1}, repo, logrus.New(), service.genID)
+	job := db.Job{ID: "job-123", CallbackURL: callbackServer.URL}
+	repo.CreateJob(&job)
+	n.notify(&job, &provider.JobStatus{Status: provider.StatusFinished})
+	n.wait()
+	if requests := callbackServer.receivedRequests(); len(requests) != 0 {
+		t.Errorf("unexpected callback requests: %#v", requests)
+	}
+	notifications, err := repo.ListNotifications(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || !notifications[0].DeadLetter {
+		t.Fatalf("wrong notifications: %#v", notifications)
+	}
+	if attemptErr := notifications[0].Attempts[0].Error; !strings.Contains(attemptErr, "internal address") {
+		t.Errorf("wrong attempt error: %q", attemptErr)
+	}
+}
+
+func TestNotifierRefusesRedirectsToDisallowedHosts(t *testing.T) {
+	callbackServer := newFakeCallbackServer(http.StatusOK)
+	defer callbackServer.Close()
+	redirectURL := strings.Replace(callbackServer.URL, "127.0.0.1", "localhost", 1)
+	redirectServer := httptest.NewServer(http.RedirectHandler(redirectURL, http.StatusFound))
+	defer redirectServer.Close()
+	repo := dbtest.NewFakeRepository(false)
+	n := newTestNotifier(repo, 1)
+	job := db.Job{ID: "job-123", CallbackURL: redirectServer.URL}
+	repo.CreateJob(&job)
+	n.notify(&job, &provider.JobStatus{Status: provider.StatusFinished})
+	n.wait()
+	if requests := callbackServer.receivedRequests(); len(requests) != 0 {
+		t.Errorf("unexpected callback requests: %#v", requests)
+	}
+	notifications, err := repo.ListNotifications(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || !notifications[0].DeadLetter {
+		t.Fatalf("wrong notifications: %#v", notifications)
+	}
+	if attemptErr := notifications[0].Attempts[0].Error; !strings.Contains(attemptErr, `host "localhost" is not allowed`) {
+		t.Errorf("wrong attempt error: %q", attemptErr)
+	}
+}
+
+func TestNotifierSkipsClaimedDeliveries(t *testing.T) {
+	callbackServer := newFakeCallbackServer(http.StatusOK)
+	defer callbackServer.Close()
+	repo := dbtest.NewFakeRepository(false)
+	n := newTestNotifier(repo, 3)
+	job := db.Job{ID: "job-123", CallbackURL: callbackServer.URL}
+	repo.CreateJob(&job)
+	notification := db.Notification{
+		ID:              "notification-123",
+		JobID:           job.ID,
+		Status:          provider.StatusFinished,
+		CallbackURL:     callbackServer.URL,
+		Payload:         `{"jobId":"job-123","status":"finished"}`,
+		NextAttemptTime: time.Now().UTC().Add(-time.Second),
+	}
+	if err := repo.CreateNotification(&notification); err != nil {
+		t.Fatal(err)
+	}
+	token, err := repo.AcquireLock("notification:"+notification.ID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.retry(time.Now())
+	n.wait()
+	if requests := callbackServer.receivedRequests(); len(requests) != 0 {
+		t.Errorf("unexpected callback requests for a claimed delivery: %#v", requests)
+	}
+	if err := repo.ReleaseLock("notification:"+notification.ID, token); err != nil {
+		t.Fatal(err)
+	}
+	n.retry(time.Now())
+	n.wait()
+	if requests := callbackServer.receivedRequests(); len(requests) != 1 {
+		t.Errorf("wrong number of callback requests after releasing the claim. Want 1. Got %d", len(requests))
+	}
+}
+
+func TestCheckCallbackURL(t *testing.T) {
+	var tests = []struct {
+		givenAllowedHosts string
+		givenCallbackURL  string
+		wantErr           string
+	}{
+		{"", "https://callbacks.example.com/jobs", ""},
+		{"", "http://203.0.113.10:8080/jobs", ""},
+		{"", "http://localhost/jobs", `invalid callbackUrl: host "localhost" is not allowed`},
+		{"", "http://127.0.0.1:8080/jobs", `invalid callbackUrl: host "127.0.0.1" is not allowed`},
+		{"", "http://10.1.2.3/jobs", `invalid callbackUrl: host "10.1.2.3" is not allowed`},
+		{"", "http://172.20.0.1/jobs", `invalid callbackUrl: host "172.20.0.1" is not allowed`},
+		{"", "http://192.168.0.10/jobs", `invalid callbackUrl: host "192.168.0.10" is not allowed`},
+		{"", "http://169.254.169.254/latest/meta-data", `invalid callbackUrl: host "169.254.169.254" is not allowed`},
+		{"", "http://[::1]:8080/jobs", `invalid callbackUrl: host "::1" is not allowed`},
+		{"", "http://[fd00::1]/jobs", `invalid callbackUrl: host "fd00::1" is not allowed`},
+		{"callbacks.example.com, 10.1.2.3", "https://Callbacks.Example.com/jobs", ""},
+		{"callbacks.example.com, 10.1.2.3", "http://10.1.2.3:8080/jobs", ""},
+		{"callbacks.example.com", "https://other.example.com/jobs", `invalid callbackUrl: host "other.example.com" is not allowed`},
+	}
+	for _, test := range tests {
+		n := newNotifier(&config.Notifications{AllowedCallbackHosts: test.givenAllowedHosts}, nil, logrus.New(), nil)
+		err := n.checkCallbackURL(test.givenCallbackURL)
+		if test.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenCallbackURL, err)
+		}
+		if test.wantErr != "" && (err == nil || err.Error() != test.wantErr) {
+			t.Errorf("%s: wrong error returned. Want %q. Got %v", test.givenCallbackURL, test.wantErr, err)
+		}
+	}
+}
+
+func TestNotifierBackoff(t *testing.T) {
+	n := notifier{initialBackoff: time.Second, maxBackoff: 10 * time.Second}
+	var tests = []struct {
+		attempt uint
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, test := range tests {
+		if got := n.backoff(test.attempt); got != test.want {
+			t.Errorf("backoff(%d): want %s. Got %s", test.attempt, test.want, got)
+		}
+	}
+}
+
+func TestJobStatusChangeNotification(t *testing.T) {
+	callbackServer := newFakeCallbackServer(http.StatusOK)
+	defer callbackServer.Close()
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDBObj := dbtest.NewFakeRepository(false)
+	fakeDBObj.CreateJob(&db.Job{
+		ID:             "job-123",
+		ProviderName:   "fake",
+		ProviderJobID:  "provider-job-123",
+		Status:         "started",
+		CallbackURL:    callbackServer.URL,
+		CallbackSecret: "s3cr3t",
+	})
+	service, err := NewTranscodingService(&config.Config{
+		Notifications: &config.Notifications{AllowedCallbackHosts: "127.0.0.1"},
+	}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDBObj
+	service.notifier.db = fakeDBObj
+	srvr.Register(service)
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("GET", "/jobs/job-123", nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+		}
+	}
+	service.notifier.wait()
+
+	requests := callbackServer.receivedRequests()
+	if len(requests) != 1 {
+		t.Fatalf("wrong number of callback requests. Want 1. Got %d", len(requests))
+	}
+	wantSignature := "sha256=" + signPayload(requests[0].body, "s3cr3t")
+	if got := requests[0].header.Get(notificationSignatureHeader); got != wantSignature {
+		t.Errorf("wrong signature. Want %q. Got %q", wantSignature, got)
+	}
+	var payload NotificationPayload
+	err = json.Unmarshal(requests[0].body, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.JobID != "job-123" {
+		t.Errorf("wrong job id in the payload. Want %q. Got %q", "job-123", payload.JobID)
+	}
+	if payload.JobStatus == nil || payload.JobStatus.Status != provider.StatusFinished {
+		t.Errorf("wrong job status in the payload: %#v", payload.JobStatus)
+	}
+
+	r, _ := http.NewRequest("GET", "/jobs/job-123/notifications", nil)
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	var notifications []db.Notification
+	err = json.Unmarshal(w.Body.Bytes(), &notifications)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 {
+		t.Fatalf("wrong number of notifications. Want 1. Got %d", len(notifications))
+	}
+	notification := notifications[0]
+	if notification.ID != payload.NotificationID {
+		t.Errorf("wrong notification id. Want %q. Got %q", payload.NotificationID, notification.ID)
+	}
+	if notification.Status != "finished" || !notification.Delivered || len(notification.Attempts) != 1 {
+		t.Errorf("wrong notification returned: %#v", notification)
+	}
+}
+
+func TestListJobNotificationsErrors(t *testing.T) {
+	var tests = []struct {
+		givenTestCase       string
+		givenJobID          string
+		givenTriggerDBError bool
+
+		wantCode int
+		wantBody map[string]interface{}
+	}{
+		{
+			"job not found",
+			"job-1234",
+			false,
+			http.StatusNotFound,
+			map[string]interface{}{"error": db.ErrJobNotFound.Error()},
+		},
+		{
+			"database error",
+			"job-123",
+			true,
+			http.StatusInternalServerError,
+			map[string]interface{}{"error": "database error"},
+		},
+	}
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDBObj := dbtest.NewFakeRepository(test.givenTriggerDBError)
+		fakeDBObj.CreateJob(&db.Job{ID: "job-123", ProviderName: "fake"})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDBObj
+		srvr.Register(service)
+		r, _ := http.NewRequest("GET", "/jobs/"+test.givenJobID+"/notifications", nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		err = json.Unmarshal(w.Body.Bytes(), &got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.wantBody) {
+			t.Errorf("%s: wrong response body. Want %#v. Got %#v", test.givenTestCase, test.wantBody, got)
+		}
+	}
+}
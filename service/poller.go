@@ -0,0 +1,219 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+const (
+	defaultStatusPollerConcurrency = 4
+	defaultStatusPollerInterval    = 30 * time.Second
+	defaultStatusPollerMaxJobAge   = 72 * time.Hour
+)
+
+// statusPoller periodically refreshes the status of jobs that are not done
+// yet, so GET /jobs/{jobId} can be served from the repository instead of
+// querying the provider on every request.
+type statusPoller struct {
+	service           *TranscodingService
+	concurrency       uint
+	interval          time.Duration
+	providerIntervals map[string]time.Duration
+	maxJobAge         time.Duration
+	done              chan struct{}
+	wg                sync.WaitGroup
+}
+
+func newStatusPoller(s *TranscodingService, cfg *config.StatusPoller) (*statusPoller, error) {
+	p := statusPoller{
+		service:     s,
+		concurrency: defaultStatusPollerConcurrency,
+		interval:    defaultStatusPollerInterval,
+		maxJobAge:   defaultStatusPollerMaxJobAge,
+	}
+	if cfg.Concurrency > 0 {
+		p.concurrency = cfg.Concurrency
+	}
+	if cfg.Interval > 0 {
+		p.interval = time.Duration(cfg.Interval) * time.Second
+	}
+	if cfg.MaxJobAge > 0 {
+		p.maxJobAge = time.Duration(cfg.MaxJobAge) * time.Hour
+	}
+	var err error
+	p.providerIntervals, err = parseProviderIntervals(cfg.ProviderIntervals)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// parseProviderIntervals parses a comma-separated list of provider:seconds
+// pairs.
+func parseProviderIntervals(value string) (map[string]time.Duration, error) {
+	intervals := make(map[string]time.Duration)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid provider interval %q: must be in the format provider:seconds", pair)
+		}
+		seconds, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 32)
+		if err != nil || seconds == 0 {
+			return nil, fmt.Errorf("invalid provider interval %q: seconds must be a positive integer", pair)
+		}
+		intervals[strings.TrimSpace(parts[0])] = time.Duration(seconds) * time.Second
+	}
+	return intervals, nil
+}
+
+// providerInterval returns the interval between refreshes of the status of
+// jobs in the given provider.
+func (p *statusPoller) providerInterval(providerName string) time.Duration {
+	if interval, ok := p.providerIntervals[providerName]; ok {
+		return interval
+	}
+	return p.interval
+}
+
+// tick returns the interval between runs of the poller, which is the
+// smallest interval among all providers.
+func (p *statusPoller) tick() time.Duration {
+	tick := p.interval
+	for _, interval := range p.providerIntervals {
+		if interval < tick {
+			tick = interval
+		}
+	}
+	return tick
+}
+
+func (p *statusPoller) start() {
+	p.done = make(chan struct{})
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.tick())
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				p.poll(now)
+			case <-p.done:
+				return
+			}
+		}
+	}()
+}
+
+func (p *statusPoller) stop() {
+	close(p.done)
+	p.wg.Wait()
+}
+
+// isPolled returns whether the status of the given job is kept up to date by
+// the poller: the job isn't older than the maximum age of polled jobs, and
+// its status was refreshed within the interval of its provider, plus the
+// delay until the next run of the poller.
+func (p *statusPoller) isPolled(job *db.Job, now time.Time) bool {
+	return now.Sub(job.CreationTime) <= p.maxJobAge &&
+		now.Sub(job.StatusUpdateTime) <= p.providerInterval(job.ProviderName)+p.tick()
+}
+
+// poll refreshes the status of all jobs that are due, using up to concurrency
+// workers, and blocks until all of them are refreshed. Jobs older than the
+// maximum age are removed from the active jobs, as they're not polled
+// anymore. Every instance of the API runs its own poller, so each job is
+// locked until it's due again before being refreshed, skipping the jobs
+// already refreshed by other instances.
+func (p *statusPoller) poll(now time.Time) {
+	if err := p.service.db.ExpireActiveJobs(now.Add(-p.maxJobAge)); err != nil {
+		p.service.logger.WithError(err).Error("failed to expire the active jobs past the maximum age")
+	}
+	jobs, err := p.service.db.ListJobs(db.JobFilter{Since: now.Add(-p.maxJobAge), Active: true})
+	if err != nil {
+		p.service.logger.WithError(err).Error("failed to list jobs for refreshing their status")
+		return
+	}
+	queue := make(chan *db.Job)
+	var wg sync.WaitGroup
+	for i := uint(0); i < p.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				if _, _, err := p.service.refreshJobStatus(job); err != nil {
+					p.service.logger.WithError(err).Errorf("failed to refresh the status of job %q", job.ID)
+				}
+			}
+		}()
+	}
+	// tolerance avoids skipping a job because it was refreshed slightly
+	// less than an interval ago in the previous run.
+	tolerance := p.tick() / 2
+	for i := range jobs {
+		job := &jobs[i]
+		lease := p.providerInterval(job.ProviderName) - tolerance
+		if now.Sub(job.StatusUpdateTime) < lease {
+			continue
+		}
+		// the lock isn't released, it expires when the job is due again.
+		token, err := p.service.db.AcquireLock("job-status:"+job.ID, lease)
+		if err != nil {
+			p.service.logger.WithError(err).Errorf("failed to lock job %q for refreshing its status", job.ID)
+			continue
+		}
+		if token == "" {
+			continue
+		}
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// StartStatusPoller starts the background worker that refreshes the status of
+// jobs, if it's enabled in the configuration.
+func (s *TranscodingService) StartStatusPoller() error {
+	if s.config.StatusPoller == nil || !s.config.StatusPoller.Enabled {
+		return nil
+	}
+	poller, err := newStatusPoller(s, s.config.StatusPoller)
+	if err != nil {
+		return err
+	}
+	poller.start()
+	s.pollerMtx.Lock()
+	s.poller = poller
+	s.pollerMtx.Unlock()
+	return nil
+}
+
+// StopStatusPoller stops the background worker that refreshes the status of
+// jobs, waiting for the current run to finish.
+func (s *TranscodingService) StopStatusPoller() {
+	s.pollerMtx.Lock()
+	poller := s.poller
+	s.poller = nil
+	s.pollerMtx.Unlock()
+	if poller != nil {
+		poller.stop()
+	}
+}
+
+// runningPoller returns the running status poller, or nil when it's not
+// running.
+func (s *TranscodingService) runningPoller() *statusPoller {
+	s.pollerMtx.RLock()
+	defer s.pollerMtx.RUnlock()
+	return s.poller
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/Sirupsen/logrus"
+)
+
+func TestParseProviderIntervals(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenValue    string
+		wantIntervals map[string]time.Duration
+		wantErrMsg    string
+	}{
+		{
+			"empty value",
+			"",
+			map[string]time.Duration{},
+			"",
+		},
+		{
+			"multiple providers",
+			"zencoder:10, encodingcom:60",
+			map[string]time.Duration{"zencoder": 10 * time.Second, "encodingcom": time.Minute},
+			"",
+		},
+		{
+			"missing seconds",
+			"zencoder",
+			nil,
+			`invalid provider interval "zencoder": must be in the format provider:seconds`,
+		},
+		{
+			"invalid seconds",
+			"zencoder:ten",
+			nil,
+			`invalid provider interval "zencoder:ten": seconds must be a positive integer`,
+		},
+		{
+			"zero seconds",
+			"zencoder:0",
+			nil,
+			`invalid provider interval "zencoder:0": seconds must be a positive integer`,
+		},
+	}
+	for _, test := range tests {
+		intervals, err := parseProviderIntervals(test.givenValue)
+		if test.wantErrMsg != "" {
+			if err == nil || err.Error() != test.wantErrMsg {
+				t.Errorf("%s: wrong error returned\nwant %q\ngot  %v", test.givenTestCase, test.wantErrMsg, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
+		if !reflect.DeepEqual(intervals, test.wantIntervals) {
+			t.Errorf("%s: wrong intervals returned\nwant %#v\ngot  %#v", test.givenTestCase, test.wantIntervals, intervals)
+		}
+	}
+}
+
+func TestStatusPollerPoll(t *testing.T) {
+	now := time.Now().UTC()
+	lastUpdate := now.Add(-time.Hour)
+	fakeDBObj := dbtest.NewFakeRepository(false)
+	jobs := []db.Job{
+		{
+			ID:               "job-started",
+			ProviderName:     "fake",
+			ProviderJobID:    "provider-job-123",
+			Status:           "started",
+			StatusUpdateTime: lastUpdate,
+		},
+		{
+			ID:               "job-finished",
+			ProviderName:     "fake",
+			ProviderJobID:    "provider-job-123",
+			Status:           "finished",
+			StatusUpdateTime: lastUpdate,
+		},
+		{
+			ID:               "job-recently-updated",
+			ProviderName:     "zencoder",
+			ProviderJobID:    "provider-job-123",
+			Status:           "started",
+			StatusUpdateTime: now.Add(-30 * time.Second),
+		},
+		{
+			ID:               "job-too-old",
+			ProviderName:     "fake",
+			ProviderJobID:    "provider-job-123",
+			Status:           "started",
+			StatusUpdateTime: lastUpdate,
+			CreationTime:     now.Add(-100 * time.Hour),
+		},
+	}
+	for i := range jobs {
+		fakeDBObj.CreateJob(&jobs[i])
+	}
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDBObj
+	service.notifier.db = fakeDBObj
+	poller, err := newStatusPoller(service, &config.StatusPoller{
+		Enabled:           true,
+		Concurrency:       2,
+		Interval:          30,
+		ProviderIntervals: "zencoder:120",
+		MaxJobAge:         72,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	poller.poll(now)
+	var tests = []struct {
+		jobID       string
+		wantRefresh bool
+	}{
+		{"job-started", true},
+		{"job-finished", false},
+		{"job-recently-updated", false},
+		{"job-too-old", false},
+	}
+	for _, test := range tests {
+		job, err := fakeDBObj.GetJob(test.jobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		refreshed := job.StatusUpdateTime.After(now)
+		if refreshed != test.wantRefresh {
+			t.Errorf("%s: wrong refresh state. Want %v. Got %v", test.jobID, test.wantRefresh, refreshed)
+		}
+		if refreshed && job.LastStatus == "" {
+			t.Errorf("%s: last status of the job wasn't stored", test.jobID)
+		}
+	}
+	activeJobs, err := fakeDBObj.ListJobs(db.JobFilter{Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range activeJobs {
+		if job.ID == "job-too-old" {
+			t.Errorf("%s: job past the maximum age is still active", job.ID)
+		}
+	}
+}
+
+func TestStatusPollerSkipsLockedJobs(t *testing.T) {
+	now := time.Now().UTC()
+	fakeDBObj := dbtest.NewFakeRepository(false)
+	for _, id := range []string{"job-1", "job-2"} {
+		fakeDBObj.CreateJob(&db.Job{
+			ID:               id,
+			ProviderName:     "fake",
+			ProviderJobID:    "provider-job-123",
+			Status:           "started",
+			StatusUpdateTime: now.Add(-time.Hour),
+		})
+	}
+	// job-1 is being refreshed by another instance of the API.
+	if _, err := fakeDBObj.AcquireLock("job-status:job-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDBObj
+	service.notifier.db = fakeDBObj
+	poller, err := newStatusPoller(service, &config.StatusPoller{Enabled: true, Interval: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	poller.poll(now)
+	var tests = []struct {
+		jobID       string
+		wantRefresh bool
+	}{
+		{"job-1", false},
+		{"job-2", true},
+	}
+	for _, test := range tests {
+		job, err := fakeDBObj.GetJob(test.jobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refreshed := job.StatusUpdateTime.After(now); refreshed != test.wantRefresh {
+			t.Errorf("%s: wrong refresh state. Want %v. Got %v", test.jobID, test.wantRefresh, refreshed)
+		}
+	}
+}
+
+func TestStatusPollerMaxJobAge(t *testing.T) {
+	poller, err := newStatusPoller(nil, &config.StatusPoller{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if poller.maxJobAge != 72*time.Hour {
+		t.Errorf("wrong default max job age. Want 72h0m0s. Got %s", poller.maxJobAge)
+	}
+	poller, err = newStatusPoller(nil, &config.StatusPoller{MaxJobAge: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if poller.maxJobAge != 24*time.Hour {
+		t.Errorf("wrong max job age. Want 24h0m0s. Got %s", poller.maxJobAge)
+	}
+}
+
+func TestStatusPollerTick(t *testing.T) {
+	poller, err := newStatusPoller(nil, &config.StatusPoller{Interval: 30, ProviderIntervals: "zencoder:10,encodingcom:60"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tick := poller.tick(); tick != 10*time.Second {
+		t.Errorf("wrong tick. Want 10s. Got %s", tick)
+	}
+	if interval := poller.providerInterval("encodingcom"); interval != time.Minute {
+		t.Errorf("wrong interval for encodingcom. Want 1m0s. Got %s", interval)
+	}
+	if interval := poller.providerInterval("bitmovin"); interval != 30*time.Second {
+		t.Errorf("wrong interval for bitmovin. Want 30s. Got %s", interval)
+	}
+}
+
+func TestStartStatusPollerInvalidConfig(t *testing.T) {
+	service, err := NewTranscodingService(&config.Config{
+		StatusPoller: &config.StatusPoller{Enabled: true, ProviderIntervals: "zencoder"},
+	}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = service.StartStatusPoller()
+	if err == nil {
+		t.Fatal("unexpected <nil> error")
+	}
+	if service.poller != nil {
+		t.Errorf("unexpected poller started: %#v", service.poller)
+	}
+}
+
+func TestStopStatusPollerWhileServing(t *testing.T) {
+	service, err := NewTranscodingService(&config.Config{
+		StatusPoller: &config.StatusPoller{Enabled: true},
+	}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = dbtest.NewFakeRepository(false)
+	if err = service.StartStatusPoller(); err != nil {
+		t.Fatal(err)
+	}
+	job := db.Job{ID: "job-1", ProviderName: "fake", Status: "started", LastStatus: "{}"}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			service.cachedJobStatus(&job)
+		}
+	}()
+	service.StopStatusPoller()
+	<-done
+	if poller := service.runningPoller(); poller != nil {
+		t.Errorf("unexpected poller after stopping it: %#v", poller)
+	}
+}
@@ -64,6 +64,94 @@ func (s *TranscodingService) deletePreset(r *http.Request) swagger.GizmoJSONResp
 	}
 }
 
+// swagger:route GET /presets presets listPresets
+//
+// Lists the presets created through the API, along with their definition on
+// each provider.
+//
+//     Responses:
+//       200: presetListOutput
+//       500: genericError
+func (s *TranscodingService) listPresets(r *http.Request) swagger.GizmoJSONResponse {
+	presetMaps, err := s.db.ListPresetMaps()
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	output := make(presetListOutput, len(presetMaps))
+	for i := range presetMaps {
+		output[i] = s.describePreset(&presetMaps[i])
+	}
+	return &listPresetsResponse{
+		baseResponse: baseResponse{
+			payload: output,
+			status:  http.StatusOK,
+		},
+	}
+}
+
+// swagger:route GET /presets/{name} presets getPresetDetails
+//
+// Returns the preset submitted to the API, along with its definition on each
+// provider.
+//
+//     Responses:
+//       200: presetDetails
+//       404: presetNotFound
+//       500: genericError
+func (s *TranscodingService) getPreset(r *http.Request) swagger.GizmoJSONResponse {
+	var params getPresetMapInput
+	params.loadParams(web.Vars(r))
+	if params.Name == "export" {
+		return s.exportPresets(r)
+	}
+	presetMap, err := s.db.GetPresetMap(params.Name)
+	switch err {
+	case nil:
+		return &getPresetResponse{
+			baseResponse: baseResponse{
+				payload: s.describePreset(presetMap),
+				status:  http.StatusOK,
+			},
+		}
+	case db.ErrPresetMapNotFound:
+		return newPresetMapNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+}
+
+// describePreset looks up the given presetmap on each of the providers it
+// maps to. Failures are reported per provider.
+func (s *TranscodingService) describePreset(presetMap *db.PresetMap) presetDetails {
+	details := presetDetails{
+		Name:          presetMap.Name,
+		Preset:        presetMap.Preset,
+		OutputOptions: presetMap.OutputOpts,
+		Providers:     make(map[string]providerPresetDetails, len(presetMap.ProviderMapping)),
+	}
+	for p, presetID := range presetMap.ProviderMapping {
+		result := providerPresetDetails{PresetID: presetID}
+		providerFactory, err := provider.GetProviderFactory(p)
+		if err != nil {
+			result.Error = "getting factory: " + err.Error()
+			details.Providers[p] = result
+			continue
+		}
+		providerObj, err := providerFactory(s.config)
+		if err != nil {
+			result.Error = "initializing provider: " + err.Error()
+			details.Providers[p] = result
+			continue
+		}
+		result.Preset, err = providerObj.GetPreset(presetID)
+		if err != nil {
+			result.Error = "getting preset: " + err.Error()
+		}
+		details.Providers[p] = result
+	}
+	return details
+}
+
 // swagger:route POST /presets presets Output
 //
 // Creates a new preset on given providers.
@@ -74,10 +162,6 @@ func (s *TranscodingService) deletePreset(r *http.Request) swagger.GizmoJSONResp
 func (s *TranscodingService) newPreset(r *http.Request) swagger.GizmoJSONResponse {
 	defer r.Body.Close()
 	var input newPresetInput
-	var output newPresetOutputs
-	var presetMap *db.PresetMap
-	var providers []string
-	var shouldCreatePresetMap bool
 
 	respData, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -89,27 +173,58 @@ func (s *TranscodingService) newPreset(r *http.Request) swagger.GizmoJSONRespons
 		return swagger.NewErrorResponse(err)
 	}
 
+	if err = input.Preset.Validate(); err != nil {
+		return newInvalidPresetResponse(err)
+	}
+
+	output, status, err := s.createPreset(input, r.Header.Get(authorHeader))
+	if err != nil {
+		if status == http.StatusBadRequest {
+			return newInvalidPresetResponse(err)
+		}
+		return swagger.NewErrorResponse(err)
+	}
+
+	return &newPresetResponse{
+		baseResponse: baseResponse{
+			payload: output,
+			status:  status,
+		},
+	}
+}
+
+// createPreset creates the given preset on the providers that don't have it
+// yet, creating or updating its presetmap. The returned status is the status
+// of the response, also when an error is returned.
+func (s *TranscodingService) createPreset(input newPresetInput, author string) (newPresetOutputs, int, error) {
+	var output newPresetOutputs
+	var providers []string
+	var shouldCreatePresetMap bool
+
 	output.Results = make(map[string]newPresetOutput)
 
 	// Sometimes we try to create a new preset in a new provider but we already
 	// have the PresetMap stored. We want to update the PresetMap in such cases.
-	presetMap, err = s.db.GetPresetMap(input.Preset.Name)
+	presetMap, err := s.db.GetPresetMap(input.Preset.Name)
 	if err == db.ErrPresetMapNotFound {
-		presetMap = &db.PresetMap{Name: input.Preset.Name}
+		presetMap = &db.PresetMap{Name: input.Preset.Name, Preset: &input.Preset}
 		presetMap.OutputOpts = input.OutputOptions
 		presetMap.OutputOpts.Extension = input.Preset.Container
 		presetMap.ProviderMapping = make(map[string]string)
 		if err = presetMap.OutputOpts.Validate(); err != nil {
-			return newInvalidPresetResponse(fmt.Errorf("invalid outputOptions: %s", err))
+			return output, http.StatusBadRequest, fmt.Errorf("invalid outputOptions: %s", err)
 		}
 		shouldCreatePresetMap = true
 		providers = input.Providers
 	} else if err != nil {
-		return swagger.NewErrorResponse(err)
+		return output, http.StatusInternalServerError, err
 	} else {
 		// If we already have a PresetMap for this preset, we just need to create the
 		// preset on the providers that are not mapped yet.
 		providers = s.getMissingProviders(input.Providers, presetMap.ProviderMapping)
+		if presetMap.Preset == nil {
+			presetMap.Preset = &input.Preset
+		}
 
 		// We also want to add the existent presets on the result.
 		for provider, presetID := range presetMap.ProviderMapping {
@@ -117,6 +232,7 @@ func (s *TranscodingService) newPreset(r *http.Request) swagger.GizmoJSONRespons
 		}
 	}
 
+	var unsupported int
 	for _, p := range providers {
 		providerFactory, ierr := provider.GetProviderFactory(p)
 		if ierr != nil {
@@ -130,7 +246,12 @@ func (s *TranscodingService) newPreset(r *http.Request) swagger.GizmoJSONRespons
 		}
 		presetID, ierr := providerObj.CreatePreset(input.Preset)
 		if ierr != nil {
-			output.Results[p] = newPresetOutput{PresetID: "", Error: "creating preset: " + ierr.Error()}
+			result := newPresetOutput{PresetID: "", Error: "creating preset: " + ierr.Error()}
+			if paramErr, ok := ierr.(provider.UnsupportedParameterError); ok {
+				result.UnsupportedParameter = &paramErr
+				unsupported++
+			}
+			output.Results[p] = result
 			continue
 		}
 		presetMap.ProviderMapping[p] = presetID
@@ -139,20 +260,69 @@ func (s *TranscodingService) newPreset(r *http.Request) swagger.GizmoJSONRespons
 
 	status := http.StatusOK
 	if len(presetMap.ProviderMapping) > 0 {
+		presetMap.Author = author
 		if shouldCreatePresetMap {
 			err = s.db.CreatePresetMap(presetMap)
 		} else {
 			err = s.db.UpdatePresetMap(presetMap)
 		}
 		if err != nil {
-			return newInvalidPresetResponse(fmt.Errorf("failed creating/updating presetmap after creating presets: %s", err))
+			return output, http.StatusBadRequest, fmt.Errorf("failed creating/updating presetmap after creating presets: %s", err)
 		}
 		output.PresetMap = presetMap.Name
+	} else if unsupported > 0 && unsupported == len(providers) {
+		// no provider supports the preset, so it's a client error.
+		status = http.StatusBadRequest
 	} else {
 		status = http.StatusInternalServerError
 	}
+	return output, status, nil
+}
 
-	return &newPresetResponse{
+// swagger:route PUT /presets/{name} presets updatePresetOnProviders
+//
+// Updates a preset on all the providers it's mapped to. Providers that can't
+// update presets in place get a new preset, replacing the previous one. The
+// presetmap is only updated when all providers succeed.
+//
+//     Responses:
+//       200: updatePresetOutputs
+//       400: invalidPreset
+//       404: presetNotFound
+//       500: updatePresetOutputs
+func (s *TranscodingService) updatePreset(r *http.Request) swagger.GizmoJSONResponse {
+	defer r.Body.Close()
+	var params getPresetMapInput
+	params.loadParams(web.Vars(r))
+	var input updatePresetInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return newInvalidPresetResponse(err)
+	}
+	if input.Preset.Name == "" {
+		input.Preset.Name = params.Name
+	} else if input.Preset.Name != params.Name {
+		return newInvalidPresetResponse(fmt.Errorf("preset name %q doesn't match %q", input.Preset.Name, params.Name))
+	}
+	if err := input.Preset.Validate(); err != nil {
+		return newInvalidPresetResponse(err)
+	}
+	presetMap, err := s.db.GetPresetMap(params.Name)
+	switch err {
+	case nil:
+	case db.ErrPresetMapNotFound:
+		return newPresetMapNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+	output := updatePresetOutputs{PresetMap: presetMap.Name}
+	status := http.StatusOK
+	output.Results, err = s.applyPreset(presetMap, input.Preset, nil, r.Header.Get(authorHeader))
+	if _, ok := err.(provider.UnsupportedParameterError); ok {
+		status = http.StatusBadRequest
+	} else if err != nil {
+		status = http.StatusInternalServerError
+	}
+	return &updatePresetResponse{
 		baseResponse: baseResponse{
 			payload: output,
 			status:  status,
@@ -160,6 +330,71 @@ func (s *TranscodingService) newPreset(r *http.Request) swagger.GizmoJSONRespons
 	}
 }
 
+// presetAction dispatches POST requests to /presets/{name}, where name is
+// the action to execute.
+func (s *TranscodingService) presetAction(r *http.Request) swagger.GizmoJSONResponse {
+	var params getPresetMapInput
+	params.loadParams(web.Vars(r))
+	switch params.Name {
+	case "validate":
+		return s.validatePreset(r)
+	case "import":
+		return s.importPresets(r)
+	default:
+		return swagger.NewErrorResponse(fmt.Errorf("unknown preset action %q", params.Name)).WithStatus(http.StatusNotFound)
+	}
+}
+
+// swagger:route POST /presets/validate presets validatePreset
+//
+// Validates a preset, checking that its parameters are consistent and
+// supported by the given providers. It doesn't create anything.
+//     Responses:
+//       200: presetValidation
+//       400: invalidPreset
+//       500: genericError
+func (s *TranscodingService) validatePreset(r *http.Request) swagger.GizmoJSONResponse {
+	defer r.Body.Close()
+	var input validatePresetInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return newInvalidPresetResponse(err)
+	}
+	output := presetValidation{Providers: make(map[string]providerPresetValidation)}
+	if err := input.Preset.Validate(); err != nil {
+		validationErr, ok := err.(db.PresetValidationError)
+		if !ok {
+			return swagger.NewErrorResponse(err)
+		}
+		output.Errors = validationErr
+	}
+	output.Valid = len(output.Errors) == 0
+	providers := input.Providers
+	if len(providers) == 0 {
+		providers = provider.ListProviders(s.config)
+	}
+	for _, p := range providers {
+		var errs []db.PresetFieldError
+		providerFactory, err := provider.GetProviderFactory(p)
+		if err != nil {
+			errs = append(errs, db.PresetFieldError{Message: "getting factory: " + err.Error()})
+		} else if providerObj, err := providerFactory(s.config); err != nil {
+			errs = append(errs, db.PresetFieldError{Message: "initializing provider: " + err.Error()})
+		} else if constraints := providerObj.Capabilities().PresetConstraints; constraints != nil {
+			errs = constraints.Validate(input.Preset)
+		}
+		output.Providers[p] = providerPresetValidation{Valid: len(errs) == 0, Errors: errs}
+		if len(errs) > 0 {
+			output.Valid = false
+		}
+	}
+	return &validatePresetResponse{
+		baseResponse: baseResponse{
+			payload: output,
+			status:  http.StatusOK,
+		},
+	}
+}
+
 // getMissingProviders will check what providers already have a preset associated to it
 // and return the missing ones. This method is used when a request to create a new preset
 // is done but we already have a PresetMap stored locally.
@@ -1,7 +1,11 @@
 package service
 
 import (
+	"net/url"
+	"strconv"
+
 	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
 )
 
 type newPresetInput struct {
@@ -24,6 +28,229 @@ type newPresetOutputs struct {
 type newPresetOutput struct {
 	PresetID string
 	Error    string
+
+	// UnsupportedParameter is set when the provider can't create the
+	// preset because it doesn't support one of its parameters.
+	UnsupportedParameter *provider.UnsupportedParameterError `json:",omitempty"`
+}
+
+type validatePresetInput struct {
+	// list of providers to validate the preset against. Defaults to all
+	// the configured providers.
+	Providers []string  `json:"providers"`
+	Preset    db.Preset `json:"preset"`
+}
+
+// result of the validation of a preset, including the problems found on
+// the preset itself and on each of the providers.
+//
+// swagger:response presetValidation
+type presetValidation struct {
+	// in: body
+	// required: true
+	Valid     bool                                `json:"valid"`
+	Errors    []db.PresetFieldError               `json:"errors,omitempty"`
+	Providers map[string]providerPresetValidation `json:"providers"`
+}
+
+type providerPresetValidation struct {
+	Valid  bool                  `json:"valid"`
+	Errors []db.PresetFieldError `json:"errors,omitempty"`
+}
+
+// a preset created through the API, along with its definition on each
+// provider.
+//
+// swagger:response presetDetails
+type presetDetails struct {
+	// in: body
+	// required: true
+	Name string `json:"name"`
+
+	// the preset submitted when the preset was created. It's not
+	// available for presets created before it started being stored.
+	Preset        *db.Preset                       `json:"preset,omitempty"`
+	OutputOptions db.OutputOptions                 `json:"outputOptions"`
+	Providers     map[string]providerPresetDetails `json:"providers"`
+}
+
+type providerPresetDetails struct {
+	PresetID string `json:"presetId"`
+
+	// the preset as returned by the provider, in its own format.
+	Preset interface{} `json:"preset,omitempty"`
+
+	// set when the preset couldn't be retrieved from the provider.
+	Error string `json:"error,omitempty"`
+}
+
+// list of presets created through the API.
+//
+// swagger:response presetListOutput
+type presetListOutput []presetDetails
+
+type updatePresetInput struct {
+	Preset db.Preset `json:"preset"`
+}
+
+// list of the results of the attempt to update a preset in each provider.
+//
+// swagger:response updatePresetOutputs
+type updatePresetOutputs struct {
+	// in: body
+	// required: true
+	Results   map[string]updatePresetOutput `json:"results"`
+	PresetMap string                        `json:"presetMap"`
+}
+
+type updatePresetOutput struct {
+	PresetID string `json:"presetId"`
+
+	// previous ID of the preset, set when the preset was replaced
+	// instead of updated in place.
+	PreviousPresetID string `json:"previousPresetId,omitempty"`
+
+	// whether the previous preset is kept in the provider until the
+	// active jobs using the presetmap are done.
+	PreviousPresetRetained bool   `json:"previousPresetRetained,omitempty"`
+	Error                  string `json:"error,omitempty"`
+}
+
+// drift of a preset in each provider, comparing the preset stored in the
+// provider with the preset stored in the API.
+//
+// swagger:response presetDrift
+type presetDrift struct {
+	// in: body
+	// required: true
+	PresetMap string                         `json:"presetMap"`
+	Drifted   bool                           `json:"drifted"`
+	Providers map[string]providerPresetDrift `json:"providers"`
+}
+
+type providerPresetDrift struct {
+	PresetID    string               `json:"presetId"`
+	Drifted     bool                 `json:"drifted"`
+	Differences []db.PresetFieldDiff `json:"differences,omitempty"`
+	Error       string               `json:"error,omitempty"`
+
+	// result of the repair of the preset, set when the drift was repaired.
+	Repair *updatePresetOutput `json:"repair,omitempty"`
+}
+
+// swagger:parameters getPresetVersion rollbackPreset
+type getPresetVersionInput struct {
+	getPresetMapInput
+
+	// in: path
+	// required: true
+	Version string `json:"version"`
+}
+
+func (p *getPresetVersionInput) loadParams(paramsMap map[string]string) {
+	p.getPresetMapInput.loadParams(paramsMap)
+	p.Version = paramsMap["version"]
+}
+
+// version of a preset.
+//
+// swagger:response presetVersion
+type presetVersionOutput struct {
+	// in: body
+	Payload *db.PresetVersion
+}
+
+// list of versions of a preset, from the oldest to the newest.
+//
+// swagger:response presetVersionListOutput
+type presetVersionListOutput []db.PresetVersion
+
+// results of the attempt to roll back a preset to one of its versions.
+//
+// swagger:response rollbackPresetOutputs
+type rollbackPresetOutputs struct {
+	// in: body
+	// required: true
+	Results   map[string]updatePresetOutput `json:"results"`
+	PresetMap string                        `json:"presetMap"`
+
+	// version the preset was rolled back to.
+	RestoredVersion int `json:"restoredVersion"`
+
+	// new version of the preset, created by the rollback.
+	Version int `json:"version,omitempty"`
+}
+
+// catalog of presets, describing each preset along with its output options
+// and the providers it should exist on.
+//
+// swagger:response presetCatalog
+type presetCatalogOutput struct {
+	// in: body
+	Payload *presetCatalog
+}
+
+type presetCatalog struct {
+	Presets []newPresetInput `json:"presets"`
+
+	// presetmaps without a known preset definition, which can't be
+	// exported.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// swagger:parameters importPresets
+type importPresetsInput struct {
+	// computes the plan of the import without applying it
+	//
+	// in: query
+	DryRun bool `json:"dryRun"`
+
+	// deletes the presets that are not in the catalog
+	//
+	// in: query
+	Prune bool `json:"prune"`
+
+	// in: body
+	// required: true
+	Catalog presetCatalog
+}
+
+func (p *importPresetsInput) loadParams(values url.Values) {
+	p.DryRun, _ = strconv.ParseBool(values.Get("dryRun"))
+	p.Prune, _ = strconv.ParseBool(values.Get("prune"))
+}
+
+// plan of the changes needed on each provider to make the presets of the API
+// match a catalog, along with their results when the plan is applied.
+//
+// swagger:response presetCatalogPlan
+type presetCatalogPlan struct {
+	// in: body
+	// required: true
+	DryRun  bool               `json:"dryRun"`
+	Presets []presetChangePlan `json:"presets"`
+}
+
+type presetChangePlan struct {
+	Name string `json:"name"`
+
+	// create, update, delete or none.
+	Action string `json:"action"`
+
+	// differences between the preset of the API and the preset of the
+	// catalog, including its output options.
+	Changes   []db.PresetFieldDiff          `json:"changes,omitempty"`
+	Providers map[string]providerChangePlan `json:"providers"`
+	Error     string                        `json:"error,omitempty"`
+
+	entry *newPresetInput
+}
+
+type providerChangePlan struct {
+	// create, update, delete or none.
+	Action   string `json:"action"`
+	PresetID string `json:"presetId,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
 
 // list of the results of the attempt to delete a preset
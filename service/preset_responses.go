@@ -14,6 +14,30 @@ type deletePresetResponse struct {
 	baseResponse
 }
 
+type validatePresetResponse struct {
+	baseResponse
+}
+
+type listPresetsResponse struct {
+	baseResponse
+}
+
+type getPresetResponse struct {
+	baseResponse
+}
+
+type updatePresetResponse struct {
+	baseResponse
+}
+
+type exportPresetsResponse struct {
+	baseResponse
+}
+
+type importPresetsResponse struct {
+	baseResponse
+}
+
 // error returned when the given preset data is not valid.
 //
 // swagger:response invalidPreset
@@ -29,3 +53,52 @@ func newInvalidPresetResponse(err error) *invalidPresetResponse {
 func (r *invalidPresetResponse) Result() (int, interface{}, error) {
 	return r.Error.Result()
 }
+
+type presetDriftResponse struct {
+	baseResponse
+}
+
+// error returned when the definition of the preset is not known by the API,
+// as with presetmaps created before presets were stored.
+//
+// swagger:response presetUnknown
+type presetUnknownResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+func newPresetUnknownResponse(err error) *presetUnknownResponse {
+	return &presetUnknownResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusConflict)}
+}
+
+func (r *presetUnknownResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
+
+type listPresetVersionsResponse struct {
+	baseResponse
+}
+
+type getPresetVersionResponse struct {
+	baseResponse
+}
+
+type rollbackPresetResponse struct {
+	baseResponse
+}
+
+// error returned when the given version of the preset is not found.
+//
+// swagger:response presetVersionNotFound
+type presetVersionNotFoundResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+func newPresetVersionNotFoundResponse(err error) *presetVersionNotFoundResponse {
+	return &presetVersionNotFoundResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusNotFound)}
+}
+
+func (r *presetVersionNotFoundResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
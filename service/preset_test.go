@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/NYTimes/gizmo/server"
 	"github.com/NYTimes/video-transcoding-api/config"
@@ -113,6 +114,79 @@ func TestNewPreset(t *testing.T) {
 			},
 			http.StatusInternalServerError,
 		},
+		{
+			"Preset with parameters unsupported by all providers",
+			map[string]interface{}{
+				"providers":     []string{"fake"},
+				"outputOptions": map[string]interface{}{},
+				"preset": map[string]interface{}{
+					"name":      "nyt_test_here_av1",
+					"container": "mp4",
+					"video": map[string]string{
+						"height":  "720",
+						"codec":   "av1",
+						"bitrate": "1000",
+						"crf":     "30",
+					},
+					"audio": map[string]string{
+						"codec":   "aac",
+						"bitrate": "64000",
+					},
+				},
+			},
+			db.OutputOptions{},
+			map[string]interface{}{
+				"Results": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"PresetID": "",
+						"Error":    `creating preset: unsupported value "av1" for video.codec`,
+						"UnsupportedParameter": map[string]interface{}{
+							"parameter": "video.codec",
+							"value":     "av1",
+						},
+					},
+				},
+				"PresetMap": "",
+			},
+			http.StatusBadRequest,
+		},
+		{
+			"Invalid preset",
+			map[string]interface{}{
+				"providers":     []string{"fake"},
+				"outputOptions": map[string]interface{}{},
+				"preset": map[string]interface{}{
+					"name":      "nyt_test_here_invalid",
+					"container": "mp4",
+					"video": map[string]string{
+						"codec":   "h264",
+						"gopMode": "fixed",
+					},
+				},
+			},
+			db.OutputOptions{},
+			map[string]interface{}{
+				"error": "invalid preset: video.gopSize: is required when gopMode is fixed",
+			},
+			http.StatusBadRequest,
+		},
+		{
+			"Reserved preset name",
+			map[string]interface{}{
+				"providers":     []string{"fake"},
+				"outputOptions": map[string]interface{}{},
+				"preset": map[string]interface{}{
+					"name":      "validate",
+					"container": "mp4",
+					"video":     map[string]string{"codec": "h264"},
+				},
+			},
+			db.OutputOptions{},
+			map[string]interface{}{
+				"error": `invalid preset: name: "validate" is reserved`,
+			},
+			http.StatusBadRequest,
+		},
 	}
 
 	for _, test := range tests {
@@ -149,6 +223,9 @@ func TestNewPreset(t *testing.T) {
 			if !reflect.DeepEqual(presetMap.OutputOpts, test.wantOutputOpts) {
 				t.Errorf("%s: wrong output options saved.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantOutputOpts, presetMap.OutputOpts)
 			}
+			if presetMap.Preset == nil || presetMap.Preset.Name != name {
+				t.Errorf("%s: wrong preset saved: %#v", test.givenTestCase, presetMap.Preset)
+			}
 		}
 	}
 }
@@ -278,3 +355,473 @@ func TestDeletePreset(t *testing.T) {
 		}
 	}
 }
+
+func TestValidatePreset(t *testing.T) {
+	tests := []struct {
+		givenTestCase    string
+		givenRequestData map[string]interface{}
+		wantBody         map[string]interface{}
+	}{
+		{
+			"Valid preset",
+			map[string]interface{}{
+				"providers": []string{"fake"},
+				"preset": map[string]interface{}{
+					"name":      "nyt_test_valid",
+					"container": "mp4",
+					"video": map[string]string{
+						"height":  "720",
+						"codec":   "h264",
+						"bitrate": "1000",
+					},
+					"audio": map[string]string{
+						"codec":   "aac",
+						"bitrate": "64000",
+					},
+				},
+			},
+			map[string]interface{}{
+				"valid": true,
+				"providers": map[string]interface{}{
+					"fake": map[string]interface{}{"valid": true},
+				},
+			},
+		},
+		{
+			"Invalid preset and unknown provider",
+			map[string]interface{}{
+				"providers": []string{"fake", "encodingcom"},
+				"preset": map[string]interface{}{
+					"name":      "nyt_test_invalid",
+					"container": "webm",
+					"video": map[string]string{
+						"codec":   "h264",
+						"bitrate": "1000k",
+					},
+				},
+			},
+			map[string]interface{}{
+				"valid": false,
+				"errors": []interface{}{
+					map[string]interface{}{
+						"field":   "video.bitrate",
+						"message": `invalid value "1000k": must be a non-negative integer`,
+					},
+					map[string]interface{}{
+						"field":   "video.codec",
+						"message": "h264 can't be used in webm outputs",
+					},
+				},
+				"providers": map[string]interface{}{
+					"fake": map[string]interface{}{"valid": true},
+					"encodingcom": map[string]interface{}{
+						"valid": false,
+						"errors": []interface{}{
+							map[string]interface{}{"message": "getting factory: provider not found"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDB := dbtest.NewFakeRepository(false)
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		body, _ := json.Marshal(test.givenRequestData)
+		r, _ := http.NewRequest("POST", "/presets/validate", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, http.StatusOK, w.Code)
+		}
+		var got map[string]interface{}
+		err = json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(got, test.wantBody) {
+			t.Errorf("%s: expected response body of\n%#v;\ngot\n%#v", test.givenTestCase, test.wantBody, got)
+		}
+		presetMaps, _ := fakeDB.ListPresetMaps()
+		if len(presetMaps) > 0 {
+			t.Errorf("%s: unexpected preset maps created: %#v", test.givenTestCase, presetMaps)
+		}
+	}
+}
+
+func TestListPresets(t *testing.T) {
+	fakeDB := dbtest.NewFakeRepository(false)
+	fakeDB.CreatePresetMap(&db.PresetMap{
+		Name:            "preset-1",
+		ProviderMapping: map[string]string{"fake": "preset-1-id", "encodingcom": "12345"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		Preset: &db.Preset{
+			Name:      "preset-1",
+			Container: "mp4",
+			Video:     db.VideoPreset{Codec: "h264", Bitrate: "1000"},
+		},
+	})
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	r, _ := http.NewRequest("GET", "/presets", nil)
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	var got []interface{}
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("unable to JSON decode response body: %s", err)
+	}
+	expected := []interface{}{
+		map[string]interface{}{
+			"name": "preset-1",
+			"preset": map[string]interface{}{
+				"name":      "preset-1",
+				"container": "mp4",
+				"video":     map[string]interface{}{"codec": "h264", "bitrate": "1000"},
+				"audio":     map[string]interface{}{},
+			},
+			"outputOptions": map[string]interface{}{"extension": "mp4"},
+			"providers": map[string]interface{}{
+				"fake": map[string]interface{}{
+					"presetId": "preset-1-id",
+					"preset":   map[string]interface{}{"presetID": "preset-1-id"},
+				},
+				"encodingcom": map[string]interface{}{
+					"presetId": "12345",
+					"error":    "getting factory: provider not found",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected response body of\n%#v;\ngot\n%#v", expected, got)
+	}
+}
+
+func TestGetPreset(t *testing.T) {
+	tests := []struct {
+		givenTestCase string
+		givenName     string
+		wantBody      map[string]interface{}
+		wantCode      int
+	}{
+		{
+			"Preset found",
+			"preset-1",
+			map[string]interface{}{
+				"name":          "preset-1",
+				"outputOptions": map[string]interface{}{"extension": "webm"},
+				"providers": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId": "missing",
+						"error":    "getting preset: preset not found",
+					},
+				},
+			},
+			http.StatusOK,
+		},
+		{
+			"Preset not found",
+			"preset-2",
+			map[string]interface{}{"error": "presetmap not found"},
+			http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		fakeDB := dbtest.NewFakeRepository(false)
+		fakeDB.CreatePresetMap(&db.PresetMap{
+			Name:            "preset-1",
+			ProviderMapping: map[string]string{"fake": "missing"},
+			OutputOpts:      db.OutputOptions{Extension: "webm"},
+		})
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest("GET", "/presets/"+test.givenName, nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		err = json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(got, test.wantBody) {
+			t.Errorf("%s: expected response body of\n%#v;\ngot\n%#v", test.givenTestCase, test.wantBody, got)
+		}
+	}
+}
+
+func TestUpdatePreset(t *testing.T) {
+	validPreset := map[string]interface{}{
+		"container": "mp4",
+		"video": map[string]string{
+			"height":  "720",
+			"codec":   "h264",
+			"bitrate": "2000",
+		},
+		"audio": map[string]string{
+			"codec":   "aac",
+			"bitrate": "64000",
+		},
+	}
+	tests := []struct {
+		givenTestCase    string
+		givenName        string
+		givenMapping     map[string]string
+		givenRequestData map[string]interface{}
+		wantBody         map[string]interface{}
+		wantCode         int
+		wantMapping      map[string]string
+		wantDeleted      []string
+	}{
+		{
+			"Update preset",
+			"preset-1",
+			map[string]string{"fake": "old-id"},
+			map[string]interface{}{"preset": validPreset},
+			map[string]interface{}{
+				"results": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId":         "presetID_here",
+						"previousPresetId": "old-id",
+					},
+				},
+				"presetMap": "preset-1",
+			},
+			http.StatusOK,
+			map[string]string{"fake": "presetID_here"},
+			[]string{"old-id"},
+		},
+		{
+			"Failure in one of the providers",
+			"preset-1",
+			map[string]string{"fake": "old-id", "unknown": "123"},
+			map[string]interface{}{"preset": validPreset},
+			map[string]interface{}{
+				"results": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId": "old-id",
+						"error":    "rolled back",
+					},
+					"unknown": map[string]interface{}{
+						"presetId": "123",
+						"error":    "getting factory: provider not found",
+					},
+				},
+				"presetMap": "preset-1",
+			},
+			http.StatusInternalServerError,
+			map[string]string{"fake": "old-id", "unknown": "123"},
+			[]string{"presetID_here"},
+		},
+		{
+			"Unsupported parameter",
+			"preset-1",
+			map[string]string{"fake": "old-id"},
+			map[string]interface{}{
+				"preset": map[string]interface{}{
+					"container": "mp4",
+					"video":     map[string]string{"codec": "av1", "bitrate": "2000"},
+				},
+			},
+			map[string]interface{}{
+				"results": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId": "old-id",
+						"error":    `creating preset: unsupported value "av1" for video.codec`,
+					},
+				},
+				"presetMap": "preset-1",
+			},
+			http.StatusBadRequest,
+			map[string]string{"fake": "old-id"},
+			nil,
+		},
+		{
+			"Invalid preset",
+			"preset-1",
+			map[string]string{"fake": "old-id"},
+			map[string]interface{}{
+				"preset": map[string]interface{}{
+					"name":      "preset-2",
+					"container": "mp4",
+				},
+			},
+			map[string]interface{}{"error": `preset name "preset-2" doesn't match "preset-1"`},
+			http.StatusBadRequest,
+			map[string]string{"fake": "old-id"},
+			nil,
+		},
+		{
+			"Preset not found",
+			"preset-2",
+			map[string]string{"fake": "old-id"},
+			map[string]interface{}{"preset": validPreset},
+			map[string]interface{}{"error": "presetmap not found"},
+			http.StatusNotFound,
+			map[string]string{"fake": "old-id"},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		fprovider.deletedPresets = nil
+		fakeDB := dbtest.NewFakeRepository(false)
+		fakeDB.CreatePresetMap(&db.PresetMap{
+			Name:            "preset-1",
+			ProviderMapping: test.givenMapping,
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		})
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		body, _ := json.Marshal(test.givenRequestData)
+		r, _ := http.NewRequest("PUT", "/presets/"+test.givenName, bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		err = json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(got, test.wantBody) {
+			t.Errorf("%s: expected response body of\n%#v;\ngot\n%#v", test.givenTestCase, test.wantBody, got)
+		}
+		presetMap, err := fakeDB.GetPresetMap("preset-1")
+		if err != nil {
+			t.Fatalf("%s: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(presetMap.ProviderMapping, test.wantMapping) {
+			t.Errorf("%s: wrong provider mapping.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantMapping, presetMap.ProviderMapping)
+		}
+		if test.wantCode == http.StatusOK && (presetMap.Preset == nil || presetMap.Preset.Video.Bitrate != "2000") {
+			t.Errorf("%s: preset not updated: %#v", test.givenTestCase, presetMap.Preset)
+		}
+		if !reflect.DeepEqual(fprovider.deletedPresets, test.wantDeleted) {
+			t.Errorf("%s: wrong deleted presets.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantDeleted, fprovider.deletedPresets)
+		}
+	}
+}
+
+func TestUpdatePresetWithActiveJob(t *testing.T) {
+	fprovider.deletedPresets = nil
+	fakeDB := dbtest.NewFakeRepository(false)
+	fakeDB.CreatePresetMap(&db.PresetMap{
+		Name:            "preset-1",
+		ProviderMapping: map[string]string{"fake": "old-id"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+	})
+	fakeDB.CreateJob(&db.Job{
+		ID:           "job-1",
+		ProviderName: "fake",
+		Status:       "started",
+		Outputs:      []db.TranscodeOutput{{Preset: db.PresetMap{Name: "preset-1"}, FileName: "output.mp4"}},
+	})
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	body, _ := json.Marshal(map[string]interface{}{
+		"preset": map[string]interface{}{
+			"container": "mp4",
+			"video":     map[string]string{"height": "720", "codec": "h264", "bitrate": "2000"},
+		},
+	})
+	r, _ := http.NewRequest("PUT", "/presets/preset-1", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	var got map[string]interface{}
+	if err = json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	wantBody := map[string]interface{}{
+		"results": map[string]interface{}{
+			"fake": map[string]interface{}{
+				"presetId":               "presetID_here",
+				"previousPresetId":       "old-id",
+				"previousPresetRetained": true,
+			},
+		},
+		"presetMap": "preset-1",
+	}
+	if !reflect.DeepEqual(got, wantBody) {
+		t.Errorf("wrong response body.\nWant %#v\nGot  %#v", wantBody, got)
+	}
+	if len(fprovider.deletedPresets) > 0 {
+		t.Errorf("unexpected deleted presets while the job is active: %#v", fprovider.deletedPresets)
+	}
+	wantRetired := []db.RetiredPreset{{ProviderName: "fake", PresetID: "old-id", PresetMap: "preset-1"}}
+	retired, err := fakeDB.ListRetiredPresets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range retired {
+		retired[i].RetirementTime = time.Time{}
+	}
+	if !reflect.DeepEqual(retired, wantRetired) {
+		t.Errorf("wrong retired presets.\nWant %#v\nGot  %#v", wantRetired, retired)
+	}
+
+	service.deleteRetiredPresets()
+	if len(fprovider.deletedPresets) > 0 {
+		t.Errorf("unexpected deleted presets while the job is active: %#v", fprovider.deletedPresets)
+	}
+	fakeDB.ExpireActiveJobs(time.Now().Add(time.Hour))
+	service.deleteRetiredPresets()
+	if len(fprovider.deletedPresets) > 0 {
+		t.Errorf("unexpected deleted presets while the expired job is still running: %#v", fprovider.deletedPresets)
+	}
+	fakeDB.UpdateJob("job-1", func(job *db.Job) {
+		job.Status = "finished"
+	})
+	service.deleteRetiredPresets()
+	if !reflect.DeepEqual(fprovider.deletedPresets, []string{"old-id"}) {
+		t.Errorf("wrong deleted presets after the job finished. Want %#v. Got %#v", []string{"old-id"}, fprovider.deletedPresets)
+	}
+	retired, err = fakeDB.ListRetiredPresets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retired) > 0 {
+		t.Errorf("unexpected retired presets after deleting them: %#v", retired)
+	}
+}
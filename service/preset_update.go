@@ -0,0 +1,231 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+// providerPreset is the preset of a presetmap on one of its providers.
+type providerPreset struct {
+	name     string
+	provider provider.TranscodingProvider
+	presetID string
+}
+
+// presetUpdate applies a new version of a preset to the providers of a
+// presetmap, keeping track of what has been done so it can be undone.
+type presetUpdate struct {
+	presetMap *db.PresetMap
+	preset    db.Preset
+	results   map[string]updatePresetOutput
+
+	// presets created by providers that can't update presets in place,
+	// mapped by provider name.
+	created map[string]providerPreset
+
+	// providers that updated the preset in place.
+	updated []providerPreset
+}
+
+// applyPreset applies the given preset to the given providers of the
+// presetmap, or to all of them when no providers are given. Providers that
+// implement provider.PresetUpdater update their presets in place, while the
+// other providers, and providers without a preset in the presetmap, get a new
+// preset that replaces the previous one.
+//
+// The presetmap is only updated in the database after all providers succeed.
+// In case of failures, new presets are deleted and presets updated in place
+// are restored to the previous version of the preset, when known. Previous
+// presets that got replaced are retired after the presetmap is updated, and
+// deleted once no active job of the provider uses the presetmap.
+func (s *TranscodingService) applyPreset(presetMap *db.PresetMap, preset db.Preset, names []string, author string) (map[string]updatePresetOutput, error) {
+	u := presetUpdate{
+		presetMap: presetMap,
+		preset:    preset,
+		results:   make(map[string]updatePresetOutput, len(presetMap.ProviderMapping)),
+		created:   make(map[string]providerPreset),
+	}
+	if len(names) == 0 {
+		names = make([]string, 0, len(presetMap.ProviderMapping))
+		for name := range presetMap.ProviderMapping {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// presets are re-created before any in place update, as new presets
+	// can always be discarded.
+	var updaters []providerPreset
+	for _, name := range names {
+		p := providerPreset{name: name, presetID: presetMap.ProviderMapping[name]}
+		providerObj, err := s.providerByName(name)
+		if err != nil {
+			u.fail(name, err)
+			return u.results, err
+		}
+		p.provider = providerObj
+		if _, ok := providerObj.(provider.PresetUpdater); ok && p.presetID != "" {
+			updaters = append(updaters, p)
+			continue
+		}
+		if err = u.recreate(p); err != nil {
+			u.fail(name, fmt.Errorf("creating preset: %s", err))
+			return u.results, err
+		}
+	}
+	for _, p := range updaters {
+		err := p.provider.(provider.PresetUpdater).UpdatePreset(p.presetID, preset)
+		if err != nil {
+			u.fail(p.name, fmt.Errorf("updating preset: %s", err))
+			return u.results, err
+		}
+		u.updated = append(u.updated, p)
+		u.results[p.name] = updatePresetOutput{PresetID: p.presetID}
+	}
+
+	updatedMap := *presetMap
+	updatedMap.Preset = &preset
+	updatedMap.Author = author
+	updatedMap.ProviderMapping = make(map[string]string, len(presetMap.ProviderMapping))
+	for name, presetID := range presetMap.ProviderMapping {
+		updatedMap.ProviderMapping[name] = presetID
+	}
+	for name, result := range u.results {
+		updatedMap.ProviderMapping[name] = result.PresetID
+	}
+	if err := s.db.UpdatePresetMap(&updatedMap); err != nil {
+		u.rollback()
+		return u.results, fmt.Errorf("updating presetmap: %s", err)
+	}
+	for name, p := range u.created {
+		result := u.results[name]
+		if result.PreviousPresetID == "" || result.PreviousPresetID == result.PresetID {
+			continue
+		}
+		retired := db.RetiredPreset{ProviderName: name, PresetID: result.PreviousPresetID, PresetMap: presetMap.Name}
+		if err := s.db.CreateRetiredPreset(&retired); err != nil {
+			result.Error = "retiring previous preset: " + err.Error()
+			u.results[name] = result
+			continue
+		}
+		deleted, err := s.deleteRetiredPreset(p.provider, &retired)
+		if err != nil {
+			result.Error = "deleting previous preset: " + err.Error()
+		}
+		result.PreviousPresetRetained = !deleted
+		u.results[name] = result
+	}
+	*presetMap = updatedMap
+	return u.results, nil
+}
+
+// deleteRetiredPreset deletes the given retired preset from the provider,
+// unless jobs of the provider created before the preset was retired use its
+// presetmap and aren't done yet, as they may still reference the preset. Jobs
+// are checked by their last known status rather than by the active jobs, as
+// jobs past the maximum age of the status poller leave the active jobs while
+// they may still be running. It returns whether the preset was deleted.
+func (s *TranscodingService) deleteRetiredPreset(providerObj provider.TranscodingProvider, retired *db.RetiredPreset) (bool, error) {
+	jobs, err := s.db.ListJobs(db.JobFilter{
+		Until:        retired.RetirementTime,
+		ProviderName: retired.ProviderName,
+		PresetName:   retired.PresetMap,
+	})
+	if err != nil {
+		return false, err
+	}
+	for i := range jobs {
+		if !jobs[i].Done() {
+			return false, nil
+		}
+	}
+	if err = providerObj.DeletePreset(retired.PresetID); err != nil {
+		return false, err
+	}
+	if err = s.db.DeleteRetiredPreset(retired); err != nil && err != db.ErrRetiredPresetNotFound {
+		return true, err
+	}
+	return true, nil
+}
+
+// deleteRetiredPresets deletes the retired presets that are no longer used
+// by jobs that aren't done yet.
+func (s *TranscodingService) deleteRetiredPresets() {
+	retiredPresets, err := s.db.ListRetiredPresets()
+	if err != nil {
+		s.logger.WithError(err).Error("failed to list retired presets")
+		return
+	}
+	for i := range retiredPresets {
+		retired := &retiredPresets[i]
+		providerObj, err := s.providerByName(retired.ProviderName)
+		if err == nil {
+			_, err = s.deleteRetiredPreset(providerObj, retired)
+		}
+		if err != nil {
+			s.logger.WithError(err).Errorf("failed to delete retired preset %q of provider %q", retired.PresetID, retired.ProviderName)
+		}
+	}
+}
+
+// recreate creates a new preset on the given provider. The name of the preset
+// gets a suffix, so it doesn't clash with the previous preset on providers
+// that use the name as the ID of the preset.
+func (u *presetUpdate) recreate(p providerPreset) error {
+	preset := u.preset
+	preset.Name = fmt.Sprintf("%s-%d", preset.Name, time.Now().Unix())
+	presetID, err := p.provider.CreatePreset(preset)
+	if err != nil {
+		return err
+	}
+	u.created[p.name] = providerPreset{name: p.name, provider: p.provider, presetID: presetID}
+	u.results[p.name] = updatePresetOutput{PresetID: presetID, PreviousPresetID: p.presetID}
+	return nil
+}
+
+// fail records the error of the given provider and rolls back the changes
+// made so far.
+func (u *presetUpdate) fail(name string, err error) {
+	u.rollback()
+	u.results[name] = updatePresetOutput{PresetID: u.presetMap.ProviderMapping[name], Error: err.Error()}
+}
+
+// rollback deletes the presets created so far and restores the previous
+// version of the preset on providers that updated it in place.
+func (u *presetUpdate) rollback() {
+	for name, p := range u.created {
+		result := updatePresetOutput{PresetID: u.presetMap.ProviderMapping[name], Error: "rolled back"}
+		if err := p.provider.DeletePreset(p.presetID); err != nil {
+			result.Error = fmt.Sprintf("rolling back: deleting preset %q: %s", p.presetID, err)
+		}
+		u.results[name] = result
+	}
+	for _, p := range u.updated {
+		result := updatePresetOutput{PresetID: p.presetID, Error: "rolled back"}
+		if u.presetMap.Preset == nil {
+			result.Error = "rolling back: previous version of the preset is unknown"
+		} else if err := p.provider.(provider.PresetUpdater).UpdatePreset(p.presetID, *u.presetMap.Preset); err != nil {
+			result.Error = "rolling back: " + err.Error()
+		}
+		u.results[p.name] = result
+	}
+	u.created = make(map[string]providerPreset)
+	u.updated = nil
+}
+
+// providerByName instantiates the provider with the given name.
+func (s *TranscodingService) providerByName(name string) (provider.TranscodingProvider, error) {
+	providerFactory, err := provider.GetProviderFactory(name)
+	if err != nil {
+		return nil, fmt.Errorf("getting factory: %s", err)
+	}
+	providerObj, err := providerFactory(s.config)
+	if err != nil {
+		return nil, fmt.Errorf("initializing provider: %s", err)
+	}
+	return providerObj, nil
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/NYTimes/gizmo/web"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+// authorHeader is the header identifying the author of changes to presets,
+// recorded in the versions of presets.
+const authorHeader = "X-Author"
+
+var errPresetVersionNotRestorable = errors.New("presets of the version no longer exist in all providers and can't be re-created, as the definition of the preset is unknown")
+
+// swagger:route GET /presets/{name}/versions presets listPresetVersions
+//
+// Lists the versions of a preset, including versions of deleted presets.
+//
+//     Responses:
+//       200: presetVersionListOutput
+//       404: presetNotFound
+//       500: genericError
+func (s *TranscodingService) listPresetVersions(r *http.Request) swagger.GizmoJSONResponse {
+	var params getPresetMapInput
+	params.loadParams(web.Vars(r))
+	versions, err := s.db.ListPresetVersions(params.Name)
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	if len(versions) == 0 {
+		return newPresetMapNotFoundResponse(db.ErrPresetMapNotFound)
+	}
+	return &listPresetVersionsResponse{
+		baseResponse: baseResponse{
+			payload: presetVersionListOutput(versions),
+			status:  http.StatusOK,
+		},
+	}
+}
+
+// swagger:route GET /presets/{name}/versions/{version} presets getPresetVersion
+//
+// Finds a version of a preset.
+//
+//     Responses:
+//       200: presetVersion
+//       404: presetVersionNotFound
+//       500: genericError
+func (s *TranscodingService) getPresetVersion(r *http.Request) swagger.GizmoJSONResponse {
+	var params getPresetVersionInput
+	params.loadParams(web.Vars(r))
+	version, err := s.findPresetVersion(params)
+	switch err {
+	case nil:
+		return &getPresetVersionResponse{
+			baseResponse: baseResponse{
+				payload: version,
+				status:  http.StatusOK,
+			},
+		}
+	case db.ErrPresetVersionNotFound:
+		return newPresetVersionNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+}
+
+// swagger:route POST /presets/{name}/versions/{version}/rollback presets rollbackPreset
+//
+// Rolls a preset back to one of its versions, creating a new version. When
+// the definition of the preset is known, it's applied to the providers of the
+// version, re-creating presets that no longer exist. Otherwise, the presets of
+// the version are restored as long as they still exist in the providers.
+//
+//     Responses:
+//       200: rollbackPresetOutputs
+//       404: presetVersionNotFound
+//       409: rollbackPresetOutputs
+//       500: rollbackPresetOutputs
+func (s *TranscodingService) rollbackPreset(r *http.Request) swagger.GizmoJSONResponse {
+	var params getPresetVersionInput
+	params.loadParams(web.Vars(r))
+	presetMap, err := s.db.GetPresetMap(params.Name)
+	switch err {
+	case nil:
+	case db.ErrPresetMapNotFound:
+		return newPresetMapNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+	version, err := s.findPresetVersion(params)
+	switch err {
+	case nil:
+	case db.ErrPresetVersionNotFound:
+		return newPresetVersionNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+	output := rollbackPresetOutputs{PresetMap: presetMap.Name, RestoredVersion: version.Version}
+	status := http.StatusOK
+	output.Results, err = s.restorePresetVersion(presetMap, version, r.Header.Get(authorHeader))
+	switch err {
+	case nil:
+		output.Version = presetMap.Version
+	case errPresetVersionNotRestorable:
+		status = http.StatusConflict
+	default:
+		status = http.StatusInternalServerError
+	}
+	return &rollbackPresetResponse{
+		baseResponse: baseResponse{
+			payload: output,
+			status:  status,
+		},
+	}
+}
+
+func (s *TranscodingService) findPresetVersion(params getPresetVersionInput) (*db.PresetVersion, error) {
+	version, err := strconv.Atoi(params.Version)
+	if err != nil {
+		return nil, db.ErrPresetVersionNotFound
+	}
+	return s.db.GetPresetVersion(params.Name, version)
+}
+
+// restorePresetVersion restores the presetmap to the given version. Providers
+// that are not in the version are removed from the presetmap, but their
+// presets are kept, as they may be restored by rolling back to another
+// version.
+func (s *TranscodingService) restorePresetVersion(presetMap *db.PresetMap, version *db.PresetVersion, author string) (map[string]updatePresetOutput, error) {
+	target := version.PresetMap
+	if target.Preset == nil {
+		return s.restorePresetMapping(presetMap, target, author)
+	}
+	restored := *presetMap
+	restored.OutputOpts = target.OutputOpts
+	restored.ProviderMapping = make(map[string]string, len(target.ProviderMapping))
+	for name, presetID := range target.ProviderMapping {
+		if currentID, ok := presetMap.ProviderMapping[name]; ok {
+			presetID = currentID
+		}
+		if s.checkProviderPreset(name, presetID) != nil {
+			// an empty ID makes applyPreset create a new preset.
+			presetID = ""
+		}
+		restored.ProviderMapping[name] = presetID
+	}
+	results, err := s.applyPreset(&restored, *target.Preset, nil, author)
+	if err != nil {
+		return results, err
+	}
+	*presetMap = restored
+	return results, nil
+}
+
+// restorePresetMapping restores the mapping of providers of a version whose
+// preset is unknown, which is only possible if the presets still exist.
+func (s *TranscodingService) restorePresetMapping(presetMap *db.PresetMap, target db.PresetMap, author string) (map[string]updatePresetOutput, error) {
+	results := make(map[string]updatePresetOutput, len(target.ProviderMapping))
+	restorable := true
+	for name, presetID := range target.ProviderMapping {
+		result := updatePresetOutput{PresetID: presetID, PreviousPresetID: presetMap.ProviderMapping[name]}
+		if err := s.checkProviderPreset(name, presetID); err != nil {
+			result.Error = err.Error()
+			restorable = false
+		}
+		results[name] = result
+	}
+	if !restorable {
+		return results, errPresetVersionNotRestorable
+	}
+	restored := target
+	restored.Author = author
+	restored.ProviderMapping = make(map[string]string, len(target.ProviderMapping))
+	for name, presetID := range target.ProviderMapping {
+		restored.ProviderMapping[name] = presetID
+	}
+	if err := s.db.UpdatePresetMap(&restored); err != nil {
+		return results, fmt.Errorf("updating presetmap: %s", err)
+	}
+	*presetMap = restored
+	return results, nil
+}
+
+// checkProviderPreset checks that the given preset exists in the provider.
+func (s *TranscodingService) checkProviderPreset(name, presetID string) error {
+	if presetID == "" {
+		return errors.New("preset not found")
+	}
+	providerObj, err := s.providerByName(name)
+	if err != nil {
+		return err
+	}
+	if _, err = providerObj.GetPreset(presetID); err != nil {
+		return fmt.Errorf("getting preset: %s", err)
+	}
+	return nil
+}
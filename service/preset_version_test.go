@@ -0,0 +1,314 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/Sirupsen/logrus"
+)
+
+func TestListPresetVersions(t *testing.T) {
+	tests := []struct {
+		givenTestCase string
+		givenName     string
+		wantCode      int
+		wantVersions  []int
+	}{
+		{
+			"List versions",
+			"preset-1",
+			http.StatusOK,
+			[]int{1, 2},
+		},
+		{
+			"Preset not found",
+			"preset-2",
+			http.StatusNotFound,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		fakeDB := dbtest.NewFakeRepository(false)
+		fakeDB.CreatePresetMap(&db.PresetMap{Name: "preset-1", ProviderMapping: map[string]string{"fake": "id-1"}})
+		fakeDB.UpdatePresetMap(&db.PresetMap{Name: "preset-1", ProviderMapping: map[string]string{"fake": "id-2"}, Author: "someone"})
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest("GET", "/presets/"+test.givenName+"/versions", nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		if test.wantCode != http.StatusOK {
+			continue
+		}
+		var got []db.PresetVersion
+		err = json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		versions := make([]int, len(got))
+		for i, version := range got {
+			versions[i] = version.Version
+		}
+		if !reflect.DeepEqual(versions, test.wantVersions) {
+			t.Errorf("%s: wrong versions. Want %v. Got %v", test.givenTestCase, test.wantVersions, versions)
+		}
+		expectedChanges := []db.PresetFieldDiff{{Field: "providerMapping.fake", From: "id-1", To: "id-2"}}
+		if !reflect.DeepEqual(got[1].Changes, expectedChanges) {
+			t.Errorf("%s: wrong changes. Want %#v. Got %#v", test.givenTestCase, expectedChanges, got[1].Changes)
+		}
+		if got[1].Author != "someone" {
+			t.Errorf("%s: wrong author. Want %q. Got %q", test.givenTestCase, "someone", got[1].Author)
+		}
+	}
+}
+
+func TestGetPresetVersion(t *testing.T) {
+	tests := []struct {
+		givenTestCase string
+		givenURI      string
+		wantCode      int
+		wantMapping   map[string]string
+	}{
+		{
+			"Get version",
+			"/presets/preset-1/versions/1",
+			http.StatusOK,
+			map[string]string{"fake": "id-1"},
+		},
+		{
+			"Version not found",
+			"/presets/preset-1/versions/3",
+			http.StatusNotFound,
+			nil,
+		},
+		{
+			"Invalid version",
+			"/presets/preset-1/versions/latest",
+			http.StatusNotFound,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		fakeDB := dbtest.NewFakeRepository(false)
+		fakeDB.CreatePresetMap(&db.PresetMap{Name: "preset-1", ProviderMapping: map[string]string{"fake": "id-1"}})
+		fakeDB.UpdatePresetMap(&db.PresetMap{Name: "preset-1", ProviderMapping: map[string]string{"fake": "id-2"}})
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest("GET", test.givenURI, nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		if test.wantCode != http.StatusOK {
+			continue
+		}
+		var got db.PresetVersion
+		err = json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(got.PresetMap.ProviderMapping, test.wantMapping) {
+			t.Errorf("%s: wrong provider mapping. Want %#v. Got %#v", test.givenTestCase, test.wantMapping, got.PresetMap.ProviderMapping)
+		}
+	}
+}
+
+func TestRollbackPreset(t *testing.T) {
+	oldPreset := db.Preset{Name: "preset-1", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "1000"}}
+	newPreset := db.Preset{Name: "preset-1", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "2000"}}
+	tests := []struct {
+		givenTestCase string
+		givenURI      string
+		givenVersions []db.PresetMap
+		wantBody      map[string]interface{}
+		wantCode      int
+		wantMapping   map[string]string
+		wantPreset    *db.Preset
+		wantDeleted   []string
+	}{
+		{
+			"Rollback preset",
+			"/presets/preset-1/versions/1/rollback",
+			[]db.PresetMap{
+				{Name: "preset-1", ProviderMapping: map[string]string{"fake": "id-1"}, Preset: &oldPreset},
+				{Name: "preset-1", ProviderMapping: map[string]string{"fake": "id-2"}, Preset: &newPreset},
+			},
+			map[string]interface{}{
+				"results": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId":         "presetID_here",
+						"previousPresetId": "id-2",
+					},
+				},
+				"presetMap":       "preset-1",
+				"restoredVersion": float64(1),
+				"version":         float64(3),
+			},
+			http.StatusOK,
+			map[string]string{"fake": "presetID_here"},
+			&oldPreset,
+			[]string{"id-2"},
+		},
+		{
+			"Rollback preset missing in the provider",
+			"/presets/preset-1/versions/1/rollback",
+			[]db.PresetMap{
+				{Name: "preset-1", ProviderMapping: map[string]string{"fake": "id-1"}, Preset: &oldPreset},
+				{Name: "preset-1", ProviderMapping: map[string]string{"fake": "missing"}, Preset: &newPreset},
+			},
+			map[string]interface{}{
+				"results": map[string]interface{}{
+					"fake": map[string]interface{}{"presetId": "presetID_here"},
+				},
+				"presetMap":       "preset-1",
+				"restoredVersion": float64(1),
+				"version":         float64(3),
+			},
+			http.StatusOK,
+			map[string]string{"fake": "presetID_here"},
+			&oldPreset,
+			nil,
+		},
+		{
+			"Rollback presetmap without preset",
+			"/presets/preset-1/versions/1/rollback",
+			[]db.PresetMap{
+				{Name: "preset-1", ProviderMapping: map[string]string{"fake": "id-1"}},
+				{Name: "preset-1", ProviderMapping: map[string]string{"fake": "id-2"}},
+			},
+			map[string]interface{}{
+				"results": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId":         "id-1",
+						"previousPresetId": "id-2",
+					},
+				},
+				"presetMap":       "preset-1",
+				"restoredVersion": float64(1),
+				"version":         float64(3),
+			},
+			http.StatusOK,
+			map[string]string{"fake": "id-1"},
+			nil,
+			nil,
+		},
+		{
+			"Presets of the version no longer exist",
+			"/presets/preset-1/versions/1/rollback",
+			[]db.PresetMap{
+				{Name: "preset-1", ProviderMapping: map[string]string{"fake": "missing"}},
+				{Name: "preset-1", ProviderMapping: map[string]string{"fake": "id-2"}},
+			},
+			map[string]interface{}{
+				"results": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId":         "missing",
+						"previousPresetId": "id-2",
+						"error":            "getting preset: preset not found",
+					},
+				},
+				"presetMap":       "preset-1",
+				"restoredVersion": float64(1),
+			},
+			http.StatusConflict,
+			map[string]string{"fake": "id-2"},
+			nil,
+			nil,
+		},
+		{
+			"Version not found",
+			"/presets/preset-1/versions/5/rollback",
+			[]db.PresetMap{
+				{Name: "preset-1", ProviderMapping: map[string]string{"fake": "id-1"}},
+			},
+			map[string]interface{}{"error": "preset version not found"},
+			http.StatusNotFound,
+			map[string]string{"fake": "id-1"},
+			nil,
+			nil,
+		},
+		{
+			"Preset not found",
+			"/presets/preset-2/versions/1/rollback",
+			[]db.PresetMap{
+				{Name: "preset-1", ProviderMapping: map[string]string{"fake": "id-1"}},
+			},
+			map[string]interface{}{"error": "presetmap not found"},
+			http.StatusNotFound,
+			map[string]string{"fake": "id-1"},
+			nil,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		fprovider.deletedPresets = nil
+		fakeDB := dbtest.NewFakeRepository(false)
+		for i := range test.givenVersions {
+			if i == 0 {
+				fakeDB.CreatePresetMap(&test.givenVersions[i])
+			} else {
+				fakeDB.UpdatePresetMap(&test.givenVersions[i])
+			}
+		}
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest("POST", test.givenURI, nil)
+		r.Header.Set(authorHeader, "someone")
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		err = json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(got, test.wantBody) {
+			t.Errorf("%s: expected response body of\n%#v;\ngot\n%#v", test.givenTestCase, test.wantBody, got)
+		}
+		presetMap, err := fakeDB.GetPresetMap("preset-1")
+		if err != nil {
+			t.Fatalf("%s: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(presetMap.ProviderMapping, test.wantMapping) {
+			t.Errorf("%s: wrong provider mapping.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantMapping, presetMap.ProviderMapping)
+		}
+		if test.wantCode == http.StatusOK {
+			if !reflect.DeepEqual(presetMap.Preset, test.wantPreset) {
+				t.Errorf("%s: wrong preset.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantPreset, presetMap.Preset)
+			}
+			if presetMap.Author != "someone" {
+				t.Errorf("%s: wrong author. Want %q. Got %q", test.givenTestCase, "someone", presetMap.Author)
+			}
+		}
+		if !reflect.DeepEqual(fprovider.deletedPresets, test.wantDeleted) {
+			t.Errorf("%s: wrong deleted presets.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantDeleted, fprovider.deletedPresets)
+		}
+	}
+}
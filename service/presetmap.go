@@ -24,6 +24,7 @@ func (s *TranscodingService) newPresetMap(r *http.Request) swagger.GizmoJSONResp
 	if err != nil {
 		return newInvalidPresetMapResponse(err)
 	}
+	preset.Author = r.Header.Get(authorHeader)
 	err = s.db.CreatePresetMap(&preset)
 	switch err {
 	case nil:
@@ -74,6 +75,7 @@ func (s *TranscodingService) updatePresetMap(r *http.Request) swagger.GizmoJSONR
 	if err != nil {
 		return newInvalidPresetMapResponse(err)
 	}
+	presetMap.Author = r.Header.Get(authorHeader)
 	err = s.db.UpdatePresetMap(&presetMap)
 
 	switch err {
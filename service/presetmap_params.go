@@ -21,7 +21,7 @@ type presetMapResponse struct {
 	baseResponse
 }
 
-// swagger:parameters getPreset deletePreset deletePresetMap
+// swagger:parameters getPreset getPresetDetails deletePreset deletePresetMap getPresetDrift repairPresetDrift listPresetVersions
 type getPresetMapInput struct {
 	// in: path
 	// required: true
@@ -48,6 +48,8 @@ func TestNewPresetMap(t *testing.T) {
 				"output": map[string]interface{}{
 					"extension": "mp4",
 				},
+				"version": float64(1),
+				"author":  "someone",
 			},
 		},
 		{
@@ -144,6 +146,7 @@ func TestNewPresetMap(t *testing.T) {
 		body, _ := json.Marshal(test.givenRequestData)
 		r, _ := http.NewRequest("POST", "/presetmaps", bytes.NewReader(body))
 		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(authorHeader, "someone")
 		w := httptest.NewRecorder()
 		srvr.ServeHTTP(w, r)
 		if w.Code != test.wantCode {
@@ -179,7 +182,7 @@ func TestGetPresetMap(t *testing.T) {
 		{
 			"Get preset",
 			"preset-1",
-			&db.PresetMap{Name: "preset-1"},
+			&db.PresetMap{Name: "preset-1", Version: 1},
 			http.StatusOK,
 		},
 		{
@@ -242,6 +245,7 @@ func TestUpdatePresetMap(t *testing.T) {
 					"elementalconductor": "abc-123",
 					"elastictranscoder":  "def-345",
 				},
+				Version: 2,
 			},
 			http.StatusOK,
 		},
@@ -370,14 +374,17 @@ func TestListPresetMaps(t *testing.T) {
 				"preset-1": {
 					Name:            "preset-1",
 					ProviderMapping: map[string]string{"elementalconductor": "abc123"},
+					Version:         1,
 				},
 				"preset-2": {
 					Name:            "preset-2",
 					ProviderMapping: map[string]string{"elementalconductor": "abc124"},
+					Version:         1,
 				},
 				"preset-3": {
 					Name:            "preset-3",
 					ProviderMapping: map[string]string{"elementalconductor": "abc125"},
+					Version:         1,
 				},
 			},
 		},
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/probe"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+const defaultProbeTimeout = 10 * time.Second
+
+// probeRule checks the probed source media of a job, returning an error when
+// the job should be rejected.
+type probeRule func(source *provider.SourceInfo, job *db.Job) error
+
+// sourceProber inspects the source media of jobs before they're submitted to
+// providers, rejecting jobs that don't satisfy the configured rules.
+type sourceProber struct {
+	enabled bool
+	rules   []probeRule
+	probe   func(source string) (*provider.SourceInfo, error)
+}
+
+func newSourceProber(cfg *config.Probe) (*sourceProber, error) {
+	prober := probe.Prober{
+		Client: &http.Client{
+			Timeout:   defaultProbeTimeout,
+			Transport: &http.Transport{DialContext: dialExternal},
+		},
+	}
+	p := sourceProber{probe: prober.Probe}
+	if cfg == nil {
+		return &p, nil
+	}
+	if cfg.Timeout > 0 {
+		prober.Client.Timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	prober.FileRoot = cfg.FileRoot
+	rules, err := parseProbeRules(cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+	p.enabled = cfg.Enabled
+	p.rules = rules
+	return &p, nil
+}
+
+func parseProbeRules(value string) ([]probeRule, error) {
+	var rules []probeRule
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		switch parts[0] {
+		case "presetHeight":
+			rules = append(rules, presetDimensionRule("height", func(source *provider.SourceInfo) int64 {
+				return source.Height
+			}, func(preset *db.Preset) string {
+				return preset.Video.Height
+			}))
+		case "presetWidth":
+			rules = append(rules, presetDimensionRule("width", func(source *provider.SourceInfo) int64 {
+				return source.Width
+			}, func(preset *db.Preset) string {
+				return preset.Video.Width
+			}))
+		case "requireVideo":
+			rules = append(rules, func(source *provider.SourceInfo, job *db.Job) error {
+				if source.VideoCodec == "" {
+					return fmt.Errorf("source media %q has no video", job.SourceMedia)
+				}
+				return nil
+			})
+		case "requireAudio":
+			rules = append(rules, func(source *provider.SourceInfo, job *db.Job) error {
+				if len(source.AudioTracks) == 0 {
+					return fmt.Errorf("source media %q has no audio", job.SourceMedia)
+				}
+				return nil
+			})
+		case "maxDuration":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid probe rule %q: missing the maximum duration", entry)
+			}
+			seconds, err := strconv.ParseUint(parts[1], 10, 32)
+			if err != nil || seconds == 0 {
+				return nil, fmt.Errorf("invalid probe rule %q: invalid maximum duration", entry)
+			}
+			maxDuration := time.Duration(seconds) * time.Second
+			rules = append(rules, func(source *provider.SourceInfo, job *db.Job) error {
+				if source.Duration > maxDuration {
+					return fmt.Errorf("source media %q is longer than %s", job.SourceMedia, maxDuration)
+				}
+				return nil
+			})
+		default:
+			return nil, fmt.Errorf("invalid probe rule %q", entry)
+		}
+	}
+	return rules, nil
+}
+
+// presetDimensionRule rejects jobs with presets that upscale the source media.
+// Presets that don't define the dimension, and sources with unknown
+// dimensions, are accepted.
+func presetDimensionRule(dimension string, sourceValue func(*provider.SourceInfo) int64, presetValue func(*db.Preset) string) probeRule {
+	return func(source *provider.SourceInfo, job *db.Job) error {
+		value := sourceValue(source)
+		if value == 0 {
+			return nil
+		}
+		for _, output := range job.Outputs {
+			if output.Preset.Preset == nil {
+				continue
+			}
+			presetDimension, err := strconv.ParseInt(presetValue(output.Preset.Preset), 10, 64)
+			if err != nil {
+				continue
+			}
+			if value < presetDimension {
+				return fmt.Errorf("source %s (%d) is below the %s of preset %q (%d)", dimension, value, dimension, output.Preset.Name, presetDimension)
+			}
+		}
+		return nil
+	}
+}
+
+// shouldCheck reports whether the source media of jobs should be checked
+// against the rules, given the probe flag of the job, which overrides the
+// default configured in the API.
+func (p *sourceProber) shouldCheck(enabled *bool) bool {
+	if enabled != nil {
+		return *enabled
+	}
+	return p.enabled
+}
+
+// check applies the rules to the probed source media of the job.
+func (p *sourceProber) check(source *provider.SourceInfo, job *db.Job) error {
+	for _, rule := range p.rules {
+		if err := rule(source, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkClips rejects jobs with clips that start or end beyond the end of the
+// probed source media. Sources with unknown duration are accepted.
+func checkClips(source *provider.SourceInfo, job *db.Job) error {
+	if source.Duration == 0 {
+		return nil
+	}
+	clips := []*db.Clip{job.Clip}
+	for _, output := range job.Outputs {
+		clips = append(clips, output.Clip)
+	}
+	for _, clip := range clips {
+		if clip == nil {
+			continue
+		}
+		if clip.StartTime() >= source.Duration {
+			return fmt.Errorf("invalid clip: start %q is beyond the duration of source media %q (%s)", clip.Start, job.SourceMedia, source.Duration)
+		}
+		if clip.EndTime() > source.Duration {
+			return fmt.Errorf("invalid clip: end %q is beyond the duration of source media %q (%s)", clip.End, job.SourceMedia, source.Duration)
+		}
+	}
+	return nil
+}
+
+// probeJobSource probes the source media of the job. Sources that can't be
+// probed, like S3 objects, are skipped, and nil is returned.
+func (s *TranscodingService) probeJobSource(job *db.Job) (*provider.SourceInfo, error) {
+	source, err := s.prober.probe(job.SourceMedia)
+	if err == probe.ErrUnsupportedSource || err == probe.ErrUnsupportedFormat {
+		s.logger.WithError(err).Infof("skipping the inspection of source %q", job.SourceMedia)
+		return nil, nil
+	}
+	if err == probe.ErrSourceNotFound {
+		return nil, fmt.Errorf("source media %q not found", job.SourceMedia)
+	}
+	return source, err
+}
+
+// swagger:route POST /probe probe probeSource
+//
+// Inspects the given source media, returning its duration, its dimensions and
+// the codecs of its streams. Only MP4, MOV and MPEG-TS files, either local or
+// served over HTTP, can be probed. Local files must be within the configured
+// root directory, and HTTP sources can't be served by internal hosts.
+//
+//     Responses:
+//       200: sourceInfo
+//       400: invalidSource
+//       500: genericError
+func (s *TranscodingService) probeSource(r *http.Request) swagger.GizmoJSONResponse {
+	defer r.Body.Close()
+	var input probeSourceInput
+	if err := input.loadParams(r.Body); err != nil {
+		return newInvalidSourceResponse(err)
+	}
+	source, err := s.prober.probe(input.Payload.Source)
+	if err != nil {
+		if isInvalidSource(err) {
+			return newInvalidSourceResponse(err)
+		}
+		return swagger.NewErrorResponse(err)
+	}
+	return newSourceInfoResponse(source)
+}
+
+// isInvalidSource checks whether the given probe error is caused by the
+// source media, rather than by a failure reading it.
+func isInvalidSource(err error) bool {
+	if _, ok := err.(probe.InvalidMediaError); ok {
+		return true
+	}
+	switch err {
+	case probe.ErrUnsupportedSource, probe.ErrUnsupportedFormat, probe.ErrSourceNotFound, probe.ErrSourceUnavailable:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// ProbeSourceInputPayload makes up the parameters available for probing
+// source media.
+type ProbeSourceInputPayload struct {
+	// source media to inspect, either a file:// or an http(s):// URL
+	Source string `json:"source"`
+}
+
+// swagger:parameters probeSource
+type probeSourceInput struct {
+	// in: body
+	// required: true
+	Payload ProbeSourceInputPayload
+}
+
+func (p *probeSourceInput) loadParams(body io.Reader) error {
+	if err := json.NewDecoder(body).Decode(&p.Payload); err != nil {
+		return err
+	}
+	if p.Payload.Source == "" {
+		return errors.New("missing source media from request")
+	}
+	return nil
+}
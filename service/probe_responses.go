@@ -0,0 +1,44 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+// response for the probeSource operation.
+//
+// swagger:response sourceInfo
+type sourceInfoResponse struct {
+	// in: body
+	Payload *provider.SourceInfo
+
+	baseResponse
+}
+
+func newSourceInfoResponse(source *provider.SourceInfo) *sourceInfoResponse {
+	return &sourceInfoResponse{
+		baseResponse: baseResponse{
+			payload: source,
+			status:  http.StatusOK,
+		},
+	}
+}
+
+// error returned when the source media is missing, invalid or can't be
+// probed.
+//
+// swagger:response invalidSource
+type invalidSourceResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+func newInvalidSourceResponse(err error) *invalidSourceResponse {
+	return &invalidSourceResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusBadRequest)}
+}
+
+func (r *invalidSourceResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
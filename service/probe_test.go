@@ -0,0 +1,360 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/NYTimes/video-transcoding-api/probe"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/Sirupsen/logrus"
+)
+
+var testSources = map[string]*provider.SourceInfo{
+	"http://media.example.com/video-1080p.mp4": {
+		Duration:    time.Minute,
+		Width:       1920,
+		Height:      1080,
+		VideoCodec:  "h264",
+		Bitrate:     4500000,
+		AudioTracks: []provider.AudioTrackInfo{{Codec: "aac", Channels: 2, SampleRate: 48000, Language: "eng"}},
+	},
+	"http://media.example.com/video-360p.ts": {
+		Duration:   2 * time.Hour,
+		Width:      640,
+		Height:     360,
+		VideoCodec: "h264",
+	},
+}
+
+func fakeProbe(source string) (*provider.SourceInfo, error) {
+	switch source {
+	case "s3://some-bucket/video.mp4":
+		return nil, probe.ErrUnsupportedSource
+	case "http://media.example.com/missing.mp4":
+		return nil, probe.ErrSourceNotFound
+	case "http://media.example.com/corrupt.mp4":
+		return nil, probe.InvalidMediaError{Format: "MP4", Message: "missing moov box"}
+	case "http://unreachable.example.com/video.mp4":
+		return nil, probe.ErrSourceUnavailable
+	case "http://failing.example.com/video.mp4":
+		return nil, errors.New("read: connection reset by peer")
+	}
+	return testSources[source], nil
+}
+
+func TestProbeSource(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenSource   string
+
+		wantCode   int
+		wantError  string
+		wantSource *provider.SourceInfo
+	}{
+		{
+			"MP4 file",
+			"http://media.example.com/video-1080p.mp4",
+			http.StatusOK,
+			"",
+			testSources["http://media.example.com/video-1080p.mp4"],
+		},
+		{
+			"missing source",
+			"",
+			http.StatusBadRequest,
+			"missing source media from request",
+			nil,
+		},
+		{
+			"source not found",
+			"http://media.example.com/missing.mp4",
+			http.StatusBadRequest,
+			"source not found",
+			nil,
+		},
+		{
+			"unsupported source",
+			"s3://some-bucket/video.mp4",
+			http.StatusBadRequest,
+			probe.ErrUnsupportedSource.Error(),
+			nil,
+		},
+		{
+			"corrupt source",
+			"http://media.example.com/corrupt.mp4",
+			http.StatusBadRequest,
+			"invalid MP4 file: missing moov box",
+			nil,
+		},
+		{
+			"unreachable source",
+			"http://unreachable.example.com/video.mp4",
+			http.StatusBadRequest,
+			"unable to read source",
+			nil,
+		},
+		{
+			"failure reading the source",
+			"http://failing.example.com/video.mp4",
+			http.StatusInternalServerError,
+			"read: connection reset by peer",
+			nil,
+		},
+	}
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.prober.probe = fakeProbe
+		srvr.Register(service)
+		body, _ := json.Marshal(map[string]string{"source": test.givenSource})
+		r, _ := http.NewRequest("POST", "/probe", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		if test.wantCode != http.StatusOK {
+			var got map[string]interface{}
+			if err = json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+			}
+			if got["error"] != test.wantError {
+				t.Errorf("%s: wrong error. Want %q. Got %q", test.givenTestCase, test.wantError, got["error"])
+			}
+			continue
+		}
+		var got provider.SourceInfo
+		if err = json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(&got, test.wantSource) {
+			t.Errorf("%s: wrong source info.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantSource, got)
+		}
+	}
+}
+
+func TestTranscodePreflight(t *testing.T) {
+	enabled, disabled := true, false
+	var tests = []struct {
+		givenTestCase string
+		givenConfig   *config.Probe
+		givenSource   string
+		givenPreset   string
+		givenProbe    *bool
+
+		wantCode  int
+		wantError string
+	}{
+		{
+			"source satisfying the rules",
+			&config.Probe{Enabled: true, Rules: "presetHeight,presetWidth,requireAudio,maxDuration:3600"},
+			"http://media.example.com/video-1080p.mp4",
+			"mp4_1080p",
+			nil,
+			http.StatusOK,
+			"",
+		},
+		{
+			"source below the preset height",
+			&config.Probe{Enabled: true, Rules: "presetHeight"},
+			"http://media.example.com/video-360p.ts",
+			"mp4_1080p",
+			nil,
+			http.StatusBadRequest,
+			`source height (360) is below the height of preset "mp4_1080p" (1080)`,
+		},
+		{
+			"source below the preset width",
+			&config.Probe{Enabled: true, Rules: "presetWidth"},
+			"http://media.example.com/video-360p.ts",
+			"mp4_1080p",
+			nil,
+			http.StatusBadRequest,
+			`source width (640) is below the width of preset "mp4_1080p" (1920)`,
+		},
+		{
+			"preset without dimensions",
+			&config.Probe{Enabled: true, Rules: "presetHeight,presetWidth"},
+			"http://media.example.com/video-360p.ts",
+			"mp4_source",
+			nil,
+			http.StatusOK,
+			"",
+		},
+		{
+			"source without audio",
+			&config.Probe{Enabled: true, Rules: "requireVideo,requireAudio"},
+			"http://media.example.com/video-360p.ts",
+			"mp4_source",
+			nil,
+			http.StatusBadRequest,
+			`source media "http://media.example.com/video-360p.ts" has no audio`,
+		},
+		{
+			"source too long",
+			&config.Probe{Enabled: true, Rules: "maxDuration:3600"},
+			"http://media.example.com/video-360p.ts",
+			"mp4_source",
+			nil,
+			http.StatusBadRequest,
+			`source media "http://media.example.com/video-360p.ts" is longer than 1h0m0s`,
+		},
+		{
+			"source not found",
+			&config.Probe{Enabled: true},
+			"http://media.example.com/missing.mp4",
+			"mp4_1080p",
+			nil,
+			http.StatusBadRequest,
+			`source media "http://media.example.com/missing.mp4" not found`,
+		},
+		{
+			"corrupt source",
+			&config.Probe{Enabled: true},
+			"http://media.example.com/corrupt.mp4",
+			"mp4_1080p",
+			nil,
+			http.StatusBadRequest,
+			"invalid MP4 file: missing moov box",
+		},
+		{
+			"source that can't be probed",
+			&config.Probe{Enabled: true, Rules: "presetHeight"},
+			"s3://some-bucket/video.mp4",
+			"mp4_1080p",
+			nil,
+			http.StatusOK,
+			"",
+		},
+		{
+			"probing disabled in the job",
+			&config.Probe{Enabled: true, Rules: "presetHeight"},
+			"http://media.example.com/video-360p.ts",
+			"mp4_1080p",
+			&disabled,
+			http.StatusOK,
+			"",
+		},
+		{
+			"probing enabled in the job",
+			&config.Probe{Rules: "presetHeight"},
+			"http://media.example.com/video-360p.ts",
+			"mp4_1080p",
+			&enabled,
+			http.StatusBadRequest,
+			`source height (360) is below the height of preset "mp4_1080p" (1080)`,
+		},
+		{
+			"probing disabled",
+			&config.Probe{Rules: "presetHeight"},
+			"http://media.example.com/video-360p.ts",
+			"mp4_1080p",
+			nil,
+			http.StatusOK,
+			"",
+		},
+	}
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDBObj := dbtest.NewFakeRepository(false)
+		fakeDBObj.CreatePresetMap(&db.PresetMap{
+			Name:            "mp4_1080p",
+			ProviderMapping: map[string]string{"fake": "18828"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			Preset: &db.Preset{
+				Name:      "mp4_1080p",
+				Container: "mp4",
+				Video:     db.VideoPreset{Codec: "h264", Width: "1920", Height: "1080"},
+			},
+		})
+		fakeDBObj.CreatePresetMap(&db.PresetMap{
+			Name:            "mp4_source",
+			ProviderMapping: map[string]string{"fake": "18829"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			Preset: &db.Preset{
+				Name:      "mp4_source",
+				Container: "mp4",
+				Video:     db.VideoPreset{Codec: "h264"},
+			},
+		})
+		service, err := NewTranscodingService(&config.Config{Probe: test.givenConfig}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDBObj
+		service.prober.probe = fakeProbe
+		srvr.Register(service)
+		payload := map[string]interface{}{
+			"source":   test.givenSource,
+			"outputs":  []map[string]string{{"preset": test.givenPreset}},
+			"provider": "fake",
+		}
+		if test.givenProbe != nil {
+			payload["probe"] = *test.givenProbe
+		}
+		body, _ := json.Marshal(payload)
+		r, _ := http.NewRequest("POST", "/jobs", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		if err = json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if test.wantCode != http.StatusOK && got["error"] != test.wantError {
+			t.Errorf("%s: wrong error. Want %q. Got %q", test.givenTestCase, test.wantError, got["error"])
+		}
+	}
+}
+
+func TestNewTranscodingServiceInvalidProbeRules(t *testing.T) {
+	var tests = []struct {
+		givenRules string
+		wantErr    string
+	}{
+		{"presetHeight,minBitrate", `invalid probe rule "minBitrate"`},
+		{"maxDuration", `invalid probe rule "maxDuration": missing the maximum duration`},
+		{"maxDuration:1h", `invalid probe rule "maxDuration:1h": invalid maximum duration`},
+		{"maxDuration:0", `invalid probe rule "maxDuration:0": invalid maximum duration`},
+	}
+	for _, test := range tests {
+		_, err := NewTranscodingService(&config.Config{Probe: &config.Probe{Rules: test.givenRules}}, logrus.New())
+		if err == nil || err.Error() != test.wantErr {
+			t.Errorf("%s: wrong error returned. Want %q. Got %v", test.givenRules, test.wantErr, err)
+		}
+	}
+}
+
+func TestSourceProberRefusesInternalHosts(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	prober, err := newSourceProber(&config.Probe{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = prober.probe(server.URL + "/video.mp4")
+	if err != probe.ErrSourceUnavailable {
+		t.Errorf("wrong error returned. Want %#v. Got %#v", probe.ErrSourceUnavailable, err)
+	}
+	if requests != 0 {
+		t.Errorf("unexpected requests to the internal host: %d", requests)
+	}
+}
@@ -1,9 +1,12 @@
 package service
 
 import (
+	"fmt"
 	"net/http"
+	"reflect"
 
 	"github.com/NYTimes/gizmo/web"
+	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/provider"
 	"github.com/NYTimes/video-transcoding-api/swagger"
 )
@@ -42,3 +45,112 @@ func (s *TranscodingService) getProvider(r *http.Request) swagger.GizmoJSONRespo
 		return swagger.NewErrorResponse(err)
 	}
 }
+
+// swagger:route POST /notifications/{provider} providers receiveProviderNotification
+//
+// Receives notifications pushed by providers about the status of jobs, in
+// the native format of the provider, and updates the stored status of the
+// job. Notifications about jobs that aren't stored yet, as providers may
+// push them before the submission of the job returns, are refused as
+// unavailable, so providers retry them.
+//
+//     Responses:
+//       200: providerNotification
+//       400: invalidProviderNotification
+//       401: unauthorizedProviderNotification
+//       404: providerNotFound
+//       500: genericError
+//       503: unknownProviderJob
+func (s *TranscodingService) receiveProviderNotification(r *http.Request) swagger.GizmoJSONResponse {
+	var params providerNotificationInput
+	params.loadParams(web.Vars(r))
+	factory, err := provider.GetProviderFactory(params.Provider)
+	if err != nil {
+		return newProviderNotFoundResponse(err)
+	}
+	providerObj, err := factory(s.config)
+	if err != nil {
+		return swagger.NewErrorResponse(fmt.Errorf("error initializing provider %q: %s", params.Provider, err))
+	}
+	handler, ok := providerObj.(provider.NotificationHandler)
+	if !ok {
+		return newProviderNotFoundResponse(fmt.Errorf("provider %q doesn't support notifications", params.Provider))
+	}
+	status, err := handler.HandleNotification(r)
+	if err != nil {
+		if err == provider.ErrNotificationUnauthorized {
+			return newUnauthorizedProviderNotificationResponse(err)
+		}
+		if _, ok := err.(provider.InvalidNotificationError); ok {
+			return newInvalidProviderNotificationResponse(err)
+		}
+		return swagger.NewErrorResponse(err)
+	}
+	if status == nil {
+		return newProviderNotificationResponse(nil)
+	}
+	job, err := s.db.GetJobByProviderJobID(params.Provider, status.ProviderJobID)
+	if err != nil {
+		if err == db.ErrJobNotFound {
+			return newUnknownProviderJobResponse(fmt.Errorf("unknown job %q of provider %q", status.ProviderJobID, params.Provider))
+		}
+		return swagger.NewErrorResponse(err)
+	}
+	logger := s.logger.WithField("jobId", job.ID)
+	lastStatus := s.lastJobStatus(job)
+	if status.Status == "" || status.Status == provider.StatusUnknown || (isDone(job.Status) && !isDone(string(status.Status))) {
+		logger.Warnf("ignoring notification from %s with status %q", params.Provider, status.Status)
+		return newProviderNotificationResponse(lastStatus)
+	}
+	status = mergeJobStatus(lastStatus, status)
+	status.ProviderName = job.ProviderName
+	if isDone(string(status.Status)) && len(status.Output.Files) == 0 {
+		// some providers don't include the output files in their
+		// notifications, so we query them once the job is done.
+		fullStatus, err := providerObj.JobStatus(job)
+		if err != nil {
+			logger.WithError(err).Warn("failed to retrieve the full status of the job after a notification")
+		} else {
+			status = fullStatus
+			status.ProviderName = job.ProviderName
+		}
+	}
+	s.updateJobStatus(job, status)
+	return newProviderNotificationResponse(status)
+}
+
+// mergeJobStatus fills the fields that are missing in a status pushed by the
+// provider with the last known status of the job.
+func mergeJobStatus(last, pushed *provider.JobStatus) *provider.JobStatus {
+	if last == nil {
+		return pushed
+	}
+	merged := *last
+	merged.ProviderJobID = pushed.ProviderJobID
+	merged.Status = pushed.Status
+	if pushed.StatusMessage != "" {
+		merged.StatusMessage = pushed.StatusMessage
+	}
+	if pushed.Progress > 0 {
+		merged.Progress = pushed.Progress
+	}
+	if len(pushed.ProviderStatus) > 0 {
+		merged.ProviderStatus = make(map[string]interface{}, len(last.ProviderStatus)+len(pushed.ProviderStatus))
+		for k, v := range last.ProviderStatus {
+			merged.ProviderStatus[k] = v
+		}
+		for k, v := range pushed.ProviderStatus {
+			merged.ProviderStatus[k] = v
+		}
+	}
+	if pushed.Output.Destination != "" {
+		merged.Output.Destination = pushed.Output.Destination
+	}
+	if len(pushed.Output.Files) > 0 {
+		merged.Output.Files = pushed.Output.Files
+	}
+	if !reflect.DeepEqual(pushed.SourceInfo, provider.SourceInfo{}) {
+		merged.SourceInfo = pushed.SourceInfo
+	}
+	return &merged
+}
@@ -10,3 +10,16 @@ type getProviderInput struct {
 func (p *getProviderInput) loadParams(paramsMap map[string]string) {
 	p.Name = paramsMap["name"]
 }
+
+// swagger:parameters receiveProviderNotification
+type providerNotificationInput struct {
+	// name of the provider sending the notification
+	//
+	// in: path
+	// required: true
+	Provider string `json:"provider"`
+}
+
+func (p *providerNotificationInput) loadParams(paramsMap map[string]string) {
+	p.Provider = paramsMap["provider"]
+}
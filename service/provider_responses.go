@@ -55,3 +55,73 @@ func newProviderNotFoundResponse(err error) *providerNotFoundResponse {
 func (r *providerNotFoundResponse) Result() (int, interface{}, error) {
 	return r.Error.Result()
 }
+
+// response for the receiveProviderNotification operation. Contains the
+// updated status of the job, or null for notifications that don't carry the
+// status of a job.
+//
+// swagger:response providerNotification
+type providerNotificationResponse struct {
+	// in: body
+	JobStatus *provider.JobStatus
+
+	baseResponse
+}
+
+func newProviderNotificationResponse(status *provider.JobStatus) *providerNotificationResponse {
+	return &providerNotificationResponse{
+		baseResponse: baseResponse{payload: status, status: http.StatusOK},
+	}
+}
+
+// error returned when the notification can't be parsed by the provider.
+//
+// swagger:response invalidProviderNotification
+type invalidProviderNotificationResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+func newInvalidProviderNotificationResponse(err error) *invalidProviderNotificationResponse {
+	return &invalidProviderNotificationResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusBadRequest)}
+}
+
+func (r *invalidProviderNotificationResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
+
+// error returned when the notification can't be authenticated by the
+// provider.
+//
+// swagger:response unauthorizedProviderNotification
+type unauthorizedProviderNotificationResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+func newUnauthorizedProviderNotificationResponse(err error) *unauthorizedProviderNotificationResponse {
+	return &unauthorizedProviderNotificationResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusUnauthorized)}
+}
+
+func (r *unauthorizedProviderNotificationResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
+
+// error returned when the notification refers to a job that isn't stored in
+// the API, which happens when the provider pushes a notification before the
+// job is stored after being submitted. The service is reported as
+// unavailable so providers retry the delivery of the notification.
+//
+// swagger:response unknownProviderJob
+type unknownProviderJobResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+func newUnknownProviderJobResponse(err error) *unknownProviderJobResponse {
+	return &unknownProviderJobResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusServiceUnavailable)}
+}
+
+func (r *unknownProviderJobResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
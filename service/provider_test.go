@@ -5,10 +5,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/NYTimes/gizmo/server"
 	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/NYTimes/video-transcoding-api/provider"
 	"github.com/Sirupsen/logrus"
 )
 
@@ -55,6 +59,12 @@ func TestGetProvider(t *testing.T) {
 					"input":        []interface{}{"prores", "h264"},
 					"output":       []interface{}{"mp4", "webm", "hls"},
 					"destinations": []interface{}{"akamai", "s3"},
+					"thumbnails": map[string]interface{}{
+						"formats":   []interface{}{"jpg"},
+						"timecodes": false,
+						"sprites":   false,
+					},
+					"clipping": map[string]interface{}{"perOutput": false},
 				},
 				"enabled": true,
 			},
@@ -89,3 +99,181 @@ func TestGetProvider(t *testing.T) {
 		}
 	}
 }
+
+func TestReceiveProviderNotification(t *testing.T) {
+	var tests = []struct {
+		testCase   string
+		uri        string
+		body       string
+		lastStatus string
+		jobStatus  string
+
+		expectedStatus        int
+		expectedJobStatus     string
+		expectedStatusMessage string
+	}{
+		{
+			"job started",
+			"/notifications/fake?token=fake-token",
+			`{"providerJobId":"provider-job-123","status":"started","progress":30}`,
+			`{"providerJobId":"provider-job-123","status":"queued","statusMessage":"created"}`,
+			"queued",
+			http.StatusOK,
+			"started",
+			"created",
+		},
+		{
+			"job finished without output files",
+			"/notifications/fake?token=fake-token",
+			`{"providerJobId":"provider-job-123","status":"finished"}`,
+			`{"providerJobId":"provider-job-123","status":"started"}`,
+			"started",
+			http.StatusOK,
+			"finished",
+			"The job is finished",
+		},
+		{
+			"out of order notification",
+			"/notifications/fake?token=fake-token",
+			`{"providerJobId":"provider-job-123","status":"started"}`,
+			`{"providerJobId":"provider-job-123","status":"finished","statusMessage":"done"}`,
+			"finished",
+			http.StatusOK,
+			"finished",
+			"done",
+		},
+		{
+			"notification without job status",
+			"/notifications/fake?token=fake-token",
+			`{}`,
+			"",
+			"queued",
+			http.StatusOK,
+			"queued",
+			"",
+		},
+		{
+			"unauthorized notification",
+			"/notifications/fake?token=wrong-token",
+			`{"providerJobId":"provider-job-123","status":"finished"}`,
+			"",
+			"queued",
+			http.StatusUnauthorized,
+			"queued",
+			"",
+		},
+		{
+			"invalid notification",
+			"/notifications/fake?token=fake-token",
+			`{"providerJobId":`,
+			"",
+			"queued",
+			http.StatusBadRequest,
+			"queued",
+			"",
+		},
+		{
+			"unknown job",
+			"/notifications/fake?token=fake-token",
+			`{"providerJobId":"provider-job-456","status":"finished"}`,
+			"",
+			"queued",
+			http.StatusServiceUnavailable,
+			"queued",
+			"",
+		},
+		{
+			"unknown provider",
+			"/notifications/whatever?token=fake-token",
+			`{"providerJobId":"provider-job-123","status":"finished"}`,
+			"",
+			"queued",
+			http.StatusNotFound,
+			"queued",
+			"",
+		},
+	}
+	for _, test := range tests {
+		fprovider.canceledJobs = nil
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDBObj := dbtest.NewFakeRepository(false)
+		fakeDBObj.CreateJob(&db.Job{
+			ID:            "job-123",
+			ProviderName:  "fake",
+			ProviderJobID: "provider-job-123",
+			Status:        test.jobStatus,
+			LastStatus:    test.lastStatus,
+		})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDBObj
+		service.notifier.db = fakeDBObj
+		srvr.Register(service)
+		r, _ := http.NewRequest("POST", test.uri, strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.expectedStatus {
+			t.Errorf("%s: wrong status code. Want %d. Got %d", test.testCase, test.expectedStatus, w.Code)
+		}
+		job, err := fakeDBObj.GetJob("job-123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != test.expectedJobStatus {
+			t.Errorf("%s: wrong job status. Want %q. Got %q", test.testCase, test.expectedJobStatus, job.Status)
+		}
+		if test.expectedStatusMessage != "" {
+			status := service.lastJobStatus(job)
+			if status == nil {
+				t.Errorf("%s: last status of the job wasn't stored", test.testCase)
+			} else if status.StatusMessage != test.expectedStatusMessage {
+				t.Errorf("%s: wrong status message. Want %q. Got %q", test.testCase, test.expectedStatusMessage, status.StatusMessage)
+			}
+		}
+	}
+}
+
+func TestMergeJobStatus(t *testing.T) {
+	last := provider.JobStatus{
+		ProviderJobID:  "123",
+		Status:         provider.StatusStarted,
+		StatusMessage:  "processing",
+		Progress:       50,
+		ProviderStatus: map[string]interface{}{"sourcefile": "s3://bucket/video.mov"},
+		Output:         provider.JobOutput{Destination: "s3://bucket/job-123/"},
+		SourceInfo:     provider.SourceInfo{Width: 1920, Height: 1080},
+	}
+	pushed := provider.JobStatus{
+		ProviderJobID:  "123",
+		Status:         provider.StatusFinished,
+		Progress:       100,
+		ProviderStatus: map[string]interface{}{"finished": "2017-03-21T18:44:05Z"},
+		Output: provider.JobOutput{
+			Files: []provider.OutputFile{{Path: "s3://bucket/job-123/video.mp4", Container: "mp4"}},
+		},
+	}
+	expected := provider.JobStatus{
+		ProviderJobID: "123",
+		Status:        provider.StatusFinished,
+		StatusMessage: "processing",
+		Progress:      100,
+		ProviderStatus: map[string]interface{}{
+			"sourcefile": "s3://bucket/video.mov",
+			"finished":   "2017-03-21T18:44:05Z",
+		},
+		Output: provider.JobOutput{
+			Destination: "s3://bucket/job-123/",
+			Files:       []provider.OutputFile{{Path: "s3://bucket/job-123/video.mp4", Container: "mp4"}},
+		},
+		SourceInfo: provider.SourceInfo{Width: 1920, Height: 1080},
+	}
+	got := mergeJobStatus(&last, &pushed)
+	if !reflect.DeepEqual(*got, expected) {
+		t.Errorf("wrong merged status\nwant %#v\ngot  %#v", expected, *got)
+	}
+	if got := mergeJobStatus(nil, &pushed); got != &pushed {
+		t.Errorf("wrong merged status without last status. Want %#v. Got %#v", &pushed, got)
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+)
+
+const (
+	defaultPresetReconcilerInterval = time.Hour
+
+	// author of the versions of presets repaired by the reconciler.
+	presetReconcilerAuthor = "preset-reconciler"
+)
+
+// presetReconciler periodically checks whether presets drifted in providers,
+// optionally repairing them.
+type presetReconciler struct {
+	service  *TranscodingService
+	interval time.Duration
+	repair   bool
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newPresetReconciler(s *TranscodingService, cfg *config.PresetReconciler) *presetReconciler {
+	r := presetReconciler{
+		service:  s,
+		interval: defaultPresetReconcilerInterval,
+		repair:   cfg.Repair,
+	}
+	if cfg.Interval > 0 {
+		r.interval = time.Duration(cfg.Interval) * time.Minute
+	}
+	return &r
+}
+
+func (r *presetReconciler) start() {
+	r.done = make(chan struct{})
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.reconcile()
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+func (r *presetReconciler) stop() {
+	close(r.done)
+	r.wg.Wait()
+}
+
+// reconcile checks the drift of all presets with a known definition, logging
+// the presets that drifted and repairing them when configured to.
+func (r *presetReconciler) reconcile() {
+	logger := r.service.logger
+	presetMaps, err := r.service.db.ListPresetMaps()
+	if err != nil {
+		logger.WithError(err).Error("failed to list presetmaps for detecting drift")
+		return
+	}
+	for i := range presetMaps {
+		presetMap := &presetMaps[i]
+		if presetMap.Preset == nil {
+			continue
+		}
+		drift, err := r.service.detectDrift(presetMap)
+		if err != nil {
+			logger.WithError(err).Errorf("failed to detect drift of preset %q", presetMap.Name)
+			continue
+		}
+		for name, result := range drift.Providers {
+			if result.Error != "" {
+				logger.WithField("provider", name).Errorf("failed to detect drift of preset %q: %s", presetMap.Name, result.Error)
+			} else if result.Drifted {
+				logger.WithField("provider", name).WithField("differences", result.Differences).Warnf("preset %q drifted", presetMap.Name)
+			}
+		}
+		if !r.repair || !drift.Drifted {
+			continue
+		}
+		if err = r.service.repairDrift(presetMap, drift, presetReconcilerAuthor); err != nil {
+			logger.WithError(err).Errorf("failed to repair drift of preset %q", presetMap.Name)
+		}
+	}
+}
+
+// StartPresetReconciler starts the background worker that detects presets
+// that drifted in providers, if it's enabled in the configuration.
+func (s *TranscodingService) StartPresetReconciler() error {
+	if s.config.PresetReconciler == nil || !s.config.PresetReconciler.Enabled {
+		return nil
+	}
+	s.reconciler = newPresetReconciler(s, s.config.PresetReconciler)
+	s.reconciler.start()
+	return nil
+}
+
+// StopPresetReconciler stops the background worker that detects drifted
+// presets, waiting for the current run to finish.
+func (s *TranscodingService) StopPresetReconciler() {
+	if s.reconciler != nil {
+		s.reconciler.stop()
+		s.reconciler = nil
+	}
+}
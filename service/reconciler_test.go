@@ -0,0 +1,80 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/Sirupsen/logrus"
+)
+
+func TestPresetReconcilerReconcile(t *testing.T) {
+	preset := db.Preset{Name: "preset-1", Container: "mp4"}
+	var tests = []struct {
+		givenTestCase string
+		givenRepair   bool
+		wantMappings  map[string]map[string]string
+		wantDeleted   []string
+	}{
+		{
+			"detect only",
+			false,
+			map[string]map[string]string{
+				"preset-1": {"fake": "drifted"},
+				"preset-2": {"fake": "drifted"},
+				"preset-3": {"fake": "preset-id"},
+			},
+			nil,
+		},
+		{
+			"repair",
+			true,
+			map[string]map[string]string{
+				"preset-1": {"fake": "presetID_here"},
+				"preset-2": {"fake": "drifted"},
+				"preset-3": {"fake": "preset-id"},
+			},
+			[]string{"drifted"},
+		},
+	}
+	for _, test := range tests {
+		fprovider.deletedPresets = nil
+		fakeDB := dbtest.NewFakeRepository(false)
+		presetMaps := []db.PresetMap{
+			{Name: "preset-1", ProviderMapping: map[string]string{"fake": "drifted"}, Preset: &preset},
+			{Name: "preset-2", ProviderMapping: map[string]string{"fake": "drifted"}},
+			{Name: "preset-3", ProviderMapping: map[string]string{"fake": "preset-id"}, Preset: &preset},
+		}
+		for i := range presetMaps {
+			fakeDB.CreatePresetMap(&presetMaps[i])
+		}
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		reconciler := newPresetReconciler(service, &config.PresetReconciler{Enabled: true, Repair: test.givenRepair})
+		reconciler.reconcile()
+		for name, wantMapping := range test.wantMappings {
+			presetMap, err := fakeDB.GetPresetMap(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(presetMap.ProviderMapping, wantMapping) {
+				t.Errorf("%s: wrong provider mapping for %q.\nWant %#v\nGot  %#v", test.givenTestCase, name, wantMapping, presetMap.ProviderMapping)
+			}
+		}
+		if !reflect.DeepEqual(fprovider.deletedPresets, test.wantDeleted) {
+			t.Errorf("%s: wrong deleted presets.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantDeleted, fprovider.deletedPresets)
+		}
+	}
+}
+
+func TestNewPresetReconcilerDefaultInterval(t *testing.T) {
+	reconciler := newPresetReconciler(nil, &config.PresetReconciler{})
+	if reconciler.interval != defaultPresetReconcilerInterval {
+		t.Errorf("wrong interval. Want %s. Got %s", defaultPresetReconcilerInterval, reconciler.interval)
+	}
+}
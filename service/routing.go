@@ -0,0 +1,481 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+var errMissingProvider = errors.New("missing provider from request")
+
+// routingRule defines the providers that may be used for jobs that match
+// all of its conditions. Empty conditions match any job.
+type routingRule struct {
+	// name of the rule, used for explaining routing decisions
+	Name string `json:"name"`
+
+	// schemes of the source media, like s3, gs or http
+	SourceSchemes []string `json:"sourceSchemes,omitempty"`
+
+	// buckets of the source media
+	SourceBuckets []string `json:"sourceBuckets,omitempty"`
+
+	// output formats (mp4, webm, hls, ...). The rule matches only if all
+	// outputs of the job use one of the given formats
+	OutputFormats []string `json:"outputFormats,omitempty"`
+
+	// streaming protocols (hls, dash). Jobs that aren't adaptive
+	// streaming jobs don't match rules that define streaming protocols
+	StreamingProtocols []string `json:"streamingProtocols,omitempty"`
+
+	// providers used by the rule, with the weight used for splitting
+	// traffic between them
+	Providers []routingTarget `json:"providers"`
+}
+
+type routingTarget struct {
+	Name string `json:"name"`
+
+	// weight of the provider, defaults to 1. Providers with a weight of
+	// 0 are never picked by weight, and are only used as fallbacks, after
+	// the providers with a positive weight
+	Weight *uint `json:"weight,omitempty"`
+}
+
+// routingRequest contains the attributes of a job considered when routing it
+// to a provider.
+type routingRequest struct {
+	source            string
+	outputFormats     []string
+	streamingProtocol string
+
+	// type of the destination of the job (s3, gcs, ...), empty when the
+	// job uses the destination configured in the provider
+	destinationType string
+
+	// thumbnails generated by the job, nil when the job doesn't generate
+	// thumbnails
+	thumbnails *db.Thumbnails
+
+	// how the job clips the source media (db.ClippingJob or
+	// db.ClippingOutputs), empty when the job uses the whole source
+	clipping string
+}
+
+func newRoutingRequest(job *db.Job) routingRequest {
+	req := routingRequest{
+		source:            job.SourceMedia,
+		streamingProtocol: job.StreamingParams.Protocol,
+		thumbnails:        job.Thumbnails,
+		clipping:          job.Clipping(),
+	}
+	if job.Destination != "" {
+		req.destinationType, _ = provider.DestinationType(job.Destination)
+	}
+	for _, output := range job.Outputs {
+		req.outputFormats = appendUnique(req.outputFormats, outputFormat(&output.Preset))
+	}
+	return req
+}
+
+// outputFormat returns the format of outputs using the given preset, as
+// described in provider.Capabilities.
+func outputFormat(preset *db.PresetMap) string {
+	switch preset.OutputOpts.Extension {
+	case "m3u8":
+		return "hls"
+	case "mpd":
+		return "dash"
+	default:
+		return preset.OutputOpts.Extension
+	}
+}
+
+// RoutingExplanation describes how the API chooses the provider of a job that
+// doesn't specify one.
+//
+// swagger:model
+type RoutingExplanation struct {
+	// source media considered
+	Source string `json:"source"`
+
+	// output formats considered
+	OutputFormats []string `json:"outputFormats"`
+
+	// streaming protocol considered
+	StreamingProtocol string `json:"streamingProtocol,omitempty"`
+
+	// type of the destination considered
+	DestinationType string `json:"destinationType,omitempty"`
+
+	// thumbnails considered
+	Thumbnails *db.Thumbnails `json:"thumbnails,omitempty"`
+
+	// clipping considered (job or outputs)
+	Clipping string `json:"clipping,omitempty"`
+
+	// evaluation of the routing rules, in order, up to the rule that
+	// matched the job
+	Rules []RoutingRuleEvaluation `json:"rules"`
+
+	// name of the rule that matched the job, empty when no rule matches
+	MatchedRule string `json:"matchedRule,omitempty"`
+
+	// providers of the matched rule
+	Providers []RoutingProvider `json:"providers,omitempty"`
+}
+
+// RoutingRuleEvaluation describes whether a routing rule matched a job.
+type RoutingRuleEvaluation struct {
+	// name of the rule
+	Name string `json:"name"`
+
+	// whether the rule matched the job
+	Matched bool `json:"matched"`
+
+	// reason for the rule not matching the job
+	Reason string `json:"reason,omitempty"`
+}
+
+// RoutingProvider describes a provider of a routing rule.
+type RoutingProvider struct {
+	// name of the provider
+	Name string `json:"name"`
+
+	// weight of the provider in the rule, 0 for providers that are only
+	// used as fallbacks
+	Weight uint `json:"weight"`
+
+	// whether the provider can be used for the job
+	Eligible bool `json:"eligible"`
+
+	// reason for the provider not being eligible
+	Reason string `json:"reason,omitempty"`
+}
+
+type router struct {
+	rules    []routingRule
+	describe func(providerName string) (*provider.Description, error)
+
+	mtx  sync.Mutex
+	rand *rand.Rand
+}
+
+// newRouter creates a router using the rules defined in the given
+// configuration.
+func newRouter(cfg *config.Config) (*router, error) {
+	r := router{
+		describe: func(providerName string) (*provider.Description, error) {
+			return describeCapabilities(providerName, cfg)
+		},
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if cfg.Routing == nil || strings.TrimSpace(cfg.Routing.Rules) == "" {
+		return &r, nil
+	}
+	err := json.Unmarshal([]byte(cfg.Routing.Rules), &r.rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid routing rules: %s", err)
+	}
+	for i, rule := range r.rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("invalid routing rules: missing name in rule %d", i)
+		}
+		if len(rule.Providers) == 0 {
+			return nil, fmt.Errorf("invalid routing rules: missing providers in rule %q", rule.Name)
+		}
+		for _, target := range rule.Providers {
+			if _, err = provider.GetProviderFactory(target.Name); err != nil {
+				return nil, fmt.Errorf("invalid routing rules: %s: %s", err, target.Name)
+			}
+		}
+	}
+	return &r, nil
+}
+
+// describeCapabilities describes the given provider without running its
+// healthcheck, as routing only depends on the capabilities of the provider.
+func describeCapabilities(providerName string, cfg *config.Config) (*provider.Description, error) {
+	providerFactory, err := provider.GetProviderFactory(providerName)
+	if err != nil {
+		return nil, err
+	}
+	description := provider.Description{Name: providerName}
+	providerObj, err := providerFactory(cfg)
+	if err != nil {
+		return &description, nil
+	}
+	description.Enabled = true
+	description.Capabilities = providerObj.Capabilities()
+	return &description, nil
+}
+
+// route returns the ordered list of providers that the job described by the
+// given request should be submitted to. The first provider is chosen
+// randomly, according to the weights of the eligible providers in the
+// matched rule, and the other eligible providers follow as fallbacks.
+// Providers with a weight of 0 come last, in the order of the rule.
+func (r *router) route(req routingRequest) ([]string, error) {
+	if len(r.rules) == 0 {
+		return nil, errMissingProvider
+	}
+	explanation := r.explain(req)
+	if explanation.MatchedRule == "" {
+		return nil, errors.New("no routing rule matches the job")
+	}
+	var candidates, standby []RoutingProvider
+	for _, p := range explanation.Providers {
+		switch {
+		case !p.Eligible:
+		case p.Weight == 0:
+			standby = append(standby, p)
+		default:
+			candidates = append(candidates, p)
+		}
+	}
+	providerNames := make([]string, 0, len(candidates)+len(standby))
+	for len(candidates) > 0 {
+		i := r.pick(candidates)
+		providerNames = append(providerNames, candidates[i].Name)
+		candidates = append(candidates[:i], candidates[i+1:]...)
+	}
+	for _, p := range standby {
+		providerNames = append(providerNames, p.Name)
+	}
+	return providerNames, nil
+}
+
+// pick chooses one of the given providers randomly, according to their
+// weights, returning its index. All weights must be positive.
+func (r *router) pick(candidates []RoutingProvider) int {
+	var total uint
+	for _, c := range candidates {
+		total += c.Weight
+	}
+	r.mtx.Lock()
+	n := uint(r.rand.Int63n(int64(total)))
+	r.mtx.Unlock()
+	for i, c := range candidates {
+		if n < c.Weight {
+			return i
+		}
+		n -= c.Weight
+	}
+	return len(candidates) - 1
+}
+
+// explain evaluates the routing rules against the given request, stopping at
+// the first rule that matches it.
+func (r *router) explain(req routingRequest) *RoutingExplanation {
+	explanation := RoutingExplanation{
+		Source:            req.source,
+		OutputFormats:     req.outputFormats,
+		StreamingProtocol: req.streamingProtocol,
+		DestinationType:   req.destinationType,
+		Thumbnails:        req.thumbnails,
+		Clipping:          req.clipping,
+		Rules:             []RoutingRuleEvaluation{},
+	}
+	if explanation.OutputFormats == nil {
+		explanation.OutputFormats = []string{}
+	}
+	scheme, bucket := sourceLocation(req.source)
+	for _, rule := range r.rules {
+		evaluation := RoutingRuleEvaluation{Name: rule.Name}
+		evaluation.Reason = rule.mismatch(req, scheme, bucket)
+		var providers []RoutingProvider
+		if evaluation.Reason == "" {
+			providers = r.evaluateProviders(rule, req)
+			evaluation.Reason = "no eligible providers"
+			for _, p := range providers {
+				if p.Eligible {
+					evaluation.Reason = ""
+					break
+				}
+			}
+		}
+		evaluation.Matched = evaluation.Reason == ""
+		explanation.Rules = append(explanation.Rules, evaluation)
+		if evaluation.Matched {
+			explanation.MatchedRule = rule.Name
+			explanation.Providers = providers
+			break
+		}
+	}
+	return &explanation
+}
+
+// mismatch returns the reason for the rule not matching the request, or an
+// empty string if the rule matches it.
+func (rule *routingRule) mismatch(req routingRequest, scheme, bucket string) string {
+	if len(rule.SourceSchemes) > 0 && !containsString(rule.SourceSchemes, scheme) {
+		return fmt.Sprintf("source scheme %q doesn't match", scheme)
+	}
+	if len(rule.SourceBuckets) > 0 && !containsString(rule.SourceBuckets, bucket) {
+		return fmt.Sprintf("source bucket %q doesn't match", bucket)
+	}
+	if len(rule.OutputFormats) > 0 {
+		for _, format := range req.outputFormats {
+			if !containsString(rule.OutputFormats, format) {
+				return fmt.Sprintf("output format %q doesn't match", format)
+			}
+		}
+	}
+	if len(rule.StreamingProtocols) > 0 && !containsString(rule.StreamingProtocols, req.streamingProtocol) {
+		return fmt.Sprintf("streaming protocol %q doesn't match", req.streamingProtocol)
+	}
+	return ""
+}
+
+// evaluateProviders checks which providers of the rule are enabled and
+// support all output formats, the streaming protocol, the destination and the
+// thumbnails of the request.
+func (r *router) evaluateProviders(rule routingRule, req routingRequest) []RoutingProvider {
+	providers := make([]RoutingProvider, len(rule.Providers))
+	for i, target := range rule.Providers {
+		p := RoutingProvider{Name: target.Name, Weight: 1}
+		if target.Weight != nil {
+			p.Weight = *target.Weight
+		}
+		providers[i] = p
+		description, err := r.describe(target.Name)
+		if err != nil {
+			providers[i].Reason = err.Error()
+			continue
+		}
+		if !description.Enabled {
+			providers[i].Reason = "provider is not enabled"
+			continue
+		}
+		providers[i].Eligible = true
+		for _, format := range req.outputFormats {
+			if !containsString(description.Capabilities.OutputFormats, format) {
+				providers[i].Eligible = false
+				providers[i].Reason = fmt.Sprintf("output format %q is not supported", format)
+				break
+			}
+		}
+		if providers[i].Eligible && req.streamingProtocol != "" && !containsString(description.Capabilities.OutputFormats, req.streamingProtocol) {
+			providers[i].Eligible = false
+			providers[i].Reason = fmt.Sprintf("streaming protocol %q is not supported", req.streamingProtocol)
+		}
+		if providers[i].Eligible && req.destinationType != "" && !containsString(description.Capabilities.Destinations, req.destinationType) {
+			providers[i].Eligible = false
+			providers[i].Reason = fmt.Sprintf("destination %q is not supported", req.destinationType)
+		}
+		if providers[i].Eligible && req.thumbnails != nil {
+			if err = description.Capabilities.Thumbnails.Check(req.thumbnails); err != nil {
+				providers[i].Eligible = false
+				providers[i].Reason = err.Error()
+			}
+		}
+		if providers[i].Eligible {
+			if err = description.Capabilities.Clipping.Check(req.clipping); err != nil {
+				providers[i].Eligible = false
+				providers[i].Reason = err.Error()
+			}
+		}
+	}
+	return providers
+}
+
+// sourceLocation returns the scheme and the bucket of the given source
+// media. For HTTP URLs, the bucket is only extracted from virtual-hosted S3
+// URLs.
+func sourceLocation(source string) (string, string) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", ""
+	}
+	scheme := strings.ToLower(u.Scheme)
+	switch scheme {
+	case "http", "https":
+		if i := strings.Index(u.Host, ".s3"); i > 0 && strings.HasSuffix(u.Host, ".amazonaws.com") {
+			return scheme, u.Host[:i]
+		}
+		return scheme, ""
+	default:
+		return scheme, u.Host
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(values []string, value string) []string {
+	if containsString(values, value) {
+		return values
+	}
+	return append(values, value)
+}
+
+// swagger:route GET /routing/explain routing explainRouting
+//
+// Explains which routing rule and providers would be used for a job with the
+// given attributes, when the job doesn't specify a provider.
+//
+//     Responses:
+//       200: routingExplanation
+//       400: invalidRoutingRequest
+//       500: genericError
+func (s *TranscodingService) explainRouting(r *http.Request) swagger.GizmoJSONResponse {
+	var params explainRoutingInput
+	params.loadParams(r.URL.Query())
+	if params.Source == "" {
+		return newInvalidRoutingRequestResponse(errors.New("missing source"))
+	}
+	req := routingRequest{
+		source:            params.Source,
+		streamingProtocol: params.StreamingProtocol,
+	}
+	switch params.Clipping {
+	case "", db.ClippingJob, db.ClippingOutputs:
+		req.clipping = params.Clipping
+	default:
+		return newInvalidRoutingRequestResponse(fmt.Errorf("invalid clipping %q: must be job or outputs", params.Clipping))
+	}
+	if params.Destination != "" {
+		var err error
+		req.destinationType, err = provider.DestinationType(params.Destination)
+		if err != nil {
+			return newInvalidRoutingRequestResponse(err)
+		}
+	}
+	for _, presetName := range params.Presets {
+		presetMap, err := s.db.GetPresetMap(presetName)
+		if err != nil {
+			if err == db.ErrPresetMapNotFound {
+				return newInvalidRoutingRequestResponse(fmt.Errorf("%s: %s", err, presetName))
+			}
+			return swagger.NewErrorResponse(err)
+		}
+		req.outputFormats = appendUnique(req.outputFormats, outputFormat(presetMap))
+	}
+	for _, format := range params.OutputFormats {
+		req.outputFormats = appendUnique(req.outputFormats, format)
+	}
+	if params.ThumbnailFormat != "" {
+		req.thumbnails = &db.Thumbnails{Interval: 1, Format: params.ThumbnailFormat}
+		if err := req.thumbnails.Validate(); err != nil {
+			return newInvalidRoutingRequestResponse(err)
+		}
+	}
+	return newRoutingExplanationResponse(s.router.explain(req))
+}
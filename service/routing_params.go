@@ -0,0 +1,54 @@
+package service
+
+import "net/url"
+
+// swagger:parameters explainRouting
+type explainRoutingInput struct {
+	// source media of the hypothetical job
+	//
+	// in: query
+	// required: true
+	Source string `json:"source"`
+
+	// presets used in the outputs of the job, can be repeated
+	//
+	// in: query
+	Presets []string `json:"preset"`
+
+	// output formats of the job, like mp4 or hls, can be repeated. They
+	// may be used instead of, or in addition to, the presets
+	//
+	// in: query
+	OutputFormats []string `json:"outputFormat"`
+
+	// adaptive streaming protocol of the job (hls or dash)
+	//
+	// in: query
+	StreamingProtocol string `json:"streamingProtocol"`
+
+	// destination of the job, like s3://bucket/path
+	//
+	// in: query
+	Destination string `json:"destination"`
+
+	// format of the thumbnails generated by the job (jpg or png), if any
+	//
+	// in: query
+	ThumbnailFormat string `json:"thumbnailFormat"`
+
+	// how the job clips the source media: job, when all outputs share the
+	// same clip, or outputs, when outputs use their own clips
+	//
+	// in: query
+	Clipping string `json:"clipping"`
+}
+
+func (p *explainRoutingInput) loadParams(values url.Values) {
+	p.Source = values.Get("source")
+	p.Presets = values["preset"]
+	p.OutputFormats = values["outputFormat"]
+	p.StreamingProtocol = values.Get("streamingProtocol")
+	p.Destination = values.Get("destination")
+	p.ThumbnailFormat = values.Get("thumbnailFormat")
+	p.Clipping = values.Get("clipping")
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+// response for the explainRouting operation.
+//
+// swagger:response routingExplanation
+type routingExplanationResponse struct {
+	// in: body
+	Payload *RoutingExplanation
+
+	baseResponse
+}
+
+func newRoutingExplanationResponse(explanation *RoutingExplanation) *routingExplanationResponse {
+	return &routingExplanationResponse{
+		baseResponse: baseResponse{
+			payload: explanation,
+			status:  http.StatusOK,
+		},
+	}
+}
+
+// error returned when the attributes of the hypothetical job are invalid.
+//
+// swagger:response invalidRoutingRequest
+type invalidRoutingRequestResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+func newInvalidRoutingRequestResponse(err error) *invalidRoutingRequestResponse {
+	return &invalidRoutingRequestResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusBadRequest)}
+}
+
+func (r *invalidRoutingRequestResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
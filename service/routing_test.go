@@ -0,0 +1,444 @@
+package service
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/Sirupsen/logrus"
+)
+
+const testRoutingRules = `[
+	{"name":"hls","streamingProtocols":["hls"],"providers":[{"name":"zencoder"}]},
+	{"name":"archive","sourceSchemes":["s3"],"sourceBuckets":["archive"],"providers":[{"name":"fake","weight":3},{"name":"zencoder","weight":1}]},
+	{"name":"webm","outputFormats":["webm"],"providers":[{"name":"zencoder"}]},
+	{"name":"default","providers":[{"name":"fake"}]}
+]`
+
+func newTestRouter(t *testing.T, rules string) *router {
+	r, err := newRouter(&config.Config{Routing: &config.Routing{Rules: rules}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.rand = rand.New(rand.NewSource(1))
+	r.describe = func(providerName string) (*provider.Description, error) {
+		description := provider.Description{Name: providerName, Enabled: true, Health: provider.Health{OK: true}}
+		switch providerName {
+		case "fake":
+			description.Capabilities.OutputFormats = []string{"mp4", "hls"}
+			description.Capabilities.Destinations = []string{"s3"}
+			description.Capabilities.Clipping = &provider.ClippingCapabilities{}
+		case "zencoder":
+			description.Capabilities.OutputFormats = []string{"mp4", "hls", "dash", "webm"}
+			description.Capabilities.Destinations = []string{"s3", "ftp"}
+			description.Capabilities.Thumbnails = &provider.ThumbnailCapabilities{Formats: []string{"jpg", "png"}}
+			description.Capabilities.Clipping = &provider.ClippingCapabilities{PerOutput: true}
+		}
+		return &description, nil
+	}
+	return r
+}
+
+func TestNewRouterInvalidRules(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenRules    string
+	}{
+		{"invalid JSON", `[{"name":`},
+		{"missing name", `[{"providers":[{"name":"fake"}]}]`},
+		{"missing providers", `[{"name":"default"}]`},
+		{"unknown provider", `[{"name":"default","providers":[{"name":"nonexistent-provider"}]}]`},
+	}
+	for _, test := range tests {
+		r, err := newRouter(&config.Config{Routing: &config.Routing{Rules: test.givenRules}})
+		if err == nil {
+			t.Errorf("%s: unexpected <nil> error", test.givenTestCase)
+		}
+		if r != nil {
+			t.Errorf("%s: unexpected non-nil router", test.givenTestCase)
+		}
+	}
+}
+
+func TestRouterExplain(t *testing.T) {
+	var tests = []struct {
+		givenTestCase   string
+		givenRequest    routingRequest
+		wantExplanation *RoutingExplanation
+	}{
+		{
+			"streaming protocol",
+			routingRequest{source: "s3://archive/video.mov", outputFormats: []string{"hls"}, streamingProtocol: "hls"},
+			&RoutingExplanation{
+				Source:            "s3://archive/video.mov",
+				OutputFormats:     []string{"hls"},
+				StreamingProtocol: "hls",
+				Rules:             []RoutingRuleEvaluation{{Name: "hls", Matched: true}},
+				MatchedRule:       "hls",
+				Providers:         []RoutingProvider{{Name: "zencoder", Weight: 1, Eligible: true}},
+			},
+		},
+		{
+			"source bucket",
+			routingRequest{source: "s3://archive/video.mov", outputFormats: []string{"mp4"}},
+			&RoutingExplanation{
+				Source:        "s3://archive/video.mov",
+				OutputFormats: []string{"mp4"},
+				Rules: []RoutingRuleEvaluation{
+					{Name: "hls", Reason: `streaming protocol "" doesn't match`},
+					{Name: "archive", Matched: true},
+				},
+				MatchedRule: "archive",
+				Providers: []RoutingProvider{
+					{Name: "fake", Weight: 3, Eligible: true},
+					{Name: "zencoder", Weight: 1, Eligible: true},
+				},
+			},
+		},
+		{
+			"source bucket, unsupported format in one provider",
+			routingRequest{source: "s3://archive/video.mov", outputFormats: []string{"webm"}},
+			&RoutingExplanation{
+				Source:        "s3://archive/video.mov",
+				OutputFormats: []string{"webm"},
+				Rules: []RoutingRuleEvaluation{
+					{Name: "hls", Reason: `streaming protocol "" doesn't match`},
+					{Name: "archive", Matched: true},
+				},
+				MatchedRule: "archive",
+				Providers: []RoutingProvider{
+					{Name: "fake", Weight: 3, Reason: `output format "webm" is not supported`},
+					{Name: "zencoder", Weight: 1, Eligible: true},
+				},
+			},
+		},
+		{
+			"source bucket, unsupported streaming protocol in one provider",
+			routingRequest{source: "s3://archive/video.mov", outputFormats: []string{"mp4"}, streamingProtocol: "dash"},
+			&RoutingExplanation{
+				Source:            "s3://archive/video.mov",
+				OutputFormats:     []string{"mp4"},
+				StreamingProtocol: "dash",
+				Rules: []RoutingRuleEvaluation{
+					{Name: "hls", Reason: `streaming protocol "dash" doesn't match`},
+					{Name: "archive", Matched: true},
+				},
+				MatchedRule: "archive",
+				Providers: []RoutingProvider{
+					{Name: "fake", Weight: 3, Reason: `streaming protocol "dash" is not supported`},
+					{Name: "zencoder", Weight: 1, Eligible: true},
+				},
+			},
+		},
+		{
+			"source bucket, unsupported destination in one provider",
+			routingRequest{source: "s3://archive/video.mov", outputFormats: []string{"mp4"}, destinationType: "ftp"},
+			&RoutingExplanation{
+				Source:          "s3://archive/video.mov",
+				OutputFormats:   []string{"mp4"},
+				DestinationType: "ftp",
+				Rules: []RoutingRuleEvaluation{
+					{Name: "hls", Reason: `streaming protocol "" doesn't match`},
+					{Name: "archive", Matched: true},
+				},
+				MatchedRule: "archive",
+				Providers: []RoutingProvider{
+					{Name: "fake", Weight: 3, Reason: `destination "ftp" is not supported`},
+					{Name: "zencoder", Weight: 1, Eligible: true},
+				},
+			},
+		},
+		{
+			"source bucket, thumbnails not supported by one provider",
+			routingRequest{source: "s3://archive/video.mov", outputFormats: []string{"mp4"}, thumbnails: &db.Thumbnails{Interval: 5, Format: "png"}},
+			&RoutingExplanation{
+				Source:        "s3://archive/video.mov",
+				OutputFormats: []string{"mp4"},
+				Thumbnails:    &db.Thumbnails{Interval: 5, Format: "png"},
+				Rules: []RoutingRuleEvaluation{
+					{Name: "hls", Reason: `streaming protocol "" doesn't match`},
+					{Name: "archive", Matched: true},
+				},
+				MatchedRule: "archive",
+				Providers: []RoutingProvider{
+					{Name: "fake", Weight: 3, Reason: "thumbnails are not supported"},
+					{Name: "zencoder", Weight: 1, Eligible: true},
+				},
+			},
+		},
+		{
+			"source bucket, output clipping not supported by one provider",
+			routingRequest{source: "s3://archive/video.mov", outputFormats: []string{"mp4"}, clipping: db.ClippingOutputs},
+			&RoutingExplanation{
+				Source:        "s3://archive/video.mov",
+				OutputFormats: []string{"mp4"},
+				Clipping:      "outputs",
+				Rules: []RoutingRuleEvaluation{
+					{Name: "hls", Reason: `streaming protocol "" doesn't match`},
+					{Name: "archive", Matched: true},
+				},
+				MatchedRule: "archive",
+				Providers: []RoutingProvider{
+					{Name: "fake", Weight: 3, Reason: "clipping per output is not supported"},
+					{Name: "zencoder", Weight: 1, Eligible: true},
+				},
+			},
+		},
+		{
+			"output format",
+			routingRequest{source: "http://nyt-bucket.s3.amazonaws.com/video.mov", outputFormats: []string{"webm"}},
+			&RoutingExplanation{
+				Source:        "http://nyt-bucket.s3.amazonaws.com/video.mov",
+				OutputFormats: []string{"webm"},
+				Rules: []RoutingRuleEvaluation{
+					{Name: "hls", Reason: `streaming protocol "" doesn't match`},
+					{Name: "archive", Reason: `source scheme "http" doesn't match`},
+					{Name: "webm", Matched: true},
+				},
+				MatchedRule: "webm",
+				Providers:   []RoutingProvider{{Name: "zencoder", Weight: 1, Eligible: true}},
+			},
+		},
+		{
+			"default rule",
+			routingRequest{source: "s3://other/video.mov", outputFormats: []string{"mp4", "webm"}},
+			&RoutingExplanation{
+				Source:        "s3://other/video.mov",
+				OutputFormats: []string{"mp4", "webm"},
+				Rules: []RoutingRuleEvaluation{
+					{Name: "hls", Reason: `streaming protocol "" doesn't match`},
+					{Name: "archive", Reason: `source bucket "other" doesn't match`},
+					{Name: "webm", Reason: `output format "mp4" doesn't match`},
+					{Name: "default", Reason: "no eligible providers"},
+				},
+			},
+		},
+	}
+	r := newTestRouter(t, testRoutingRules)
+	for _, test := range tests {
+		explanation := r.explain(test.givenRequest)
+		if !reflect.DeepEqual(explanation, test.wantExplanation) {
+			t.Errorf("%s: wrong explanation\nwant %#v\ngot  %#v", test.givenTestCase, test.wantExplanation, explanation)
+		}
+	}
+}
+
+func TestRouterRoute(t *testing.T) {
+	r := newTestRouter(t, testRoutingRules)
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		providerNames, err := r.route(routingRequest{source: "s3://archive/video.mov", outputFormats: []string{"mp4"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(providerNames) != 2 {
+			t.Fatalf("wrong number of providers returned: %#v", providerNames)
+		}
+		if providerNames[0] == providerNames[1] {
+			t.Fatalf("duplicate provider returned: %#v", providerNames)
+		}
+		counts[providerNames[0]]++
+	}
+	if counts["fake"] < 650 || counts["fake"] > 850 {
+		t.Errorf("wrong distribution of jobs, want ~750 jobs in fake. Got %#v", counts)
+	}
+	_, err := r.route(routingRequest{source: "s3://other/video.mov", outputFormats: []string{"mp4", "webm"}})
+	if err == nil || err.Error() != "no routing rule matches the job" {
+		t.Errorf("wrong error returned: %v", err)
+	}
+	r = newTestRouter(t, "")
+	_, err = r.route(routingRequest{source: "s3://other/video.mov", outputFormats: []string{"mp4"}})
+	if err != errMissingProvider {
+		t.Errorf("wrong error returned. Want %#v. Got %#v", errMissingProvider, err)
+	}
+}
+
+func TestRouterRouteZeroWeight(t *testing.T) {
+	r := newTestRouter(t, `[{"name":"default","providers":[{"name":"fake","weight":0},{"name":"zencoder"}]}]`)
+	for i := 0; i < 100; i++ {
+		providerNames, err := r.route(routingRequest{source: "s3://bucket/video.mov", outputFormats: []string{"mp4"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"zencoder", "fake"}; !reflect.DeepEqual(providerNames, want) {
+			t.Fatalf("wrong providers returned. Want %#v. Got %#v", want, providerNames)
+		}
+	}
+	explanation := r.explain(routingRequest{source: "s3://bucket/video.mov", outputFormats: []string{"mp4"}})
+	if weight := explanation.Providers[0].Weight; weight != 0 {
+		t.Errorf("wrong weight of the standby provider. Want 0. Got %d", weight)
+	}
+	r = newTestRouter(t, `[{"name":"default","providers":[{"name":"fake","weight":0},{"name":"zencoder","weight":0}]}]`)
+	providerNames, err := r.route(routingRequest{source: "s3://bucket/video.mov", outputFormats: []string{"mp4"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"fake", "zencoder"}; !reflect.DeepEqual(providerNames, want) {
+		t.Errorf("wrong providers returned when all weights are 0. Want %#v. Got %#v", want, providerNames)
+	}
+}
+
+func TestTranscodeRouting(t *testing.T) {
+	fprovider.jobs = nil
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDBObj := dbtest.NewFakeRepository(false)
+	fakeDBObj.CreatePresetMap(&db.PresetMap{
+		Name:            "webm_720p",
+		ProviderMapping: map[string]string{"fake": "17727", "zencoder": "17727"},
+		OutputOpts:      db.OutputOptions{Extension: "webm"},
+	})
+	service, err := NewTranscodingService(&config.Config{Routing: &config.Routing{Rules: testRoutingRules}}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDBObj
+	srvr.Register(service)
+	r, _ := http.NewRequest("POST", "/jobs", strings.NewReader(`{"source":"http://some.source/video.mp4","outputs":[{"preset":"webm_720p"}]}`))
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("wrong response code. Want %d. Got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var got map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := fakeDBObj.GetJob(got["jobId"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ProviderName != "zencoder" {
+		t.Errorf("wrong provider name. Want %q. Got %q", "zencoder", job.ProviderName)
+	}
+}
+
+func TestExplainRouting(t *testing.T) {
+	var tests = []struct {
+		givenTestCase string
+		givenURI      string
+
+		wantCode int
+		wantBody map[string]interface{}
+	}{
+		{
+			"presets and streaming protocol",
+			"/routing/explain?source=s3://archive/video.mov&preset=hls_1080p&streamingProtocol=hls",
+			http.StatusOK,
+			map[string]interface{}{
+				"source":            "s3://archive/video.mov",
+				"outputFormats":     []interface{}{"hls"},
+				"streamingProtocol": "hls",
+				"rules":             []interface{}{map[string]interface{}{"name": "hls", "matched": true}},
+				"matchedRule":       "hls",
+				"providers": []interface{}{
+					map[string]interface{}{"name": "zencoder", "weight": float64(1), "eligible": true},
+				},
+			},
+		},
+		{
+			"output formats",
+			"/routing/explain?source=s3://other/video.mov&outputFormat=mp4",
+			http.StatusOK,
+			map[string]interface{}{
+				"source":        "s3://other/video.mov",
+				"outputFormats": []interface{}{"mp4"},
+				"rules": []interface{}{
+					map[string]interface{}{"name": "hls", "matched": false, "reason": `streaming protocol "" doesn't match`},
+					map[string]interface{}{"name": "archive", "matched": false, "reason": `source bucket "other" doesn't match`},
+					map[string]interface{}{"name": "webm", "matched": false, "reason": `output format "mp4" doesn't match`},
+					map[string]interface{}{"name": "default", "matched": true},
+				},
+				"matchedRule": "default",
+				"providers": []interface{}{
+					map[string]interface{}{"name": "fake", "weight": float64(1), "eligible": true},
+				},
+			},
+		},
+		{
+			"unsupported destination",
+			"/routing/explain?source=s3://other/video.mov&outputFormat=mp4&destination=gs://bucket/videos",
+			http.StatusOK,
+			map[string]interface{}{
+				"source":          "s3://other/video.mov",
+				"outputFormats":   []interface{}{"mp4"},
+				"destinationType": "gcs",
+				"rules": []interface{}{
+					map[string]interface{}{"name": "hls", "matched": false, "reason": `streaming protocol "" doesn't match`},
+					map[string]interface{}{"name": "archive", "matched": false, "reason": `source bucket "other" doesn't match`},
+					map[string]interface{}{"name": "webm", "matched": false, "reason": `output format "mp4" doesn't match`},
+					map[string]interface{}{"name": "default", "matched": false, "reason": "no eligible providers"},
+				},
+			},
+		},
+		{
+			"invalid destination",
+			"/routing/explain?source=s3://other/video.mov&outputFormat=mp4&destination=bucket/videos",
+			http.StatusBadRequest,
+			map[string]interface{}{"error": `invalid destination "bucket/videos": unsupported scheme ""`},
+		},
+		{
+			"invalid thumbnail format",
+			"/routing/explain?source=s3://other/video.mov&outputFormat=mp4&thumbnailFormat=gif",
+			http.StatusBadRequest,
+			map[string]interface{}{"error": `invalid thumbnails: invalid format "gif": must be jpg or png`},
+		},
+		{
+			"invalid clipping",
+			"/routing/explain?source=s3://other/video.mov&outputFormat=mp4&clipping=preview",
+			http.StatusBadRequest,
+			map[string]interface{}{"error": `invalid clipping "preview": must be job or outputs`},
+		},
+		{
+			"unknown preset",
+			"/routing/explain?source=s3://other/video.mov&preset=mp4_4k",
+			http.StatusBadRequest,
+			map[string]interface{}{"error": "presetmap not found: mp4_4k"},
+		},
+		{
+			"missing source",
+			"/routing/explain?outputFormat=mp4",
+			http.StatusBadRequest,
+			map[string]interface{}{"error": "missing source"},
+		},
+	}
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDBObj := dbtest.NewFakeRepository(false)
+		fakeDBObj.CreatePresetMap(&db.PresetMap{
+			Name:            "hls_1080p",
+			ProviderMapping: map[string]string{"fake": "19928", "zencoder": "19928"},
+			OutputOpts:      db.OutputOptions{Extension: "m3u8"},
+		})
+		service, err := NewTranscodingService(&config.Config{Routing: &config.Routing{Rules: testRoutingRules}}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDBObj
+		srvr.Register(service)
+		r, _ := http.NewRequest("GET", test.givenURI, nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		err = json.Unmarshal(w.Body.Bytes(), &got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.wantBody) {
+			t.Errorf("%s: wrong response body\nwant %#v\ngot  %#v", test.givenTestCase, test.wantBody, got)
+		}
+	}
+}
@@ -3,12 +3,14 @@ package service
 import (
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/NYTimes/gizmo/server"
 	"github.com/NYTimes/gziphandler"
 	"github.com/NYTimes/video-transcoding-api/config"
 	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/db/redis"
+	"github.com/NYTimes/video-transcoding-api/provider"
 	"github.com/NYTimes/video-transcoding-api/swagger"
 	"github.com/Sirupsen/logrus"
 	"github.com/fsouza/ctxlogger"
@@ -17,9 +19,28 @@ import (
 // TranscodingService will implement server.JSONService and handle all requests
 // to the server.
 type TranscodingService struct {
-	config *config.Config
-	db     db.Repository
-	logger *logrus.Logger
+	config     *config.Config
+	db         db.Repository
+	logger     *logrus.Logger
+	notifier   *notifier
+	reconciler *presetReconciler
+	cleaner    *presetCleaner
+
+	// health caches the health of the providers, refreshed by the
+	// healthMonitor and guarded by healthMtx.
+	healthMtx     sync.RWMutex
+	health        map[string]provider.Health
+	healthMonitor *healthMonitor
+
+	// poller is started and stopped while requests are served, so it's
+	// guarded by pollerMtx (see statusPoller).
+	pollerMtx sync.RWMutex
+	poller    *statusPoller
+
+	failoverPolicies    map[string][]string
+	allowedDestinations []string
+	router              *router
+	prober              *sourceProber
 }
 
 // NewTranscodingService will instantiate a JSONService
@@ -29,7 +50,33 @@ func NewTranscodingService(cfg *config.Config, logger *logrus.Logger) (*Transcod
 	if err != nil {
 		return nil, fmt.Errorf("Error initializing Redis client: %s", err)
 	}
-	return &TranscodingService{config: cfg, db: dbRepo, logger: logger}, nil
+	failoverPolicies, err := parseFailoverPolicies(cfg.FailoverPolicies)
+	if err != nil {
+		return nil, err
+	}
+	allowedDestinations, err := parseAllowedDestinations(cfg.AllowedDestinations)
+	if err != nil {
+		return nil, err
+	}
+	jobRouter, err := newRouter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	prober, err := newSourceProber(cfg.Probe)
+	if err != nil {
+		return nil, err
+	}
+	service := TranscodingService{
+		config:              cfg,
+		db:                  dbRepo,
+		logger:              logger,
+		failoverPolicies:    failoverPolicies,
+		allowedDestinations: allowedDestinations,
+		router:              jobRouter,
+		prober:              prober,
+	}
+	service.notifier = newNotifier(cfg.Notifications, dbRepo, logger, service.genID)
+	return &service, nil
 }
 
 // Prefix returns the string prefix used for all endpoints within
@@ -62,6 +109,7 @@ func (s *TranscodingService) JSONEndpoints() map[string]map[string]server.JSONEn
 	return map[string]map[string]server.JSONEndpoint{
 		"/jobs": {
 			"POST": swagger.HandlerToJSONEndpoint(s.newTranscodeJob),
+			"GET":  swagger.HandlerToJSONEndpoint(s.listTranscodeJobs),
 		},
 		"/jobs/:jobId": {
 			"GET": swagger.HandlerToJSONEndpoint(s.getTranscodeJob),
@@ -69,12 +117,51 @@ func (s *TranscodingService) JSONEndpoints() map[string]map[string]server.JSONEn
 		"/jobs/:jobId/cancel": {
 			"POST": swagger.HandlerToJSONEndpoint(s.cancelTranscodeJob),
 		},
+		"/jobs/:jobId/notifications": {
+			"GET": swagger.HandlerToJSONEndpoint(s.listJobNotifications),
+		},
+		"/ladders": {
+			"POST": swagger.HandlerToJSONEndpoint(s.newLadder),
+			"GET":  swagger.HandlerToJSONEndpoint(s.listLadders),
+		},
+		"/ladders/:name": {
+			"GET":    swagger.HandlerToJSONEndpoint(s.getLadder),
+			"PUT":    swagger.HandlerToJSONEndpoint(s.updateLadder),
+			"DELETE": swagger.HandlerToJSONEndpoint(s.deleteLadder),
+		},
+		"/probe": {
+			"POST": swagger.HandlerToJSONEndpoint(s.probeSource),
+		},
+		"/routing/explain": {
+			"GET": swagger.HandlerToJSONEndpoint(s.explainRouting),
+		},
 		"/presets": {
 			"POST": swagger.HandlerToJSONEndpoint(s.newPreset),
+			"GET":  swagger.HandlerToJSONEndpoint(s.listPresets),
 		},
 		"/presets/:name": {
+			// the router doesn't allow static paths like
+			// /presets/validate next to /presets/:name, so presetAction
+			// handles POST /presets/validate and POST /presets/import,
+			// and getPreset handles GET /presets/export.
+			"GET":    swagger.HandlerToJSONEndpoint(s.getPreset),
+			"PUT":    swagger.HandlerToJSONEndpoint(s.updatePreset),
+			"POST":   swagger.HandlerToJSONEndpoint(s.presetAction),
 			"DELETE": swagger.HandlerToJSONEndpoint(s.deletePreset),
 		},
+		"/presets/:name/drift": {
+			"GET":  swagger.HandlerToJSONEndpoint(s.getPresetDrift),
+			"POST": swagger.HandlerToJSONEndpoint(s.repairPresetDrift),
+		},
+		"/presets/:name/versions": {
+			"GET": swagger.HandlerToJSONEndpoint(s.listPresetVersions),
+		},
+		"/presets/:name/versions/:version": {
+			"GET": swagger.HandlerToJSONEndpoint(s.getPresetVersion),
+		},
+		"/presets/:name/versions/:version/rollback": {
+			"POST": swagger.HandlerToJSONEndpoint(s.rollbackPreset),
+		},
 		"/presetmaps": {
 			"POST": swagger.HandlerToJSONEndpoint(s.newPresetMap),
 			"GET":  swagger.HandlerToJSONEndpoint(s.listPresetMaps),
@@ -90,6 +177,9 @@ func (s *TranscodingService) JSONEndpoints() map[string]map[string]server.JSONEn
 		"/providers/:name": {
 			"GET": swagger.HandlerToJSONEndpoint(s.getProvider),
 		},
+		"/notifications/:provider": {
+			"POST": swagger.HandlerToJSONEndpoint(s.receiveProviderNotification),
+		},
 	}
 }
 
@@ -2,11 +2,13 @@ package service
 
 import (
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"path"
 	"path/filepath"
+	"time"
 
 	"github.com/NYTimes/gizmo/web"
 	"github.com/NYTimes/video-transcoding-api/db"
@@ -14,6 +16,18 @@ import (
 	"github.com/NYTimes/video-transcoding-api/swagger"
 )
 
+// defaultPlaylistFileNames maps the supported adaptive streaming protocols to
+// the name of the playlist used when the job doesn't define one.
+var defaultPlaylistFileNames = map[string]string{
+	"hls":  "hls/index.m3u8",
+	"dash": "dash/index.mpd",
+}
+
+const (
+	defaultThumbnailFormat = "jpg"
+	defaultThumbnailPath   = "thumbnails"
+)
+
 // swagger:route POST /jobs jobs newJob
 //
 // Creates a new transcoding job.
@@ -25,21 +39,39 @@ import (
 func (s *TranscodingService) newTranscodeJob(r *http.Request) swagger.GizmoJSONResponse {
 	defer r.Body.Close()
 	var input newTranscodeJobInput
-	providerFactory, err := input.ProviderFactory(r.Body)
+	providerNames, err := input.ProviderNames(r.Body, s.failoverPolicies)
 	if err != nil {
 		return newInvalidJobResponse(err)
 	}
-	providerObj, err := providerFactory(s.config)
-	if err != nil {
-		formattedErr := fmt.Errorf("Error initializing provider %s for new job: %v %s", input.Payload.Provider, providerObj, err)
-		if _, ok := err.(provider.InvalidConfigError); ok {
-			return newInvalidJobResponse(formattedErr)
+	if input.Payload.Destination != "" {
+		// credentials are taken from the configuration of providers,
+		// never stored along with jobs.
+		input.Payload.Destination = provider.StripCredentials(input.Payload.Destination)
+		if err = s.checkDestination(input.Payload.Destination); err != nil {
+			return newInvalidJobResponse(err)
+		}
+	}
+	if input.Payload.CallbackURL != "" {
+		if err = s.notifier.checkCallbackURL(input.Payload.CallbackURL); err != nil {
+			return newInvalidJobResponse(err)
 		}
-		return swagger.NewErrorResponse(formattedErr)
 	}
 	job := db.Job{
 		SourceMedia:     input.Payload.Source,
+		Destination:     input.Payload.Destination,
 		StreamingParams: input.Payload.StreamingParams,
+		CallbackURL:     input.Payload.CallbackURL,
+		CallbackSecret:  input.Payload.CallbackSecret,
+		Thumbnails:      input.Payload.Thumbnails,
+		Clip:            input.Payload.Clip,
+	}
+	if job.Thumbnails != nil {
+		if job.Thumbnails.Format == "" {
+			job.Thumbnails.Format = defaultThumbnailFormat
+		}
+		if job.Thumbnails.Path == "" {
+			job.Thumbnails.Path = defaultThumbnailPath
+		}
 	}
 	outputs := make([]db.TranscodeOutput, len(input.Payload.Outputs))
 	for i, output := range input.Payload.Outputs {
@@ -54,32 +86,67 @@ func (s *TranscodingService) newTranscodeJob(r *http.Request) swagger.GizmoJSONR
 		if fileName == "" {
 			fileName = s.defaultFileName(input.Payload.Source, presetMap)
 		}
-		outputs[i] = db.TranscodeOutput{FileName: fileName, Preset: *presetMap}
+		outputs[i] = db.TranscodeOutput{FileName: fileName, Preset: *presetMap, PresetVersion: presetMap.Version, Clip: output.Clip}
 	}
 	job.Outputs = outputs
+	var source *provider.SourceInfo
+	checkSource := s.prober.shouldCheck(input.Payload.Probe)
+	// the rungs of a ladder depend on the source media, and clips are
+	// checked against its duration, so it's probed unless the job
+	// explicitly disables probing.
+	if checkSource || ((input.Payload.Ladder != "" || job.Clipping() != "") && input.Payload.Probe == nil) {
+		if source, err = s.probeJobSource(&job); err != nil {
+			return newInvalidJobResponse(err)
+		}
+	}
+	if input.Payload.Ladder != "" {
+		if err = s.addLadderOutputs(&job, input.Payload.Ladder, source); err != nil {
+			if err == db.ErrLadderNotFound || err == db.ErrPresetMapNotFound {
+				return newInvalidJobResponse(err)
+			}
+			return swagger.NewErrorResponse(err)
+		}
+	}
+	if source != nil {
+		if err = checkClips(source, &job); err != nil {
+			return newInvalidJobResponse(err)
+		}
+	}
+	if checkSource && source != nil {
+		if err = s.prober.check(source, &job); err != nil {
+			return newInvalidJobResponse(err)
+		}
+	}
 	job.ID, err = s.genID()
 	if err != nil {
 		return swagger.NewErrorResponse(err)
 	}
-	if job.StreamingParams.Protocol == "hls" {
+	if defaultPlaylist, ok := defaultPlaylistFileNames[job.StreamingParams.Protocol]; ok {
 		if job.StreamingParams.PlaylistFileName == "" {
-			job.StreamingParams.PlaylistFileName = "hls/index.m3u8"
+			job.StreamingParams.PlaylistFileName = defaultPlaylist
 		}
 		if job.StreamingParams.SegmentDuration == 0 {
 			job.StreamingParams.SegmentDuration = s.config.DefaultSegmentDuration
 		}
 	}
-	jobStatus, err := providerObj.Transcode(&job)
-	if err == provider.ErrPresetMapNotFound {
-		return newInvalidJobResponse(err)
+	if len(providerNames) == 0 {
+		providerNames, err = s.router.route(newRoutingRequest(&job))
+		if err != nil {
+			return newInvalidJobResponse(err)
+		}
 	}
+	jobStatus, err := s.transcode(&job, providerNames)
 	if err != nil {
-		providerError := fmt.Errorf("Error with provider %q: %s", input.Payload.Provider, err)
-		return swagger.NewErrorResponse(providerError)
+		if subErr, ok := err.(*submissionError); ok && subErr.invalid {
+			return newInvalidJobResponse(err)
+		}
+		return swagger.NewErrorResponse(err)
+	}
+	if jobStatus.Status == "" {
+		jobStatus.Status = provider.StatusQueued
 	}
-	jobStatus.ProviderName = input.Payload.Provider
-	job.ProviderName = jobStatus.ProviderName
 	job.ProviderJobID = jobStatus.ProviderJobID
+	s.setJobStatus(&job, jobStatus)
 	err = s.db.CreateJob(&job)
 	if err != nil {
 		return swagger.NewErrorResponse(err)
@@ -104,16 +171,53 @@ func (s *TranscodingService) defaultFileName(source string, preset *db.PresetMap
 	_, source = path.Split(source)
 	source = source[:len(source)-len(sourceExtension)]
 	pattern := "%s_%s.%s"
-	if preset.OutputOpts.Extension == "m3u8" {
+	switch preset.OutputOpts.Extension {
+	case "m3u8":
 		pattern = "hls/" + pattern
+	case "mpd":
+		pattern = "dash/" + pattern
 	}
 	return fmt.Sprintf(pattern, source, preset.Name, preset.OutputOpts.Extension)
 }
 
+// swagger:route GET /jobs jobs listJobs
+//
+// Lists transcoding jobs, optionally filtering them by provider, status,
+// creation time, source and preset. Results are paginated using the cursor
+// returned in the response.
+//
+//     Responses:
+//       200: listJobs
+//       400: invalidJobFilter
+//       500: genericError
+func (s *TranscodingService) listTranscodeJobs(r *http.Request) swagger.GizmoJSONResponse {
+	var params listTranscodeJobsInput
+	params.loadParams(r.URL.Query())
+	filter, err := params.JobFilter()
+	if err != nil {
+		return newInvalidJobFilterResponse(err)
+	}
+	limit := filter.Limit
+	filter.Limit++
+	jobs, err := s.db.ListJobs(filter)
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	var jobList JobList
+	if uint(len(jobs)) > limit {
+		jobs = jobs[:limit]
+		jobList.NextCursor = encodeJobCursor(db.NewJobCursor(&jobs[len(jobs)-1]))
+	}
+	jobList.Jobs = jobs
+	return newListJobsResponse(&jobList)
+}
+
 // swagger:route GET /jobs/{jobId} jobs getJob
 //
 // Finds a trancode job using its ID.
-// It also queries the provider to get the status of the job.
+// It returns the last known status of the job, refreshed in background. When
+// there's no known status, or when refresh is requested, it also queries the
+// provider to get the status of the job.
 //
 //     Responses:
 //       200: jobStatus
@@ -122,8 +226,19 @@ func (s *TranscodingService) defaultFileName(source string, preset *db.PresetMap
 //       500: genericError
 func (s *TranscodingService) getTranscodeJob(r *http.Request) swagger.GizmoJSONResponse {
 	var params getTranscodeJobInput
-	params.loadParams(web.Vars(r))
-	return s.getJobStatusResponse(s.getTranscodeJobByID(params.JobID))
+	params.loadParams(web.Vars(r), r.URL.Query())
+	job, err := s.getJob(params.JobID)
+	if err != nil {
+		return s.getJobStatusResponse(job, nil, nil, err)
+	}
+	if !params.Refresh {
+		if status := s.cachedJobStatus(job); status != nil {
+			status.ProviderAttempts = job.ProviderAttempts
+			return newJobStatusResponse(status)
+		}
+	}
+	status, providerObj, err := s.refreshJobStatus(job)
+	return s.getJobStatusResponse(job, status, providerObj, err)
 }
 
 func (s *TranscodingService) getJobStatusResponse(job *db.Job, status *provider.JobStatus, p provider.TranscodingProvider, err error) swagger.GizmoJSONResponse {
@@ -140,31 +255,133 @@ func (s *TranscodingService) getJobStatusResponse(job *db.Job, status *provider.
 		}
 		return swagger.NewErrorResponse(err)
 	}
+	status.ProviderAttempts = job.ProviderAttempts
 	return newJobStatusResponse(status)
 }
 
 func (s *TranscodingService) getTranscodeJobByID(jobID string) (*db.Job, *provider.JobStatus, provider.TranscodingProvider, error) {
+	job, err := s.getJob(jobID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	jobStatus, providerObj, err := s.refreshJobStatus(job)
+	return job, jobStatus, providerObj, err
+}
+
+func (s *TranscodingService) getJob(jobID string) (*db.Job, error) {
 	job, err := s.db.GetJob(jobID)
 	if err != nil {
 		if err == db.ErrJobNotFound {
-			return nil, nil, nil, err
+			return nil, err
 		}
-		return nil, nil, nil, fmt.Errorf("error retrieving job with id %q: %s", jobID, err)
+		return nil, fmt.Errorf("error retrieving job with id %q: %s", jobID, err)
 	}
+	return job, nil
+}
+
+// refreshJobStatus queries the provider for the current status of the job,
+// and stores it in the repository.
+func (s *TranscodingService) refreshJobStatus(job *db.Job) (*provider.JobStatus, provider.TranscodingProvider, error) {
 	providerFactory, err := provider.GetProviderFactory(job.ProviderName)
 	if err != nil {
-		return job, nil, nil, fmt.Errorf("unknown provider %q for job id %q", job.ProviderName, jobID)
+		return nil, nil, fmt.Errorf("unknown provider %q for job id %q", job.ProviderName, job.ID)
 	}
 	providerObj, err := providerFactory(s.config)
 	if err != nil {
-		return job, nil, nil, fmt.Errorf("error initializing provider %q on job id %q: %s %s", job.ProviderName, jobID, providerObj, err)
+		return nil, nil, fmt.Errorf("error initializing provider %q on job id %q: %s %s", job.ProviderName, job.ID, providerObj, err)
 	}
 	jobStatus, err := providerObj.JobStatus(job)
 	if err != nil {
-		return job, nil, providerObj, err
+		return nil, providerObj, err
 	}
 	jobStatus.ProviderName = job.ProviderName
-	return job, jobStatus, providerObj, nil
+	s.updateJobStatus(job, jobStatus)
+	return jobStatus, providerObj, nil
+}
+
+// cachedJobStatus returns the last known status of the job. The cached status
+// is only used when the job is done, or when it's kept up to date by the
+// status poller, otherwise it returns nil. Jobs whose status wasn't refreshed
+// recently, or that are too old for the poller, are queried in the provider.
+func (s *TranscodingService) cachedJobStatus(job *db.Job) *provider.JobStatus {
+	if job.LastStatus == "" {
+		return nil
+	}
+	if poller := s.runningPoller(); !isDone(job.Status) && (poller == nil || !poller.isPolled(job, time.Now().UTC())) {
+		return nil
+	}
+	return s.lastJobStatus(job)
+}
+
+// lastJobStatus decodes the last known status of the job, returning nil when
+// it's not available.
+func (s *TranscodingService) lastJobStatus(job *db.Job) *provider.JobStatus {
+	if job.LastStatus == "" {
+		return nil
+	}
+	var status provider.JobStatus
+	if err := json.Unmarshal([]byte(job.LastStatus), &status); err != nil {
+		s.logger.WithError(err).Errorf("failed to decode the last known status of job %q", job.ID)
+		return nil
+	}
+	return &status
+}
+
+// setJobStatus sets the given status as the last known status of the job.
+// Credentials in the destination of the outputs, which providers may take
+// from their configuration, are removed from the status.
+func (s *TranscodingService) setJobStatus(job *db.Job, status *provider.JobStatus) {
+	status.Output.Destination = provider.StripCredentials(status.Output.Destination)
+	for i := range status.Output.Files {
+		status.Output.Files[i].Path = provider.StripCredentials(status.Output.Files[i].Path)
+	}
+	for i := range status.Output.Thumbnails {
+		status.Output.Thumbnails[i].Path = provider.StripCredentials(status.Output.Thumbnails[i].Path)
+	}
+	job.StatusUpdateTime = time.Now().UTC()
+	if status.Status != "" {
+		job.Status = string(status.Status)
+	}
+	data, err := json.Marshal(status)
+	if err != nil {
+		s.logger.WithError(err).Errorf("failed to encode the status of job %q", job.ID)
+		return
+	}
+	job.LastStatus = string(data)
+}
+
+// updateJobStatus stores the last known status of the job, so it can be
+// served without querying the provider and used for filtering the list of
+// jobs, and notifies the callback URL of the job when the status changes.
+//
+// The status is applied to the stored version of the job, so concurrent
+// updates from the poller, notifications and cancellations aren't lost.
+func (s *TranscodingService) updateJobStatus(job *db.Job, status *provider.JobStatus) {
+	var changed bool
+	updatedJob, err := s.db.UpdateJob(job.ID, func(job *db.Job) {
+		changed = status.Status != "" && job.Status != string(status.Status)
+		s.setJobStatus(job, status)
+	})
+	if err != nil {
+		s.logger.WithError(err).Errorf("failed to update the status of job %q", job.ID)
+		s.setJobStatus(job, status)
+		return
+	}
+	*job = *updatedJob
+	if changed {
+		s.notifier.notify(job, status)
+	}
+}
+
+// isDone checks whether the given status is terminal, meaning that the status
+// of the job won't change anymore.
+func isDone(status string) bool {
+	switch provider.Status(status) {
+	case provider.StatusFinished, provider.StatusFailed, provider.StatusCanceled:
+		return true
+	default:
+		return false
+	}
 }
 
 // swagger:route POST /jobs/{jobId}/cancel jobs cancelJob
@@ -198,5 +415,7 @@ func (s *TranscodingService) cancelTranscodeJob(r *http.Request) swagger.GizmoJS
 		return swagger.NewErrorResponse(err)
 	}
 	status.ProviderName = job.ProviderName
+	s.updateJobStatus(job, status)
+	status.ProviderAttempts = job.ProviderAttempts
 	return newJobStatusResponse(status)
 }
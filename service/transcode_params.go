@@ -1,9 +1,15 @@
 package service
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/provider"
@@ -19,13 +25,56 @@ type NewTranscodeJobInputPayload struct {
 	Outputs []struct {
 		FileName string `json:"fileName"`
 		Preset   string `json:"preset"`
+
+		// segment of the source media used by the output, overriding
+		// the clip of the job
+		Clip *db.Clip `json:"clip,omitempty"`
 	} `json:"outputs"`
 
+	// name of the ladder used for generating the outputs of this job. The
+	// rungs that suit the source media are added to the list of outputs
+	Ladder string `json:"ladder,omitempty"`
+
 	// provider to use in this job
 	Provider string `json:"provider"`
 
+	// ordered list of providers to use in this job. The job is submitted
+	// to the next provider in the list when the previous one fails or
+	// doesn't support all presets
+	Providers []string `json:"providers,omitempty"`
+
+	// name of the failover policy, configured in the API, that defines the
+	// ordered list of providers to use in this job
+	Policy string `json:"policy,omitempty"`
+
 	// provider Adaptive Streaming parameters
 	StreamingParams db.StreamingParams `json:"streamingParams,omitempty"`
+
+	// base location of the outputs (e.g. s3://bucket/path), overriding the
+	// destination configured in the provider. It must be under one of the
+	// destinations allowed in the API, and supported by the provider.
+	// Credentials in the destination are ignored
+	Destination string `json:"destination,omitempty"`
+
+	// thumbnails generated from the source media, along with the outputs
+	Thumbnails *db.Thumbnails `json:"thumbnails,omitempty"`
+
+	// segment of the source media used by the outputs of the job, instead
+	// of the whole source. It's checked against the duration of the source
+	// when the source can be probed
+	Clip *db.Clip `json:"clip,omitempty"`
+
+	// whether the source media should be probed, and checked against the
+	// probe rules, before submitting the job. It overrides the default
+	// configured in the API
+	Probe *bool `json:"probe,omitempty"`
+
+	// URL that will receive a POST request with the status of the job
+	// whenever it changes
+	CallbackURL string `json:"callbackUrl,omitempty"`
+
+	// secret used for signing the notifications sent to the callback URL
+	CallbackSecret string `json:"callbackSecret,omitempty"`
 }
 
 // swagger:parameters newJob
@@ -35,9 +84,12 @@ type newTranscodeJobInput struct {
 	Payload NewTranscodeJobInputPayload
 }
 
-// ProviderFactory loads and validates the parameters, and then returns the
-// provider factory.
-func (p *newTranscodeJobInput) ProviderFactory(body io.Reader) (provider.Factory, error) {
+// ProviderNames loads and validates the parameters, and then returns the
+// ordered list of providers that the job should be submitted to, resolving
+// failover policies using the given map. It returns an empty list when the
+// request doesn't specify any provider, meaning that the job should be
+// routed using the routing rules.
+func (p *newTranscodeJobInput) ProviderNames(body io.Reader, policies map[string][]string) ([]string, error) {
 	err := p.loadParams(body)
 	if err != nil {
 		return nil, err
@@ -46,7 +98,26 @@ func (p *newTranscodeJobInput) ProviderFactory(body io.Reader) (provider.Factory
 	if err != nil {
 		return nil, err
 	}
-	return provider.GetProviderFactory(p.Payload.Provider)
+	providerNames := p.Payload.Providers
+	switch {
+	case p.Payload.Provider != "":
+		providerNames = []string{p.Payload.Provider}
+	case p.Payload.Policy != "":
+		var ok bool
+		providerNames, ok = policies[p.Payload.Policy]
+		if !ok {
+			return nil, fmt.Errorf("unknown failover policy %q", p.Payload.Policy)
+		}
+	}
+	for _, providerName := range providerNames {
+		if _, err = provider.GetProviderFactory(providerName); err != nil {
+			if len(providerNames) == 1 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%s: %s", err, providerName)
+		}
+	}
+	return providerNames, nil
 }
 
 func (p *newTranscodeJobInput) loadParams(body io.Reader) error {
@@ -54,30 +125,216 @@ func (p *newTranscodeJobInput) loadParams(body io.Reader) error {
 }
 
 func (p *newTranscodeJobInput) validate() error {
-	if p.Payload.Provider == "" {
-		return errors.New("missing provider from request")
+	var providerFields int
+	for _, set := range []bool{p.Payload.Provider != "", len(p.Payload.Providers) > 0, p.Payload.Policy != ""} {
+		if set {
+			providerFields++
+		}
+	}
+	if providerFields > 1 {
+		return errors.New("provider, providers and policy are mutually exclusive")
 	}
 	if p.Payload.Source == "" {
 		return errors.New("missing source media from request")
 	}
-	if len(p.Payload.Outputs) == 0 {
+	if len(p.Payload.Outputs) == 0 && p.Payload.Ladder == "" {
 		return errors.New("missing output list from request")
 	}
+	if protocol := p.Payload.StreamingParams.Protocol; protocol != "" {
+		if _, ok := defaultPlaylistFileNames[protocol]; !ok {
+			return fmt.Errorf("invalid streaming protocol %q: must be hls or dash", protocol)
+		}
+	}
+	if p.Payload.Destination != "" {
+		if _, err := provider.DestinationType(p.Payload.Destination); err != nil {
+			return err
+		}
+	}
+	if p.Payload.Thumbnails != nil {
+		if err := p.Payload.Thumbnails.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Payload.Clip != nil {
+		if err := p.Payload.Clip.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, output := range p.Payload.Outputs {
+		if output.Clip != nil {
+			if err := output.Clip.Validate(); err != nil {
+				return fmt.Errorf("output %q: %s", output.FileName, err)
+			}
+		}
+	}
+	if p.Payload.CallbackURL != "" {
+		callbackURL, err := url.Parse(p.Payload.CallbackURL)
+		if err != nil || (callbackURL.Scheme != "http" && callbackURL.Scheme != "https") || callbackURL.Host == "" {
+			return errors.New("invalid callbackUrl: must be an absolute http or https URL")
+		}
+	} else if p.Payload.CallbackSecret != "" {
+		return errors.New("callbackSecret requires a callbackUrl")
+	}
 	return nil
 }
 
-// swagger:parameters getJob
-type getTranscodeJobInput struct {
+type jobIDInput struct {
 	// in: path
 	// required: true
 	JobID string `json:"jobId"`
 }
 
-func (p *getTranscodeJobInput) loadParams(paramsMap map[string]string) {
+func (p *jobIDInput) loadParams(paramsMap map[string]string) {
 	p.JobID = paramsMap["jobId"]
 }
 
+// swagger:parameters getJob
+type getTranscodeJobInput struct {
+	jobIDInput
+
+	// forces the API to query the provider for the status of the job,
+	// instead of returning the last known status
+	//
+	// in: query
+	Refresh bool `json:"refresh"`
+}
+
+func (p *getTranscodeJobInput) loadParams(paramsMap map[string]string, values url.Values) {
+	p.jobIDInput.loadParams(paramsMap)
+	p.Refresh, _ = strconv.ParseBool(values.Get("refresh"))
+}
+
 // swagger:parameters cancelJob
 type cancelTranscodeJobInput struct {
-	getTranscodeJobInput
+	jobIDInput
+}
+
+const (
+	defaultJobListLimit = 100
+	maxJobListLimit     = 1000
+)
+
+// swagger:parameters listJobs
+type listTranscodeJobsInput struct {
+	// name of the provider used in the job
+	//
+	// in: query
+	Provider string `json:"provider"`
+
+	// last known status of the job
+	//
+	// in: query
+	Status string `json:"status"`
+
+	// list only jobs created at or after the given time, in RFC 3339 format
+	//
+	// in: query
+	Since string `json:"since"`
+
+	// list only jobs created at or before the given time, in RFC 3339
+	// format
+	//
+	// in: query
+	Until string `json:"until"`
+
+	// list only jobs whose source media starts with the given prefix
+	//
+	// in: query
+	SourcePrefix string `json:"sourcePrefix"`
+
+	// list only jobs with at least one output using the given preset
+	//
+	// in: query
+	Preset string `json:"preset"`
+
+	// maximum number of jobs in the response, defaults to 100 and can't be
+	// greater than 1000
+	//
+	// in: query
+	Limit string `json:"limit"`
+
+	// cursor returned in a previous call, used for retrieving the next page
+	// of jobs
+	//
+	// in: query
+	Cursor string `json:"cursor"`
+}
+
+func (p *listTranscodeJobsInput) loadParams(query url.Values) {
+	p.Provider = query.Get("provider")
+	p.Status = query.Get("status")
+	p.Since = query.Get("since")
+	p.Until = query.Get("until")
+	p.SourcePrefix = query.Get("sourcePrefix")
+	p.Preset = query.Get("preset")
+	p.Limit = query.Get("limit")
+	p.Cursor = query.Get("cursor")
+}
+
+// JobFilter validates the parameters and converts them to a db.JobFilter.
+func (p *listTranscodeJobsInput) JobFilter() (db.JobFilter, error) {
+	filter := db.JobFilter{
+		ProviderName: p.Provider,
+		Status:       p.Status,
+		SourcePrefix: p.SourcePrefix,
+		PresetName:   p.Preset,
+		Limit:        defaultJobListLimit,
+	}
+	var err error
+	if p.Since != "" {
+		filter.Since, err = time.Parse(time.RFC3339, p.Since)
+		if err != nil {
+			return filter, fmt.Errorf("invalid since: %s", err)
+		}
+	}
+	if p.Until != "" {
+		filter.Until, err = time.Parse(time.RFC3339, p.Until)
+		if err != nil {
+			return filter, fmt.Errorf("invalid until: %s", err)
+		}
+	}
+	if p.Limit != "" {
+		limit, err := strconv.ParseUint(p.Limit, 10, 32)
+		if err != nil || limit == 0 || limit > maxJobListLimit {
+			return filter, fmt.Errorf("invalid limit: must be a number between 1 and %d", maxJobListLimit)
+		}
+		filter.Limit = uint(limit)
+	}
+	if p.Cursor != "" {
+		filter.After, err = decodeJobCursor(p.Cursor)
+		if err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+func encodeJobCursor(cursor db.JobCursor) string {
+	value := strconv.FormatInt(cursor.CreationTime.UnixNano(), 10) + ":" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func decodeJobCursor(value string) (db.JobCursor, error) {
+	var cursor db.JobCursor
+	errInvalidCursor := errors.New("invalid cursor")
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, errInvalidCursor
+	}
+	parts := strings.SplitN(string(data), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return cursor, errInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return cursor, errInvalidCursor
+	}
+	cursor.CreationTime = time.Unix(0, nanos).UTC()
+	cursor.ID = parts[1]
+	return cursor, nil
+}
+
+// swagger:parameters listJobNotifications
+type listJobNotificationsInput struct {
+	jobIDInput
 }
@@ -3,6 +3,7 @@ package service
 import (
 	"net/http"
 
+	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/provider"
 	"github.com/NYTimes/video-transcoding-api/swagger"
 )
@@ -57,6 +58,37 @@ func newJobStatusResponse(jobStatus *provider.JobStatus) *jobStatusResponse {
 	}
 }
 
+// JobList is a page of jobs returned by the listJobs operation.
+//
+// swagger:model
+type JobList struct {
+	// list of jobs, in ascending order of creation time
+	Jobs []db.Job `json:"jobs"`
+
+	// opaque cursor that can be used for retrieving the next page of jobs.
+	// It's omitted when there are no more jobs to list.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// response for the listJobs operation.
+//
+// swagger:response listJobs
+type listJobsResponse struct {
+	// in: body
+	Payload *JobList
+
+	baseResponse
+}
+
+func newListJobsResponse(jobList *JobList) *listJobsResponse {
+	return &listJobsResponse{
+		baseResponse: baseResponse{
+			payload: jobList,
+			status:  http.StatusOK,
+		},
+	}
+}
+
 // error returned when the given job data is not valid.
 //
 // swagger:response invalidJob
@@ -105,3 +137,36 @@ func newJobNotFoundProviderResponse(err error) *jobNotFoundProviderResponse {
 func (r *jobNotFoundProviderResponse) Result() (int, interface{}, error) {
 	return r.Error.Result()
 }
+
+// error returned when the given parameters for listing jobs are not valid.
+//
+// swagger:response invalidJobFilter
+type invalidJobFilterResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+func newInvalidJobFilterResponse(err error) *invalidJobFilterResponse {
+	return &invalidJobFilterResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusBadRequest)}
+}
+
+func (r *invalidJobFilterResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
+
+// response for the listJobNotifications operation. Contains the list of
+// notifications of the job, in the order they were created.
+//
+// swagger:response listJobNotifications
+type listJobNotificationsResponse struct {
+	// in: body
+	Notifications []db.Notification
+
+	baseResponse
+}
+
+func newListJobNotificationsResponse(notifications []db.Notification) *listJobNotificationsResponse {
+	return &listJobNotificationsResponse{
+		baseResponse: baseResponse{payload: notifications, status: http.StatusOK},
+	}
+}
@@ -0,0 +1,210 @@
+// Command transcoding-catalog imports and exports catalogs of presets,
+// talking to a running instance of the transcoding API.
+//
+// Exporting the presets of the API to a YAML file:
+//
+//     transcoding-catalog -api http://localhost:8080 export > catalog.yaml
+//
+// Checking what importing a catalog would change, without changing anything:
+//
+//     transcoding-catalog -api http://localhost:8080 import -dry-run catalog.yaml
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+type changePlan struct {
+	Name      string `json:"name"`
+	Action    string `json:"action"`
+	Error     string `json:"error"`
+	Providers map[string]struct {
+		Action   string `json:"action"`
+		PresetID string `json:"presetId"`
+		Error    string `json:"error"`
+	} `json:"providers"`
+}
+
+type catalogPlan struct {
+	DryRun  bool         `json:"dryRun"`
+	Presets []changePlan `json:"presets"`
+}
+
+func main() {
+	apiURL := flag.String("api", "http://localhost:8080", "URL of the transcoding API")
+	author := flag.String("author", os.Getenv("USER"), "author of the changes, recorded in the versions of presets")
+	flag.Usage = usage
+	flag.Parse()
+	client := catalogClient{apiURL: *apiURL, author: *author}
+	var err error
+	switch flag.Arg(0) {
+	case "export":
+		err = client.export(flag.Args()[1:])
+	case "import":
+		err = client.importCatalog(flag.Args()[1:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: %s [flags] <command> [arguments]
+
+commands:
+  export [-format yaml|json]                 writes the catalog of presets to the standard output
+  import [-dry-run] [-prune] <catalog file>  imports a catalog of presets from a YAML or JSON file
+
+flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+type catalogClient struct {
+	apiURL string
+	author string
+}
+
+func (c *catalogClient) export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "yaml", "format of the catalog, yaml or json")
+	flags.Parse(args)
+	resp, err := http.Get(c.apiURL + "/presets/export")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := readResponse(resp)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "json":
+		var out bytes.Buffer
+		if err = json.Indent(&out, data, "", "  "); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		data = out.Bytes()
+	case "yaml":
+		if data, err = jsonToYAML(data); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid format %q", *format)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func (c *catalogClient) importCatalog(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "show the plan of the import without applying it")
+	prune := flags.Bool("prune", false, "delete presets that are not in the catalog")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("import requires the path of the catalog file")
+	}
+	fileName := flags.Arg(0)
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	contentType := "application/x-yaml"
+	if filepath.Ext(fileName) == ".json" {
+		contentType = "application/json"
+	}
+	values := url.Values{}
+	values.Set("dryRun", strconv.FormatBool(*dryRun))
+	values.Set("prune", strconv.FormatBool(*prune))
+	req, err := http.NewRequest("POST", c.apiURL+"/presets/import?"+values.Encode(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Author", c.author)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var plan catalogPlan
+	if err = json.Unmarshal(body, &plan); err != nil || plan.Presets == nil {
+		return fmt.Errorf("importing catalog: %s: %s", resp.Status, body)
+	}
+	printPlan(&plan)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("importing catalog: %s", resp.Status)
+	}
+	return nil
+}
+
+func printPlan(plan *catalogPlan) {
+	if plan.DryRun {
+		fmt.Println("dry run, nothing was changed")
+	}
+	for _, change := range plan.Presets {
+		fmt.Printf("%-6s %s", change.Action, change.Name)
+		if change.Error != "" {
+			fmt.Printf(": %s", change.Error)
+		}
+		fmt.Println()
+		names := make([]string, 0, len(change.Providers))
+		for name := range change.Providers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			providerChange := change.Providers[name]
+			fmt.Printf("  %-6s %s", providerChange.Action, name)
+			if providerChange.PresetID != "" {
+				fmt.Printf(" (%s)", providerChange.PresetID)
+			}
+			if providerChange.Error != "" {
+				fmt.Printf(": %s", providerChange.Error)
+			}
+			fmt.Println()
+		}
+	}
+}
+
+func readResponse(resp *http.Response) ([]byte, error) {
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, data)
+	}
+	return data, nil
+}
+
+// jsonToYAML converts the catalog returned by the API to YAML. The catalog
+// doesn't have numbers, as all numeric parameters of presets are strings.
+func jsonToYAML(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(value)
+}